@@ -3,8 +3,11 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"godex/pkg/proxy"
 )
 
 func TestCheckCodexAuth(t *testing.T) {
@@ -284,3 +287,62 @@ func joinArgs(args []string) string {
 	}
 	return result
 }
+
+func TestRunAuthAddProfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("CODEX_HOME", filepath.Join(tmpDir, ".codex"))
+	t.Setenv("GODEX_AUTH_PATH", "")
+
+	codexDir := filepath.Join(tmpDir, ".codex")
+	os.MkdirAll(codexDir, 0755)
+	os.WriteFile(filepath.Join(codexDir, "auth.json"),
+		[]byte(`{"auth_mode":"chatgpt","tokens":{"access_token":"default-token"}}`), 0600)
+	workPath := filepath.Join(tmpDir, "work-auth.json")
+	os.WriteFile(workPath, []byte(`{"auth_mode":"chatgpt","tokens":{"access_token":"work-token"}}`), 0600)
+
+	if err := runAuth([]string{"add-profile", "--name", "work", "--auth-path", workPath}); err != nil {
+		t.Fatalf("add-profile: %v", err)
+	}
+	if err := runAuth([]string{"add-profile", "--name", "work"}); err == nil {
+		t.Error("add-profile without --auth-path succeeded")
+	}
+
+	status := checkCodexAuth()
+	if !status.Configured || len(status.Profiles) != 1 || status.Profiles[0].Name != "work" {
+		t.Errorf("status = %+v, want the work profile listed", status)
+	}
+}
+
+func TestRunAuthServiceAccount(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GODEX_PROXY_SERVICE_ACCOUNT_SECRET", "")
+	if err := runAuth([]string{"service-account", "add", "--label", "ci-bot"}); err == nil {
+		t.Error("service-account add without a secret succeeded")
+	}
+	t.Setenv("GODEX_PROXY_SERVICE_ACCOUNT_SECRET", "service-account-secret")
+	if err := runAuth([]string{"service-account", "add", "--label", "ci-bot", "--scope", "admin"}); err == nil {
+		t.Error("service-account add with an unknown scope succeeded")
+	}
+	keysPath := filepath.Join(t.TempDir(), "keys.json")
+	if err := runAuth([]string{"service-account", "add", "--label", "ci-bot", "--scope", "read", "--keys-path", keysPath}); err != nil {
+		t.Errorf("service-account add: %v", err)
+	}
+	store, err := proxy.LoadKeyStore(keysPath)
+	if err != nil {
+		t.Fatalf("LoadKeyStore: %v", err)
+	}
+	keys := store.List()
+	if len(keys) != 1 || !strings.HasPrefix(keys[0].ID, "sa_") || keys[0].Label != "ci-bot" {
+		t.Fatalf("recorded keys = %+v, want the service account", keys)
+	}
+	if err := runAuth([]string{"service-account", "revoke", "--keys-path", keysPath, keys[0].ID}); err != nil {
+		t.Errorf("service-account revoke: %v", err)
+	}
+	if err := runAuth([]string{"service-account", "revoke", "--keys-path", keysPath, "sa_missing"}); err == nil {
+		t.Error("revoking an unknown service account succeeded")
+	}
+	if err := runAuth([]string{"service-account", "list"}); err == nil {
+		t.Error("unknown service-account subcommand succeeded")
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"godex/pkg/harness"
+)
+
+// batchPrompt is one prompt of exec --prompts-file.
+type batchPrompt struct {
+	Index int // line number in the file
+	Text  string
+}
+
+// batchResult is one line of results.jsonl, written by --prompts-file with
+// --json.
+type batchResult struct {
+	PromptIndex int    `json:"prompt_index"`
+	Prompt      string `json:"prompt"`
+	Response    string `json:"response"`
+	Model       string `json:"model"`
+	TokensIn    int    `json:"tokens_in"`
+	TokensOut   int    `json:"tokens_out"`
+	LatencyMS   int64  `json:"latency_ms"`
+}
+
+// batchError is one line of errors.jsonl.
+type batchError struct {
+	PromptIndex int    `json:"prompt_index"`
+	Prompt      string `json:"prompt"`
+	Error       string `json:"error"`
+}
+
+// readBatchPrompts reads one prompt per non-blank line of path.
+func readBatchPrompts(path string) ([]batchPrompt, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("read prompts file: %w", err)
+	}
+	defer f.Close()
+	var prompts []batchPrompt
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if text := strings.TrimSpace(scanner.Text()); text != "" {
+			prompts = append(prompts, batchPrompt{Index: line, Text: text})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read prompts file: %w", err)
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("prompts file %s has no prompts", path)
+	}
+	return prompts, nil
+}
+
+// runPromptBatch runs each prompt as its own turn, built from base, with at
+// most concurrency in flight. Each response is written to
+// outputDir/prompt-<line>.txt, or with jsonOnly appended to
+// outputDir/results.jsonl. Failed prompts are appended to
+// outputDir/errors.jsonl and reported in the returned error once the rest
+// have run. Progress goes to progress.
+func runPromptBatch(ctx context.Context, prompts []batchPrompt, base *harness.Turn, collect func(context.Context, *harness.Turn) (*harness.TurnResult, error), concurrency int, outputDir string, jsonOnly bool, progress io.Writer) error {
+	if err := os.MkdirAll(outputDir, 0o755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+	var results *json.Encoder
+	if jsonOnly {
+		f, err := os.Create(filepath.Join(outputDir, "results.jsonl"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		results = json.NewEncoder(f)
+	}
+	errorsPath := filepath.Join(outputDir, "errors.jsonl")
+	_ = os.Remove(errorsPath)
+	var errorsFile *os.File
+	defer func() {
+		if errorsFile != nil {
+			errorsFile.Close()
+		}
+	}()
+
+	turns := make([]*harness.Turn, len(prompts))
+	for i, p := range prompts {
+		t := *base
+		t.Messages = append(append([]harness.Message(nil), base.Messages...), harness.Message{Role: "user", Content: p.Text})
+		turns[i] = &t
+	}
+	timed := func(ctx context.Context, turn *harness.Turn) (*harness.TurnResult, error) {
+		start := time.Now()
+		result, err := collect(ctx, turn)
+		if result != nil && result.Duration == 0 {
+			result.Duration = time.Since(start)
+		}
+		return result, err
+	}
+
+	var done, failed int
+	var writeErr error
+	fail := func(p batchPrompt, err error) {
+		failed++
+		if errorsFile == nil {
+			f, ferr := os.Create(errorsPath)
+			if ferr != nil {
+				writeErr = ferr
+				return
+			}
+			errorsFile = f
+		}
+		if err := json.NewEncoder(errorsFile).Encode(batchError{PromptIndex: p.Index, Prompt: p.Text, Error: err.Error()}); err != nil {
+			writeErr = err
+		}
+	}
+	runErr := harness.RunBatch(ctx, timed, turns, func(i int, result *harness.TurnResult, err error) {
+		defer func() {
+			done++
+			fmt.Fprintf(progress, "\r%d/%d prompts done", done, len(prompts))
+		}()
+		p := prompts[i]
+		if err != nil {
+			fail(p, err)
+			return
+		}
+		if results != nil {
+			rec := batchResult{
+				PromptIndex: p.Index,
+				Prompt:      p.Text,
+				Response:    result.FinalText,
+				Model:       base.Model,
+				LatencyMS:   result.Duration.Milliseconds(),
+			}
+			if result.Usage != nil {
+				rec.TokensIn, rec.TokensOut = result.Usage.InputTokens, result.Usage.OutputTokens
+			}
+			if err := results.Encode(rec); err != nil {
+				writeErr = err
+			}
+			return
+		}
+		name := filepath.Join(outputDir, fmt.Sprintf("prompt-%04d.txt", p.Index))
+		if err := os.WriteFile(name, []byte(result.FinalText+"\n"), 0o644); err != nil {
+			fail(p, err)
+		}
+	}, harness.LoopOptions{MaxConcurrency: concurrency})
+	fmt.Fprintln(progress)
+
+	switch {
+	case writeErr != nil:
+		return writeErr
+	case runErr != nil:
+		return runErr
+	case failed > 0:
+		return fmt.Errorf("%d of %d prompts failed; see %s", failed, len(prompts), errorsPath)
+	}
+	return nil
+}
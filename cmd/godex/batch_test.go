@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"godex/pkg/harness"
+)
+
+func TestRunPromptBatch(t *testing.T) {
+	dir := t.TempDir()
+	promptsPath := filepath.Join(dir, "prompts.txt")
+	if err := os.WriteFile(promptsPath, []byte("hello\n\nbad\nworld\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prompts, err := readBatchPrompts(promptsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 3 || prompts[1] != (batchPrompt{Index: 3, Text: "bad"}) {
+		t.Fatalf("prompts = %+v, want 3 with line numbers", prompts)
+	}
+
+	collect := func(ctx context.Context, turn *harness.Turn) (*harness.TurnResult, error) {
+		msgs := turn.Messages
+		if len(msgs) != 2 || msgs[0].Role != "system" {
+			return nil, errors.New("base history not kept")
+		}
+		text := msgs[1].Content
+		if text == "bad" {
+			return nil, errors.New("backend down")
+		}
+		return &harness.TurnResult{FinalText: "re: " + text, Usage: &harness.UsageEvent{InputTokens: 7, OutputTokens: 3}}, nil
+	}
+	base := &harness.Turn{Model: "gpt-test", Messages: []harness.Message{{Role: "system", Content: "be brief"}}}
+
+	for _, jsonOnly := range []bool{false, true} {
+		out := filepath.Join(dir, "out")
+		if jsonOnly {
+			out += "-json"
+		}
+		var progress bytes.Buffer
+		err := runPromptBatch(context.Background(), prompts, base, collect, 2, out, jsonOnly, &progress)
+		if err == nil || !strings.Contains(err.Error(), "1 of 3 prompts failed") {
+			t.Errorf("json=%v: err = %v, want one failure", jsonOnly, err)
+		}
+		if !strings.Contains(progress.String(), "3/3 prompts done") {
+			t.Errorf("json=%v: progress = %q", jsonOnly, progress.String())
+		}
+		errs, err := os.ReadFile(filepath.Join(out, "errors.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var failure batchError
+		if err := json.Unmarshal(errs, &failure); err != nil || failure.PromptIndex != 3 || failure.Error != "backend down" {
+			t.Errorf("json=%v: errors.jsonl = %s", jsonOnly, errs)
+		}
+
+		if !jsonOnly {
+			got, err := os.ReadFile(filepath.Join(out, "prompt-0004.txt"))
+			if err != nil || string(got) != "re: world\n" {
+				t.Errorf("prompt-0004.txt = %q (%v)", got, err)
+			}
+			if _, err := os.Stat(filepath.Join(out, "prompt-0003.txt")); !os.IsNotExist(err) {
+				t.Errorf("failed prompt wrote a result file")
+			}
+			continue
+		}
+		buf, err := os.ReadFile(filepath.Join(out, "results.jsonl"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var results []batchResult
+		for _, line := range strings.Split(strings.TrimSpace(string(buf)), "\n") {
+			var r batchResult
+			if err := json.Unmarshal([]byte(line), &r); err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, r)
+		}
+		sort.Slice(results, func(i, j int) bool { return results[i].PromptIndex < results[j].PromptIndex })
+		if len(results) != 2 {
+			t.Fatalf("results = %+v, want 2", results)
+		}
+		want := batchResult{PromptIndex: 1, Prompt: "hello", Response: "re: hello", Model: "gpt-test", TokensIn: 7, TokensOut: 3}
+		results[0].LatencyMS = 0
+		if results[0] != want {
+			t.Errorf("result = %+v, want %+v", results[0], want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const ansiReset = "\x1b[0m"
+
+// defaultColorScheme maps each output role to its ANSI SGR parameters.
+// Model output has no role and is never colored.
+var defaultColorScheme = map[string]string{
+	"tool":    "36", // tool call names
+	"error":   "31",
+	"added":   "32", // diff lines
+	"removed": "31",
+	"hunk":    "36",
+	"header":  "1", // diff file headers and summaries
+}
+
+// colorNames are the names GODEX_COLOR_SCHEME accepts besides raw SGR
+// parameters such as "38;5;208".
+var colorNames = map[string]string{
+	"bold": "1", "dim": "2", "italic": "3", "underline": "4",
+	"black": "30", "red": "31", "green": "32", "yellow": "33",
+	"blue": "34", "magenta": "35", "cyan": "36", "white": "37",
+	"bright-black": "90", "bright-red": "91", "bright-green": "92", "bright-yellow": "93",
+	"bright-blue": "94", "bright-magenta": "95", "bright-cyan": "96", "bright-white": "97",
+}
+
+var sgrRE = regexp.MustCompile(`^[0-9]+(;[0-9]+)*$`)
+
+// colorConfig is the resolved --color setting and scheme.
+type colorConfig struct {
+	mode   string // always, never or auto
+	scheme map[string]string
+}
+
+// colors paints text for one output stream. The zero value paints nothing.
+type colors struct {
+	scheme map[string]string
+}
+
+// paint wraps s in the escape codes of role, if any.
+func (c colors) paint(role, s string) string {
+	code := c.scheme[role]
+	if code == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + ansiReset
+}
+
+// loadColorConfig resolves the color mode from mode, normally --color, or
+// GODEX_COLOR, and the scheme from the defaults and GODEX_COLOR_SCHEME.
+func loadColorConfig(mode string) (colorConfig, error) {
+	mode, err := resolveColorMode(mode, os.Getenv, runtime.GOOS)
+	if err != nil {
+		return colorConfig{}, err
+	}
+	scheme, err := loadColorScheme(os.Getenv("GODEX_COLOR_SCHEME"))
+	if err != nil {
+		return colorConfig{}, err
+	}
+	return colorConfig{mode: mode, scheme: scheme}, nil
+}
+
+// resolveColorMode picks flagValue, then GODEX_COLOR, then a default: auto,
+// except on Windows, where it is never unless COLORTERM=truecolor.
+func resolveColorMode(flagValue string, getenv func(string) string, goos string) (string, error) {
+	mode := strings.ToLower(strings.TrimSpace(flagValue))
+	if mode == "" {
+		mode = strings.ToLower(strings.TrimSpace(getenv("GODEX_COLOR")))
+	}
+	switch mode {
+	case "always", "never", "auto":
+		return mode, nil
+	case "":
+		if goos == "windows" && getenv("COLORTERM") != "truecolor" {
+			return "never", nil
+		}
+		return "auto", nil
+	default:
+		return "", fmt.Errorf("invalid color mode %q (use auto, always or never)", mode)
+	}
+}
+
+// loadColorScheme overlays the JSON object of role to color in path, if
+// set, on defaultColorScheme. A color is a space-separated list of names
+// or SGR parameters; an empty one turns the role's color off.
+func loadColorScheme(path string) (map[string]string, error) {
+	scheme := make(map[string]string, len(defaultColorScheme))
+	for role, code := range defaultColorScheme {
+		scheme[role] = code
+	}
+	if path == "" {
+		return scheme, nil
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read GODEX_COLOR_SCHEME: %w", err)
+	}
+	var custom map[string]string
+	if err := json.Unmarshal(buf, &custom); err != nil {
+		return nil, fmt.Errorf("parse GODEX_COLOR_SCHEME %s: %w", path, err)
+	}
+	for role, spec := range custom {
+		if _, ok := defaultColorScheme[role]; !ok {
+			return nil, fmt.Errorf("GODEX_COLOR_SCHEME %s: unknown role %q (use tool, error, added, removed, hunk or header)", path, role)
+		}
+		var codes []string
+		for _, field := range strings.Fields(spec) {
+			code, ok := colorNames[strings.ToLower(field)]
+			if !ok && !sgrRE.MatchString(field) {
+				return nil, fmt.Errorf("GODEX_COLOR_SCHEME %s: unknown color %q for %s", path, field, role)
+			}
+			if !ok {
+				code = field
+			}
+			codes = append(codes, code)
+		}
+		scheme[role] = strings.Join(codes, ";")
+	}
+	return scheme, nil
+}
+
+// forFile returns the colors for output written to f. In auto mode, f
+// must be a terminal and NO_COLOR unset.
+func (c colorConfig) forFile(f *os.File) colors {
+	switch c.mode {
+	case "always":
+		return colors{scheme: c.scheme}
+	case "auto":
+		if os.Getenv("NO_COLOR") == "" && term.IsTerminal(int(f.Fd())) {
+			return colors{scheme: c.scheme}
+		}
+	}
+	return colors{}
+}
+
+// colorFromArgs returns the --color value in args. Like
+// configPathFromArgs, it scans args before flag parsing, so that main can
+// color errors from any subcommand.
+func colorFromArgs(args []string) string {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "--color=") {
+			return strings.TrimPrefix(arg, "--color=")
+		}
+		if arg == "--color" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// printError prints err to stderr for main, with "error:" in the error
+// color.
+func printError(err error) {
+	var c colors
+	if cfg, cerr := loadColorConfig(colorFromArgs(os.Args[2:])); cerr == nil {
+		c = cfg.forFile(os.Stderr)
+	}
+	fmt.Fprintln(os.Stderr, c.paint("error", "error:"), err)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveColorMode(t *testing.T) {
+	tests := []struct {
+		flag string
+		env  map[string]string
+		goos string
+		want string
+	}{
+		{"", nil, "linux", "auto"},
+		{"never", map[string]string{"GODEX_COLOR": "always"}, "linux", "never"},
+		{"", map[string]string{"GODEX_COLOR": "Always"}, "linux", "always"},
+		{"", nil, "windows", "never"},
+		{"", map[string]string{"COLORTERM": "truecolor"}, "windows", "auto"},
+		{"always", nil, "windows", "always"},
+	}
+	for _, tt := range tests {
+		got, err := resolveColorMode(tt.flag, func(k string) string { return tt.env[k] }, tt.goos)
+		if err != nil || got != tt.want {
+			t.Errorf("resolveColorMode(%q, %v, %s) = %q, %v; want %q", tt.flag, tt.env, tt.goos, got, err, tt.want)
+		}
+	}
+	if _, err := resolveColorMode("sometimes", func(string) string { return "" }, "linux"); err == nil {
+		t.Error("accepted an unknown mode")
+	}
+}
+
+func TestLoadColorScheme(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "colors.json")
+	if err := os.WriteFile(path, []byte(`{"tool": "bold magenta", "added": "38;5;40", "hunk": ""}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	scheme, err := loadColorScheme(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scheme["tool"] != "1;35" || scheme["added"] != "38;5;40" || scheme["hunk"] != "" || scheme["error"] != "31" {
+		t.Errorf("scheme = %v", scheme)
+	}
+	c := colors{scheme: scheme}
+	if got := c.paint("hunk", "@@"); got != "@@" {
+		t.Errorf("role with no color painted: %q", got)
+	}
+	if defaultColorScheme["tool"] != "36" {
+		t.Error("loading a scheme changed the defaults")
+	}
+
+	for _, bad := range []string{`{"tools": "red"}`, `{"tool": "mauve"}`, `["red"]`} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadColorScheme(path); err == nil {
+			t.Errorf("accepted scheme %s", bad)
+		}
+	}
+}
+
+func TestColorConfigForFile(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Setenv("NO_COLOR", "")
+	if got := (colorConfig{mode: "auto", scheme: defaultColorScheme}).forFile(f).paint("error", "x"); got != "x" {
+		t.Errorf("auto colored a file: %q", got)
+	}
+	if got := (colorConfig{mode: "always", scheme: defaultColorScheme}).forFile(f).paint("error", "x"); !strings.HasPrefix(got, "\x1b[31m") {
+		t.Errorf("always did not color: %q", got)
+	}
+	if got := colorFromArgs([]string{"--prompt", "hi", "--color=never"}); got != "never" {
+		t.Errorf("colorFromArgs = %q", got)
+	}
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"godex/pkg/config"
+	"godex/pkg/proxy"
+)
+
+// Completion kinds of a flag's value.
+const (
+	flagBool  = iota // takes no value
+	flagArg          // takes a value godex cannot suggest
+	flagModel        // takes a model name
+)
+
+type completionFlag struct {
+	name string
+	kind int
+	desc string
+}
+
+// completionCommand is one node of the command tree godex __complete walks.
+type completionCommand struct {
+	name  string
+	desc  string
+	flags []completionFlag
+	subs  []completionCommand
+	// args lists the values of the first positional argument, or with
+	// modelArg the first positional argument is a model name.
+	args     []string
+	modelArg bool
+}
+
+var configCompletionFlags = []completionFlag{
+	{"config", flagArg, "Config file path"},
+	{"profile", flagArg, "Config profile under ~/.config/godex/profiles (default $GODEX_PROFILE)"},
+}
+
+// completionTree mirrors the commands main dispatches. The exec, proxy and
+// probe flags must match their FlagSets; TestCompletionFlagsMatchMain
+// checks them against main.go.
+var completionTree = completionCommand{
+	name: "godex",
+	subs: []completionCommand{
+		{
+			name: "exec",
+			desc: "Run a Responses API call",
+			flags: append([]completionFlag{
+				{"prompt", flagArg, "User prompt"},
+				{"stdin", flagBool, "With --prompt, send piped stdin as a second user message"},
+				{"model", flagModel, "Model name"},
+				{"instructions", flagArg, "Optional system instructions"},
+				{"system", flagArg, "Alias for --instructions"},
+				{"append-system-prompt", flagArg, "Append to system instructions"},
+				{"trace", flagBool, "Print raw SSE event JSON"},
+				{"json", flagBool, "Emit JSON events only (no text output)"},
+				{"allow-refresh", flagBool, "Allow network token refresh on 401"},
+				{"auto-tools", flagBool, "Automatically run tool loop with static outputs"},
+				{"parallel-tools", flagArg, "With --auto-tools, run up to N tool calls per turn concurrently (0 = serial)"},
+				{"web-search", flagBool, "Enable web_search tool"},
+				{"tool-choice", flagArg, "Tool choice: auto|required|function:<name>"},
+				{"input-json", flagArg, "JSON array of response input items (overrides --prompt)"},
+				{"mock", flagBool, "Mock mode: no network, emit synthetic stream"},
+				{"mock-mode", flagArg, "Mock mode: echo|text|tool-call|tool-loop"},
+				{"tool", flagArg, "Tool spec (repeatable): web_search or name:json=/path/schema.json"},
+				{"tool-output", flagArg, "Static tool output: name=value or name=$args (repeatable)"},
+				{"session-id", flagArg, "Optional session id (reuses prompt cache key)"},
+				{"session-file", flagArg, "Continue the conversation saved in this file and save it back (codex models)"},
+				{"log-requests", flagArg, "Write JSON request payload to file"},
+				{"log-responses", flagArg, "Append JSONL response events to file"},
+				{"provider-key", flagArg, "API key for non-Codex backends (or set via env per provider)"},
+				{"upstream-audit-path", flagArg, "Upstream model SSE audit JSONL path"},
+				{"native-tools", flagBool, "Use Codex native tools (shell, apply_patch, update_plan) instead of proxy mode"},
+				{"count-tokens", flagBool, "Print the estimated prompt token count and exit without sending"},
+				{"dry-run", flagBool, "Validate the request and print it as JSON without sending it"},
+				{"url", flagArg, "With --dry-run, proxy URL to ask which backend would serve --model"},
+				{"key", flagArg, "With --dry-run and --url, proxy API key (or set GODEX_API_KEY)"},
+				{"stop-sequence", flagArg, "Stop generating at this sequence (repeatable, up to 4)"},
+				{"image", flagArg, "Attach a local image file to the prompt (repeatable; png, jpeg, gif or webp)"},
+				{"diff-mode", flagBool, "Print apply_patch calls as colored unified diffs"},
+				{"color", flagArg, "Color output: auto, always or never (default $GODEX_COLOR, else auto)"},
+				{"image-gen", flagBool, "Generate images from --prompt with --model (openai backends) instead of running a turn"},
+				{"image-size", flagArg, "With --image-gen, image size (e.g. 1024x1024)"},
+				{"image-quality", flagArg, "With --image-gen, image quality (e.g. standard, hd)"},
+				{"image-n", flagArg, "With --image-gen, number of images"},
+				{"image-format", flagArg, "With --image-gen, url or b64_json"},
+				{"image-out", flagArg, "With --image-gen, directory for b64_json images"},
+				{"repl", flagBool, "Read prompts from stdin one line at a time, keeping the conversation (Ctrl-D exits)"},
+				{"prompts-file", flagArg, "Run each line of this file as a separate prompt, writing results to --output-dir"},
+				{"output-dir", flagArg, "With --prompts-file, directory for results and errors.jsonl"},
+				{"concurrency", flagArg, "With --prompts-file, how many prompts to run at once"},
+				{"template", flagArg, "Render this Go text/template as the prompt (a .json template as --input-json)"},
+				{"var", flagArg, "Template variable KEY=VALUE (repeatable)"},
+				{"vars-file", flagArg, "JSON object of template variables (--var overrides)"},
+				{"template-delims", flagArg, "Template left and right delimiters, separated by a space"},
+				{"export-history", flagArg, "After the run, write the conversation to this JSON file (readable by --input-json)"},
+				{"export-sanitized", flagBool, "With --export-history, redact texts longer than 200 characters"},
+			}, configCompletionFlags...),
+		},
+		{
+			name: "proxy",
+			desc: "Run the OpenAI-compatible proxy",
+			flags: append([]completionFlag{
+				{"listen", flagArg, "Listen address"},
+				{"cert", flagArg, "TLS certificate file (enables HTTPS with --key)"},
+				{"key", flagArg, "TLS private key file (enables HTTPS with --cert)"},
+				{"api-key", flagArg, "API key"},
+				{"model", flagModel, "Model name"},
+				{"base-url", flagArg, "Upstream base URL"},
+				{"originator", flagArg, "Originator header"},
+				{"user-agent", flagArg, "User-Agent header"},
+				{"allow-refresh", flagBool, "Allow network token refresh on 401"},
+				{"allow-any-key", flagBool, "Allow any bearer token"},
+				{"auth-path", flagArg, "Auth file path (defaults to ~/.codex/auth.json)"},
+				{"cache-ttl", flagArg, "Prompt cache TTL"},
+				{"log-level", flagArg, "Log level (debug|info|warn|error)"},
+				{"log-requests", flagBool, "Log HTTP requests"},
+				{"keys-path", flagArg, "API keys file"},
+				{"rate", flagArg, "Default rate limit (e.g. 60/m)"},
+				{"burst", flagArg, "Default rate burst"},
+				{"quota-tokens", flagArg, "Default token quota (0 = none)"},
+				{"stats-path", flagArg, "Usage stats JSONL path (empty disables history)"},
+				{"stats-summary", flagArg, "Usage summary JSON path"},
+				{"stats-max-bytes", flagArg, "Max stats file size before rotation"},
+				{"stats-max-backups", flagArg, "Max rotated stats files to keep"},
+				{"events-path", flagArg, "Proxy events JSONL path"},
+				{"events-max-bytes", flagArg, "Max events file size before rotation"},
+				{"events-max-backups", flagArg, "Max rotated events files to keep"},
+				{"trace-path", flagArg, "Deep trace JSONL path (request/response payloads)"},
+				{"trace-max-bytes", flagArg, "Max trace file size before rotation"},
+				{"trace-max-backups", flagArg, "Max rotated trace files to keep"},
+				{"upstream-audit-path", flagArg, "Upstream model SSE audit JSONL path"},
+				{"meter-window", flagArg, "Metering window duration (e.g. 24h); empty disables window"},
+				{"drain-timeout", flagArg, "Max time to wait for in-flight requests on shutdown"},
+				{"heartbeat-interval", flagArg, "SSE keepalive ping interval on streams (0 disables)"},
+				{"max-request-bytes", flagArg, "Max JSON request body size; larger requests get 413"},
+				{"cors-allow-origins", flagArg, "Comma-separated origins allowed for browser clients (* = any; empty disables CORS)"},
+				{"allow-ips", flagArg, "Comma-separated CIDRs allowed to connect (empty = any)"},
+				{"deny-ips", flagArg, "Comma-separated CIDRs rejected with 403"},
+				{"trust-proxy-headers", flagBool, "Use X-Forwarded-For/X-Real-IP for the client address"},
+				{"response-header", flagArg, "Header added to every response as Key=Value (repeatable; ${ENV} expanded)"},
+				{"sync-aliases", flagBool, "Update model aliases from providers on startup"},
+				{"native-tools", flagBool, "Use Codex native tools (shell, apply_patch) instead of proxy mode"},
+			}, configCompletionFlags...),
+			subs: []completionCommand{
+				{name: "keys", desc: "Manage proxy API keys", subs: []completionCommand{
+					{name: "add", desc: "Create a key"},
+					{name: "list", desc: "List keys"},
+					{name: "update", desc: "Change a key's limits"},
+					{name: "revoke", desc: "Revoke a key"},
+					{name: "rotate", desc: "Replace a key's secret"},
+					{name: "bulk", desc: "Apply a file of key operations"},
+				}},
+				{name: "usage", desc: "Show per-key usage", subs: []completionCommand{
+					{name: "list", desc: "List usage by key"},
+					{name: "show", desc: "Show one key's usage"},
+				}},
+				{name: "replay", desc: "Replay a traced request"},
+				{name: "attach", desc: "Follow a running proxy's logs"},
+			},
+		},
+		{
+			name:     "probe",
+			desc:     "Check a model and its routing",
+			modelArg: true,
+			flags: []completionFlag{
+				{"url", flagArg, "proxy URL"},
+				{"key", flagArg, "API key (or set GODEX_API_KEY)"},
+				{"json", flagBool, "output as JSON"},
+				{"explain", flagBool, "explain the routing decision"},
+			},
+		},
+		{name: "auth", desc: "Manage backend authentication", subs: []completionCommand{
+			{name: "status", desc: "Show credential status"},
+			{name: "setup", desc: "Set up credentials"},
+			{name: "add-profile", desc: "Add an auth profile"},
+			{name: "service-account", desc: "Manage service accounts", subs: []completionCommand{
+				{name: "add", desc: "Create a service account"},
+				{name: "revoke", desc: "Revoke a service account"},
+			}},
+		}},
+		{name: "aliases", desc: "Manage model aliases", subs: []completionCommand{
+			{name: "list", desc: "List model aliases"},
+			{name: "update", desc: "Update aliases from providers"},
+		}},
+		{name: "config", desc: "Create, print or check a config file", subs: []completionCommand{
+			{name: "init", desc: "Write a template config"},
+			{name: "schema", desc: "Print the config JSON Schema"},
+			{name: "show", desc: "Print the effective config"},
+			{name: "validate", desc: "Check a config file"},
+		}},
+		{name: "completion", desc: "Print a shell completion script", args: completionShells},
+		{name: "version", desc: "Show the build version"},
+	},
+}
+
+var completionShells = []string{"bash", "zsh", "fish", "powershell"}
+
+// fallbackCompletionModels is offered for model names when the proxy cannot
+// be asked.
+var fallbackCompletionModels = []string{
+	"gpt-5.3-codex",
+	"gpt-5.2-codex",
+	"gpt-5.2-pro",
+	"gpt-5.1-codex-mini",
+	"gpt-5-mini",
+	"claude-opus-4-6",
+	"claude-sonnet-4-6",
+	"claude-haiku-4-5",
+}
+
+// completionCandidate is one line of godex __complete output.
+type completionCandidate struct {
+	Value string
+	Desc  string
+}
+
+// runCompletion prints the completion script for shell.
+func runCompletion(args []string, w io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: godex completion bash|zsh|fish|powershell")
+	}
+	switch args[0] {
+	case "bash":
+		_, err := io.WriteString(w, bashCompletion)
+		return err
+	case "zsh":
+		_, err := io.WriteString(w, zshCompletion)
+		return err
+	case "fish":
+		_, err := io.WriteString(w, fishCompletion)
+		return err
+	case "powershell":
+		_, err := io.WriteString(w, powershellCompletion)
+		return err
+	default:
+		return fmt.Errorf("unsupported shell %q (use bash, zsh, fish or powershell)", args[0])
+	}
+}
+
+// runComplete implements the hidden godex __complete command the scripts
+// call: args are the words after "godex", the last being the word under
+// the cursor, and each candidate is printed as "value\tdescription".
+func runComplete(args []string, w io.Writer) error {
+	models := func() []completionCandidate {
+		return completionModels(configPathFromArgs(args))
+	}
+	for _, c := range completeWords(args, models) {
+		if _, err := fmt.Fprintf(w, "%s\t%s\n", c.Value, c.Desc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completeWords returns the candidates for the last of words. models is
+// only called when a model name is wanted.
+func completeWords(words []string, models func() []completionCandidate) []completionCandidate {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur := words[len(words)-1]
+	cmd := &completionTree
+	positional := 0
+	var pending *completionFlag
+	for _, word := range words[:len(words)-1] {
+		if pending != nil {
+			pending = nil
+			continue
+		}
+		if strings.HasPrefix(word, "-") {
+			name := strings.TrimLeft(word, "-")
+			if f := cmd.flag(name); f != nil && f.kind != flagBool && !strings.Contains(name, "=") {
+				pending = f
+			}
+			continue
+		}
+		if sub := cmd.sub(word); sub != nil && positional == 0 {
+			cmd = sub
+			continue
+		}
+		positional++
+	}
+
+	var all []completionCandidate
+	switch {
+	case pending != nil && pending.kind == flagModel:
+		all = models()
+	case pending != nil:
+		return nil
+	case strings.HasPrefix(cur, "-"):
+		for _, f := range cmd.flags {
+			all = append(all, completionCandidate{Value: "--" + f.name, Desc: f.desc})
+		}
+	case positional > 0:
+		return nil
+	case cmd.modelArg:
+		all = models()
+	default:
+		for _, sub := range cmd.subs {
+			all = append(all, completionCandidate{Value: sub.name, Desc: sub.desc})
+		}
+		for _, arg := range cmd.args {
+			all = append(all, completionCandidate{Value: arg})
+		}
+	}
+	var out []completionCandidate
+	for _, c := range all {
+		if strings.HasPrefix(c.Value, cur) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (c *completionCommand) flag(name string) *completionFlag {
+	for i := range c.flags {
+		if c.flags[i].name == name {
+			return &c.flags[i]
+		}
+	}
+	return nil
+}
+
+func (c *completionCommand) sub(name string) *completionCommand {
+	for i := range c.subs {
+		if c.subs[i].name == name {
+			return &c.subs[i]
+		}
+	}
+	return nil
+}
+
+// completionModels lists the models of the proxy configured in
+// configPath, authenticating with GODEX_API_KEY, or falls back to
+// fallbackCompletionModels.
+func completionModels(configPath string) []completionCandidate {
+	key := os.Getenv("GODEX_API_KEY")
+	if key != "" {
+		if cfg, _, err := config.LoadFrom(configPath); err == nil {
+			if models, err := fetchProxyModels(proxyURL(cfg.Proxy), key); err == nil && len(models) > 0 {
+				return models
+			}
+		}
+	}
+	out := make([]completionCandidate, len(fallbackCompletionModels))
+	for i, m := range fallbackCompletionModels {
+		out[i] = completionCandidate{Value: m}
+	}
+	return out
+}
+
+// proxyURL returns the base URL a local client reaches the proxy at.
+func proxyURL(cfg config.ProxyConfig) string {
+	scheme := "http"
+	if cfg.TLSCertFile != "" {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(cfg.Listen)
+	if err != nil {
+		return scheme + "://" + cfg.Listen
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+// fetchProxyModels asks the proxy at baseURL for its models. The short
+// timeout keeps a missing proxy from stalling the shell.
+func fetchProxyModels(baseURL, key string) ([]completionCandidate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/v1/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("list models: %s", resp.Status)
+	}
+	var list proxy.OpenAIModelsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, err
+	}
+	out := make([]completionCandidate, 0, len(list.Data))
+	for _, m := range list.Data {
+		out = append(out, completionCandidate{Value: m.ID, Desc: m.OwnedBy})
+	}
+	return out, nil
+}
+
+const bashCompletion = `# godex bash completion. Install with: godex completion bash >> ~/.bashrc
+_godex_complete() {
+    local IFS=$'\n'
+    local out
+    out=$(godex __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null) || return
+    COMPREPLY=($(printf '%s\n' "$out" | cut -f1))
+}
+complete -o default -F _godex_complete godex
+`
+
+const zshCompletion = `# godex zsh completion. Install with: godex completion zsh >> ~/.zshrc
+# (after compinit).
+_godex_complete() {
+    local -a candidates
+    local line
+    for line in "${(@f)$(godex __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}"; do
+        [[ -z $line ]] && continue
+        candidates+=("${${line%%$'\t'*}//:/\\:}:${line#*$'\t'}")
+    done
+    if (( ${#candidates} )); then
+        _describe 'godex' candidates
+    else
+        _files
+    fi
+}
+compdef _godex_complete godex
+`
+
+const fishCompletion = `# godex fish completion. Install with:
+#   godex completion fish > ~/.config/fish/completions/godex.fish
+function __godex_complete
+    set -l args (commandline -opc)
+    set -e args[1]
+    godex __complete $args (commandline -ct) 2>/dev/null
+end
+complete -c godex -f -a '(__godex_complete)'
+`
+
+const powershellCompletion = `# godex PowerShell completion. Install with:
+#   godex completion powershell >> $PROFILE
+Register-ArgumentCompleter -Native -CommandName godex -ScriptBlock {
+    param($wordToComplete, $commandAst, $cursorPosition)
+    $words = @($commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() })
+    if ($wordToComplete -eq '') {
+        # Windows PowerShell drops empty native arguments.
+        if ($PSVersionTable.PSVersion -lt [version]'7.3') { $words += '""' } else { $words += '' }
+    }
+    godex __complete @words 2>$null | ForEach-Object {
+        $value, $desc = $_ -split "` + "`t" + `", 2
+        if (-not $desc) { $desc = $value }
+        [System.Management.Automation.CompletionResult]::new($value, $value, 'ParameterValue', $desc)
+    }
+}
+`
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"godex/pkg/config"
+)
+
+func TestCompleteWords(t *testing.T) {
+	models := func() []completionCandidate {
+		return []completionCandidate{{Value: "gpt-test"}, {Value: "sonnet"}}
+	}
+	values := func(cs []completionCandidate) []string {
+		var out []string
+		for _, c := range cs {
+			out = append(out, c.Value)
+		}
+		return out
+	}
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"ex"}, []string{"exec"}},
+		{[]string{"proxy", "keys", "r"}, []string{"revoke", "rotate"}},
+		{[]string{"exec", "--mock-m"}, []string{"--mock-mode"}},
+		{[]string{"exec", "--model", ""}, []string{"gpt-test", "sonnet"}},
+		{[]string{"exec", "--json", "--model", "s"}, []string{"sonnet"}},
+		{[]string{"exec", "--prompt", ""}, nil},
+		{[]string{"probe", "--json", "g"}, []string{"gpt-test"}},
+		{[]string{"probe", "gpt-test", ""}, nil},
+		{[]string{"completion", "p"}, []string{"powershell"}},
+	}
+	for _, tt := range tests {
+		if got := values(completeWords(tt.words, models)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("completeWords(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+	got := completeWords([]string{"exec", "--repl"}, models)
+	if len(got) != 1 || !strings.Contains(got[0].Desc, "stdin") {
+		t.Errorf("flag completion lost its description: %+v", got)
+	}
+}
+
+func TestCompletionModelsFromProxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" || r.Header.Get("Authorization") != "Bearer k" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"object":"list","data":[{"id":"m1","owned_by":"codex"}]}`))
+	}))
+	defer srv.Close()
+
+	got, err := fetchProxyModels(srv.URL, "k")
+	if err != nil || !reflect.DeepEqual(got, []completionCandidate{{Value: "m1", Desc: "codex"}}) {
+		t.Errorf("fetchProxyModels = %+v, %v", got, err)
+	}
+	if _, err := fetchProxyModels(srv.URL, "wrong"); err == nil {
+		t.Error("fetchProxyModels accepted a 401")
+	}
+
+	t.Setenv("GODEX_API_KEY", "")
+	if got := completionModels(""); len(got) != len(fallbackCompletionModels) {
+		t.Errorf("completionModels without a key = %+v, want the fallback list", got)
+	}
+
+	for listen, want := range map[string]string{
+		"127.0.0.1:39001": "http://127.0.0.1:39001",
+		"0.0.0.0:8080":    "http://127.0.0.1:8080",
+		":8080":           "http://127.0.0.1:8080",
+	} {
+		if got := proxyURL(config.ProxyConfig{Listen: listen}); got != want {
+			t.Errorf("proxyURL(%q) = %q, want %q", listen, got, want)
+		}
+	}
+}
+
+// TestCompletionFlagsMatchMain keeps completionTree in step with the flags
+// runExec, runProxy and runProbe define.
+func TestCompletionFlagsMatchMain(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "main.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for fn, cmd := range map[string]string{"runExec": "exec", "runProxy": "proxy", "runProbe": "probe"} {
+		defined := map[string]bool{} // flag name -> is bool
+		for _, decl := range file.Decls {
+			f, ok := decl.(*ast.FuncDecl)
+			if !ok || f.Name.Name != fn {
+				continue
+			}
+			ast.Inspect(f.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				if id, ok := call.Fun.(*ast.Ident); ok && id.Name == "configFlag" {
+					defined["config"], defined["profile"] = false, false
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok {
+					return true
+				}
+				if x, ok := sel.X.(*ast.Ident); !ok || x.Name != "fs" {
+					return true
+				}
+				nameArg := 0
+				if strings.HasSuffix(sel.Sel.Name, "Var") {
+					nameArg = 1
+				}
+				if len(call.Args) <= nameArg {
+					return true
+				}
+				lit, ok := call.Args[nameArg].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					return true
+				}
+				name, _ := strconv.Unquote(lit.Value)
+				defined[name] = strings.HasPrefix(sel.Sel.Name, "Bool")
+				return true
+			})
+		}
+
+		listed := map[string]bool{}
+		for _, f := range completionTree.sub(cmd).flags {
+			listed[f.name] = f.kind == flagBool
+		}
+		if !reflect.DeepEqual(listed, defined) {
+			for name, isBool := range defined {
+				if b, ok := listed[name]; !ok || b != isBool {
+					t.Errorf("%s: flag --%s (bool=%v) missing or mistyped in completionTree", cmd, name, isBool)
+				}
+			}
+			for name := range listed {
+				if _, ok := defined[name]; !ok {
+					t.Errorf("%s: completionTree lists --%s, which %s does not define", cmd, name, fn)
+				}
+			}
+		}
+	}
+}
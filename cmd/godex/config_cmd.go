@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"godex/pkg/config"
+)
+
+func runConfig(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing config command (use 'init', 'schema', 'show' or 'validate')")
+	}
+	switch args[0] {
+	case "init":
+		return runConfigInit(args[1:], os.Stdout)
+	case "schema":
+		return runConfigSchema(os.Stdout)
+	case "show":
+		return runConfigShow(args[1:], os.Stdout)
+	case "validate":
+		return runConfigValidate(args[1:], os.Stdout)
+	default:
+		return fmt.Errorf("unknown config command: %s (use 'init', 'schema', 'show' or 'validate')", args[0])
+	}
+}
+
+// runConfigInit writes a template config to the file --config or --profile
+// selects, creating its directory.
+func runConfigInit(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("config init", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	configPath := configFlag(fs, args)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := config.WriteTemplate(*configPath); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "wrote %s\n", *configPath)
+	return nil
+}
+
+// runConfigSchema prints the config file's JSON Schema.
+func runConfigSchema(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(config.Schema())
+}
+
+// runConfigShow prints the effective config, with secrets masked, as YAML.
+func runConfigShow(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("config show", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	configPath := configFlag(fs, args)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	return yaml.NewEncoder(w).Encode(config.Sanitize(cfg))
+}
+
+// runConfigValidate prints each problem in the config file to w and fails
+// when there are any.
+func runConfigValidate(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("config validate", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	configPath := configFlag(fs, args)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(*configPath); err != nil {
+		return err
+	}
+	_, warnings, err := config.LoadFrom(*configPath)
+	for _, warning := range warnings {
+		fmt.Fprintln(w, warning)
+	}
+	if err != nil {
+		return err
+	}
+	if len(warnings) > 0 {
+		return fmt.Errorf("%s: %d problem(s) found", *configPath, len(warnings))
+	}
+	fmt.Fprintf(w, "%s: ok\n", *configPath)
+	return nil
+}
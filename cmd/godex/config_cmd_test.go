@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunConfigValidate(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.yaml")
+	bad := filepath.Join(dir, "bad.yaml")
+	if err := os.WriteFile(good, []byte("proxy:\n  default_rate: 60/m\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte("proxy:\n  default_rate: 60/day\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := runConfigValidate([]string{"--config", good}, &out); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+
+	out.Reset()
+	err := runConfigValidate([]string{"--config", bad}, &out)
+	if err == nil {
+		t.Fatal("invalid config: want error")
+	}
+	if !strings.Contains(out.String(), "proxy.default_rate") {
+		t.Errorf("output = %q, want the default_rate problem", out.String())
+	}
+}
+
+func TestRunConfigInitProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GODEX_CONFIG", "")
+
+	var out bytes.Buffer
+	if err := runConfigInit([]string{"--profile", "production"}, &out); err != nil {
+		t.Fatalf("config init: %v", err)
+	}
+	path := filepath.Join(home, ".config", "godex", "profiles", "production", "config.yaml")
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("template not written: %v", err)
+	}
+	if !strings.Contains(out.String(), path) {
+		t.Errorf("output = %q, want the written path", out.String())
+	}
+	if got := configPathFromArgs([]string{"--profile=production"}); got != path {
+		t.Errorf("configPathFromArgs = %q, want %q", got, path)
+	}
+	if err := runConfigValidate([]string{"--profile", "production"}, &out); err != nil {
+		t.Errorf("validate the new profile: %v", err)
+	}
+}
+
+func TestRunConfigShowMasksSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("proxy:\n  api_key: sk-very-secret\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := runConfigShow([]string{"--config", path}, &out); err != nil {
+		t.Fatalf("config show: %v", err)
+	}
+	if strings.Contains(out.String(), "sk-very-secret") {
+		t.Error("config show printed the API key")
+	}
+	if !strings.Contains(out.String(), "api_key: '***'") {
+		t.Errorf("config show should mark api_key as set, got:\n%s", out.String())
+	}
+}
+
+func TestRunConfigSchema(t *testing.T) {
+	var out bytes.Buffer
+	if err := runConfigSchema(&out); err != nil {
+		t.Fatalf("config schema: %v", err)
+	}
+	var schema struct {
+		Schema     string                     `json:"$schema"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &schema); err != nil {
+		t.Fatalf("config schema printed invalid JSON: %v", err)
+	}
+	if schema.Schema == "" || schema.Properties["proxy"] == nil {
+		t.Errorf("config schema = %s", out.String())
+	}
+}
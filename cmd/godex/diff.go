@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"godex/pkg/harness"
+)
+
+// writeToolCall prints a tool call for --diff-mode as soon as it arrives:
+// an apply_patch call as a diff, any other call as its name and compact
+// JSON arguments.
+func writeToolCall(w io.Writer, call *harness.ToolCallEvent, c colors) {
+	if call.Name == "apply_patch" {
+		if summary, err := harness.ParseApplyPatchArgs(call.Arguments); err == nil {
+			writePatchDiff(w, summary, harness.ApplyPatchInput(call.Arguments), c)
+			return
+		}
+	}
+	args := strings.TrimSpace(call.Arguments)
+	var compact bytes.Buffer
+	if json.Compact(&compact, []byte(args)) == nil {
+		args = compact.String()
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, c.paint("tool", "tool "+call.Name)+" "+args)
+}
+
+// writePatchDiff prints an apply_patch patch as a unified diff, preceded by
+// a one-line summary. c colors headers, hunks and changed lines.
+func writePatchDiff(w io.Writer, summary *harness.ParsedPatch, patch string, c colors) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, c.paint("header", fmt.Sprintf("patch %s: %d hunk(s), +%d -%d",
+		summary.FilePath, summary.HunkCount, summary.AddedLines, summary.RemovedLines)))
+
+	// An update header waits for a possible "*** Move to:" line.
+	pending := ""
+	flush := func(to string) {
+		if pending == "" {
+			return
+		}
+		fmt.Fprintln(w, c.paint("header", "--- a/"+pending))
+		fmt.Fprintln(w, c.paint("header", "+++ b/"+to))
+		pending = ""
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "*** Update File: "):
+			flush(pending)
+			pending = strings.TrimSpace(strings.TrimPrefix(line, "*** Update File: "))
+		case strings.HasPrefix(line, "*** Move to: "):
+			flush(strings.TrimSpace(strings.TrimPrefix(line, "*** Move to: ")))
+		case strings.HasPrefix(line, "*** Add File: "):
+			flush(pending)
+			fmt.Fprintln(w, c.paint("header", "--- /dev/null"))
+			fmt.Fprintln(w, c.paint("header", "+++ b/"+strings.TrimSpace(strings.TrimPrefix(line, "*** Add File: "))))
+		case strings.HasPrefix(line, "*** Delete File: "):
+			flush(pending)
+			fmt.Fprintln(w, c.paint("header", "--- a/"+strings.TrimSpace(strings.TrimPrefix(line, "*** Delete File: "))))
+			fmt.Fprintln(w, c.paint("header", "+++ /dev/null"))
+		case strings.HasPrefix(line, "***"), line == "":
+			// Begin/End Patch and End of File markers.
+		case strings.HasPrefix(line, "@@"):
+			flush(pending)
+			fmt.Fprintln(w, c.paint("hunk", line))
+		case strings.HasPrefix(line, "+"):
+			flush(pending)
+			fmt.Fprintln(w, c.paint("added", line))
+		case strings.HasPrefix(line, "-"):
+			flush(pending)
+			fmt.Fprintln(w, c.paint("removed", line))
+		default:
+			flush(pending)
+			fmt.Fprintln(w, line)
+		}
+	}
+	flush(pending)
+}
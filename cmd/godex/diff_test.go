@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"godex/pkg/harness"
+)
+
+func TestWritePatchDiff(t *testing.T) {
+	patch := "*** Begin Patch\n*** Update File: a.go\n*** Move to: b.go\n@@ func main()\n-old\n+new\n ctx\n*** Add File: c.txt\n+hello\n*** Delete File: d.txt\n*** End Patch"
+	summary, err := harness.ParseApplyPatchArgs(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	writePatchDiff(&b, summary, patch, colors{})
+	want := `
+patch a.go: 3 hunk(s), +2 -1
+--- a/a.go
++++ b/b.go
+@@ func main()
+-old
++new
+ ctx
+--- /dev/null
++++ b/c.txt
++hello
+--- a/d.txt
++++ /dev/null
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	writePatchDiff(&b, summary, patch, colors{scheme: defaultColorScheme})
+	if !strings.Contains(b.String(), "\x1b[32m+new"+ansiReset) || !strings.Contains(b.String(), "\x1b[31m-old"+ansiReset) {
+		t.Errorf("expected colored lines, got %q", b.String())
+	}
+}
+
+func TestWriteToolCall(t *testing.T) {
+	var b strings.Builder
+	writeToolCall(&b, &harness.ToolCallEvent{Name: "shell", Arguments: "{\n  \"cmd\": [\"ls\", \"-la\"]\n}"}, colors{})
+	if want := "\ntool shell {\"cmd\":[\"ls\",\"-la\"]}\n"; b.String() != want {
+		t.Errorf("shell call = %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+	args := `{"input":"*** Begin Patch\n*** Add File: a.txt\n+hi\n*** End Patch"}`
+	writeToolCall(&b, &harness.ToolCallEvent{Name: "apply_patch", Arguments: args}, colors{})
+	if want := "\npatch a.txt: 1 hunk(s), +1 -0\n--- /dev/null\n+++ b/a.txt\n+hi\n"; b.String() != want {
+		t.Errorf("apply_patch call = %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+	writeToolCall(&b, &harness.ToolCallEvent{Name: "apply_patch", Arguments: "not a patch"}, colors{})
+	if want := "\ntool apply_patch not a patch\n"; b.String() != want {
+		t.Errorf("invalid patch = %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+	writeToolCall(&b, &harness.ToolCallEvent{Name: "shell", Arguments: "{}"}, colors{scheme: defaultColorScheme})
+	if want := "\n\x1b[36mtool shell" + ansiReset + " {}\n"; b.String() != want {
+		t.Errorf("colored call = %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"godex/pkg/protocol"
+	"godex/pkg/router"
+)
+
+// modelRoute is the proxy's answer to GET /v1/models/<model>?explain=true.
+type modelRoute struct {
+	Backend string `json:"backend,omitempty"`
+	router.RouteExplanation
+}
+
+// runDryRun prints req as indented JSON to out and its problems to errOut.
+// With a proxy URL, it also asks that proxy which backend would serve the
+// model. Any problem makes the returned error non-nil.
+func runDryRun(req protocol.ResponsesRequest, proxyURL, apiKey string, out, errOut io.Writer) error {
+	buf, err := json.MarshalIndent(req, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode request: %w", err)
+	}
+	fmt.Fprintln(out, string(buf))
+
+	problems := validateRequest(req)
+	if proxyURL != "" && req.Model != "" {
+		route, err := fetchModelRoute(proxyURL, apiKey, req.Model)
+		if err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			fmt.Fprintf(errOut, "route: %s -> %s (%s)\n", req.Model, route.Backend, route.Reason)
+		}
+	}
+	for _, p := range problems {
+		fmt.Fprintf(errOut, "error: %s\n", p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("dry run: %d problem(s) found", len(problems))
+	}
+	fmt.Fprintln(errOut, "dry run: request is valid")
+	return nil
+}
+
+// validateRequest lists what is wrong with req before it is sent.
+func validateRequest(req protocol.ResponsesRequest) []string {
+	var problems []string
+	if strings.TrimSpace(req.Model) == "" {
+		problems = append(problems, "model is empty (set --model or exec.model)")
+	}
+	if strings.TrimSpace(req.Instructions) == "" {
+		problems = append(problems, "instructions are empty")
+	}
+	if len(req.Input) == 0 {
+		problems = append(problems, "input is empty")
+	}
+	names := map[string]bool{}
+	for _, t := range req.Tools {
+		if t.Type != "function" {
+			continue
+		}
+		if names[t.Name] {
+			problems = append(problems, fmt.Sprintf("tool %s is defined twice", t.Name))
+		}
+		names[t.Name] = true
+		var schema map[string]any
+		if len(t.Parameters) > 0 && json.Unmarshal(t.Parameters, &schema) != nil {
+			problems = append(problems, fmt.Sprintf("tool %s: schema is not a JSON object", t.Name))
+		}
+	}
+	switch choice := req.ToolChoice; {
+	case choice == "auto" || choice == "required":
+	case strings.HasPrefix(choice, "function:"):
+		if name := strings.TrimPrefix(choice, "function:"); !names[name] {
+			problems = append(problems, fmt.Sprintf("--tool-choice names tool %q, which no --tool defines", name))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("--tool-choice %q must be auto, required or function:<name>", choice))
+	}
+	return problems
+}
+
+// fetchModelRoute asks the proxy at baseURL how it routes model.
+func fetchModelRoute(baseURL, apiKey, model string) (*modelRoute, error) {
+	reqURL := strings.TrimRight(baseURL, "/") + "/v1/models/" + url.PathEscape(model) + "?explain=true"
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, fmt.Errorf("model %q not found", model)
+	default:
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var route modelRoute
+	if err := json.Unmarshal(body, &route); err != nil {
+		return nil, fmt.Errorf("parse response: %w", err)
+	}
+	return &route, nil
+}
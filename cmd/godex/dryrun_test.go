@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"godex/pkg/protocol"
+)
+
+func TestValidateRequest(t *testing.T) {
+	valid := protocol.ResponsesRequest{
+		Model:        "gpt-test",
+		Instructions: "be brief",
+		Input:        []protocol.ResponseInputItem{protocol.UserMessage("hi")},
+		Tools:        []protocol.ToolSpec{{Type: "function", Name: "lookup", Parameters: json.RawMessage(`{"type":"object"}`)}},
+		ToolChoice:   "function:lookup",
+	}
+	if problems := validateRequest(valid); len(problems) != 0 {
+		t.Fatalf("valid request has problems: %q", problems)
+	}
+
+	bad := valid
+	bad.Model = ""
+	bad.Tools = append(bad.Tools, protocol.ToolSpec{Type: "function", Name: "lookup", Parameters: json.RawMessage(`[1]`)})
+	bad.ToolChoice = "function:missing"
+	problems := strings.Join(validateRequest(bad), "\n")
+	for _, want := range []string{"model is empty", "lookup is defined twice", "not a JSON object", `"missing"`} {
+		if !strings.Contains(problems, want) {
+			t.Errorf("problems missing %q:\n%s", want, problems)
+		}
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Authorization") != "Bearer k":
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		case r.URL.Path == "/v1/models/sonnet" && r.URL.Query().Get("explain") == "true":
+			w.Write([]byte(`{"id":"claude-sonnet-4-6","backend":"anthropic","reason":"alias"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	req := protocol.ResponsesRequest{
+		Model:        "sonnet",
+		Instructions: "be brief",
+		Input:        []protocol.ResponseInputItem{protocol.UserMessage("hi")},
+		ToolChoice:   "auto",
+	}
+	var out, errOut bytes.Buffer
+	if err := runDryRun(req, srv.URL, "k", &out, &errOut); err != nil {
+		t.Fatalf("runDryRun: %v\n%s", err, errOut.String())
+	}
+	var printed protocol.ResponsesRequest
+	if err := json.Unmarshal(out.Bytes(), &printed); err != nil || printed.Model != "sonnet" {
+		t.Errorf("stdout is not the request: %s (%v)", out.String(), err)
+	}
+	if !strings.Contains(errOut.String(), "route: sonnet -> anthropic (alias)") {
+		t.Errorf("route not printed:\n%s", errOut.String())
+	}
+
+	req.Model = "nope"
+	errOut.Reset()
+	if err := runDryRun(req, srv.URL, "k", &out, &errOut); err == nil || !strings.Contains(errOut.String(), `model "nope" not found`) {
+		t.Errorf("unknown model: err = %v\n%s", err, errOut.String())
+	}
+}
@@ -97,3 +97,28 @@ func assertEventType(t *testing.T, ev map[string]any, want string) {
 		t.Fatalf("event type = %#v, want %q", ev["type"], want)
 	}
 }
+
+func TestExecJSONEmitter_StopSequence(t *testing.T) {
+	var out bytes.Buffer
+	emitter := newExecJSONEmitter(&out, "")
+	for _, ev := range []harness.Event{
+		harness.NewTextEvent("a"),
+		harness.NewStopReasonEvent("END"),
+		harness.NewDoneEvent(),
+	} {
+		if err := emitter.Emit(ev); err != nil {
+			t.Fatalf("emit event: %v", err)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	var completed map[string]any
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &completed); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	assertEventType(t, completed, "response.completed")
+	response := completed["response"].(map[string]any)
+	if response["stop_reason"] != "stop_sequence" || response["stop_sequence"] != "END" {
+		t.Fatalf("expected stop sequence on response.completed, got %#v", response)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"godex/pkg/harness"
+	"godex/pkg/protocol"
+)
+
+// exportRedactLimit is the longest text --export-sanitized keeps.
+const exportRedactLimit = 200
+
+// historyExport is the file --export-history writes. Input is in the
+// --input-json format, and --input-json also reads the whole file, so an
+// exported conversation can be replayed or continued.
+type historyExport struct {
+	Model        string                       `json:"model"`
+	Instructions string                       `json:"instructions,omitempty"`
+	Input        []protocol.ResponseInputItem `json:"input"`
+	Usage        *harness.UsageEvent          `json:"usage,omitempty"`
+}
+
+// writeHistoryExport writes turn's conversation to path. With sanitized,
+// texts longer than exportRedactLimit are replaced by their length.
+func writeHistoryExport(path string, turn *harness.Turn, usage *harness.UsageEvent, sanitized bool) error {
+	export := historyExport{
+		Model:        turn.Model,
+		Instructions: turn.Instructions,
+		Input:        inputItemsFromMessages(turn.Messages),
+		Usage:        usage,
+	}
+	if sanitized {
+		export.Instructions = redactLong(export.Instructions)
+		for i := range export.Input {
+			item := &export.Input[i]
+			item.Arguments = redactLong(item.Arguments)
+			item.Output = redactLong(item.Output)
+			for j := range item.Content {
+				item.Content[j].Text = redactLong(item.Content[j].Text)
+			}
+		}
+	}
+	buf, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode history: %w", err)
+	}
+	if err := os.WriteFile(path, append(buf, '\n'), 0o600); err != nil {
+		return fmt.Errorf("export history: %w", err)
+	}
+	return nil
+}
+
+// inputItemsFromMessages converts harness messages back to the response
+// input items runExec builds them from.
+func inputItemsFromMessages(msgs []harness.Message) []protocol.ResponseInputItem {
+	items := make([]protocol.ResponseInputItem, 0, len(msgs))
+	for _, m := range msgs {
+		switch {
+		case m.Role == "tool":
+			items = append(items, protocol.FunctionCallOutputInput(m.ToolID, m.Content))
+		case m.Role == "assistant" && m.Name != "":
+			items = append(items, protocol.FunctionCallInput(m.Name, m.ToolID, m.Content))
+		case m.Role == "assistant":
+			items = append(items, protocol.ResponseInputItem{
+				Type:    "message",
+				Role:    "assistant",
+				Content: []protocol.InputContentPart{{Type: "output_text", Text: m.Content}},
+			})
+		default:
+			item := protocol.UserMessage(m.Content)
+			item.Role = m.Role
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseInputJSON reads an --input-json file: an array of response input
+// items, or a file written by --export-history.
+func parseInputJSON(buf []byte) ([]protocol.ResponseInputItem, error) {
+	var items []protocol.ResponseInputItem
+	if strings.HasPrefix(strings.TrimSpace(string(buf)), "{") {
+		var export historyExport
+		if err := json.Unmarshal(buf, &export); err != nil {
+			return nil, err
+		}
+		return export.Input, nil
+	}
+	if err := json.Unmarshal(buf, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// addUsage sums the usage events of a turn.
+func addUsage(total *harness.UsageEvent, events []harness.Event) *harness.UsageEvent {
+	for _, ev := range events {
+		if ev.Kind != harness.EventUsage || ev.Usage == nil {
+			continue
+		}
+		if total == nil {
+			total = &harness.UsageEvent{}
+		}
+		total.InputTokens += ev.Usage.InputTokens
+		total.OutputTokens += ev.Usage.OutputTokens
+		total.TotalTokens += ev.Usage.TotalTokens
+		total.CachedTokens += ev.Usage.CachedTokens
+	}
+	return total
+}
+
+func redactLong(s string) string {
+	if n := len([]rune(s)); n > exportRedactLimit {
+		return fmt.Sprintf("[redacted: %d characters]", n)
+	}
+	return s
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"godex/pkg/harness"
+	"godex/pkg/protocol"
+)
+
+func TestWriteHistoryExport(t *testing.T) {
+	turn := &harness.Turn{
+		Model:        "gpt-5.2-codex",
+		Instructions: "Be brief.",
+		Messages: []harness.Message{
+			{Role: "user", Content: "List the files."},
+			{Role: "assistant", Content: `{"cmd":"ls"}`, Name: "shell", ToolID: "call_1"},
+			{Role: "tool", Content: "README.md", ToolID: "call_1"},
+			{Role: "assistant", Content: "There is a README."},
+		},
+	}
+	usage := addUsage(nil, []harness.Event{harness.NewUsageEvent(10, 5), harness.NewUsageEvent(20, 7)})
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err := writeHistoryExport(path, turn, usage, false); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var export historyExport
+	if err := json.Unmarshal(buf, &export); err != nil {
+		t.Fatal(err)
+	}
+	if export.Model != turn.Model || export.Instructions != turn.Instructions || export.Usage.InputTokens != 30 || export.Usage.OutputTokens != 12 {
+		t.Errorf("export = %+v", export)
+	}
+
+	// --input-json reads the export back as the same conversation.
+	items, err := parseInputJSON(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []protocol.ResponseInputItem{
+		protocol.UserMessage("List the files."),
+		protocol.FunctionCallInput("shell", "call_1", `{"cmd":"ls"}`),
+		protocol.FunctionCallOutputInput("call_1", "README.md"),
+		{Type: "message", Role: "assistant", Content: []protocol.InputContentPart{{Type: "output_text", Text: "There is a README."}}},
+	}
+	if !reflect.DeepEqual(items, want) {
+		t.Errorf("input items:\n got %+v\nwant %+v", items, want)
+	}
+
+	long := strings.Repeat("x", 201)
+	turn.Messages = []harness.Message{{Role: "user", Content: long}, {Role: "tool", Content: strings.Repeat("y", 200), ToolID: "call_2"}}
+	if err := writeHistoryExport(path, turn, nil, true); err != nil {
+		t.Fatal(err)
+	}
+	buf, _ = os.ReadFile(path)
+	if items, err = parseInputJSON(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := items[0].Content[0].Text; got != "[redacted: 201 characters]" {
+		t.Errorf("long prompt = %q, want it redacted", got)
+	}
+	if got := items[1].Output; len(got) != 200 {
+		t.Errorf("200-character output was redacted: %q", got)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"godex/pkg/harness"
+)
+
+// discoveringMock lists models from a static fallback, like the Codex and
+// Claude harnesses, while its upstream discovery fails.
+type discoveringMock struct {
+	*harness.Mock
+	err error
+}
+
+func (d discoveringMock) DiscoverModels(ctx context.Context) ([]harness.ModelInfo, error) {
+	return nil, d.err
+}
+
+func TestListModelsHealthCheck_UsesDiscovery(t *testing.T) {
+	mock := harness.NewMock(harness.MockConfig{})
+	if !listModelsHealthCheck(mock)(context.Background()) {
+		t.Fatal("expected a harness that lists its models to be healthy")
+	}
+	down := discoveringMock{Mock: mock, err: errors.New("models API: 401")}
+	if listModelsHealthCheck(down)(context.Background()) {
+		t.Fatal("expected a failing discovery to mark the backend unhealthy")
+	}
+	up := discoveringMock{Mock: mock}
+	if !listModelsHealthCheck(up)(context.Background()) {
+		t.Fatal("expected a working discovery to mark the backend healthy")
+	}
+}
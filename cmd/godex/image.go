@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"godex/pkg/harness"
+)
+
+// loadImageBlock reads a local image file into a base64 image block. The
+// media type is sniffed from the file contents.
+func loadImageBlock(path string) (harness.ImageBlock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return harness.ImageBlock{}, fmt.Errorf("read image: %w", err)
+	}
+	mediaType := http.DetectContentType(data)
+	if !slices.Contains(harness.ImageMediaTypes, mediaType) {
+		return harness.ImageBlock{}, fmt.Errorf("image %s: unsupported media type %s", path, mediaType)
+	}
+	return harness.ImageBlock{
+		MediaType: mediaType,
+		Base64:    base64.StdEncoding.EncodeToString(data),
+	}, nil
+}
+
+// attachImages adds images to the last user message in messages. The
+// message's text is kept as Content and repeated as the first block, so
+// harnesses without image support still see the prompt.
+func attachImages(messages []harness.Message, paths []string) error {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		blocks := []harness.ContentBlock{harness.TextBlock{Text: messages[i].Content}}
+		for _, path := range paths {
+			img, err := loadImageBlock(path)
+			if err != nil {
+				return err
+			}
+			blocks = append(blocks, img)
+		}
+		messages[i].ContentBlocks = blocks
+		return nil
+	}
+	return fmt.Errorf("--image needs a user message to attach to")
+}
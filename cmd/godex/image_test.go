@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"godex/pkg/harness"
+)
+
+// pngHeader is enough of a PNG file for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestAttachImages(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shot.png")
+	if err := os.WriteFile(path, pngHeader, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	messages := []harness.Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "ok"},
+		{Role: "user", Content: "what is in this image?"},
+	}
+	if err := attachImages(messages, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+	if messages[0].ContentBlocks != nil {
+		t.Error("images should attach to the last user message only")
+	}
+	blocks := messages[2].ContentBlocks
+	if len(blocks) != 2 {
+		t.Fatalf("expected text and image blocks, got %+v", blocks)
+	}
+	if text, ok := blocks[0].(harness.TextBlock); !ok || text.Text != "what is in this image?" {
+		t.Errorf("unexpected text block %+v", blocks[0])
+	}
+	img, ok := blocks[1].(harness.ImageBlock)
+	if !ok || img.MediaType != "image/png" || img.Base64 != base64.StdEncoding.EncodeToString(pngHeader) {
+		t.Errorf("unexpected image block %+v", blocks[1])
+	}
+	if messages[2].Content != "what is in this image?" {
+		t.Error("Content should be kept for harnesses without image support")
+	}
+}
+
+func TestAttachImages_Errors(t *testing.T) {
+	dir := t.TempDir()
+	text := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(text, []byte("plain text"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	user := []harness.Message{{Role: "user", Content: "hi"}}
+	if err := attachImages(user, []string{text}); err == nil {
+		t.Error("expected an error for a non-image file")
+	}
+	if err := attachImages(user, []string{filepath.Join(dir, "missing.png")}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	if err := attachImages([]harness.Message{{Role: "tool", Content: "x"}}, nil); err == nil {
+		t.Error("expected an error without a user message")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"godex/pkg/harness"
+	harnessOpenaiP "godex/pkg/harness/openai"
+)
+
+// generatedImage is one exec --image-gen result as printed: its URL, or
+// the file its base64 data was saved to.
+type generatedImage struct {
+	URL           string `json:"url,omitempty"`
+	Path          string `json:"path,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// runImageGen generates images for prompt instead of running a turn. Base64
+// results are saved as image-N.png in outDir; each image's URL or path is
+// printed to w, one per line, or as a JSON object when jsonOnly is set.
+func runImageGen(ctx context.Context, h harness.Harness, prompt string, opts harnessOpenaiP.ImageOptions, outDir string, jsonOnly bool, w io.Writer) error {
+	gen, ok := h.(*harnessOpenaiP.Harness)
+	if !ok {
+		return fmt.Errorf("--image-gen requires an openai-compatible model, got %s (%s)", opts.Model, h.Name())
+	}
+	results, err := gen.GenerateImage(ctx, prompt, opts)
+	if err != nil {
+		return err
+	}
+	images := make([]generatedImage, 0, len(results))
+	for i, res := range results {
+		img := generatedImage{URL: res.URL, RevisedPrompt: res.RevisedPrompt}
+		if res.B64JSON != "" {
+			data, err := base64.StdEncoding.DecodeString(res.B64JSON)
+			if err != nil {
+				return fmt.Errorf("decode image %d: %w", i+1, err)
+			}
+			img.Path = filepath.Join(outDir, fmt.Sprintf("image-%d.png", i+1))
+			if err := os.WriteFile(img.Path, data, 0o644); err != nil {
+				return fmt.Errorf("save image: %w", err)
+			}
+		}
+		images = append(images, img)
+	}
+
+	if jsonOnly {
+		return json.NewEncoder(w).Encode(map[string]any{"model": opts.Model, "images": images})
+	}
+	for _, img := range images {
+		if img.Path != "" {
+			fmt.Fprintln(w, img.Path)
+		} else {
+			fmt.Fprintln(w, img.URL)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"godex/pkg/harness"
+	harnessOpenaiP "godex/pkg/harness/openai"
+)
+
+func TestRunImageGenSavesBase64(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString(pngHeader)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":[{"b64_json":"` + b64 + `"}]}`))
+	}))
+	defer srv.Close()
+	client, _ := harnessOpenaiP.NewClient(harnessOpenaiP.ClientConfig{BaseURL: srv.URL})
+	h := harnessOpenaiP.New(harnessOpenaiP.Config{Client: client})
+
+	dir := t.TempDir()
+	var out bytes.Buffer
+	opts := harnessOpenaiP.ImageOptions{Model: "dall-e-3", ResponseFormat: "b64_json"}
+	if err := runImageGen(context.Background(), h, "a fox", opts, dir, false, &out); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "image-1.png")
+	if strings.TrimSpace(out.String()) != path {
+		t.Errorf("printed %q, want %q", out.String(), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !bytes.Equal(data, pngHeader) {
+		t.Errorf("saved image = %q, %v", data, err)
+	}
+
+	mock := harness.NewMock(harness.MockConfig{HarnessName: "mock"})
+	if err := runImageGen(context.Background(), mock, "a fox", opts, dir, false, &out); err == nil {
+		t.Error("expected an error for a non-openai harness")
+	}
+}
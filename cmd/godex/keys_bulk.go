@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"godex/pkg/admin"
+)
+
+// runKeysBulk posts the operations in file to the running proxy's
+// POST /admin/keys/bulk, over adminURL if set or else the admin socket,
+// and prints each operation's result.
+func runKeysBulk(file, socket, adminURL, apiKey string) error {
+	body, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	client := http.DefaultClient
+	endpoint := strings.TrimRight(adminURL, "/") + "/admin/keys/bulk"
+	if strings.TrimSpace(adminURL) == "" {
+		if strings.TrimSpace(socket) == "" {
+			return errors.New("bulk needs proxy.admin_socket or --admin-url")
+		}
+		sock := expandHome(socket)
+		client = &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial("unix", sock)
+		}}}
+		endpoint = "http://unix/admin/keys/bulk"
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if adminURL != "" && apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("admin request failed (is the proxy running?): %w", err)
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Committed bool                `json:"committed"`
+		Results   []admin.KeyOpResult `json:"results"`
+		Error     struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("decode admin response (%s): %w", resp.Status, err)
+	}
+	for _, res := range out.Results {
+		fmt.Printf("%d\t%s\t%s\t%s\t%s\t%s\n", res.Index, res.Op, res.Status, res.KeyID, res.APIKey, res.Error)
+	}
+	if !out.Committed {
+		if out.Error.Message == "" {
+			out.Error.Message = resp.Status
+		}
+		return fmt.Errorf("bulk rolled back: %s", out.Error.Message)
+	}
+	return nil
+}
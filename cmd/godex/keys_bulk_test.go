@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"godex/pkg/admin"
+)
+
+func TestRunKeysBulk(t *testing.T) {
+	var gotAuth string
+	var gotOps []admin.KeyOp
+	committed := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/keys/bulk" {
+			http.NotFound(w, r)
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &gotOps)
+		resp := map[string]any{"committed": committed, "results": []admin.KeyOpResult{{Index: 0, Op: "add", Status: admin.KeyOpStatusOK}}}
+		if !committed {
+			resp["error"] = map[string]any{"message": "operation 0 (add): label is required"}
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	file := filepath.Join(t.TempDir(), "ops.json")
+	os.WriteFile(file, []byte(`[{"op":"add","label":"ci-1","rate":"10/m"}]`), 0o600)
+
+	if err := runKeysBulk(file, "", srv.URL, "admin-key"); err != nil {
+		t.Fatalf("runKeysBulk: %v", err)
+	}
+	if gotAuth != "Bearer admin-key" || len(gotOps) != 1 || gotOps[0].Label != "ci-1" {
+		t.Errorf("request: auth %q, ops %+v", gotAuth, gotOps)
+	}
+
+	committed = false
+	err := runKeysBulk(file, "", srv.URL, "admin-key")
+	if err == nil || !strings.Contains(err.Error(), "label is required") {
+		t.Errorf("rolled back batch: err = %v", err)
+	}
+	if err := runKeysBulk(file, "", "", ""); err == nil {
+		t.Error("runKeysBulk without a socket or URL succeeded")
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -44,6 +45,95 @@ func (o *outputFlags) Set(v string) error {
 	return nil
 }
 
+type stopSequenceFlags []string
+
+func (s *stopSequenceFlags) String() string { return strings.Join(*s, ",") }
+func (s *stopSequenceFlags) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// imageFlags collects repeated --image paths.
+type imageFlags []string
+
+func (f *imageFlags) String() string { return strings.Join(*f, ",") }
+func (f *imageFlags) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// scopeFlags collects repeated --scope values.
+type scopeFlags []string
+
+func (f *scopeFlags) String() string { return strings.Join(*f, ",") }
+func (f *scopeFlags) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// modelQuotaFlags collects repeated --model-quota model=N values.
+type modelQuotaFlags map[string]int64
+
+func (m modelQuotaFlags) String() string {
+	parts := make([]string, 0, len(m))
+	for model, limit := range m {
+		parts = append(parts, fmt.Sprintf("%s=%d", model, limit))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (m modelQuotaFlags) Set(v string) error {
+	model, limit, err := proxy.ParseModelQuota(v)
+	if err != nil {
+		return err
+	}
+	m[model] = limit
+	return nil
+}
+
+// headerFlags collects repeated --response-header Key=Value values.
+type headerFlags map[string]string
+
+func (h headerFlags) String() string {
+	parts := make([]string, 0, len(h))
+	for name, value := range h {
+		parts = append(parts, name+"="+value)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (h headerFlags) Set(v string) error {
+	name, value, err := proxy.ParseResponseHeader(v)
+	if err != nil {
+		return err
+	}
+	h[name] = value
+	return nil
+}
+
+// templateVarFlags collects repeated --var KEY=VALUE values.
+type templateVarFlags map[string]string
+
+func (t templateVarFlags) String() string {
+	parts := make([]string, 0, len(t))
+	for k, v := range t {
+		parts = append(parts, k+"="+v)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (t templateVarFlags) Set(v string) error {
+	key, value, ok := strings.Cut(v, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("invalid --var %q; expected KEY=VALUE", v)
+	}
+	t[strings.TrimSpace(key)] = value
+	return nil
+}
+
 var Version = "dev"
 
 func main() {
@@ -57,27 +147,41 @@ func main() {
 		return
 	case "exec":
 		if err := runExec(os.Args[2:]); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
+			printError(err)
 			os.Exit(1)
 		}
 	case "proxy":
 		if err := runProxy(os.Args[2:]); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
+			printError(err)
 			os.Exit(1)
 		}
 	case "probe":
 		if err := runProbe(os.Args[2:]); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
+			printError(err)
 			os.Exit(1)
 		}
 	case "auth":
 		if err := runAuth(os.Args[2:]); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
+			printError(err)
 			os.Exit(1)
 		}
 	case "aliases":
 		if err := runAliases(os.Args[2:]); err != nil {
-			fmt.Fprintln(os.Stderr, "error:", err)
+			printError(err)
+			os.Exit(1)
+		}
+	case "config":
+		if err := runConfig(os.Args[2:]); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+	case "completion":
+		if err := runCompletion(os.Args[2:], os.Stdout); err != nil {
+			printError(err)
+			os.Exit(1)
+		}
+	case "__complete":
+		if err := runComplete(os.Args[2:], os.Stdout); err != nil {
 			os.Exit(1)
 		}
 	default:
@@ -90,7 +194,13 @@ func runExec(args []string) error {
 	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 
-	cfg := config.LoadFrom(configPathFromArgs(args))
+	cfg, err := loadConfig(configPathFromArgs(args))
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(cfg).Fatal(); err != nil {
+		return err
+	}
 
 	var prompt string
 	var model string
@@ -101,6 +211,7 @@ func runExec(args []string) error {
 	var jsonOnly bool
 	var allowRefresh bool
 	var autoTools bool
+	var parallelTools int
 	var webSearch bool
 	var toolChoice string
 	var inputJSON string
@@ -114,9 +225,33 @@ func runExec(args []string) error {
 	var logResponses string
 	var providerKey string
 	var upstreamAuditPath string
-
-	configPath := fs.String("config", config.DefaultPath(), "Config file path")
+	var countTokens bool
+	var dryRun bool
+	var templatePath string
+	var templateDelims string
+	var varsFile string
+	templateVars := templateVarFlags{}
+	var routeURL string
+	var routeKey string
+	var stopSequences stopSequenceFlags
+	var sessionFile string
+	var diffMode bool
+	var colorMode string
+	var images imageFlags
+	var imageGen bool
+	var imageOpts harnessOpenaiP.ImageOptions
+	var imageOut string
+	var repl bool
+	var promptsFile string
+	var outputDir string
+	var concurrency int
+	var exportHistory string
+	var exportSanitized bool
+	var useStdin bool
+
+	configPath := configFlag(fs, args)
 	fs.StringVar(&prompt, "prompt", "", "User prompt")
+	fs.BoolVar(&useStdin, "stdin", false, "With --prompt, send piped stdin as a second user message")
 	fs.StringVar(&model, "model", cfg.Exec.Model, "Model name")
 	fs.StringVar(&instructions, "instructions", cfg.Exec.Instructions, "Optional system instructions")
 	fs.StringVar(&instructionsAlt, "system", "", "Alias for --instructions")
@@ -125,6 +260,7 @@ func runExec(args []string) error {
 	fs.BoolVar(&jsonOnly, "json", false, "Emit JSON events only (no text output)")
 	fs.BoolVar(&allowRefresh, "allow-refresh", cfg.Exec.AllowRefresh, "Allow network token refresh on 401")
 	fs.BoolVar(&autoTools, "auto-tools", cfg.Exec.AutoToolsEnabled, "Automatically run tool loop with static outputs")
+	fs.IntVar(&parallelTools, "parallel-tools", 0, "With --auto-tools, run up to N tool calls per turn concurrently (0 = serial)")
 	fs.BoolVar(&webSearch, "web-search", cfg.Exec.WebSearch, "Enable web_search tool")
 	fs.StringVar(&toolChoice, "tool-choice", cfg.Exec.ToolChoice, "Tool choice: auto|required|function:<name>")
 	fs.StringVar(&inputJSON, "input-json", "", "JSON array of response input items (overrides --prompt)")
@@ -133,37 +269,118 @@ func runExec(args []string) error {
 	fs.Var(&tools, "tool", "Tool spec (repeatable): web_search or name:json=/path/schema.json")
 	fs.Var(&outputs, "tool-output", "Static tool output: name=value or name=$args (repeatable)")
 	fs.StringVar(&sessionID, "session-id", "", "Optional session id (reuses prompt cache key)")
+	fs.StringVar(&sessionFile, "session-file", "", "Continue the conversation saved in this file and save it back (codex models)")
 	fs.StringVar(&logRequests, "log-requests", "", "Write JSON request payload to file")
 	fs.StringVar(&logResponses, "log-responses", "", "Append JSONL response events to file")
 	fs.StringVar(&providerKey, "provider-key", "", "API key for non-Codex backends (or set via env per provider)")
 	fs.StringVar(&upstreamAuditPath, "upstream-audit-path", cfg.Proxy.UpstreamAuditPath, "Upstream model SSE audit JSONL path")
 	fs.BoolVar(&nativeTools, "native-tools", false, "Use Codex native tools (shell, apply_patch, update_plan) instead of proxy mode")
+	fs.BoolVar(&countTokens, "count-tokens", false, "Print the estimated prompt token count and exit without sending")
+	fs.BoolVar(&dryRun, "dry-run", false, "Validate the request and print it as JSON without sending it")
+	fs.StringVar(&routeURL, "url", "", "With --dry-run, proxy URL to ask which backend would serve --model")
+	fs.StringVar(&routeKey, "key", "", "With --dry-run and --url, proxy API key (or set GODEX_API_KEY)")
+	fs.Var(&stopSequences, "stop-sequence", "Stop generating at this sequence (repeatable, up to 4)")
+	fs.Var(&images, "image", "Attach a local image file to the prompt (repeatable; png, jpeg, gif or webp)")
+	fs.BoolVar(&diffMode, "diff-mode", false, "Print apply_patch calls as colored unified diffs")
+	fs.StringVar(&colorMode, "color", "", "Color output: auto, always or never (default $GODEX_COLOR, else auto)")
+	fs.BoolVar(&imageGen, "image-gen", false, "Generate images from --prompt with --model (openai backends) instead of running a turn")
+	fs.StringVar(&imageOpts.Size, "image-size", "", "With --image-gen, image size (e.g. 1024x1024)")
+	fs.StringVar(&imageOpts.Quality, "image-quality", "", "With --image-gen, image quality (e.g. standard, hd)")
+	fs.IntVar(&imageOpts.N, "image-n", 0, "With --image-gen, number of images")
+	fs.StringVar(&imageOpts.ResponseFormat, "image-format", "", "With --image-gen, url or b64_json")
+	fs.StringVar(&imageOut, "image-out", ".", "With --image-gen, directory for b64_json images")
+	fs.BoolVar(&repl, "repl", false, "Read prompts from stdin one line at a time, keeping the conversation (Ctrl-D exits)")
+	fs.StringVar(&promptsFile, "prompts-file", "", "Run each line of this file as a separate prompt, writing results to --output-dir")
+	fs.StringVar(&outputDir, "output-dir", "", "With --prompts-file, directory for results and errors.jsonl")
+	fs.IntVar(&concurrency, "concurrency", 1, "With --prompts-file, how many prompts to run at once")
+	fs.StringVar(&templatePath, "template", "", "Render this Go text/template as the prompt (a .json template as --input-json)")
+	fs.Var(templateVars, "var", "Template variable KEY=VALUE (repeatable)")
+	fs.StringVar(&varsFile, "vars-file", "", "JSON object of template variables (--var overrides)")
+	fs.StringVar(&templateDelims, "template-delims", "{{ }}", "Template left and right delimiters, separated by a space")
+	fs.StringVar(&exportHistory, "export-history", "", "After the run, write the conversation to this JSON file (readable by --input-json)")
+	fs.BoolVar(&exportSanitized, "export-sanitized", false, "With --export-history, redact texts longer than 200 characters")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 	_ = configPath
-	if strings.TrimSpace(prompt) == "" && strings.TrimSpace(inputJSON) == "" {
-		return errors.New("--prompt is required unless --input-json is provided")
+	colorCfg, err := loadColorConfig(colorMode)
+	if err != nil {
+		return err
 	}
-	if strings.TrimSpace(upstreamAuditPath) != "" {
-		cfg.Proxy.UpstreamAuditPath = strings.TrimSpace(upstreamAuditPath)
+	batch := strings.TrimSpace(promptsFile) != ""
+	var templateItems []protocol.ResponseInputItem
+	if templatePath != "" {
+		if strings.TrimSpace(prompt) != "" || strings.TrimSpace(inputJSON) != "" || batch {
+			return errors.New("--template replaces --prompt, --input-json and --prompts-file")
+		}
+		data, err := loadTemplateVars(varsFile, templateVars)
+		if err != nil {
+			return err
+		}
+		rendered, err := renderTemplate(templatePath, templateDelims, data)
+		if err != nil {
+			return err
+		}
+		if strings.EqualFold(filepath.Ext(templatePath), ".json") {
+			if templateItems, err = parseInputJSON([]byte(rendered)); err != nil {
+				return fmt.Errorf("parse rendered template %s as input json: %w", templatePath, err)
+			}
+		} else {
+			prompt = rendered
+		}
+	} else if len(templateVars) > 0 || varsFile != "" {
+		return errors.New("--var and --vars-file require --template")
 	}
-
-	if cfg.Auth.RefreshURL != "" || cfg.Auth.ClientID != "" || cfg.Auth.Scope != "" {
-		auth.SetRefreshConfig(cfg.Auth.RefreshURL, cfg.Auth.ClientID, cfg.Auth.Scope)
+	// Piped input is the prompt, or with --stdin follows --prompt as a
+	// second message.
+	var piped string
+	if !repl && !batch && strings.TrimSpace(inputJSON) == "" && templateItems == nil {
+		if piped, err = execStdin(os.Stdin, prompt, useStdin); err != nil {
+			return err
+		}
+		if strings.TrimSpace(prompt) == "" {
+			prompt, piped = piped, ""
+		}
 	}
-	authPath := cfg.Auth.Path
-	if strings.TrimSpace(authPath) == "" {
-		var err error
-		authPath, err = auth.DefaultPath()
-		if err != nil {
+	if !repl && !batch && strings.TrimSpace(prompt) == "" && strings.TrimSpace(inputJSON) == "" && templateItems == nil {
+		return errors.New("--prompt is required unless --input-json, --template, --repl or --prompts-file is provided or stdin is piped")
+	}
+	if repl && (mock || countTokens || imageGen) {
+		return errors.New("--repl cannot be combined with --mock, --count-tokens or --image-gen")
+	}
+	if dryRun && (repl || batch || mock || countTokens || imageGen) {
+		return errors.New("--dry-run cannot be combined with --repl, --prompts-file, --mock, --count-tokens or --image-gen")
+	}
+	if routeURL != "" && !dryRun {
+		return errors.New("--url requires --dry-run")
+	}
+	if exportSanitized && exportHistory == "" {
+		return errors.New("--export-sanitized requires --export-history")
+	}
+	if exportHistory != "" && (batch || mock || countTokens || imageGen) {
+		return errors.New("--export-history cannot be combined with --prompts-file, --mock, --count-tokens or --image-gen")
+	}
+	var prompts []batchPrompt
+	if batch {
+		if repl || mock || countTokens || imageGen || sessionFile != "" || len(images) > 0 {
+			return errors.New("--prompts-file cannot be combined with --repl, --mock, --count-tokens, --image-gen, --session-file or --image")
+		}
+		if strings.TrimSpace(prompt) != "" || strings.TrimSpace(inputJSON) != "" {
+			return errors.New("--prompts-file replaces --prompt and --input-json")
+		}
+		if strings.TrimSpace(outputDir) == "" {
+			return errors.New("--prompts-file requires --output-dir")
+		}
+		if concurrency < 1 {
+			return errors.New("--concurrency must be at least 1")
+		}
+		if prompts, err = readBatchPrompts(promptsFile); err != nil {
 			return err
 		}
 	}
-	store, err := auth.Load(authPath)
-	if err != nil {
-		return err
+	if strings.TrimSpace(upstreamAuditPath) != "" {
+		cfg.Proxy.UpstreamAuditPath = strings.TrimSpace(upstreamAuditPath)
 	}
 
 	if strings.TrimSpace(sessionID) == "" {
@@ -191,21 +408,35 @@ func runExec(args []string) error {
 		instructions = strings.TrimSpace(instructions) + "\n\n" + strings.TrimSpace(appendSystemPrompt)
 	}
 
-	inputItems := []protocol.ResponseInputItem{protocol.UserMessage(prompt)}
+	var inputItems []protocol.ResponseInputItem
+	if !(repl || batch) || strings.TrimSpace(prompt) != "" {
+		inputItems = append(inputItems, protocol.UserMessage(prompt))
+	}
+	if piped != "" {
+		inputItems = append(inputItems, protocol.UserMessage(piped))
+	}
 	if strings.TrimSpace(inputJSON) != "" {
 		buf, err := os.ReadFile(inputJSON)
 		if err != nil {
 			return fmt.Errorf("read input json: %w", err)
 		}
-		if err := json.Unmarshal(buf, &inputItems); err != nil {
+		if inputItems, err = parseInputJSON(buf); err != nil {
 			return fmt.Errorf("parse input json: %w", err)
 		}
 	}
+	if templateItems != nil {
+		inputItems = templateItems
+	}
 
 	// Build the harness Turn from exec args
 	turn := &harness.Turn{
-		Model:        model,
-		Instructions: instructions,
+		Model:          model,
+		Instructions:   instructions,
+		StopSequences:  stopSequences,
+		PromptCacheKey: sessionID,
+	}
+	if err := turn.ValidateSampling(); err != nil {
+		return err
 	}
 	// Convert input items to harness messages
 	for _, item := range inputItems {
@@ -234,6 +465,11 @@ func runExec(args []string) error {
 			})
 		}
 	}
+	if len(images) > 0 {
+		if err := attachImages(turn.Messages, images); err != nil {
+			return err
+		}
+	}
 	// Convert tool specs to harness format
 	for _, t := range toolSpecs {
 		if t.Type == "function" {
@@ -256,11 +492,19 @@ func runExec(args []string) error {
 		Input:             inputItems,
 		Tools:             toolSpecs,
 		ToolChoice:        normalizeToolChoice(toolChoice),
-		ParallelToolCalls: false,
+		ParallelToolCalls: autoTools && parallelTools > 0,
 		Store:             false,
 		Stream:            true,
 		Include:           []string{},
 		PromptCacheKey:    sessionID,
+		StopSequences:     stopSequences,
+	}
+
+	if dryRun {
+		if routeURL != "" && routeKey == "" {
+			routeKey = os.Getenv("GODEX_API_KEY")
+		}
+		return runDryRun(req, routeURL, routeKey, os.Stdout, os.Stderr)
 	}
 
 	if logRequests != "" {
@@ -270,9 +514,28 @@ func runExec(args []string) error {
 	}
 
 	if mock {
+		if countTokens {
+			return printTokenCount(model, harness.EstimateTurnTokens(turn), jsonOnly)
+		}
 		return emitMockStream(req, jsonOnly, logResponses, mockMode)
 	}
 
+	if cfg.Auth.RefreshURL != "" || cfg.Auth.ClientID != "" || cfg.Auth.Scope != "" {
+		auth.SetRefreshConfig(cfg.Auth.RefreshURL, cfg.Auth.ClientID, cfg.Auth.Scope)
+	}
+	authPath := cfg.Auth.Path
+	if strings.TrimSpace(authPath) == "" {
+		var err error
+		authPath, err = auth.DefaultPath()
+		if err != nil {
+			return err
+		}
+	}
+	store, err := auth.Load(authPath)
+	if err != nil {
+		return err
+	}
+
 	execRouter, err := buildExecHarnessRouter(cfg, store, allowRefresh, sessionID, nativeTools)
 	if err != nil {
 		return err
@@ -284,42 +547,173 @@ func runExec(args []string) error {
 		return fmt.Errorf("no harness configured for model %q", model)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Exec.Timeout)
-	defer cancel()
-
+	ctx := context.Background()
 	// Inject provider key into context if provided
 	if providerKey != "" {
 		ctx = harness.WithProviderKey(ctx, providerKey)
 	}
 
-	onEvent := newExecEventHandler(jsonOnly, trace, logResponses)
-	if autoTools {
-		outputs, err := parseToolOutputs(outputs)
-		if err != nil {
+	var sessions *harnessCodexP.Harness
+	if sessionFile != "" {
+		var ok bool
+		if sessions, ok = h.(*harnessCodexP.Harness); !ok {
+			return fmt.Errorf("--session-file requires a codex model, got %s (%s)", model, h.Name())
+		}
+		saved, err := sessions.LoadSession(sessionFile)
+		switch {
+		case err == nil:
+			turn.Messages = append(saved.Messages, turn.Messages...)
+			if saved.PromptCacheKey != "" {
+				turn.PromptCacheKey = saved.PromptCacheKey
+			}
+		case !errors.Is(err, os.ErrNotExist):
 			return err
 		}
-		handler := execToolHandler{outputs: outputs}
-		result, err := h.RunToolLoop(ctx, turn, handler, harness.LoopOptions{
-			MaxTurns: cfg.Exec.AutoToolsMax,
-			OnEvent:  onEvent,
-		})
+	}
+
+	if imageGen {
+		genCtx, cancel := context.WithTimeout(ctx, cfg.Exec.Timeout)
+		defer cancel()
+		imageOpts.Model = model
+		return runImageGen(genCtx, h, prompt, imageOpts, imageOut, jsonOnly, os.Stdout)
+	}
+
+	if countTokens {
+		countCtx, cancel := context.WithTimeout(ctx, cfg.Exec.Timeout)
+		defer cancel()
+		n, err := h.CountTokens(countCtx, turn)
 		if err != nil {
+			return fmt.Errorf("count tokens: %w", err)
+		}
+		return printTokenCount(model, n, jsonOnly)
+	}
+
+	var handler execToolHandler
+	if autoTools {
+		if handler.outputs, err = parseToolOutputs(outputs); err != nil {
+			return err
+		}
+	}
+	// The exec timeout bounds the whole loop, not each turn.
+	loopOpts := harness.LoopOptions{
+		MaxTurns:             cfg.Exec.AutoToolsMax,
+		MaxDuration:          cfg.Exec.Timeout,
+		ParallelToolCalls:    parallelTools > 0,
+		MaxParallelToolCalls: parallelTools,
+	}
+
+	if promptsFile != "" {
+		collect := func(ctx context.Context, turn *harness.Turn) (*harness.TurnResult, error) {
+			if autoTools {
+				return h.RunToolLoop(ctx, turn, handler, loopOpts)
+			}
+			ctx, cancel := context.WithTimeout(ctx, cfg.Exec.Timeout)
+			defer cancel()
+			return h.StreamAndCollect(ctx, turn)
+		}
+		return runPromptBatch(ctx, prompts, turn, collect, concurrency, outputDir, jsonOnly, os.Stderr)
+	}
+
+	if repl {
+		var save func(*harness.Turn) error
+		if sessions != nil {
+			save = func(turn *harness.Turn) error { return sessions.SaveSession(sessionFile, turn) }
+		}
+		var usage *harness.UsageEvent
+		run := func(ctx context.Context, turn *harness.Turn) ([]harness.Event, error) {
+			onEvent := newExecEventHandler(jsonOnly, trace, diffMode, logResponses, colorCfg)
+			if autoTools {
+				opts := loopOpts
+				opts.OnEvent = onEvent
+				result, err := h.RunToolLoop(ctx, turn, handler, opts)
+				if err != nil {
+					return nil, err
+				}
+				usage = addUsage(usage, result.Events)
+				return result.Events, nil
+			}
+			ctx, cancel := context.WithTimeout(ctx, cfg.Exec.Timeout)
+			defer cancel()
+			var events []harness.Event
+			err := h.StreamTurn(ctx, turn, func(ev harness.Event) error {
+				events = append(events, ev)
+				return onEvent(ev)
+			})
+			usage = addUsage(usage, events)
+			return events, err
+		}
+		if err := runREPL(ctx, os.Stdin, os.Stdout, os.Stderr, turn, run, save); err != nil || exportHistory == "" {
 			return err
 		}
-		_ = result
+		return writeHistoryExport(exportHistory, turn, usage, exportSanitized)
+	}
+
+	keep := sessions != nil || exportHistory != ""
+	// finish records the reply in the session and export files.
+	finish := func(events []harness.Event, usage *harness.UsageEvent) error {
+		turn.Messages = append(turn.Messages, replyMessages(events)...)
+		if sessions != nil {
+			if err := sessions.SaveSession(sessionFile, turn); err != nil {
+				return err
+			}
+		}
+		if exportHistory != "" {
+			return writeHistoryExport(exportHistory, turn, usage, exportSanitized)
+		}
 		return nil
 	}
 
-	return h.StreamTurn(ctx, turn, onEvent)
+	onEvent := newExecEventHandler(jsonOnly, trace, diffMode, logResponses, colorCfg)
+	if autoTools {
+		loopOpts.OnEvent = onEvent
+		result, err := h.RunToolLoop(ctx, turn, handler, loopOpts)
+		if errors.Is(err, harness.ErrLoopTimeout) {
+			return fmt.Errorf("%w; raise exec.timeout to allow longer tool loops", err)
+		}
+		if err != nil || !keep {
+			return err
+		}
+		return finish(result.Events, result.Usage)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Exec.Timeout)
+	defer cancel()
+
+	if !keep {
+		return h.StreamTurn(ctx, turn, onEvent)
+	}
+	var events []harness.Event
+	err = h.StreamTurn(ctx, turn, func(ev harness.Event) error {
+		events = append(events, ev)
+		return onEvent(ev)
+	})
+	if err != nil {
+		return err
+	}
+	return finish(events, addUsage(nil, events))
 }
 
-func newExecEventHandler(jsonOnly, trace bool, logResponses string) func(harness.Event) error {
+// printTokenCount reports the result of exec --count-tokens.
+func printTokenCount(model string, n int, jsonOnly bool) error {
+	if jsonOnly {
+		return json.NewEncoder(os.Stdout).Encode(map[string]any{"model": model, "input_tokens": n})
+	}
+	fmt.Println(n)
+	return nil
+}
+
+func newExecEventHandler(jsonOnly, trace, diffMode bool, logResponses string, colorCfg colorConfig) func(harness.Event) error {
 	var jsonEmitter *execJSONEmitter
 	if jsonOnly {
 		jsonEmitter = newExecJSONEmitter(os.Stdout, logResponses)
 	}
+	stdoutColors, stderrColors := colorCfg.forFile(os.Stdout), colorCfg.forFile(os.Stderr)
 	return func(ev harness.Event) error {
 		if jsonEmitter != nil {
+			// Keep stdout JSON; diffs go to stderr instead.
+			if diffMode && ev.Kind == harness.EventToolCall && ev.ToolCall != nil {
+				writeToolCall(os.Stderr, ev.ToolCall, stderrColors)
+			}
 			return jsonEmitter.Emit(ev)
 		}
 		if logResponses != "" {
@@ -336,6 +730,9 @@ func newExecEventHandler(jsonOnly, trace bool, logResponses string) func(harness
 		if ev.Kind == harness.EventText && ev.Text != nil {
 			fmt.Print(ev.Text.Delta)
 		}
+		if diffMode && ev.Kind == harness.EventToolCall && ev.ToolCall != nil {
+			writeToolCall(os.Stdout, ev.ToolCall, stdoutColors)
+		}
 		return nil
 	}
 }
@@ -345,6 +742,7 @@ type execJSONEmitter struct {
 	logPath    string
 	textOpened bool
 	usage      *harness.UsageEvent
+	stop       *harness.StopReasonEvent
 	toolSeq    int
 	completed  bool
 }
@@ -415,6 +813,9 @@ func (e *execJSONEmitter) Emit(ev harness.Event) error {
 	case harness.EventUsage:
 		e.usage = ev.Usage
 		return nil
+	case harness.EventStopReason:
+		e.stop = ev.StopReason
+		return nil
 	case harness.EventError:
 		msg := "unknown error"
 		if ev.Error != nil && strings.TrimSpace(ev.Error.Message) != "" {
@@ -444,16 +845,24 @@ func (e *execJSONEmitter) emitCompleted() error {
 	if e.usage != nil {
 		usage["input_tokens"] = e.usage.InputTokens
 		usage["output_tokens"] = e.usage.OutputTokens
+		usage["cached_tokens"] = e.usage.CachedTokens
 		if e.usage.TotalTokens > 0 {
 			usage["total_tokens"] = e.usage.TotalTokens
 		}
 	}
+	response := map[string]any{
+		"status": "completed",
+		"usage":  usage,
+	}
+	if e.stop != nil {
+		response["stop_reason"] = e.stop.Reason
+		if e.stop.Sequence != "" {
+			response["stop_sequence"] = e.stop.Sequence
+		}
+	}
 	return e.write(map[string]any{
-		"type": "response.completed",
-		"response": map[string]any{
-			"status": "completed",
-			"usage":  usage,
-		},
+		"type":     "response.completed",
+		"response": response,
 	})
 }
 
@@ -641,41 +1050,62 @@ func buildExecHarnessRouter(cfg config.Config, store *auth.Store, allowRefresh b
 	r := router.New(router.Config{
 		UserAliases:  cfg.Proxy.Backends.Routing.Aliases,
 		UserPatterns: cfg.Proxy.Backends.Routing.Patterns,
+		Weights:      cfg.Proxy.Backends.Routing.Weights,
+		Canary:       router.CanaryConfig(cfg.Proxy.Backends.Routing.Canary),
 	})
 	registered := 0
 
 	baseURL := cfg.Client.BaseURL
 	if baseURL == "" {
-		baseURL = "https://chatgpt.com/backend-api/codex"
+		baseURL = auth.DefaultCodexBaseURL
 	}
 	codexClient := harnessCodexP.NewClient(nil, store, harnessCodexP.ClientConfig{
-		SessionID:    sessionID,
-		AllowRefresh: allowRefresh,
-		BaseURL:      baseURL,
-		Originator:   cfg.Client.Originator,
-		UserAgent:    cfg.Client.UserAgent,
-		RetryMax:     cfg.Client.RetryMax,
-		RetryDelay:   cfg.Client.RetryDelay,
+		SessionID:      sessionID,
+		AllowRefresh:   allowRefresh,
+		BaseURL:        baseURL,
+		Originator:     cfg.Client.Originator,
+		UserAgent:      cfg.Client.UserAgent,
+		RetryMax:       cfg.Client.RetryMax,
+		RetryDelay:     cfg.Client.RetryDelay,
+		MaxRetryDelay:  cfg.Client.MaxRetryDelay,
+		AccountProfile: cfg.Client.AccountProfile,
 	})
+	if err := harness.ValidateCompaction(cfg.Proxy.Backends.Codex.Compaction); err != nil {
+		return nil, fmt.Errorf("backends.codex.compaction: %w", err)
+	}
 	r.Register("codex", harnessCodexP.New(harnessCodexP.Config{
-		Client:        codexClient,
-		NativeTools:   nativeTools,
-		ExtraAliases:  cfg.Proxy.Backends.Routing.Aliases,
-		ExtraPrefixes: cfg.Proxy.Backends.Routing.Patterns["codex"],
-	}))
+		Client:                codexClient,
+		NativeTools:           nativeTools,
+		ExtraAliases:          cfg.Proxy.Backends.Routing.Aliases,
+		ExtraPrefixes:         cfg.Proxy.Backends.Routing.Patterns["codex"],
+		MaxContextTokens:      cfg.Proxy.Backends.Codex.MaxContextTokens,
+		Compaction:            cfg.Proxy.Backends.Codex.Compaction,
+		SystemPromptTemplates: cfg.Proxy.Backends.Codex.SystemPromptTemplates,
+	}), router.WithPriority(codexPriority))
 	registered++
 
 	if cfg.Proxy.Backends.Anthropic.Enabled {
+		if err := harnessClaudeP.ValidateThinkingBudget(cfg.Proxy.Backends.Anthropic.ThinkingBudget); err != nil {
+			return nil, fmt.Errorf("backends.anthropic.thinking_budget: %w", err)
+		}
 		anthTokens := harnessClaudeP.NewTokenStore(cfg.Proxy.Backends.Anthropic.CredentialsPath)
 		if err := anthTokens.Load(); err == nil {
 			wrapper := harnessClaudeP.NewClientWrapper(anthTokens, harnessClaudeP.ClientConfig{
 				DefaultMaxTokens: cfg.Proxy.Backends.Anthropic.DefaultMaxTokens,
+				BetaFeatures:     cfg.Proxy.Backends.Anthropic.BetaFeatures,
+				MaxRetries:       cfg.Proxy.Backends.Anthropic.MaxRetries,
+				MaxRetryDelay:    cfg.Proxy.Backends.Anthropic.MaxRetryDelay,
 			})
 			r.Register("anthropic", harnessClaudeP.New(harnessClaudeP.Config{
-				Client:           wrapper,
-				DefaultMaxTokens: cfg.Proxy.Backends.Anthropic.DefaultMaxTokens,
-				ExtraAliases:     cfg.Proxy.Backends.Routing.Aliases,
-			}))
+				Client:              wrapper,
+				DefaultMaxTokens:    cfg.Proxy.Backends.Anthropic.DefaultMaxTokens,
+				ThinkingBudget:      cfg.Proxy.Backends.Anthropic.ThinkingBudget,
+				ExtraAliases:        cfg.Proxy.Backends.Routing.Aliases,
+				EnablePromptCaching: cfg.Proxy.Backends.Anthropic.PromptCaching,
+				CacheBreakpoint:     cfg.Proxy.Backends.Anthropic.CacheBreakpoint,
+				ModelCacheTTL:       cfg.Proxy.Backends.Anthropic.ModelCacheTTL,
+				UseBatchAPI:         cfg.Proxy.Backends.Anthropic.UseBatchAPI,
+			}), router.WithPriority(anthropicPriority))
 			registered++
 		}
 	}
@@ -769,9 +1199,17 @@ func runProxy(args []string) error {
 	fs := flag.NewFlagSet("proxy", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 
-	cfg := config.LoadFrom(configPathFromArgs(args))
+	cfg, err := loadConfig(configPathFromArgs(args))
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(cfg).Fatal(); err != nil {
+		return err
+	}
 
 	var listen string
+	var tlsCert string
+	var tlsKey string
 	var apiKey string
 	var model string
 	var baseURL string
@@ -795,6 +1233,17 @@ func runProxy(args []string) error {
 	var eventsMaxBytes int64
 	var eventsBackups int
 	var meterWindow string
+	var drainTimeout time.Duration
+	var heartbeatInterval time.Duration
+	var maxRequestBytes int64
+	var corsOrigins string
+	var allowIPs string
+	var denyIPs string
+	var trustProxyHeaders bool
+	responseHeaders := headerFlags{}
+	for name, value := range cfg.Proxy.ResponseHeaders {
+		responseHeaders[name] = value
+	}
 	var syncAliases bool
 	var proxyNativeTools bool
 	var tracePath string
@@ -802,8 +1251,10 @@ func runProxy(args []string) error {
 	var traceBackups int
 	var upstreamAuditPath string
 
-	configPath := fs.String("config", config.DefaultPath(), "Config file path")
+	configPath := configFlag(fs, args)
 	fs.StringVar(&listen, "listen", cfg.Proxy.Listen, "Listen address")
+	fs.StringVar(&tlsCert, "cert", cfg.Proxy.TLSCertFile, "TLS certificate file (enables HTTPS with --key)")
+	fs.StringVar(&tlsKey, "key", cfg.Proxy.TLSKeyFile, "TLS private key file (enables HTTPS with --cert)")
 	fs.StringVar(&apiKey, "api-key", cfg.Proxy.APIKey, "API key")
 	fs.StringVar(&model, "model", cfg.Proxy.Model, "Model name")
 	fs.StringVar(&baseURL, "base-url", cfg.Proxy.BaseURL, "Upstream base URL")
@@ -831,6 +1282,14 @@ func runProxy(args []string) error {
 	fs.IntVar(&traceBackups, "trace-max-backups", cfg.Proxy.TraceBackups, "Max rotated trace files to keep")
 	fs.StringVar(&upstreamAuditPath, "upstream-audit-path", cfg.Proxy.UpstreamAuditPath, "Upstream model SSE audit JSONL path")
 	fs.StringVar(&meterWindow, "meter-window", cfg.Proxy.MeterWindow.String(), "Metering window duration (e.g. 24h); empty disables window")
+	fs.DurationVar(&drainTimeout, "drain-timeout", cfg.Proxy.DrainTimeout, "Max time to wait for in-flight requests on shutdown")
+	fs.DurationVar(&heartbeatInterval, "heartbeat-interval", cfg.Proxy.HeartbeatInterval, "SSE keepalive ping interval on streams (0 disables)")
+	fs.Int64Var(&maxRequestBytes, "max-request-bytes", cfg.Proxy.MaxRequestBytes, "Max JSON request body size; larger requests get 413")
+	fs.StringVar(&corsOrigins, "cors-allow-origins", strings.Join(cfg.Proxy.CORSAllowOrigins, ","), "Comma-separated origins allowed for browser clients (* = any; empty disables CORS)")
+	fs.StringVar(&allowIPs, "allow-ips", strings.Join(cfg.Proxy.AllowIPs, ","), "Comma-separated CIDRs allowed to connect (empty = any)")
+	fs.StringVar(&denyIPs, "deny-ips", strings.Join(cfg.Proxy.DenyIPs, ","), "Comma-separated CIDRs rejected with 403")
+	fs.BoolVar(&trustProxyHeaders, "trust-proxy-headers", cfg.Proxy.TrustProxyHeaders, "Use X-Forwarded-For/X-Real-IP for the client address")
+	fs.Var(responseHeaders, "response-header", "Header added to every response as Key=Value (repeatable; ${ENV} expanded)")
 	fs.BoolVar(&syncAliases, "sync-aliases", false, "Update model aliases from providers on startup")
 	fs.BoolVar(&proxyNativeTools, "native-tools", cfg.Proxy.Backends.Codex.NativeTools, "Use Codex native tools (shell, apply_patch) instead of proxy mode")
 
@@ -865,45 +1324,63 @@ func runProxy(args []string) error {
 		models = append(models, proxy.ModelEntry{ID: m.ID, BaseURL: m.BaseURL})
 	}
 	proxyCfg := proxy.Config{
-		Listen:          listen,
-		Version:         Version,
-		APIKey:          apiKey,
-		Model:           model,
-		Models:          models,
-		BaseURL:         baseURL,
-		AllowRefresh:    allowRefresh,
-		AllowAnyKey:     allowAnyKey,
-		AuthPath:        authPath,
-		Originator:      originator,
-		UserAgent:       userAgent,
-		CacheTTL:        ttl,
-		LogLevel:        logLevel,
-		LogRequests:     logRequests,
-		KeysPath:        keysPath,
-		RateLimit:       rateLimit,
-		Burst:           burst,
-		QuotaTokens:     quotaTokens,
-		StatsPath:       statsPath,
-		StatsSummary:    statsSummary,
-		StatsMaxBytes:   statsMaxBytes,
-		StatsMaxBackups: statsMaxBackups,
-		EventsPath:      eventsPath,
-		EventsMaxBytes:  eventsMaxBytes,
-		EventsBackups:   eventsBackups,
-		AuditPath:       cfg.Proxy.AuditPath,
-		AuditMaxBytes:   cfg.Proxy.AuditMaxBytes,
-		AuditBackups:    cfg.Proxy.AuditBackups,
-		TracePath:       tracePath,
-		TraceMaxBytes:   traceMaxBytes,
-		TraceBackups:    traceBackups,
-		MeterWindow:     window,
-		AdminSocket:     cfg.Proxy.AdminSocket,
-		Payments:        payCfg,
+		Listen:            listen,
+		TLSCertFile:       tlsCert,
+		TLSKeyFile:        tlsKey,
+		Version:           Version,
+		APIKey:            apiKey,
+		Model:             model,
+		Models:            models,
+		BaseURL:           baseURL,
+		AllowRefresh:      allowRefresh,
+		AllowAnyKey:       allowAnyKey,
+		AuthPath:          authPath,
+		Originator:        originator,
+		UserAgent:         userAgent,
+		CacheTTL:          ttl,
+		LogLevel:          logLevel,
+		LogRequests:       logRequests,
+		KeysPath:          keysPath,
+		RateLimit:         rateLimit,
+		Burst:             burst,
+		QuotaTokens:       quotaTokens,
+		StatsPath:         statsPath,
+		StatsSummary:      statsSummary,
+		StatsMaxBytes:     statsMaxBytes,
+		StatsMaxBackups:   statsMaxBackups,
+		EventsPath:        eventsPath,
+		EventsMaxBytes:    eventsMaxBytes,
+		EventsBackups:     eventsBackups,
+		AuditPath:         cfg.Proxy.AuditPath,
+		AuditMaxBytes:     cfg.Proxy.AuditMaxBytes,
+		AuditBackups:      cfg.Proxy.AuditBackups,
+		TracePath:         tracePath,
+		TraceMaxBytes:     traceMaxBytes,
+		TraceBackups:      traceBackups,
+		MeterWindow:       window,
+		DrainTimeout:      drainTimeout,
+		HeartbeatInterval: heartbeatInterval,
+		MaxRequestBytes:   maxRequestBytes,
+		ModelQuotas:       cfg.Proxy.ModelQuotas,
+		CORSAllowOrigins:  strings.Split(corsOrigins, ","),
+		CORSAllowHeaders:  cfg.Proxy.CORSAllowHeaders,
+		WebhookMaxRetries: cfg.Proxy.WebhookMaxRetries,
+		AllowIPs:          strings.Split(allowIPs, ","),
+		DenyIPs:           strings.Split(denyIPs, ","),
+		TrustProxyHeaders: trustProxyHeaders,
+		TrustedProxies:    cfg.Proxy.TrustedProxies,
+		ProbePrompt:       cfg.Proxy.ProbePrompt,
+		ResponseHeaders:   responseHeaders,
+		StatsStreamMaxAge: cfg.Proxy.StatsStreamMaxAge,
+		AdminSocket:       cfg.Proxy.AdminSocket,
+		AdminHTTPAddr:     cfg.Proxy.AdminHTTPAddr,
+		AdminAPIKey:       cfg.Proxy.AdminAPIKey,
+		AdminDebug:        cfg.Proxy.AdminDebug,
+		Payments:          payCfg,
 		Backends: proxy.BackendsConfig{
 			Codex: proxy.CodexBackendConfig{
-				Enabled:         cfg.Proxy.Backends.Codex.Enabled,
-				BaseURL:         cfg.Proxy.Backends.Codex.BaseURL,
-				CredentialsPath: cfg.Proxy.Backends.Codex.CredentialsPath,
+				Enabled: cfg.Proxy.Backends.Codex.Enabled,
+				BaseURL: cfg.Proxy.Backends.Codex.BaseURL,
 			},
 			Anthropic: proxy.AnthropicBackendConfig{
 				Enabled:          cfg.Proxy.Backends.Anthropic.Enabled,
@@ -912,15 +1389,29 @@ func runProxy(args []string) error {
 			},
 			Custom: cfg.Proxy.Backends.Custom,
 			Routing: proxy.RoutingConfig{
-				Patterns: cfg.Proxy.Backends.Routing.Patterns,
-				Aliases:  cfg.Proxy.Backends.Routing.Aliases,
+				Patterns:            cfg.Proxy.Backends.Routing.Patterns,
+				Aliases:             cfg.Proxy.Backends.Routing.Aliases,
+				StickySessionTTL:    cfg.Proxy.Backends.Routing.StickySessionTTL,
+				Weights:             cfg.Proxy.Backends.Routing.Weights,
+				LatencyRouting:      cfg.Proxy.Backends.Routing.LatencyRouting,
+				WarmupRequests:      cfg.Proxy.Backends.Routing.WarmupRequests,
+				Canary:              router.CanaryConfig(cfg.Proxy.Backends.Routing.Canary),
+				HealthCheckInterval: cfg.Proxy.Backends.Routing.HealthCheckInterval,
 			},
 		},
+		AllowBackendOverride: cfg.Proxy.AllowBackendOverride,
+		JWT:                  auth.JWTConfig(cfg.Proxy.JWT),
+		HMACSecret:           cfg.Proxy.HMACSecret,
+		ServiceAccountSecret: cfg.Proxy.ServiceAccountSecret,
 		Metrics: proxy.MetricsConfig{
 			Enabled:     cfg.Proxy.Metrics.Enabled,
 			Path:        cfg.Proxy.Metrics.Path,
 			LogRequests: cfg.Proxy.Metrics.LogRequests,
 		},
+		CircuitBreaker: proxy.CircuitBreakerConfig{
+			FailureThreshold: cfg.Proxy.CircuitBreaker.FailureThreshold,
+			RecoveryWindow:   cfg.Proxy.CircuitBreaker.RecoveryWindow,
+		},
 	}
 	// Apply CLI flag overrides to config
 	if proxyNativeTools {
@@ -935,6 +1426,26 @@ func runProxy(args []string) error {
 		}
 	}
 
+	if err := harness.ValidateCompaction(cfg.Proxy.Backends.Codex.Compaction); err != nil {
+		return fmt.Errorf("backends.codex.compaction: %w", err)
+	}
+	proxyCfg.WebhookAllowedHosts = cfg.Proxy.WebhookAllowedHosts
+	if cfg.Proxy.Moderation.Enabled {
+		moderator, err := buildModerator(cfg)
+		if err != nil {
+			return fmt.Errorf("proxy.moderation: %w", err)
+		}
+		proxyCfg.Moderation = proxy.ModerationConfig{
+			Enabled:     true,
+			Threshold:   cfg.Proxy.Moderation.Threshold,
+			BlockOnFail: cfg.Proxy.Moderation.BlockOnFail,
+			Moderator:   moderator,
+		}
+	}
+	if err := harnessClaudeP.ValidateThinkingBudget(cfg.Proxy.Backends.Anthropic.ThinkingBudget); err != nil {
+		return fmt.Errorf("backends.anthropic.thinking_budget: %w", err)
+	}
+
 	// Build harness router
 	harnessRouter := buildHarnessRouter(cfg, proxyCfg)
 	if harnessRouter == nil {
@@ -942,14 +1453,52 @@ func runProxy(args []string) error {
 	}
 	proxyCfg.HarnessRouter = harnessRouter
 
+	reloads := make(chan proxy.Config, 1)
+	proxyCfg.Reloads = reloads
+	reloader := newConfigReloader(*configPath, cfg, proxyCfg)
+	proxyCfg.ConfigReload = reloader.reload
+	proxyCfg.AdminConfig = reloader.sanitized
+	stopWatch := watchConfig(reloader, reloads)
+	defer stopWatch()
+
 	return proxy.Run(proxyCfg)
 }
 
+// buildModerator creates the client for the custom backend named by
+// proxy.moderation.backend.
+func buildModerator(cfg config.Config) (*harnessOpenaiP.Client, error) {
+	name := cfg.Proxy.Moderation.Backend
+	bcfg, ok := cfg.Proxy.Backends.Custom[name]
+	if !ok || bcfg.Type != "openai" {
+		return nil, fmt.Errorf("backend %q is not a configured openai backend", name)
+	}
+	return harnessOpenaiP.NewClient(harnessOpenaiP.ClientConfig{
+		Name:    name,
+		BaseURL: bcfg.BaseURL,
+		Auth:    bcfg.Auth,
+		Timeout: bcfg.Timeout,
+	})
+}
+
+// Registration priorities: when several backends claim a model, Codex is
+// preferred over Anthropic, and both over custom backends.
+const (
+	codexPriority     = 100
+	anthropicPriority = 50
+)
+
 // buildHarnessRouter creates a harness router with all configured providers.
 func buildHarnessRouter(cfg config.Config, proxyCfg proxy.Config) *router.Router {
 	routingCfg := router.Config{
-		UserAliases:  proxyCfg.Backends.Routing.Aliases,
-		UserPatterns: proxyCfg.Backends.Routing.Patterns,
+		UserAliases:         proxyCfg.Backends.Routing.Aliases,
+		UserPatterns:        proxyCfg.Backends.Routing.Patterns,
+		StickySessionTTL:    proxyCfg.Backends.Routing.StickySessionTTL,
+		Weights:             proxyCfg.Backends.Routing.Weights,
+		LatencyRouting:      proxyCfg.Backends.Routing.LatencyRouting,
+		WarmupRequests:      proxyCfg.Backends.Routing.WarmupRequests,
+		Canary:              proxyCfg.Backends.Routing.Canary,
+		HealthCheckInterval: proxyCfg.Backends.Routing.HealthCheckInterval,
+		Debug:               proxy.ParseLogLevel(proxyCfg.LogLevel) == proxy.LogLevelDebug,
 	}
 
 	r := router.New(routingCfg)
@@ -973,14 +1522,18 @@ func buildHarnessRouter(cfg config.Config, proxyCfg proxy.Config) *router.Router
 				UserAgent:         proxyCfg.UserAgent,
 				AllowRefresh:      proxyCfg.AllowRefresh,
 				UpstreamAuditPath: cfg.Proxy.UpstreamAuditPath,
+				AccountProfile:    cfg.Client.AccountProfile,
 			})
 			h := harnessCodexP.New(harnessCodexP.Config{
-				Client:        codexClient,
-				NativeTools:   cfg.Proxy.Backends.Codex.NativeTools,
-				ExtraAliases:  cfg.Proxy.Backends.Routing.Aliases,
-				ExtraPrefixes: cfg.Proxy.Backends.Routing.Patterns["codex"],
+				Client:                codexClient,
+				NativeTools:           cfg.Proxy.Backends.Codex.NativeTools,
+				ExtraAliases:          cfg.Proxy.Backends.Routing.Aliases,
+				ExtraPrefixes:         cfg.Proxy.Backends.Routing.Patterns["codex"],
+				MaxContextTokens:      cfg.Proxy.Backends.Codex.MaxContextTokens,
+				Compaction:            cfg.Proxy.Backends.Codex.Compaction,
+				SystemPromptTemplates: cfg.Proxy.Backends.Codex.SystemPromptTemplates,
 			})
-			r.Register("codex", h)
+			r.Register("codex", h, router.WithPriority(codexPriority))
 			registered++
 		}
 	}
@@ -991,13 +1544,21 @@ func buildHarnessRouter(cfg config.Config, proxyCfg proxy.Config) *router.Router
 		if err := anthTokens.Load(); err == nil {
 			wrapper := harnessClaudeP.NewClientWrapper(anthTokens, harnessClaudeP.ClientConfig{
 				DefaultMaxTokens: cfg.Proxy.Backends.Anthropic.DefaultMaxTokens,
+				BetaFeatures:     cfg.Proxy.Backends.Anthropic.BetaFeatures,
+				MaxRetries:       cfg.Proxy.Backends.Anthropic.MaxRetries,
+				MaxRetryDelay:    cfg.Proxy.Backends.Anthropic.MaxRetryDelay,
 			})
 			h := harnessClaudeP.New(harnessClaudeP.Config{
-				Client:           wrapper,
-				DefaultMaxTokens: cfg.Proxy.Backends.Anthropic.DefaultMaxTokens,
-				ExtraAliases:     cfg.Proxy.Backends.Routing.Aliases,
+				Client:              wrapper,
+				DefaultMaxTokens:    cfg.Proxy.Backends.Anthropic.DefaultMaxTokens,
+				ThinkingBudget:      cfg.Proxy.Backends.Anthropic.ThinkingBudget,
+				ExtraAliases:        cfg.Proxy.Backends.Routing.Aliases,
+				EnablePromptCaching: cfg.Proxy.Backends.Anthropic.PromptCaching,
+				CacheBreakpoint:     cfg.Proxy.Backends.Anthropic.CacheBreakpoint,
+				ModelCacheTTL:       cfg.Proxy.Backends.Anthropic.ModelCacheTTL,
+				UseBatchAPI:         cfg.Proxy.Backends.Anthropic.UseBatchAPI,
 			})
-			r.Register("anthropic", h)
+			r.Register("anthropic", h, router.WithPriority(anthropicPriority))
 			registered++
 		}
 	}
@@ -1027,12 +1588,41 @@ func buildHarnessRouter(cfg config.Config, proxyCfg proxy.Config) *router.Router
 		registered++
 	}
 
+	if routingCfg.HealthCheckInterval > 0 {
+		for _, name := range r.List() {
+			r.RegisterHealthCheck(name, listModelsHealthCheck(r.Get(name)))
+		}
+	}
+	if c := routingCfg.Canary; c.Backend != "" && r.Get(c.Backend) == nil {
+		fmt.Fprintf(os.Stderr, "warning: routing canary backend %q is not registered; canary disabled\n", c.Backend)
+	}
 	if registered == 0 {
 		return nil
 	}
 	return r
 }
 
+// modelDiscoverer is implemented by harnesses whose ListModels falls back
+// to a built-in list, which would hide an upstream failure from a health
+// check.
+type modelDiscoverer interface {
+	DiscoverModels(ctx context.Context) ([]harness.ModelInfo, error)
+}
+
+// listModelsHealthCheck reports a backend healthy while it can list its
+// models from the upstream: through DiscoverModels when the harness has it,
+// and ListModels otherwise.
+func listModelsHealthCheck(h harness.Harness) func(ctx context.Context) bool {
+	list := h.ListModels
+	if d, ok := h.(modelDiscoverer); ok {
+		list = d.DiscoverModels
+	}
+	return func(ctx context.Context) bool {
+		_, err := list(ctx)
+		return err == nil
+	}
+}
+
 // aliasModelLister adapts a harness to the aliases.ModelLister interface.
 type aliasModelLister struct {
 	listFn func(ctx context.Context) ([]aliases.ModelInfo, error)
@@ -1068,6 +1658,9 @@ func syncAliasesOnStartup(cfg config.Config, configPath string, proxyCfg *proxy.
 		if err := anthTokens.Load(); err == nil {
 			wrapper := harnessClaudeP.NewClientWrapper(anthTokens, harnessClaudeP.ClientConfig{
 				DefaultMaxTokens: cfg.Proxy.Backends.Anthropic.DefaultMaxTokens,
+				BetaFeatures:     cfg.Proxy.Backends.Anthropic.BetaFeatures,
+				MaxRetries:       cfg.Proxy.Backends.Anthropic.MaxRetries,
+				MaxRetryDelay:    cfg.Proxy.Backends.Anthropic.MaxRetryDelay,
 			})
 			backends["anthropic"] = &aliasModelLister{listFn: func(ctx context.Context) ([]aliases.ModelInfo, error) {
 				models, err := wrapper.ListModels(ctx)
@@ -1144,8 +1737,11 @@ func runProxyKeys(args []string) error {
 
 	fs := flag.NewFlagSet("proxy keys", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
-	cfg := config.LoadFrom(configPathFromArgs(args))
-	configPath := fs.String("config", config.DefaultPath(), "Config file path")
+	cfg, err := loadConfig(configPathFromArgs(args))
+	if err != nil {
+		return err
+	}
+	configPath := configFlag(fs, args)
 	keysPath := fs.String("keys-path", defaultString(cfg.Proxy.KeysPath, proxy.DefaultKeysPath()), "API keys file")
 	label := fs.String("label", "", "Key label")
 	providedKey := fs.String("key", "", "Use a pre-generated API key (BYOK)")
@@ -1153,6 +1749,12 @@ func runProxyKeys(args []string) error {
 	burst := fs.Int("burst", defaultInt(cfg.Proxy.DefaultBurst, 10), "Burst")
 	quota := fs.Int64("quota-tokens", defaultInt64(cfg.Proxy.DefaultQuota, 0), "Token quota")
 	expiresIn := fs.String("expires-in", "", "Key TTL (e.g. 24h); empty = no expiry")
+	allowedModels := fs.String("allowed-models", "", "Comma-separated models this key may call; empty = all")
+	signed := fs.Bool("signed", false, "With add, issue an HMAC-signed key (needs proxy.hmac_secret)")
+	bulkFile := fs.String("file", "", "With bulk, JSON array of key operations")
+	adminURL := fs.String("admin-url", "", "With bulk, admin HTTP URL such as http://127.0.0.1:39002 (default: the admin socket)")
+	modelQuotas := modelQuotaFlags{}
+	fs.Var(modelQuotas, "model-quota", "Per-model token quota as model=N (repeatable; N=0 removes)")
 	if err := fs.Parse(args[1:]); err != nil {
 		return err
 	}
@@ -1173,10 +1775,18 @@ func runProxyKeys(args []string) error {
 			}
 			ttl = d
 		}
-		rec, secret, err := store.Add(*label, *rate, *burst, *quota, *providedKey, ttl)
+		if *signed {
+			return addSignedKey(store, cfg.Proxy.HMACSecret, *label, *rate, *burst, *quota, ttl, proxy.ParseModelList(*allowedModels), *providedKey != "" || len(modelQuotas) > 0)
+		}
+		rec, secret, err := store.Add(*label, *rate, *burst, *quota, *providedKey, ttl, proxy.ParseModelList(*allowedModels)...)
 		if err != nil {
 			return err
 		}
+		if len(modelQuotas) > 0 {
+			if rec, err = store.SetModelQuotas(rec.ID, modelQuotas); err != nil {
+				return err
+			}
+		}
 		fmt.Printf("id=%s label=%s key=%s\n", rec.ID, rec.Label, secret)
 	case "list":
 		for _, rec := range store.List() {
@@ -1188,7 +1798,7 @@ func runProxyKeys(args []string) error {
 			if rec.ExpiresAt != nil {
 				expires = rec.ExpiresAt.Format(time.RFC3339)
 			}
-			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\n", rec.ID, rec.Label, rec.CreatedAt.Format(time.RFC3339), revoked, rec.Rate, rec.Burst, rec.QuotaTokens, expires)
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n", rec.ID, rec.Label, rec.CreatedAt.Format(time.RFC3339), revoked, rec.Rate, rec.Burst, rec.QuotaTokens, expires, strings.Join(rec.AllowedModels, ","), modelQuotaFlags(rec.ModelQuotas).String())
 		}
 	case "revoke":
 		if len(fs.Args()) == 0 {
@@ -1210,11 +1820,20 @@ func runProxyKeys(args []string) error {
 			}
 			ttl = d
 		}
-		rec, err := store.Update(fs.Args()[0], *label, *rate, *burst, *quota, ttl)
+		var models []string
+		if strings.TrimSpace(*allowedModels) != "" {
+			models = proxy.ParseModelList(*allowedModels)
+		}
+		rec, err := store.Update(fs.Args()[0], *label, *rate, *burst, *quota, ttl, models)
 		if err != nil {
 			return err
 		}
-		fmt.Printf("id=%s label=%s rate=%s burst=%d quota=%d\n", rec.ID, rec.Label, rec.Rate, rec.Burst, rec.QuotaTokens)
+		if len(modelQuotas) > 0 {
+			if rec, err = store.SetModelQuotas(rec.ID, modelQuotas); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("id=%s label=%s rate=%s burst=%d quota=%d allowed_models=%s model_quotas=%s\n", rec.ID, rec.Label, rec.Rate, rec.Burst, rec.QuotaTokens, strings.Join(rec.AllowedModels, ","), modelQuotaFlags(rec.ModelQuotas).String())
 	case "rotate":
 		if len(fs.Args()) == 0 {
 			return errors.New("rotate requires id or key")
@@ -1224,12 +1843,42 @@ func runProxyKeys(args []string) error {
 			return err
 		}
 		fmt.Printf("id=%s label=%s key=%s\n", rec.ID, rec.Label, secret)
+	case "bulk":
+		if strings.TrimSpace(*bulkFile) == "" {
+			return errors.New("bulk requires --file")
+		}
+		return runKeysBulk(*bulkFile, cfg.Proxy.AdminSocket, *adminURL, cfg.Proxy.AdminAPIKey)
 	default:
 		return fmt.Errorf("unknown proxy keys command: %s", cmd)
 	}
 	return nil
 }
 
+// addSignedKey issues and prints a key from KeyStore.GenerateSigned.
+// Signed keys carry their limits, so a provided key or per-model quotas
+// (unsupported) is an error.
+func addSignedKey(store *proxy.KeyStore, secret, label, rate string, burst int, quota int64, ttl time.Duration, models []string, unsupported bool) error {
+	if secret == "" {
+		return errors.New("--signed requires proxy.hmac_secret or GODEX_PROXY_HMAC_SECRET")
+	}
+	if unsupported {
+		return errors.New("--signed keys do not support --key or --model-quota")
+	}
+	claims := map[string]any{"rate": rate, "burst": burst, "quota_tokens": quota}
+	if len(models) > 0 {
+		claims["allowed_models"] = models
+	}
+	if ttl > 0 {
+		claims["exp"] = time.Now().Add(ttl).Unix()
+	}
+	token, err := store.GenerateSigned(label, claims, []byte(secret))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("label=%s key=%s\n", label, token)
+	return nil
+}
+
 func runProxyUsage(args []string) error {
 	if len(args) == 0 {
 		return errors.New("proxy usage requires a subcommand")
@@ -1238,8 +1887,11 @@ func runProxyUsage(args []string) error {
 
 	fs := flag.NewFlagSet("proxy usage", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
-	cfg := config.LoadFrom(configPathFromArgs(args))
-	configPath := fs.String("config", config.DefaultPath(), "Config file path")
+	cfg, err := loadConfig(configPathFromArgs(args))
+	if err != nil {
+		return err
+	}
+	configPath := configFlag(fs, args)
 	statsPath := fs.String("stats-path", defaultString(cfg.Proxy.StatsPath, ""), "Usage JSONL path")
 	sinceStr := fs.String("since", "", "Lookback duration (e.g. 24h)")
 	keyID := fs.String("key", "", "Key id filter")
@@ -1329,7 +1981,21 @@ func expandHome(path string) string {
 	return path
 }
 
+// loadConfig loads the config file at path, printing any warnings to
+// stderr.
+func loadConfig(path string) (config.Config, error) {
+	cfg, warnings, err := config.LoadFrom(path)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: config: %s\n", w)
+	}
+	return cfg, err
+}
+
+// configPathFromArgs returns the config file selected by --config or,
+// failing that, by --profile (see config.ResolvePath). It scans args
+// before flag parsing so the config can supply flag defaults.
 func configPathFromArgs(args []string) string {
+	profile := ""
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
 		if strings.HasPrefix(arg, "--config=") {
@@ -1338,8 +2004,21 @@ func configPathFromArgs(args []string) string {
 		if arg == "--config" && i+1 < len(args) {
 			return args[i+1]
 		}
+		if strings.HasPrefix(arg, "--profile=") {
+			profile = strings.TrimPrefix(arg, "--profile=")
+		}
+		if arg == "--profile" && i+1 < len(args) {
+			profile = args[i+1]
+		}
 	}
-	return config.DefaultPath()
+	return config.ResolvePath(profile)
+}
+
+// configFlag defines --config and --profile on fs and returns the config
+// path, which defaults to the file args select.
+func configFlag(fs *flag.FlagSet, args []string) *string {
+	fs.String("profile", "", "Config profile under ~/.config/godex/profiles (default $GODEX_PROFILE)")
+	return fs.String("config", configPathFromArgs(args), "Config file path")
 }
 
 func runProbe(args []string) error {
@@ -1349,17 +2028,19 @@ func runProbe(args []string) error {
 	var url string
 	var apiKey string
 	var jsonOutput bool
+	var explain bool
 
 	fs.StringVar(&url, "url", "http://127.0.0.1:39001", "proxy URL")
 	fs.StringVar(&apiKey, "key", "", "API key (or set GODEX_API_KEY)")
 	fs.BoolVar(&jsonOutput, "json", false, "output as JSON")
+	fs.BoolVar(&explain, "explain", false, "explain the routing decision")
 
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
 	if fs.NArg() < 1 {
-		return fmt.Errorf("usage: godex probe <model> [--url URL] [--key KEY] [--json]")
+		return fmt.Errorf("usage: godex probe <model> [--url URL] [--key KEY] [--json] [--explain]")
 	}
 	model := fs.Arg(0)
 
@@ -1373,6 +2054,9 @@ func runProbe(args []string) error {
 
 	// Build request URL
 	reqURL := strings.TrimRight(url, "/") + "/v1/models/" + model
+	if explain {
+		reqURL += "?explain=true"
+	}
 
 	// Make request
 	req, err := http.NewRequest("GET", reqURL, nil)
@@ -1412,6 +2096,7 @@ func runProbe(args []string) error {
 		DisplayName string `json:"display_name,omitempty"`
 		Backend     string `json:"backend,omitempty"`
 		Alias       string `json:"alias,omitempty"`
+		router.RouteExplanation
 	}
 	if err := json.Unmarshal(body, &result); err != nil {
 		return fmt.Errorf("parse response: %w", err)
@@ -1433,6 +2118,16 @@ func runProbe(args []string) error {
 			fmt.Printf(" [%s]", result.DisplayName)
 		}
 		fmt.Println()
+		if explain && result.Reason != "" {
+			fmt.Printf("  reason: %s\n", result.Reason)
+			for _, c := range result.AllCandidates {
+				if c.Pattern != "" {
+					fmt.Printf("  candidate: %s (pattern %q)\n", c.Backend, c.Pattern)
+				} else {
+					fmt.Printf("  candidate: %s (harness match)\n", c.Backend)
+				}
+			}
+		}
 	}
 
 	return nil
@@ -1440,16 +2135,20 @@ func runProbe(args []string) error {
 
 func runAuth(args []string) error {
 	if len(args) == 0 {
-		return runAuthStatus()
+		return runAuthStatus(nil)
 	}
 
 	switch args[0] {
 	case "status":
-		return runAuthStatus()
+		return runAuthStatus(args[1:])
 	case "setup":
-		return runAuthSetup()
+		return runAuthSetup(args[1:])
+	case "add-profile":
+		return runAuthAddProfile(args[1:])
+	case "service-account":
+		return runAuthServiceAccount(args[1:])
 	default:
-		return fmt.Errorf("unknown auth command: %s (use 'status' or 'setup')", args[0])
+		return fmt.Errorf("unknown auth command: %s (use 'status', 'setup', 'add-profile' or 'service-account')", args[0])
 	}
 }
 
@@ -1460,24 +2159,103 @@ type AuthStatus struct {
 	Path       string
 	ExpiresAt  time.Time
 	Error      string
+	// Live is the result of a --check request; empty when not checked.
+	Live string
+	// Profiles are the named accounts in a Codex auth file.
+	Profiles []AuthProfile
+}
+
+// AuthProfile is a named account in the Codex auth file.
+type AuthProfile struct {
+	Name      string
+	ExpiresAt time.Time
 }
 
-func runAuthStatus() error {
+func runAuthStatus(args []string) error {
+	fs := flag.NewFlagSet("auth status", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	configPath := configFlag(fs, args)
+	check := fs.Bool("check", false, "Make a request with each configured credential to see if it works")
+	timeout := fs.Duration("timeout", auth.DefaultHealthTimeout, "With --check, timeout per backend")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+
 	fmt.Println("godex authentication status")
 	fmt.Println("===========================")
 	fmt.Println()
 
 	// Check Codex
 	codexStatus := checkCodexAuth()
+	if *check && codexStatus.Configured {
+		codexStatus.Live = checkCodexHealth(codexStatus.Path, auth.HealthCheckConfig{
+			BaseURL:        cfg.Client.BaseURL,
+			AccountProfile: cfg.Client.AccountProfile,
+			Timeout:        *timeout,
+		})
+	}
 	printAuthStatus("Codex", codexStatus)
 
 	// Check Anthropic
 	anthropicStatus := checkAnthropicAuth()
+	if *check && anthropicStatus.Configured {
+		anthropicStatus.Live = checkAnthropicHealth(anthropicStatus.Path, *timeout)
+	}
 	printAuthStatus("Anthropic", anthropicStatus)
 
 	return nil
 }
 
+// checkCodexHealth checks the credentials in the auth file at path against
+// the Codex backend and account that cfg selects.
+func checkCodexHealth(path string, cfg auth.HealthCheckConfig) string {
+	store, err := auth.Load(path)
+	if err != nil {
+		return describeAuthHealth(auth.AuthHealth{}, err)
+	}
+	return describeAuthHealth(auth.CheckHealth(context.Background(), store, cfg))
+}
+
+func checkAnthropicHealth(path string, timeout time.Duration) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return describeAuthHealth(auth.AuthHealth{}, err)
+	}
+	var creds struct {
+		ClaudeAiOauth struct {
+			AccessToken string `json:"accessToken"`
+			ExpiresAt   int64  `json:"expiresAt"`
+		} `json:"claudeAiOauth"`
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return describeAuthHealth(auth.AuthHealth{}, err)
+	}
+	cfg := auth.HealthCheckConfig{Backend: auth.BackendAnthropic, Token: creds.ClaudeAiOauth.AccessToken, Timeout: timeout}
+	if creds.ClaudeAiOauth.ExpiresAt > 0 {
+		cfg.ExpiresAt = time.UnixMilli(creds.ClaudeAiOauth.ExpiresAt)
+	}
+	return describeAuthHealth(auth.CheckHealth(context.Background(), nil, cfg))
+}
+
+// describeAuthHealth summarizes a health check as live, expired, invalid
+// or the reason it could not be made.
+func describeAuthHealth(h auth.AuthHealth, err error) string {
+	switch {
+	case err != nil:
+		return "⚠️  check failed: " + err.Error()
+	case h.Valid:
+		return "✅ live"
+	case h.Expired():
+		return "❌ expired: " + h.Error
+	default:
+		return "❌ invalid: " + h.Error
+	}
+}
+
 func printAuthStatus(name string, status AuthStatus) {
 	if status.Configured {
 		fmt.Printf("%-12s ✅ configured\n", name+":")
@@ -1489,6 +2267,19 @@ func printAuthStatus(name string, status AuthStatus) {
 				fmt.Printf("             ⚠️  Expired: %s\n", status.ExpiresAt.Format("2006-01-02 15:04"))
 			}
 		}
+		if status.Live != "" {
+			fmt.Printf("             Live: %s\n", status.Live)
+		}
+		for _, p := range status.Profiles {
+			switch {
+			case p.ExpiresAt.IsZero():
+				fmt.Printf("             Profile %s\n", p.Name)
+			case p.ExpiresAt.After(time.Now()):
+				fmt.Printf("             Profile %s: expires %s\n", p.Name, p.ExpiresAt.Format("2006-01-02 15:04"))
+			default:
+				fmt.Printf("             Profile %s: ⚠️  expired %s\n", p.Name, p.ExpiresAt.Format("2006-01-02 15:04"))
+			}
+		}
 	} else {
 		fmt.Printf("%-12s ❌ not configured\n", name+":")
 		if status.Path != "" {
@@ -1521,27 +2312,33 @@ func checkCodexAuth() AuthStatus {
 	}
 
 	// Codex auth.json structure: { auth_mode, tokens: { access_token, ... } }
-	var auth struct {
+	var creds struct {
 		AuthMode string `json:"auth_mode"`
 		APIKey   string `json:"OPENAI_API_KEY"`
 		Tokens   struct {
 			AccessToken string `json:"access_token"`
 		} `json:"tokens"`
 	}
-	if err := json.Unmarshal(data, &auth); err != nil {
+	if err := json.Unmarshal(data, &creds); err != nil {
 		status.Error = "invalid JSON: " + err.Error()
 		return status
 	}
 
 	// Check for API key mode
-	if auth.AuthMode == "api_key" && auth.APIKey != "" {
+	if creds.AuthMode == "api_key" && creds.APIKey != "" {
 		status.Configured = true
 		return status
 	}
 
 	// Check for OAuth/ChatGPT mode
-	if auth.Tokens.AccessToken != "" {
+	if creds.Tokens.AccessToken != "" {
 		status.Configured = true
+		if store, err := auth.Load(path); err == nil {
+			status.ExpiresAt = store.ExpiresAt()
+			for _, name := range store.Profiles() {
+				status.Profiles = append(status.Profiles, AuthProfile{Name: name, ExpiresAt: store.ProfileExpiresAt(name)})
+			}
+		}
 		return status
 	}
 
@@ -1592,7 +2389,106 @@ func checkAnthropicAuth() AuthStatus {
 	return status
 }
 
-func runAuthSetup() error {
+// runAuthAddProfile copies the credentials in another Codex auth file into
+// godex's auth file as a named account, which account_profile can select.
+func runAuthAddProfile(args []string) error {
+	fs := flag.NewFlagSet("auth add-profile", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	configPath := configFlag(fs, args)
+	name := fs.String("name", "", "Profile name")
+	source := fs.String("auth-path", "", "Codex auth.json holding the account's credentials")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*name) == "" || strings.TrimSpace(*source) == "" {
+		return fmt.Errorf("usage: godex auth add-profile --name <name> --auth-path <auth.json>")
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	target := cfg.Auth.Path
+	if target == "" {
+		if target, err = auth.DefaultPath(); err != nil {
+			return err
+		}
+	}
+	src, err := auth.Load(expandHome(*source))
+	if err != nil {
+		return err
+	}
+	store, err := auth.Load(target)
+	if err != nil {
+		return err
+	}
+	if err := store.AddProfile(*name, src.File); err != nil {
+		return err
+	}
+	fmt.Printf("Added profile %q to %s\n", strings.TrimSpace(*name), target)
+	return nil
+}
+
+// runAuthServiceAccount issues service account credentials for CI and
+// other automation, signed with proxy.service_account_secret, and revokes
+// them. Issued IDs are recorded in the proxy key store, which the proxy
+// checks for revocations.
+func runAuthServiceAccount(args []string) error {
+	const usage = "usage: godex auth service-account add --label <label> [--scope read|chat] | revoke <id>"
+	if len(args) == 0 || (args[0] != "add" && args[0] != "revoke") {
+		return errors.New(usage)
+	}
+	fs := flag.NewFlagSet("auth service-account "+args[0], flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	configPath := configFlag(fs, args)
+	keysPath := fs.String("keys-path", "", "API keys file (default proxy.keys_path)")
+	label := fs.String("label", "", "Label for the service account")
+	var scopes scopeFlags
+	fs.Var(&scopes, "scope", "Scope granted to the service account: read or chat (repeatable or comma-separated; none = all)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	store, err := proxy.LoadKeyStore(defaultString(*keysPath, defaultString(cfg.Proxy.KeysPath, proxy.DefaultKeysPath())))
+	if err != nil {
+		return err
+	}
+	if args[0] == "revoke" {
+		if fs.NArg() != 1 || !strings.HasPrefix(fs.Arg(0), "sa_") {
+			return errors.New(usage)
+		}
+		rec, ok := store.Revoke(fs.Arg(0))
+		if !ok {
+			return fmt.Errorf("service account %s not found", fs.Arg(0))
+		}
+		fmt.Printf("revoked %s (%s)\n", rec.ID, rec.Label)
+		return nil
+	}
+	if cfg.Proxy.ServiceAccountSecret == "" {
+		return errors.New("service accounts require proxy.service_account_secret or GODEX_PROXY_SERVICE_ACCOUNT_SECRET")
+	}
+	cred, err := auth.IssueServiceAccount(*label, scopes, []byte(cfg.Proxy.ServiceAccountSecret))
+	if err != nil {
+		return err
+	}
+	if err := store.AddServiceAccount(cred); err != nil {
+		return fmt.Errorf("record service account: %w", err)
+	}
+	fmt.Printf("id=%s label=%s scopes=%s key=%s\n", cred.ID, cred.Label, strings.Join(cred.Scopes, ","), cred.Secret)
+	return nil
+}
+
+func runAuthSetup(args []string) error {
+	fs := flag.NewFlagSet("auth setup", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	configPath := configFlag(fs, args)
+	interactive := fs.Bool("interactive", false, "Sign in to Codex in the browser instead of via the Codex CLI")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
 	fmt.Println("godex authentication setup")
 	fmt.Println("==========================")
 	fmt.Println()
@@ -1606,12 +2502,42 @@ func runAuthSetup() error {
 	if allConfigured {
 		fmt.Println("✅ All backends are already configured!")
 		fmt.Println()
-		runAuthStatus()
+		runAuthStatus(nil)
 		return nil
 	}
 
 	// Setup missing backends
-	if !codexStatus.Configured {
+	if !codexStatus.Configured && *interactive {
+		fmt.Println("Setting up Codex authentication...")
+		fmt.Println("──────────────────────────────────")
+		fmt.Println()
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			return err
+		}
+		store, err := auth.StartPKCEFlow(auth.PKCEConfig{
+			Path:       cfg.Auth.Path,
+			RefreshURL: cfg.Auth.RefreshURL,
+			ClientID:   cfg.Auth.ClientID,
+			Scope:      cfg.Auth.Scope,
+			OpenBrowser: func(target string) error {
+				fmt.Println("Opening your browser to sign in. If it does not open, visit:")
+				fmt.Println()
+				fmt.Println("  " + target)
+				fmt.Println()
+				if err := auth.OpenBrowser(target); err != nil {
+					fmt.Printf("⚠️  could not open a browser: %v\n", err)
+				}
+				return nil
+			},
+		})
+		if err != nil {
+			fmt.Printf("⚠️  sign-in failed: %v\n", err)
+		} else {
+			fmt.Printf("✅ Codex authentication complete! Saved to %s\n", store.Path())
+		}
+		fmt.Println()
+	} else if !codexStatus.Configured {
 		fmt.Println("Setting up Codex authentication...")
 		fmt.Println("──────────────────────────────────")
 		fmt.Println()
@@ -1687,7 +2613,7 @@ func runAuthSetup() error {
 	fmt.Println("─────────────────────────────────")
 	fmt.Println("Final status:")
 	fmt.Println()
-	return runAuthStatus()
+	return runAuthStatus(nil)
 }
 
 func promptYesNo(prompt string) bool {
@@ -1711,7 +2637,7 @@ func resolveClient(model string, store *auth.Store, cfg config.Config, allowRefr
 	// Direct Anthropic/Gemini exec would need the harness path, but that's a future enhancement.
 	baseURL := cfg.Client.BaseURL
 	if baseURL == "" {
-		baseURL = "https://chatgpt.com/backend-api/codex"
+		baseURL = auth.DefaultCodexBaseURL
 	}
 	c := harnessCodexP.NewClient(nil, store, harnessCodexP.ClientConfig{
 		SessionID:         sessionID,
@@ -1721,7 +2647,9 @@ func resolveClient(model string, store *auth.Store, cfg config.Config, allowRefr
 		UserAgent:         cfg.Client.UserAgent,
 		RetryMax:          cfg.Client.RetryMax,
 		RetryDelay:        cfg.Client.RetryDelay,
+		MaxRetryDelay:     cfg.Client.MaxRetryDelay,
 		UpstreamAuditPath: cfg.Proxy.UpstreamAuditPath,
+		AccountProfile:    cfg.Client.AccountProfile,
 	})
 	return c, nil
 }
@@ -1744,11 +2672,14 @@ func runAliases(args []string) error {
 func runAliasesList(args []string) error {
 	fs := flag.NewFlagSet("aliases list", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
-	configPath := fs.String("config", config.DefaultPath(), "Config file path")
+	configPath := configFlag(fs, args)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
-	cfg := config.LoadFrom(*configPath)
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
 
 	if len(cfg.Proxy.Backends.Routing.Aliases) == 0 {
 		fmt.Println("No aliases configured.")
@@ -1771,12 +2702,15 @@ func runAliasesList(args []string) error {
 func runAliasesUpdate(args []string) error {
 	fs := flag.NewFlagSet("aliases update", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
-	configPath := fs.String("config", config.DefaultPath(), "Config file path")
+	configPath := configFlag(fs, args)
 	dryRun := fs.Bool("dry-run", false, "Show what would change without writing")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
-	cfg := config.LoadFrom(*configPath)
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -1804,6 +2738,9 @@ func runAliasesUpdate(args []string) error {
 		if err := anthTokens.Load(); err == nil {
 			wrapper := harnessClaudeP.NewClientWrapper(anthTokens, harnessClaudeP.ClientConfig{
 				DefaultMaxTokens: cfg.Proxy.Backends.Anthropic.DefaultMaxTokens,
+				BetaFeatures:     cfg.Proxy.Backends.Anthropic.BetaFeatures,
+				MaxRetries:       cfg.Proxy.Backends.Anthropic.MaxRetries,
+				MaxRetryDelay:    cfg.Proxy.Backends.Anthropic.MaxRetryDelay,
 			})
 			backends["anthropic"] = &aliasModelLister{listFn: func(ctx context.Context) ([]aliases.ModelInfo, error) {
 				models, err := wrapper.ListModels(ctx)
@@ -1900,14 +2837,16 @@ func runAliasesUpdate(args []string) error {
 }
 
 func usage() {
-	fmt.Fprintln(os.Stderr, "usage: godex exec --config <path> --prompt \"...\" [--model gpt-5.2-codex] [--tool web_search] [--tool name:json=schema.json] [--web-search] [--tool-choice auto|required|function:<name>] [--input-json path] [--mock --mock-mode echo|text|tool-call|tool-loop] [--auto-tools --tool-output name=value] [--trace] [--json] [--log-requests path] [--log-responses path]")
-	fmt.Fprintln(os.Stderr, "       godex proxy --config <path> --api-key <key> [--listen 127.0.0.1:39001] [--model gpt-5.2-codex] [--base-url https://chatgpt.com/backend-api/codex] [--allow-any-key] [--auth-path ~/.codex/auth.json] [--log-requests]")
-	fmt.Fprintln(os.Stderr, "       godex proxy keys --config <path> add --label <label> [--rate 60/m] [--burst 10] [--quota-tokens N]")
-	fmt.Fprintln(os.Stderr, "       godex proxy keys list | update <id> | revoke <id|key> | rotate <id|key>")
+	fmt.Fprintln(os.Stderr, "usage: godex exec --config <path> --prompt \"...\" [--model gpt-5.2-codex] [--tool web_search] [--tool name:json=schema.json] [--web-search] [--tool-choice auto|required|function:<name>] [--input-json path] [--template path [--var KEY=VALUE] [--vars-file vars.json] [--template-delims \"<< >>\"]] [--mock --mock-mode echo|text|tool-call|tool-loop] [--auto-tools --tool-output name=value [--parallel-tools N]] [--count-tokens] [--dry-run [--url URL --key KEY]] [--stop-sequence seq] [--session-file path] [--repl] [--prompts-file path --output-dir dir [--concurrency N]] [--export-history path [--export-sanitized]] [--diff-mode] [--color auto|always|never] [--trace] [--json] [--log-requests path] [--log-responses path]")
+	fmt.Fprintln(os.Stderr, "       godex proxy --config <path> --api-key <key> [--listen 127.0.0.1:39001] [--cert cert.pem --key key.pem] [--model gpt-5.2-codex] [--base-url https://chatgpt.com/backend-api/codex] [--allow-any-key] [--auth-path ~/.codex/auth.json] [--log-requests]")
+	fmt.Fprintln(os.Stderr, "       godex proxy keys --config <path> add --label <label> [--rate 60/m] [--burst 10] [--quota-tokens N] [--allowed-models a,b] [--model-quota model=N]")
+	fmt.Fprintln(os.Stderr, "       godex proxy keys list | update <id> | revoke <id|key> | rotate <id|key> | bulk --file <ops.json> [--admin-url <url>]")
 	fmt.Fprintln(os.Stderr, "       godex proxy usage --config <path> list [--since 24h] [--key <id>] | show <id>")
 	fmt.Fprintln(os.Stderr, "       godex proxy replay [--request-id <id>|latest] [--list N] [--trace-path path] [--audit-path path] [--url http://127.0.0.1:39001] [--api-key key]")
 	fmt.Fprintln(os.Stderr, "       godex proxy attach [--service godex-proxy.service] [--no-journal] [--no-trace] [--no-upstream-audit] [--trace-path path] [--upstream-audit-path path]")
-	fmt.Fprintln(os.Stderr, "       godex probe <model> [--url http://127.0.0.1:39001] [--key <api-key>] [--json]")
-	fmt.Fprintln(os.Stderr, "       godex auth status | setup")
+	fmt.Fprintln(os.Stderr, "       godex probe <model> [--url http://127.0.0.1:39001] [--key <api-key>] [--json] [--explain]")
+	fmt.Fprintln(os.Stderr, "       godex auth status [--check] | setup [--interactive] | add-profile --name <name> --auth-path <file> | service-account add --label <label> [--scope read|chat] | service-account revoke <id>")
 	fmt.Fprintln(os.Stderr, "       godex aliases list | update [--dry-run]")
+	fmt.Fprintln(os.Stderr, "       godex config init | schema | show | validate [--config path.yaml | --profile name]")
+	fmt.Fprintln(os.Stderr, "       godex completion bash|zsh|fish|powershell")
 }
@@ -137,3 +137,27 @@ func TestRunProbeNotFound(t *testing.T) {
 	// For now, just document the behavior
 	t.Log("Note: probe not found case calls os.Exit(1)")
 }
+
+func TestRunProbeExplain(t *testing.T) {
+	var gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotQuery = r.URL.RawQuery
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":              "claude-sonnet-4-5-20250929",
+			"backend":         "anthropic",
+			"alias":           "sonnet",
+			"matched_backend": "anthropic",
+			"matched_pattern": "claude-",
+			"candidates":      []map[string]string{{"backend": "anthropic", "pattern": "claude-"}},
+			"reason":          `user pattern "claude-" routes to anthropic`,
+		})
+	}))
+	defer server.Close()
+
+	if err := runProbe([]string{"--url", server.URL, "--key", "test-key", "--explain", "sonnet"}); err != nil {
+		t.Fatalf("runProbe --explain: %v", err)
+	}
+	if gotQuery != "explain=true" {
+		t.Fatalf("expected explain=true query, got %q", gotQuery)
+	}
+}
@@ -24,9 +24,12 @@ func runProxyAttach(args []string) error {
 	fs := flag.NewFlagSet("proxy attach", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 
-	cfg := config.LoadFrom(configPathFromArgs(args))
+	cfg, err := loadConfig(configPathFromArgs(args))
+	if err != nil {
+		return err
+	}
 
-	configPath := fs.String("config", config.DefaultPath(), "Config file path")
+	configPath := configFlag(fs, args)
 	service := fs.String("service", "godex-proxy.service", "systemd user service name")
 	journal := fs.Bool("journal", true, "Attach to systemd journal stream")
 	trace := fs.Bool("trace", true, "Attach to proxy trace JSONL stream")
@@ -11,8 +11,6 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
-
-	"godex/pkg/config"
 )
 
 type replayRecord struct {
@@ -27,8 +25,11 @@ func runProxyReplay(args []string) error {
 	fs := flag.NewFlagSet("proxy replay", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 
-	cfg := config.LoadFrom(configPathFromArgs(args))
-	configPath := fs.String("config", config.DefaultPath(), "Config file path")
+	cfg, err := loadConfig(configPathFromArgs(args))
+	if err != nil {
+		return err
+	}
+	configPath := configFlag(fs, args)
 	requestID := fs.String("request-id", "latest", "Request ID to replay (or latest)")
 	listCount := fs.Int("list", 0, "List recent replayable request IDs instead of replaying")
 	tracePath := fs.String("trace-path", defaultReplayTracePath(cfg.Proxy.TracePath), "Trace JSONL path")
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"gopkg.in/yaml.v3"
+
+	"godex/pkg/admin"
+	"godex/pkg/config"
+	"godex/pkg/proxy"
+)
+
+// reloadableFields are the config file settings Server.Reload applies;
+// changes to any other setting need a restart.
+var reloadableFields = map[string]bool{
+	"proxy.log_level":        true,
+	"proxy.default_rate":     true,
+	"proxy.default_burst":    true,
+	"proxy.meter_window":     true,
+	"proxy.cache_ttl":        true,
+	"proxy.response_headers": true,
+}
+
+// configReloader tracks the config file and the proxy config built from it
+// across reloads, whether they come from the watcher or the admin API.
+type configReloader struct {
+	path     string
+	mu       sync.Mutex
+	prev     config.Config
+	proxyCfg proxy.Config
+}
+
+func newConfigReloader(path string, started config.Config, proxyCfg proxy.Config) *configReloader {
+	return &configReloader{path: path, prev: started, proxyCfg: proxyCfg}
+}
+
+// apply records next as the loaded config file and returns the proxy
+// config to reload with and the settings that changed.
+func (r *configReloader) apply(next config.Config) (proxy.Config, []config.Change) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	changes := config.Diff(r.prev, next)
+	r.proxyCfg = reloadedProxyConfig(r.proxyCfg, r.prev, next)
+	r.prev = next
+	return r.proxyCfg, changes
+}
+
+// sanitized returns the loaded config with its secrets masked, for
+// GET /admin/config. It is decoded from the config's YAML, so keys and
+// durations read as they do in the file.
+func (r *configReloader) sanitized() any {
+	r.mu.Lock()
+	cfg := r.prev
+	r.mu.Unlock()
+	out := map[string]any{}
+	if buf, err := yaml.Marshal(config.Sanitize(cfg)); err == nil {
+		_ = yaml.Unmarshal(buf, &out)
+	}
+	return out
+}
+
+// reload re-reads the config file for POST /admin/reload. A file that
+// fails to load keeps the running config.
+func (r *configReloader) reload() (proxy.Config, admin.ReloadResult, error) {
+	cfg, warnings, err := config.LoadFile(r.path)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "warning: config: %s\n", w)
+	}
+	if err != nil {
+		return proxy.Config{}, admin.ReloadResult{}, fmt.Errorf("reload %s: %w", r.path, err)
+	}
+	proxyCfg, changes := r.apply(cfg)
+	result := admin.ReloadResult{Changes: make([]admin.ConfigChange, 0, len(changes))}
+	for _, c := range changes {
+		restart := !reloadableFields[c.Field]
+		result.Changes = append(result.Changes, admin.ConfigChange{Field: c.Field, Old: c.Old, New: c.New, RestartRequired: restart})
+		result.RestartRequired = result.RestartRequired || restart
+	}
+	return proxyCfg, result, nil
+}
+
+// watchConfig sends proxy configs on reloads when the config file changes
+// or the process receives SIGHUP. The returned function stops watching.
+func watchConfig(r *configReloader, reloads chan<- proxy.Config) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := config.NewWatcher(r.path, 0, func(cfg config.Config) {
+		proxyCfg, _ := r.apply(cfg)
+		select {
+		case reloads <- proxyCfg:
+		case <-ctx.Done():
+		}
+	})
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				w.Trigger()
+			}
+		}
+	}()
+	go w.Run(ctx)
+
+	return func() {
+		signal.Stop(hup)
+		cancel()
+	}
+}
+
+// reloadedProxyConfig returns proxyCfg with the reloadable settings that
+// changed between the prev and next config files applied. Settings the file
+// leaves unchanged keep their current value, so flag overrides survive a
+// reload until the file changes the same setting. Listen and TLS changes
+// are passed on for Server.Reload to warn about. default_quota_tokens is
+// not reloadable: it is reported as needing a restart.
+func reloadedProxyConfig(proxyCfg proxy.Config, prev, next config.Config) proxy.Config {
+	p, n := prev.Proxy, next.Proxy
+	applyChanged(&proxyCfg.Listen, p.Listen, n.Listen)
+	applyChanged(&proxyCfg.TLSCertFile, p.TLSCertFile, n.TLSCertFile)
+	applyChanged(&proxyCfg.TLSKeyFile, p.TLSKeyFile, n.TLSKeyFile)
+	applyChanged(&proxyCfg.LogLevel, p.LogLevel, n.LogLevel)
+	applyChanged(&proxyCfg.RateLimit, p.DefaultRate, n.DefaultRate)
+	applyChanged(&proxyCfg.Burst, p.DefaultBurst, n.DefaultBurst)
+	applyChanged(&proxyCfg.MeterWindow, p.MeterWindow, n.MeterWindow)
+	applyChanged(&proxyCfg.CacheTTL, p.CacheTTL, n.CacheTTL)
+	if !maps.Equal(p.ResponseHeaders, n.ResponseHeaders) {
+		proxyCfg.ResponseHeaders = n.ResponseHeaders
+	}
+	return proxyCfg
+}
+
+func applyChanged[T comparable](dst *T, prev, next T) {
+	if prev != next {
+		*dst = next
+	}
+}
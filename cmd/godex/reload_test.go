@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"godex/pkg/admin"
+	"godex/pkg/config"
+	"godex/pkg/proxy"
+)
+
+func TestReloadedProxyConfigKeepsFlagOverrides(t *testing.T) {
+	prev := config.DefaultConfig()
+	next := prev
+	next.Proxy.LogLevel = "debug"
+
+	// --rate was given on the command line; the file did not change it.
+	cur := proxy.Config{RateLimit: "5/m", LogLevel: "info"}
+	got := reloadedProxyConfig(cur, prev, next)
+	if got.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q, want debug", got.LogLevel)
+	}
+	if got.RateLimit != "5/m" {
+		t.Errorf("RateLimit = %q, want the flag value 5/m", got.RateLimit)
+	}
+}
+
+func TestConfigReloaderAdminReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("proxy:\n  log_level: info\n  listen: 127.0.0.1:39001\n")
+	started, _, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newConfigReloader(path, started, proxy.Config{LogLevel: "info", Listen: "127.0.0.1:39001"})
+
+	var applied proxy.Config
+	srv := admin.New("", nil)
+	srv.EnableReload(admin.ReloadFunc(func() (admin.ReloadResult, error) {
+		next, result, err := r.reload()
+		applied = next
+		return result, err
+	}))
+	ts := httptest.NewServer(srv.HTTPHandler("admin-key"))
+	defer ts.Close()
+	post := func() admin.ReloadResult {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/admin/reload", nil)
+		req.Header.Set("Authorization", "Bearer admin-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d, want 200", resp.StatusCode)
+		}
+		var result admin.ReloadResult
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if got := post(); len(got.Changes) != 0 || got.RestartRequired {
+		t.Fatalf("unchanged file: %+v, want no changes", got)
+	}
+
+	write("proxy:\n  log_level: debug\n  listen: 127.0.0.1:39101\n")
+	got := post()
+	want := []admin.ConfigChange{
+		{Field: "proxy.listen", Old: "127.0.0.1:39001", New: "127.0.0.1:39101", RestartRequired: true},
+		{Field: "proxy.log_level", Old: "info", New: "debug"},
+	}
+	if len(got.Changes) != len(want) || !got.RestartRequired {
+		t.Fatalf("result = %+v, want %+v with a restart", got, want)
+	}
+	for i, w := range want {
+		if got.Changes[i] != w {
+			t.Errorf("change %d = %+v, want %+v", i, got.Changes[i], w)
+		}
+	}
+	if applied.LogLevel != "debug" || applied.Listen != "127.0.0.1:39101" {
+		t.Errorf("proxy config log_level=%q listen=%q", applied.LogLevel, applied.Listen)
+	}
+
+	if got := post(); len(got.Changes) != 0 {
+		t.Errorf("second reload: %+v, want no changes", got.Changes)
+	}
+}
+
+func TestConfigReloaderSanitized(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("proxy:\n  admin_api_key: hunter2\n  listen: 127.0.0.1:39001\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _, err := config.LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newConfigReloader(path, cfg, proxy.Config{})
+	out, err := json.Marshal(r.sanitized())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "hunter2") || !strings.Contains(string(out), `"listen":"127.0.0.1:39001"`) {
+		t.Errorf("sanitized = %s", out)
+	}
+}
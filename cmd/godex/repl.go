@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"godex/pkg/harness"
+)
+
+// replTurnFunc runs one turn of exec --repl on the history in turn and
+// returns the events it produced.
+type replTurnFunc func(ctx context.Context, turn *harness.Turn) ([]harness.Event, error)
+
+// runREPL runs exec --repl: each line read from in is sent as the next user
+// message, with the replies appended to turn.Messages. Messages already in
+// turn that end with a user message, as from --prompt, are sent first.
+// Lines starting with "/" are meta-commands: /clear resets the history and
+// /history prints it. The prompt, notices and turn errors go to errOut; a
+// failed turn is dropped from the history. save, when set, is called with
+// the history after each turn. End of input returns nil.
+func runREPL(ctx context.Context, in io.Reader, out, errOut io.Writer, turn *harness.Turn, run replTurnFunc, save func(*harness.Turn) error) error {
+	send := func() error {
+		sent := len(turn.Messages)
+		events, err := run(ctx, turn)
+		fmt.Fprintln(out)
+		if err != nil {
+			turn.Messages = turn.Messages[:sent-1]
+			fmt.Fprintf(errOut, "error: %v\n", err)
+			return nil
+		}
+		turn.Messages = append(turn.Messages, replyMessages(events)...)
+		if save != nil {
+			return save(turn)
+		}
+		return nil
+	}
+
+	if n := len(turn.Messages); n > 0 && turn.Messages[n-1].Role == "user" {
+		if err := send(); err != nil {
+			return err
+		}
+	}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for {
+		fmt.Fprint(errOut, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(errOut)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case line == "/clear":
+			turn.Messages = nil
+			fmt.Fprintln(errOut, "history cleared")
+			if save != nil {
+				if err := save(turn); err != nil {
+					return err
+				}
+			}
+			continue
+		case line == "/history":
+			printHistory(out, turn.Messages)
+			continue
+		case strings.HasPrefix(line, "/"):
+			fmt.Fprintf(errOut, "unknown command %s (use /clear or /history; Ctrl-D exits)\n", line)
+			continue
+		}
+		turn.Messages = append(turn.Messages, harness.Message{Role: "user", Content: line})
+		if err := send(); err != nil {
+			return err
+		}
+	}
+}
+
+// printHistory prints one line per message for /history.
+func printHistory(w io.Writer, msgs []harness.Message) {
+	if len(msgs) == 0 {
+		fmt.Fprintln(w, "(no messages)")
+		return
+	}
+	for _, m := range msgs {
+		role := m.Role
+		if m.Name != "" {
+			role += " " + m.Name
+		}
+		fmt.Fprintf(w, "%s: %s\n", role, m.Content)
+	}
+}
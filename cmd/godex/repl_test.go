@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"godex/pkg/harness"
+)
+
+func TestRunREPL(t *testing.T) {
+	var sent [][]harness.Message
+	run := func(ctx context.Context, turn *harness.Turn) ([]harness.Event, error) {
+		sent = append(sent, append([]harness.Message(nil), turn.Messages...))
+		last := turn.Messages[len(turn.Messages)-1].Content
+		if last == "fail" {
+			return nil, errors.New("backend down")
+		}
+		return []harness.Event{harness.NewTextEvent("re: " + last), harness.NewDoneEvent()}, nil
+	}
+	var saved [][]harness.Message
+	save := func(turn *harness.Turn) error {
+		saved = append(saved, append([]harness.Message(nil), turn.Messages...))
+		return nil
+	}
+
+	turn := &harness.Turn{Messages: []harness.Message{{Role: "user", Content: "first"}}}
+	in := strings.NewReader("second\n\nfail\n/history\n/bogus\n/clear\nthird\n")
+	var out, errOut bytes.Buffer
+	if err := runREPL(context.Background(), in, &out, &errOut, turn, run, save); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sent) != 4 {
+		t.Fatalf("sent %d turns, want 4 (first, second, fail, third)", len(sent))
+	}
+	wantSecond := []harness.Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "re: first"},
+		{Role: "user", Content: "second"},
+	}
+	if !reflect.DeepEqual(sent[1], wantSecond) {
+		t.Errorf("second turn history = %+v, want %+v", sent[1], wantSecond)
+	}
+	if got := turn.Messages; !reflect.DeepEqual(got, []harness.Message{
+		{Role: "user", Content: "third"},
+		{Role: "assistant", Content: "re: third"},
+	}) {
+		t.Errorf("history after /clear = %+v", got)
+	}
+	// Saved after first, second, /clear and third; not after the failed turn.
+	if len(saved) != 4 || len(saved[1]) != 4 || len(saved[2]) != 0 {
+		t.Errorf("saved %d times: %+v", len(saved), saved)
+	}
+	if !strings.Contains(out.String(), "user: second\nassistant: re: second\n") {
+		t.Errorf("/history output missing messages:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "user: fail") {
+		t.Errorf("failed turn kept in history:\n%s", out.String())
+	}
+	for _, want := range []string{"error: backend down", "unknown command /bogus", "history cleared"} {
+		if !strings.Contains(errOut.String(), want) {
+			t.Errorf("stderr missing %q:\n%s", want, errOut.String())
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+
+	"godex/pkg/harness"
+)
+
+// replyMessages converts the events of a finished exec run into the history
+// that continues the conversation in the next --session-file run: assistant
+// text, tool calls and tool results, in the order they happened.
+func replyMessages(events []harness.Event) []harness.Message {
+	var msgs []harness.Message
+	var text strings.Builder
+	flush := func() {
+		if text.Len() > 0 {
+			msgs = append(msgs, harness.Message{Role: "assistant", Content: text.String()})
+			text.Reset()
+		}
+	}
+	for _, ev := range events {
+		switch ev.Kind {
+		case harness.EventText:
+			if ev.Text != nil {
+				text.WriteString(ev.Text.Delta)
+			}
+		case harness.EventToolCall:
+			if ev.ToolCall != nil {
+				flush()
+				msgs = append(msgs, harness.Message{
+					Role:    "assistant",
+					Content: ev.ToolCall.Arguments,
+					Name:    ev.ToolCall.Name,
+					ToolID:  ev.ToolCall.CallID,
+				})
+			}
+		case harness.EventToolResult:
+			if ev.ToolResult != nil {
+				flush()
+				msgs = append(msgs, harness.Message{Role: "tool", Content: ev.ToolResult.Output, ToolID: ev.ToolResult.CallID})
+			}
+		}
+	}
+	flush()
+	return msgs
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"godex/pkg/harness"
+)
+
+func TestReplyMessages(t *testing.T) {
+	got := replyMessages([]harness.Event{
+		harness.NewTextEvent("Let me "),
+		harness.NewTextEvent("check."),
+		harness.NewToolCallEvent("call_1", "shell", `{"cmd":"ls"}`),
+		harness.NewUsageEvent(10, 5),
+		harness.NewToolResultEvent("call_1", "README.md", false),
+		harness.NewTextEvent("There is a README."),
+		harness.NewDoneEvent(),
+	})
+	want := []harness.Message{
+		{Role: "assistant", Content: "Let me check."},
+		{Role: "assistant", Content: `{"cmd":"ls"}`, Name: "shell", ToolID: "call_1"},
+		{Role: "tool", Content: "README.md", ToolID: "call_1"},
+		{Role: "assistant", Content: "There is a README."},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("replyMessages:\n got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// execStdin returns the piped input exec should use. Without a prompt,
+// piped stdin is the prompt; with one, stdin is only read when --stdin asks
+// for it, so a caller that leaves stdin open, such as a cron job or a
+// parent process, cannot make `godex exec --prompt ...` wait forever.
+func execStdin(f *os.File, prompt string, useStdin bool) (string, error) {
+	if strings.TrimSpace(prompt) != "" && !useStdin {
+		return "", nil
+	}
+	return readPipedInput(f)
+}
+
+// readPipedInput returns what is piped to exec on f, normally stdin, so
+// that `cat file.go | godex exec --stdin --prompt "explain this"` works. It
+// reads only a pipe or a regular file, returning "" for a terminal, a
+// device such as /dev/null, or a socket, which leaves interactive use alone.
+func readPipedInput(f *os.File) (string, error) {
+	if term.IsTerminal(int(f.Fd())) {
+		return "", nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return "", nil
+	}
+	if mode := info.Mode(); mode&os.ModeNamedPipe == 0 && !mode.IsRegular() {
+		return "", nil
+	}
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("read stdin: %w", err)
+	}
+	return strings.TrimSpace(string(buf)), nil
+}
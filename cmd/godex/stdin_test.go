@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReadPipedInput(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		_, _ = w.WriteString("package main\n\nfunc main() {}\n\n")
+		w.Close()
+	}()
+	got, err := readPipedInput(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "package main\n\nfunc main() {}" {
+		t.Errorf("readPipedInput = %q", got)
+	}
+}
+
+func TestExecStdin_PromptIgnoresOpenPipe(t *testing.T) {
+	// A parent that never closes stdin must not block exec --prompt.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	done := make(chan string, 1)
+	go func() {
+		got, _ := execStdin(r, "explain this", false)
+		done <- got
+	}()
+	select {
+	case got := <-done:
+		if got != "" {
+			t.Errorf("execStdin = %q, want nothing without --stdin", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("execStdin blocked on an open pipe")
+	}
+}
+
+func TestExecStdin_OptIn(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go func() {
+		_, _ = w.WriteString("code")
+		w.Close()
+	}()
+	got, err := execStdin(r, "explain this", true)
+	if err != nil || got != "code" {
+		t.Fatalf("execStdin = %q, %v; want the piped text with --stdin", got, err)
+	}
+}
+
+func TestReadPipedInput_SkipsDevices(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got, err := readPipedInput(f); err != nil || got != "" {
+		t.Fatalf("readPipedInput(%s) = %q, %v", os.DevNull, got, err)
+	}
+}
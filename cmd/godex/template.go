@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// templateLineRE finds the line number text/template puts in its errors,
+// as in "template: review.tmpl:3:12: ...".
+var templateLineRE = regexp.MustCompile(`^template: [^:]*:(\d+):`)
+
+// loadTemplateVars returns the variables of exec --template: those in the
+// JSON object in varsFile, if set, overridden by vars from --var.
+func loadTemplateVars(varsFile string, vars map[string]string) (map[string]any, error) {
+	data := map[string]any{}
+	if varsFile != "" {
+		buf, err := os.ReadFile(varsFile)
+		if err != nil {
+			return nil, fmt.Errorf("read vars file: %w", err)
+		}
+		if err := json.Unmarshal(buf, &data); err != nil {
+			return nil, fmt.Errorf("parse vars file %s: must be a JSON object: %w", varsFile, err)
+		}
+	}
+	for k, v := range vars {
+		data[k] = v
+	}
+	return data, nil
+}
+
+// templateFuncs are the functions templates can call. json quotes a value
+// for use inside a .json template.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		buf, err := json.Marshal(v)
+		return string(buf), err
+	},
+}
+
+// renderTemplate renders the text/template in path with data. delims holds
+// the left and right delimiters separated by a space. A variable the
+// template uses but data lacks is an error, and errors quote the template
+// line they refer to.
+func renderTemplate(path, delims string, data map[string]any) (string, error) {
+	pair := strings.Fields(delims)
+	if len(pair) != 2 {
+		return "", fmt.Errorf("--template-delims %q must be two delimiters separated by a space", delims)
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Delims(pair[0], pair[1]).Funcs(templateFuncs).Option("missingkey=error").Parse(string(src))
+	if err != nil {
+		return "", templateError(string(src), err)
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", templateError(string(src), err)
+	}
+	return out.String(), nil
+}
+
+// templateError adds the template line err refers to, when it names one.
+func templateError(src string, err error) error {
+	m := templateLineRE.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	n, _ := strconv.Atoi(m[1])
+	lines := strings.Split(src, "\n")
+	if n < 1 || n > len(lines) {
+		return err
+	}
+	return fmt.Errorf("%w\n%5d | %s", err, n, lines[n-1])
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	varsFile := write("vars.json", `{"who": "ann", "diff": "from file"}`)
+	data, err := loadTemplateVars(varsFile, templateVarFlags{"diff": "a \"quoted\" diff"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := renderTemplate(write("review.tmpl", "Review for {{.who}}:\n{{.diff}}"), "{{ }}", data)
+	if err != nil || got != "Review for ann:\na \"quoted\" diff" {
+		t.Errorf("render = %q, %v", got, err)
+	}
+	got, err = renderTemplate(write("input.json", `[{"text": <<json .diff>>}]`), "<< >>", data)
+	if err != nil || got != `[{"text": "a \"quoted\" diff"}]` {
+		t.Errorf("json render = %q, %v", got, err)
+	}
+	if items, err := parseInputJSON([]byte(got)); err != nil || len(items) != 1 {
+		t.Errorf("rendered json does not parse as input: %v", err)
+	}
+
+	_, err = renderTemplate(write("missing.tmpl", "first\nhi {{.whom}}\n"), "{{ }}", data)
+	if err == nil || !strings.Contains(err.Error(), `no entry for key "whom"`) || !strings.Contains(err.Error(), "2 | hi {{.whom}}") {
+		t.Errorf("missing variable error = %v", err)
+	}
+	_, err = renderTemplate(write("broken.tmpl", "a\nb\n{{if}}"), "{{ }}", data)
+	if err == nil || !strings.Contains(err.Error(), "broken.tmpl:3") || !strings.Contains(err.Error(), "3 | {{if}}") {
+		t.Errorf("parse error = %v", err)
+	}
+	if _, err := renderTemplate(write("x.tmpl", ""), "<<", data); err == nil {
+		t.Error("accepted a single delimiter")
+	}
+}
@@ -0,0 +1,42 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// debugPrefix is where the net/http/pprof endpoints are served.
+const debugPrefix = "/admin/debug/pprof/"
+
+// EnableDebug serves the net/http/pprof endpoints under
+// /admin/debug/pprof/, so that `go tool pprof` can fetch profiles by URL.
+// Call it before Start or StartHTTP.
+func (s *Server) EnableDebug() {
+	s.debug = true
+}
+
+// handleDebug handles GET /admin/debug/pprof/<name>: the index when name is
+// empty, cmdline, profile, symbol, trace, or a named profile such as heap
+// or goroutine.
+func (s *Server) handleDebug(w http.ResponseWriter, r *http.Request) {
+	if !s.debug {
+		writeError(w, http.StatusNotFound, errors.New("debug endpoints are not enabled"))
+		return
+	}
+	switch name := strings.TrimPrefix(r.URL.Path, debugPrefix); name {
+	case "":
+		pprof.Index(w, r)
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Handler(name).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultLogTail = 100
+	maxLogTail     = 10000
+)
+
+// LogSource gives access to the proxy's recent log lines.
+type LogSource interface {
+	// RecentLogs returns the kept entries after seq, oldest first, and a
+	// channel that is closed when another entry is logged.
+	RecentLogs(seq uint64) ([]LogEntry, <-chan struct{})
+}
+
+// LogEntry is one log line, sent as an event of GET /admin/logs.
+type LogEntry struct {
+	Seq     uint64            `json:"seq"`
+	Time    time.Time         `json:"ts"`
+	Level   string            `json:"level"`
+	Message string            `json:"msg"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// logLevels ranks levels from most to least severe.
+var logLevels = map[string]int{"ERROR": 0, "WARN": 1, "INFO": 2, "DEBUG": 3}
+
+// logFilter selects the entries GET /admin/logs sends.
+type logFilter struct {
+	tail     int
+	maxLevel int       // least severe level to send
+	after    time.Time // zero for no limit
+}
+
+func parseLogFilter(r *http.Request) (logFilter, error) {
+	q := r.URL.Query()
+	f := logFilter{tail: defaultLogTail, maxLevel: logLevels["DEBUG"]}
+	if v := strings.TrimSpace(q.Get("tail")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return f, fmt.Errorf("invalid tail %q", v)
+		}
+		f.tail = min(n, maxLogTail)
+	}
+	if v := strings.TrimSpace(q.Get("level")); v != "" {
+		rank, ok := logLevels[strings.ToUpper(v)]
+		if !ok {
+			return f, fmt.Errorf("invalid level %q (use error, warn, info or debug)", v)
+		}
+		f.maxLevel = rank
+	}
+	if v := strings.TrimSpace(q.Get("since")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return f, fmt.Errorf("invalid since %q", v)
+		}
+		f.after = time.Now().Add(-d)
+	}
+	return f, nil
+}
+
+func (f logFilter) match(e LogEntry) bool {
+	rank, ok := logLevels[e.Level]
+	if !ok || rank > f.maxLevel {
+		return false
+	}
+	return f.after.IsZero() || e.Time.After(f.after)
+}
+
+// EnableLogs serves GET /admin/logs from source. Call it before Start or
+// StartHTTP.
+func (s *Server) EnableLogs(source LogSource) {
+	s.logs = source
+}
+
+// handleLogs handles GET /admin/logs?tail=100&level=error&since=5m: it
+// sends the last tail matching entries as SSE events, then each new
+// matching entry as it is logged, until the client leaves or the stream
+// is 24 hours old.
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if s.logs == nil {
+		writeError(w, http.StatusNotFound, errors.New("logs are not enabled"))
+		return
+	}
+	filter, err := parseLogFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	maxAge := s.streamMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultStreamMaxAge
+	}
+	deadline := time.NewTimer(maxAge)
+	defer deadline.Stop()
+
+	entries, changed := s.logs.RecentLogs(0)
+	var seq uint64
+	if len(entries) > 0 {
+		seq = entries[len(entries)-1].Seq
+	}
+	var backlog []LogEntry
+	for _, e := range entries {
+		if filter.match(e) {
+			backlog = append(backlog, e)
+		}
+	}
+	if len(backlog) > filter.tail {
+		backlog = backlog[len(backlog)-filter.tail:]
+	}
+	if writeLogEntries(w, flusher, backlog) != nil {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+			flusher.Flush()
+			return
+		case <-changed:
+			entries, changed = s.logs.RecentLogs(seq)
+			var fresh []LogEntry
+			for _, e := range entries {
+				seq = e.Seq
+				if filter.match(e) {
+					fresh = append(fresh, e)
+				}
+			}
+			if writeLogEntries(w, flusher, fresh) != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeLogEntries(w http.ResponseWriter, flusher http.Flusher, entries []LogEntry) error {
+	for _, e := range entries {
+		buf, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write([]byte("data: " + string(buf) + "\n\n")); err != nil {
+			return err
+		}
+	}
+	flusher.Flush()
+	return nil
+}
@@ -0,0 +1,62 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Reloader re-reads the proxy's config file and applies what it can.
+type Reloader interface {
+	ReloadConfig() (ReloadResult, error)
+}
+
+// ReloadFunc adapts a function to Reloader.
+type ReloadFunc func() (ReloadResult, error)
+
+// ReloadConfig calls f.
+func (f ReloadFunc) ReloadConfig() (ReloadResult, error) { return f() }
+
+// ReloadResult is the response of POST /admin/reload.
+type ReloadResult struct {
+	Changes []ConfigChange `json:"changes"`
+	// RestartRequired is set when any change only takes effect after a
+	// restart.
+	RestartRequired bool `json:"restart_required"`
+}
+
+// ConfigChange is one setting the reload changed. Secrets read "***".
+type ConfigChange struct {
+	Field           string `json:"field"`
+	Old             string `json:"old"`
+	New             string `json:"new"`
+	RestartRequired bool   `json:"restart_required"`
+}
+
+// EnableReload serves POST /admin/reload with reloader. Call it before
+// Start or StartHTTP.
+func (s *Server) EnableReload(reloader Reloader) {
+	s.reloader = reloader
+}
+
+// handleReload handles POST /admin/reload, which reloads the config file
+// and reports the settings that changed. Reloading an unchanged file
+// reports no changes.
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if s.reloader == nil {
+		writeError(w, http.StatusNotFound, errors.New("reload is not enabled"))
+		return
+	}
+	result, err := s.reloader.ReloadConfig()
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	if result.Changes == nil {
+		result.Changes = []ConfigChange{}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -2,6 +2,7 @@ package admin
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -17,8 +18,49 @@ type KeyStore interface {
 	Add(label, rate string, burst int, quota int64, providedKey string, ttl time.Duration) (KeyInfo, string, error)
 	SetTokenPolicy(id string, balance int64, allowance int64, duration time.Duration) (KeyInfo, error)
 	AddTokens(id string, delta int64) (KeyInfo, error)
+	// BulkKeys applies ops in order, all or none. It returns a result for
+	// every op, and an error when the batch was rolled back.
+	BulkKeys(ops []KeyOp) ([]KeyOpResult, error)
 }
 
+// KeyOp is one operation of POST /admin/keys/bulk. Add uses Label, Rate,
+// Burst and Quota; revoke uses ID; update uses ID and changes the other
+// fields that are set.
+type KeyOp struct {
+	Op    string `json:"op"` // "add", "revoke" or "update"
+	ID    string `json:"id,omitempty"`
+	Label string `json:"label,omitempty"`
+	Rate  string `json:"rate,omitempty"`
+	Burst int    `json:"burst,omitempty"`
+	Quota int64  `json:"quota,omitempty"`
+}
+
+// Key operations.
+const (
+	KeyOpAdd    = "add"
+	KeyOpRevoke = "revoke"
+	KeyOpUpdate = "update"
+)
+
+// KeyOpResult reports what became of one KeyOp.
+type KeyOpResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	Status string `json:"status"` // one of the KeyOpStatus constants
+	KeyID  string `json:"key_id,omitempty"`
+	APIKey string `json:"api_key,omitempty"` // for adds that were committed
+	Error  string `json:"error,omitempty"`
+}
+
+// Key operation statuses. When an op fails, the ones before it are rolled
+// back and the ones after it are skipped.
+const (
+	KeyOpStatusOK         = "ok"
+	KeyOpStatusError      = "error"
+	KeyOpStatusRolledBack = "rolled_back"
+	KeyOpStatusSkipped    = "skipped"
+)
+
 type KeyInfo struct {
 	ID                   string
 	TokenBalance         int64
@@ -27,14 +69,26 @@ type KeyInfo struct {
 }
 
 type Server struct {
-	socketPath string
-	keys       KeyStore
+	socketPath   string
+	keys         KeyStore
+	stats        *StatsAccumulator
+	logs         LogSource
+	reloader     Reloader
+	status       StatusSource
+	debug        bool
+	streamMaxAge time.Duration // 0 = 24h
 }
 
 func New(socketPath string, keys KeyStore) *Server {
 	return &Server{socketPath: socketPath, keys: keys}
 }
 
+// EnableStats serves GET /admin/stats/stream from source's counters.
+// Call it before Start or StartHTTP.
+func (s *Server) EnableStats(source StatsSource) {
+	s.stats = NewStatsAccumulator(source)
+}
+
 func (s *Server) Start(ctx context.Context) error {
 	if s == nil || s.keys == nil {
 		return errors.New("admin server: missing keystore")
@@ -51,10 +105,10 @@ func (s *Server) Start(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	mux := http.NewServeMux()
-	mux.HandleFunc("/admin/keys", s.handleKeys)
-	mux.HandleFunc("/admin/keys/", s.handleKeyActions)
-	server := &http.Server{Handler: mux}
+	if s.stats != nil {
+		s.stats.run(ctx)
+	}
+	server := &http.Server{Handler: s.handler()}
 	go func() {
 		<-ctx.Done()
 		_ = server.Close()
@@ -64,6 +118,76 @@ func (s *Server) Start(ctx context.Context) error {
 	return server.Serve(listener)
 }
 
+// StartHTTP serves the admin endpoints over TCP at addr, such as
+// "127.0.0.1:39002", until ctx is done. Unlike the socket, which relies on
+// file permissions, every request must carry apiKey as a bearer token.
+func (s *Server) StartHTTP(ctx context.Context, addr, apiKey string) error {
+	if s == nil || s.keys == nil {
+		return errors.New("admin server: missing keystore")
+	}
+	if strings.TrimSpace(addr) == "" {
+		return errors.New("admin server: http address required")
+	}
+	if strings.TrimSpace(apiKey) == "" {
+		return errors.New("admin server: api key required for http")
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if s.stats != nil {
+		s.stats.run(ctx)
+	}
+	server := &http.Server{Handler: s.HTTPHandler(apiKey), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// HTTPHandler is the handler StartHTTP serves: the socket's endpoints
+// behind a check for apiKey.
+func (s *Server) HTTPHandler(apiKey string) http.Handler {
+	next := s.handler()
+	want := []byte("Bearer " + apiKey)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if apiKey == "" || subtle.ConstantTimeCompare(got, want) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid admin api key"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/health", s.handleHealth)
+	mux.HandleFunc("/admin/keys", s.handleKeys)
+	mux.HandleFunc("/admin/keys/", s.handleKeyActions)
+	mux.HandleFunc("/admin/keys/bulk", s.handleBulk)
+	mux.HandleFunc("/admin/stats/stream", s.handleStatsStream)
+	mux.HandleFunc("/admin/logs", s.handleLogs)
+	mux.HandleFunc("/admin/reload", s.handleReload)
+	mux.HandleFunc("/admin/usage", s.handleUsage)
+	mux.HandleFunc("/admin/config", s.handleConfig)
+	mux.HandleFunc("/admin/backends", s.handleBackends)
+	mux.HandleFunc(debugPrefix, s.handleDebug)
+	return mux
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
 func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
@@ -81,6 +205,45 @@ func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// maxBulkOps caps the operations in one bulk request.
+const maxBulkOps = 1000
+
+func (s *Server) handleBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	var ops []KeyOp
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(ops) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("no operations"))
+		return
+	}
+	if len(ops) > maxBulkOps {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("at most %d operations per request", maxBulkOps))
+		return
+	}
+	results, err := s.keys.BulkKeys(ops)
+	if err != nil {
+		status := http.StatusInternalServerError
+		for _, res := range results {
+			if res.Status == KeyOpStatusError {
+				status = http.StatusBadRequest
+			}
+		}
+		writeJSON(w, status, map[string]any{
+			"committed": false,
+			"results":   results,
+			"error":     map[string]any{"message": err.Error(), "type": "admin_error"},
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"committed": true, "results": results})
+}
+
 func (s *Server) handleKeyActions(w http.ResponseWriter, r *http.Request) {
 	path := strings.TrimPrefix(r.URL.Path, "/admin/keys/")
 	parts := strings.Split(path, "/")
@@ -5,10 +5,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -64,6 +70,62 @@ func (m *mockKeyStore) AddTokens(id string, delta int64) (KeyInfo, error) {
 	return info, nil
 }
 
+// BulkKeys fails on the first op that is not an add, as a store whose
+// keys are all missing would.
+func (m *mockKeyStore) BulkKeys(ops []KeyOp) ([]KeyOpResult, error) {
+	results := make([]KeyOpResult, len(ops))
+	for i, op := range ops {
+		results[i] = KeyOpResult{Index: i, Op: op.Op, Status: KeyOpStatusOK}
+		if op.Op != KeyOpAdd {
+			results[i].Status = KeyOpStatusError
+			results[i].Error = "key not found"
+			for j := range results[:i] {
+				results[j].Status = KeyOpStatusRolledBack
+			}
+			for j := i + 1; j < len(ops); j++ {
+				results[j] = KeyOpResult{Index: j, Op: ops[j].Op, Status: KeyOpStatusSkipped}
+			}
+			return results, errors.New("key not found")
+		}
+		results[i].KeyID = fmt.Sprintf("key_%d", i)
+	}
+	return results, nil
+}
+
+func TestHandleBulk(t *testing.T) {
+	h := New("", newMockKeyStore()).handler()
+	post := func(body string) (int, map[string]any) {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/keys/bulk", strings.NewReader(body)))
+		var out map[string]any
+		json.Unmarshal(rr.Body.Bytes(), &out)
+		return rr.Code, out
+	}
+
+	code, out := post(`[{"op":"add","label":"a"},{"op":"add","label":"b"}]`)
+	if code != http.StatusOK || out["committed"] != true || len(out["results"].([]any)) != 2 {
+		t.Errorf("all adds: %d %v", code, out)
+	}
+
+	code, out = post(`[{"op":"add","label":"a"},{"op":"revoke","id":"missing"},{"op":"add","label":"c"}]`)
+	if code != http.StatusBadRequest || out["committed"] != false {
+		t.Fatalf("failing batch: %d %v", code, out)
+	}
+	var statuses []string
+	for _, r := range out["results"].([]any) {
+		statuses = append(statuses, r.(map[string]any)["status"].(string))
+	}
+	if want := []string{KeyOpStatusRolledBack, KeyOpStatusError, KeyOpStatusSkipped}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+
+	for _, body := range []string{`[]`, `{"op":"add"}`} {
+		if code, _ := post(body); code != http.StatusBadRequest {
+			t.Errorf("body %s: status = %d, want 400", body, code)
+		}
+	}
+}
+
 func TestNew(t *testing.T) {
 	keys := newMockKeyStore()
 	srv := New("/tmp/test.sock", keys)
@@ -240,6 +302,357 @@ func TestServerIntegration(t *testing.T) {
 	cancel()
 }
 
+func TestHTTPHandlerMatchesSocket(t *testing.T) {
+	// Separate stores, so both see the same sequence of changes.
+	socket := New("", newMockKeyStore()).handler()
+	overHTTP := New("", newMockKeyStore()).HTTPHandler("admin-key")
+
+	requests := []struct {
+		method, path, body string
+	}{
+		{http.MethodGet, "/admin/health", ""},
+		{http.MethodPost, "/admin/keys", ""},
+		{http.MethodGet, "/admin/keys", ""},
+		{http.MethodPost, "/admin/keys/key_test123/policy", `{"token_allowance": 500, "allowance_duration": "24h"}`},
+		{http.MethodPost, "/admin/keys/key_test123/add-tokens", `{"tokens": 100}`},
+		{http.MethodPost, "/admin/keys/nonexistent/add-tokens", `{"tokens": 100}`},
+		{http.MethodPost, "/admin/keys/key_test123/invalid", ""},
+	}
+	serve := func(h http.Handler, method, path, body, bearer string) (int, map[string]any) {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if bearer != "" {
+			req.Header.Set("Authorization", "Bearer "+bearer)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		var out map[string]any
+		json.Unmarshal(rr.Body.Bytes(), &out)
+		delete(out, "created_at")
+		return rr.Code, out
+	}
+	for _, r := range requests {
+		wantCode, wantBody := serve(socket, r.method, r.path, r.body, "")
+		gotCode, gotBody := serve(overHTTP, r.method, r.path, r.body, "admin-key")
+		if gotCode != wantCode || !reflect.DeepEqual(gotBody, wantBody) {
+			t.Errorf("%s %s: http = %d %v, socket = %d %v", r.method, r.path, gotCode, gotBody, wantCode, wantBody)
+		}
+	}
+
+	for _, bearer := range []string{"", "wrong-key"} {
+		if code, _ := serve(overHTTP, http.MethodGet, "/admin/health", "", bearer); code != http.StatusUnauthorized {
+			t.Errorf("bearer %q: status = %d, want 401", bearer, code)
+		}
+	}
+}
+
+func TestStartHTTPRequiresAPIKey(t *testing.T) {
+	srv := New("", newMockKeyStore())
+	if err := srv.StartHTTP(context.Background(), "127.0.0.1:0", ""); err == nil {
+		t.Error("StartHTTP without an api key succeeded")
+	}
+	if err := srv.StartHTTP(context.Background(), "", "admin-key"); err == nil {
+		t.Error("StartHTTP without an address succeeded")
+	}
+}
+
+// fakeStats is a StatsSource returning totals.
+type fakeStats struct {
+	totals StatsTotals
+}
+
+func (f *fakeStats) AdminStats() StatsTotals { return f.totals }
+
+func TestStatsAccumulator(t *testing.T) {
+	src := &fakeStats{totals: StatsTotals{Requests: 10, TokensIn: 100, Backends: map[string]BackendStats{"codex": {Requests: 10}}}}
+	acc := NewStatsAccumulator(src)
+	start := time.Now()
+	acc.Sample(start)
+
+	src.totals = StatsTotals{Active: 2, Requests: 15, Errors: 1, TokensIn: 160, TokensOut: 40,
+		Backends: map[string]BackendStats{"codex": {Requests: 13, TokensIn: 60}, "claude": {Requests: 2, Errors: 1}}}
+	ev := acc.Sample(start.Add(30 * time.Second))
+	if ev.ActiveRequests != 2 || ev.TotalRequestsLastMinute != 5 || ev.ErrorsLastMinute != 1 || ev.TokensInLastMinute != 60 || ev.TokensOutLastMinute != 40 {
+		t.Errorf("event = %+v", ev)
+	}
+	if got := ev.PerBackendBreakdown["codex"]; got.Requests != 3 || got.TokensIn != 60 {
+		t.Errorf("codex = %+v", got)
+	}
+	if got := ev.PerBackendBreakdown["claude"]; got.Requests != 2 || got.Errors != 1 {
+		t.Errorf("claude = %+v", got)
+	}
+
+	// Samples more than a minute old stop counting.
+	ev = acc.Sample(start.Add(95 * time.Second))
+	if ev.TotalRequestsLastMinute != 0 || ev.ActiveRequests != 2 {
+		t.Errorf("after a quiet minute: event = %+v", ev)
+	}
+}
+
+func TestStatsStream(t *testing.T) {
+	srv := New("", newMockKeyStore())
+	srv.EnableStats(&fakeStats{totals: StatsTotals{Active: 3}})
+	srv.streamMaxAge = 50 * time.Millisecond
+	srv.stats.Sample(time.Now())
+	ts := httptest.NewServer(srv.HTTPHandler("admin-key"))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/admin/stats/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("without key: status = %d, want 401", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/admin/stats/stream", nil)
+	req.Header.Set("Authorization", "Bearer admin-key")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n\n")
+	var ev StatsEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[0], "data: ")), &ev); err != nil || ev.ActiveRequests != 3 {
+		t.Errorf("first event = %q (%v)", lines[0], err)
+	}
+	if last := lines[len(lines)-1]; last != "data: [DONE]" {
+		t.Errorf("stream ended with %q, want [DONE] at max age", last)
+	}
+}
+
+type fakeLogs struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	changed chan struct{}
+}
+
+func (f *fakeLogs) add(level, msg string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries = append(f.entries, LogEntry{Seq: uint64(len(f.entries) + 1), Time: time.Now(), Level: level, Message: msg})
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *fakeLogs) RecentLogs(seq uint64) ([]LogEntry, <-chan struct{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []LogEntry
+	for _, e := range f.entries {
+		if e.Seq > seq {
+			out = append(out, e)
+		}
+	}
+	return out, f.changed
+}
+
+func TestLogsStream(t *testing.T) {
+	logs := &fakeLogs{changed: make(chan struct{})}
+	logs.add("INFO", "started")
+	logs.add("ERROR", "first failure")
+	logs.add("WARN", "slow backend")
+	logs.add("ERROR", "second failure")
+	srv := New("", newMockKeyStore())
+	srv.EnableLogs(logs)
+	srv.streamMaxAge = 200 * time.Millisecond
+	ts := httptest.NewServer(srv.HTTPHandler("admin-key"))
+	defer ts.Close()
+
+	get := func(query string) (*http.Response, error) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/admin/logs"+query, nil)
+		req.Header.Set("Authorization", "Bearer admin-key")
+		return http.DefaultClient.Do(req)
+	}
+	resp, err := get("?level=bogus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("bad level: status = %d, want 400", resp.StatusCode)
+	}
+
+	resp, err = get("?tail=1&level=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	time.AfterFunc(50*time.Millisecond, func() {
+		logs.add("INFO", "ignored")
+		logs.add("ERROR", "third failure")
+	})
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n\n") {
+		data := strings.TrimPrefix(line, "data: ")
+		if data == "[DONE]" {
+			got = append(got, data)
+			continue
+		}
+		var e LogEntry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("event %q: %v", line, err)
+		}
+		got = append(got, e.Message)
+	}
+	want := []string{"second failure", "third failure", "[DONE]"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
+
+func TestHandleReload(t *testing.T) {
+	srv := New("", newMockKeyStore())
+	h := srv.handler()
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("not enabled: status = %d, want 404", rr.Code)
+	}
+
+	var fail bool
+	srv.EnableReload(ReloadFunc(func() (ReloadResult, error) {
+		if fail {
+			return ReloadResult{}, errors.New("config.yaml: invalid")
+		}
+		return ReloadResult{}, nil
+	}))
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/reload", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d, want 405", rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"changes":[],"restart_required":false}` {
+		t.Errorf("unchanged: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	fail = true
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("bad config: status = %d, want 422", rr.Code)
+	}
+}
+
+type fakeStatus struct {
+	since time.Duration
+	keyID string
+}
+
+func (f *fakeStatus) AdminUsage(since time.Duration, keyID string) ([]UsageReport, error) {
+	f.since, f.keyID = since, keyID
+	return []UsageReport{{KeyID: "key_a", Requests: 2, TotalTokens: 30}}, nil
+}
+
+func (f *fakeStatus) AdminConfig() any {
+	return map[string]any{"proxy": map[string]any{"admin_api_key": "***"}}
+}
+
+func (f *fakeStatus) AdminBackends(ctx context.Context) []BackendStatus {
+	return []BackendStatus{{Name: "codex", Status: "ok", LatencyMs: 5}}
+}
+
+func TestStatusEndpoints(t *testing.T) {
+	srv := New("", newMockKeyStore())
+	h := srv.HTTPHandler("admin-key")
+	get := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer admin-key")
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := get(http.MethodGet, "/admin/usage"); rr.Code != http.StatusNotFound {
+		t.Fatalf("not enabled: status = %d, want 404", rr.Code)
+	}
+
+	source := &fakeStatus{}
+	srv.EnableStatus(source)
+	rr := get(http.MethodGet, "/admin/usage?since=24h&key=key_a")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"key_id":"key_a"`) {
+		t.Errorf("usage: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	if source.since != 24*time.Hour || source.keyID != "key_a" {
+		t.Errorf("usage args = %v, %q", source.since, source.keyID)
+	}
+	if rr := get(http.MethodGet, "/admin/usage?since=yesterday"); rr.Code != http.StatusBadRequest {
+		t.Errorf("bad since: status = %d, want 400", rr.Code)
+	}
+	if rr := get(http.MethodPost, "/admin/usage"); rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status = %d, want 405", rr.Code)
+	}
+	rr = get(http.MethodGet, "/admin/config")
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != `{"proxy":{"admin_api_key":"***"}}` {
+		t.Errorf("config: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+	rr = get(http.MethodGet, "/admin/backends")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"name":"codex"`) {
+		t.Errorf("backends: status = %d, body = %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDebugPprof(t *testing.T) {
+	srv := New("", newMockKeyStore())
+	ts := httptest.NewServer(srv.HTTPHandler("admin-key"))
+	defer ts.Close()
+	get := func(path, key string) (*http.Response, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp, body
+	}
+
+	if resp, _ := get("/admin/debug/pprof/heap", "admin-key"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("disabled: status = %d, want 404", resp.StatusCode)
+	}
+
+	srv.EnableDebug()
+	if resp, _ := get("/admin/debug/pprof/heap", "wrong"); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("wrong key: status = %d, want 401", resp.StatusCode)
+	}
+	resp, body := get("/admin/debug/pprof/heap", "admin-key")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/octet-stream" {
+		t.Fatalf("heap: status = %d, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	// Profiles are gzipped protobuf, as go tool pprof expects.
+	if !bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		t.Errorf("heap profile is not gzipped: % x", body[:min(len(body), 8)])
+	}
+	resp, body = get("/admin/debug/pprof/goroutine?debug=1", "admin-key")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "goroutine profile") {
+		t.Errorf("goroutine: status = %d, body = %.80s", resp.StatusCode, body)
+	}
+	resp, body = get("/admin/debug/pprof/", "admin-key")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), "heap") {
+		t.Errorf("index: status = %d", resp.StatusCode)
+	}
+	if resp, _ := get("/admin/debug/pprof/nonesuch", "admin-key"); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown profile: status = %d, want 404", resp.StatusCode)
+	}
+}
+
 func TestExpandPath(t *testing.T) {
 	home, _ := os.UserHomeDir()
 
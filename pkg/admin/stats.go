@@ -0,0 +1,197 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	statsInterval       = time.Second
+	statsWindow         = time.Minute
+	defaultStreamMaxAge = 24 * time.Hour
+	statsSamplesToKeep  = int(statsWindow/statsInterval) + 1
+)
+
+// StatsSource reports the proxy's lifetime counters, which it keeps with
+// sync/atomic.
+type StatsSource interface {
+	AdminStats() StatsTotals
+}
+
+// StatsTotals are lifetime counters, apart from Active.
+type StatsTotals struct {
+	Active    int64
+	Requests  int64
+	Errors    int64
+	TokensIn  int64
+	TokensOut int64
+	Backends  map[string]BackendStats
+}
+
+// BackendStats counts one backend's turns and tokens.
+type BackendStats struct {
+	Requests  int64 `json:"requests"`
+	Errors    int64 `json:"errors"`
+	TokensIn  int64 `json:"tokens_in"`
+	TokensOut int64 `json:"tokens_out"`
+}
+
+// StatsEvent is one event of GET /admin/stats/stream.
+type StatsEvent struct {
+	Timestamp               string                  `json:"ts"`
+	ActiveRequests          int64                   `json:"active_requests"`
+	TotalRequestsLastMinute int64                   `json:"total_requests_last_minute"`
+	ErrorsLastMinute        int64                   `json:"errors_last_minute"`
+	TokensInLastMinute      int64                   `json:"tokens_in_last_minute"`
+	TokensOutLastMinute     int64                   `json:"tokens_out_last_minute"`
+	PerBackendBreakdown     map[string]BackendStats `json:"per_backend_breakdown"`
+}
+
+// StatsAccumulator samples a StatsSource every second and turns the
+// lifetime counters into totals over the last minute.
+type StatsAccumulator struct {
+	source  StatsSource
+	once    sync.Once
+	mu      sync.Mutex
+	samples []statsSample // oldest first, at most a minute apart
+}
+
+type statsSample struct {
+	at     time.Time
+	totals StatsTotals
+}
+
+// NewStatsAccumulator returns an accumulator reading source.
+func NewStatsAccumulator(source StatsSource) *StatsAccumulator {
+	return &StatsAccumulator{source: source}
+}
+
+// run samples until ctx is done. Only the first call samples; later ones
+// return at once, so each transport can call it.
+func (a *StatsAccumulator) run(ctx context.Context) {
+	a.once.Do(func() {
+		a.Sample(time.Now())
+		go func() {
+			ticker := time.NewTicker(statsInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case now := <-ticker.C:
+					a.Sample(now)
+				}
+			}
+		}()
+	})
+}
+
+// Sample records the source's counters at now and returns the event they
+// give. Until a minute has been sampled, the totals cover the time since
+// the first sample.
+func (a *StatsAccumulator) Sample(now time.Time) StatsEvent {
+	totals := a.source.AdminStats()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.samples = append(a.samples, statsSample{at: now, totals: totals})
+	for len(a.samples) > 1 && (now.Sub(a.samples[1].at) >= statsWindow || len(a.samples) > statsSamplesToKeep) {
+		a.samples = a.samples[1:]
+	}
+	return a.eventLocked()
+}
+
+// Latest returns the event for the most recent sample.
+func (a *StatsAccumulator) Latest() StatsEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.eventLocked()
+}
+
+func (a *StatsAccumulator) eventLocked() StatsEvent {
+	ev := StatsEvent{PerBackendBreakdown: map[string]BackendStats{}}
+	if len(a.samples) == 0 {
+		ev.Timestamp = time.Now().UTC().Format(time.RFC3339)
+		return ev
+	}
+	first, last := a.samples[0], a.samples[len(a.samples)-1]
+	ev.Timestamp = last.at.UTC().Format(time.RFC3339)
+	ev.ActiveRequests = last.totals.Active
+	ev.TotalRequestsLastMinute = last.totals.Requests - first.totals.Requests
+	ev.ErrorsLastMinute = last.totals.Errors - first.totals.Errors
+	ev.TokensInLastMinute = last.totals.TokensIn - first.totals.TokensIn
+	ev.TokensOutLastMinute = last.totals.TokensOut - first.totals.TokensOut
+	for name, cur := range last.totals.Backends {
+		prev := first.totals.Backends[name]
+		ev.PerBackendBreakdown[name] = BackendStats{
+			Requests:  cur.Requests - prev.Requests,
+			Errors:    cur.Errors - prev.Errors,
+			TokensIn:  cur.TokensIn - prev.TokensIn,
+			TokensOut: cur.TokensOut - prev.TokensOut,
+		}
+	}
+	return ev
+}
+
+// handleStatsStream handles GET /admin/stats/stream, sending a StatsEvent
+// every second until the client leaves or the stream is 24 hours old.
+func (s *Server) handleStatsStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if s.stats == nil {
+		writeError(w, http.StatusNotFound, errors.New("stats are not enabled"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	maxAge := s.streamMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultStreamMaxAge
+	}
+	deadline := time.NewTimer(maxAge)
+	defer deadline.Stop()
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	if writeStatsEvent(w, flusher, s.stats.Latest()) != nil {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-deadline.C:
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+			flusher.Flush()
+			return
+		case <-ticker.C:
+			if writeStatsEvent(w, flusher, s.stats.Latest()) != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeStatsEvent(w http.ResponseWriter, flusher http.Flusher, ev StatsEvent) error {
+	buf, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte("data: " + string(buf) + "\n\n")); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
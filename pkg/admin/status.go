@@ -0,0 +1,115 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"godex/pkg/circuitbreaker"
+)
+
+// StatusSource reports what the proxy is doing for GET /admin/usage,
+// GET /admin/config and GET /admin/backends.
+type StatusSource interface {
+	// AdminUsage returns per-key usage over the last since (0 for all
+	// recorded usage), limited to keyID when it is set.
+	AdminUsage(since time.Duration, keyID string) ([]UsageReport, error)
+	// AdminConfig returns the running config with its secrets masked.
+	AdminConfig() any
+	// AdminBackends probes every registered backend.
+	AdminBackends(ctx context.Context) []BackendStatus
+}
+
+// UsageReport is one key's row in GET /admin/usage.
+type UsageReport struct {
+	KeyID       string `json:"key_id"`
+	Label       string `json:"label,omitempty"`
+	Requests    int    `json:"requests"`
+	TotalTokens int    `json:"total_tokens"`
+	LastSeen    string `json:"last_seen,omitempty"`
+}
+
+// BackendStatus is one backend in GET /admin/backends.
+type BackendStatus struct {
+	Name      string                   `json:"name"`
+	Model     string                   `json:"model,omitempty"`
+	Status    string                   `json:"status"`
+	LatencyMs int64                    `json:"latency_ms"`
+	LastError string                   `json:"last_error,omitempty"`
+	Breaker   *circuitbreaker.Snapshot `json:"circuit_breaker,omitempty"`
+}
+
+// EnableStatus serves GET /admin/usage, /admin/config and /admin/backends
+// from source. Call it before Start or StartHTTP.
+func (s *Server) EnableStatus(source StatusSource) {
+	s.status = source
+}
+
+// statusSource returns the StatusSource for a GET request, or writes the
+// error and returns nil.
+func (s *Server) statusSource(w http.ResponseWriter, r *http.Request) StatusSource {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return nil
+	}
+	if s.status == nil {
+		writeError(w, http.StatusNotFound, errors.New("status is not enabled"))
+		return nil
+	}
+	return s.status
+}
+
+// handleUsage handles GET /admin/usage?since=24h&key=KEY_ID. Unlike
+// GET /v1/usage, every key is visible.
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	source := s.statusSource(w, r)
+	if source == nil {
+		return
+	}
+	q := r.URL.Query()
+	var since time.Duration
+	if raw := strings.TrimSpace(q.Get("since")); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid since %q", raw))
+			return
+		}
+		since = d
+	}
+	data, err := source.AdminUsage(since, strings.TrimSpace(q.Get("key")))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if data == nil {
+		data = []UsageReport{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+// handleConfig handles GET /admin/config: the running config, with API
+// keys, secrets and URL passwords masked.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	source := s.statusSource(w, r)
+	if source == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, source.AdminConfig())
+}
+
+// handleBackends handles GET /admin/backends, which probes every backend
+// as GET /v1/backends does.
+func (s *Server) handleBackends(w http.ResponseWriter, r *http.Request) {
+	source := s.statusSource(w, r)
+	if source == nil {
+		return
+	}
+	data := source.AdminBackends(r.Context())
+	if data == nil {
+		data = []BackendStatus{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
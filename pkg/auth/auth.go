@@ -9,8 +9,10 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
@@ -24,15 +26,23 @@ var (
 	refreshScope    = "openid profile email"
 )
 
+// RefreshWindow is how long before its access token expires that a Store
+// reports NeedsRefresh.
+const RefreshWindow = 5 * time.Minute
+
 var (
 	ErrNoToken            = errors.New("no authorization token in auth.json")
 	ErrRefreshUnavailable = errors.New("token refresh unavailable for current auth state")
 )
 
+// File is the Codex CLI's auth.json. Its top-level credentials are the
+// default account; Profiles holds further named accounts, which the Codex
+// CLI ignores.
 type File struct {
-	AuthMode string `json:"auth_mode,omitempty"`
-	APIKey   string `json:"OPENAI_API_KEY,omitempty"`
-	Tokens   Tokens `json:"tokens,omitempty"`
+	AuthMode string           `json:"auth_mode,omitempty"`
+	APIKey   string           `json:"OPENAI_API_KEY,omitempty"`
+	Tokens   Tokens           `json:"tokens,omitempty"`
+	Profiles map[string]*File `json:"profiles,omitempty"`
 }
 
 type Tokens struct {
@@ -40,6 +50,9 @@ type Tokens struct {
 	RefreshToken string    `json:"refresh_token,omitempty"`
 	AccountID    string    `json:"account_id,omitempty"`
 	IDToken      IDTokenV1 `json:"id_token,omitempty"`
+	// ExpiresAt is when AccessToken expires, from the token endpoint's
+	// expires_in; used when the access token is not a JWT.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
 type IDTokenV1 struct {
@@ -77,6 +90,8 @@ type Store struct {
 	path string
 	mu   sync.Mutex
 	File File
+	// refreshMu serializes RefreshIfNeeded.
+	refreshMu sync.Mutex
 }
 
 type RefreshOptions struct {
@@ -119,6 +134,15 @@ func Load(path string) (*Store, error) {
 	if f.AuthMode == "" {
 		f.AuthMode = ModeChatGPT
 	}
+	for name, p := range f.Profiles {
+		if p == nil {
+			delete(f.Profiles, name)
+			continue
+		}
+		if p.AuthMode == "" {
+			p.AuthMode = ModeChatGPT
+		}
+	}
 	return &Store{path: path, File: f}, nil
 }
 
@@ -138,6 +162,91 @@ func (s *Store) AccountID() string {
 	return accountIDNoLock(s.File)
 }
 
+// Profiles returns the names of the named accounts, sorted. The default
+// account, "", is not included.
+func (s *Store) Profiles() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.File.Profiles))
+	for name := range s.File.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TokenForProfile returns the authorization token of the named account,
+// or of the default account when profile is "".
+func (s *Store) TokenForProfile(profile string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.profileNoLock(profile)
+	if err != nil {
+		return "", err
+	}
+	return authorizationTokenNoLock(*f)
+}
+
+// AccountIDForProfile returns the ChatGPT account ID of the named account,
+// or "" if it is unknown or not a ChatGPT login.
+func (s *Store) AccountIDForProfile(profile string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.profileNoLock(profile)
+	if err != nil || f.AuthMode != ModeChatGPT {
+		return ""
+	}
+	return accountIDNoLock(*f)
+}
+
+// ProfileExpiresAt is ExpiresAt for the named account; it is zero for an
+// unknown profile.
+func (s *Store) ProfileExpiresAt(profile string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.profileNoLock(profile)
+	if err != nil {
+		return time.Time{}
+	}
+	return expiresAtNoLock(*f)
+}
+
+// AddProfile stores f as the named account, replacing any account of that
+// name, and saves the auth file. Profiles nested in f are dropped.
+func (s *Store) AddProfile(name string, f File) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("profile name is required")
+	}
+	if _, err := authorizationTokenNoLock(f); err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+	f.Profiles = nil
+	if f.AuthMode == "" {
+		f.AuthMode = ModeChatGPT
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.File.Profiles == nil {
+		s.File.Profiles = map[string]*File{}
+	}
+	s.File.Profiles[name] = &f
+	return s.saveNoLock()
+}
+
+// profileNoLock returns the credentials of the named account; "" is the
+// default account.
+func (s *Store) profileNoLock(profile string) (*File, error) {
+	if profile == "" {
+		return &s.File, nil
+	}
+	f, ok := s.File.Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown auth profile %q", profile)
+	}
+	return f, nil
+}
+
 func (s *Store) IsChatGPT() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -156,6 +265,54 @@ func (s *Store) CanRefresh() bool {
 	return canRefreshNoLock(s.File)
 }
 
+// ExpiresAt returns when the access token expires: its exp claim if it is
+// a JWT, else the expiry recorded in the auth file. It is zero when
+// unknown, as for API keys.
+func (s *Store) ExpiresAt() time.Time {
+	return s.ProfileExpiresAt("")
+}
+
+// IsExpired reports whether the access token is known to have expired.
+func (s *Store) IsExpired() bool {
+	exp := s.ExpiresAt()
+	return !exp.IsZero() && !time.Now().Before(exp)
+}
+
+// NeedsRefresh reports whether the access token expires within
+// RefreshWindow, or already has.
+func (s *Store) NeedsRefresh() bool {
+	exp := s.ExpiresAt()
+	return !exp.IsZero() && time.Until(exp) < RefreshWindow
+}
+
+// RefreshIfNeeded refreshes the token when NeedsRefresh and CanRefresh
+// report it should be, and reports whether it did. Concurrent callers
+// wait for one refresh rather than each spending the refresh token.
+func (s *Store) RefreshIfNeeded(ctx context.Context, opts RefreshOptions) (bool, error) {
+	return s.RefreshProfileIfNeeded(ctx, "", opts)
+}
+
+// RefreshProfileIfNeeded is RefreshIfNeeded for the named account.
+func (s *Store) RefreshProfileIfNeeded(ctx context.Context, profile string, opts RefreshOptions) (bool, error) {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+	exp := s.ProfileExpiresAt(profile)
+	if exp.IsZero() || time.Until(exp) >= RefreshWindow {
+		return false, nil
+	}
+	s.mu.Lock()
+	f, err := s.profileNoLock(profile)
+	canRefresh := err == nil && canRefreshNoLock(*f)
+	s.mu.Unlock()
+	if !canRefresh {
+		return false, nil
+	}
+	if err := s.RefreshProfile(ctx, profile, opts); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (s *Store) Save() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -175,16 +332,27 @@ func (s *Store) saveNoLock() error {
 }
 
 func (s *Store) Refresh(ctx context.Context, opts RefreshOptions) error {
+	return s.RefreshProfile(ctx, "", opts)
+}
+
+// RefreshProfile is Refresh for the named account; "" is the default
+// account.
+func (s *Store) RefreshProfile(ctx context.Context, profile string, opts RefreshOptions) error {
 	if !opts.AllowNetwork {
 		return fmt.Errorf("refresh blocked: %w", ErrRefreshUnavailable)
 	}
 
 	s.mu.Lock()
-	if !canRefreshNoLock(s.File) {
+	f, err := s.profileNoLock(profile)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if !canRefreshNoLock(*f) {
 		s.mu.Unlock()
 		return ErrRefreshUnavailable
 	}
-	refreshToken := s.File.Tokens.RefreshToken
+	refreshToken := f.Tokens.RefreshToken
 	s.mu.Unlock()
 
 	body := map[string]string{
@@ -218,6 +386,7 @@ func (s *Store) Refresh(ctx context.Context, opts RefreshOptions) error {
 		AccessToken  string `json:"access_token"`
 		RefreshToken string `json:"refresh_token"`
 		IDToken      string `json:"id_token"`
+		ExpiresIn    int64  `json:"expires_in"`
 		Error        string `json:"error"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&rr); err != nil {
@@ -235,12 +404,17 @@ func (s *Store) Refresh(ctx context.Context, opts RefreshOptions) error {
 	}
 
 	s.mu.Lock()
-	s.File.Tokens.AccessToken = rr.AccessToken
+	if f, err = s.profileNoLock(profile); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	f.Tokens.AccessToken = rr.AccessToken
+	f.Tokens.ExpiresAt = expiresIn(rr.ExpiresIn)
 	if rr.RefreshToken != "" {
-		s.File.Tokens.RefreshToken = rr.RefreshToken
+		f.Tokens.RefreshToken = rr.RefreshToken
 	}
 	if rr.IDToken != "" {
-		s.File.Tokens.IDToken.RawJWT = rr.IDToken
+		f.Tokens.IDToken.RawJWT = rr.IDToken
 	}
 	err = s.saveNoLock()
 	s.mu.Unlock()
@@ -280,6 +454,45 @@ func accountIDNoLock(f File) string {
 	return f.Tokens.IDToken.ChatGPTAccountID
 }
 
+func expiresAtNoLock(f File) time.Time {
+	if f.AuthMode == ModeAPIKey {
+		return time.Time{}
+	}
+	if exp := jwtExpiry(f.Tokens.AccessToken); !exp.IsZero() {
+		return exp
+	}
+	if f.Tokens.ExpiresAt != nil {
+		return *f.Tokens.ExpiresAt
+	}
+	return time.Time{}
+}
+
+// jwtExpiry returns the exp claim of a JWT, or zero if token is not one.
+// The signature is not checked.
+func jwtExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if decodeSegment(parts[1], &claims) != nil || claims.Exp <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(claims.Exp), 0)
+}
+
+// expiresIn converts a token endpoint's expires_in to a time, or nil when
+// it gave none.
+func expiresIn(seconds int64) *time.Time {
+	if seconds <= 0 {
+		return nil
+	}
+	exp := time.Now().Add(time.Duration(seconds) * time.Second).UTC()
+	return &exp
+}
+
 func canRefreshNoLock(f File) bool {
 	return f.AuthMode == ModeChatGPT && f.Tokens.RefreshToken != ""
 }
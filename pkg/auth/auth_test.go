@@ -2,10 +2,13 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 )
 
 func TestLoadAuthorizationTokenAndAccountID(t *testing.T) {
@@ -329,3 +332,93 @@ func TestSetRefreshConfigEmpty(t *testing.T) {
 		t.Error("empty string should not change refreshURL")
 	}
 }
+
+// testJWT returns an unsigned JWT whose exp claim is exp.
+func testJWT(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "eyJhbGciOiJub25lIn0." + payload + ".sig"
+}
+
+func TestTokenExpiry(t *testing.T) {
+	soon := time.Now().Add(2 * time.Minute)
+	later := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name         string
+		file         File
+		expired      bool
+		needsRefresh bool
+	}{
+		{"jwt valid", File{AuthMode: ModeChatGPT, Tokens: Tokens{AccessToken: testJWT(later)}}, false, false},
+		{"jwt expiring", File{AuthMode: ModeChatGPT, Tokens: Tokens{AccessToken: testJWT(soon)}}, false, true},
+		{"jwt expired", File{AuthMode: ModeChatGPT, Tokens: Tokens{AccessToken: testJWT(past)}}, true, true},
+		{"expires_at", File{AuthMode: ModeChatGPT, Tokens: Tokens{AccessToken: "opaque", ExpiresAt: &soon}}, false, true},
+		{"unknown expiry", File{AuthMode: ModeChatGPT, Tokens: Tokens{AccessToken: "opaque"}}, false, false},
+		{"api key", File{AuthMode: ModeAPIKey, APIKey: "sk-test"}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Store{File: tt.file}
+			if got := s.IsExpired(); got != tt.expired {
+				t.Errorf("IsExpired = %v, want %v", got, tt.expired)
+			}
+			if got := s.NeedsRefresh(); got != tt.needsRefresh {
+				t.Errorf("NeedsRefresh = %v, want %v", got, tt.needsRefresh)
+			}
+		})
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.json")
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	data := fmt.Sprintf(`{"tokens":{"access_token":"default-token","account_id":"acct-default"},
+		"profiles":{"work":{"tokens":{"access_token":%q,"account_id":"acct-work"}}}}`, testJWT(exp))
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if got := store.Profiles(); len(got) != 1 || got[0] != "work" {
+		t.Fatalf("Profiles = %v, want [work]", got)
+	}
+	if tok, err := store.TokenForProfile(""); err != nil || tok != "default-token" {
+		t.Errorf("TokenForProfile(\"\") = %q, %v", tok, err)
+	}
+	if tok, err := store.TokenForProfile("work"); err != nil || tok != testJWT(exp) {
+		t.Errorf("TokenForProfile(work) = %q, %v", tok, err)
+	}
+	if got := store.AccountIDForProfile("work"); got != "acct-work" {
+		t.Errorf("AccountIDForProfile(work) = %q, want acct-work", got)
+	}
+	if got := store.ProfileExpiresAt("work"); !got.Equal(exp) {
+		t.Errorf("ProfileExpiresAt(work) = %v, want %v", got, exp)
+	}
+	if _, err := store.TokenForProfile("missing"); err == nil {
+		t.Error("TokenForProfile(missing) succeeded")
+	}
+
+	if err := store.AddProfile("personal", File{AuthMode: ModeAPIKey, APIKey: "sk-personal"}); err != nil {
+		t.Fatalf("AddProfile: %v", err)
+	}
+	if err := store.AddProfile("empty", File{}); err == nil {
+		t.Error("AddProfile accepted a file without credentials")
+	}
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if tok, err := reloaded.TokenForProfile("personal"); err != nil || tok != "sk-personal" {
+		t.Errorf("saved profile token = %q, %v", tok, err)
+	}
+	if got := reloaded.AccountIDForProfile("personal"); got != "" {
+		t.Errorf("api key profile AccountID = %q, want empty", got)
+	}
+	if tok, _ := reloaded.AuthorizationToken(); tok != "default-token" {
+		t.Errorf("default token = %q after AddProfile", tok)
+	}
+}
@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultHealthTimeout bounds a CheckHealth request when
+// HealthCheckConfig.Timeout is zero.
+const DefaultHealthTimeout = 5 * time.Second
+
+// DefaultCodexBaseURL is the ChatGPT Codex backend, used when no
+// client.base_url is configured.
+const DefaultCodexBaseURL = "https://chatgpt.com/backend-api/codex"
+
+const (
+	defaultAnthropicHealthURL = "https://api.anthropic.com/v1/messages/count_tokens"
+	defaultAnthropicModel     = "claude-haiku-4-5"
+)
+
+// Health check backends.
+const (
+	BackendCodex     = "codex"
+	BackendAnthropic = "anthropic"
+)
+
+// HealthCheckConfig configures CheckHealth.
+type HealthCheckConfig struct {
+	// Backend is BackendCodex (default) or BackendAnthropic.
+	Backend string
+	// Token is the Anthropic OAuth access token; the Codex check takes its
+	// token from the store.
+	Token string
+	// ExpiresAt is when Token expires, if known.
+	ExpiresAt time.Time
+	// BaseURL is the Codex backend (client.base_url), whose models
+	// endpoint the Codex check lists, as the codex client does. Defaults
+	// to the ChatGPT Codex backend.
+	BaseURL string
+	// AccountProfile is the named account of the store the Codex check
+	// uses (client.account_profile); "" is the default account.
+	AccountProfile string
+	// URL overrides the endpoint: GET BaseURL/models for Codex, POST
+	// /v1/messages/count_tokens for Anthropic.
+	URL string
+	// Model is counted against by the Anthropic check.
+	Model      string
+	Timeout    time.Duration
+	HTTPClient *http.Client
+}
+
+// AuthHealth is the result of a live credential check.
+type AuthHealth struct {
+	Backend   string
+	Valid     bool
+	ExpiresAt time.Time // zero if unknown
+	Error     string
+}
+
+// Expired reports whether the credentials are known to have expired.
+func (h AuthHealth) Expired() bool {
+	return !h.ExpiresAt.IsZero() && !time.Now().Before(h.ExpiresAt)
+}
+
+// CheckHealth makes the cheapest authenticated request the backend offers
+// to see whether its credentials work: listing the Codex backend's models
+// as the selected account for Codex, counting the tokens of a one-word
+// prompt for Anthropic. Credentials the backend rejects give an AuthHealth
+// with Valid false and the reason in Error; the error is set only when the
+// check itself could not be made, and is then also recorded in Error.
+func CheckHealth(ctx context.Context, store *Store, cfg HealthCheckConfig) (AuthHealth, error) {
+	if cfg.Backend == "" {
+		cfg.Backend = BackendCodex
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultHealthTimeout
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	health := AuthHealth{Backend: cfg.Backend, ExpiresAt: cfg.ExpiresAt}
+	fail := func(err error) (AuthHealth, error) {
+		health.Error = err.Error()
+		return health, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	var req *http.Request
+	var err error
+	switch cfg.Backend {
+	case BackendCodex:
+		if store == nil {
+			return fail(errors.New("auth store is required"))
+		}
+		health.ExpiresAt = store.ProfileExpiresAt(cfg.AccountProfile)
+		token, terr := store.TokenForProfile(cfg.AccountProfile)
+		if terr != nil {
+			health.Error = terr.Error()
+			return health, nil
+		}
+		modelsURL := strings.TrimRight(orDefault(cfg.BaseURL, DefaultCodexBaseURL), "/") + "/models"
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, orDefault(cfg.URL, modelsURL), nil)
+		if err != nil {
+			return fail(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if accountID := store.AccountIDForProfile(cfg.AccountProfile); accountID != "" {
+			req.Header.Set("chatgpt-account-id", accountID)
+		}
+	case BackendAnthropic:
+		if strings.TrimSpace(cfg.Token) == "" {
+			health.Error = ErrNoToken.Error()
+			return health, nil
+		}
+		body := fmt.Sprintf(`{"model":%q,"messages":[{"role":"user","content":"ping"}]}`, orDefault(cfg.Model, defaultAnthropicModel))
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, orDefault(cfg.URL, defaultAnthropicHealthURL), strings.NewReader(body))
+		if err != nil {
+			return fail(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("anthropic-version", "2023-06-01")
+		req.Header.Set("anthropic-beta", "oauth-2025-04-20,token-counting-2024-11-01")
+	default:
+		return fail(fmt.Errorf("unknown backend %q", cfg.Backend))
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fail(fmt.Errorf("health check request: %w", err))
+	}
+	defer resp.Body.Close()
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 4*1024))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		health.Valid = true
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		health.Error = fmt.Sprintf("rejected with status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail)))
+	default:
+		return fail(fmt.Errorf("health check failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(detail))))
+	}
+	return health, nil
+}
+
+func orDefault(value, fallback string) string {
+	if strings.TrimSpace(value) == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckHealthCodex(t *testing.T) {
+	valid := "good-token"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+valid {
+			http.Error(w, `{"error":"invalid_token"}`, http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer srv.Close()
+
+	exp := time.Now().Add(time.Hour).UTC()
+	store := &Store{File: File{AuthMode: ModeChatGPT, Tokens: Tokens{AccessToken: valid, ExpiresAt: &exp}}}
+	health, err := CheckHealth(context.Background(), store, HealthCheckConfig{URL: srv.URL})
+	if err != nil || !health.Valid || health.Backend != BackendCodex || !health.ExpiresAt.Equal(exp) {
+		t.Fatalf("CheckHealth = %+v, %v", health, err)
+	}
+
+	store.File.Tokens.AccessToken = "revoked-token"
+	health, err = CheckHealth(context.Background(), store, HealthCheckConfig{URL: srv.URL})
+	if err != nil || health.Valid || health.Error == "" {
+		t.Fatalf("rejected token: CheckHealth = %+v, %v", health, err)
+	}
+}
+
+func TestCheckHealthCodexBaseURLAndProfile(t *testing.T) {
+	var gotPath, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		w.Write([]byte(`{"models":[]}`))
+	}))
+	defer srv.Close()
+
+	store := &Store{File: File{
+		AuthMode: ModeAPIKey,
+		APIKey:   "sk-default",
+		Profiles: map[string]*File{"work": {AuthMode: ModeAPIKey, APIKey: "sk-work"}},
+	}}
+	health, err := CheckHealth(context.Background(), store, HealthCheckConfig{BaseURL: srv.URL + "/backend-api/codex/", AccountProfile: "work"})
+	if err != nil || !health.Valid {
+		t.Fatalf("CheckHealth = %+v, %v", health, err)
+	}
+	if gotPath != "/backend-api/codex/models" || gotAuth != "Bearer sk-work" {
+		t.Fatalf("checked %s with %q, want the base URL's models endpoint as the work profile", gotPath, gotAuth)
+	}
+
+	health, err = CheckHealth(context.Background(), store, HealthCheckConfig{BaseURL: srv.URL, AccountProfile: "missing"})
+	if err != nil || health.Valid || health.Error == "" {
+		t.Fatalf("unknown profile: CheckHealth = %+v, %v", health, err)
+	}
+}
+
+func TestCheckHealthAnthropic(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer oat-token" || r.Header.Get("anthropic-version") == "" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"input_tokens":1}`))
+	}))
+	defer srv.Close()
+
+	health, err := CheckHealth(context.Background(), nil, HealthCheckConfig{Backend: BackendAnthropic, Token: "oat-token", URL: srv.URL})
+	if err != nil || !health.Valid {
+		t.Fatalf("CheckHealth = %+v, %v", health, err)
+	}
+	health, err = CheckHealth(context.Background(), nil, HealthCheckConfig{Backend: BackendAnthropic, URL: srv.URL})
+	if err != nil || health.Valid || health.Error == "" {
+		t.Fatalf("no token: CheckHealth = %+v, %v", health, err)
+	}
+}
+
+func TestCheckHealthErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	store := &Store{File: File{AuthMode: ModeAPIKey, APIKey: "sk-test"}}
+
+	health, err := CheckHealth(context.Background(), store, HealthCheckConfig{URL: srv.URL})
+	if err == nil || health.Valid || health.Error == "" {
+		t.Errorf("server error: CheckHealth = %+v, %v", health, err)
+	}
+	if _, err := CheckHealth(context.Background(), store, HealthCheckConfig{URL: srv.URL + "/slow", Timeout: 20 * time.Millisecond}); err == nil {
+		t.Error("expected a timeout error")
+	}
+	if _, err := CheckHealth(context.Background(), store, HealthCheckConfig{Backend: "gemini"}); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+}
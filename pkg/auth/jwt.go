@@ -0,0 +1,393 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidJWT is wrapped by the errors ValidateJWT returns for tokens
+// that are malformed, badly signed, expired or not meant for this proxy.
+var ErrInvalidJWT = errors.New("invalid jwt")
+
+const (
+	// jwksCacheTTL is how long a fetched key set is used before it is
+	// fetched again.
+	jwksCacheTTL = 10 * time.Minute
+	// jwksMinRefetch limits refetches for tokens signed by unknown keys.
+	jwksMinRefetch = 30 * time.Second
+	// jwtLeeway allows for clock skew when checking exp and nbf.
+	jwtLeeway = 30 * time.Second
+	// jwksFetchTimeout bounds one key set fetch.
+	jwksFetchTimeout = 10 * time.Second
+	// jwksMaxBytes caps the size of a key set document.
+	jwksMaxBytes = 1 << 20
+)
+
+// jwksClient fetches key sets; fetches are also bounded by
+// jwksFetchTimeout.
+var jwksClient = &http.Client{Timeout: jwksFetchTimeout}
+
+// JWTConfig configures ValidateJWT. Tokens must be signed by a key from
+// JWKSURL; Issuer and Audience, when set, must match the iss and aud
+// claims.
+type JWTConfig struct {
+	JWKSURL  string
+	Issuer   string
+	Audience string
+}
+
+// Enabled reports whether JWT validation is configured.
+func (c JWTConfig) Enabled() bool {
+	return strings.TrimSpace(c.JWKSURL) != ""
+}
+
+// JWTClaims are the claims of a validated token that the proxy uses.
+type JWTClaims struct {
+	Subject   string
+	Email     string
+	Name      string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time // zero if the token has no exp
+	// Scopes come from the space-separated "scope" claim or the "scp"
+	// list.
+	Scopes []string
+}
+
+// HasScope reports whether the token grants scope.
+func (c *JWTClaims) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// ValidateJWT checks token's signature against the key set at
+// cfg.JWKSURL and its exp, nbf, iss and aud claims, and returns its
+// claims. RS256, RS384, RS512, ES256, ES384 and ES512 are accepted. Key
+// sets are cached for ten minutes, and fetched again early when a token
+// names a key the cached set lacks.
+func ValidateJWT(ctx context.Context, token string, cfg JWTConfig) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: not a compact JWS", ErrInvalidJWT)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: header: %v", ErrInvalidJWT, err)
+	}
+	hash, ok := jwtHashes[header.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported alg %q", ErrInvalidJWT, header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %v", ErrInvalidJWT, err)
+	}
+	keys, err := defaultJWKSCache.keys(ctx, cfg.JWKSURL, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+	verified := false
+	for _, key := range keys {
+		if verifySignature(header.Alg, key, hash, digest, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidJWT)
+	}
+
+	var raw struct {
+		Sub   string          `json:"sub"`
+		Email string          `json:"email"`
+		Name  string          `json:"name"`
+		Iss   string          `json:"iss"`
+		Aud   json.RawMessage `json:"aud"`
+		Exp   *float64        `json:"exp"`
+		Nbf   *float64        `json:"nbf"`
+		Scope string          `json:"scope"`
+		Scp   json.RawMessage `json:"scp"`
+	}
+	if err := decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("%w: claims: %v", ErrInvalidJWT, err)
+	}
+	claims := &JWTClaims{
+		Subject:  raw.Sub,
+		Email:    raw.Email,
+		Name:     raw.Name,
+		Issuer:   raw.Iss,
+		Audience: stringOrList(raw.Aud),
+		Scopes:   strings.Fields(raw.Scope),
+	}
+	for _, s := range stringOrList(raw.Scp) {
+		claims.Scopes = append(claims.Scopes, strings.Fields(s)...)
+	}
+
+	now := time.Now()
+	if raw.Exp != nil {
+		claims.ExpiresAt = time.Unix(int64(*raw.Exp), 0)
+		if now.After(claims.ExpiresAt.Add(jwtLeeway)) {
+			return nil, fmt.Errorf("%w: expired at %s", ErrInvalidJWT, claims.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+	}
+	if raw.Nbf != nil && now.Add(jwtLeeway).Before(time.Unix(int64(*raw.Nbf), 0)) {
+		return nil, fmt.Errorf("%w: not valid yet", ErrInvalidJWT)
+	}
+	if cfg.Issuer != "" && claims.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q", ErrInvalidJWT, claims.Issuer)
+	}
+	if cfg.Audience != "" && !slices.Contains(claims.Audience, cfg.Audience) {
+		return nil, fmt.Errorf("%w: audience %q", ErrInvalidJWT, claims.Audience)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidJWT)
+	}
+	return claims, nil
+}
+
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+func verifySignature(alg string, key crypto.PublicKey, hash crypto.Hash, digest, sig []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(k, hash, digest, sig) == nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	buf, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// stringOrList decodes a claim that may be a string or a list of strings.
+func stringOrList(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var one string
+	if json.Unmarshal(raw, &one) == nil {
+		if one == "" {
+			return nil
+		}
+		return []string{one}
+	}
+	var list []string
+	json.Unmarshal(raw, &list)
+	return list
+}
+
+// jwksCache holds the signing keys fetched from each JWKS URL. Fetches run
+// without the lock held, and concurrent callers share one fetch per URL.
+type jwksCache struct {
+	mu       sync.Mutex
+	sets     map[string]*jwkSet
+	inflight map[string]*jwksFetch
+}
+
+// jwksFetch is a fetch under way; set and err are valid once done is
+// closed.
+type jwksFetch struct {
+	done chan struct{}
+	set  *jwkSet
+	err  error
+}
+
+type jwkSet struct {
+	keys    map[string]crypto.PublicKey // by kid
+	fetched time.Time
+}
+
+var defaultJWKSCache = &jwksCache{}
+
+// keys returns the keys that may have signed a token with kid: the key
+// with that ID, or every key when kid is empty.
+func (c *jwksCache) keys(ctx context.Context, url, kid string) ([]crypto.PublicKey, error) {
+	c.mu.Lock()
+	set := c.sets[url]
+	c.mu.Unlock()
+	stale := set == nil || time.Since(set.fetched) > jwksCacheTTL
+	if !stale && kid != "" && set.keys[kid] == nil && time.Since(set.fetched) > jwksMinRefetch {
+		stale = true
+	}
+	if stale {
+		fresh, err := c.refresh(ctx, url)
+		if err != nil {
+			if set == nil {
+				return nil, err
+			}
+			// Keep using the cached set while the provider is unreachable.
+		} else {
+			set = fresh
+		}
+	}
+	if kid != "" {
+		if key := set.keys[kid]; key != nil {
+			return []crypto.PublicKey{key}, nil
+		}
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidJWT, kid)
+	}
+	keys := make([]crypto.PublicKey, 0, len(set.keys))
+	for _, key := range set.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// refresh fetches the key set at url and caches it, or waits for a fetch
+// of url already under way.
+func (c *jwksCache) refresh(ctx context.Context, url string) (*jwkSet, error) {
+	c.mu.Lock()
+	if f, ok := c.inflight[url]; ok {
+		c.mu.Unlock()
+		select {
+		case <-f.done:
+			return f.set, f.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	f := &jwksFetch{done: make(chan struct{})}
+	if c.inflight == nil {
+		c.inflight = map[string]*jwksFetch{}
+	}
+	c.inflight[url] = f
+	c.mu.Unlock()
+
+	// Other callers wait on this fetch, so it must not end when this
+	// caller's request is cancelled.
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jwksFetchTimeout)
+	f.set, f.err = c.fetch(fetchCtx, url)
+	cancel()
+
+	c.mu.Lock()
+	delete(c.inflight, url)
+	if f.err == nil {
+		if c.sets == nil {
+			c.sets = map[string]*jwkSet{}
+		}
+		c.sets[url] = f.set
+	}
+	c.mu.Unlock()
+	close(f.done)
+	return f.set, f.err
+}
+
+func (c *jwksCache) fetch(ctx context.Context, url string) (*jwkSet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build jwks request: %w", err)
+	}
+	resp, err := jwksClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch jwks: %s", resp.Status)
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, jwksMaxBytes)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode jwks: %w", err)
+	}
+	set := &jwkSet{keys: map[string]crypto.PublicKey{}, fetched: time.Now()}
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue // keys of other types may share the set
+		}
+		id := k.Kid
+		if id == "" {
+			id = fmt.Sprintf("#%d", i)
+		}
+		set.keys[id] = key
+	}
+	return set, nil
+}
+
+// jwk is a JSON Web Key (RFC 7517) holding an RSA or EC public key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func b64(buf []byte) string { return base64.RawURLEncoding.EncodeToString(buf) }
+
+// signRS256 returns a compact JWS of claims signed with key.
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	sum := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + b64(sig)
+}
+
+// serveJWKS serves keys as a JWKS document and returns its URL.
+func serveJWKS(t *testing.T, keys ...map[string]string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func rsaJWK(key *rsa.PrivateKey, kid string) map[string]string {
+	return map[string]string{"kty": "RSA", "kid": kid, "use": "sig", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func TestValidateJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := JWTConfig{JWKSURL: serveJWKS(t, rsaJWK(key, "k1")), Issuer: "https://idp.example.com", Audience: "godex"}
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := func(extra map[string]any) map[string]any {
+		c := map[string]any{"sub": "user-1", "email": "a@example.com", "iss": cfg.Issuer, "aud": []string{"godex", "other"}, "exp": exp, "scope": "models:read responses:write"}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+
+	got, err := ValidateJWT(context.Background(), signRS256(t, key, "k1", claims(nil)), cfg)
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+	if got.Subject != "user-1" || got.Email != "a@example.com" || got.ExpiresAt.Unix() != exp || !got.HasScope("responses:write") {
+		t.Errorf("claims = %+v", got)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", signRS256(t, key, "k1", claims(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}))},
+		{"issuer", signRS256(t, key, "k1", claims(map[string]any{"iss": "https://evil.example.com"}))},
+		{"audience", signRS256(t, key, "k1", claims(map[string]any{"aud": "someone-else"}))},
+		{"no sub", signRS256(t, key, "k1", claims(map[string]any{"sub": ""}))},
+		{"wrong key", signRS256(t, other, "k1", claims(nil))},
+		{"unknown kid", signRS256(t, key, "k2", claims(nil))},
+		{"alg none", b64([]byte(`{"alg":"none"}`)) + "." + b64([]byte(`{"sub":"user-1"}`)) + "."},
+		{"not a jwt", "sk-plain-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ValidateJWT(context.Background(), tt.token, cfg); !errors.Is(err, ErrInvalidJWT) {
+				t.Errorf("err = %v, want ErrInvalidJWT", err)
+			}
+		})
+	}
+}
+
+func TestValidateJWTES256AndScp(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := serveJWKS(t, map[string]string{"kty": "EC", "crv": "P-256", "x": b64(key.X.FillBytes(make([]byte, 32))), "y": b64(key.Y.FillBytes(make([]byte, 32)))})
+
+	header := b64([]byte(`{"alg":"ES256"}`))
+	payload := b64([]byte(`{"sub":"svc","name":"Build bot","scp":["a","b"]}`))
+	sum := sha256.Sum256([]byte(header + "." + payload))
+	r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	got, err := ValidateJWT(context.Background(), header+"."+payload+"."+b64(sig), JWTConfig{JWKSURL: url})
+	if err != nil {
+		t.Fatalf("ValidateJWT: %v", err)
+	}
+	if got.Name != "Build bot" || len(got.Scopes) != 2 || !got.ExpiresAt.IsZero() {
+		t.Errorf("claims = %+v", got)
+	}
+}
+
+func TestJWKSCacheSharesFetch(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{rsaJWK(key, "k1")}})
+	}))
+	defer srv.Close()
+
+	cache := &jwksCache{}
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = cache.keys(context.Background(), srv.URL, "k1")
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("caller %d: %v", i, err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("concurrent callers made %d fetches, want 1", n)
+	}
+}
+
+func TestJWKSCacheFetchOutlivesCaller(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{rsaJWK(key, "k1")}})
+	}))
+	defer srv.Close()
+
+	cache := &jwksCache{}
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := cache.keys(ctx, srv.URL, "k1")
+		first <- err
+	}()
+	<-started
+	second := make(chan error, 1)
+	go func() {
+		_, err := cache.keys(context.Background(), srv.URL, "k1")
+		second <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	// The caller that started the fetch goes away; the waiter must not
+	// fail with it.
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-second; err != nil {
+		t.Fatalf("waiting caller: %v", err)
+	}
+	<-first
+}
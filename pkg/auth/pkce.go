@@ -0,0 +1,295 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultPKCETimeout bounds how long StartPKCEFlow waits for the browser
+// sign-in when PKCEConfig.Timeout is zero.
+const DefaultPKCETimeout = 5 * time.Minute
+
+// pkceCallbackPath is where the local server receives the authorization
+// code.
+const pkceCallbackPath = "/auth/callback"
+
+// PKCEConfig configures StartPKCEFlow. The first fields mirror the auth
+// section of the config file; empty ones use the same defaults as Refresh,
+// and an empty Path uses DefaultPath.
+type PKCEConfig struct {
+	Path       string
+	RefreshURL string // token endpoint; the authorize endpoint is its sibling
+	ClientID   string
+	Scope      string
+
+	// OpenBrowser opens the sign-in page (default: OpenBrowser).
+	OpenBrowser func(authorizeURL string) error
+	HTTPClient  *http.Client
+	Timeout     time.Duration
+}
+
+// StartPKCEFlow signs in with the OAuth authorization code flow and PKCE:
+// it serves the redirect on a random localhost port, opens the browser at
+// the authorize endpoint, exchanges the returned code at the token
+// endpoint and saves the tokens to cfg.Path as a ChatGPT auth file.
+func StartPKCEFlow(cfg PKCEConfig) (*Store, error) {
+	cfg = cfg.withDefaults()
+	authPath := strings.TrimSpace(cfg.Path)
+	if authPath == "" {
+		var err error
+		if authPath, err = DefaultPath(); err != nil {
+			return nil, err
+		}
+	}
+	authorizeURL, err := authorizeEndpoint(cfg.RefreshURL)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	state, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("start callback server: %w", err)
+	}
+	redirectURI := fmt.Sprintf("http://localhost:%d%s", ln.Addr().(*net.TCPAddr).Port, pkceCallbackPath)
+	codes := make(chan callbackResult, 1)
+	srv := &http.Server{Handler: callbackHandler(state, codes), ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {cfg.Scope},
+		"code_challenge":        {codeChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+		"state":                 {state},
+	}
+	if err := cfg.OpenBrowser(authorizeURL + "?" + q.Encode()); err != nil {
+		return nil, fmt.Errorf("open browser: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+	var res callbackResult
+	select {
+	case res = <-codes:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("no sign-in callback within %s", cfg.Timeout)
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	tokens, err := exchangeCode(ctx, cfg, res.code, verifier, redirectURI)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(authPath), 0o700); err != nil {
+		return nil, fmt.Errorf("create auth dir: %w", err)
+	}
+	store := &Store{path: authPath, File: File{AuthMode: ModeChatGPT, Tokens: tokens}}
+	// The sign-in replaces the default account only; named accounts added
+	// with AddProfile are kept.
+	if existing, err := Load(authPath); err == nil {
+		store.File.Profiles = existing.File.Profiles
+	}
+	if err := store.Save(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (c PKCEConfig) withDefaults() PKCEConfig {
+	if strings.TrimSpace(c.RefreshURL) == "" {
+		c.RefreshURL = refreshURL
+	}
+	if strings.TrimSpace(c.ClientID) == "" {
+		c.ClientID = refreshClientID
+	}
+	if strings.TrimSpace(c.Scope) == "" {
+		c.Scope = refreshScope
+	}
+	if c.OpenBrowser == nil {
+		c.OpenBrowser = OpenBrowser
+	}
+	if c.HTTPClient == nil {
+		c.HTTPClient = http.DefaultClient
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultPKCETimeout
+	}
+	return c
+}
+
+// OpenBrowser opens target in the user's default browser.
+func OpenBrowser(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Start()
+}
+
+// authorizeEndpoint returns the authorize endpoint next to the token
+// endpoint, e.g. /oauth/authorize for /oauth/token.
+func authorizeEndpoint(tokenURL string) (string, error) {
+	u, err := url.Parse(tokenURL)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid refresh url %q", tokenURL)
+	}
+	u.Path = path.Join(path.Dir(u.Path), "authorize")
+	u.RawQuery = ""
+	return u.String(), nil
+}
+
+// randomToken returns n random bytes, base64url encoded without padding.
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// codeChallenge is the S256 challenge for verifier (RFC 7636).
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// callbackHandler receives the authorization redirect and sends its code,
+// or the error it reports, on results. Requests with the wrong state are
+// rejected without ending the flow.
+func callbackHandler(state string, results chan<- callbackResult) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(pkceCallbackPath, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") != state {
+			http.Error(w, "state mismatch", http.StatusBadRequest)
+			return
+		}
+		var res callbackResult
+		switch {
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("authorization denied: %s", strings.TrimSpace(q.Get("error")+" "+q.Get("error_description")))
+			http.Error(w, "Sign-in failed; return to the terminal.", http.StatusBadRequest)
+		case q.Get("code") == "":
+			res.err = errors.New("authorization callback missing code")
+			http.Error(w, "Sign-in failed; return to the terminal.", http.StatusBadRequest)
+		default:
+			res.code = q.Get("code")
+			fmt.Fprintln(w, "Signed in to godex. You can close this window.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})
+	return mux
+}
+
+// exchangeCode trades an authorization code for tokens at the token
+// endpoint.
+func exchangeCode(ctx context.Context, cfg PKCEConfig, code, verifier, redirectURI string) (Tokens, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {cfg.ClientID},
+		"code":          {code},
+		"code_verifier": {verifier},
+		"redirect_uri":  {redirectURI},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.RefreshURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Tokens{}, fmt.Errorf("build token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return Tokens{}, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tr struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		IDToken      string `json:"id_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		Error        string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return Tokens{}, fmt.Errorf("decode token response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail := strings.TrimSpace(tr.Error)
+		if detail == "" {
+			detail = resp.Status
+		}
+		return Tokens{}, fmt.Errorf("token exchange rejected: %s", detail)
+	}
+	if tr.AccessToken == "" {
+		return Tokens{}, errors.New("token response missing access_token")
+	}
+	return Tokens{
+		AccessToken:  tr.AccessToken,
+		RefreshToken: tr.RefreshToken,
+		IDToken:      IDTokenV1{RawJWT: tr.IDToken, ChatGPTAccountID: jwtAccountID(tr.IDToken)},
+		ExpiresAt:    expiresIn(tr.ExpiresIn),
+	}, nil
+}
+
+// jwtAccountID returns the ChatGPT account ID claimed by an ID token, or ""
+// if it has none. The token is not verified; it came straight from the
+// token endpoint.
+func jwtAccountID(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Auth struct {
+			AccountID string `json:"chatgpt_account_id"`
+		} `json:"https://api.openai.com/auth"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	return claims.Auth.AccountID
+}
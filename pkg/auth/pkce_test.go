@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeBrowser returns an OpenBrowser hook that follows the authorize URL's
+// redirect with the given query, as the provider would after sign-in, and
+// records the authorize URL.
+func fakeBrowser(t *testing.T, authorize *url.URL, query url.Values) func(string) error {
+	return func(target string) error {
+		u, err := url.Parse(target)
+		if err != nil {
+			return err
+		}
+		*authorize = *u
+		q := u.Query()
+		if query.Get("state") == "" {
+			query.Set("state", q.Get("state"))
+		}
+		go func() {
+			resp, err := http.Get(q.Get("redirect_uri") + "?" + query.Encode())
+			if err != nil {
+				t.Errorf("callback: %v", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+		return nil
+	}
+}
+
+func TestStartPKCEFlow(t *testing.T) {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"https://api.openai.com/auth":{"chatgpt_account_id":"acct-1"}}`))
+	var form url.Values
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token" {
+			http.NotFound(w, r)
+			return
+		}
+		r.ParseForm()
+		form = r.PostForm
+		w.Write([]byte(`{"access_token":"at","refresh_token":"rt","id_token":"h.` + claims + `.s"}`))
+	}))
+	defer tokenSrv.Close()
+
+	var authorize url.URL
+	path := filepath.Join(t.TempDir(), "codex", "auth.json")
+	os.MkdirAll(filepath.Dir(path), 0o700)
+	os.WriteFile(path, []byte(`{"auth_mode":"apikey","OPENAI_API_KEY":"sk-old","profiles":{"work":{"auth_mode":"apikey","OPENAI_API_KEY":"sk-work"}}}`), 0o600)
+	store, err := StartPKCEFlow(PKCEConfig{
+		Path:        path,
+		RefreshURL:  tokenSrv.URL + "/oauth/token",
+		ClientID:    "client-1",
+		Scope:       "openid offline_access",
+		OpenBrowser: fakeBrowser(t, &authorize, url.Values{"code": {"code-1"}}),
+		Timeout:     5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("StartPKCEFlow: %v", err)
+	}
+
+	q := authorize.Query()
+	if authorize.Path != "/oauth/authorize" || q.Get("client_id") != "client-1" || q.Get("scope") != "openid offline_access" || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("authorize URL = %s", authorize.String())
+	}
+	if form.Get("grant_type") != "authorization_code" || form.Get("code") != "code-1" || form.Get("redirect_uri") != q.Get("redirect_uri") {
+		t.Errorf("token request = %v", form)
+	}
+	if got := codeChallenge(form.Get("code_verifier")); got != q.Get("code_challenge") {
+		t.Errorf("code_verifier does not match code_challenge")
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("load saved auth: %v", err)
+	}
+	if tok, _ := loaded.AuthorizationToken(); tok != "at" || loaded.RefreshToken() != "rt" || !loaded.IsChatGPT() {
+		t.Errorf("saved auth = %+v", loaded.File)
+	}
+	if tok, err := loaded.TokenForProfile("work"); err != nil || tok != "sk-work" {
+		t.Errorf("sign-in dropped the work profile: %q, %v", tok, err)
+	}
+	if got := store.AccountID(); got != "acct-1" {
+		t.Errorf("AccountID = %q, want acct-1", got)
+	}
+}
+
+func TestStartPKCEFlowDenied(t *testing.T) {
+	var authorize url.URL
+	_, err := StartPKCEFlow(PKCEConfig{
+		Path:        filepath.Join(t.TempDir(), "auth.json"),
+		RefreshURL:  "http://127.0.0.1:1/oauth/token",
+		OpenBrowser: fakeBrowser(t, &authorize, url.Values{"error": {"access_denied"}}),
+		Timeout:     5 * time.Second,
+	})
+	if err == nil || !strings.Contains(err.Error(), "access_denied") {
+		t.Fatalf("err = %v, want access_denied", err)
+	}
+}
+
+func TestAuthorizeEndpoint(t *testing.T) {
+	got, err := authorizeEndpoint("https://auth.openai.com/oauth/token")
+	if err != nil || got != "https://auth.openai.com/oauth/authorize" {
+		t.Errorf("authorizeEndpoint = %q, %v", got, err)
+	}
+	if _, err := authorizeEndpoint("not a url"); err == nil {
+		t.Error("authorizeEndpoint accepted a relative URL")
+	}
+}
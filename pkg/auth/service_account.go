@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrInvalidServiceAccount is wrapped by the errors ValidateServiceAccount
+// returns for tokens that are malformed or not signed with the secret.
+var ErrInvalidServiceAccount = errors.New("invalid service account token")
+
+// serviceAccountPrefix marks service account tokens.
+const serviceAccountPrefix = "gxsa_"
+
+// minServiceAccountSecret is the shortest signing secret accepted.
+const minServiceAccountSecret = 16
+
+// Service account scopes, one per class of proxy endpoint. A credential
+// without scopes may call every endpoint.
+const (
+	// ScopeRead allows the read-only endpoints: models, backends, usage
+	// and stats.
+	ScopeRead = "read"
+	// ScopeChat allows the endpoints that run the model: responses, chat
+	// completions and completions.
+	ScopeChat = "chat"
+)
+
+// ServiceAccountCredential is a machine credential for CI and other
+// automation. Secret is the bearer token; it is only known when issued.
+type ServiceAccountCredential struct {
+	ID     string
+	Secret string
+	Label  string
+	Scopes []string
+}
+
+type serviceAccountClaims struct {
+	ID       string   `json:"id"`
+	Label    string   `json:"label"`
+	Scopes   []string `json:"scopes,omitempty"`
+	IssuedAt int64    `json:"iat"`
+}
+
+// IssueServiceAccount creates a service account credential signed with
+// secret. Its token is "gxsa_" then the base64url JSON claims, a ".", and
+// their HMAC-SHA256 under secret. It never expires; rotating secret
+// invalidates every credential signed with it.
+func IssueServiceAccount(label string, scopes []string, secret []byte) (ServiceAccountCredential, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return ServiceAccountCredential{}, errors.New("label is required")
+	}
+	if len(secret) < minServiceAccountSecret {
+		return ServiceAccountCredential{}, errors.New("service account secret must be at least 16 bytes")
+	}
+	scopes = normalizeScopes(scopes)
+	for _, scope := range scopes {
+		if scope != ScopeRead && scope != ScopeChat {
+			return ServiceAccountCredential{}, fmt.Errorf("unknown scope %q (use %s or %s)", scope, ScopeRead, ScopeChat)
+		}
+	}
+	id, err := randomToken(12)
+	if err != nil {
+		return ServiceAccountCredential{}, err
+	}
+	claims := serviceAccountClaims{
+		ID:       "sa_" + id,
+		Label:    label,
+		Scopes:   scopes,
+		IssuedAt: time.Now().Unix(),
+	}
+	buf, err := json.Marshal(claims)
+	if err != nil {
+		return ServiceAccountCredential{}, fmt.Errorf("encode service account claims: %w", err)
+	}
+	body := serviceAccountPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return ServiceAccountCredential{
+		ID:     claims.ID,
+		Secret: body + "." + base64.RawURLEncoding.EncodeToString(signServiceAccount(body, secret)),
+		Label:  claims.Label,
+		Scopes: claims.Scopes,
+	}, nil
+}
+
+// IsServiceAccountToken reports whether token looks like a service account
+// token, without checking it.
+func IsServiceAccountToken(token string) bool {
+	return strings.HasPrefix(token, serviceAccountPrefix)
+}
+
+// ValidateServiceAccount checks a token from IssueServiceAccount against
+// secret and returns its credential.
+func ValidateServiceAccount(token string, secret []byte) (ServiceAccountCredential, error) {
+	if len(secret) == 0 {
+		return ServiceAccountCredential{}, errors.New("service account secret is not configured")
+	}
+	if !IsServiceAccountToken(token) {
+		return ServiceAccountCredential{}, fmt.Errorf("%w: missing %s prefix", ErrInvalidServiceAccount, serviceAccountPrefix)
+	}
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return ServiceAccountCredential{}, fmt.Errorf("%w: missing signature", ErrInvalidServiceAccount)
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, signServiceAccount(body, secret)) {
+		return ServiceAccountCredential{}, fmt.Errorf("%w: bad signature", ErrInvalidServiceAccount)
+	}
+	var claims serviceAccountClaims
+	if err := decodeSegment(strings.TrimPrefix(body, serviceAccountPrefix), &claims); err != nil || claims.ID == "" {
+		return ServiceAccountCredential{}, fmt.Errorf("%w: bad claims", ErrInvalidServiceAccount)
+	}
+	return ServiceAccountCredential{
+		ID:     claims.ID,
+		Secret: token,
+		Label:  claims.Label,
+		Scopes: claims.Scopes,
+	}, nil
+}
+
+// HasScope reports whether the credential lists scope.
+func (c ServiceAccountCredential) HasScope(scope string) bool {
+	return slices.Contains(c.Scopes, scope)
+}
+
+// normalizeScopes trims scopes, splits comma-separated ones and drops
+// blanks and duplicates.
+func normalizeScopes(scopes []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, s := range scopes {
+		for _, part := range strings.Split(s, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" || seen[part] {
+				continue
+			}
+			seen[part] = true
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func signServiceAccount(body string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
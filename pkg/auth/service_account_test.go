@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestServiceAccount(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	cred, err := IssueServiceAccount("ci-bot", []string{"read", "chat,read", " "}, secret)
+	if err != nil {
+		t.Fatalf("IssueServiceAccount: %v", err)
+	}
+	if !strings.HasPrefix(cred.Secret, serviceAccountPrefix) || cred.ID == "" || cred.Label != "ci-bot" {
+		t.Fatalf("credential = %+v", cred)
+	}
+	if len(cred.Scopes) != 2 || !cred.HasScope("read") || !cred.HasScope("chat") {
+		t.Errorf("Scopes = %v, want [read chat]", cred.Scopes)
+	}
+
+	got, err := ValidateServiceAccount(cred.Secret, secret)
+	if err != nil {
+		t.Fatalf("ValidateServiceAccount: %v", err)
+	}
+	if got.ID != cred.ID || got.Label != "ci-bot" || !got.HasScope("chat") {
+		t.Errorf("validated = %+v, want %+v", got, cred)
+	}
+
+	if _, err := ValidateServiceAccount(cred.Secret, []byte("another-secret-0123")); !errors.Is(err, ErrInvalidServiceAccount) {
+		t.Errorf("wrong secret: err = %v", err)
+	}
+	if _, err := ValidateServiceAccount(cred.Secret+"x", secret); !errors.Is(err, ErrInvalidServiceAccount) {
+		t.Errorf("tampered signature: err = %v", err)
+	}
+	if _, err := ValidateServiceAccount("gx_plainkey", secret); !errors.Is(err, ErrInvalidServiceAccount) {
+		t.Errorf("plain key: err = %v", err)
+	}
+	if _, err := IssueServiceAccount("ci-bot", nil, []byte("short")); err == nil {
+		t.Error("IssueServiceAccount accepted a short secret")
+	}
+	if _, err := IssueServiceAccount(" ", nil, secret); err == nil {
+		t.Error("IssueServiceAccount accepted an empty label")
+	}
+	if _, err := IssueServiceAccount("ci-bot", []string{"admin"}, secret); err == nil {
+		t.Error("IssueServiceAccount accepted an unknown scope")
+	}
+}
@@ -0,0 +1,187 @@
+// Package circuitbreaker implements a small closed/open/half-open circuit
+// breaker used to stop routing traffic to failing backends.
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the current position of a breaker.
+type State int
+
+const (
+	// Closed lets all requests through and counts consecutive failures.
+	Closed State = iota
+	// Open rejects requests until the recovery window elapses.
+	Open
+	// HalfOpen admits a single probe request to test recovery.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Config configures a Breaker.
+type Config struct {
+	// FailureThreshold is the number of consecutive failures that trips the
+	// breaker. Values <= 0 default to 5.
+	FailureThreshold int
+	// RecoveryWindow is how long the breaker stays open before admitting a
+	// probe. Values <= 0 default to DefaultRecoveryWindow.
+	RecoveryWindow time.Duration
+	// OnStateChange, if set, is called after every state transition, outside
+	// the breaker's lock. Open to HalfOpen is reported when Allow admits the
+	// probe, not when the window elapses.
+	OnStateChange func(from, to State)
+}
+
+// DefaultRecoveryWindow is the recovery window used when Config leaves it
+// unset.
+const DefaultRecoveryWindow = 30 * time.Second
+
+// Breaker tracks consecutive failures for one backend.
+type Breaker struct {
+	mu        sync.Mutex
+	cfg       Config
+	state     State
+	failures  int
+	openedAt  time.Time
+	probeAt   time.Time
+	probing   bool
+	lastError string
+	now       func() time.Time
+}
+
+// Snapshot is a point-in-time view of a breaker for status endpoints.
+type Snapshot struct {
+	State     string     `json:"state"`
+	Failures  int        `json:"failures"`
+	OpenedAt  *time.Time `json:"opened_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// New creates a closed breaker.
+func New(cfg Config) *Breaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.RecoveryWindow <= 0 {
+		cfg.RecoveryWindow = DefaultRecoveryWindow
+	}
+	return &Breaker{cfg: cfg, now: time.Now}
+}
+
+// Allow reports whether a request may be sent. In the half-open state only
+// one probe is admitted at a time; a probe that never reports back is
+// abandoned after another recovery window so the breaker cannot wedge.
+func (b *Breaker) Allow() bool {
+	allowed, halfOpened := b.allow()
+	if halfOpened {
+		b.notify(Open, HalfOpen)
+	}
+	return allowed
+}
+
+// allow is Allow under the lock; halfOpened reports an Open to HalfOpen
+// transition.
+func (b *Breaker) allow() (allowed, halfOpened bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	switch b.state {
+	case Closed:
+		return true, false
+	case Open:
+		if now.Sub(b.openedAt) < b.cfg.RecoveryWindow {
+			return false, false
+		}
+		b.state = HalfOpen
+		b.probing = true
+		b.probeAt = now
+		return true, true
+	case HalfOpen:
+		if b.probing && now.Sub(b.probeAt) < b.cfg.RecoveryWindow {
+			return false, false
+		}
+		b.probing = true
+		b.probeAt = now
+		return true, false
+	}
+	return true, false
+}
+
+// Success records a successful request and closes the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	from := b.state
+	b.state = Closed
+	b.failures = 0
+	b.probing = false
+	b.lastError = ""
+	b.mu.Unlock()
+	if from != Closed {
+		b.notify(from, Closed)
+	}
+}
+
+// Failure records a failed request. A failed half-open probe reopens the
+// breaker immediately; otherwise it trips once the threshold is reached.
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	from := b.state
+	b.failures++
+	if err != nil {
+		b.lastError = err.Error()
+	}
+	if b.state == HalfOpen || b.failures >= b.cfg.FailureThreshold {
+		b.state = Open
+		b.openedAt = b.now()
+		b.probing = false
+	}
+	to := b.state
+	b.mu.Unlock()
+	if from != to {
+		b.notify(from, to)
+	}
+}
+
+func (b *Breaker) notify(from, to State) {
+	if b.cfg.OnStateChange != nil {
+		b.cfg.OnStateChange(from, to)
+	}
+}
+
+// State returns the current state, moving Open to HalfOpen if the recovery
+// window has elapsed.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.cfg.RecoveryWindow {
+		return HalfOpen
+	}
+	return b.state
+}
+
+// Snapshot returns the breaker's state for reporting.
+func (b *Breaker) Snapshot() Snapshot {
+	state := b.State()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	snap := Snapshot{State: state.String(), Failures: b.failures, LastError: b.lastError}
+	if state != Closed && !b.openedAt.IsZero() {
+		opened := b.openedAt
+		snap.OpenedAt = &opened
+	}
+	return snap
+}
@@ -0,0 +1,118 @@
+package circuitbreaker
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestBreaker(threshold int, window time.Duration) (*Breaker, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	b := New(Config{FailureThreshold: threshold, RecoveryWindow: window})
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBreakerTripsAfterThreshold(t *testing.T) {
+	b, _ := newTestBreaker(3, time.Minute)
+	for i := 0; i < 2; i++ {
+		b.Failure(errors.New("boom"))
+		if b.State() != Closed || !b.Allow() {
+			t.Fatalf("breaker tripped early after %d failures", i+1)
+		}
+	}
+	b.Failure(errors.New("boom"))
+	if b.State() != Open {
+		t.Fatalf("expected open, got %s", b.State())
+	}
+	if b.Allow() {
+		t.Fatal("open breaker should reject requests")
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b, _ := newTestBreaker(2, time.Minute)
+	b.Failure(nil)
+	b.Success()
+	b.Failure(nil)
+	if b.State() != Closed {
+		t.Fatalf("expected closed after success reset, got %s", b.State())
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+	b.Failure(errors.New("down"))
+	if b.Allow() {
+		t.Fatal("expected rejection while open")
+	}
+
+	*now = now.Add(time.Minute)
+	if b.State() != HalfOpen {
+		t.Fatalf("expected half-open after recovery window, got %s", b.State())
+	}
+	if !b.Allow() {
+		t.Fatal("expected a probe to be admitted")
+	}
+	if b.Allow() {
+		t.Fatal("only one probe should be admitted while half-open")
+	}
+
+	// Failed probe reopens.
+	b.Failure(errors.New("still down"))
+	if b.State() != Open || b.Allow() {
+		t.Fatalf("expected failed probe to reopen, got %s", b.State())
+	}
+	if got := b.Snapshot().LastError; got != "still down" {
+		t.Fatalf("LastError = %q", got)
+	}
+
+	// Successful probe closes.
+	*now = now.Add(time.Minute)
+	if !b.Allow() {
+		t.Fatal("expected second probe to be admitted")
+	}
+	b.Success()
+	if b.State() != Closed || !b.Allow() {
+		t.Fatalf("expected closed after successful probe, got %s", b.State())
+	}
+}
+
+func TestBreakerAbandonedProbeIsRetried(t *testing.T) {
+	b, now := newTestBreaker(1, time.Minute)
+	b.Failure(nil)
+	*now = now.Add(time.Minute)
+	if !b.Allow() {
+		t.Fatal("expected probe")
+	}
+	*now = now.Add(time.Minute)
+	if !b.Allow() {
+		t.Fatal("expected abandoned probe to be replaced after a recovery window")
+	}
+}
+
+func TestBreakerReportsStateChanges(t *testing.T) {
+	var changes []string
+	now := time.Unix(1700000000, 0)
+	b := New(Config{FailureThreshold: 2, RecoveryWindow: time.Minute, OnStateChange: func(from, to State) {
+		changes = append(changes, from.String()+">"+to.String())
+	}})
+	b.now = func() time.Time { return now }
+
+	b.Failure(nil)
+	b.Failure(nil)
+	b.Failure(nil) // already open: no transition
+	now = now.Add(time.Minute)
+	b.Allow()
+	b.Failure(nil)
+	now = now.Add(time.Minute)
+	b.Allow()
+	b.Success()
+	b.Success() // already closed: no transition
+
+	got := strings.Join(changes, " ")
+	if got != "closed>open open>half-open half-open>open open>half-open half-open>closed" {
+		t.Fatalf("unexpected transitions %q", got)
+	}
+}
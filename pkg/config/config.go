@@ -1,13 +1,13 @@
 package config
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
@@ -32,11 +32,15 @@ type ExecConfig struct {
 }
 
 type ClientConfig struct {
-	BaseURL    string        `yaml:"base_url"`
-	Originator string        `yaml:"originator"`
-	UserAgent  string        `yaml:"user_agent"`
-	RetryMax   int           `yaml:"retry_max"`
-	RetryDelay time.Duration `yaml:"retry_delay"`
+	BaseURL       string        `yaml:"base_url"`
+	Originator    string        `yaml:"originator"`
+	UserAgent     string        `yaml:"user_agent"`
+	RetryMax      int           `yaml:"retry_max"`
+	RetryDelay    time.Duration `yaml:"retry_delay"`
+	MaxRetryDelay time.Duration `yaml:"max_retry_delay"`
+	// AccountProfile selects a named account from the auth file (see
+	// godex auth add-profile); empty uses its default account.
+	AccountProfile string `yaml:"account_profile"`
 }
 
 type AuthConfig struct {
@@ -52,42 +56,114 @@ type ModelConfig struct {
 }
 
 type ProxyConfig struct {
-	Listen            string         `yaml:"listen"`
-	APIKey            string         `yaml:"api_key"`
-	AllowAnyKey       bool           `yaml:"allow_any_key"`
-	AllowRefresh      bool           `yaml:"allow_refresh"`
-	Model             string         `yaml:"model"`
-	Models            []ModelConfig  `yaml:"models"`
-	BaseURL           string         `yaml:"base_url"`
-	Originator        string         `yaml:"originator"`
-	UserAgent         string         `yaml:"user_agent"`
-	AuthPath          string         `yaml:"auth_path"`
-	CacheTTL          time.Duration  `yaml:"cache_ttl"`
-	LogLevel          string         `yaml:"log_level"`
-	LogRequests       bool           `yaml:"log_requests"`
-	KeysPath          string         `yaml:"keys_path"`
-	DefaultRate       string         `yaml:"default_rate"`
-	DefaultBurst      int            `yaml:"default_burst"`
-	DefaultQuota      int64          `yaml:"default_quota_tokens"`
-	StatsPath         string         `yaml:"stats_path"`
-	StatsSummary      string         `yaml:"stats_summary"`
-	StatsMaxBytes     int64          `yaml:"stats_max_bytes"`
-	StatsBackups      int            `yaml:"stats_max_backups"`
-	EventsPath        string         `yaml:"events_path"`
-	EventsMax         int64          `yaml:"events_max_bytes"`
-	EventsBackups     int            `yaml:"events_max_backups"`
-	AuditPath         string         `yaml:"audit_path"`
-	AuditMaxBytes     int64          `yaml:"audit_max_bytes"`
-	AuditBackups      int            `yaml:"audit_max_backups"`
-	TracePath         string         `yaml:"trace_path"`
-	TraceMaxBytes     int64          `yaml:"trace_max_bytes"`
-	TraceBackups      int            `yaml:"trace_max_backups"`
-	UpstreamAuditPath string         `yaml:"upstream_audit_path"`
-	MeterWindow       time.Duration  `yaml:"meter_window"`
-	AdminSocket       string         `yaml:"admin_socket"`
-	Payments          PaymentsConfig `yaml:"payments"`
-	Backends          BackendsConfig `yaml:"backends"`
-	Metrics           MetricsConfig  `yaml:"metrics"`
+	Listen            string        `yaml:"listen"`
+	TLSCertFile       string        `yaml:"tls_cert_file"`
+	TLSKeyFile        string        `yaml:"tls_key_file"`
+	APIKey            string        `yaml:"api_key"`
+	AllowAnyKey       bool          `yaml:"allow_any_key"`
+	AllowRefresh      bool          `yaml:"allow_refresh"`
+	Model             string        `yaml:"model"`
+	Models            []ModelConfig `yaml:"models"`
+	BaseURL           string        `yaml:"base_url"`
+	Originator        string        `yaml:"originator"`
+	UserAgent         string        `yaml:"user_agent"`
+	AuthPath          string        `yaml:"auth_path"`
+	CacheTTL          time.Duration `yaml:"cache_ttl"`
+	LogLevel          string        `yaml:"log_level"`
+	LogRequests       bool          `yaml:"log_requests"`
+	KeysPath          string        `yaml:"keys_path"`
+	DefaultRate       string        `yaml:"default_rate"`
+	DefaultBurst      int           `yaml:"default_burst"`
+	DefaultQuota      int64         `yaml:"default_quota_tokens"`
+	StatsPath         string        `yaml:"stats_path"`
+	StatsSummary      string        `yaml:"stats_summary"`
+	StatsMaxBytes     int64         `yaml:"stats_max_bytes"`
+	StatsBackups      int           `yaml:"stats_max_backups"`
+	EventsPath        string        `yaml:"events_path"`
+	EventsMax         int64         `yaml:"events_max_bytes"`
+	EventsBackups     int           `yaml:"events_max_backups"`
+	AuditPath         string        `yaml:"audit_path"`
+	AuditMaxBytes     int64         `yaml:"audit_max_bytes"`
+	AuditBackups      int           `yaml:"audit_max_backups"`
+	TracePath         string        `yaml:"trace_path"`
+	TraceMaxBytes     int64         `yaml:"trace_max_bytes"`
+	TraceBackups      int           `yaml:"trace_max_backups"`
+	UpstreamAuditPath string        `yaml:"upstream_audit_path"`
+	MeterWindow       time.Duration `yaml:"meter_window"`
+	AdminSocket       string        `yaml:"admin_socket"`
+	// AdminHTTPAddr serves the admin API over TCP too, e.g.
+	// "127.0.0.1:39002"; requests need AdminAPIKey as a bearer token.
+	AdminHTTPAddr     string           `yaml:"admin_http_addr"`
+	AdminAPIKey       string           `yaml:"admin_api_key"`
+	AdminDebug        bool             `yaml:"admin_debug"` // pprof under /admin/debug/pprof/
+	Payments          PaymentsConfig   `yaml:"payments"`
+	Backends          BackendsConfig   `yaml:"backends"`
+	Metrics           MetricsConfig    `yaml:"metrics"`
+	CircuitBreaker    BreakerConfig    `yaml:"circuit_breaker"`
+	Moderation        ModerationConfig `yaml:"moderation"`
+	DrainTimeout      time.Duration    `yaml:"drain_timeout"`
+	HeartbeatInterval time.Duration    `yaml:"heartbeat_interval"`
+	MaxRequestBytes   int64            `yaml:"max_request_bytes"`
+	// ModelQuotas maps model IDs to token limits per meter window.
+	ModelQuotas map[string]int64 `yaml:"model_quotas"`
+	// CORS settings for browser clients; empty origins disables CORS.
+	CORSAllowOrigins []string `yaml:"cors_allow_origins"`
+	CORSAllowHeaders []string `yaml:"cors_allow_headers"`
+	// WebhookMaxRetries bounds X-Webhook-URL redeliveries (0 = default 3,
+	// negative disables retries).
+	WebhookMaxRetries int `yaml:"webhook_max_retries"`
+	// WebhookAllowedHosts lists the hosts X-Webhook-URL may name ("*.x.com"
+	// matches subdomains); empty disables webhooks.
+	WebhookAllowedHosts []string `yaml:"webhook_allowed_hosts"`
+	// Source address filtering (CIDR or bare IP); deny wins over allow.
+	AllowIPs          []string `yaml:"allow_ips"`
+	DenyIPs           []string `yaml:"deny_ips"`
+	TrustProxyHeaders bool     `yaml:"trust_proxy_headers"`
+	TrustedProxies    []string `yaml:"trusted_proxies"`
+	// ProbePrompt is sent to each backend by GET /v1/backends.
+	ProbePrompt string `yaml:"probe_prompt"`
+	// ResponseHeaders are added to every proxy response (${ENV} expanded).
+	ResponseHeaders map[string]string `yaml:"response_headers"`
+	// StatsStreamMaxAge closes /v1/stats/stream connections (0 = 1h).
+	StatsStreamMaxAge time.Duration `yaml:"stats_stream_max_age"`
+	// AllowBackendOverride lets callers pick a backend with X-Godex-Backend.
+	AllowBackendOverride bool `yaml:"allow_backend_override"`
+	// Includes are config files merged over this one in order, such as
+	// backends.yaml or keys.yaml; relative paths are from this file's
+	// directory.
+	Includes []string `yaml:"includes"`
+	// JWT accepts bearer tokens issued by an identity provider alongside
+	// keys from keys_path.
+	JWT JWTConfig `yaml:"jwt"`
+	// HMACSecret signs and verifies "keys add --signed" keys.
+	HMACSecret string `yaml:"hmac_secret"`
+	// ServiceAccountSecret signs and verifies "auth service-account add"
+	// credentials.
+	ServiceAccountSecret string `yaml:"service_account_secret"`
+}
+
+// JWTConfig validates JWT bearer tokens against an identity provider's
+// signing keys; an empty JWKSURL disables it.
+type JWTConfig struct {
+	JWKSURL  string `yaml:"jwks_url"`
+	Issuer   string `yaml:"issuer"`   // required iss claim, if set
+	Audience string `yaml:"audience"` // required aud entry, if set
+}
+
+// BreakerConfig configures per-backend circuit breakers.
+type BreakerConfig struct {
+	FailureThreshold int           `yaml:"failure_threshold"` // 0 disables
+	RecoveryWindow   time.Duration `yaml:"recovery_window"`
+}
+
+// ModerationConfig screens user messages with an OpenAI moderation
+// endpoint before forwarding them.
+type ModerationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Backend names the custom openai backend whose /moderations is used.
+	Backend     string  `yaml:"backend"`
+	Threshold   float64 `yaml:"threshold"`     // 0 uses the API's own flags
+	BlockOnFail bool    `yaml:"block_on_fail"` // 451 instead of a warning
 }
 
 // MetricsConfig configures per-backend metrics collection.
@@ -154,13 +230,21 @@ type BackendModelDef struct {
 
 // CodexBackendConfig configures the Codex/ChatGPT backend.
 type CodexBackendConfig struct {
-	Enabled         bool   `yaml:"enabled"`
-	BaseURL         string `yaml:"base_url"`
-	CredentialsPath string `yaml:"credentials_path"`
+	Enabled bool   `yaml:"enabled"`
+	BaseURL string `yaml:"base_url"`
 	// NativeTools forces Codex's built-in tools (shell, apply_patch, update_plan)
 	// even when the caller provides their own tools. Default false (proxy mode
 	// uses caller's tools).
 	NativeTools bool `yaml:"native_tools"`
+	// MaxContextTokens, when > 0, compacts older history so the estimated
+	// prompt stays within this many tokens.
+	MaxContextTokens int `yaml:"max_context_tokens"`
+	// Compaction is "drop_oldest" (default) or "summarize", which spends an
+	// extra model call to summarize the dropped history.
+	Compaction string `yaml:"compaction"`
+	// SystemPromptTemplates maps model name prefixes to Go text/template
+	// system prompts, rendered with the turn, that replace the default.
+	SystemPromptTemplates map[string]string `yaml:"system_prompt_templates"`
 }
 
 // AnthropicBackendConfig configures the Anthropic backend.
@@ -168,12 +252,54 @@ type AnthropicBackendConfig struct {
 	Enabled          bool   `yaml:"enabled"`
 	CredentialsPath  string `yaml:"credentials_path"`
 	DefaultMaxTokens int    `yaml:"default_max_tokens"`
+	// ThinkingBudget enables extended thinking with this many budget
+	// tokens (at least 1024); 0 disables it.
+	ThinkingBudget int `yaml:"thinking_budget"`
+	// PromptCaching caches the system prompt across requests.
+	PromptCaching bool `yaml:"prompt_caching"`
+	// CacheBreakpoint caches only the first N (estimated) tokens of a
+	// longer system prompt; 0 caches all of it.
+	CacheBreakpoint int `yaml:"cache_breakpoint"`
+	// ModelCacheTTL is how long the discovered model list is reused
+	// (default 1h).
+	ModelCacheTTL time.Duration `yaml:"model_cache_ttl"`
+	// BetaFeatures are extra Anthropic-Beta flags sent on every request.
+	BetaFeatures []string `yaml:"beta_features"`
+	// MaxRetries and MaxRetryDelay bound retries of rate-limited (429)
+	// and 5xx responses (defaults 3 and 60s).
+	MaxRetries    int           `yaml:"max_retries"`
+	MaxRetryDelay time.Duration `yaml:"max_retry_delay"`
+	// UseBatchAPI sends batched turns through the Message Batches API,
+	// which costs half as much but can take up to 24 hours.
+	UseBatchAPI bool `yaml:"use_batch_api"`
 }
 
 // RoutingConfig configures model-to-backend routing.
 type RoutingConfig struct {
 	Patterns map[string][]string `yaml:"patterns"`
 	Aliases  map[string]string   `yaml:"aliases"`
+	// StickySessionTTL keeps a session on one backend while it stays active:
+	// each request renews the pin, which lapses after this long idle.
+	StickySessionTTL time.Duration `yaml:"sticky_session_ttl"`
+	// Weights balances requests across backends that serve the same model
+	// (unset = 1, 0 drains a backend).
+	Weights map[string]int `yaml:"weights"`
+	// LatencyRouting prefers the backend with the lowest recent latency.
+	LatencyRouting bool `yaml:"latency_routing"`
+	WarmupRequests int  `yaml:"warmup_requests"`
+	// Canary diverts a share of traffic to a backend being rolled out.
+	Canary CanaryConfig `yaml:"canary"`
+	// HealthCheckInterval periodically checks each backend and routes
+	// around those that fail.
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"`
+}
+
+// CanaryConfig sends Percent (0-100) of the requests for models starting
+// with ModelPattern to Backend.
+type CanaryConfig struct {
+	Backend      string  `yaml:"backend"`
+	ModelPattern string  `yaml:"model_pattern"`
+	Percent      float64 `yaml:"percent"`
 }
 
 func DefaultConfig() Config {
@@ -191,11 +317,12 @@ func DefaultConfig() Config {
 			WebSearch:        false,
 		},
 		Client: ClientConfig{
-			BaseURL:    "https://chatgpt.com/backend-api/codex",
-			Originator: "codex_cli_rs",
-			UserAgent:  "codex_cli_rs/0.0",
-			RetryMax:   1,
-			RetryDelay: 300 * time.Millisecond,
+			BaseURL:       "https://chatgpt.com/backend-api/codex",
+			Originator:    "codex_cli_rs",
+			UserAgent:     "codex_cli_rs/0.0",
+			RetryMax:      1,
+			RetryDelay:    300 * time.Millisecond,
+			MaxRetryDelay: 30 * time.Second,
 		},
 		Auth: AuthConfig{
 			Path:       "",
@@ -236,6 +363,13 @@ func DefaultConfig() Config {
 			UpstreamAuditPath: "",
 			MeterWindow:       0,
 			AdminSocket:       "~/.godex/admin.sock",
+			DrainTimeout:      30 * time.Second,
+			HeartbeatInterval: 15 * time.Second,
+			MaxRequestBytes:   20 * 1024 * 1024,
+			CircuitBreaker: BreakerConfig{
+				FailureThreshold: 0,
+				RecoveryWindow:   30 * time.Second,
+			},
 			Payments: PaymentsConfig{
 				Enabled:       false,
 				Provider:      "l402",
@@ -243,9 +377,8 @@ func DefaultConfig() Config {
 			},
 			Backends: BackendsConfig{
 				Codex: CodexBackendConfig{
-					Enabled:         true,
-					BaseURL:         "https://chatgpt.com/backend-api/codex",
-					CredentialsPath: "",
+					Enabled: true,
+					BaseURL: "https://chatgpt.com/backend-api/codex",
 				},
 				Anthropic: AnthropicBackendConfig{
 					Enabled:          false,
@@ -272,19 +405,76 @@ func DefaultPath() string {
 	return filepath.Join(home, ".config", "godex", "config.yaml")
 }
 
-func Load() Config {
+func Load() (Config, []Warning, error) {
 	return LoadFrom(DefaultPath())
 }
 
-func LoadFrom(path string) Config {
+// LoadFrom loads path over the defaults; a missing file leaves them as they
+// are. When GODEX_ENV is set, the overlay next to path (see OverlayPath) is
+// loaded over it. Each file's proxy.includes are loaded after it.
+//
+// Unknown keys, values of the wrong type, deprecated keys and the problems
+// found by Validate are returned as warnings; the affected settings keep
+// their previous values. The error is set when a file exists but cannot be
+// read or is not valid YAML, or when an include is missing, nested more
+// than five deep or includes itself. With proxy.log_level debug, the sanitized
+// result is logged.
+func LoadFrom(path string) (Config, []Warning, error) {
 	cfg := DefaultConfig()
-	if strings.TrimSpace(path) != "" {
-		if buf, err := os.ReadFile(path); err == nil {
-			_ = yaml.Unmarshal(buf, &cfg)
+	var warnings []Warning
+	for _, file := range []string{path, OverlayPath(path)} {
+		if strings.TrimSpace(file) == "" {
+			continue
+		}
+		buf, err := os.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return cfg, warnings, err
+		}
+		w, err := decodeWithIncludes(file, buf, &cfg, nil)
+		warnings = append(warnings, w...)
+		if err != nil {
+			return cfg, warnings, err
 		}
 	}
 	ApplyEnv(&cfg)
-	return cfg
+	for _, e := range Validate(cfg) {
+		warnings = append(warnings, Warning{File: path, Field: e.Field, Message: e.detail()})
+	}
+	logEffective(path, cfg)
+	return cfg, warnings, nil
+}
+
+// LoadFile is LoadFrom for callers that must not fall back to defaults: it
+// also fails when path does not exist or the result is invalid.
+func LoadFile(path string) (Config, []Warning, error) {
+	if _, err := os.Stat(path); err != nil {
+		return DefaultConfig(), nil, err
+	}
+	cfg, warnings, err := LoadFrom(path)
+	if err != nil {
+		return cfg, warnings, err
+	}
+	return cfg, warnings, Validate(cfg).Fatal()
+}
+
+// OverlayPath returns the environment overlay for the config file at path:
+// config.production.yaml for config.yaml when GODEX_ENV is "production".
+// It is empty when GODEX_ENV is unset.
+//
+// An overlay is decoded over the base config, so it only needs the settings
+// that differ. Maps such as backends.custom and routing.aliases are merged
+// by key, with the overlay's entry replacing the base's whole; lists are
+// replaced.
+func OverlayPath(path string) string {
+	env := strings.TrimSpace(os.Getenv("GODEX_ENV"))
+	if env == "" || strings.TrimSpace(path) == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
 }
 
 func ApplyEnv(cfg *Config) {
@@ -336,6 +526,14 @@ func ApplyEnv(cfg *Config) {
 			cfg.Client.RetryDelay = d
 		}
 	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_MAX_RETRY_DELAY")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Client.MaxRetryDelay = d
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_ACCOUNT_PROFILE")); v != "" {
+		cfg.Client.AccountProfile = v
+	}
 
 	if v := strings.TrimSpace(os.Getenv("GODEX_AUTH_PATH")); v != "" {
 		cfg.Auth.Path = v
@@ -353,9 +551,21 @@ func ApplyEnv(cfg *Config) {
 	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_LISTEN")); v != "" {
 		cfg.Proxy.Listen = v
 	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_TLS_CERT_FILE")); v != "" {
+		cfg.Proxy.TLSCertFile = v
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_TLS_KEY_FILE")); v != "" {
+		cfg.Proxy.TLSKeyFile = v
+	}
 	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_API_KEY")); v != "" {
 		cfg.Proxy.APIKey = v
 	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_HMAC_SECRET")); v != "" {
+		cfg.Proxy.HMACSecret = v
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_SERVICE_ACCOUNT_SECRET")); v != "" {
+		cfg.Proxy.ServiceAccountSecret = v
+	}
 	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_ALLOW_ANY_KEY")); v != "" {
 		cfg.Proxy.AllowAnyKey = parseBool(v)
 	}
@@ -467,9 +677,54 @@ func ApplyEnv(cfg *Config) {
 			cfg.Proxy.MeterWindow = d
 		}
 	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_DRAIN_TIMEOUT")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Proxy.DrainTimeout = d
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_HEARTBEAT_INTERVAL")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Proxy.HeartbeatInterval = d
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_MAX_REQUEST_BYTES")); v != "" {
+		if n, err := parseInt64(v); err == nil {
+			cfg.Proxy.MaxRequestBytes = n
+		}
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_CORS_ALLOW_ORIGINS")); v != "" {
+		cfg.Proxy.CORSAllowOrigins = splitList(v)
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_CORS_ALLOW_HEADERS")); v != "" {
+		cfg.Proxy.CORSAllowHeaders = splitList(v)
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_WEBHOOK_ALLOWED_HOSTS")); v != "" {
+		cfg.Proxy.WebhookAllowedHosts = splitList(v)
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_ALLOW_IPS")); v != "" {
+		cfg.Proxy.AllowIPs = splitList(v)
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_DENY_IPS")); v != "" {
+		cfg.Proxy.DenyIPs = splitList(v)
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_TRUST_PROXY_HEADERS")); v != "" {
+		cfg.Proxy.TrustProxyHeaders = parseBool(v)
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_TRUSTED_PROXIES")); v != "" {
+		cfg.Proxy.TrustedProxies = splitList(v)
+	}
 	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_ADMIN_SOCKET")); v != "" {
 		cfg.Proxy.AdminSocket = v
 	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_ADMIN_HTTP_ADDR")); v != "" {
+		cfg.Proxy.AdminHTTPAddr = v
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_ADMIN_API_KEY")); v != "" {
+		cfg.Proxy.AdminAPIKey = v
+	}
+	if v := strings.TrimSpace(os.Getenv("GODEX_PROXY_ADMIN_DEBUG")); v != "" {
+		cfg.Proxy.AdminDebug = parseBool(v)
+	}
 	if v := strings.TrimSpace(os.Getenv("GODEX_PAYMENTS_ENABLED")); v != "" {
 		cfg.Proxy.Payments.Enabled = parseBool(v)
 	}
@@ -493,3 +748,13 @@ func parseBool(val string) bool {
 	val = strings.TrimSpace(strings.ToLower(val))
 	return val == "1" || val == "true" || val == "yes"
 }
+
+func splitList(val string) []string {
+	var out []string
+	for _, part := range strings.Split(val, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -1,8 +1,10 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -71,6 +73,19 @@ func TestDefaultPath(t *testing.T) {
 	}
 }
 
+// mustLoadFrom loads path and fails the test on an error or warning.
+func mustLoadFrom(t *testing.T, path string) Config {
+	t.Helper()
+	cfg, warnings, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom(%q): %v", path, err)
+	}
+	if len(warnings) > 0 {
+		t.Fatalf("LoadFrom(%q) warnings: %v", path, warnings)
+	}
+	return cfg
+}
+
 func TestLoadFrom(t *testing.T) {
 	// Create a temp config file
 	tmpDir := t.TempDir()
@@ -90,7 +105,7 @@ proxy:
 		t.Fatal(err)
 	}
 
-	cfg := LoadFrom(configPath)
+	cfg := mustLoadFrom(t, configPath)
 
 	// Check custom values loaded
 	if cfg.Exec.Model != "custom-model" {
@@ -112,9 +127,185 @@ proxy:
 	}
 }
 
+func TestLoadFromOverlay(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	base := `
+proxy:
+  listen: "127.0.0.1:39001"
+  log_level: info
+  cors_allow_origins: ["https://a.example.com", "https://b.example.com"]
+  backends:
+    custom:
+      local:
+        type: openai
+        base_url: http://localhost:8080/v1
+      staging:
+        type: openai
+        base_url: http://staging:8080/v1
+    routing:
+      aliases:
+        fast: gpt-5.2-codex
+        smart: claude-sonnet-4-5
+`
+	overlay := `
+proxy:
+  log_level: warn
+  cors_allow_origins: ["https://prod.example.com"]
+  backends:
+    custom:
+      staging:
+        type: openai
+        base_url: https://prod:8443/v1
+    routing:
+      aliases:
+        smart: claude-opus-4-1
+        cheap: gpt-5-mini
+`
+	if err := os.WriteFile(configPath, []byte(base), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "config.production.yaml"), []byte(overlay), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without GODEX_ENV the overlay is ignored.
+	t.Setenv("GODEX_ENV", "")
+	if cfg := mustLoadFrom(t, configPath); cfg.Proxy.LogLevel != "info" {
+		t.Errorf("LogLevel without GODEX_ENV = %q, want info", cfg.Proxy.LogLevel)
+	}
+
+	t.Setenv("GODEX_ENV", "production")
+	cfg := mustLoadFrom(t, configPath)
+
+	// Scalars: the overlay wins, unset ones keep the base value.
+	if cfg.Proxy.LogLevel != "warn" {
+		t.Errorf("LogLevel = %q, want warn", cfg.Proxy.LogLevel)
+	}
+	if cfg.Proxy.Listen != "127.0.0.1:39001" {
+		t.Errorf("Listen = %q, want the base value", cfg.Proxy.Listen)
+	}
+	// Maps: merged by key.
+	wantAliases := map[string]string{"fast": "gpt-5.2-codex", "smart": "claude-opus-4-1", "cheap": "gpt-5-mini"}
+	if got := cfg.Proxy.Backends.Routing.Aliases; len(got) != len(wantAliases) || got["fast"] != wantAliases["fast"] || got["smart"] != wantAliases["smart"] || got["cheap"] != wantAliases["cheap"] {
+		t.Errorf("Aliases = %v, want %v", got, wantAliases)
+	}
+	custom := cfg.Proxy.Backends.Custom
+	if custom["local"].BaseURL != "http://localhost:8080/v1" || custom["staging"].BaseURL != "https://prod:8443/v1" {
+		t.Errorf("Custom = %+v, want local kept and staging overridden", custom)
+	}
+	// Slices: replaced.
+	if got := cfg.Proxy.CORSAllowOrigins; len(got) != 1 || got[0] != "https://prod.example.com" {
+		t.Errorf("CORSAllowOrigins = %v, want only the overlay's", got)
+	}
+}
+
+func TestOverlayPath(t *testing.T) {
+	t.Setenv("GODEX_ENV", "")
+	if got := OverlayPath("/etc/godex/config.yaml"); got != "" {
+		t.Errorf("OverlayPath without GODEX_ENV = %q, want empty", got)
+	}
+	t.Setenv("GODEX_ENV", "production")
+	if got := OverlayPath("/etc/godex/config.yaml"); got != "/etc/godex/config.production.yaml" {
+		t.Errorf("OverlayPath = %q", got)
+	}
+}
+
+func TestLoadFromIncludes(t *testing.T) {
+	t.Setenv("GODEX_ENV", "")
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "conf.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	writeConfig(t, configPath, `
+proxy:
+  log_level: info
+  includes: [conf.d/backends.yaml, conf.d/routing.yaml]
+`)
+	writeConfig(t, filepath.Join(dir, "conf.d", "backends.yaml"), `
+proxy:
+  log_level: warn
+  includes: [keys.yaml]
+  backends:
+    custom:
+      local:
+        type: openai
+        base_url: http://localhost:8080/v1
+`)
+	writeConfig(t, filepath.Join(dir, "conf.d", "keys.yaml"), `
+proxy:
+  keys_path: /etc/godex/keys.json
+`)
+	writeConfig(t, filepath.Join(dir, "conf.d", "routing.yaml"), `
+proxy:
+  log_level: error
+  backends:
+    routing:
+      aliases:
+        fast: gpt-5.2-codex
+`)
+
+	cfg := mustLoadFrom(t, configPath)
+	// Later includes win; nested ones are relative to their includer.
+	if cfg.Proxy.LogLevel != "error" {
+		t.Errorf("LogLevel = %q, want error from the last include", cfg.Proxy.LogLevel)
+	}
+	if cfg.Proxy.KeysPath != "/etc/godex/keys.json" {
+		t.Errorf("KeysPath = %q, want the nested include's", cfg.Proxy.KeysPath)
+	}
+	if cfg.Proxy.Backends.Custom["local"].BaseURL != "http://localhost:8080/v1" || cfg.Proxy.Backends.Routing.Aliases["fast"] != "gpt-5.2-codex" {
+		t.Errorf("includes not merged: %+v", cfg.Proxy.Backends)
+	}
+	if got := cfg.Proxy.Includes; len(got) != 2 || got[0] != "conf.d/backends.yaml" {
+		t.Errorf("Includes = %v, want the top-level list", got)
+	}
+
+	// Warnings name the included file.
+	writeConfig(t, filepath.Join(dir, "conf.d", "keys.yaml"), "proxy:\n  keys_pth: x\n")
+	_, warnings, err := LoadFrom(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || !strings.HasSuffix(warnings[0].File, "keys.yaml") {
+		t.Errorf("warnings = %v, want one in keys.yaml", warnings)
+	}
+}
+
+func TestLoadFromIncludeErrors(t *testing.T) {
+	t.Setenv("GODEX_ENV", "")
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+
+	writeConfig(t, configPath, "proxy:\n  includes: [missing.yaml]\n")
+	if _, _, err := LoadFrom(configPath); err == nil || !strings.Contains(err.Error(), "missing.yaml") {
+		t.Errorf("missing include: err = %v", err)
+	}
+
+	writeConfig(t, configPath, "proxy:\n  includes: [a.yaml]\n")
+	writeConfig(t, filepath.Join(dir, "a.yaml"), "proxy:\n  includes: [config.yaml]\n")
+	if _, _, err := LoadFrom(configPath); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("cycle: err = %v", err)
+	}
+
+	// config.yaml -> 1.yaml -> ... -> 5.yaml is as deep as includes go.
+	writeConfig(t, configPath, "proxy:\n  includes: [1.yaml]\n")
+	for i := 1; i <= 5; i++ {
+		writeConfig(t, filepath.Join(dir, fmt.Sprintf("%d.yaml", i)), fmt.Sprintf("proxy:\n  includes: [%d.yaml]\n", i+1))
+	}
+	writeConfig(t, filepath.Join(dir, "6.yaml"), "proxy:\n  log_level: debug\n")
+	if _, _, err := LoadFrom(configPath); err == nil || !strings.Contains(err.Error(), "nested more than 5 deep") {
+		t.Errorf("depth 6: err = %v", err)
+	}
+	writeConfig(t, filepath.Join(dir, "5.yaml"), "proxy:\n  log_level: warn\n")
+	if cfg := mustLoadFrom(t, configPath); cfg.Proxy.LogLevel != "warn" {
+		t.Errorf("depth 5: LogLevel = %q, want warn", cfg.Proxy.LogLevel)
+	}
+}
+
 func TestLoadFromMissing(t *testing.T) {
 	// Load from non-existent file should return defaults
-	cfg := LoadFrom("/nonexistent/path/config.yaml")
+	cfg := mustLoadFrom(t, "/nonexistent/path/config.yaml")
 
 	if cfg.Exec.Model != "gpt-5.2-codex" {
 		t.Errorf("should return defaults for missing file, got Exec.Model = %q", cfg.Exec.Model)
@@ -123,7 +314,7 @@ func TestLoadFromMissing(t *testing.T) {
 
 func TestLoadFromEmpty(t *testing.T) {
 	// Load from empty path should return defaults
-	cfg := LoadFrom("")
+	cfg := mustLoadFrom(t, "")
 
 	if cfg.Exec.Model != "gpt-5.2-codex" {
 		t.Errorf("should return defaults for empty path, got Exec.Model = %q", cfg.Exec.Model)
@@ -247,7 +438,7 @@ proxy:
 		t.Fatal(err)
 	}
 
-	cfg := LoadFrom(configPath)
+	cfg := mustLoadFrom(t, configPath)
 
 	// Verify all fields loaded correctly
 	if cfg.Exec.Model != "test-model" {
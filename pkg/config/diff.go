@@ -0,0 +1,73 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Change is one setting that differs between two configs. Old and New are
+// taken from the sanitized configs, so secrets read "***".
+type Change struct {
+	// Field is the setting's path in the config file, such as
+	// "proxy.log_level".
+	Field string
+	Old   string
+	New   string
+}
+
+// Diff lists the settings that differ between prev and next, in file
+// order. Structs are compared field by field; maps and lists are compared
+// whole and reported as one change. Secrets are compared before masking,
+// so a rotated key is reported even though both values read "***".
+func Diff(prev, next Config) []Change {
+	var changes []Change
+	diffValues(&changes, "",
+		reflect.ValueOf(prev), reflect.ValueOf(next),
+		reflect.ValueOf(Sanitize(prev)), reflect.ValueOf(Sanitize(next)))
+	return changes
+}
+
+func diffValues(changes *[]Change, path string, prev, next, prevShown, nextShown reflect.Value) {
+	if prev.Kind() == reflect.Struct && prev.Type() != durationType {
+		t := prev.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			diffValues(changes, name, prev.Field(i), next.Field(i), prevShown.Field(i), nextShown.Field(i))
+		}
+		return
+	}
+	if reflect.DeepEqual(prev.Interface(), next.Interface()) {
+		return
+	}
+	*changes = append(*changes, Change{Field: path, Old: formatValue(prevShown), New: formatValue(nextShown)})
+}
+
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Pointer:
+		if v.IsNil() {
+			return ""
+		}
+		buf, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Sprint(v.Interface())
+		}
+		return string(buf)
+	}
+	return fmt.Sprint(v.Interface())
+}
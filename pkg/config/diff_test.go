@@ -0,0 +1,40 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	prev := DefaultConfig()
+	if got := Diff(prev, prev); len(got) != 0 {
+		t.Fatalf("Diff of equal configs = %+v, want none", got)
+	}
+
+	next := prev
+	next.Proxy.LogLevel = "debug"
+	next.Proxy.MeterWindow = 2 * time.Hour
+	next.Proxy.AdminAPIKey = "rotated-key"
+	next.Proxy.ResponseHeaders = map[string]string{"X-Env": "prod"}
+
+	want := []Change{
+		{Field: "proxy.log_level", Old: prev.Proxy.LogLevel, New: "debug"},
+		{Field: "proxy.meter_window", Old: prev.Proxy.MeterWindow.String(), New: "2h0m0s"},
+		{Field: "proxy.admin_api_key", Old: mask(prev.Proxy.AdminAPIKey), New: "***"},
+		{Field: "proxy.response_headers", Old: "", New: `{"X-Env":"prod"}`},
+	}
+	got := Diff(prev, next)
+	byField := map[string]Change{}
+	for _, c := range got {
+		byField[c.Field] = c
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Diff = %+v, want %d changes", got, len(want))
+	}
+	for _, w := range want {
+		if c := byField[w.Field]; !reflect.DeepEqual(c, w) {
+			t.Errorf("change %s = %+v, want %+v", w.Field, c, w)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// maxIncludeDepth bounds how deeply proxy.includes may nest below the file
+// being loaded.
+const maxIncludeDepth = 5
+
+// decodeWithIncludes decodes the file at path over cfg and then, in order,
+// the files listed in its proxy.includes, each with its own includes. They
+// merge as an overlay does. chain holds the absolute paths of the files
+// that included this one, outermost first.
+//
+// cfg.Proxy.Includes is left as the file set it, so that the loaded config
+// shows the top-level list rather than the last nested one.
+func decodeWithIncludes(path string, buf []byte, cfg *Config, chain []string) ([]Warning, error) {
+	prev := cfg.Proxy.Includes
+	cfg.Proxy.Includes = nil
+	warnings, err := decodeFile(path, buf, cfg)
+	includes := cfg.Proxy.Includes
+	defer func() {
+		if includes == nil {
+			includes = prev
+		}
+		cfg.Proxy.Includes = includes
+	}()
+	if err != nil || len(includes) == 0 {
+		return warnings, err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return warnings, err
+	}
+	chain = append(chain, abs)
+	if len(chain) > maxIncludeDepth {
+		return warnings, fmt.Errorf("%s: includes nested more than %d deep", path, maxIncludeDepth)
+	}
+	for _, include := range includes {
+		file := includePath(path, include)
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return warnings, err
+		}
+		if slices.Contains(chain, absFile) {
+			return warnings, fmt.Errorf("include cycle: %s", strings.Join(append(chain, absFile), " -> "))
+		}
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			return warnings, fmt.Errorf("%s: include: %w", path, err)
+		}
+		w, err := decodeWithIncludes(file, buf, cfg, chain)
+		warnings = append(warnings, w...)
+		if err != nil {
+			return warnings, err
+		}
+	}
+	return warnings, nil
+}
+
+// includePath resolves an include listed in the file at from: relative
+// paths are relative to that file's directory and "~/" to the home
+// directory.
+func includePath(from, include string) string {
+	include = strings.TrimSpace(include)
+	if rest, ok := strings.CutPrefix(include, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	if filepath.IsAbs(include) {
+		return include
+	}
+	return filepath.Join(filepath.Dir(from), include)
+}
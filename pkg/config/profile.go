@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfilePath returns the config file of the named profile,
+// ~/.config/godex/profiles/<name>/config.yaml.
+func ProfilePath(name string) string {
+	dir := profilesDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, name, "config.yaml")
+}
+
+func profilesDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "godex", "profiles")
+}
+
+// ResolvePath returns the config file to load for profile. GODEX_CONFIG
+// wins when set; otherwise an empty profile falls back to GODEX_PROFILE,
+// and with neither the default path is used.
+func ResolvePath(profile string) string {
+	if v := strings.TrimSpace(os.Getenv("GODEX_CONFIG")); v != "" {
+		return v
+	}
+	if strings.TrimSpace(profile) == "" {
+		profile = strings.TrimSpace(os.Getenv("GODEX_PROFILE"))
+	}
+	if profile == "" {
+		return DefaultPath()
+	}
+	return ProfilePath(profile)
+}
+
+// LoadProfile loads the named profile's config as LoadFrom does, creating
+// the profiles directory if needed. GODEX_CONFIG, when set, is loaded
+// instead.
+func LoadProfile(name string) (Config, []Warning, error) {
+	if err := checkProfileName(name); err != nil {
+		return DefaultConfig(), nil, err
+	}
+	dir := profilesDir()
+	if dir == "" {
+		return DefaultConfig(), nil, errors.New("no home directory for profiles")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return DefaultConfig(), nil, fmt.Errorf("create profiles directory: %w", err)
+	}
+	return LoadFrom(ResolvePath(name))
+}
+
+// WriteTemplate writes the default config to path as a starting point,
+// creating its directory. It refuses to overwrite an existing file.
+func WriteTemplate(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	buf, err := yaml.Marshal(DefaultConfig())
+	if err != nil {
+		return fmt.Errorf("encode template: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create config directory: %w", err)
+	}
+	header := "# Godex configuration; see docs/config.template.yaml for every option.\n"
+	return os.WriteFile(path, append([]byte(header), buf...), 0o600)
+}
+
+// checkProfileName rejects names that would leave the profiles directory.
+func checkProfileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GODEX_CONFIG", "")
+	t.Setenv("GODEX_PROFILE", "")
+
+	path := filepath.Join(home, ".config", "godex", "profiles", "production", "config.yaml")
+	if got := ProfilePath("production"); got != path {
+		t.Fatalf("ProfilePath = %q, want %q", got, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, path, "proxy:\n  listen: \"0.0.0.0:9000\"\n")
+
+	cfg, warnings, err := LoadProfile("production")
+	if err != nil || len(warnings) > 0 {
+		t.Fatalf("LoadProfile: %v %v", err, warnings)
+	}
+	if cfg.Proxy.Listen != "0.0.0.0:9000" {
+		t.Errorf("Listen = %q, want the profile's value", cfg.Proxy.Listen)
+	}
+
+	if _, _, err := LoadProfile("../escape"); err == nil {
+		t.Error("LoadProfile with a path in the name: want error")
+	}
+}
+
+func TestLoadProfileCreatesProfilesDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GODEX_CONFIG", "")
+
+	cfg, _, err := LoadProfile("staging")
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if cfg.Proxy.Listen != DefaultConfig().Proxy.Listen {
+		t.Errorf("missing profile should load defaults, got Listen %q", cfg.Proxy.Listen)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "godex", "profiles")); err != nil {
+		t.Errorf("profiles directory not created: %v", err)
+	}
+}
+
+func TestGodexConfigOverridesProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	override := filepath.Join(t.TempDir(), "override.yaml")
+	writeConfig(t, override, "proxy:\n  listen: \"127.0.0.1:7000\"\n")
+	profile := ProfilePath("production")
+	if err := os.MkdirAll(filepath.Dir(profile), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	writeConfig(t, profile, "proxy:\n  listen: \"0.0.0.0:9000\"\n")
+	t.Setenv("GODEX_CONFIG", override)
+
+	if got := ResolvePath("production"); got != override {
+		t.Errorf("ResolvePath = %q, want GODEX_CONFIG %q", got, override)
+	}
+	cfg, _, err := LoadProfile("production")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Proxy.Listen != "127.0.0.1:7000" {
+		t.Errorf("Listen = %q, want GODEX_CONFIG's value", cfg.Proxy.Listen)
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GODEX_CONFIG", "")
+
+	t.Setenv("GODEX_PROFILE", "")
+	if got := ResolvePath(""); got != DefaultPath() {
+		t.Errorf("ResolvePath(\"\") = %q, want DefaultPath", got)
+	}
+	t.Setenv("GODEX_PROFILE", "staging")
+	if got := ResolvePath(""); got != ProfilePath("staging") {
+		t.Errorf("ResolvePath with GODEX_PROFILE = %q", got)
+	}
+	if got := ResolvePath("production"); got != ProfilePath("production") {
+		t.Errorf("an explicit profile should beat GODEX_PROFILE, got %q", got)
+	}
+}
+
+func TestWriteTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "profiles", "dev", "config.yaml")
+	if err := WriteTemplate(path); err != nil {
+		t.Fatalf("WriteTemplate: %v", err)
+	}
+	cfg := mustLoadFrom(t, path)
+	if cfg.Proxy.Listen != DefaultConfig().Proxy.Listen {
+		t.Errorf("template Listen = %q", cfg.Proxy.Listen)
+	}
+	if err := WriteTemplate(path); err == nil {
+		t.Error("WriteTemplate over an existing file: want error")
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"log"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// masked replaces the value of a sensitive setting that is set.
+const masked = "***"
+
+// Sanitize returns a copy of cfg that is safe to log or print: API keys,
+// the HMAC and service account secrets, the OAuth client ID, the token
+// meter URL and custom backend keys and auth headers are replaced with
+// "***", and passwords in URLs are redacted.
+// Unset fields stay empty, so it still shows which ones are configured.
+func Sanitize(cfg Config) Config {
+	cfg.Auth.ClientID = mask(cfg.Auth.ClientID)
+	cfg.Client.BaseURL = maskURL(cfg.Client.BaseURL)
+	cfg.Proxy.APIKey = mask(cfg.Proxy.APIKey)
+	cfg.Proxy.HMACSecret = mask(cfg.Proxy.HMACSecret)
+	cfg.Proxy.ServiceAccountSecret = mask(cfg.Proxy.ServiceAccountSecret)
+	cfg.Proxy.AdminAPIKey = mask(cfg.Proxy.AdminAPIKey)
+	cfg.Proxy.BaseURL = maskURL(cfg.Proxy.BaseURL)
+	cfg.Proxy.Payments.TokenMeterURL = mask(cfg.Proxy.Payments.TokenMeterURL)
+	cfg.Proxy.Backends.Codex.BaseURL = maskURL(cfg.Proxy.Backends.Codex.BaseURL)
+
+	if cfg.Proxy.Models != nil {
+		models := make([]ModelConfig, len(cfg.Proxy.Models))
+		for i, m := range cfg.Proxy.Models {
+			m.BaseURL = maskURL(m.BaseURL)
+			models[i] = m
+		}
+		cfg.Proxy.Models = models
+	}
+	if cfg.Proxy.Backends.Custom != nil {
+		custom := make(map[string]CustomBackendConfig, len(cfg.Proxy.Backends.Custom))
+		for name, b := range cfg.Proxy.Backends.Custom {
+			b.BaseURL = maskURL(b.BaseURL)
+			b.Auth.Key = mask(b.Auth.Key)
+			if b.Auth.Headers != nil {
+				headers := make(map[string]string, len(b.Auth.Headers))
+				for k, v := range b.Auth.Headers {
+					headers[k] = mask(v)
+				}
+				b.Auth.Headers = headers
+			}
+			custom[name] = b
+		}
+		cfg.Proxy.Backends.Custom = custom
+	}
+	return cfg
+}
+
+func mask(v string) string {
+	if v == "" {
+		return ""
+	}
+	return masked
+}
+
+// maskURL redacts the password in a URL's user info, if any.
+func maskURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return u.Redacted()
+}
+
+// logEffective logs the sanitized config at DEBUG level when the proxy log
+// level asks for it.
+func logEffective(path string, cfg Config) {
+	if !strings.EqualFold(strings.TrimSpace(cfg.Proxy.LogLevel), "debug") {
+		return
+	}
+	buf, err := yaml.Marshal(Sanitize(cfg))
+	if err != nil {
+		return
+	}
+	log.Printf("[DEBUG] config: effective config from %s:\n%s", path, buf)
+}
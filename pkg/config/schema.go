@@ -0,0 +1,142 @@
+package config
+
+import (
+	_ "embed"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// configSource is parsed for the field comments that describe settings in
+// the schema.
+//
+//go:embed config.go
+var configSource string
+
+// durationPattern matches the Go durations the config accepts, such as
+// "90s", "300ms" or "1h30m". Bare numbers are not durations in YAML.
+const durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// fieldConstraints adds enums and patterns to settings, keyed by
+// "Type.Field".
+var fieldConstraints = map[string]map[string]any{
+	"ExecConfig.MockMode":           {"enum": []string{"echo", "text", "tool-call", "tool-loop"}},
+	"ProxyConfig.LogLevel":          {"enum": []string{"", "debug", "info", "warn", "warning", "error"}},
+	"ProxyConfig.DefaultRate":       {"pattern": `^([1-9][0-9]*/(` + strings.Join(rateUnits, "|") + `))?$`},
+	"CustomBackendConfig.Type":      {"enum": []string{"openai"}},
+	"BackendAuthConfig.Type":        {"enum": []string{"", "api_key", "bearer", "header", "none"}},
+	"CodexBackendConfig.Compaction": {"enum": []string{"", "drop_oldest", "summarize"}},
+	"CanaryConfig.Percent":          {"minimum": 0, "maximum": 100},
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Schema returns a JSON Schema (draft-07) document describing the config
+// file, for editors and the YAML language server. Keys come from the yaml
+// struct tags and descriptions from the field comments in this package.
+func Schema() map[string]any {
+	docs := fieldDocs()
+	s := schemaFor(reflect.TypeOf(Config{}), docs)
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "godex configuration"
+	return s
+}
+
+func schemaFor(t reflect.Type, docs map[string]string) map[string]any {
+	if t == durationType {
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := schemaFor(t.Elem(), docs)
+		if typ, ok := s["type"].(string); ok {
+			s["type"] = []string{typ, "null"}
+		}
+		return s
+	case reflect.Struct:
+		props := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := yamlName(f)
+			if !f.IsExported() || name == "" {
+				continue
+			}
+			key := t.Name() + "." + f.Name
+			prop := schemaFor(f.Type, docs)
+			if doc := docs[key]; doc != "" {
+				prop["description"] = doc
+			}
+			for k, v := range fieldConstraints[key] {
+				prop[k] = v
+			}
+			props[name] = prop
+		}
+		s := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+		if doc := docs[t.Name()]; doc != "" {
+			s["description"] = doc
+		}
+		return s
+	case reflect.Map:
+		// An empty key ("custom:") decodes as null, leaving the map unset.
+		return map[string]any{"type": []string{"object", "null"}, "additionalProperties": schemaFor(t.Elem(), docs)}
+	case reflect.Slice:
+		return map[string]any{"type": []string{"array", "null"}, "items": schemaFor(t.Elem(), docs)}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// fieldDocs returns the doc comments of the config types, keyed by type
+// name, and of their fields, keyed by "Type.Field". A field's trailing
+// comment is used when it has no doc comment.
+func fieldDocs() map[string]string {
+	docs := map[string]string{}
+	file, err := parser.ParseFile(token.NewFileSet(), "config.go", configSource, parser.ParseComments)
+	if err != nil {
+		return docs
+	}
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			ts := spec.(*ast.TypeSpec)
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			if doc := commentText(gen.Doc); doc != "" {
+				docs[ts.Name.Name] = doc
+			}
+			for _, field := range st.Fields.List {
+				doc := commentText(field.Doc)
+				if doc == "" {
+					doc = commentText(field.Comment)
+				}
+				if doc == "" {
+					continue
+				}
+				for _, name := range field.Names {
+					docs[ts.Name.Name+"."+name.Name] = doc
+				}
+			}
+		}
+	}
+	return docs
+}
+
+func commentText(group *ast.CommentGroup) string {
+	return strings.Join(strings.Fields(group.Text()), " ")
+}
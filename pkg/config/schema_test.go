@@ -0,0 +1,143 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// validateSchema checks v against the subset of JSON Schema that Schema
+// emits and returns the problems found.
+func validateSchema(s map[string]any, v any, path string) []string {
+	var errs []string
+	if enum, ok := s["enum"].([]any); ok && !slices.Contains(enum, v) {
+		errs = append(errs, fmt.Sprintf("%s: %v not in %v", path, v, enum))
+	}
+	types := []any{s["type"]}
+	if list, ok := s["type"].([]any); ok {
+		types = list
+	}
+	matched := false
+	for _, typ := range types {
+		switch typ {
+		case "object":
+			obj, ok := v.(map[string]any)
+			if !ok {
+				continue
+			}
+			matched = true
+			props, _ := s["properties"].(map[string]any)
+			for k, val := range obj {
+				if prop, ok := props[k].(map[string]any); ok {
+					errs = append(errs, validateSchema(prop, val, path+"."+k)...)
+				} else if extra, ok := s["additionalProperties"].(map[string]any); ok {
+					errs = append(errs, validateSchema(extra, val, path+"."+k)...)
+				} else {
+					errs = append(errs, path+"."+k+": unknown property")
+				}
+			}
+		case "array":
+			arr, ok := v.([]any)
+			if !ok {
+				continue
+			}
+			matched = true
+			for i, item := range arr {
+				errs = append(errs, validateSchema(s["items"].(map[string]any), item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		case "string":
+			str, ok := v.(string)
+			if !ok {
+				continue
+			}
+			matched = true
+			if pattern, ok := s["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(str) {
+				errs = append(errs, fmt.Sprintf("%s: %q does not match %s", path, str, pattern))
+			}
+		case "integer":
+			n, ok := v.(float64)
+			matched = matched || ok && n == float64(int64(n))
+		case "number":
+			_, ok := v.(float64)
+			matched = matched || ok
+		case "boolean":
+			_, ok := v.(bool)
+			matched = matched || ok
+		case "null":
+			matched = matched || v == nil
+		}
+	}
+	if !matched {
+		errs = append(errs, fmt.Sprintf("%s: %v (%T) is not %v", path, v, v, s["type"]))
+	}
+	return errs
+}
+
+// asJSON converts v to the generic form a JSON Schema validator sees.
+func asJSON(t *testing.T, v any) any {
+	t.Helper()
+	buf, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out any
+	if err := json.Unmarshal(buf, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestSchemaValidatesConfigs(t *testing.T) {
+	// Go through JSON so the validator sees the schema as ajv would.
+	schema := asJSON(t, Schema()).(map[string]any)
+	if schema["$schema"] != "http://json-schema.org/draft-07/schema#" {
+		t.Errorf("$schema = %v", schema["$schema"])
+	}
+
+	template, err := os.ReadFile("../../docs/config.template.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := yaml.Marshal(DefaultConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, doc := range map[string][]byte{"template": template, "defaults": defaults} {
+		var v map[string]any
+		if err := yaml.Unmarshal(doc, &v); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, e := range validateSchema(schema, asJSON(t, v), name) {
+			t.Error(e)
+		}
+	}
+}
+
+func TestSchemaRejectsBadValues(t *testing.T) {
+	schema := asJSON(t, Schema()).(map[string]any)
+	bad := map[string]any{
+		"proxy": map[string]any{
+			"log_level":    "loud",
+			"default_rate": "60/day",
+			"cache_ttl":    "6hours",
+			"listen_addr":  "x",
+		},
+	}
+	if errs := validateSchema(schema, asJSON(t, bad), "bad"); len(errs) != 4 {
+		t.Errorf("errors = %v, want 4", errs)
+	}
+}
+
+func TestSchemaDescriptions(t *testing.T) {
+	s := Schema()
+	proxy := s["properties"].(map[string]any)["proxy"].(map[string]any)
+	quotas := proxy["properties"].(map[string]any)["model_quotas"].(map[string]any)
+	if quotas["description"] != "ModelQuotas maps model IDs to token limits per meter window." {
+		t.Errorf("model_quotas description = %q", quotas["description"])
+	}
+}
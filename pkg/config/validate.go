@@ -0,0 +1,224 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError describes one setting that is malformed or missing.
+// Field is the setting's YAML path, such as "proxy.default_rate".
+type ValidationError struct {
+	Field   string
+	Value   string
+	Message string
+	// Fatal marks a problem godex cannot start with, such as a routing
+	// pattern that does not compile. Others are reported as warnings.
+	Fatal bool
+}
+
+// ValidationErrors are the problems Validate found.
+type ValidationErrors []ValidationError
+
+// Fatal returns the first fatal problem in errs, or nil.
+func (errs ValidationErrors) Fatal() error {
+	for _, e := range errs {
+		if e.Fatal {
+			return e
+		}
+	}
+	return nil
+}
+
+func (e ValidationError) Error() string {
+	return e.Field + ": " + e.detail()
+}
+
+// detail is the message with the offending value, if any.
+func (e ValidationError) detail() string {
+	if e.Value == "" {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (got %q)", e.Message, e.Value)
+}
+
+// Validate checks cfg for settings that would be ignored or fail at run
+// time: malformed rates, URLs and log levels, negative durations, features
+// enabled without the settings they need, and routing patterns that are not
+// valid regular expressions, which are fatal.
+func Validate(cfg Config) ValidationErrors {
+	var v validator
+	p := cfg.Proxy
+
+	v.url("client.base_url", cfg.Client.BaseURL)
+	v.url("auth.refresh_url", cfg.Auth.RefreshURL)
+	v.duration("exec.timeout", cfg.Exec.Timeout)
+	v.duration("client.retry_delay", cfg.Client.RetryDelay)
+	v.duration("client.max_retry_delay", cfg.Client.MaxRetryDelay)
+
+	v.logLevel("proxy.log_level", p.LogLevel)
+	v.rate("proxy.default_rate", p.DefaultRate)
+	v.url("proxy.base_url", p.BaseURL)
+	v.url("proxy.jwt.jwks_url", p.JWT.JWKSURL)
+	for i, m := range p.Models {
+		field := fmt.Sprintf("proxy.models[%d]", i)
+		v.required(field+".id", m.ID)
+		v.url(field+".base_url", m.BaseURL)
+	}
+	if (p.TLSCertFile == "") != (p.TLSKeyFile == "") {
+		v.add("proxy.tls_key_file", "", "tls_cert_file and tls_key_file must be set together")
+	}
+	v.duration("proxy.cache_ttl", p.CacheTTL)
+	v.duration("proxy.meter_window", p.MeterWindow)
+	v.duration("proxy.drain_timeout", p.DrainTimeout)
+	v.duration("proxy.heartbeat_interval", p.HeartbeatInterval)
+	v.duration("proxy.stats_stream_max_age", p.StatsStreamMaxAge)
+	v.duration("proxy.circuit_breaker.recovery_window", p.CircuitBreaker.RecoveryWindow)
+
+	if p.AdminHTTPAddr != "" {
+		v.required("proxy.admin_api_key", p.AdminAPIKey)
+	}
+
+	if p.Payments.Enabled {
+		v.required("proxy.payments.token_meter_url", p.Payments.TokenMeterURL)
+	}
+	v.url("proxy.payments.token_meter_url", p.Payments.TokenMeterURL)
+
+	if p.Moderation.Enabled {
+		if v.required("proxy.moderation.backend", p.Moderation.Backend) {
+			if _, ok := p.Backends.Custom[p.Moderation.Backend]; !ok {
+				v.add("proxy.moderation.backend", p.Moderation.Backend, "must name a custom backend")
+			}
+		}
+	}
+
+	b := p.Backends
+	v.url("proxy.backends.codex.base_url", b.Codex.BaseURL)
+	v.duration("proxy.backends.anthropic.model_cache_ttl", b.Anthropic.ModelCacheTTL)
+	v.duration("proxy.backends.anthropic.max_retry_delay", b.Anthropic.MaxRetryDelay)
+	for _, name := range sortedKeys(b.Custom) {
+		c := b.Custom[name]
+		field := "proxy.backends.custom." + name
+		if !c.IsEnabled() {
+			continue
+		}
+		if c.Type != "openai" {
+			v.add(field+".type", c.Type, `must be "openai"`)
+		}
+		if v.required(field+".base_url", c.BaseURL) {
+			v.url(field+".base_url", c.BaseURL)
+		}
+		switch c.Auth.Type {
+		case "", "api_key", "bearer", "header", "none":
+		default:
+			v.add(field+".auth.type", c.Auth.Type, "must be api_key, bearer, header or none")
+		}
+		v.duration(field+".timeout", c.Timeout)
+	}
+
+	r := b.Routing
+	for _, name := range sortedKeys(r.Patterns) {
+		for _, pattern := range r.Patterns[name] {
+			v.pattern("proxy.backends.routing.patterns."+name, pattern)
+		}
+	}
+	for _, name := range sortedKeys(r.Weights) {
+		if w := r.Weights[name]; w < 0 {
+			v.add("proxy.backends.routing.weights."+name, strconv.Itoa(w), "must not be negative")
+		}
+	}
+	v.duration("proxy.backends.routing.sticky_session_ttl", r.StickySessionTTL)
+	v.duration("proxy.backends.routing.health_check_interval", r.HealthCheckInterval)
+	if r.Canary.Percent < 0 || r.Canary.Percent > 100 {
+		v.add("proxy.backends.routing.canary.percent", strconv.FormatFloat(r.Canary.Percent, 'g', -1, 64), "must be between 0 and 100")
+	}
+	if r.Canary.Percent > 0 {
+		v.required("proxy.backends.routing.canary.backend", r.Canary.Backend)
+	}
+	v.pattern("proxy.backends.routing.canary.model_pattern", r.Canary.ModelPattern)
+	return v.errs
+}
+
+// validator collects ValidationErrors.
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) add(field, value, message string) {
+	v.errs = append(v.errs, ValidationError{Field: field, Value: value, Message: message})
+}
+
+// required reports whether value is set, recording an error if not.
+func (v *validator) required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.add(field, "", "is required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) url(field, value string) {
+	if value != "" && !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		v.add(field, value, "must start with http:// or https://")
+	}
+}
+
+func (v *validator) duration(field string, d time.Duration) {
+	if d < 0 {
+		v.add(field, d.String(), "must not be negative")
+	}
+}
+
+func (v *validator) logLevel(field, value string) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "debug", "info", "warn", "warning", "error":
+	default:
+		v.add(field, value, "must be debug, info, warn or error")
+	}
+}
+
+// pattern checks that a routing pattern starting with "~" compiles as a
+// regexp. The router cannot start without it, so a failure is fatal.
+func (v *validator) pattern(field, value string) {
+	expr, ok := strings.CutPrefix(value, "~")
+	if !ok {
+		return
+	}
+	if _, err := regexp.Compile(expr); err != nil {
+		v.errs = append(v.errs, ValidationError{Field: field, Value: value, Message: "invalid regexp: " + err.Error(), Fatal: true})
+	}
+}
+
+// rate checks a rate limit such as "60/m". The units are those the proxy
+// accepts: s, m or h, or their longer spellings.
+func (v *validator) rate(field, value string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	n, unit, ok := strings.Cut(value, "/")
+	count, err := strconv.Atoi(strings.TrimSpace(n))
+	if ok && err == nil && count > 0 && slices.Contains(rateUnits, strings.TrimSpace(unit)) {
+		return
+	}
+	v.add(field, value, "must be N/s, N/m or N/h")
+}
+
+// rateUnits are the units a rate limit may use, as the proxy parses them.
+var rateUnits = []string{
+	"s", "sec", "second", "seconds",
+	"m", "min", "minute", "minutes",
+	"h", "hr", "hour", "hours",
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateDefaults(t *testing.T) {
+	if errs := Validate(DefaultConfig()); len(errs) != 0 {
+		t.Errorf("default config has problems: %v", errs)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		field  string
+	}{
+		{"rate unit", func(c *Config) { c.Proxy.DefaultRate = "60/d" }, "proxy.default_rate"},
+		{"rate count", func(c *Config) { c.Proxy.DefaultRate = "many/m" }, "proxy.default_rate"},
+		{"url scheme", func(c *Config) { c.Proxy.BaseURL = "localhost:8080" }, "proxy.base_url"},
+		{"log level", func(c *Config) { c.Proxy.LogLevel = "verbose" }, "proxy.log_level"},
+		{"negative duration", func(c *Config) { c.Proxy.CacheTTL = -1 }, "proxy.cache_ttl"},
+		{"tls pair", func(c *Config) { c.Proxy.TLSCertFile = "cert.pem" }, "proxy.tls_key_file"},
+		{"payments url", func(c *Config) { c.Proxy.Payments.Enabled = true }, "proxy.payments.token_meter_url"},
+		{"moderation backend", func(c *Config) { c.Proxy.Moderation.Enabled = true }, "proxy.moderation.backend"},
+		{"custom base url", func(c *Config) {
+			c.Proxy.Backends.Custom = map[string]CustomBackendConfig{"local": {Type: "openai"}}
+		}, "proxy.backends.custom.local.base_url"},
+		{"canary percent", func(c *Config) {
+			c.Proxy.Backends.Routing.Canary = CanaryConfig{Backend: "codex", Percent: 120}
+		}, "proxy.backends.routing.canary.percent"},
+		{"routing weight", func(c *Config) {
+			c.Proxy.Backends.Routing.Weights = map[string]int{"codex": 2, "anthropic": -1}
+		}, "proxy.backends.routing.weights.anthropic"},
+		{"routing regexp", func(c *Config) {
+			c.Proxy.Backends.Routing.Patterns = map[string][]string{"codex": {"~gpt-("}}
+		}, "proxy.backends.routing.patterns.codex"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(&cfg)
+			errs := Validate(cfg)
+			if len(errs) != 1 || errs[0].Field != tt.field {
+				t.Fatalf("Validate() = %v, want one error for %s", errs, tt.field)
+			}
+		})
+	}
+}
+
+func TestValidateRateUnits(t *testing.T) {
+	for _, rate := range []string{"10/s", "60/m", "1000/h", "60/min"} {
+		cfg := DefaultConfig()
+		cfg.Proxy.DefaultRate = rate
+		if errs := Validate(cfg); len(errs) != 0 {
+			t.Errorf("rate %q: %v", rate, errs)
+		}
+	}
+}
+
+func TestValidateRoutingPatternsFatal(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Proxy.Backends.Routing.Patterns = map[string][]string{
+		"anthropic": {"claude-", `~^claude-3\.5-sonnet$`},
+	}
+	if err := Validate(cfg).Fatal(); err != nil {
+		t.Fatalf("expected valid patterns, got %v", err)
+	}
+
+	cfg.Proxy.Backends.Routing.Patterns["openai"] = []string{"~gpt-(4"}
+	err := Validate(cfg).Fatal()
+	if err == nil || !strings.Contains(err.Error(), "routing.patterns.openai") || !strings.Contains(err.Error(), "~gpt-(4") {
+		t.Fatalf("expected descriptive error, got %v", err)
+	}
+
+	cfg.Proxy.Backends.Routing.Patterns = nil
+	cfg.Proxy.Backends.Routing.Canary.ModelPattern = "~["
+	if err := Validate(cfg).Fatal(); err == nil || !strings.Contains(err.Error(), "canary") {
+		t.Fatalf("expected canary pattern error, got %v", err)
+	}
+
+	// Other problems are warnings only.
+	cfg = DefaultConfig()
+	cfg.Proxy.DefaultRate = "60/d"
+	if errs := Validate(cfg); len(errs) != 1 || errs.Fatal() != nil {
+		t.Fatalf("expected one non-fatal problem, got %v", errs)
+	}
+}
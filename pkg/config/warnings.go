@@ -0,0 +1,225 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Warning is a problem in a config file that does not stop it loading: an
+// unknown key, a value of the wrong type, a deprecated key or a setting
+// Validate rejects. The setting keeps its default (or its base value, in an
+// overlay) unless it is a deprecated key.
+type Warning struct {
+	File    string
+	Line    int    // 0 when not tied to a line
+	Field   string // YAML path such as "proxy.cache_ttl"
+	Message string
+}
+
+func (w Warning) String() string {
+	loc := w.File
+	if w.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", w.File, w.Line)
+	}
+	if w.Field == "" {
+		return fmt.Sprintf("%s: %s", loc, w.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", loc, w.Field, w.Message)
+}
+
+// deprecatedFields maps the YAML paths of renamed settings to their
+// replacements. A file using an old path still loads: its value moves to
+// the new path, unless that is also set, and a warning names the new path.
+var deprecatedFields = map[string]string{
+	// The Codex backend has always read its credentials from proxy.auth_path.
+	"proxy.backends.codex.credentials_path": "proxy.auth_path",
+}
+
+// decodeFile decodes the YAML document in buf over cfg and returns the
+// problems found in it. The error is set only when buf is not valid YAML.
+func decodeFile(path string, buf []byte, cfg *Config) ([]Warning, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(buf, &doc); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+
+	var warnings []Warning
+	warn := func(line int, field, message string) {
+		warnings = append(warnings, Warning{File: path, Line: line, Field: field, Message: message})
+	}
+	moveDeprecated(root, warn)
+	lines := map[int]string{}
+	checkKeys(root, reflect.TypeOf(*cfg), "", lines, warn)
+
+	if err := root.Decode(cfg); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return warnings, fmt.Errorf("parse %s: %w", path, err)
+		}
+		for _, msg := range typeErr.Errors {
+			var line int
+			if n, _ := fmt.Sscanf(msg, "line %d:", &line); n == 1 {
+				msg = strings.TrimSpace(msg[strings.Index(msg, ":")+1:])
+			}
+			if strings.Contains(msg, "time.Duration") {
+				msg += " (durations look like 90s, 5m or 6h)"
+			}
+			warn(line, lines[line], msg)
+		}
+	}
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].Line < warnings[j].Line })
+	return warnings, nil
+}
+
+// checkKeys reports mapping keys under node that no field of t accepts,
+// and records the path of each key by line for locating decode errors.
+func checkKeys(node *yaml.Node, t reflect.Type, path string, lines map[int]string, warn func(int, string, string)) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		var fields map[string]reflect.Type
+		switch t.Kind() {
+		case reflect.Struct:
+			fields = yamlFields(t)
+		case reflect.Map:
+		default:
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue // merge key; its mapping is checked where it is defined
+			}
+			field := key.Value
+			if path != "" {
+				field = path + "." + key.Value
+			}
+			lines[key.Line] = field
+			elem := t
+			if fields == nil {
+				elem = t.Elem()
+			} else if ft, ok := fields[key.Value]; ok {
+				elem = ft
+			} else {
+				warn(key.Line, field, "unknown field")
+				continue
+			}
+			checkKeys(value, elem, field, lines, warn)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice {
+			return
+		}
+		for i, item := range node.Content {
+			field := fmt.Sprintf("%s[%d]", path, i)
+			lines[item.Line] = field
+			checkKeys(item, t.Elem(), field, lines, warn)
+		}
+	}
+}
+
+// yamlFields returns the types of t's fields by YAML key.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if name := yamlName(f); name != "" {
+			fields[name] = f.Type
+		}
+	}
+	return fields
+}
+
+// yamlName returns the key yaml.v3 uses for f, or "" if it skips f.
+func yamlName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+// moveDeprecated moves the values of deprecated keys under root to their
+// replacement paths.
+func moveDeprecated(root *yaml.Node, warn func(int, string, string)) {
+	olds := make([]string, 0, len(deprecatedFields))
+	for old := range deprecatedFields {
+		olds = append(olds, old)
+	}
+	sort.Strings(olds)
+	for _, old := range olds {
+		replacement := deprecatedFields[old]
+		parent, i := findKey(root, strings.Split(old, "."), false)
+		if parent == nil {
+			continue
+		}
+		key, value := parent.Content[i], parent.Content[i+1]
+		parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+		if dst, _ := findKey(root, strings.Split(replacement, "."), false); dst != nil {
+			warn(key.Line, old, fmt.Sprintf("deprecated and ignored because %s is also set", replacement))
+			continue
+		}
+		segments := strings.Split(replacement, ".")
+		dst := root
+		if len(segments) > 1 {
+			parent, i := findKey(root, segments[:len(segments)-1], true)
+			if parent == nil {
+				continue
+			}
+			dst = parent.Content[i+1]
+		}
+		newKey := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: segments[len(segments)-1], Line: key.Line}
+		dst.Content = append(dst.Content, newKey, value)
+		warn(key.Line, old, fmt.Sprintf("deprecated; use %s", replacement))
+	}
+}
+
+// findKey returns the mapping holding the key at path and the key's index
+// in it, or nil when there is none. With create, missing keys along path
+// are added with empty mappings as their values.
+func findKey(root *yaml.Node, path []string, create bool) (*yaml.Node, int) {
+	node := root
+	for depth, segment := range path {
+		if node.Kind != yaml.MappingNode {
+			return nil, 0
+		}
+		found := -1
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == segment {
+				found = i
+				break
+			}
+		}
+		if found < 0 {
+			if !create {
+				return nil, 0
+			}
+			node.Content = append(node.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: segment},
+				&yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"})
+			found = len(node.Content) - 2
+		}
+		if depth == len(path)-1 {
+			return node, found
+		}
+		node = node.Content[found+1]
+	}
+	return nil, 0
+}
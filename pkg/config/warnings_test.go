@@ -0,0 +1,88 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadFromWarnings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, `proxy:
+  cache_ttl: 6hours
+  log_levle: debug
+  default_rate: 60/day
+  listen: "0.0.0.0:8080"
+`)
+
+	cfg, warnings, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if cfg.Proxy.Listen != "0.0.0.0:8080" {
+		t.Errorf("Listen = %q, want the file's value despite the warnings", cfg.Proxy.Listen)
+	}
+	if cfg.Proxy.CacheTTL != 6*time.Hour {
+		t.Errorf("CacheTTL = %v, want the default", cfg.Proxy.CacheTTL)
+	}
+
+	want := []struct {
+		line  int
+		field string
+		msg   string
+	}{
+		{2, "proxy.cache_ttl", "time.Duration"},
+		{3, "proxy.log_levle", "unknown field"},
+		{0, "proxy.default_rate", "must be N/s, N/m or N/h"},
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("warnings = %v, want %d", warnings, len(want))
+	}
+	for i, w := range want {
+		got := warnings[i]
+		if got.Line != w.line || got.Field != w.field || !strings.Contains(got.Message, w.msg) {
+			t.Errorf("warnings[%d] = %+v, want line %d %s %q", i, got, w.line, w.field, w.msg)
+		}
+	}
+	if got := warnings[0].String(); !strings.HasPrefix(got, path+":2: proxy.cache_ttl: ") {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestLoadFromDeprecatedField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "proxy:\n  backends:\n    codex:\n      credentials_path: /srv/codex/auth.json\n")
+
+	cfg, warnings, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if cfg.Proxy.AuthPath != "/srv/codex/auth.json" {
+		t.Errorf("AuthPath = %q, want the deprecated key's value", cfg.Proxy.AuthPath)
+	}
+	if len(warnings) != 1 || warnings[0].Field != "proxy.backends.codex.credentials_path" || !strings.Contains(warnings[0].Message, "use proxy.auth_path") {
+		t.Errorf("warnings = %v, want one naming the replacement", warnings)
+	}
+
+	writeConfig(t, path, "proxy:\n  auth_path: /srv/auth.json\n  backends:\n    codex:\n      credentials_path: /srv/codex/auth.json\n")
+	cfg, warnings, err = LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom: %v", err)
+	}
+	if cfg.Proxy.AuthPath != "/srv/auth.json" {
+		t.Errorf("AuthPath = %q, want the replacement key to win", cfg.Proxy.AuthPath)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0].Message, "ignored because proxy.auth_path is also set") {
+		t.Errorf("warnings = %v, want the deprecated key reported as ignored", warnings)
+	}
+}
+
+func TestLoadFromParseError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "proxy: [unterminated\n")
+
+	if _, _, err := LoadFrom(path); err == nil {
+		t.Error("LoadFrom: want an error for invalid YAML")
+	}
+}
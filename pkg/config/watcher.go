@@ -0,0 +1,100 @@
+package config
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+)
+
+// DefaultWatchInterval is how often a Watcher checks its file when no
+// interval is given.
+const DefaultWatchInterval = 5 * time.Second
+
+// Watcher reloads a config file when it changes on disk or when Trigger is
+// called, and passes each config that loads and validates to a callback.
+// Changes are found by polling the file's size and modification time.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	onChange func(Config)
+	trigger  chan struct{}
+}
+
+// NewWatcher returns a Watcher for path that calls onChange with each
+// reloaded config. An interval of zero uses DefaultWatchInterval; a
+// negative one disables polling, leaving only Trigger.
+func NewWatcher(path string, interval time.Duration, onChange func(Config)) *Watcher {
+	if interval == 0 {
+		interval = DefaultWatchInterval
+	}
+	return &Watcher{
+		path:     path,
+		interval: interval,
+		onChange: onChange,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Trigger asks the watcher to reload the file now, whether or not it
+// changed, as on SIGHUP. It does not block; triggers made while a reload is
+// pending are merged.
+func (w *Watcher) Trigger() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run watches the file until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	last := statFile(w.path)
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-w.trigger:
+			last = statFile(w.path)
+			w.reload()
+		case <-tick:
+			if cur := statFile(w.path); cur != last {
+				last = cur
+				w.reload()
+			}
+		}
+	}
+}
+
+// reload loads the file and hands it to the callback. A file that fails to
+// load keeps the running config.
+func (w *Watcher) reload() {
+	cfg, warnings, err := LoadFile(w.path)
+	for _, warning := range warnings {
+		log.Printf("[WARN] config: %s", warning)
+	}
+	if err != nil {
+		log.Printf("[WARN] config: reload %s: %v", w.path, err)
+		return
+	}
+	w.onChange(cfg)
+}
+
+// fileStamp identifies a version of a file for change detection.
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func statFile(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{size: info.Size(), modTime: info.ModTime()}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func startWatcher(t *testing.T, path string, interval time.Duration) (*Watcher, <-chan Config) {
+	t.Helper()
+	got := make(chan Config, 4)
+	w := NewWatcher(path, interval, func(cfg Config) { got <- cfg })
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go w.Run(ctx)
+	return w, got
+}
+
+func TestWatcherReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "proxy:\n  log_level: info\n")
+	_, got := startWatcher(t, path, 10*time.Millisecond)
+
+	// Let the watcher record the original file before changing it.
+	time.Sleep(50 * time.Millisecond)
+	writeConfig(t, path, "proxy:\n  log_level: debug\n  default_burst: 42\n")
+
+	select {
+	case cfg := <-got:
+		if cfg.Proxy.LogLevel != "debug" || cfg.Proxy.DefaultBurst != 42 {
+			t.Errorf("reloaded log_level=%q default_burst=%d", cfg.Proxy.LogLevel, cfg.Proxy.DefaultBurst)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback not called after the file changed")
+	}
+}
+
+func TestWatcherTrigger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "proxy:\n  default_rate: 5/m\n")
+	w, got := startWatcher(t, path, -1)
+
+	w.Trigger()
+	select {
+	case cfg := <-got:
+		if cfg.Proxy.DefaultRate != "5/m" {
+			t.Errorf("DefaultRate = %q, want 5/m", cfg.Proxy.DefaultRate)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("callback not called after Trigger")
+	}
+}
+
+func TestWatcherKeepsConfigOnInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, "proxy: [not a map\n")
+	w, got := startWatcher(t, path, -1)
+
+	w.Trigger()
+	select {
+	case cfg := <-got:
+		t.Fatalf("callback called with %+v for an invalid file", cfg.Proxy)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,61 @@
+package harness
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultBatchConcurrency caps concurrent turns in RunBatch when no limit is given.
+const DefaultBatchConcurrency = 8
+
+// RunBatch is the default BatchTurns implementation shared by all harnesses.
+// It runs each turn through collect with at most opts.MaxConcurrency in
+// flight (0 = DefaultBatchConcurrency) and reports every turn to onResult
+// with its index in turns. onResult calls are serialized. A failed turn does
+// not stop the others; turns not started before ctx is cancelled are
+// reported with ctx.Err(), which RunBatch also returns.
+func RunBatch(
+	ctx context.Context,
+	collect func(ctx context.Context, turn *Turn) (*TurnResult, error),
+	turns []*Turn,
+	onResult func(index int, result *TurnResult, err error),
+	opts LoopOptions,
+) error {
+	maxConcurrency := opts.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = DefaultBatchConcurrency
+	}
+	var (
+		wg  sync.WaitGroup
+		mu  sync.Mutex
+		sem = make(chan struct{}, maxConcurrency)
+	)
+	report := func(index int, result *TurnResult, err error) {
+		if onResult == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		onResult(index, result, err)
+	}
+	for i, turn := range turns {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			for j := i; j < len(turns); j++ {
+				report(j, nil, ctx.Err())
+			}
+			wg.Wait()
+			return ctx.Err()
+		}
+		wg.Add(1)
+		go func(i int, turn *Turn) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			result, err := collect(ctx, turn)
+			report(i, result, err)
+		}(i, turn)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
@@ -0,0 +1,121 @@
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBatchOrderingAndPartialFailure(t *testing.T) {
+	turns := make([]*Turn, 6)
+	for i := range turns {
+		turns[i] = &Turn{Model: fmt.Sprintf("m%d", i)}
+	}
+	boom := errors.New("boom")
+	collect := func(ctx context.Context, turn *Turn) (*TurnResult, error) {
+		// Finish in reverse order so results arrive out of index order.
+		var n int
+		fmt.Sscanf(turn.Model, "m%d", &n)
+		time.Sleep(time.Duration(len(turns)-n) * 5 * time.Millisecond)
+		if n == 3 {
+			return nil, boom
+		}
+		return &TurnResult{FinalText: turn.Model}, nil
+	}
+
+	results := make([]string, len(turns))
+	errs := make([]error, len(turns))
+	calls := 0
+	err := RunBatch(context.Background(), collect, turns, func(i int, res *TurnResult, err error) {
+		calls++
+		errs[i] = err
+		if res != nil {
+			results[i] = res.FinalText
+		}
+	}, LoopOptions{})
+	if err != nil {
+		t.Fatalf("RunBatch: %v", err)
+	}
+	if calls != len(turns) {
+		t.Fatalf("expected %d results, got %d", len(turns), calls)
+	}
+	for i := range turns {
+		if i == 3 {
+			if !errors.Is(errs[i], boom) {
+				t.Fatalf("turn 3: expected boom, got %v", errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil || results[i] != fmt.Sprintf("m%d", i) {
+			t.Fatalf("turn %d: got %q, %v", i, results[i], errs[i])
+		}
+	}
+}
+
+func TestRunBatchConcurrencyLimit(t *testing.T) {
+	var active, peak atomic.Int32
+	collect := func(ctx context.Context, turn *Turn) (*TurnResult, error) {
+		n := active.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		active.Add(-1)
+		return &TurnResult{}, nil
+	}
+	turns := make([]*Turn, 10)
+	if err := RunBatch(context.Background(), collect, turns, nil, LoopOptions{MaxConcurrency: 3}); err != nil {
+		t.Fatalf("RunBatch: %v", err)
+	}
+	if got := peak.Load(); got > 3 || got < 2 {
+		t.Fatalf("expected at most 3 concurrent turns, peak was %d", got)
+	}
+}
+
+func TestRunBatchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	collect := func(ctx context.Context, turn *Turn) (*TurnResult, error) {
+		cancel()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	reported := map[int]error{}
+	err := RunBatch(ctx, collect, make([]*Turn, 4), func(i int, _ *TurnResult, err error) {
+		reported[i] = err
+	}, LoopOptions{MaxConcurrency: 1})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if len(reported) != 4 {
+		t.Fatalf("expected every turn reported, got %v", reported)
+	}
+	for i, err := range reported {
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("turn %d: expected context.Canceled, got %v", i, err)
+		}
+	}
+}
+
+func TestMockBatchTurns(t *testing.T) {
+	m := NewMock(MockConfig{Responses: [][]Event{
+		{NewTextEvent("a")},
+		{NewTextEvent("b")},
+	}})
+	var texts []string
+	err := m.BatchTurns(context.Background(), []*Turn{{}, {}}, func(_ int, res *TurnResult, err error) {
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+			return
+		}
+		texts = append(texts, res.FinalText)
+	}, LoopOptions{})
+	if err != nil || len(texts) != 2 {
+		t.Fatalf("got %v, %v", texts, err)
+	}
+}
@@ -0,0 +1,102 @@
+package harness
+
+import "strings"
+
+// CapabilitySet describes what a harness (or one of its models) supports.
+// It doubles as a requirement: see Covers.
+type CapabilitySet struct {
+	// MaxContextTokens is the context window; 0 means unknown.
+	MaxContextTokens         int      `json:"max_context_tokens,omitempty"`
+	SupportsVision           bool     `json:"supports_vision"`
+	SupportsTools            bool     `json:"supports_tools"`
+	SupportsReasoning        bool     `json:"supports_reasoning"`
+	SupportsStructuredOutput bool     `json:"supports_structured_output"`
+	SupportedMediaTypes      []string `json:"supported_media_types,omitempty"`
+}
+
+// Covers reports whether c satisfies every requirement set in need. Zero
+// fields in need are not requirements, and an unknown context window is
+// assumed to be large enough.
+func (c CapabilitySet) Covers(need CapabilitySet) bool {
+	if need.SupportsVision && !c.SupportsVision ||
+		need.SupportsTools && !c.SupportsTools ||
+		need.SupportsReasoning && !c.SupportsReasoning ||
+		need.SupportsStructuredOutput && !c.SupportsStructuredOutput {
+		return false
+	}
+	if need.MaxContextTokens > 0 && c.MaxContextTokens > 0 && c.MaxContextTokens < need.MaxContextTokens {
+		return false
+	}
+	for _, mt := range need.SupportedMediaTypes {
+		if !containsFold(c.SupportedMediaTypes, mt) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsZero reports whether the set requires nothing.
+func (c CapabilitySet) IsZero() bool {
+	return c.MaxContextTokens == 0 && !c.SupportsVision && !c.SupportsTools &&
+		!c.SupportsReasoning && !c.SupportsStructuredOutput && len(c.SupportedMediaTypes) == 0
+}
+
+// ImageMediaTypes are the image formats accepted by the vision-capable
+// providers godex talks to.
+var ImageMediaTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp"}
+
+// CapabilityRule maps a model-name prefix to its capabilities.
+type CapabilityRule struct {
+	Prefix string
+	Caps   CapabilitySet
+}
+
+// LookupCapabilities returns the capabilities of the rule with the longest
+// prefix matching model (case-insensitive), or fallback if none match.
+// With an empty model it returns the union of all rules and fallback.
+func LookupCapabilities(rules []CapabilityRule, fallback CapabilitySet, model string) CapabilitySet {
+	if model == "" {
+		out := fallback
+		for _, rule := range rules {
+			out = unionCapabilities(out, rule.Caps)
+		}
+		return out
+	}
+	lower := strings.ToLower(model)
+	best := -1
+	for i, rule := range rules {
+		if strings.HasPrefix(lower, rule.Prefix) && (best < 0 || len(rule.Prefix) > len(rules[best].Prefix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return fallback
+	}
+	return rules[best].Caps
+}
+
+func unionCapabilities(a, b CapabilitySet) CapabilitySet {
+	out := CapabilitySet{
+		MaxContextTokens:         max(a.MaxContextTokens, b.MaxContextTokens),
+		SupportsVision:           a.SupportsVision || b.SupportsVision,
+		SupportsTools:            a.SupportsTools || b.SupportsTools,
+		SupportsReasoning:        a.SupportsReasoning || b.SupportsReasoning,
+		SupportsStructuredOutput: a.SupportsStructuredOutput || b.SupportsStructuredOutput,
+	}
+	out.SupportedMediaTypes = append(out.SupportedMediaTypes, a.SupportedMediaTypes...)
+	for _, mt := range b.SupportedMediaTypes {
+		if !containsFold(out.SupportedMediaTypes, mt) {
+			out.SupportedMediaTypes = append(out.SupportedMediaTypes, mt)
+		}
+	}
+	return out
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package harness
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCapabilitySetCovers(t *testing.T) {
+	have := CapabilitySet{
+		MaxContextTokens:    128000,
+		SupportsVision:      true,
+		SupportsTools:       true,
+		SupportedMediaTypes: ImageMediaTypes,
+	}
+	cases := []struct {
+		name string
+		need CapabilitySet
+		want bool
+	}{
+		{"nothing", CapabilitySet{}, true},
+		{"vision", CapabilitySet{SupportsVision: true}, true},
+		{"reasoning", CapabilitySet{SupportsReasoning: true}, false},
+		{"context fits", CapabilitySet{MaxContextTokens: 100000}, true},
+		{"context too large", CapabilitySet{MaxContextTokens: 200000}, false},
+		{"media type", CapabilitySet{SupportedMediaTypes: []string{"IMAGE/PNG"}}, true},
+		{"pdf", CapabilitySet{SupportedMediaTypes: []string{"application/pdf"}}, false},
+	}
+	for _, tc := range cases {
+		if got := have.Covers(tc.need); got != tc.want {
+			t.Errorf("%s: Covers = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+	if !(CapabilitySet{SupportsVision: true}).Covers(CapabilitySet{MaxContextTokens: 1 << 30}) {
+		t.Error("an unknown context window should not reject a request")
+	}
+}
+
+func TestLookupCapabilities(t *testing.T) {
+	rules := []CapabilityRule{
+		{Prefix: "m-", Caps: CapabilitySet{SupportsTools: true}},
+		{Prefix: "m-vision", Caps: CapabilitySet{SupportsVision: true, SupportedMediaTypes: []string{"image/png"}}},
+	}
+	fallback := CapabilitySet{MaxContextTokens: 8000}
+
+	if got := LookupCapabilities(rules, fallback, "M-Vision-2"); !got.SupportsVision || got.SupportsTools {
+		t.Fatalf("expected longest prefix to win, got %+v", got)
+	}
+	if got := LookupCapabilities(rules, fallback, "other"); got.MaxContextTokens != 8000 || got.SupportsTools {
+		t.Fatalf("expected fallback, got %+v", got)
+	}
+	all := LookupCapabilities(rules, fallback, "")
+	if !all.SupportsTools || !all.SupportsVision || all.MaxContextTokens != 8000 || len(all.SupportedMediaTypes) != 1 {
+		t.Fatalf("expected union without a model, got %+v", all)
+	}
+}
+
+func TestModelContext(t *testing.T) {
+	if _, ok := Model(context.Background()); ok {
+		t.Fatal("expected no model")
+	}
+	if got, ok := Model(WithModel(context.Background(), "gpt-4o")); !ok || got != "gpt-4o" {
+		t.Fatalf("got %q, %v", got, ok)
+	}
+}
@@ -0,0 +1,220 @@
+package claude
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/anthropics/anthropic-sdk-go/option"
+
+	"godex/pkg/harness"
+)
+
+// MaxBatchRequests is the most requests one Message Batch accepts.
+const MaxBatchRequests = 10000
+
+// DefaultBatchPollInterval is how often PollBatch checks a running batch.
+const DefaultBatchPollInterval = 30 * time.Second
+
+// BatchResult is the outcome of one request in a Message Batch.
+type BatchResult struct {
+	// Index is the request's position in the CreateBatch call.
+	Index int
+	// Message is the reply when the request succeeded.
+	Message *anthropic.Message
+	// Err is set when the request errored, was canceled or expired.
+	Err error
+}
+
+// CreateBatch submits requests as one Message Batch via
+// POST /v1/messages/batches and returns the batch ID. Each request's custom
+// ID is its index in requests.
+func (w *ClientWrapper) CreateBatch(ctx context.Context, requests []anthropic.MessageNewParams) (string, error) {
+	if len(requests) == 0 || len(requests) > MaxBatchRequests {
+		return "", fmt.Errorf("create batch: need 1 to %d requests, got %d", MaxBatchRequests, len(requests))
+	}
+	body := anthropic.MessageBatchNewParams{}
+	var betas []string
+	for i, params := range requests {
+		betas = append(betas, requestBetas(params)...)
+		// The batch params type mirrors MessageNewParams field for field.
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return "", fmt.Errorf("create batch: encode request %d: %w", i, err)
+		}
+		var batchParams anthropic.MessageBatchNewParamsRequestParams
+		if err := json.Unmarshal(raw, &batchParams); err != nil {
+			return "", fmt.Errorf("create batch: convert request %d: %w", i, err)
+		}
+		body.Requests = append(body.Requests, anthropic.MessageBatchNewParamsRequest{
+			CustomID: strconv.Itoa(i),
+			Params:   batchParams,
+		})
+	}
+
+	client, err := w.batchClient(betas...)
+	if err != nil {
+		return "", err
+	}
+	batch, err := client.Messages.Batches.New(ctx, body)
+	if err != nil {
+		return "", fmt.Errorf("create batch: %w", err)
+	}
+	return batch.ID, nil
+}
+
+// batchClient returns a client authorized with the current access token
+// and sending the default, configured and extra betas. A batch can run for
+// hours, so callers ask for a new client per call rather than keeping one.
+func (w *ClientWrapper) batchClient(extra ...string) (anthropic.Client, error) {
+	token, err := w.tokens.AccessToken()
+	if err != nil {
+		return anthropic.Client{}, fmt.Errorf("get access token: %w", err)
+	}
+	return anthropic.NewClient(
+		option.WithAuthToken(token),
+		option.WithHeader("anthropic-beta", w.betaHeader(extra...)),
+	), nil
+}
+
+// PollBatch waits until the batch's processing_status is "ended", checking
+// every ClientConfig.BatchPollInterval, then returns one result per request
+// ordered by Index. Each check uses a fresh access token, since the token
+// can expire while the batch runs.
+func (w *ClientWrapper) PollBatch(ctx context.Context, batchID string) ([]*BatchResult, error) {
+	var client anthropic.Client
+	for {
+		var err error
+		if client, err = w.batchClient(); err != nil {
+			return nil, err
+		}
+		batch, err := client.Messages.Batches.Get(ctx, batchID)
+		if err != nil {
+			return nil, fmt.Errorf("poll batch %s: %w", batchID, err)
+		}
+		if batch.ProcessingStatus == anthropic.MessageBatchProcessingStatusEnded {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(w.cfg.BatchPollInterval):
+		}
+	}
+
+	stream := client.Messages.Batches.ResultsStreaming(ctx, batchID)
+	defer stream.Close()
+	var results []*BatchResult
+	for stream.Next() {
+		res := stream.Current()
+		index, err := strconv.Atoi(res.CustomID)
+		if err != nil {
+			return nil, fmt.Errorf("batch %s: unexpected custom_id %q", batchID, res.CustomID)
+		}
+		out := &BatchResult{Index: index}
+		switch res.Result.Type {
+		case "succeeded":
+			msg := res.Result.Message
+			out.Message = &msg
+		case "errored":
+			out.Err = fmt.Errorf("batch request %d: %s: %s", index, res.Result.Error.Error.Type, res.Result.Error.Error.Message)
+		default:
+			out.Err = fmt.Errorf("batch request %d: %s", index, res.Result.Type)
+		}
+		results = append(results, out)
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("batch %s results: %w", batchID, err)
+	}
+	slices.SortFunc(results, func(a, b *BatchResult) int { return cmp.Compare(a.Index, b.Index) })
+	return results, nil
+}
+
+// RunBatch runs turns through the Message Batches API, which costs half as
+// much as individual calls but can take up to 24 hours. It blocks until
+// every batch has ended, submitting at most MaxBatchRequests turns per
+// batch, and reports each turn to onResult with its index in turns. A turn
+// whose request cannot be built is reported without being sent, and one
+// missing from the batch results is reported as an error.
+func (h *Harness) RunBatch(ctx context.Context, turns []*harness.Turn, onResult func(index int, result *harness.TurnResult, err error)) error {
+	if h.client == nil {
+		return errors.New("claude: batch requires a client")
+	}
+	var indexes []int
+	var requests []anthropic.MessageNewParams
+	for i, turn := range turns {
+		params, err := h.buildRequest(turn)
+		if err != nil {
+			onResult(i, nil, fmt.Errorf("claude: build request: %w", err))
+			continue
+		}
+		indexes = append(indexes, i)
+		requests = append(requests, params)
+	}
+
+	for start := 0; start < len(requests); start += MaxBatchRequests {
+		end := min(start+MaxBatchRequests, len(requests))
+		started := time.Now()
+		id, err := h.client.CreateBatch(ctx, requests[start:end])
+		if err != nil {
+			return fmt.Errorf("claude: %w", err)
+		}
+		results, err := h.client.PollBatch(ctx, id)
+		if err != nil {
+			return fmt.Errorf("claude: %w", err)
+		}
+		reported := make([]bool, end-start)
+		for _, res := range results {
+			if res.Index < 0 || res.Index >= end-start || reported[res.Index] {
+				continue
+			}
+			reported[res.Index] = true
+			turnIndex := indexes[start+res.Index]
+			if res.Err != nil {
+				onResult(turnIndex, nil, fmt.Errorf("claude: %w", res.Err))
+				continue
+			}
+			result := messageTurnResult(res.Message)
+			result.Duration = time.Since(started)
+			_ = harness.ParseStructuredOutput(turns[turnIndex], result)
+			onResult(turnIndex, result, nil)
+		}
+		for i, ok := range reported {
+			if !ok {
+				onResult(indexes[start+i], nil, fmt.Errorf("claude: batch %s returned no result for request %d", id, i))
+			}
+		}
+	}
+	return nil
+}
+
+// messageTurnResult converts a complete Messages API reply to a TurnResult.
+func messageTurnResult(msg *anthropic.Message) *harness.TurnResult {
+	result := &harness.TurnResult{}
+	var text strings.Builder
+	for _, block := range msg.Content {
+		switch block.Type {
+		case "text":
+			text.WriteString(block.Text)
+		case "tool_use":
+			result.ToolCalls = append(result.ToolCalls, harness.ToolCallEvent{
+				CallID:    block.ID,
+				Name:      block.Name,
+				Arguments: string(block.Input),
+			})
+		}
+	}
+	result.FinalText = text.String()
+	u := msg.Usage
+	input := int(u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens)
+	result.Usage = harness.NewUsageEvent(input, int(u.OutputTokens)).Usage
+	result.Usage.CachedTokens = int(u.CacheReadInputTokens)
+	return result
+}
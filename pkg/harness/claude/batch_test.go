@@ -0,0 +1,163 @@
+package claude
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"godex/pkg/harness"
+)
+
+// batchServer fakes the Message Batches endpoints. The batch reports
+// in_progress on the first poll and ended on the next.
+type batchServer struct {
+	mu      sync.Mutex
+	created []map[string]any
+	betas   []string // anthropic-beta header of each create call
+	polls   int
+	results string
+}
+
+func (s *batchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch := func(status string) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id": "msgbatch_01", "type": "message_batch", "processing_status": status,
+			"created_at": "2025-01-01T00:00:00Z", "expires_at": "2025-01-02T00:00:00Z",
+			"request_counts": map[string]int{}, "results_url": nil,
+		})
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/messages/batches":
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		s.created = append(s.created, body)
+		s.betas = append(s.betas, r.Header.Get("anthropic-beta"))
+		batch("in_progress")
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/messages/batches/msgbatch_01":
+		s.polls++
+		if s.polls < 2 {
+			batch("in_progress")
+			return
+		}
+		batch("ended")
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/messages/batches/msgbatch_01/results":
+		w.Header().Set("Content-Type", "application/x-jsonl")
+		w.Write([]byte(s.results))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newBatchHarness(t *testing.T, srv *batchServer) *Harness {
+	t.Helper()
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+	t.Setenv("ANTHROPIC_BASE_URL", ts.URL)
+	tokens := NewTokenStore(writeCredentials(t, t.TempDir(), &Credentials{
+		AccessToken: "test-token",
+		ExpiresAt:   UnixMillis(time.Now().Add(time.Hour)),
+	}))
+	if err := tokens.Load(); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClientWrapper(tokens, ClientConfig{BatchPollInterval: time.Millisecond})
+	return New(Config{Client: client, UseBatchAPI: true})
+}
+
+func TestBatchTurns_MessageBatchesAPI(t *testing.T) {
+	srv := &batchServer{results: strings.Join([]string{
+		`{"custom_id":"1","result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"bad turn"}}}}`,
+		`{"custom_id":"0","result":{"type":"succeeded","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-6","content":[{"type":"text","text":"four"}],"stop_reason":"end_turn","usage":{"input_tokens":12,"output_tokens":3}}}}`,
+	}, "\n") + "\n"}
+	h := newBatchHarness(t, srv)
+
+	turns := []*harness.Turn{
+		{Messages: []harness.Message{{Role: "user", Content: "2+2?"}}},
+		{Messages: []harness.Message{{Role: "user", Content: "bad"}}},
+	}
+	results := make([]*harness.TurnResult, len(turns))
+	errs := make([]error, len(turns))
+	err := h.BatchTurns(context.Background(), turns, func(i int, res *harness.TurnResult, err error) {
+		results[i], errs[i] = res, err
+	}, harness.LoopOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(srv.created) != 1 {
+		t.Fatalf("expected one batch, got %d", len(srv.created))
+	}
+	reqs, _ := srv.created[0]["requests"].([]any)
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 batch requests, got %v", srv.created[0])
+	}
+	first := reqs[0].(map[string]any)
+	if first["custom_id"] != "0" || first["params"].(map[string]any)["messages"] == nil {
+		t.Errorf("unexpected batch request %v", first)
+	}
+	if srv.polls < 2 {
+		t.Errorf("expected polling until ended, got %d polls", srv.polls)
+	}
+
+	if errs[0] != nil || results[0] == nil || results[0].FinalText != "four" {
+		t.Fatalf("unexpected first result %+v, %v", results[0], errs[0])
+	}
+	if results[0].Usage == nil || results[0].Usage.InputTokens != 12 || results[0].Usage.OutputTokens != 3 {
+		t.Errorf("unexpected usage %+v", results[0].Usage)
+	}
+	if errs[1] == nil || !strings.Contains(errs[1].Error(), "bad turn") {
+		t.Errorf("expected the batch error for turn 1, got %v", errs[1])
+	}
+}
+
+func TestBatchTurns_MissingResultAndBetas(t *testing.T) {
+	srv := &batchServer{results: `{"custom_id":"0","result":{"type":"succeeded","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-6","content":[{"type":"text","text":"ok"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}}}` + "\n"}
+	h := newBatchHarness(t, srv)
+	h.promptCaching = true
+
+	turns := []*harness.Turn{
+		{Instructions: "be brief", Messages: []harness.Message{{Role: "user", Content: "one"}}},
+		{Instructions: "be brief", Messages: []harness.Message{{Role: "user", Content: "two"}}},
+	}
+	errs := make([]error, len(turns))
+	called := make([]bool, len(turns))
+	err := h.BatchTurns(context.Background(), turns, func(i int, res *harness.TurnResult, err error) {
+		called[i], errs[i] = true, err
+	}, harness.LoopOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !called[0] || errs[0] != nil {
+		t.Errorf("turn 0: called=%v err=%v", called[0], errs[0])
+	}
+	if !called[1] || errs[1] == nil || !strings.Contains(errs[1].Error(), "no result") {
+		t.Errorf("turn 1 should be reported missing: called=%v err=%v", called[1], errs[1])
+	}
+	if len(srv.betas) != 1 || !strings.Contains(srv.betas[0], promptCachingBeta) {
+		t.Errorf("batch betas = %q, want %s", srv.betas, promptCachingBeta)
+	}
+}
+
+func TestPollBatch_ContextCancelled(t *testing.T) {
+	srv := &batchServer{polls: -1000}
+	h := newBatchHarness(t, srv)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := h.client.PollBatch(ctx, "msgbatch_01"); err == nil {
+		t.Fatal("expected an error once the context ends")
+	}
+}
+
+func TestCreateBatch_RejectsEmpty(t *testing.T) {
+	if _, err := NewClientWrapper(nil, ClientConfig{}).CreateBatch(context.Background(), nil); err == nil {
+		t.Fatal("expected an error for an empty batch")
+	}
+}
@@ -0,0 +1,89 @@
+package claude
+
+import (
+	"strings"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+// Anthropic-Beta feature flags used by the harness.
+const (
+	oauthBeta               = "oauth-2025-04-20"
+	promptCachingBeta       = "prompt-caching-2024-07-31"
+	tokenCountingBeta       = "token-counting-2024-11-01"
+	interleavedThinkingBeta = "interleaved-thinking-2025-05-14"
+	output128kBeta          = "output-128k-2025-02-19"
+)
+
+// defaultBetas are sent on every request. OAuth tokens are rejected
+// without the oauth beta.
+var defaultBetas = []string{oauthBeta}
+
+// modelBeta is a beta that a model family needs for some requests.
+type modelBeta struct {
+	Prefix  string
+	Beta    string
+	Applies func(anthropic.MessageNewParams) bool
+}
+
+// modelBetas is the known table of model-specific betas, matched by
+// prefix against the request model.
+var modelBetas = []modelBeta{
+	// Claude 4 models only think between tool calls with this beta.
+	{Prefix: "claude-sonnet-4", Beta: interleavedThinkingBeta, Applies: thinkingWithTools},
+	{Prefix: "claude-opus-4", Beta: interleavedThinkingBeta, Applies: thinkingWithTools},
+	{Prefix: "claude-haiku-4", Beta: interleavedThinkingBeta, Applies: thinkingWithTools},
+	// Claude 3.7 Sonnet caps output at 64k tokens without this beta.
+	{Prefix: "claude-3-7-sonnet", Beta: output128kBeta, Applies: func(p anthropic.MessageNewParams) bool {
+		return p.MaxTokens > 64000
+	}},
+}
+
+func thinkingWithTools(p anthropic.MessageNewParams) bool {
+	return p.Thinking.OfEnabled != nil && len(p.Tools) > 0
+}
+
+// requestBetas returns the betas params needs: prompt caching when the
+// system prompt has a cache_control breakpoint, and any from modelBetas.
+func requestBetas(params anthropic.MessageNewParams) []string {
+	var betas []string
+	if usesPromptCaching(params) {
+		betas = append(betas, promptCachingBeta)
+	}
+	model := strings.ToLower(string(params.Model))
+	for _, mb := range modelBetas {
+		if strings.HasPrefix(model, mb.Prefix) && mb.Applies(params) {
+			betas = append(betas, mb.Beta)
+		}
+	}
+	return betas
+}
+
+// usesPromptCaching reports whether params set a cache_control breakpoint on
+// the system prompt.
+func usesPromptCaching(params anthropic.MessageNewParams) bool {
+	for _, block := range params.System {
+		if block.CacheControl.Type != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// betaHeader joins the default, configured and extra betas into one
+// Anthropic-Beta value, dropping blanks and duplicates.
+func (w *ClientWrapper) betaHeader(extra ...string) string {
+	seen := map[string]bool{}
+	var out []string
+	for _, list := range [][]string{defaultBetas, w.cfg.BetaFeatures, extra} {
+		for _, beta := range list {
+			beta = strings.TrimSpace(beta)
+			if beta == "" || seen[beta] {
+				continue
+			}
+			seen[beta] = true
+			out = append(out, beta)
+		}
+	}
+	return strings.Join(out, ",")
+}
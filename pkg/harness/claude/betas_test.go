@@ -0,0 +1,48 @@
+package claude
+
+import (
+	"reflect"
+	"testing"
+
+	"godex/pkg/harness"
+)
+
+func TestRequestBetas(t *testing.T) {
+	tools := []harness.ToolSpec{{Name: "shell", Parameters: map[string]any{"type": "object"}}}
+	cases := []struct {
+		name string
+		cfg  Config
+		turn harness.Turn
+		want []string
+	}{
+		{"plain", Config{}, harness.Turn{Model: "claude-sonnet-4-6"}, nil},
+		{"thinking without tools", Config{ThinkingBudget: 2048}, harness.Turn{Model: "claude-sonnet-4-6"}, nil},
+		{"thinking with tools", Config{ThinkingBudget: 2048}, harness.Turn{Model: "claude-opus-4-6", Tools: tools}, []string{interleavedThinkingBeta}},
+		{"3.7 with tools", Config{ThinkingBudget: 2048}, harness.Turn{Model: "claude-3-7-sonnet-20250219", Tools: tools}, nil},
+		{"3.7 long output", Config{DefaultMaxTokens: 100000}, harness.Turn{Model: "claude-3-7-sonnet-20250219"}, []string{output128kBeta}},
+		{"prompt caching", Config{EnablePromptCaching: true}, harness.Turn{Model: "claude-haiku-4-5", Instructions: "sys"}, []string{promptCachingBeta}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			params, err := New(tc.cfg).buildRequest(&tc.turn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := requestBetas(params); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestBetaHeader(t *testing.T) {
+	w := NewClientWrapper(nil, ClientConfig{BetaFeatures: []string{"computer-use-2025-01-24", " ", oauthBeta}})
+	got := w.betaHeader(promptCachingBeta, "computer-use-2025-01-24")
+	want := "oauth-2025-04-20,computer-use-2025-01-24,prompt-caching-2024-07-31"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := NewClientWrapper(nil, ClientConfig{}).betaHeader(); got != oauthBeta {
+		t.Errorf("expected only the default beta, got %q", got)
+	}
+}
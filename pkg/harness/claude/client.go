@@ -3,7 +3,13 @@ package claude
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
@@ -15,6 +21,7 @@ import (
 type ClientWrapper struct {
 	tokens *TokenStore
 	cfg    ClientConfig
+	sleep  func(ctx context.Context, d time.Duration) error // waits between retries
 }
 
 // ClientConfig holds configuration for the Claude client wrapper.
@@ -24,6 +31,21 @@ type ClientConfig struct {
 
 	// DefaultThinkingBudget is the default budget_tokens for extended thinking.
 	DefaultThinkingBudget int
+
+	// BetaFeatures are extra Anthropic-Beta flags sent on every request,
+	// in addition to the defaults and any the request itself needs.
+	BetaFeatures []string
+
+	// BatchPollInterval is how often PollBatch checks a running batch.
+	// Defaults to DefaultBatchPollInterval.
+	BatchPollInterval time.Duration
+
+	// MaxRetries is how many times StreamMessages retries a request
+	// rejected with HTTP 429 or 5xx (default 3).
+	MaxRetries int
+
+	// MaxRetryDelay caps the Retry-After wait between retries (default 60s).
+	MaxRetryDelay time.Duration
 }
 
 // NewClientWrapper creates a wrapper around the Anthropic token store.
@@ -34,55 +56,166 @@ func NewClientWrapper(tokens *TokenStore, cfg ClientConfig) *ClientWrapper {
 	if cfg.DefaultThinkingBudget <= 0 {
 		cfg.DefaultThinkingBudget = 10000
 	}
-	return &ClientWrapper{tokens: tokens, cfg: cfg}
+	if cfg.BatchPollInterval <= 0 {
+		cfg.BatchPollInterval = DefaultBatchPollInterval
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 3
+	}
+	if cfg.MaxRetryDelay <= 0 {
+		cfg.MaxRetryDelay = 60 * time.Second
+	}
+	return &ClientWrapper{tokens: tokens, cfg: cfg, sleep: sleepContext}
 }
 
 // StreamMessages starts a streaming Messages API call and invokes onEvent for
-// each raw Anthropic stream event.
+// each raw Anthropic stream event. A request rejected with HTTP 429 or 5xx
+// before any event arrives is retried up to MaxRetries times after the
+// Retry-After delay, unless that wait would pass the context deadline.
 func (w *ClientWrapper) StreamMessages(ctx context.Context, params anthropic.MessageNewParams, onEvent func(anthropic.MessageStreamEventUnion) error) error {
 	token, err := w.tokens.AccessToken()
 	if err != nil {
 		return fmt.Errorf("get access token: %w", err)
 	}
 
-	client := anthropic.NewClient(
+	opts := []option.RequestOption{
 		option.WithAuthToken(token),
-		option.WithHeader("anthropic-beta", "oauth-2025-04-20"),
-	)
+		option.WithHeader("anthropic-beta", w.betaHeader(requestBetas(params)...)),
+		option.WithMiddleware(leaveRateLimitRetries),
+	}
+	if id, ok := harness.RequestID(ctx); ok {
+		opts = append(opts, option.WithHeader("X-Request-ID", id))
+	}
+	client := anthropic.NewClient(opts...)
 
-	stream := client.Messages.NewStreaming(ctx, params)
-	for stream.Next() {
-		if err := onEvent(stream.Current()); err != nil {
+	for attempt := 1; ; attempt++ {
+		started := false
+		stream := client.Messages.NewStreaming(ctx, params)
+		for stream.Next() {
+			started = true
+			if err := onEvent(stream.Current()); err != nil {
+				stream.Close()
+				return err
+			}
+		}
+		err := stream.Err()
+		stream.Close()
+		if err == nil || started || attempt > w.cfg.MaxRetries {
+			return err
+		}
+		delay, ok := w.retryDelay(err, attempt)
+		if !ok {
+			return err
+		}
+		if deadline, has := ctx.Deadline(); has && time.Until(deadline) < delay {
+			return err
+		}
+		log.Printf("[WARN] claude: upstream rejected the request; retry %d/%d in %s", attempt, w.cfg.MaxRetries, delay)
+		if err := w.sleep(ctx, delay); err != nil {
 			return err
 		}
 	}
-	return stream.Err()
 }
 
-// ListModels returns available Claude models.
-func (w *ClientWrapper) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
+// leaveRateLimitRetries stops the SDK from retrying 429 and 5xx replies,
+// which StreamMessages retries itself, honoring Retry-After and MaxRetries.
+// The SDK still retries connection errors and 408 and 409 replies.
+func leaveRateLimitRetries(req *http.Request, next option.MiddlewareNext) (*http.Response, error) {
+	res, err := next(req)
+	if res != nil && (res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= http.StatusInternalServerError) {
+		res.Header.Set("x-should-retry", "false")
+	}
+	return res, err
+}
+
+// retryDelay reports whether err is a retryable API error (429 or 5xx) and
+// how long to wait: the Retry-After header when present, otherwise one
+// second per attempt. The wait is capped at MaxRetryDelay.
+func (w *ClientWrapper) retryDelay(err error, attempt int) (time.Duration, bool) {
+	var apiErr *anthropic.Error
+	if !errors.As(err, &apiErr) {
+		return 0, false
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests && apiErr.StatusCode < 500 {
+		return 0, false
+	}
+	delay := time.Duration(attempt) * time.Second
+	if apiErr.Response != nil {
+		if d, ok := parseRetryAfter(apiErr.Response.Header.Get("Retry-After")); ok {
+			delay = d
+		}
+	}
+	return min(delay, w.cfg.MaxRetryDelay), true
+}
+
+// parseRetryAfter reads a Retry-After value in seconds or as an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// CountTokens calls POST /v1/messages/count_tokens with the prompt of params
+// and returns the input token count. Nothing is generated.
+func (w *ClientWrapper) CountTokens(ctx context.Context, params anthropic.MessageNewParams) (int, error) {
 	token, err := w.tokens.AccessToken()
 	if err != nil {
-		return nil, fmt.Errorf("get access token: %w", err)
+		return 0, fmt.Errorf("get access token: %w", err)
 	}
 
 	client := anthropic.NewClient(
 		option.WithAuthToken(token),
-		option.WithHeader("anthropic-beta", "oauth-2025-04-20"),
+		option.WithHeader("anthropic-beta", w.betaHeader(append(requestBetas(params), tokenCountingBeta)...)),
 	)
 
-	page, err := client.Models.List(ctx, anthropic.ModelListParams{})
+	res, err := client.Messages.CountTokens(ctx, countTokensParams(params))
 	if err != nil {
-		return nil, fmt.Errorf("list models: %w", err)
+		return 0, fmt.Errorf("count tokens: %w", err)
 	}
+	return int(res.InputTokens), nil
+}
+
+// ListModels returns every model from GET /v1/models, following pagination.
+func (w *ClientWrapper) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
+	token, err := w.tokens.AccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("get access token: %w", err)
+	}
+
+	client := anthropic.NewClient(
+		option.WithAuthToken(token),
+		option.WithHeader("anthropic-beta", w.betaHeader()),
+	)
 
 	var models []harness.ModelInfo
-	for _, m := range page.Data {
+	iter := client.Models.ListAutoPaging(ctx, anthropic.ModelListParams{Limit: anthropic.Int(1000)})
+	for iter.Next() {
+		m := iter.Current()
 		models = append(models, harness.ModelInfo{
 			ID:       m.ID,
 			Name:     m.DisplayName,
 			Provider: "claude",
 		})
 	}
+	if err := iter.Err(); err != nil {
+		return nil, fmt.Errorf("list models: %w", err)
+	}
 	return models, nil
 }
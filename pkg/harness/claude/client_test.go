@@ -0,0 +1,147 @@
+package claude
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+)
+
+const okStream = "event: message_start\n" +
+	`data: {"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-6","usage":{"input_tokens":5,"output_tokens":0}}}` + "\n\n" +
+	"event: message_stop\n" +
+	`data: {"type":"message_stop"}` + "\n\n"
+
+// rateLimitedServer answers 429 with the given Retry-After values, then
+// streams a short reply.
+func rateLimitedServer(t *testing.T, retryAfter ...string) (*atomic.Int32, *ClientWrapper) {
+	t.Helper()
+	return failingServer(t, http.StatusTooManyRequests, retryAfter...)
+}
+
+// failingServer answers status with the given Retry-After values, then
+// streams a short reply.
+func failingServer(t *testing.T, status int, retryAfter ...string) (*atomic.Int32, *ClientWrapper) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		if n <= len(retryAfter) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", retryAfter[n-1])
+			w.WriteHeader(status)
+			w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(okStream))
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("ANTHROPIC_BASE_URL", srv.URL)
+	tokens := NewTokenStore(writeCredentials(t, t.TempDir(), &Credentials{
+		AccessToken: "test-token",
+		ExpiresAt:   UnixMillis(time.Now().Add(time.Hour)),
+	}))
+	if err := tokens.Load(); err != nil {
+		t.Fatal(err)
+	}
+	return &calls, NewClientWrapper(tokens, ClientConfig{MaxRetryDelay: 5 * time.Second})
+}
+
+func testParams() anthropic.MessageNewParams {
+	return anthropic.MessageNewParams{
+		Model:     "claude-sonnet-4-6",
+		MaxTokens: 16,
+		Messages:  []anthropic.MessageParam{anthropic.NewUserMessage(anthropic.NewTextBlock("hi"))},
+	}
+}
+
+func TestStreamMessages_RetriesRateLimit(t *testing.T) {
+	calls, w := rateLimitedServer(t, "2", "30")
+	var slept []time.Duration
+	w.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+
+	events := 0
+	err := w.StreamMessages(context.Background(), testParams(), func(anthropic.MessageStreamEventUnion) error {
+		events++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected 3 requests, got %d", calls.Load())
+	}
+	// The second Retry-After is capped at MaxRetryDelay.
+	if len(slept) != 2 || slept[0] != 2*time.Second || slept[1] != 5*time.Second {
+		t.Errorf("unexpected retry delays %v", slept)
+	}
+	if events != 2 {
+		t.Errorf("expected 2 events, got %d", events)
+	}
+}
+
+func TestStreamMessages_RetryLimits(t *testing.T) {
+	calls, w := rateLimitedServer(t, "1", "1", "1", "1", "1")
+	w.sleep = func(context.Context, time.Duration) error { return nil }
+	err := w.StreamMessages(context.Background(), testParams(), func(anthropic.MessageStreamEventUnion) error { return nil })
+	var apiErr *anthropic.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected the 429 after MaxRetries, got %v", err)
+	}
+	if calls.Load() != 4 {
+		t.Errorf("expected 1 request plus 3 retries, got %d", calls.Load())
+	}
+
+	// A Retry-After past the context deadline is not waited out.
+	calls, w = rateLimitedServer(t, "3")
+	w.sleep = func(context.Context, time.Duration) error {
+		t.Error("should not sleep past the deadline")
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := w.StreamMessages(ctx, testParams(), func(anthropic.MessageStreamEventUnion) error { return nil }); err == nil {
+		t.Fatal("expected the 429 to be returned")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected no retry, got %d requests", calls.Load())
+	}
+}
+
+func TestStreamMessages_SDKRetriesConflict(t *testing.T) {
+	calls, w := failingServer(t, http.StatusConflict, "0")
+	w.sleep = func(context.Context, time.Duration) error {
+		t.Error("a 409 is retried by the SDK, not StreamMessages")
+		return nil
+	}
+	if err := w.StreamMessages(context.Background(), testParams(), func(anthropic.MessageStreamEventUnion) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("expected 2 requests, got %d", calls.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d, ok := parseRetryAfter("7"); !ok || d != 7*time.Second {
+		t.Errorf("seconds: got %v, %v", d, ok)
+	}
+	at := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(at); !ok || d <= 0 || d > 10*time.Second {
+		t.Errorf("date: got %v, %v", d, ok)
+	}
+	for _, v := range []string{"", "soon", "-1"} {
+		if _, ok := parseRetryAfter(v); ok {
+			t.Errorf("%q should not parse", v)
+		}
+	}
+}
@@ -3,7 +3,11 @@ package claude
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
@@ -23,20 +27,54 @@ type Config struct {
 	DefaultMaxTokens int
 
 	// ThinkingBudget is the budget_tokens for extended thinking.
-	// Set to 0 to disable extended thinking.
+	// Set to 0 to disable extended thinking; otherwise it must be at least
+	// MinThinkingBudget.
 	ThinkingBudget int
 
 	// ExtraAliases are additional aliases merged with defaults.
 	ExtraAliases map[string]string
+
+	// ModelCacheTTL is how long ListModels reuses a discovered model list.
+	// Defaults to DefaultModelCacheTTL.
+	ModelCacheTTL time.Duration
+
+	// EnablePromptCaching marks the system prompt as an ephemeral cache
+	// breakpoint, so repeated turns read it from Anthropic's prompt cache.
+	EnablePromptCaching bool
+
+	// UseBatchAPI makes BatchTurns submit turns through the Message Batches
+	// API (see RunBatch) instead of running them concurrently. Batches cost
+	// half as much but can take hours to finish.
+	UseBatchAPI bool
+
+	// CacheBreakpoint, with EnablePromptCaching, splits a system prompt
+	// longer than this many (estimated) tokens and caches only the prefix
+	// up to it. 0 caches the whole prompt.
+	CacheBreakpoint int
+}
+
+// MinThinkingBudget is the smallest extended thinking budget_tokens the
+// Messages API accepts.
+const MinThinkingBudget = 1024
+
+// ValidateThinkingBudget checks a configured ThinkingBudget: 0 (disabled) or
+// at least MinThinkingBudget.
+func ValidateThinkingBudget(budget int) error {
+	if budget != 0 && budget < MinThinkingBudget {
+		return fmt.Errorf("thinking budget %d must be 0 (disabled) or at least %d tokens", budget, MinThinkingBudget)
+	}
+	return nil
 }
 
 // messageStreamer abstracts the streaming API for testing.
 type messageStreamer interface {
 	StreamMessages(ctx context.Context, params anthropic.MessageNewParams, onEvent func(anthropic.MessageStreamEventUnion) error) error
 	ListModels(ctx context.Context) ([]harness.ModelInfo, error)
+	CountTokens(ctx context.Context, params anthropic.MessageNewParams) (int, error)
 }
 
 // Harness implements harness.Harness for the Anthropic Messages API.
+// Anthropic does not support seeding, so Turn.Seed is ignored.
 type Harness struct {
 	client       *ClientWrapper
 	defaultModel string
@@ -44,6 +82,18 @@ type Harness struct {
 	thinkBudget  int
 	testClient   messageStreamer // for testing only; nil in production
 	extraAliases map[string]string
+
+	promptCaching   bool
+	cacheBreakpoint int
+	useBatchAPI     bool
+
+	modelCacheTTL time.Duration
+	modelsMu      sync.Mutex
+	models        []harness.ModelInfo
+	modelsAt      time.Time
+	// discoveryFailing is set while model discovery keeps failing, so the
+	// fallback is logged once per outage.
+	discoveryFailing bool
 }
 
 var _ harness.Harness = (*Harness)(nil)
@@ -58,12 +108,21 @@ func New(cfg Config) *Harness {
 	if maxTokens <= 0 {
 		maxTokens = 16384
 	}
+	modelCacheTTL := cfg.ModelCacheTTL
+	if modelCacheTTL <= 0 {
+		modelCacheTTL = DefaultModelCacheTTL
+	}
 	return &Harness{
 		client:       cfg.Client,
 		defaultModel: model,
 		maxTokens:    maxTokens,
 		thinkBudget:  cfg.ThinkingBudget,
 		extraAliases: cfg.ExtraAliases,
+
+		promptCaching:   cfg.EnablePromptCaching,
+		cacheBreakpoint: cfg.CacheBreakpoint,
+		useBatchAPI:     cfg.UseBatchAPI,
+		modelCacheTTL:   modelCacheTTL,
 	}
 }
 
@@ -87,6 +146,10 @@ func (h *Harness) StreamTurn(ctx context.Context, turn *harness.Turn, onEvent fu
 	err = streamer.StreamMessages(ctx, params, func(ev anthropic.MessageStreamEventUnion) error {
 		return h.translateEvent(ev, state, onEvent)
 	})
+	var apiErr *anthropic.Error
+	if errors.As(err, &apiErr) {
+		return &harness.StatusError{StatusCode: apiErr.StatusCode, Err: err}
+	}
 	if err != nil {
 		return err
 	}
@@ -118,6 +181,10 @@ func (h *Harness) StreamAndCollect(ctx context.Context, turn *harness.Turn) (*ha
 		return nil
 	})
 	result.Duration = time.Since(start)
+	if err == nil {
+		// A reply that misses the schema leaves ParsedOutput nil.
+		_ = harness.ParseStructuredOutput(turn, result)
+	}
 	return result, err
 }
 
@@ -126,6 +193,49 @@ func (h *Harness) RunToolLoop(ctx context.Context, turn *harness.Turn, handler h
 	return harness.RunToolLoop(ctx, h.StreamTurn, turn, handler, opts)
 }
 
+// BatchTurns runs independent turns concurrently, or through the Message
+// Batches API when Config.UseBatchAPI is set.
+func (h *Harness) BatchTurns(ctx context.Context, turns []*harness.Turn, onResult func(index int, result *harness.TurnResult, err error), opts harness.LoopOptions) error {
+	if h.useBatchAPI && h.client != nil {
+		return h.RunBatch(ctx, turns, onResult)
+	}
+	return harness.RunBatch(ctx, h.StreamAndCollect, turns, onResult, opts)
+}
+
+// CountTokens asks the Anthropic token counting endpoint how many input
+// tokens the request built from turn would use.
+func (h *Harness) CountTokens(ctx context.Context, turn *harness.Turn) (int, error) {
+	params, err := h.buildRequest(turn)
+	if err != nil {
+		return 0, fmt.Errorf("claude: build request: %w", err)
+	}
+	counter := messageStreamer(h.client)
+	if h.testClient != nil {
+		counter = h.testClient
+	}
+	return counter.CountTokens(ctx, params)
+}
+
+// countTokensParams copies the prompt-bearing fields of a Messages request
+// into the count_tokens request shape.
+func countTokensParams(params anthropic.MessageNewParams) anthropic.MessageCountTokensParams {
+	out := anthropic.MessageCountTokensParams{
+		Model:      params.Model,
+		Messages:   params.Messages,
+		Thinking:   params.Thinking,
+		ToolChoice: params.ToolChoice,
+	}
+	if len(params.System) > 0 {
+		out.System = anthropic.MessageCountTokensParamsSystemUnion{OfTextBlockArray: params.System}
+	}
+	for _, tool := range params.Tools {
+		if tool.OfTool != nil {
+			out.Tools = append(out.Tools, anthropic.MessageCountTokensToolUnionParam{OfTool: tool.OfTool})
+		}
+	}
+	return out
+}
+
 // ListModels returns available Claude models.
 func (h *Harness) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
 	return h.listModelsWithDiscovery(ctx)
@@ -142,14 +252,42 @@ func (h *Harness) buildRequest(turn *harness.Turn) (anthropic.MessageNewParams,
 		Model:     anthropic.Model(model),
 		MaxTokens: int64(h.maxTokens),
 	}
+	if turn.MaxOutputTokens > 0 {
+		params.MaxTokens = int64(turn.MaxOutputTokens)
+	}
+
+	// Anthropic accepts temperature in [0, 1] and has no presence penalty,
+	// which is ignored.
+	if err := turn.ValidateSampling(); err != nil {
+		return params, err
+	}
+	if turn.Temperature != nil {
+		if *turn.Temperature > 1 {
+			return params, fmt.Errorf("temperature %g is out of range [0, 1] for Claude", *turn.Temperature)
+		}
+		params.Temperature = anthropic.Float(*turn.Temperature)
+	}
+	if turn.TopP != nil {
+		params.TopP = anthropic.Float(*turn.TopP)
+	}
+	if turn.Seed != nil {
+		log.Printf("[DEBUG] claude: ignoring seed, not supported by the Messages API")
+	}
+	if len(turn.StopSequences) > 0 {
+		params.StopSequences = turn.StopSequences
+	}
 
 	// Build the system prompt using Claude-specific patterns
 	systemText, err := BuildSystemPrompt(turn)
 	if err != nil {
 		return params, fmt.Errorf("build system prompt: %w", err)
 	}
+	if turn.ResponseSchema != nil {
+		// The Messages API has no structured output mode; ask in the prompt.
+		systemText = strings.TrimSpace(harness.SchemaInstruction(*turn.ResponseSchema) + "\n\n" + systemText)
+	}
 	if systemText != "" {
-		params.System = []anthropic.TextBlockParam{{Text: systemText}}
+		params.System = h.systemBlocks(systemText)
 	}
 
 	// Convert messages
@@ -157,6 +295,14 @@ func (h *Harness) buildRequest(turn *harness.Turn) (anthropic.MessageNewParams,
 	for _, msg := range turn.Messages {
 		switch msg.Role {
 		case "user":
+			if msg.ContentBlocks != nil {
+				blocks, err := userContentBlocks(msg.ContentBlocks)
+				if err != nil {
+					return params, err
+				}
+				messages = append(messages, anthropic.NewUserMessage(blocks...))
+				continue
+			}
 			messages = append(messages, anthropic.NewUserMessage(
 				anthropic.NewTextBlock(msg.Content),
 			))
@@ -214,6 +360,9 @@ func (h *Harness) buildRequest(turn *harness.Turn) (anthropic.MessageNewParams,
 	}
 
 	// Handle extended thinking
+	if err := ValidateThinkingBudget(h.thinkBudget); err != nil {
+		return params, err
+	}
 	thinkBudget := h.thinkBudget
 	if turn.Reasoning != nil {
 		switch turn.Reasoning.Effort {
@@ -236,15 +385,64 @@ func (h *Harness) buildRequest(turn *harness.Turn) (anthropic.MessageNewParams,
 	return params, nil
 }
 
+// userContentBlocks converts a multi-part user message to Anthropic content
+// blocks. An image is sent as a base64 source when it has inline data, and
+// as a url source otherwise.
+func userContentBlocks(blocks []harness.ContentBlock) ([]anthropic.ContentBlockParamUnion, error) {
+	out := make([]anthropic.ContentBlockParamUnion, 0, len(blocks))
+	for i, block := range blocks {
+		switch b := block.(type) {
+		case harness.TextBlock:
+			out = append(out, anthropic.NewTextBlock(b.Text))
+		case harness.ImageBlock:
+			switch {
+			case b.Base64 != "":
+				if b.MediaType == "" {
+					return nil, fmt.Errorf("content block %d: image media type is required with base64 data", i)
+				}
+				out = append(out, anthropic.NewImageBlockBase64(b.MediaType, b.Base64))
+			case b.URL != "":
+				out = append(out, anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: b.URL}))
+			default:
+				return nil, fmt.Errorf("content block %d: image has neither base64 data nor a url", i)
+			}
+		default:
+			return nil, fmt.Errorf("content block %d: unsupported type %T", i, block)
+		}
+	}
+	return out, nil
+}
+
 // streamState tracks state while translating a stream of Anthropic events.
+// Content blocks are keyed by their stream index, since parallel tool_use
+// blocks can interleave their deltas.
 type streamState struct {
-	currentBlockType string // "text", "thinking", "tool_use"
-	currentToolID    string
-	currentToolName  string
-	thinkingText     string
-	toolArgsJSON     string
-	inputTokens      int
-	outputTokens     int
+	blocks       map[int64]*blockState
+	inputTokens  int
+	outputTokens int
+	cachedTokens int
+}
+
+// blockState accumulates one open content block.
+type blockState struct {
+	blockType string // "text", "thinking", "tool_use"
+	toolID    string
+	toolName  string
+	thinking  string
+	toolArgs  string
+}
+
+// block returns the state for the block at index, creating it if needed.
+func (s *streamState) block(index int64) *blockState {
+	if s.blocks == nil {
+		s.blocks = map[int64]*blockState{}
+	}
+	b, ok := s.blocks[index]
+	if !ok {
+		b = &blockState{}
+		s.blocks[index] = b
+	}
+	return b
 }
 
 // translateEvent converts a raw Anthropic stream event to harness events.
@@ -252,18 +450,12 @@ func (h *Harness) translateEvent(event anthropic.MessageStreamEventUnion, state
 	switch e := event.AsAny().(type) {
 	case anthropic.ContentBlockStartEvent:
 		block := e.ContentBlock
-		switch block.Type {
-		case "text":
-			state.currentBlockType = "text"
-		case "thinking":
-			state.currentBlockType = "thinking"
-			state.thinkingText = ""
-		case "tool_use":
-			state.currentBlockType = "tool_use"
+		b := state.block(e.Index)
+		*b = blockState{blockType: block.Type}
+		if block.Type == "tool_use" {
 			toolBlock := block.AsToolUse()
-			state.currentToolID = toolBlock.ID
-			state.currentToolName = toolBlock.Name
-			state.toolArgsJSON = ""
+			b.toolID = toolBlock.ID
+			b.toolName = toolBlock.Name
 		}
 
 	case anthropic.ContentBlockDeltaEvent:
@@ -275,41 +467,57 @@ func (h *Harness) translateEvent(event anthropic.MessageStreamEventUnion, state
 
 		case "thinking_delta":
 			thinkDelta := delta.AsThinkingDelta()
-			state.thinkingText += thinkDelta.Thinking
+			state.block(e.Index).thinking += thinkDelta.Thinking
 			return emit(harness.NewThinkingEvent(thinkDelta.Thinking))
 
 		case "input_json_delta":
 			jsonDelta := delta.AsInputJSONDelta()
-			state.toolArgsJSON += jsonDelta.PartialJSON
+			state.block(e.Index).toolArgs += jsonDelta.PartialJSON
 		}
 
 	case anthropic.ContentBlockStopEvent:
-		blockType := state.currentBlockType
-		state.currentBlockType = ""
-		switch blockType {
+		b, ok := state.blocks[e.Index]
+		if !ok {
+			return nil
+		}
+		delete(state.blocks, e.Index)
+		switch b.blockType {
 		case "tool_use":
-			return emit(harness.NewToolCallEvent(
-				state.currentToolID,
-				state.currentToolName,
-				state.toolArgsJSON,
-			))
+			args, ok := repairToolArgs(b.toolArgs)
+			if !ok {
+				log.Printf("[WARN] claude: tool_use %s (%s) has invalid input JSON (%d bytes)", b.toolID, b.toolName, len(b.toolArgs))
+				return emit(harness.NewErrorEvent(fmt.Sprintf("tool call %s (%s): invalid input JSON", b.toolID, b.toolName)))
+			}
+			if args != b.toolArgs {
+				log.Printf("[WARN] claude: repaired truncated input JSON for tool_use %s (%s)", b.toolID, b.toolName)
+			}
+			return emit(harness.NewToolCallEvent(b.toolID, b.toolName, args))
 		case "thinking":
 			// Complete thinking block already streamed as deltas
 		}
 
 	case anthropic.MessageStartEvent:
-		if e.Message.Usage.InputTokens > 0 {
-			state.inputTokens = int(e.Message.Usage.InputTokens)
+		// input_tokens excludes cache reads and writes; count them in, so
+		// CachedTokens is a subset of InputTokens as for other providers.
+		u := e.Message.Usage
+		if total := u.InputTokens + u.CacheReadInputTokens + u.CacheCreationInputTokens; total > 0 {
+			state.inputTokens = int(total)
 		}
+		state.cachedTokens = int(u.CacheReadInputTokens)
 
 	case anthropic.MessageDeltaEvent:
 		if e.Usage.OutputTokens > 0 {
 			state.outputTokens = int(e.Usage.OutputTokens)
 		}
+		if e.Delta.StopReason == anthropic.StopReasonStopSequence {
+			return emit(harness.NewStopReasonEvent(e.Delta.StopSequence))
+		}
 
 	case anthropic.MessageStopEvent:
 		if state.inputTokens > 0 || state.outputTokens > 0 {
-			return emit(harness.NewUsageEvent(state.inputTokens, state.outputTokens))
+			ev := harness.NewUsageEvent(state.inputTokens, state.outputTokens)
+			ev.Usage.CachedTokens = state.cachedTokens
+			return emit(ev)
 		}
 	}
 
@@ -2,6 +2,8 @@ package claude
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"godex/pkg/harness"
@@ -65,7 +67,7 @@ func TestBuildRequest_Basic(t *testing.T) {
 
 func TestBuildRequest_ModelOverride(t *testing.T) {
 	h := New(Config{})
-	turn := &harness.Turn{Model: "claude-opus-4-20250514"}
+	turn := &harness.Turn{Model: "claude-opus-4-20250514", MaxOutputTokens: 256}
 	params, err := h.buildRequest(turn)
 	if err != nil {
 		t.Fatal(err)
@@ -73,6 +75,9 @@ func TestBuildRequest_ModelOverride(t *testing.T) {
 	if string(params.Model) != "claude-opus-4-20250514" {
 		t.Errorf("expected claude-opus-4-20250514, got %s", params.Model)
 	}
+	if params.MaxTokens != 256 {
+		t.Errorf("expected the turn's max_tokens 256, got %d", params.MaxTokens)
+	}
 }
 
 func TestBuildRequest_WithThinking(t *testing.T) {
@@ -94,6 +99,37 @@ func TestBuildRequest_WithThinking(t *testing.T) {
 	}
 }
 
+func TestBuildRequest_ThinkingWireFormat(t *testing.T) {
+	h := New(Config{ThinkingBudget: MinThinkingBudget})
+	params, err := h.buildRequest(&harness.Turn{Messages: []harness.Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(raw), `"thinking":{"budget_tokens":1024,"type":"enabled"}`) {
+		t.Errorf("expected enabled thinking in payload, got %s", raw)
+	}
+}
+
+func TestValidateThinkingBudget(t *testing.T) {
+	for _, budget := range []int{0, MinThinkingBudget, 32000} {
+		if err := ValidateThinkingBudget(budget); err != nil {
+			t.Errorf("budget %d: unexpected error %v", budget, err)
+		}
+	}
+	for _, budget := range []int{-1, 1, 1000, MinThinkingBudget - 1} {
+		if err := ValidateThinkingBudget(budget); err == nil {
+			t.Errorf("budget %d: expected error", budget)
+		}
+	}
+	if _, err := New(Config{ThinkingBudget: 500}).buildRequest(&harness.Turn{}); err == nil {
+		t.Error("expected buildRequest to reject a budget below the minimum")
+	}
+}
+
 func TestBuildRequest_ReasoningLowDisablesThinking(t *testing.T) {
 	h := New(Config{ThinkingBudget: 10000})
 	turn := &harness.Turn{
@@ -175,6 +211,72 @@ func TestBuildRequest_MessageTypes(t *testing.T) {
 	}
 }
 
+func TestBuildRequest_PromptCaching(t *testing.T) {
+	turn := &harness.Turn{Instructions: "long system prompt", Messages: []harness.Message{{Role: "user", Content: "hi"}}}
+
+	params, err := New(Config{}).buildRequest(turn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(params.System)
+	if strings.Contains(string(raw), "cache_control") || usesPromptCaching(params) {
+		t.Errorf("caching should be off by default: %s", raw)
+	}
+
+	params, err = New(Config{EnablePromptCaching: true}).buildRequest(turn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ = json.Marshal(params.System)
+	if !strings.Contains(string(raw), `"cache_control":{"type":"ephemeral"}`) {
+		t.Errorf("expected an ephemeral cache_control block, got %s", raw)
+	}
+	if !usesPromptCaching(params) {
+		t.Error("expected the prompt caching beta to be requested")
+	}
+}
+
+func TestBuildRequest_ImageBlocks(t *testing.T) {
+	h := New(Config{})
+	turn := &harness.Turn{Messages: []harness.Message{{
+		Role:    "user",
+		Content: "ignored when blocks are set",
+		ContentBlocks: []harness.ContentBlock{
+			harness.TextBlock{Text: "what is this?"},
+			harness.ImageBlock{MediaType: "image/png", Base64: "iVBORw0KGgo="},
+			harness.ImageBlock{URL: "https://example.com/cat.jpg"},
+		},
+	}}}
+	params, err := h.buildRequest(turn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(params.Messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`{"text":"what is this?","type":"text"}`,
+		`{"source":{"data":"iVBORw0KGgo=","media_type":"image/png","type":"base64"},"type":"image"}`,
+		`{"source":{"url":"https://example.com/cat.jpg","type":"url"},"type":"image"}`,
+	} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("expected %s in payload, got %s", want, raw)
+		}
+	}
+	if strings.Contains(string(raw), "ignored when blocks are set") {
+		t.Errorf("Content should be unused when ContentBlocks is set: %s", raw)
+	}
+
+	bad := &harness.Turn{Messages: []harness.Message{{
+		Role:          "user",
+		ContentBlocks: []harness.ContentBlock{harness.ImageBlock{Base64: "abc"}},
+	}}}
+	if _, err := h.buildRequest(bad); err == nil {
+		t.Error("expected an error for base64 image data without a media type")
+	}
+}
+
 // Mock tests
 
 func TestNewMock_Defaults(t *testing.T) {
@@ -327,3 +429,40 @@ func TestMock_RecordsTurns(t *testing.T) {
 		t.Errorf("unexpected model: %s", recorded[0].Model)
 	}
 }
+
+func TestBuildRequest_ResponseSchema(t *testing.T) {
+	schema := json.RawMessage(`{"type":"object","required":["ok"]}`)
+	h := New(Config{})
+	params, err := h.buildRequest(&harness.Turn{ResponseSchema: &schema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params.System) == 0 || !strings.HasPrefix(params.System[0].Text, harness.SchemaInstruction(schema)) {
+		t.Fatalf("expected system prompt to start with the schema instruction, got %+v", params.System)
+	}
+}
+
+func TestBuildRequest_Sampling(t *testing.T) {
+	h := New(Config{})
+	temp, topP := 0.3, 0.8
+	params, err := h.buildRequest(&harness.Turn{Temperature: &temp, TopP: &topP})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.Temperature.Value != 0.3 || params.TopP.Value != 0.8 {
+		t.Fatalf("expected sampling to be forwarded, got %v %v", params.Temperature, params.TopP)
+	}
+
+	hot := 1.5
+	if _, err := h.buildRequest(&harness.Turn{Temperature: &hot}); err == nil || !strings.Contains(err.Error(), "[0, 1]") {
+		t.Fatalf("expected Claude temperature range error, got %v", err)
+	}
+
+	params, err = h.buildRequest(&harness.Turn{StopSequences: []string{"\n\nHuman:"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(params.StopSequences) != 1 || params.StopSequences[0] != "\n\nHuman:" {
+		t.Fatalf("expected stop_sequences to be forwarded, got %v", params.StopSequences)
+	}
+}
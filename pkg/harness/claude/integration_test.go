@@ -42,11 +42,16 @@ func (tc *testClientWrapper) ListModels(_ context.Context) ([]harness.ModelInfo,
 	return tc.models, nil
 }
 
+func (tc *testClientWrapper) CountTokens(_ context.Context, _ anthropic.MessageNewParams) (int, error) {
+	return 0, nil
+}
+
 // streamClient is the interface that Harness.client needs to satisfy.
 // We use this to verify our test mock matches the real client.
 type streamClient interface {
 	StreamMessages(ctx context.Context, params anthropic.MessageNewParams, onEvent func(anthropic.MessageStreamEventUnion) error) error
 	ListModels(ctx context.Context) ([]harness.ModelInfo, error)
+	CountTokens(ctx context.Context, params anthropic.MessageNewParams) (int, error)
 }
 
 var _ streamClient = (*ClientWrapper)(nil)
@@ -217,6 +222,10 @@ func (tc *multiTurnTestClient) ListModels(_ context.Context) ([]harness.ModelInf
 	return nil, nil
 }
 
+func (tc *multiTurnTestClient) CountTokens(_ context.Context, _ anthropic.MessageNewParams) (int, error) {
+	return 0, nil
+}
+
 func parseEvents(t *testing.T, jsons ...string) []anthropic.MessageStreamEventUnion {
 	t.Helper()
 	var events []anthropic.MessageStreamEventUnion
@@ -291,6 +300,54 @@ func TestRunToolLoop_Integration(t *testing.T) {
 	}
 }
 
+func TestStreamTurn_ParallelToolUse(t *testing.T) {
+	tc := &multiTurnTestClient{
+		rounds: [][]anthropic.MessageStreamEventUnion{
+			// Two tool_use blocks open at once, with interleaved deltas.
+			parseEvents(t,
+				`{"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"test","usage":{"input_tokens":80,"output_tokens":0}}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_01","name":"read","input":{}}}`,
+				`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_02","name":"shell","input":{}}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"path\":"}}`,
+				`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"command\":"}}`,
+				`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"ls\"}"}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"a.go\"}"}}`,
+				`{"type":"content_block_stop","index":1}`,
+				`{"type":"content_block_stop","index":0}`,
+				`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}`,
+				`{"type":"message_stop"}`,
+			),
+		},
+	}
+	h := &Harness{defaultModel: "test", maxTokens: 4096, testClient: tc}
+
+	var calls []harness.ToolCallEvent
+	err := h.StreamTurn(context.Background(), &harness.Turn{
+		Messages: []harness.Message{{Role: "user", Content: "read a.go and list files"}},
+	}, func(ev harness.Event) error {
+		if ev.Kind == harness.EventToolCall {
+			calls = append(calls, *ev.ToolCall)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %d: %+v", len(calls), calls)
+	}
+	// Calls are emitted as their blocks stop.
+	want := []harness.ToolCallEvent{
+		{CallID: "toolu_02", Name: "shell", Arguments: `{"command":"ls"}`},
+		{CallID: "toolu_01", Name: "read", Arguments: `{"path":"a.go"}`},
+	}
+	for i, w := range want {
+		if calls[i].CallID != w.CallID || calls[i].Name != w.Name || calls[i].Arguments != w.Arguments {
+			t.Errorf("call %d: got %+v, want %+v", i, calls[i], w)
+		}
+	}
+}
+
 func TestRunToolLoop_MockBased(t *testing.T) {
 	mock := NewMock(WithToolUseFlow("shell", `{"command":"ls"}`, "Found files."))
 
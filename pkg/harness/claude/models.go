@@ -2,11 +2,33 @@ package claude
 
 import (
 	"context"
+	"errors"
+	"log"
 	"strings"
+	"time"
 
 	"godex/pkg/harness"
 )
 
+// DefaultModelCacheTTL is how long a discovered model list is reused.
+const DefaultModelCacheTTL = time.Hour
+
+// errNoModels is logged when the models API lists nothing.
+var errNoModels = errors.New("models API returned no models")
+
+// errNoClient is returned by DiscoverModels when the harness has no client.
+var errNoClient = errors.New("claude: no client configured")
+
+// defaultClaudeModels is returned when the models API cannot be reached.
+var defaultClaudeModels = []harness.ModelInfo{
+	{ID: "claude-opus-4-6", Name: "Claude Opus 4.6", Provider: "claude"},
+	{ID: "claude-sonnet-4-6", Name: "Claude Sonnet 4.6", Provider: "claude"},
+	{ID: "claude-opus-4-5", Name: "Claude Opus 4.5", Provider: "claude"},
+	{ID: "claude-sonnet-4-5", Name: "Claude Sonnet 4.5", Provider: "claude"},
+	{ID: "claude-haiku-4-5", Name: "Claude Haiku 4.5", Provider: "claude"},
+	{ID: "claude-sonnet-4-20250514", Name: "Claude Sonnet 4", Provider: "claude"},
+}
+
 var defaultClaudeAliases = map[string]string{
 	"sonnet":   "claude-sonnet-4-6",
 	"sonnet45": "claude-sonnet-4-5",
@@ -20,6 +42,39 @@ var defaultClaudePrefixes = []string{"claude-"}
 // Exact names that match this harness (beyond prefix).
 var defaultClaudeExactMatches = []string{"sonnet", "opus", "haiku"}
 
+var claudeBase = harness.CapabilitySet{
+	MaxContextTokens:    200000,
+	SupportsVision:      true,
+	SupportsTools:       true,
+	SupportedMediaTypes: append(append([]string{}, harness.ImageMediaTypes...), "application/pdf"),
+}
+
+// claudeCapabilityRules is the known-model capability table, matched by
+// longest prefix. Extended thinking arrived with Claude 3.7.
+var claudeCapabilityRules = []harness.CapabilityRule{
+	{Prefix: "claude-", Caps: claudeBase},
+	{Prefix: "claude-3-7-", Caps: claudeThinking},
+	{Prefix: "claude-sonnet-4", Caps: claudeThinking},
+	{Prefix: "claude-opus-4", Caps: claudeThinking},
+	{Prefix: "claude-haiku-4", Caps: claudeThinking},
+}
+
+var claudeThinking = func() harness.CapabilitySet {
+	c := claudeBase
+	c.SupportsReasoning = true
+	return c
+}()
+
+// Capabilities reports what the model named in ctx supports, from the
+// known-model table.
+func (h *Harness) Capabilities(ctx context.Context) (harness.CapabilitySet, error) {
+	model, _ := harness.Model(ctx)
+	if model != "" {
+		model = h.ExpandAlias(model)
+	}
+	return harness.LookupCapabilities(claudeCapabilityRules, claudeBase, model), nil
+}
+
 func (h *Harness) mergedAliases() map[string]string {
 	m := make(map[string]string, len(defaultClaudeAliases))
 	for k, v := range defaultClaudeAliases {
@@ -72,13 +127,55 @@ func (h *Harness) MatchesModel(model string) bool {
 	return false
 }
 
-// listModelsWithDiscovery tries API discovery, falls back to nil.
+// listModelsWithDiscovery returns the models from the Anthropic models API,
+// cached for the configured TTL. If the API fails or there is no client, it
+// returns the built-in list; that result is not cached, so the next call
+// tries the API again. A failure is logged once, not on every call, until
+// discovery succeeds again.
 func (h *Harness) listModelsWithDiscovery(ctx context.Context) ([]harness.ModelInfo, error) {
+	if h.testClient == nil && h.client == nil {
+		return defaultClaudeModels, nil
+	}
+	h.modelsMu.Lock()
+	if h.models != nil && time.Since(h.modelsAt) < h.modelCacheTTL {
+		models := h.models
+		h.modelsMu.Unlock()
+		return models, nil
+	}
+	h.modelsMu.Unlock()
+
+	models, err := h.DiscoverModels(ctx)
+
+	h.modelsMu.Lock()
+	defer h.modelsMu.Unlock()
+	if err != nil {
+		if !h.discoveryFailing {
+			log.Printf("[WARN] claude: model discovery failed, using the built-in list: %v", err)
+		}
+		h.discoveryFailing = true
+		return defaultClaudeModels, nil
+	}
+	h.discoveryFailing = false
+	h.models, h.modelsAt = models, time.Now()
+	return models, nil
+}
+
+// DiscoverModels lists the models from the Anthropic models API, bypassing
+// the cache and the built-in fallback of ListModels, so a failing API
+// returns its error. Health checks use it to see the upstream.
+func (h *Harness) DiscoverModels(ctx context.Context) ([]harness.ModelInfo, error) {
+	lister := messageStreamer(h.client)
 	if h.testClient != nil {
-		return h.testClient.ListModels(ctx)
+		lister = h.testClient
+	} else if h.client == nil {
+		return nil, errNoClient
+	}
+	models, err := lister.ListModels(ctx)
+	if err == nil && len(models) == 0 {
+		err = errNoModels
 	}
-	if h.client != nil {
-		return h.client.ListModels(ctx)
+	if err != nil {
+		return nil, err
 	}
-	return nil, nil
+	return models, nil
 }
@@ -1,6 +1,11 @@
 package claude
 
-import "testing"
+import (
+	"context"
+	"testing"
+
+	"godex/pkg/harness"
+)
 
 func TestExpandAlias(t *testing.T) {
 	h := New(Config{})
@@ -87,3 +92,18 @@ func TestExpandAlias_ExtraOverridesDefault(t *testing.T) {
 		t.Errorf("got %q, want custom-sonnet", got)
 	}
 }
+
+func TestCapabilities(t *testing.T) {
+	h := New(Config{})
+	caps, _ := h.Capabilities(harness.WithModel(context.Background(), "sonnet"))
+	if !caps.SupportsVision || !caps.SupportsTools || !caps.SupportsReasoning || caps.MaxContextTokens != 200000 {
+		t.Fatalf("sonnet: unexpected %+v", caps)
+	}
+	caps, _ = h.Capabilities(harness.WithModel(context.Background(), "claude-3-5-haiku-20241022"))
+	if caps.SupportsReasoning || !caps.SupportsVision {
+		t.Fatalf("claude-3-5-haiku: unexpected %+v", caps)
+	}
+	if !caps.Covers(harness.CapabilitySet{SupportedMediaTypes: []string{"application/pdf"}}) {
+		t.Fatalf("expected PDF support, got %+v", caps.SupportedMediaTypes)
+	}
+}
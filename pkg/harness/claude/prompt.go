@@ -4,10 +4,35 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/anthropics/anthropic-sdk-go"
+
 	"godex/pkg/harness"
 	"godex/pkg/harness/prompt"
 )
 
+// systemBlocks returns the system parameter for text. Without prompt
+// caching it is one plain block. With caching, a prompt longer than the
+// cache breakpoint is split near it, at a line boundary where possible, and
+// only the prefix is marked ephemeral; otherwise the whole prompt is.
+func (h *Harness) systemBlocks(text string) []anthropic.TextBlockParam {
+	if !h.promptCaching {
+		return []anthropic.TextBlockParam{{Text: text}}
+	}
+	cached := anthropic.NewCacheControlEphemeralParam()
+	if h.cacheBreakpoint <= 0 || harness.EstimateTokens(text) <= h.cacheBreakpoint {
+		return []anthropic.TextBlockParam{{Text: text, CacheControl: cached}}
+	}
+	// EstimateTokens counts four runes per token.
+	prefix := string([]rune(text)[:h.cacheBreakpoint*4])
+	if i := strings.LastIndex(prefix, "\n"); i > 0 {
+		prefix = prefix[:i+1]
+	}
+	return []anthropic.TextBlockParam{
+		{Text: prefix, CacheControl: cached},
+		{Text: text[len(prefix):]},
+	}
+}
+
 // BuildSystemPrompt constructs the full Claude system prompt from a Turn.
 // Claude uses the system parameter natively (not a user message), so this
 // returns a single string to be set as the system block.
@@ -1,6 +1,8 @@
 package claude
 
 import (
+	"encoding/json"
+	"slices"
 	"strings"
 	"testing"
 
@@ -160,3 +162,48 @@ func TestFormatAgentsMD(t *testing.T) {
 		t.Error("expected content")
 	}
 }
+
+func TestSystemBlocks_CacheBreakpoint(t *testing.T) {
+	stable := strings.Repeat("stable instructions line\n", 20) // 500 chars
+	prompt := stable + "per-request tail"
+
+	// Shorter than the threshold: one block, cached whole.
+	blocks := New(Config{EnablePromptCaching: true, CacheBreakpoint: 1000}).systemBlocks(prompt)
+	if len(blocks) != 1 || blocks[0].Text != prompt || blocks[0].CacheControl.Type == "" {
+		t.Fatalf("expected one cached block, got %+v", blocks)
+	}
+
+	// Longer: the prefix up to the last line break before the breakpoint
+	// is cached, the rest is not.
+	h := New(Config{EnablePromptCaching: true, CacheBreakpoint: 100})
+	blocks = h.systemBlocks(prompt)
+	if len(blocks) != 2 {
+		t.Fatalf("expected a split prompt, got %+v", blocks)
+	}
+	if blocks[0].Text+blocks[1].Text != prompt {
+		t.Error("split lost text")
+	}
+	if blocks[0].CacheControl.Type != "ephemeral" || blocks[1].CacheControl.Type != "" {
+		t.Errorf("only the prefix should be cached: %+v", blocks)
+	}
+	if !strings.HasSuffix(blocks[0].Text, "\n") || len(blocks[0].Text) > 400 {
+		t.Errorf("expected a line-aligned prefix within 100 tokens, got %d chars", len(blocks[0].Text))
+	}
+	params, err := h.buildRequest(&harness.Turn{Instructions: prompt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(requestBetas(params), promptCachingBeta) {
+		t.Error("expected the prompt caching beta")
+	}
+
+	// Caching off: no markers, whatever the breakpoint.
+	params, err = New(Config{CacheBreakpoint: 100}).buildRequest(&harness.Turn{Instructions: prompt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(params.System)
+	if len(params.System) != 1 || strings.Contains(string(raw), "cache_control") {
+		t.Errorf("expected no cache markers, got %s", raw)
+	}
+}
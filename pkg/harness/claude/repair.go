@@ -0,0 +1,67 @@
+package claude
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// repairSuffixes are tried, in order, after closing whatever the scan in
+// closeJSON finds open.
+var repairSuffixes = []string{"}", "\"}", "]}", "\"]}", "}}", "null}"}
+
+// repairToolArgs returns args unchanged when it is valid JSON (or empty, for
+// a tool without input). Otherwise it tries to complete JSON cut short in
+// the stream and returns the first candidate that parses, or false.
+func repairToolArgs(args string) (string, bool) {
+	if strings.TrimSpace(args) == "" || json.Valid([]byte(args)) {
+		return args, true
+	}
+	if fixed := closeJSON(args); json.Valid([]byte(fixed)) {
+		return fixed, true
+	}
+	for _, suffix := range repairSuffixes {
+		if fixed := args + suffix; json.Valid([]byte(fixed)) {
+			return fixed, true
+		}
+	}
+	return "", false
+}
+
+// closeJSON appends the closing quote, brackets and braces that s leaves
+// open.
+func closeJSON(s string) string {
+	var open []byte
+	inString, escaped := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case escaped:
+			escaped = false
+		case inString && c == '\\':
+			escaped = true
+		case c == '"':
+			inString = !inString
+		case inString:
+		case c == '{':
+			open = append(open, '}')
+		case c == '[':
+			open = append(open, ']')
+		case (c == '}' || c == ']') && len(open) > 0:
+			open = open[:len(open)-1]
+		}
+	}
+	var b strings.Builder
+	b.WriteString(s)
+	if escaped {
+		// Drop a dangling backslash rather than escape the closing quote.
+		b.Reset()
+		b.WriteString(s[:len(s)-1])
+	}
+	if inString {
+		b.WriteByte('"')
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteByte(open[i])
+	}
+	return b.String()
+}
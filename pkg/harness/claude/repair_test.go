@@ -0,0 +1,28 @@
+package claude
+
+import "testing"
+
+func TestRepairToolArgs(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{`{"command":"ls"}`, `{"command":"ls"}`, true},
+		{``, ``, true},
+		{`{"command":"ls"`, `{"command":"ls"}`, true},
+		{`{"command":"l`, `{"command":"l"}`, true},
+		{`{"paths":["a","b"`, `{"paths":["a","b"]}`, true},
+		{`{"opts":{"deep":true`, `{"opts":{"deep":true}}`, true},
+		{`{"text":"brace } in string`, `{"text":"brace } in string"}`, true},
+		{`{"text":"ends in \`, `{"text":"ends in "}`, true},
+		{`{"limit":`, `{"limit":null}`, true},
+		{`{"a" 1`, ``, false},
+	}
+	for _, tc := range cases {
+		got, ok := repairToolArgs(tc.in)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("repairToolArgs(%q) = %q, %v; want %q, %v", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
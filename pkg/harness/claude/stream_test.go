@@ -1,9 +1,18 @@
 package claude
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/anthropics/anthropic-sdk-go"
 
@@ -15,6 +24,12 @@ type fakeStreamer struct {
 	events []anthropic.MessageStreamEventUnion
 	models []harness.ModelInfo
 	err    error
+
+	listErr   error
+	listCalls int
+
+	tokens      int
+	countParams anthropic.MessageNewParams
 }
 
 func (f *fakeStreamer) StreamMessages(ctx context.Context, params anthropic.MessageNewParams, onEvent func(anthropic.MessageStreamEventUnion) error) error {
@@ -30,7 +45,13 @@ func (f *fakeStreamer) StreamMessages(ctx context.Context, params anthropic.Mess
 }
 
 func (f *fakeStreamer) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
-	return f.models, nil
+	f.listCalls++
+	return f.models, f.listErr
+}
+
+func (f *fakeStreamer) CountTokens(ctx context.Context, params anthropic.MessageNewParams) (int, error) {
+	f.countParams = params
+	return f.tokens, f.err
 }
 
 func makeTestEvent(jsonStr string) anthropic.MessageStreamEventUnion {
@@ -111,6 +132,121 @@ func TestListModels_ViaTestClient(t *testing.T) {
 	}
 }
 
+func TestListModels_CachesDiscovery(t *testing.T) {
+	h := New(Config{ModelCacheTTL: time.Minute})
+	fake := &fakeStreamer{models: []harness.ModelInfo{{ID: "claude-new-1", Provider: "claude"}}}
+	h.testClient = fake
+
+	for i := 0; i < 3; i++ {
+		models, err := h.ListModels(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(models) != 1 || models[0].ID != "claude-new-1" {
+			t.Fatalf("unexpected models %+v", models)
+		}
+	}
+	if fake.listCalls != 1 {
+		t.Fatalf("expected 1 API call within the TTL, got %d", fake.listCalls)
+	}
+
+	h.modelsAt = time.Now().Add(-2 * time.Minute)
+	if _, err := h.ListModels(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if fake.listCalls != 2 {
+		t.Fatalf("expected a refresh after the TTL, got %d calls", fake.listCalls)
+	}
+}
+
+func TestListModels_FallsBackOnError(t *testing.T) {
+	h := New(Config{})
+	fake := &fakeStreamer{listErr: fmt.Errorf("unauthorized")}
+	h.testClient = fake
+
+	models, err := h.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != len(defaultClaudeModels) || models[0].ID != defaultClaudeModels[0].ID {
+		t.Fatalf("expected the built-in list, got %+v", models)
+	}
+	// A failure is not cached.
+	fake.listErr = nil
+	fake.models = []harness.ModelInfo{{ID: "claude-new-1"}}
+	models, _ = h.ListModels(context.Background())
+	if fake.listCalls != 2 || len(models) != 1 {
+		t.Fatalf("expected a retry after the failure, got %d calls and %+v", fake.listCalls, models)
+	}
+
+	if models, _ := New(Config{}).ListModels(context.Background()); len(models) != len(defaultClaudeModels) {
+		t.Fatalf("expected the built-in list without a client, got %+v", models)
+	}
+}
+
+func TestDiscoverModels_ReturnsErrors(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	h := New(Config{})
+	h.testClient = &fakeStreamer{listErr: fmt.Errorf("unauthorized")}
+	if _, err := h.DiscoverModels(context.Background()); err == nil {
+		t.Fatal("expected the API error from DiscoverModels")
+	}
+	h.testClient = &fakeStreamer{}
+	if _, err := h.DiscoverModels(context.Background()); !errors.Is(err, errNoModels) {
+		t.Fatalf("expected errNoModels for an empty list, got %v", err)
+	}
+	if _, err := New(Config{}).DiscoverModels(context.Background()); err == nil {
+		t.Fatal("expected an error without a client")
+	}
+
+	// ListModels falls back on every failure but warns once per outage.
+	for i := 0; i < 3; i++ {
+		h.ListModels(context.Background())
+	}
+	if n := strings.Count(logs.String(), "model discovery failed"); n != 1 {
+		t.Fatalf("expected one warning for repeated failures, got %d:\n%s", n, logs.String())
+	}
+}
+
+func TestClientWrapperListModels_Paginates(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/v1/models" {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("after_id") == "" {
+			w.Write([]byte(`{"data":[{"id":"claude-a","display_name":"A","type":"model","created_at":"2025-01-01T00:00:00Z"}],"has_more":true,"first_id":"claude-a","last_id":"claude-a"}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":"claude-b","display_name":"B","type":"model","created_at":"2025-01-01T00:00:00Z"}],"has_more":false,"first_id":"claude-b","last_id":"claude-b"}`))
+	}))
+	defer srv.Close()
+	t.Setenv("ANTHROPIC_BASE_URL", srv.URL)
+
+	tokens := NewTokenStore(writeCredentials(t, t.TempDir(), &Credentials{
+		AccessToken: "test-token",
+		ExpiresAt:   UnixMillis(time.Now().Add(time.Hour)),
+	}))
+	if err := tokens.Load(); err != nil {
+		t.Fatal(err)
+	}
+	models, err := NewClientWrapper(tokens, ClientConfig{}).ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0].ID != "claude-a" || models[1].ID != "claude-b" || models[1].Name != "B" {
+		t.Fatalf("unexpected models %+v", models)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 page requests, got %d", calls)
+	}
+}
+
 func TestStreamAndCollect_ViaTestClient(t *testing.T) {
 	h := New(Config{})
 	h.testClient = &fakeStreamer{
@@ -159,6 +295,101 @@ func TestRunToolLoop_ViaTestClient(t *testing.T) {
 	}
 }
 
+func TestCountTokens_SendsPrompt(t *testing.T) {
+	h := New(Config{})
+	fake := &fakeStreamer{tokens: 42}
+	h.testClient = fake
+
+	n, err := h.CountTokens(context.Background(), &harness.Turn{
+		Instructions: "Be brief.",
+		Messages:     []harness.Message{{Role: "user", Content: "hi"}},
+		Tools:        []harness.ToolSpec{{Name: "shell", Parameters: map[string]any{"type": "object"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 {
+		t.Fatalf("expected 42, got %d", n)
+	}
+	p := fake.countParams
+	if len(p.Messages) != 1 || len(p.Tools) != 1 || len(p.System) != 1 {
+		t.Fatalf("count request missing prompt fields: %+v", p)
+	}
+	if !strings.Contains(p.System[0].Text, "Be brief.") {
+		t.Fatalf("system prompt not forwarded: %q", p.System[0].Text)
+	}
+
+	h.testClient = &fakeStreamer{err: fmt.Errorf("unauthorized")}
+	if _, err := h.CountTokens(context.Background(), &harness.Turn{}); err == nil {
+		t.Fatal("expected the API error to be returned")
+	}
+}
+
+func TestClientWrapperCountTokens(t *testing.T) {
+	var gotPath, gotBeta, gotAuth string
+	var gotBody map[string]any
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBeta = r.Header.Get("anthropic-beta")
+		gotAuth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"bad model"}}`))
+			return
+		}
+		w.Write([]byte(`{"input_tokens":128}`))
+	}))
+	defer srv.Close()
+	t.Setenv("ANTHROPIC_BASE_URL", srv.URL)
+
+	tokens := NewTokenStore(writeCredentials(t, t.TempDir(), &Credentials{
+		AccessToken: "test-token",
+		ExpiresAt:   UnixMillis(time.Now().Add(time.Hour)),
+	}))
+	if err := tokens.Load(); err != nil {
+		t.Fatal(err)
+	}
+	client := NewClientWrapper(tokens, ClientConfig{})
+	params, err := New(Config{}).buildRequest(&harness.Turn{
+		Instructions: "Be brief.",
+		Messages:     []harness.Message{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := client.CountTokens(context.Background(), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 128 {
+		t.Errorf("expected 128 tokens, got %d", n)
+	}
+	if gotPath != "/v1/messages/count_tokens" {
+		t.Errorf("unexpected path %q", gotPath)
+	}
+	if !strings.Contains(gotBeta, "token-counting-2024-11-01") || !strings.Contains(gotBeta, "oauth-2025-04-20") {
+		t.Errorf("missing beta flags: %q", gotBeta)
+	}
+	if gotAuth != "Bearer test-token" {
+		t.Errorf("unexpected auth header %q", gotAuth)
+	}
+	if gotBody["model"] == nil || gotBody["messages"] == nil || gotBody["system"] == nil {
+		t.Errorf("count request missing prompt fields: %v", gotBody)
+	}
+	if _, ok := gotBody["max_tokens"]; ok {
+		t.Errorf("count request should not carry max_tokens: %v", gotBody)
+	}
+
+	status = http.StatusBadRequest
+	if _, err := client.CountTokens(context.Background(), params); err == nil || !strings.Contains(err.Error(), "count tokens") {
+		t.Fatalf("expected a count tokens error, got %v", err)
+	}
+}
+
 type simpleHandler struct{}
 
 func (h *simpleHandler) Handle(_ context.Context, call harness.ToolCallEvent) (*harness.ToolResultEvent, error) {
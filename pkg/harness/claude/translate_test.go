@@ -21,7 +21,7 @@ func makeEvent(t *testing.T, jsonStr string) anthropic.MessageStreamEventUnion {
 
 func TestTranslateEvent_TextDelta(t *testing.T) {
 	h := New(Config{})
-	state := &streamState{currentBlockType: "text"}
+	state := &streamState{blocks: map[int64]*blockState{0: {blockType: "text"}}}
 
 	ev := makeEvent(t, `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`)
 
@@ -46,7 +46,7 @@ func TestTranslateEvent_TextDelta(t *testing.T) {
 
 func TestTranslateEvent_ThinkingDelta(t *testing.T) {
 	h := New(Config{})
-	state := &streamState{currentBlockType: "thinking"}
+	state := &streamState{blocks: map[int64]*blockState{0: {blockType: "thinking"}}}
 
 	ev := makeEvent(t, `{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me think..."}}`)
 
@@ -67,18 +67,16 @@ func TestTranslateEvent_ThinkingDelta(t *testing.T) {
 	if events[0].Thinking.Delta != "Let me think..." {
 		t.Errorf("unexpected thinking: %q", events[0].Thinking.Delta)
 	}
-	if state.thinkingText != "Let me think..." {
-		t.Errorf("state not updated: %q", state.thinkingText)
+	if got := state.blocks[0].thinking; got != "Let me think..." {
+		t.Errorf("state not updated: %q", got)
 	}
 }
 
 func TestTranslateEvent_InputJSONDelta(t *testing.T) {
 	h := New(Config{})
-	state := &streamState{
-		currentBlockType: "tool_use",
-		currentToolID:    "toolu_01",
-		currentToolName:  "shell",
-	}
+	state := &streamState{blocks: map[int64]*blockState{
+		1: {blockType: "tool_use", toolID: "toolu_01", toolName: "shell"},
+	}}
 
 	ev := makeEvent(t, `{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"command\":"}}`)
 
@@ -94,19 +92,16 @@ func TestTranslateEvent_InputJSONDelta(t *testing.T) {
 	if len(events) != 0 {
 		t.Fatalf("expected 0 events, got %d", len(events))
 	}
-	if state.toolArgsJSON != `{"command":` {
-		t.Errorf("unexpected args: %q", state.toolArgsJSON)
+	if got := state.blocks[1].toolArgs; got != `{"command":` {
+		t.Errorf("unexpected args: %q", got)
 	}
 }
 
 func TestTranslateEvent_ContentBlockStop_ToolUse(t *testing.T) {
 	h := New(Config{})
-	state := &streamState{
-		currentBlockType: "tool_use",
-		currentToolID:    "toolu_01",
-		currentToolName:  "shell",
-		toolArgsJSON:     `{"command":"ls"}`,
-	}
+	state := &streamState{blocks: map[int64]*blockState{
+		1: {blockType: "tool_use", toolID: "toolu_01", toolName: "shell", toolArgs: `{"command":"ls"}`},
+	}}
 
 	ev := makeEvent(t, `{"type":"content_block_stop","index":1}`)
 
@@ -128,8 +123,8 @@ func TestTranslateEvent_ContentBlockStop_ToolUse(t *testing.T) {
 	if tc.CallID != "toolu_01" || tc.Name != "shell" || tc.Arguments != `{"command":"ls"}` {
 		t.Errorf("unexpected tool call: %+v", tc)
 	}
-	if state.currentBlockType != "" {
-		t.Error("block type should be reset")
+	if len(state.blocks) != 0 {
+		t.Error("stopped block should be dropped")
 	}
 }
 
@@ -143,8 +138,8 @@ func TestTranslateEvent_ContentBlockStart_Text(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if state.currentBlockType != "text" {
-		t.Errorf("expected block type 'text', got %q", state.currentBlockType)
+	if got := state.block(0).blockType; got != "text" {
+		t.Errorf("expected block type 'text', got %q", got)
 	}
 }
 
@@ -158,8 +153,8 @@ func TestTranslateEvent_ContentBlockStart_Thinking(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if state.currentBlockType != "thinking" {
-		t.Errorf("expected block type 'thinking', got %q", state.currentBlockType)
+	if got := state.block(0).blockType; got != "thinking" {
+		t.Errorf("expected block type 'thinking', got %q", got)
 	}
 }
 
@@ -173,14 +168,48 @@ func TestTranslateEvent_ContentBlockStart_ToolUse(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	if state.currentBlockType != "tool_use" {
-		t.Errorf("expected block type 'tool_use', got %q", state.currentBlockType)
+	b := state.block(1)
+	if b.blockType != "tool_use" {
+		t.Errorf("expected block type 'tool_use', got %q", b.blockType)
 	}
-	if state.currentToolID != "toolu_01" {
-		t.Errorf("expected tool ID 'toolu_01', got %q", state.currentToolID)
+	if b.toolID != "toolu_01" {
+		t.Errorf("expected tool ID 'toolu_01', got %q", b.toolID)
 	}
-	if state.currentToolName != "shell" {
-		t.Errorf("expected tool name 'shell', got %q", state.currentToolName)
+	if b.toolName != "shell" {
+		t.Errorf("expected tool name 'shell', got %q", b.toolName)
+	}
+}
+
+func TestTranslateEvent_ContentBlockStop_TruncatedToolInput(t *testing.T) {
+	h := New(Config{})
+	var events []harness.Event
+	emit := func(e harness.Event) error {
+		events = append(events, e)
+		return nil
+	}
+
+	state := &streamState{blocks: map[int64]*blockState{
+		0: {blockType: "tool_use", toolID: "toolu_01", toolName: "shell", toolArgs: `{"command":"ls"`},
+	}}
+	if err := h.translateEvent(makeEvent(t, `{"type":"content_block_stop","index":0}`), state, emit); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Kind != harness.EventToolCall {
+		t.Fatalf("expected a repaired tool call, got %+v", events)
+	}
+	if got := events[0].ToolCall.Arguments; got != `{"command":"ls"}` {
+		t.Errorf("unexpected repaired args %q", got)
+	}
+
+	events = nil
+	state = &streamState{blocks: map[int64]*blockState{
+		0: {blockType: "tool_use", toolID: "toolu_02", toolName: "shell", toolArgs: `{"command" "ls`},
+	}}
+	if err := h.translateEvent(makeEvent(t, `{"type":"content_block_stop","index":0}`), state, emit); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Kind != harness.EventError {
+		t.Fatalf("expected an error event for unrepairable input, got %+v", events)
 	}
 }
 
@@ -239,9 +268,34 @@ func TestTranslateEvent_MessageStop_EmitsUsage(t *testing.T) {
 	}
 }
 
+func TestTranslateEvent_CacheReadUsage(t *testing.T) {
+	h := New(Config{})
+	state := &streamState{}
+	var events []harness.Event
+	emit := func(e harness.Event) error {
+		events = append(events, e)
+		return nil
+	}
+	for _, raw := range []string{
+		`{"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-20250514","usage":{"input_tokens":20,"cache_read_input_tokens":1500,"cache_creation_input_tokens":0,"output_tokens":0}}}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":40}}`,
+		`{"type":"message_stop"}`,
+	} {
+		if err := h.translateEvent(makeEvent(t, raw), state, emit); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(events) != 1 || events[0].Kind != harness.EventUsage {
+		t.Fatalf("expected one usage event, got %+v", events)
+	}
+	if u := events[0].Usage; u.InputTokens != 1520 || u.CachedTokens != 1500 || u.OutputTokens != 40 {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+}
+
 func TestTranslateEvent_ContentBlockStop_Thinking(t *testing.T) {
 	h := New(Config{})
-	state := &streamState{currentBlockType: "thinking", thinkingText: "some thought"}
+	state := &streamState{blocks: map[int64]*blockState{0: {blockType: "thinking", thinking: "some thought"}}}
 
 	ev := makeEvent(t, `{"type":"content_block_stop","index":0}`)
 
@@ -257,8 +311,8 @@ func TestTranslateEvent_ContentBlockStop_Thinking(t *testing.T) {
 	if len(events) != 0 {
 		t.Fatalf("expected 0 events, got %d", len(events))
 	}
-	if state.currentBlockType != "" {
-		t.Error("block type should be reset")
+	if len(state.blocks) != 0 {
+		t.Error("stopped block should be dropped")
 	}
 }
 
@@ -312,3 +366,28 @@ func TestTranslateEvent_FullFlow(t *testing.T) {
 		t.Errorf("unexpected usage: %+v", events[3].Usage)
 	}
 }
+
+func TestTranslateEvent_StopSequence(t *testing.T) {
+	h := New(Config{})
+	state := &streamState{}
+
+	ev := makeEvent(t, `{"type":"message_delta","delta":{"stop_reason":"stop_sequence","stop_sequence":"END"},"usage":{"output_tokens":3}}`)
+
+	var events []harness.Event
+	err := h.translateEvent(ev, state, func(e harness.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Kind != harness.EventStopReason {
+		t.Fatalf("expected stop_reason event, got %v", events)
+	}
+	if events[0].StopReason.Sequence != "END" {
+		t.Errorf("expected sequence END, got %q", events[0].StopReason.Sequence)
+	}
+	if state.outputTokens != 3 {
+		t.Errorf("expected output tokens to be recorded, got %d", state.outputTokens)
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math/rand"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -18,7 +22,12 @@ import (
 	"godex/pkg/sse"
 )
 
-const defaultBaseURL = "https://chatgpt.com/backend-api/codex"
+// DefaultModelCacheTTL is how long ListModels reuses discovered models.
+const DefaultModelCacheTTL = time.Hour
+
+// ErrContextLengthExceeded is wrapped by StreamResponses errors when the
+// backend rejects a request for not fitting the model's context window.
+var ErrContextLengthExceeded = errors.New("context length exceeded")
 
 // ClientConfig holds configuration for the Codex client.
 type ClientConfig struct {
@@ -29,7 +38,17 @@ type ClientConfig struct {
 	AllowRefresh      bool
 	RetryMax          int
 	RetryDelay        time.Duration
+	MaxRetryDelay     time.Duration // caps the jittered backoff; default 30s
 	UpstreamAuditPath string
+	ModelsURL         string        // model discovery endpoint; default BaseURL + "/models"
+	DisableDiscovery  bool          // ListModels returns only the known models
+	ModelCacheTTL     time.Duration // how long discovered models are reused; default DefaultModelCacheTTL
+	// CancelOnContextDone deletes the server-side response when ctx is
+	// cancelled mid-stream, so the backend stops generating; default true.
+	CancelOnContextDone *bool
+	// AccountProfile selects a named account from the auth file; empty
+	// uses its default account.
+	AccountProfile string
 }
 
 // Client implements the Codex/ChatGPT API client directly.
@@ -38,6 +57,10 @@ type Client struct {
 	auth          *auth.Store
 	cfg           ClientConfig
 	upstreamAudit *upstreamAuditLogger
+
+	modelsMu sync.Mutex
+	models   []harness.ModelInfo
+	modelsAt time.Time
 }
 
 var requestCounter uint64
@@ -48,7 +71,7 @@ func NewClient(httpClient *http.Client, authStore *auth.Store, cfg ClientConfig)
 		httpClient = http.DefaultClient
 	}
 	if cfg.BaseURL == "" {
-		cfg.BaseURL = defaultBaseURL
+		cfg.BaseURL = auth.DefaultCodexBaseURL
 	}
 	if cfg.Originator == "" {
 		cfg.Originator = "codex_cli_rs"
@@ -62,6 +85,12 @@ func NewClient(httpClient *http.Client, authStore *auth.Store, cfg ClientConfig)
 	if cfg.RetryDelay == 0 {
 		cfg.RetryDelay = 300 * time.Millisecond
 	}
+	if cfg.MaxRetryDelay == 0 {
+		cfg.MaxRetryDelay = 30 * time.Second
+	}
+	if cfg.ModelCacheTTL <= 0 {
+		cfg.ModelCacheTTL = DefaultModelCacheTTL
+	}
 	if strings.TrimSpace(cfg.UpstreamAuditPath) == "" {
 		cfg.UpstreamAuditPath = strings.TrimSpace(os.Getenv("GODEX_UPSTREAM_AUDIT_PATH"))
 	}
@@ -86,6 +115,8 @@ func (c *Client) WithBaseURL(baseURL string) *Client {
 }
 
 // StreamResponses sends a request and streams events back via the callback.
+// A stream that closes without a terminal event (response.completed and the
+// like, or error) fails with io.ErrUnexpectedEOF.
 func (c *Client) StreamResponses(ctx context.Context, req protocol.ResponsesRequest, onEvent func(sse.Event) error) error {
 	if onEvent == nil {
 		return fmt.Errorf("onEvent callback is required")
@@ -109,12 +140,12 @@ func (c *Client) StreamResponses(ctx context.Context, req protocol.ResponsesRequ
 			io.Copy(io.Discard, resp.Body)
 			resp.Body.Close()
 			if c.auth != nil && c.cfg.AllowRefresh {
-				if err := c.auth.Refresh(ctx, auth.RefreshOptions{AllowNetwork: true, HTTPClient: c.httpClient}); err == nil {
+				if err := c.auth.RefreshProfile(ctx, c.cfg.AccountProfile, auth.RefreshOptions{AllowNetwork: true, HTTPClient: c.httpClient}); err == nil {
 					refreshed = true
 					continue
 				}
 			}
-			return fmt.Errorf("request failed with status 401")
+			return &harness.StatusError{StatusCode: http.StatusUnauthorized, Err: errors.New("request failed with status 401")}
 		}
 		if isRetryable(resp.StatusCode) && retried < c.cfg.RetryMax {
 			io.Copy(io.Discard, resp.Body)
@@ -134,13 +165,37 @@ func (c *Client) StreamResponses(ctx context.Context, req protocol.ResponsesRequ
 			defer resp.Body.Close()
 			body, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
 			c.logUpstreamHTTPError(reqID, req.Model, resp.StatusCode, body)
-			return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+			err := &harness.StatusError{
+				StatusCode: resp.StatusCode,
+				Err:        fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body))),
+			}
+			if isContextLengthExceeded(body) {
+				return fmt.Errorf("%w: %w", ErrContextLengthExceeded, err)
+			}
+			return err
 		}
 		defer resp.Body.Close()
-		return sse.ParseStream(resp.Body, func(ev sse.Event) error {
+		var responseID string
+		finished := false
+		err = sse.ParseStream(resp.Body, func(ev sse.Event) error {
 			c.logUpstreamEvent(reqID, req.Model, ev)
+			switch ev.Value.Type {
+			case "response.created":
+				if ev.Value.Response != nil {
+					responseID = ev.Value.Response.ID
+				}
+			case "response.completed", "response.done", "response.failed", "response.incomplete", "error":
+				finished = true
+			}
 			return onEvent(ev)
 		})
+		if err != nil && ctx.Err() != nil && responseID != "" && !finished && c.cancelOnContextDone() {
+			go c.cancelResponse(ctx, responseID)
+		}
+		if err == nil && !finished {
+			return fmt.Errorf("stream closed before response.completed: %w", io.ErrUnexpectedEOF)
+		}
+		return err
 	}
 }
 
@@ -241,16 +296,59 @@ var knownCodexModels = []harness.ModelInfo{
 	{ID: "o1-mini", Name: "o1 Mini", Provider: "codex"},
 }
 
-// ListModels returns models for the Codex/GPT backend.
+// codexModelPrefixes are the ID prefixes of discovered models that can be
+// used through the Codex backend.
+var codexModelPrefixes = []string{"gpt-5", "o1", "o3", "o4", "codex-"}
+
+// nonChatModelMarkers exclude discovered models that share a Codex prefix
+// but do not serve the Responses text API.
+var nonChatModelMarkers = []string{"audio", "realtime", "tts", "transcribe", "image", "search"}
+
+func isCodexModelID(id string) bool {
+	for _, marker := range nonChatModelMarkers {
+		if strings.Contains(id, marker) {
+			return false
+		}
+	}
+	for _, p := range codexModelPrefixes {
+		if strings.HasPrefix(id, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListModels returns models for the Codex/GPT backend: the models found by
+// discoverModels merged with knownCodexModels, or just the known models when
+// discovery is disabled or fails. A successful discovery is reused for
+// ModelCacheTTL; a failed one is tried again on the next call.
 func (c *Client) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
+	if c.cfg.DisableDiscovery {
+		return knownCodexModels, nil
+	}
+	c.modelsMu.Lock()
+	defer c.modelsMu.Unlock()
+	if c.models != nil && time.Since(c.modelsAt) < c.cfg.ModelCacheTTL {
+		return c.models, nil
+	}
 	discovered, err := c.discoverModels(ctx)
 	if err != nil || len(discovered) == 0 {
 		return knownCodexModels, nil
 	}
+	known := make(map[string]harness.ModelInfo, len(knownCodexModels))
+	for _, m := range knownCodexModels {
+		known[m.ID] = m
+	}
 	seen := map[string]bool{}
 	var merged []harness.ModelInfo
 	for _, m := range discovered {
+		if seen[m.ID] {
+			continue
+		}
 		seen[m.ID] = true
+		if k, ok := known[m.ID]; ok {
+			m.Name = k.Name
+		}
 		merged = append(merged, m)
 	}
 	for _, m := range knownCodexModels {
@@ -258,26 +356,38 @@ func (c *Client) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
 			merged = append(merged, m)
 		}
 	}
+	c.models, c.modelsAt = merged, time.Now()
 	return merged, nil
 }
 
-func (c *Client) discoverModels(ctx context.Context) ([]harness.ModelInfo, error) {
-	key := ""
-	if k, ok := harness.ProviderKey(ctx); ok {
-		key = k
-	}
-	if key == "" {
-		key = strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
-	}
-	if key == "" {
-		return nil, fmt.Errorf("no OpenAI API key")
+// modelsURL is ModelsURL, or the models endpoint of the Codex backend at
+// BaseURL.
+func (c *Client) modelsURL() string {
+	if c.cfg.ModelsURL != "" {
+		return c.cfg.ModelsURL
 	}
+	return strings.TrimRight(c.cfg.BaseURL, "/") + "/models"
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.openai.com/v1/models", nil)
+// discoverModels lists the Codex models visible at modelsURL. It
+// authenticates with the request's provider key, then the auth store's
+// account, sending the same headers as StreamResponses, then
+// OPENAI_API_KEY. It reads both the OpenAI {"data":[{"id"}]} list and the
+// Codex backend's {"models":[{"slug"}]}.
+func (c *Client) discoverModels(ctx context.Context) ([]harness.ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.modelsURL(), nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", "Bearer "+key)
+	if k, ok := harness.ProviderKey(ctx); ok && k != "" {
+		req.Header.Set("Authorization", "Bearer "+k)
+	} else if c.auth == nil || c.setHeaders(ctx, req) != nil {
+		key := strings.TrimSpace(os.Getenv("OPENAI_API_KEY"))
+		if key == "" {
+			return nil, fmt.Errorf("no OpenAI API key")
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
 
 	client := &http.Client{Timeout: 15 * time.Second}
 	resp, err := client.Do(req)
@@ -287,7 +397,7 @@ func (c *Client) discoverModels(ctx context.Context) ([]harness.ModelInfo, error
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("OpenAI models API: %d", resp.StatusCode)
+		return nil, fmt.Errorf("models API: %d", resp.StatusCode)
 	}
 
 	var body struct {
@@ -295,59 +405,146 @@ func (c *Client) discoverModels(ctx context.Context) ([]harness.ModelInfo, error
 			ID      string `json:"id"`
 			Created int64  `json:"created"`
 		} `json:"data"`
+		Models []struct {
+			Slug string `json:"slug"`
+		} `json:"models"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
 		return nil, err
 	}
 
-	var models []harness.ModelInfo
+	ids := make([]string, 0, len(body.Data)+len(body.Models))
 	for _, m := range body.Data {
-		models = append(models, harness.ModelInfo{ID: m.ID, Provider: "codex"})
+		ids = append(ids, m.ID)
+	}
+	for _, m := range body.Models {
+		ids = append(ids, m.Slug)
+	}
+	var models []harness.ModelInfo
+	for _, id := range ids {
+		if isCodexModelID(id) {
+			models = append(models, harness.ModelInfo{ID: id, Provider: "codex"})
+		}
 	}
 	return models, nil
 }
 
 func (c *Client) doRequest(ctx context.Context, payload []byte) (*http.Response, error) {
-	if c.auth == nil {
-		return nil, fmt.Errorf("auth store is required")
+	if c.auth != nil && c.cfg.AllowRefresh {
+		// Refresh a token about to expire now rather than on a 401, which
+		// may not come until a stream is underway.
+		if _, err := c.auth.RefreshProfileIfNeeded(ctx, c.cfg.AccountProfile, auth.RefreshOptions{AllowNetwork: true, HTTPClient: c.httpClient}); err != nil {
+			log.Printf("[WARN] codex: proactive token refresh: %v", err)
+		}
 	}
 	url := strings.TrimRight(c.cfg.BaseURL, "/") + "/responses"
 	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
 	if err != nil {
 		return nil, fmt.Errorf("build request: %w", err)
 	}
-	token, err := c.auth.AuthorizationToken()
-	if err != nil {
+	if err := c.setHeaders(ctx, hreq); err != nil {
 		return nil, err
 	}
-	hreq.Header.Set("Authorization", "Bearer "+token)
 	hreq.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient.Do(hreq)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// setHeaders adds the auth and client identification headers to hreq,
+// taking the request id from ctx.
+func (c *Client) setHeaders(ctx context.Context, hreq *http.Request) error {
+	if c.auth == nil {
+		return fmt.Errorf("auth store is required")
+	}
+	token, err := c.auth.TokenForProfile(c.cfg.AccountProfile)
+	if err != nil {
+		return err
+	}
+	hreq.Header.Set("Authorization", "Bearer "+token)
 	hreq.Header.Set("originator", c.cfg.Originator)
 	hreq.Header.Set("User-Agent", c.cfg.UserAgent)
+	if id, ok := harness.RequestID(ctx); ok {
+		hreq.Header.Set("X-Request-ID", id)
+	}
 	if c.cfg.SessionID != "" {
 		hreq.Header.Set("session_id", c.cfg.SessionID)
 	}
-	if c.auth.IsChatGPT() {
-		if accountID := c.auth.AccountID(); accountID != "" {
-			hreq.Header.Set("chatgpt-account-id", accountID)
-		}
+	if accountID := c.auth.AccountIDForProfile(c.cfg.AccountProfile); accountID != "" {
+		hreq.Header.Set("chatgpt-account-id", accountID)
+	}
+	return nil
+}
+
+func (c *Client) cancelOnContextDone() bool {
+	return c.cfg.CancelOnContextDone == nil || *c.cfg.CancelOnContextDone
+}
+
+// cancelResponse asks the backend to stop generating response id after the
+// caller's ctx was cancelled. StreamResponses runs it in its own goroutine,
+// so the caller does not wait on it. It keeps ctx's values but not its
+// cancellation, with a 5s timeout of its own; failures are only logged.
+func (c *Client) cancelResponse(ctx context.Context, id string) {
+	cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	url := strings.TrimRight(c.cfg.BaseURL, "/") + "/responses/" + id
+	hreq, err := http.NewRequestWithContext(cancelCtx, http.MethodDelete, url, nil)
+	if err != nil {
+		log.Printf("[WARN] codex: cancel response %s: %v", id, err)
+		return
+	}
+	if err := c.setHeaders(cancelCtx, hreq); err != nil {
+		log.Printf("[WARN] codex: cancel response %s: %v", id, err)
+		return
 	}
 	resp, err := c.httpClient.Do(hreq)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		log.Printf("[WARN] codex: cancel response %s: %v", id, err)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Printf("[WARN] codex: cancel response %s: status %d", id, resp.StatusCode)
 	}
-	return resp, nil
+}
+
+// isContextLengthExceeded reports whether an error body carries the
+// context_length_exceeded error code or type.
+func isContextLengthExceeded(body []byte) bool {
+	var payload struct {
+		Error struct {
+			Type string `json:"type"`
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return false
+	}
+	return payload.Error.Code == "context_length_exceeded" || payload.Error.Type == "context_length_exceeded"
 }
 
 func isRetryable(status int) bool {
 	return status == http.StatusTooManyRequests || status >= 500
 }
 
+// retryDelay returns a random delay in [0, attempt*RetryDelay), with the
+// ceiling capped at MaxRetryDelay. Full jitter keeps clients that were
+// rejected together from all retrying at the same moment.
 func (c *Client) retryDelay(attempt int) time.Duration {
 	if attempt <= 0 {
 		return 0
 	}
-	return time.Duration(attempt) * c.cfg.RetryDelay
+	ceiling := time.Duration(attempt) * c.cfg.RetryDelay
+	if c.cfg.MaxRetryDelay > 0 && ceiling > c.cfg.MaxRetryDelay {
+		ceiling = c.cfg.MaxRetryDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
 }
 
 // RunToolLoop executes a tool loop using the Codex Responses API wire format.
@@ -3,11 +3,15 @@ package codex
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -31,7 +35,7 @@ func makeAuthStore(t *testing.T) *auth.Store {
 
 func TestNewClient_Defaults(t *testing.T) {
 	c := NewClient(nil, nil, ClientConfig{})
-	if c.cfg.BaseURL != defaultBaseURL {
+	if c.cfg.BaseURL != auth.DefaultCodexBaseURL {
 		t.Errorf("expected default base URL, got %q", c.cfg.BaseURL)
 	}
 	if c.cfg.Originator != "codex_cli_rs" {
@@ -49,21 +53,32 @@ func TestWithBaseURL(t *testing.T) {
 		t.Errorf("expected custom URL, got %q", c2.cfg.BaseURL)
 	}
 	// Original should be unchanged
-	if c.cfg.BaseURL != defaultBaseURL {
+	if c.cfg.BaseURL != auth.DefaultCodexBaseURL {
 		t.Error("original should not change")
 	}
 }
 
 func TestRetryDelay(t *testing.T) {
-	c := NewClient(nil, nil, ClientConfig{RetryDelay: 100 * time.Millisecond})
+	c := NewClient(nil, nil, ClientConfig{RetryDelay: 100 * time.Millisecond, MaxRetryDelay: 250 * time.Millisecond})
 	if c.retryDelay(0) != 0 {
 		t.Error("attempt 0 should return 0")
 	}
-	if c.retryDelay(1) != 100*time.Millisecond {
-		t.Errorf("attempt 1 should return 100ms, got %v", c.retryDelay(1))
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		if d := c.retryDelay(1); d < 0 || d >= 100*time.Millisecond {
+			t.Fatalf("attempt 1 should be in [0, 100ms), got %v", d)
+		}
+		d := c.retryDelay(3)
+		if d < 0 || d >= 250*time.Millisecond {
+			t.Fatalf("attempt 3 should be capped below 250ms, got %v", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Error("expected jittered delays to vary")
 	}
-	if c.retryDelay(3) != 300*time.Millisecond {
-		t.Errorf("attempt 3 should return 300ms, got %v", c.retryDelay(3))
+	if NewClient(nil, nil, ClientConfig{}).cfg.MaxRetryDelay != 30*time.Second {
+		t.Error("expected MaxRetryDelay to default to 30s")
 	}
 }
 
@@ -119,6 +134,28 @@ func TestStreamResponses_Success(t *testing.T) {
 	}
 }
 
+func TestStreamResponses_ClosedBeforeCompleted(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		data, _ := json.Marshal(protocol.StreamEvent{Type: "response.output_text.delta", Delta: "Hel"})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	}))
+	defer srv.Close()
+
+	c := NewClient(nil, makeAuthStore(t), ClientConfig{BaseURL: srv.URL})
+	var events int
+	err := c.StreamResponses(context.Background(), protocol.ResponsesRequest{Model: "test"}, func(sse.Event) error {
+		events++
+		return nil
+	})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+	if events != 1 {
+		t.Errorf("expected the delta before the close, got %d events", events)
+	}
+}
+
 func TestStreamResponses_NonOK(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -250,13 +287,18 @@ func TestStreamResponses_401(t *testing.T) {
 }
 
 func TestStreamResponses_Retry(t *testing.T) {
-	attempts := 0
+	const retryDelay = 50 * time.Millisecond
+	var attempts int
+	var rejectedAt time.Time
+	var gaps []time.Duration
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		attempts++
-		if attempts <= 1 {
+		if attempts%2 == 1 {
+			rejectedAt = time.Now()
 			w.WriteHeader(http.StatusTooManyRequests)
 			return
 		}
+		gaps = append(gaps, time.Since(rejectedAt))
 		w.Header().Set("Content-Type", "text/event-stream")
 		ev := protocol.StreamEvent{Type: "response.completed", Response: &protocol.ResponseRef{
 			Usage: &protocol.Usage{InputTokens: 1, OutputTokens: 1},
@@ -270,15 +312,143 @@ func TestStreamResponses_Retry(t *testing.T) {
 	c := NewClient(nil, store, ClientConfig{
 		BaseURL:    srv.URL,
 		RetryMax:   2,
-		RetryDelay: 1 * time.Millisecond,
+		RetryDelay: retryDelay,
 	})
 
+	// Each run is rejected once and succeeds on the retry.
+	const runs = 8
+	for i := 0; i < runs; i++ {
+		err := c.StreamResponses(context.Background(), protocol.ResponsesRequest{}, func(ev sse.Event) error { return nil })
+		if err != nil {
+			t.Fatalf("expected success after retry, got %v", err)
+		}
+	}
+	if attempts != 2*runs {
+		t.Errorf("expected %d attempts, got %d", 2*runs, attempts)
+	}
+
+	// A fixed backoff waits the full 50ms every time. With full jitter, each
+	// wait falls below 40ms with probability 0.8, so all eight staying above
+	// it (p = 0.2^8) means the delays are not being randomized.
+	fastest := gaps[0]
+	for _, g := range gaps[1:] {
+		if g < fastest {
+			fastest = g
+		}
+	}
+	if fastest >= 40*time.Millisecond {
+		t.Errorf("expected jittered retry delays, got %v", gaps)
+	}
+}
+
+func TestStreamResponses_ContextLengthExceeded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":{"type":"invalid_request_error","code":"context_length_exceeded","message":"too long"}}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(nil, makeAuthStore(t), ClientConfig{BaseURL: srv.URL})
 	err := c.StreamResponses(context.Background(), protocol.ResponsesRequest{}, func(ev sse.Event) error { return nil })
-	if err != nil {
-		t.Fatalf("expected success after retry, got %v", err)
+	if !errors.Is(err, ErrContextLengthExceeded) {
+		t.Fatalf("expected ErrContextLengthExceeded, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "status 400") {
+		t.Errorf("expected the status in the error, got %v", err)
+	}
+}
+
+// cancelServer streams response.created for resp_42 and then hangs until
+// the client goes away. DELETE requests are reported on deleted.
+func cancelServer(t *testing.T, deleted chan<- string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			if r.Header.Get("Authorization") != "Bearer test-token" {
+				t.Errorf("expected auth on DELETE, got %q", r.Header.Get("Authorization"))
+			}
+			deleted <- r.URL.Path
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"type":"response.created","response":{"id":"resp_42"}}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+}
+
+func streamUntilCreated(c *Client) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	return c.StreamResponses(ctx, protocol.ResponsesRequest{}, func(ev sse.Event) error {
+		if ev.Value.Type == "response.created" {
+			cancel()
+		}
+		return nil
+	})
+}
+
+func TestStreamResponses_CancelDeletesResponse(t *testing.T) {
+	deleted := make(chan string, 1)
+	srv := cancelServer(t, deleted)
+	defer srv.Close()
+
+	c := NewClient(nil, makeAuthStore(t), ClientConfig{BaseURL: srv.URL})
+	if err := streamUntilCreated(c); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	select {
+	case path := <-deleted:
+		if path != "/responses/resp_42" {
+			t.Errorf("unexpected DELETE path %q", path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a DELETE for the cancelled response")
 	}
-	if attempts != 2 {
-		t.Errorf("expected 2 attempts, got %d", attempts)
+}
+
+func TestStreamResponses_CancelDoesNotWaitForDelete(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			<-release
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"type":"response.created","response":{"id":"resp_42"}}`+"\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := NewClient(nil, makeAuthStore(t), ClientConfig{BaseURL: srv.URL})
+	done := make(chan error, 1)
+	go func() { done <- streamUntilCreated(c) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("StreamResponses waited for the DELETE")
+	}
+}
+
+func TestStreamResponses_CancelOnContextDoneDisabled(t *testing.T) {
+	deleted := make(chan string, 1)
+	srv := cancelServer(t, deleted)
+	defer srv.Close()
+
+	off := false
+	c := NewClient(nil, makeAuthStore(t), ClientConfig{BaseURL: srv.URL, CancelOnContextDone: &off})
+	if err := streamUntilCreated(c); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	select {
+	case path := <-deleted:
+		t.Errorf("unexpected DELETE %s", path)
+	case <-time.After(100 * time.Millisecond):
 	}
 }
 
@@ -357,6 +527,85 @@ func TestDiscoverModels_WithKey(t *testing.T) {
 	}
 }
 
+func TestListModels_Discovery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+			t.Errorf("expected auth store token, got %q", got)
+		}
+		fmt.Fprint(w, `{"data":[{"id":"gpt-5.4-codex"},{"id":"o3"},{"id":"o3"},{"id":"gpt-4o"},{"id":"gpt-5-realtime"},{"id":"text-embedding-3-small"}]}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(nil, makeAuthStore(t), ClientConfig{ModelsURL: srv.URL})
+	models, err := c.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]int{}
+	for _, m := range models {
+		ids[m.ID]++
+	}
+	if models[0].ID != "gpt-5.4-codex" {
+		t.Errorf("expected discovered model first, got %q", models[0].ID)
+	}
+	for _, id := range []string{"gpt-4o", "gpt-5-realtime", "text-embedding-3-small"} {
+		if ids[id] != 0 {
+			t.Errorf("expected %s to be filtered out", id)
+		}
+	}
+	if ids["o3"] != 1 || ids["gpt-5.2-codex"] != 1 {
+		t.Errorf("expected deduplicated merge with known models, got %v", ids)
+	}
+	if len(models) != len(knownCodexModels)+1 {
+		t.Errorf("expected %d models, got %d", len(knownCodexModels)+1, len(models))
+	}
+}
+
+func TestListModels_BaseURLAndCache(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/codex/models" {
+			t.Errorf("expected the base URL's models endpoint, got %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-token" || r.Header.Get("originator") == "" {
+			t.Errorf("expected the Codex request headers, got %v", r.Header)
+		}
+		fmt.Fprint(w, `{"models":[{"slug":"gpt-5.4-codex"}]}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(nil, makeAuthStore(t), ClientConfig{BaseURL: srv.URL + "/codex"})
+	for i := 0; i < 2; i++ {
+		models, err := c.ListModels(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if models[0].ID != "gpt-5.4-codex" {
+			t.Errorf("expected discovered model first, got %q", models[0].ID)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected one discovery call, got %d", calls.Load())
+	}
+}
+
+func TestListModels_DisableDiscovery(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("discovery endpoint should not be called")
+	}))
+	defer srv.Close()
+
+	c := NewClient(nil, makeAuthStore(t), ClientConfig{ModelsURL: srv.URL, DisableDiscovery: true})
+	models, err := c.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != len(knownCodexModels) {
+		t.Errorf("expected known models only, got %d", len(models))
+	}
+}
+
 func TestListModels_WithDiscoverError(t *testing.T) {
 	c := NewClient(nil, nil, ClientConfig{})
 	// discoverModels will fail (no key), should fall back to known models
@@ -369,6 +618,23 @@ func TestListModels_WithDiscoverError(t *testing.T) {
 	}
 }
 
+func TestDiscoverModels_ReturnsErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	c := NewClient(nil, makeAuthStore(t), ClientConfig{ModelsURL: srv.URL})
+	h := New(Config{Client: c})
+	if _, err := h.DiscoverModels(context.Background()); err == nil {
+		t.Fatal("expected the models API error from DiscoverModels")
+	}
+	// ListModels still falls back to the known models.
+	if models, err := h.ListModels(context.Background()); err != nil || len(models) == 0 {
+		t.Fatalf("expected the fallback list, got %d models and %v", len(models), err)
+	}
+}
+
 func TestDoRequest_ChatGPTHeaders(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("originator") == "" {
@@ -407,3 +673,84 @@ func TestDoRequest_WithSessionID(t *testing.T) {
 	}
 	resp.Body.Close()
 }
+
+func TestDoRequest_RefreshesExpiringToken(t *testing.T) {
+	var refreshes int
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		w.Write([]byte(`{"access_token":"fresh-token","refresh_token":"rt-2","expires_in":3600}`))
+	}))
+	defer tokenSrv.Close()
+	auth.SetRefreshConfig(tokenSrv.URL, "", "")
+	t.Cleanup(func() { auth.SetRefreshConfig("https://auth.openai.com/oauth/token", "", "") })
+
+	var gotAuth []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = append(gotAuth, r.Header.Get("Authorization"))
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	// An access token that expires in a minute, inside the refresh window.
+	exp := time.Now().Add(time.Minute).UTC()
+	path := filepath.Join(t.TempDir(), "auth.json")
+	data := fmt.Sprintf(`{"auth_mode":"chatgpt","tokens":{"access_token":"stale-token","refresh_token":"rt-1","expires_at":%q}}`, exp.Format(time.RFC3339))
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := auth.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !store.NeedsRefresh() {
+		t.Fatal("expected the stored token to need a refresh")
+	}
+
+	c := NewClient(nil, store, ClientConfig{BaseURL: srv.URL, AllowRefresh: true})
+	for i := 0; i < 2; i++ {
+		resp, err := c.doRequest(context.Background(), []byte("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if refreshes != 1 {
+		t.Errorf("refreshes = %d, want 1", refreshes)
+	}
+	if len(gotAuth) != 2 || gotAuth[0] != "Bearer fresh-token" || gotAuth[1] != "Bearer fresh-token" {
+		t.Errorf("Authorization headers = %v, want the refreshed token from the first request", gotAuth)
+	}
+}
+
+func TestDoRequest_AccountProfile(t *testing.T) {
+	var gotAuth, gotAccount string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotAccount = r.Header.Get("chatgpt-account-id")
+		w.WriteHeader(200)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "auth.json")
+	data := `{"tokens":{"access_token":"default-token","account_id":"acct-default"},
+		"profiles":{"work":{"tokens":{"access_token":"work-token","account_id":"acct-work"}}}}`
+	os.WriteFile(path, []byte(data), 0o600)
+	store, err := auth.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(nil, store, ClientConfig{BaseURL: srv.URL, AccountProfile: "work"})
+	resp, err := c.doRequest(context.Background(), []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if gotAuth != "Bearer work-token" || gotAccount != "acct-work" {
+		t.Errorf("headers = %q, %q; want the work profile's", gotAuth, gotAccount)
+	}
+
+	c = NewClient(nil, store, ClientConfig{BaseURL: srv.URL, AccountProfile: "missing"})
+	if _, err := c.doRequest(context.Background(), []byte("{}")); err == nil {
+		t.Error("doRequest succeeded with an unknown profile")
+	}
+}
@@ -2,10 +2,14 @@ package codex
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"sort"
 	"strings"
 	"time"
 
@@ -33,6 +37,22 @@ type Config struct {
 
 	// ExtraPrefixes are additional match prefixes merged with defaults.
 	ExtraPrefixes []string
+
+	// MaxContextTokens, when > 0, is the prompt budget: older history is
+	// compacted with harness.CompactHistory until the estimated request size
+	// fits. It also enables retrying with shorter history when the backend
+	// still reports context_length_exceeded.
+	MaxContextTokens int
+
+	// Compaction selects how history is compacted: harness.CompactDropOldest
+	// (default) or harness.CompactSummarize.
+	Compaction string
+
+	// SystemPromptTemplates maps model name prefixes to text/template
+	// strings that replace the built-in system prompt for matching models.
+	// Templates are executed with the *harness.Turn as data; the longest
+	// matching prefix wins.
+	SystemPromptTemplates map[string]string
 }
 
 // Harness implements harness.Harness for the Codex/Responses API.
@@ -42,6 +62,9 @@ type Harness struct {
 	nativeTools   bool
 	extraAliases  map[string]string
 	extraPrefixes []string
+	maxContext    int
+	compaction    string
+	templates     map[string]string
 }
 
 // Ensure Harness implements the interface.
@@ -59,14 +82,46 @@ func New(cfg Config) *Harness {
 		nativeTools:   cfg.NativeTools,
 		extraAliases:  cfg.ExtraAliases,
 		extraPrefixes: cfg.ExtraPrefixes,
+		maxContext:    cfg.MaxContextTokens,
+		compaction:    cfg.Compaction,
+		templates:     cfg.SystemPromptTemplates,
 	}
 }
 
+// overflowRetries is how many times StreamTurn drops more history and
+// retries after a context_length_exceeded error.
+const overflowRetries = 2
+
 // Name returns "codex".
 func (h *Harness) Name() string { return "codex" }
 
 // StreamTurn executes a single turn, translating SSE events to structured harness events.
 func (h *Harness) StreamTurn(ctx context.Context, turn *harness.Turn, onEvent func(harness.Event) error) error {
+	if h.maxContext > 0 && h.compaction == harness.CompactSummarize {
+		turn = h.summarizeHistory(ctx, turn)
+	}
+	for retries := 0; ; retries++ {
+		err := h.streamOnce(ctx, turn, onEvent)
+		if err == nil {
+			break
+		}
+		// The token estimate is rough, so the backend can still reject a
+		// compacted request; the rejection comes before any output.
+		if !errors.Is(err, ErrContextLengthExceeded) || h.maxContext <= 0 || retries == overflowRetries {
+			return err
+		}
+		shorter, dropped := h.shrinkHistory(turn)
+		if dropped == 0 {
+			return err
+		}
+		log.Printf("[WARN] codex: context length exceeded; dropped %d older messages and retrying", dropped)
+		turn = shorter
+	}
+
+	return onEvent(harness.NewDoneEvent())
+}
+
+func (h *Harness) streamOnce(ctx context.Context, turn *harness.Turn, onEvent func(harness.Event) error) error {
 	req, err := h.buildRequest(turn)
 	if err != nil {
 		return fmt.Errorf("codex: build request: %w", err)
@@ -74,15 +129,72 @@ func (h *Harness) StreamTurn(ctx context.Context, turn *harness.Turn, onEvent fu
 
 	collector := sse.NewCollector()
 
+	// The Responses API takes no stop sequences, so they are matched here.
+	// A match cancels the stream, which also deletes the response upstream.
+	emit := onEvent
+	var stops *stopMatcher
+	if len(turn.StopSequences) > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		stops = &stopMatcher{stops: turn.StopSequences, onMatch: cancel}
+		emit = stops.wrap(onEvent)
+	}
+
 	err = h.client.StreamResponses(ctx, req, func(ev sse.Event) error {
 		collector.Observe(ev.Value)
-		return h.translateEvent(ev.Value, collector, onEvent)
+		return h.translateEvent(ev.Value, collector, emit)
 	})
-	if err != nil {
-		return err
+	if errors.Is(err, errStopSequence) {
+		return onEvent(harness.NewStopReasonEvent(stops.matched))
 	}
+	// Text held back as the possible start of a stop sequence goes out
+	// before anything else, however the stream ended.
+	if stops != nil {
+		if text := stops.flush(); text != "" {
+			if ferr := onEvent(harness.NewTextEvent(text)); ferr != nil && err == nil {
+				return ferr
+			}
+		}
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		if perr := h.completePartial(collector, emit); perr != nil {
+			return perr
+		}
+		return fmt.Errorf("codex: %w: %v", harness.ErrPartialResponse, err)
+	}
+	return err
+}
 
-	return onEvent(harness.NewDoneEvent())
+// completePartial finishes a stream that broke off before
+// response.completed: tool calls whose arguments are complete JSON but were
+// not emitted yet are emitted, then a synthetic response.completed is
+// translated like a real one. Text deltas were already emitted as they came.
+func (h *Harness) completePartial(collector *sse.Collector, emit func(harness.Event) error) error {
+	args := collector.AllFunctionArgs()
+	callIDs := make([]string, 0, len(args))
+	for callID := range args {
+		callIDs = append(callIDs, callID)
+	}
+	sort.Strings(callIDs)
+	for _, callID := range callIDs {
+		name := collector.FunctionName(callID)
+		callArgs := normalizeToolCallArguments(args[callID])
+		if name == "" || !json.Valid([]byte(callArgs)) || !collector.MarkToolCallEmitted(callID) {
+			continue
+		}
+		var err error
+		if name == "update_plan" {
+			err = h.emitPlanEvents(callArgs, emit)
+		} else {
+			err = emitToolCall(callID, name, callArgs, emit)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	completed := protocol.StreamEvent{Type: "response.completed", Response: &protocol.ResponseRef{}}
+	return h.translateEvent(completed, collector, emit)
 }
 
 // StreamAndCollect executes a turn and returns collected results.
@@ -109,6 +221,10 @@ func (h *Harness) StreamAndCollect(ctx context.Context, turn *harness.Turn) (*ha
 		return nil
 	})
 	result.Duration = time.Since(start)
+	if err == nil {
+		// A reply that misses the schema leaves ParsedOutput nil.
+		_ = harness.ParseStructuredOutput(turn, result)
+	}
 	return result, err
 }
 
@@ -117,6 +233,41 @@ func (h *Harness) RunToolLoop(ctx context.Context, turn *harness.Turn, handler h
 	return harness.RunToolLoop(ctx, h.StreamTurn, turn, handler, opts)
 }
 
+// BatchTurns runs independent turns concurrently.
+func (h *Harness) BatchTurns(ctx context.Context, turns []*harness.Turn, onResult func(index int, result *harness.TurnResult, err error), opts harness.LoopOptions) error {
+	return harness.RunBatch(ctx, h.StreamAndCollect, turns, onResult, opts)
+}
+
+// CountTokens estimates the prompt size of the request turn would send,
+// including the Codex system prompt. Codex has no public counting API, so
+// this is the four-characters-per-token heuristic.
+func (h *Harness) CountTokens(ctx context.Context, turn *harness.Turn) (int, error) {
+	// Sampling does not affect the prompt; clearing it avoids buildRequest
+	// logging warnings for a request that is never sent.
+	t := *turn
+	t.Temperature, t.TopP, t.PresencePenalty, t.Seed = nil, nil, nil, nil
+	req, err := h.buildRequest(&t)
+	if err != nil {
+		return 0, fmt.Errorf("codex: build request: %w", err)
+	}
+	n := harness.EstimateTokens(req.Instructions)
+	for _, item := range req.Input {
+		for _, part := range item.Content {
+			n += harness.EstimateTokens(part.Text)
+		}
+		n += harness.EstimateTokens(item.Arguments) + harness.EstimateTokens(item.Output)
+	}
+	if len(req.Tools) > 0 {
+		if raw, err := json.Marshal(req.Tools); err == nil {
+			n += harness.EstimateTokens(string(raw))
+		}
+	}
+	if req.Text != nil && req.Text.Format != nil {
+		n += harness.EstimateTokens(string(req.Text.Format.Schema))
+	}
+	return n, nil
+}
+
 // ListModels returns available Codex models.
 func (h *Harness) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
 	return h.listModelsWithDiscovery(ctx)
@@ -129,29 +280,19 @@ func (h *Harness) buildRequest(turn *harness.Turn) (protocol.ResponsesRequest, e
 		model = h.defaultModel
 	}
 
-	// Build the system prompt.
-	// - Default (proxy mode): keep Codex base prompt but replace tool-specific
-	//   sections with caller's instructions. Used by proxy and godex exec.
-	// - Native mode (nativeTools flag): full Codex prompt with shell/apply_patch.
-	proxyMode := !h.nativeTools
-	var instructions string
-	if proxyMode {
-		var err error
-		instructions, err = BuildProxySystemPrompt(turn)
-		if err != nil {
-			return protocol.ResponsesRequest{}, err
-		}
-	} else {
-		var err error
-		instructions, err = BuildSystemPrompt(turn)
-		if err != nil {
-			return protocol.ResponsesRequest{}, err
-		}
+	instructions, err := h.systemPrompt(turn)
+	if err != nil {
+		return protocol.ResponsesRequest{}, err
+	}
+
+	messages := turn.Messages
+	if h.maxContext > 0 {
+		messages = harness.CompactHistory(messages, h.maxContext, promptCounter(instructions, turn.Tools))
 	}
 
 	// Convert messages to protocol input items
-	input := make([]protocol.ResponseInputItem, 0, len(turn.Messages))
-	for _, msg := range turn.Messages {
+	input := make([]protocol.ResponseInputItem, 0, len(messages))
+	for _, msg := range messages {
 		switch msg.Role {
 		case "user":
 			input = append(input, protocol.UserMessage(msg.Content))
@@ -179,11 +320,17 @@ func (h *Harness) buildRequest(turn *harness.Turn) (protocol.ResponsesRequest, e
 	var tools []protocol.ToolSpec
 	if len(turn.Tools) > 0 {
 		for _, t := range turn.Tools {
+			// Round-trip through JSON for a deep copy in generic form, so
+			// normalization reaches nested schemas built from typed Go values
+			// ([]string, []map[string]any) without mutating the caller's turn.
 			var paramsMap map[string]any
 			if t.Parameters != nil {
-				paramsMap = make(map[string]any, len(t.Parameters))
-				for k, v := range t.Parameters {
-					paramsMap[k] = v
+				raw, err := json.Marshal(t.Parameters)
+				if err != nil {
+					return protocol.ResponsesRequest{}, fmt.Errorf("tool %s: encode parameters: %w", t.Name, err)
+				}
+				if err := json.Unmarshal(raw, &paramsMap); err != nil {
+					return protocol.ResponsesRequest{}, fmt.Errorf("tool %s: decode parameters: %w", t.Name, err)
 				}
 			}
 			typ, _ := paramsMap["type"].(string)
@@ -224,18 +371,196 @@ func (h *Harness) buildRequest(turn *harness.Turn) (protocol.ResponsesRequest, e
 		}
 	}
 
+	if err := turn.ValidateSampling(); err != nil {
+		return protocol.ResponsesRequest{}, err
+	}
+	temperature, topP := samplingFor(model, turn, h.Capabilities)
+
+	// Structured output: native text.format where the model supports it,
+	// otherwise a schema instruction in the system prompt.
+	var text *protocol.TextControls
+	if turn.ResponseSchema != nil {
+		caps, _ := h.Capabilities(harness.WithModel(context.Background(), model))
+		if caps.SupportsStructuredOutput {
+			text = &protocol.TextControls{Format: &protocol.TextFormat{
+				Type:   "json_schema",
+				Name:   "response",
+				Schema: *turn.ResponseSchema,
+			}}
+		} else {
+			instructions = harness.SchemaInstruction(*turn.ResponseSchema) + "\n\n" + instructions
+		}
+	}
+
 	return protocol.ResponsesRequest{
-		Model:        model,
-		Instructions: instructions,
-		Input:        input,
-		Tools:        tools,
-		ToolChoice:   "auto",
-		Reasoning:    reasoning,
-		Store:        false,
-		Stream:       true,
+		Model:          model,
+		Instructions:   instructions,
+		Input:          input,
+		Tools:          tools,
+		ToolChoice:     "auto",
+		Reasoning:      reasoning,
+		Store:          false,
+		Stream:         true,
+		PromptCacheKey: toolsCacheKey(turn.PromptCacheKey, tools),
+		Text:           text,
+		Temperature:    temperature,
+		TopP:           topP,
 	}, nil
 }
 
+// toolsCacheKey suffixes a non-empty prompt cache key with a hash of the
+// tool specs, so changing the tools between turns misses the cached prompt
+// instead of reusing one built for the old tools. Keys are sorted before
+// hashing, so equal tool sets always give the same key.
+func toolsCacheKey(key string, tools []protocol.ToolSpec) string {
+	if key == "" {
+		return ""
+	}
+	raw, err := json.Marshal(tools)
+	if err != nil {
+		return key
+	}
+	// Re-encoding through any sorts object keys, including those inside
+	// the parameter schemas.
+	var generic any
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return key
+	}
+	canonical, err := json.Marshal(generic)
+	if err != nil {
+		return key
+	}
+	sum := sha256.Sum256(canonical)
+	return key + "-" + hex.EncodeToString(sum[:6])
+}
+
+// systemPrompt builds the Codex system prompt for turn.
+//   - A configured template for the model, if any. If it fails to render,
+//     the default below is used with a warning.
+//   - Default (proxy mode): keep Codex base prompt but replace tool-specific
+//     sections with caller's instructions. Used by proxy and godex exec.
+//   - Native mode (nativeTools flag): full Codex prompt with shell/apply_patch.
+func (h *Harness) systemPrompt(turn *harness.Turn) (string, error) {
+	model := turn.Model
+	if model == "" {
+		model = h.defaultModel
+	}
+	if prefix, tpl, ok := h.promptTemplate(model); ok {
+		rendered, err := renderTemplate("system_prompt", tpl, turn)
+		if err == nil {
+			return rendered, nil
+		}
+		log.Printf("[WARN] codex: system prompt template %q: %v; using the default prompt", prefix, err)
+	}
+	if h.nativeTools {
+		return BuildSystemPrompt(turn)
+	}
+	return BuildProxySystemPrompt(turn)
+}
+
+// promptTemplate returns the configured system prompt template with the
+// longest prefix of model.
+func (h *Harness) promptTemplate(model string) (prefix, tpl string, ok bool) {
+	for p, t := range h.templates {
+		if strings.HasPrefix(model, p) && (!ok || len(p) > len(prefix)) {
+			prefix, tpl, ok = p, t, true
+		}
+	}
+	return prefix, tpl, ok
+}
+
+// promptCounter returns the size estimate compaction works against: the
+// system prompt and tools plus the given history.
+func promptCounter(instructions string, tools []harness.ToolSpec) func([]harness.Message) int {
+	base := harness.EstimateTurnTokens(&harness.Turn{Instructions: instructions, Tools: tools})
+	return func(msgs []harness.Message) int {
+		return base + harness.EstimateTurnTokens(&harness.Turn{Messages: msgs})
+	}
+}
+
+// summarizeHistory applies the summarize compaction strategy before the
+// request is built. If the summary call fails, buildRequest falls back to
+// dropping the oldest exchanges.
+func (h *Harness) summarizeHistory(ctx context.Context, turn *harness.Turn) *harness.Turn {
+	instructions, err := h.systemPrompt(turn)
+	if err != nil {
+		return turn // buildRequest reports the error
+	}
+	count := promptCounter(instructions, turn.Tools)
+	if count(turn.Messages) <= h.maxContext {
+		return turn
+	}
+	model := turn.Model
+	if model == "" {
+		model = h.defaultModel
+	}
+	messages, err := harness.SummarizeHistory(ctx, h.StreamAndCollect, model, turn.Messages, h.maxContext, count)
+	if err != nil {
+		log.Printf("[WARN] codex: %v; dropping oldest history instead", err)
+		return turn
+	}
+	compacted := *turn
+	compacted.Messages = messages
+	return &compacted
+}
+
+// shrinkHistory compacts the history buildRequest sent for turn to three
+// quarters of its estimated size, after the backend rejected it as too long.
+// It returns the shorter turn and how many messages were dropped.
+func (h *Harness) shrinkHistory(turn *harness.Turn) (*harness.Turn, int) {
+	instructions, err := h.systemPrompt(turn)
+	if err != nil {
+		return turn, 0
+	}
+	count := promptCounter(instructions, turn.Tools)
+	sent := harness.CompactHistory(turn.Messages, h.maxContext, count)
+	kept := harness.CompactHistory(sent, count(sent)*3/4, count)
+	shorter := *turn
+	shorter.Messages = kept
+	return &shorter, len(sent) - len(kept)
+}
+
+// samplingFor returns the temperature and top_p to send for model. Reasoning
+// models reject sampling parameters, so they are dropped with a warning, as
+// are presence_penalty and seed, which the Responses API does not have.
+func samplingFor(model string, turn *harness.Turn, capabilities func(context.Context) (harness.CapabilitySet, error)) (*float64, *float64) {
+	if turn.PresencePenalty != nil {
+		log.Printf("[WARN] codex: ignoring presence_penalty, not supported by the Responses API")
+	}
+	if turn.Seed != nil {
+		log.Printf("[WARN] codex: ignoring seed, not supported by the Responses API")
+	}
+	if turn.Temperature == nil && turn.TopP == nil {
+		return nil, nil
+	}
+	caps, _ := capabilities(harness.WithModel(context.Background(), model))
+	if caps.SupportsReasoning {
+		log.Printf("[WARN] codex: ignoring temperature/top_p for reasoning model %s", model)
+		return nil, nil
+	}
+	return turn.Temperature, turn.TopP
+}
+
+func isReasoningItem(itemType string) bool {
+	return itemType == "reasoning" || itemType == "reasoning_summary"
+}
+
+// emitReasoningPreamble emits a reasoning item's summary as a preamble
+// event, once per item. The summary is usually empty when the item is added
+// and filled in when it is done.
+func emitReasoningPreamble(item *protocol.OutputItem, collector *sse.Collector, emit func(harness.Event) error) error {
+	parts := make([]string, 0, len(item.Summary))
+	for _, part := range item.Summary {
+		if text := strings.TrimSpace(part.Text); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	if len(parts) == 0 || !collector.MarkItemEmitted(item.ID) {
+		return nil
+	}
+	return emit(harness.NewPreambleEvent(strings.Join(parts, "\n\n")))
+}
+
 // translateEvent converts a raw SSE StreamEvent into structured harness events.
 func (h *Harness) translateEvent(ev protocol.StreamEvent, collector *sse.Collector, emit func(harness.Event) error) error {
 	switch ev.Type {
@@ -251,6 +576,9 @@ func (h *Harness) translateEvent(ev protocol.StreamEvent, collector *sse.Collect
 		if ev.Item != nil && ev.Item.Type == "function_call" {
 			// We'll emit the tool call when it's done (arguments complete)
 		}
+		if ev.Item != nil && isReasoningItem(ev.Item.Type) {
+			return emitReasoningPreamble(ev.Item, collector, emit)
+		}
 
 	case "response.function_call_arguments.done":
 		callID := ""
@@ -292,7 +620,7 @@ func (h *Harness) translateEvent(ev protocol.StreamEvent, collector *sse.Collect
 		if name == "update_plan" {
 			return h.emitPlanEvents(args, emit)
 		}
-		return emit(harness.NewToolCallEvent(callID, name, args))
+		return emitToolCall(callID, name, args, emit)
 
 	case "response.output_item.done":
 		if ev.Item != nil && ev.Item.Type == "function_call" {
@@ -311,11 +639,22 @@ func (h *Harness) translateEvent(ev protocol.StreamEvent, collector *sse.Collect
 				return h.emitPlanEvents(args, emit)
 			}
 
-			return emit(harness.NewToolCallEvent(callID, name, args))
+			return emitToolCall(callID, name, args, emit)
+		}
+		if ev.Item != nil && isReasoningItem(ev.Item.Type) {
+			return emitReasoningPreamble(ev.Item, collector, emit)
 		}
 
 	case "response.completed", "response.done":
-		if ev.Response != nil && ev.Response.Usage != nil {
+		if ev.Response == nil {
+			return nil
+		}
+		if ev.Response.StopReason == harness.StopReasonStopSequence {
+			if err := emit(harness.NewStopReasonEvent(ev.Response.StopSequence)); err != nil {
+				return err
+			}
+		}
+		if ev.Response.Usage != nil {
 			return emit(harness.NewUsageEvent(
 				ev.Response.Usage.InputTokens,
 				ev.Response.Usage.OutputTokens,
@@ -408,6 +747,23 @@ func sanitizeJSONValue(v any) any {
 	}
 }
 
+// emitToolCall emits a tool call event. An apply_patch call is followed by
+// an EventPatchApplied summarizing the patch, unless it does not parse.
+func emitToolCall(callID, name, args string, emit func(harness.Event) error) error {
+	if err := emit(harness.NewToolCallEvent(callID, name, args)); err != nil {
+		return err
+	}
+	if name != "apply_patch" {
+		return nil
+	}
+	patch, err := harness.ParseApplyPatchArgs(args)
+	if err != nil {
+		log.Printf("[WARN] codex: apply_patch call %s: %v", callID, err)
+		return nil
+	}
+	return emit(harness.NewPatchAppliedEvent(patch))
+}
+
 // emitPlanEvents parses update_plan arguments and emits PlanEvent for each step.
 func (h *Harness) emitPlanEvents(argsJSON string, emit func(harness.Event) error) error {
 	var plan struct {
@@ -3,10 +3,12 @@ package codex
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 
 	"godex/pkg/harness"
+	"godex/pkg/protocol"
 )
 
 func TestNewMock_Defaults(t *testing.T) {
@@ -205,6 +207,86 @@ func TestBuildRequest_ModelOverride(t *testing.T) {
 	}
 }
 
+func TestBuildRequest_SystemPromptTemplates(t *testing.T) {
+	h := New(Config{DefaultModel: "gpt-5.2-codex", SystemPromptTemplates: map[string]string{
+		"gpt-5":   "generic {{.Model}}",
+		"gpt-5.2": "You are {{.Model}}. {{.Instructions}}",
+		"o3":      "{{.Nope}}",
+	}})
+
+	req, err := h.buildRequest(&harness.Turn{Model: "gpt-5.2-codex", Instructions: "Be brief."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Instructions != "You are gpt-5.2-codex. Be brief." {
+		t.Errorf("expected longest-prefix template, got %q", req.Instructions)
+	}
+
+	// A template that fails to render falls back to the default prompt.
+	req, err = h.buildRequest(&harness.Turn{Model: "o3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, _ := BuildProxySystemPrompt(&harness.Turn{Model: "o3"})
+	if req.Instructions != def {
+		t.Errorf("expected default prompt on render error, got %q", req.Instructions)
+	}
+
+	// Models without a template keep the default.
+	req, _ = h.buildRequest(&harness.Turn{Model: "gpt-4o"})
+	if strings.HasPrefix(req.Instructions, "generic") {
+		t.Errorf("unexpected template for gpt-4o: %q", req.Instructions)
+	}
+}
+
+func TestBuildRequest_PromptCacheKeyTracksTools(t *testing.T) {
+	h := &Harness{defaultModel: "gpt-5.2-codex"}
+	read := harness.ToolSpec{Name: "read", Parameters: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"path": map[string]any{"type": "string"}, "limit": map[string]any{"type": "integer"}},
+	}}
+	write := harness.ToolSpec{Name: "write", Parameters: map[string]any{"type": "object"}}
+	key := func(tools ...harness.ToolSpec) string {
+		t.Helper()
+		req, err := h.buildRequest(&harness.Turn{PromptCacheKey: "sess-1", Tools: tools})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req.PromptCacheKey
+	}
+
+	base := key(read)
+	if !strings.HasPrefix(base, "sess-1-") {
+		t.Fatalf("expected session key with tools hash, got %q", base)
+	}
+	if again := key(read); again != base {
+		t.Errorf("identical tools gave different keys: %q vs %q", base, again)
+	}
+	added := key(read, write)
+	if added == base {
+		t.Error("adding a tool should change the cache key")
+	}
+	if removed := key(write); removed == added || removed == base {
+		t.Error("removing a tool should change the cache key")
+	}
+
+	req, err := h.buildRequest(&harness.Turn{Tools: []harness.ToolSpec{read}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.PromptCacheKey != "" {
+		t.Errorf("expected no cache key without a session, got %q", req.PromptCacheKey)
+	}
+}
+
+func TestToolsCacheKey_SortedKeys(t *testing.T) {
+	a := []protocol.ToolSpec{{Name: "x", Parameters: json.RawMessage(`{"type":"object","properties":{"b":{},"a":{}}}`)}}
+	b := []protocol.ToolSpec{{Name: "x", Parameters: json.RawMessage(`{"properties":{"a":{},"b":{}},"type":"object"}`)}}
+	if toolsCacheKey("k", a) != toolsCacheKey("k", b) {
+		t.Error("key order in tool schemas should not change the cache key")
+	}
+}
+
 func TestBuildRequest_UserToolsAreStrict(t *testing.T) {
 	h := &Harness{defaultModel: "gpt-5.2-codex"}
 	turn := &harness.Turn{
@@ -306,6 +388,10 @@ func TestBuildRequest_UserToolsStrictNormalizesRequired(t *testing.T) {
 			t.Fatalf("required missing %s: %#v", k, rawReq)
 		}
 	}
+	// Declared names come first, then the optional ones in sorted order.
+	if got := fmt.Sprint(rawReq); got != "[path limit offset]" {
+		t.Errorf("expected required [path limit offset], got %s", got)
+	}
 }
 
 func TestBuildRequest_UserToolsStrictNormalizesNestedObjectInUnion(t *testing.T) {
@@ -343,6 +429,109 @@ func TestBuildRequest_UserToolsStrictNormalizesNestedObjectInUnion(t *testing.T)
 	}
 }
 
+func TestBuildRequest_UserToolsStrictNormalizesAllOfBranches(t *testing.T) {
+	h := &Harness{defaultModel: "gpt-5.2-codex"}
+	params := map[string]any{
+		"type":     "object",
+		"required": []string{"target"},
+		"properties": map[string]any{
+			"target": map[string]any{
+				"allOf": []map[string]any{
+					{
+						"type":       "object",
+						"required":   []string{"host"},
+						"properties": map[string]any{"host": map[string]any{"type": "string"}},
+					},
+					{
+						"properties": map[string]any{
+							"port": map[string]any{"type": "integer"},
+							"tls": map[string]any{
+								"type":       "object",
+								"properties": map[string]any{"ca": map[string]any{"type": "string"}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	req, err := h.buildRequest(&harness.Turn{Tools: []harness.ToolSpec{{Name: "connect", Parameters: params}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(req.Tools[0].Parameters, &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	target := schema["properties"].(map[string]any)["target"].(map[string]any)
+	branches := target["allOf"].([]any)
+	first := branches[0].(map[string]any)
+	second := branches[1].(map[string]any)
+	for i, b := range []map[string]any{first, second} {
+		if ap, ok := b["additionalProperties"].(bool); !ok || ap {
+			t.Errorf("allOf[%d]: expected additionalProperties=false, got %#v", i, b["additionalProperties"])
+		}
+	}
+	if got := first["required"].([]any); len(got) != 1 || got[0] != "host" {
+		t.Errorf("allOf[0]: expected required [host], got %v", got)
+	}
+	if typ := first["properties"].(map[string]any)["host"].(map[string]any)["type"]; typ != "string" {
+		t.Errorf("required property should stay non-nullable, got %v", typ)
+	}
+	if got := second["required"].([]any); len(got) != 2 {
+		t.Errorf("allOf[1]: expected optional properties added to required, got %v", got)
+	}
+	tls := second["properties"].(map[string]any)["tls"].(map[string]any)
+	if ap, ok := tls["additionalProperties"].(bool); !ok || ap {
+		t.Errorf("nested object in allOf: expected additionalProperties=false, got %#v", tls["additionalProperties"])
+	}
+	if got, _ := tls["required"].([]any); len(got) != 1 || got[0] != "ca" {
+		t.Errorf("nested object in allOf: expected required [ca], got %v", tls["required"])
+	}
+
+	// The caller's schema is left untouched.
+	if _, ok := params["additionalProperties"]; ok {
+		t.Error("buildRequest mutated the caller's tool parameters")
+	}
+	if _, ok := params["properties"].(map[string]any)["target"].(map[string]any)["allOf"].([]map[string]any)[1]["required"]; ok {
+		t.Error("buildRequest mutated a nested caller schema")
+	}
+}
+
+func TestBuildRequest_UserToolsStrictNormalizesDefs(t *testing.T) {
+	h := &Harness{defaultModel: "gpt-5.2-codex"}
+	req, err := h.buildRequest(&harness.Turn{Tools: []harness.ToolSpec{{
+		Name: "edit",
+		Parameters: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"range": map[string]any{"$ref": "#/$defs/range"}},
+			"$defs": map[string]any{
+				"range": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"start": map[string]any{"type": "integer"},
+						"end":   map[string]any{"type": "integer"},
+					},
+				},
+			},
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(req.Tools[0].Parameters, &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	def := schema["$defs"].(map[string]any)["range"].(map[string]any)
+	if ap, ok := def["additionalProperties"].(bool); !ok || ap {
+		t.Errorf("expected $defs object to be closed, got %#v", def["additionalProperties"])
+	}
+	if got, _ := def["required"].([]any); len(got) != 2 {
+		t.Errorf("expected $defs properties to be required, got %v", def["required"])
+	}
+}
+
 func TestBuildRequest_MessageTypes(t *testing.T) {
 	h := &Harness{defaultModel: "test"}
 	turn := &harness.Turn{
@@ -424,3 +613,212 @@ func TestShellToolSpec_ValidJSON(t *testing.T) {
 		t.Fatalf("invalid parameters JSON: %v", err)
 	}
 }
+
+func TestBuildRequest_Sampling(t *testing.T) {
+	h := New(Config{})
+	temp, topP := 0.2, 0.9
+
+	req, err := h.buildRequest(&harness.Turn{Model: "gpt-4o", Temperature: &temp, TopP: &topP})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Temperature == nil || *req.Temperature != 0.2 || req.TopP == nil || *req.TopP != 0.9 {
+		t.Fatalf("expected sampling to be forwarded, got %v %v", req.Temperature, req.TopP)
+	}
+
+	// Reasoning models do not accept sampling parameters.
+	req, err = h.buildRequest(&harness.Turn{Model: "o3", Temperature: &temp, TopP: &topP})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Temperature != nil || req.TopP != nil {
+		t.Fatalf("expected sampling to be dropped for o3, got %v %v", req.Temperature, req.TopP)
+	}
+
+	// The Responses API has no seed.
+	seed := int64(42)
+	req, err = h.buildRequest(&harness.Turn{Model: "gpt-4o", Seed: &seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Seed != nil {
+		t.Fatalf("expected seed to be dropped, got %v", *req.Seed)
+	}
+
+	req, err = h.buildRequest(&harness.Turn{Model: "gpt-4o", StopSequences: []string{"END"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.StopSequences) != 0 {
+		t.Fatalf("expected stop sequences to be matched locally, got %v upstream", req.StopSequences)
+	}
+
+	bad := 2.5
+	if _, err := h.buildRequest(&harness.Turn{Model: "gpt-4o", Temperature: &bad}); err == nil {
+		t.Fatal("expected out-of-range temperature to be rejected")
+	}
+}
+
+func TestCountTokens_Heuristic(t *testing.T) {
+	h := &Harness{defaultModel: "gpt-5.2-codex"}
+	short, err := h.CountTokens(context.Background(), &harness.Turn{
+		Messages: []harness.Message{{Role: "user", Content: "hiya"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short <= 0 {
+		t.Fatalf("expected the system prompt to be counted, got %d", short)
+	}
+	long, err := h.CountTokens(context.Background(), &harness.Turn{
+		Messages: []harness.Message{{Role: "user", Content: "hiya" + strings.Repeat("x", 400)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if long-short != 100 {
+		t.Fatalf("expected 400 more characters to add 100 tokens, got %d", long-short)
+	}
+}
+
+func longHistory(exchanges int) []harness.Message {
+	msgs := []harness.Message{{Role: "user", Content: "original prompt"}}
+	for i := 0; i < exchanges; i++ {
+		id := fmt.Sprintf("call_%d", i)
+		msgs = append(msgs,
+			harness.Message{Role: "assistant", Name: "shell", ToolID: id, Content: `{"cmd":"cat big.txt"}`},
+			harness.Message{Role: "tool", ToolID: id, Content: fmt.Sprintf("output %d ", i) + strings.Repeat("x", 400)},
+		)
+	}
+	return msgs
+}
+
+func TestBuildRequest_CompactsHistory(t *testing.T) {
+	h := &Harness{defaultModel: "gpt-5.2-codex"}
+	turn := &harness.Turn{Messages: longHistory(10)}
+	instructions, err := h.systemPrompt(turn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := promptCounter(instructions, nil)
+	h.maxContext = count(turn.Messages[:1]) + 700 // room for about six exchanges
+
+	req, err := h.buildRequest(turn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(req.Input) >= len(turn.Messages) {
+		t.Fatalf("expected history to be compacted, got %d items", len(req.Input))
+	}
+	if req.Input[0].Role != "user" || req.Input[0].Content[0].Text != "original prompt" {
+		t.Fatalf("original prompt dropped: %+v", req.Input[0])
+	}
+	last := req.Input[len(req.Input)-1]
+	if !strings.HasPrefix(last.Output, "output 9 ") {
+		t.Fatalf("most recent exchange dropped: %+v", last)
+	}
+	if req.Input[1].Type != "function_call" {
+		t.Fatalf("compaction split a tool call from its result: %+v", req.Input[1])
+	}
+
+	h.maxContext = 0
+	req, _ = h.buildRequest(turn)
+	if len(req.Input) != len(turn.Messages) {
+		t.Fatalf("MaxContextTokens 0 must not compact, got %d items", len(req.Input))
+	}
+}
+
+func TestStreamTurn_ReasoningSummaryPreamble(t *testing.T) {
+	h, server := newTestHarness(sseResponse(
+		`{"type":"response.output_item.added","item":{"id":"rs_1","type":"reasoning","summary":[]}}`,
+		`{"type":"response.output_item.done","item":{"id":"rs_1","type":"reasoning","summary":[{"type":"summary_text","text":"Checking the files"},{"type":"summary_text","text":"then answering"}]}}`,
+		`{"type":"response.output_item.done","item":{"id":"rs_1","type":"reasoning","summary":[{"type":"summary_text","text":"Checking the files"}]}}`,
+		`{"type":"response.output_text.delta","delta":"Done."}`,
+		`{"type":"response.completed","response":{}}`,
+	))
+	defer server.Close()
+
+	var kinds []harness.EventKind
+	var preamble string
+	err := h.StreamTurn(context.Background(), &harness.Turn{
+		Messages: []harness.Message{{Role: "user", Content: "hi"}},
+	}, func(ev harness.Event) error {
+		kinds = append(kinds, ev.Kind)
+		if ev.Kind == harness.EventPreamble {
+			preamble = ev.Preamble.Text
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []harness.EventKind{harness.EventPreamble, harness.EventText, harness.EventDone}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, kinds)
+	}
+	if preamble != "Checking the files\n\nthen answering" {
+		t.Errorf("unexpected preamble %q", preamble)
+	}
+}
+
+func TestStreamTurn_StopSequences(t *testing.T) {
+	h, server := newTestHarness(sseResponse(
+		`{"type":"response.created","response":{"id":"resp_1"}}`,
+		`{"type":"response.output_text.delta","delta":"Hello E"}`,
+		`{"type":"response.output_text.delta","delta":"ND world"}`,
+		`{"type":"response.completed","response":{}}`,
+	))
+	defer server.Close()
+
+	var text, stop string
+	var kinds []harness.EventKind
+	err := h.StreamTurn(context.Background(), &harness.Turn{
+		Messages:      []harness.Message{{Role: "user", Content: "hi"}},
+		StopSequences: []string{"END", "###"},
+	}, func(ev harness.Event) error {
+		kinds = append(kinds, ev.Kind)
+		switch ev.Kind {
+		case harness.EventText:
+			text += ev.Text.Delta
+		case harness.EventStopReason:
+			stop = ev.StopReason.Sequence
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Hello " || stop != "END" {
+		t.Errorf("text = %q, stop = %q", text, stop)
+	}
+	if kinds[len(kinds)-1] != harness.EventDone {
+		t.Errorf("expected a done event last, got %v", kinds)
+	}
+
+	// Held-back text that never completes a sequence is emitted before the
+	// usage and done events.
+	h2, server2 := newTestHarness(sseResponse(
+		`{"type":"response.output_text.delta","delta":"Hello #"}`,
+		`{"type":"response.completed","response":{"usage":{"input_tokens":3,"output_tokens":2}}}`,
+	))
+	defer server2.Close()
+	text = ""
+	kinds = nil
+	err = h2.StreamTurn(context.Background(), &harness.Turn{
+		Messages:      []harness.Message{{Role: "user", Content: "hi"}},
+		StopSequences: []string{"###"},
+	}, func(ev harness.Event) error {
+		kinds = append(kinds, ev.Kind)
+		if ev.Kind == harness.EventText {
+			text += ev.Text.Delta
+		}
+		return nil
+	})
+	if err != nil || text != "Hello #" {
+		t.Errorf("text = %q, err = %v", text, err)
+	}
+	want := []harness.EventKind{harness.EventText, harness.EventText, harness.EventUsage, harness.EventDone}
+	if fmt.Sprint(kinds) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, kinds)
+	}
+}
@@ -3,11 +3,13 @@ package codex
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"godex/pkg/auth"
@@ -33,7 +35,8 @@ func newTestHarness(handler http.HandlerFunc) (*Harness, *httptest.Server) {
 	store, _ := auth.Load(authPath)
 
 	client := NewClient(nil, store, ClientConfig{
-		BaseURL: server.URL,
+		BaseURL:          server.URL,
+		DisableDiscovery: true,
 	})
 	h := New(Config{Client: client, DefaultModel: "test-model"})
 	return h, server
@@ -240,3 +243,165 @@ func TestStreamTurn_Error(t *testing.T) {
 		t.Errorf("expected 1 error event, got %d", errorEvents)
 	}
 }
+
+func TestStreamTurn_SummarizeCompaction(t *testing.T) {
+	var requests []map[string]any
+	h, server := newTestHarness(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		requests = append(requests, body)
+		reply := "done"
+		if len(requests) == 1 {
+			reply = "earlier: cat big.txt several times"
+		}
+		sseResponse(
+			fmt.Sprintf(`{"type":"response.output_text.delta","delta":%q}`, reply),
+			`{"type":"response.completed","response":{"usage":{"input_tokens":1,"output_tokens":1}}}`,
+		)(w, r)
+	})
+	defer server.Close()
+
+	turn := &harness.Turn{Messages: longHistory(10)}
+	instructions, _ := h.systemPrompt(turn)
+	h.maxContext = promptCounter(instructions, nil)(turn.Messages[:1]) + 700
+	h.compaction = harness.CompactSummarize
+
+	if _, err := h.StreamAndCollect(context.Background(), turn); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected a summary call and the turn, got %d requests", len(requests))
+	}
+	raw, _ := json.Marshal(requests[0]["input"])
+	if !strings.Contains(string(raw), "output 0") {
+		t.Fatalf("summary call should include the dropped history: %s", raw)
+	}
+	raw, _ = json.Marshal(requests[1]["input"])
+	if !strings.Contains(string(raw), "earlier: cat big.txt several times") || strings.Contains(string(raw), "output 0") {
+		t.Fatalf("turn should carry the summary instead of the oldest history: %s", raw)
+	}
+}
+
+// overflowServer rejects the first n requests with context_length_exceeded
+// and records the number of input items in every request.
+func overflowServer(n int, inputs *[]int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Input []json.RawMessage `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		*inputs = append(*inputs, len(body.Input))
+		if len(*inputs) <= n {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":{"type":"invalid_request_error","code":"context_length_exceeded"}}`)
+			return
+		}
+		sseResponse(
+			`{"type":"response.output_text.delta","delta":"ok"}`,
+			`{"type":"response.completed","response":{}}`,
+		)(w, r)
+	}
+}
+
+func TestStreamTurn_ContextOverflowRetry(t *testing.T) {
+	var inputs []int
+	h, server := newTestHarness(overflowServer(1, &inputs))
+	defer server.Close()
+	h.maxContext = 1 << 20 // the estimate fits; only the backend objects
+
+	result, err := h.StreamAndCollect(context.Background(), &harness.Turn{Messages: longHistory(10)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FinalText != "ok" {
+		t.Errorf("expected retried turn to complete, got %q", result.FinalText)
+	}
+	if len(inputs) != 2 || inputs[1] >= inputs[0] {
+		t.Fatalf("expected a retry with shorter history, got input sizes %v", inputs)
+	}
+}
+
+func TestStreamTurn_ContextOverflowGivesUp(t *testing.T) {
+	var inputs []int
+	h, server := newTestHarness(overflowServer(100, &inputs))
+	defer server.Close()
+	h.maxContext = 1 << 20
+
+	// Long enough that every retry still has history to drop.
+	_, err := h.StreamAndCollect(context.Background(), &harness.Turn{Messages: longHistory(40)})
+	if !errors.Is(err, ErrContextLengthExceeded) {
+		t.Fatalf("expected ErrContextLengthExceeded, got %v", err)
+	}
+	if len(inputs) != 1+overflowRetries {
+		t.Fatalf("expected %d requests, got %d", 1+overflowRetries, len(inputs))
+	}
+}
+
+func TestStreamTurn_ContextOverflowDisabled(t *testing.T) {
+	var inputs []int
+	h, server := newTestHarness(overflowServer(1, &inputs))
+	defer server.Close()
+
+	_, err := h.StreamAndCollect(context.Background(), &harness.Turn{Messages: longHistory(10)})
+	if !errors.Is(err, ErrContextLengthExceeded) {
+		t.Fatalf("expected ErrContextLengthExceeded, got %v", err)
+	}
+	if len(inputs) != 1 {
+		t.Fatalf("expected no retry without MaxContextTokens, got %d requests", len(inputs))
+	}
+}
+
+func TestStreamTurn_PartialResponseOnUnexpectedEOF(t *testing.T) {
+	h, server := newTestHarness(func(w http.ResponseWriter, r *http.Request) {
+		body := "" +
+			`data: {"type":"response.output_text.delta","delta":"Half an "}` + "\n\n" +
+			`data: {"type":"response.output_item.added","item":{"id":"item_1","type":"function_call","call_id":"call_1","name":"shell","arguments":"{\"command\":[\"ls\"]}"}}` + "\n\n" +
+			`data: {"type":"response.output_item.added","item":{"id":"item_2","type":"function_call","call_id":"call_2","name":"shell"}}` + "\n\n" +
+			`data: {"type":"response.function_call_arguments.delta","item_id":"item_2","delta":"{\"comm"}` + "\n\n"
+		// Promise more than is sent, so the client sees the connection drop.
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)+100))
+		fmt.Fprint(w, body)
+	})
+	defer server.Close()
+
+	result, err := h.StreamAndCollect(context.Background(), &harness.Turn{
+		Messages: []harness.Message{{Role: "user", Content: "hi"}},
+	})
+	if !errors.Is(err, harness.ErrPartialResponse) {
+		t.Fatalf("expected ErrPartialResponse, got %v", err)
+	}
+	if result.FinalText != "Half an " {
+		t.Errorf("expected partial text, got %q", result.FinalText)
+	}
+	if len(result.ToolCalls) != 1 || result.ToolCalls[0].CallID != "call_1" {
+		t.Errorf("expected only the complete tool call, got %+v", result.ToolCalls)
+	}
+	for _, ev := range result.Events {
+		if ev.Kind == harness.EventDone {
+			t.Error("a partial response must not emit done")
+		}
+	}
+}
+
+func TestStreamTurn_PartialResponseFlushesStopHoldback(t *testing.T) {
+	h, server := newTestHarness(func(w http.ResponseWriter, r *http.Request) {
+		body := `data: {"type":"response.output_text.delta","delta":"Half an #"}` + "\n\n"
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)+100))
+		fmt.Fprint(w, body)
+	})
+	defer server.Close()
+
+	// "#" could start "###", so it is held back until the stream breaks.
+	result, err := h.StreamAndCollect(context.Background(), &harness.Turn{
+		Messages:      []harness.Message{{Role: "user", Content: "hi"}},
+		StopSequences: []string{"###"},
+	})
+	if !errors.Is(err, harness.ErrPartialResponse) {
+		t.Fatalf("expected ErrPartialResponse, got %v", err)
+	}
+	if result.FinalText != "Half an #" {
+		t.Errorf("expected the held-back text to be flushed, got %q", result.FinalText)
+	}
+}
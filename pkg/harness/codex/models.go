@@ -2,7 +2,7 @@ package codex
 
 import (
 	"context"
-	"os"
+	"errors"
 	"strings"
 
 	"godex/pkg/harness"
@@ -31,6 +31,49 @@ var defaultCodexModels = []harness.ModelInfo{
 	{ID: "o1-mini", Name: "o1 Mini", Provider: "codex"},
 }
 
+var codexVision = harness.CapabilitySet{
+	SupportsVision:           true,
+	SupportsTools:            true,
+	SupportsStructuredOutput: true,
+	SupportedMediaTypes:      harness.ImageMediaTypes,
+}
+
+// codexCapabilityRules is the known-model capability table, matched by
+// longest prefix.
+var codexCapabilityRules = []harness.CapabilityRule{
+	{Prefix: "gpt-5", Caps: withReasoning(codexVision, 400000)},
+	{Prefix: "gpt-4.1", Caps: withContext(codexVision, 1047576)},
+	{Prefix: "gpt-4o", Caps: withContext(codexVision, 128000)},
+	{Prefix: "o1", Caps: withReasoning(codexVision, 200000)},
+	{Prefix: "o1-mini", Caps: harness.CapabilitySet{MaxContextTokens: 128000, SupportsReasoning: true}},
+	{Prefix: "o3", Caps: withReasoning(codexVision, 200000)},
+	{Prefix: "o3-mini", Caps: harness.CapabilitySet{MaxContextTokens: 200000, SupportsTools: true, SupportsReasoning: true, SupportsStructuredOutput: true}},
+	{Prefix: "o4-mini", Caps: withReasoning(codexVision, 200000)},
+	{Prefix: "codex-", Caps: withReasoning(codexVision, 200000)},
+}
+
+var codexFallbackCapabilities = harness.CapabilitySet{SupportsTools: true}
+
+func withContext(c harness.CapabilitySet, tokens int) harness.CapabilitySet {
+	c.MaxContextTokens = tokens
+	return c
+}
+
+func withReasoning(c harness.CapabilitySet, tokens int) harness.CapabilitySet {
+	c.SupportsReasoning = true
+	return withContext(c, tokens)
+}
+
+// Capabilities reports what the model named in ctx supports, from the
+// known-model table.
+func (h *Harness) Capabilities(ctx context.Context) (harness.CapabilitySet, error) {
+	model, _ := harness.Model(ctx)
+	if model != "" {
+		model = h.ExpandAlias(model)
+	}
+	return harness.LookupCapabilities(codexCapabilityRules, codexFallbackCapabilities, model), nil
+}
+
 func (h *Harness) mergedAliases() map[string]string {
 	m := make(map[string]string, len(defaultCodexAliases))
 	for k, v := range defaultCodexAliases {
@@ -96,7 +139,7 @@ func (h *Harness) MatchesModel(model string) bool {
 // ListModels returns available Codex models.
 // If the client is available, it tries API discovery first.
 func (h *Harness) listModelsWithDiscovery(ctx context.Context) ([]harness.ModelInfo, error) {
-	if h.client != nil && os.Getenv("OPENAI_API_KEY") != "" {
+	if h.client != nil && !h.client.cfg.DisableDiscovery {
 		models, err := h.client.ListModels(ctx)
 		if err == nil && len(models) > 0 {
 			return models, nil
@@ -104,3 +147,17 @@ func (h *Harness) listModelsWithDiscovery(ctx context.Context) ([]harness.ModelI
 	}
 	return defaultCodexModels, nil
 }
+
+// DiscoverModels asks the Codex backend for its models, bypassing the cache
+// and the known-model fallback of ListModels, so a failing backend returns
+// its error. Health checks use it to see the upstream. With discovery
+// disabled it returns the known models.
+func (h *Harness) DiscoverModels(ctx context.Context) ([]harness.ModelInfo, error) {
+	if h.client == nil {
+		return nil, errors.New("codex: no client configured")
+	}
+	if h.client.cfg.DisableDiscovery {
+		return knownCodexModels, nil
+	}
+	return h.client.discoverModels(ctx)
+}
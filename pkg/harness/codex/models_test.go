@@ -1,7 +1,10 @@
 package codex
 
 import (
+	"context"
 	"testing"
+
+	"godex/pkg/harness"
 )
 
 func TestExpandAlias(t *testing.T) {
@@ -69,3 +72,19 @@ func TestMatchesModel_ExtraPrefixes(t *testing.T) {
 		t.Error("expected gpt-5 to still match")
 	}
 }
+
+func TestCapabilities(t *testing.T) {
+	h := New(Config{})
+	caps, _ := h.Capabilities(harness.WithModel(context.Background(), "gpt"))
+	if !caps.SupportsVision || !caps.SupportsReasoning || caps.MaxContextTokens != 400000 {
+		t.Fatalf("gpt alias: unexpected %+v", caps)
+	}
+	caps, _ = h.Capabilities(harness.WithModel(context.Background(), "o3-mini"))
+	if caps.SupportsVision || !caps.SupportsReasoning {
+		t.Fatalf("o3-mini: unexpected %+v", caps)
+	}
+	caps, _ = h.Capabilities(context.Background())
+	if !caps.SupportsVision || !caps.SupportsTools {
+		t.Fatalf("harness-wide: unexpected %+v", caps)
+	}
+}
@@ -0,0 +1,55 @@
+package codex
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"godex/pkg/harness"
+)
+
+// SaveSession writes turn to path as JSON, including its messages and
+// PromptCacheKey, so a later process can continue the conversation with
+// LoadSession and hit the same server-side prompt cache. An empty
+// PromptCacheKey is filled from the client's session id. The file is
+// replaced atomically and is readable only by the owner.
+func (h *Harness) SaveSession(path string, turn *harness.Turn) error {
+	saved := *turn
+	if saved.PromptCacheKey == "" && h.client != nil {
+		saved.PromptCacheKey = h.client.cfg.SessionID
+	}
+	data, err := json.MarshalIndent(&saved, "", "  ")
+	if err != nil {
+		return fmt.Errorf("codex: encode session: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".session-*")
+	if err != nil {
+		return fmt.Errorf("codex: save session: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("codex: save session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("codex: save session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("codex: save session: %w", err)
+	}
+	return nil
+}
+
+// LoadSession reads a turn written by SaveSession.
+func (h *Harness) LoadSession(path string) (*harness.Turn, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("codex: load session: %w", err)
+	}
+	var turn harness.Turn
+	if err := json.Unmarshal(data, &turn); err != nil {
+		return nil, fmt.Errorf("codex: parse session %s: %w", path, err)
+	}
+	return &turn, nil
+}
@@ -0,0 +1,68 @@
+package codex
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"godex/pkg/harness"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	h := New(Config{})
+	path := filepath.Join(t.TempDir(), "session.json")
+	turn := &harness.Turn{
+		Model:          "gpt-5.2-codex",
+		Instructions:   "be brief",
+		PromptCacheKey: "cache-123",
+		Messages: []harness.Message{
+			{Role: "user", Content: "list files"},
+			{Role: "assistant", Name: "shell", ToolID: "call_1", Content: `{"cmd":"ls"}`},
+			{Role: "tool", ToolID: "call_1", Content: "README.md"},
+			{Role: "assistant", Content: "There is a README."},
+		},
+	}
+	if err := h.SaveSession(path, turn); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected 0600 session file, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := h.LoadSession(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, turn) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", loaded, turn)
+	}
+}
+
+func TestSaveSession_DefaultsCacheKeyToSessionID(t *testing.T) {
+	h := New(Config{Client: NewClient(nil, nil, ClientConfig{SessionID: "sess-1"})})
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := h.SaveSession(path, &harness.Turn{Messages: []harness.Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := h.LoadSession(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.PromptCacheKey != "sess-1" {
+		t.Errorf("expected cache key sess-1, got %q", loaded.PromptCacheKey)
+	}
+}
+
+func TestLoadSession_Missing(t *testing.T) {
+	_, err := New(Config{}).LoadSession(filepath.Join(t.TempDir(), "nope.json"))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package codex
+
+import (
+	"errors"
+	"strings"
+
+	"godex/pkg/harness"
+)
+
+// errStopSequence ends a stream once stopMatcher has found a stop sequence.
+var errStopSequence = errors.New("stop sequence matched")
+
+// stopMatcher applies Turn.StopSequences on our side, as the Responses API
+// takes none. Text that could still be the start of a sequence is held
+// back until the next delta tells.
+type stopMatcher struct {
+	stops   []string
+	onMatch func() // called once, when a sequence is found
+	pending string
+	matched string
+}
+
+// feed adds delta and returns the text that is safe to emit. Once a
+// sequence is found, it returns the text before it and sets matched.
+func (m *stopMatcher) feed(delta string) string {
+	buf := m.pending + delta
+	at := -1
+	for _, stop := range m.stops {
+		if i := strings.Index(buf, stop); i >= 0 && (at < 0 || i < at) {
+			at, m.matched = i, stop
+		}
+	}
+	if at >= 0 {
+		m.pending = ""
+		return buf[:at]
+	}
+	hold := 0
+	for _, stop := range m.stops {
+		for n := min(len(stop)-1, len(buf)); n > hold; n-- {
+			if strings.HasSuffix(buf, stop[:n]) {
+				hold = n
+				break
+			}
+		}
+	}
+	m.pending = buf[len(buf)-hold:]
+	return buf[:len(buf)-hold]
+}
+
+// flush returns the text held back so far.
+func (m *stopMatcher) flush() string {
+	out := m.pending
+	m.pending = ""
+	return out
+}
+
+// wrap returns an emit function that passes text events through m, emits
+// held-back text ahead of any other event, and returns errStopSequence
+// once a sequence matched.
+func (m *stopMatcher) wrap(emit func(harness.Event) error) func(harness.Event) error {
+	return func(ev harness.Event) error {
+		if m.matched != "" {
+			return errStopSequence
+		}
+		if ev.Kind == harness.EventText && ev.Text != nil && ev.Text.Complete == "" {
+			text := m.feed(ev.Text.Delta)
+			if text != "" {
+				if err := emit(harness.NewTextEvent(text)); err != nil {
+					return err
+				}
+			}
+			if m.matched != "" {
+				if m.onMatch != nil {
+					m.onMatch()
+				}
+				return errStopSequence
+			}
+			return nil
+		}
+		if text := m.flush(); text != "" {
+			if err := emit(harness.NewTextEvent(text)); err != nil {
+				return err
+			}
+		}
+		return emit(ev)
+	}
+}
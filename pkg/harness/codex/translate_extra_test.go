@@ -305,6 +305,24 @@ func TestTranslateEvent_ResponseDone_NoUsage(t *testing.T) {
 	}
 }
 
+func TestTranslateEvent_ResponseDone_StopSequence(t *testing.T) {
+	h := &Harness{}
+	collector := sse.NewCollector()
+
+	ev := protocol.StreamEvent{Type: "response.completed", Response: &protocol.ResponseRef{StopReason: "stop_sequence", StopSequence: "###"}}
+	var events []harness.Event
+	err := h.translateEvent(ev, collector, func(e harness.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Kind != harness.EventStopReason || events[0].StopReason.Sequence != "###" {
+		t.Fatalf("expected stop_reason event, got %v", events)
+	}
+}
+
 func TestTranslateEvent_ResponseDone_NilResponse(t *testing.T) {
 	h := &Harness{}
 	collector := sse.NewCollector()
@@ -357,3 +375,52 @@ func TestTranslateEvent_EmitError(t *testing.T) {
 		t.Errorf("expected emit error, got %v", err)
 	}
 }
+
+func TestTranslateEvent_ApplyPatchEmitsPatchApplied(t *testing.T) {
+	h := &Harness{}
+	collector := sse.NewCollector()
+
+	patch := "*** Begin Patch\n*** Update File: main.go\n@@\n-a\n+b\n+c\n*** End Patch"
+	ev := protocol.StreamEvent{
+		Type: "response.output_item.done",
+		Item: &protocol.OutputItem{
+			Type:      "function_call",
+			CallID:    "call_patch",
+			Name:      "apply_patch",
+			Arguments: patch,
+		},
+	}
+	var events []harness.Event
+	err := h.translateEvent(ev, collector, func(e harness.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Kind != harness.EventToolCall || events[1].Kind != harness.EventPatchApplied {
+		t.Fatalf("expected tool_call then patch_applied, got %v", events)
+	}
+	want := harness.ParsedPatch{FilePath: "main.go", HunkCount: 1, AddedLines: 2, RemovedLines: 1}
+	if *events[1].Patch != want {
+		t.Errorf("got %+v, want %+v", *events[1].Patch, want)
+	}
+}
+
+func TestTranslateEvent_ApplyPatchUnparsable(t *testing.T) {
+	h := &Harness{}
+	ev := protocol.StreamEvent{
+		Type: "response.output_item.done",
+		Item: &protocol.OutputItem{Type: "function_call", CallID: "c", Name: "apply_patch", Arguments: "not a patch"},
+	}
+	var events []harness.Event
+	if err := h.translateEvent(ev, sse.NewCollector(), func(e harness.Event) error {
+		events = append(events, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Kind != harness.EventToolCall {
+		t.Fatalf("expected only the tool call, got %v", events)
+	}
+}
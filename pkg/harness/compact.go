@@ -0,0 +1,188 @@
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Compaction strategies for harnesses that trim history to fit a context
+// window.
+const (
+	// CompactDropOldest drops the oldest exchanges (the default).
+	CompactDropOldest = "drop_oldest"
+	// CompactSummarize replaces the dropped exchanges with a model-written
+	// summary, at the cost of an extra model call.
+	CompactSummarize = "summarize"
+)
+
+// CompactKeepRecent is the number of most recent exchanges CompactHistory
+// always keeps.
+const CompactKeepRecent = 4
+
+// ErrUnknownCompaction is returned for a strategy other than the Compact*
+// constants.
+var ErrUnknownCompaction = errors.New("unknown compaction strategy")
+
+// ValidateCompaction checks a configured strategy name. Empty means
+// CompactDropOldest.
+func ValidateCompaction(strategy string) error {
+	switch strategy {
+	case "", CompactDropOldest, CompactSummarize:
+		return nil
+	}
+	return fmt.Errorf("%w %q (want %s or %s)", ErrUnknownCompaction, strategy, CompactDropOldest, CompactSummarize)
+}
+
+// CompactHistory trims the oldest exchanges from messages until countFn
+// reports at most maxTokens. System messages, the first user message (the
+// original prompt) and the CompactKeepRecent most recent exchanges are never
+// dropped, so the result can still exceed maxTokens. An assistant tool call
+// and its tool results form one exchange and are dropped together. messages
+// is returned unchanged when it already fits or maxTokens <= 0.
+func CompactHistory(messages []Message, maxTokens int, countFn func([]Message) int) []Message {
+	kept, _, _ := compact(messages, maxTokens, countFn)
+	return kept
+}
+
+// SummarizeHistory is CompactHistory with the CompactSummarize strategy: the
+// exchanges CompactHistory would drop are summarized by collect and the
+// summary is kept in their place as an assistant message. If the summary
+// itself does not fit, the plain CompactHistory result is returned. The
+// error is non-nil only when the summary call fails; the compacted history
+// is returned alongside it so callers can fall back.
+func SummarizeHistory(
+	ctx context.Context,
+	collect func(ctx context.Context, turn *Turn) (*TurnResult, error),
+	model string,
+	messages []Message,
+	maxTokens int,
+	countFn func([]Message) int,
+) ([]Message, error) {
+	kept, dropped, at := compact(messages, maxTokens, countFn)
+	if len(dropped) == 0 {
+		return kept, nil
+	}
+	result, err := collect(ctx, &Turn{
+		Model: model,
+		Instructions: "Summarize the conversation excerpt below for the assistant that will continue it. " +
+			"Keep facts, decisions, file names, tool results and open tasks. Be concise; output only the summary.",
+		Messages: []Message{{Role: "user", Content: transcript(dropped)}},
+	})
+	if err != nil {
+		return kept, fmt.Errorf("summarize history: %w", err)
+	}
+	summary := strings.TrimSpace(result.FinalText)
+	if summary == "" {
+		return kept, nil
+	}
+	out := make([]Message, 0, len(kept)+1)
+	out = append(out, kept[:at]...)
+	out = append(out, Message{Role: "assistant", Content: "Summary of the earlier conversation:\n" + summary})
+	out = append(out, kept[at:]...)
+	if countFn(out) > maxTokens {
+		return kept, nil
+	}
+	return out, nil
+}
+
+// compact returns the kept messages, the dropped ones in order, and the
+// index in kept where the dropped messages used to start.
+func compact(messages []Message, maxTokens int, countFn func([]Message) int) (kept, dropped []Message, at int) {
+	if maxTokens <= 0 || countFn(messages) <= maxTokens {
+		return messages, nil, 0
+	}
+	groups := exchanges(messages)
+	var droppable []int // indices into groups, oldest first
+	for i, g := range groups {
+		if !g.pinned {
+			droppable = append(droppable, i)
+		}
+	}
+	if len(droppable) > CompactKeepRecent {
+		droppable = droppable[:len(droppable)-CompactKeepRecent]
+	} else {
+		droppable = nil
+	}
+
+	drop := make(map[int]bool, len(droppable))
+	assemble := func() []Message {
+		out := make([]Message, 0, len(messages))
+		for i, g := range groups {
+			if !drop[i] {
+				out = append(out, messages[g.start:g.end]...)
+			}
+		}
+		return out
+	}
+	kept = messages
+	for _, i := range droppable {
+		drop[i] = true
+		kept = assemble()
+		if countFn(kept) <= maxTokens {
+			break
+		}
+	}
+	for i, g := range groups {
+		if !drop[i] {
+			continue
+		}
+		if dropped == nil {
+			for j := 0; j < i; j++ {
+				if !drop[j] {
+					at += groups[j].end - groups[j].start
+				}
+			}
+		}
+		dropped = append(dropped, messages[g.start:g.end]...)
+	}
+	return kept, dropped, at
+}
+
+type exchange struct {
+	start, end int // messages[start:end]
+	pinned     bool
+}
+
+// exchanges splits messages into the units CompactHistory drops. A new unit
+// starts at every non-tool message, except that consecutive assistant tool
+// calls (parallel calls) share one unit with the results that follow them.
+// System messages and the first user message are pinned units of their own.
+func exchanges(messages []Message) []exchange {
+	var out []exchange
+	seenUser := false
+	for i, msg := range messages {
+		pinned := msg.Role == "system" || (msg.Role == "user" && !seenUser)
+		if msg.Role == "user" {
+			seenUser = true
+		}
+		if len(out) > 0 && !pinned && !out[len(out)-1].pinned {
+			prev := messages[i-1]
+			joins := msg.Role == "tool" ||
+				(msg.Role == "assistant" && msg.ToolID != "" && prev.Role == "assistant" && prev.ToolID != "")
+			if joins {
+				out[len(out)-1].end = i + 1
+				continue
+			}
+		}
+		out = append(out, exchange{start: i, end: i + 1, pinned: pinned})
+	}
+	return out
+}
+
+// transcript renders messages as plain text for the summary call.
+func transcript(messages []Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		switch {
+		case msg.Role == "assistant" && msg.ToolID != "":
+			fmt.Fprintf(&b, "assistant called %s(%s)\n", msg.Name, msg.Content)
+		case msg.Role == "tool":
+			fmt.Fprintf(&b, "tool result: %s\n", msg.Content)
+		default:
+			fmt.Fprintf(&b, "%s: %s\n", msg.Role, msg.Content)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,139 @@
+package harness
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// countMessages charges one token per message, which makes budgets easy to
+// reason about in tests.
+func countMessages(msgs []Message) int { return len(msgs) }
+
+func syntheticHistory(exchanges int) []Message {
+	msgs := []Message{
+		{Role: "system", Content: "be helpful"},
+		{Role: "user", Content: "original prompt"},
+	}
+	for i := 0; i < exchanges; i++ {
+		id := fmt.Sprintf("call_%d", i)
+		msgs = append(msgs,
+			Message{Role: "assistant", Name: "shell", ToolID: id, Content: `{"cmd":"ls"}`},
+			Message{Role: "tool", ToolID: id, Content: fmt.Sprintf("output %d", i)},
+		)
+	}
+	return msgs
+}
+
+func TestCompactHistoryDropsOldestExchanges(t *testing.T) {
+	msgs := syntheticHistory(8) // 2 pinned + 16
+	got := CompactHistory(msgs, 12, countMessages)
+	if len(got) != 12 {
+		t.Fatalf("expected 12 messages, got %d", len(got))
+	}
+	if got[0].Role != "system" || got[1].Content != "original prompt" {
+		t.Fatalf("system message and original prompt must be kept: %+v", got[:2])
+	}
+	// Exchanges 0..2 dropped; 3..7 kept in order.
+	if got[2].ToolID != "call_3" || got[len(got)-1].Content != "output 7" {
+		t.Fatalf("unexpected kept range: first %+v, last %+v", got[2], got[len(got)-1])
+	}
+	for i := 2; i < len(got); i += 2 {
+		if got[i].Role != "assistant" || got[i+1].Role != "tool" || got[i].ToolID != got[i+1].ToolID {
+			t.Fatalf("tool call split from its result at %d: %+v %+v", i, got[i], got[i+1])
+		}
+	}
+}
+
+func TestCompactHistoryKeepsRecentAndSystem(t *testing.T) {
+	msgs := syntheticHistory(8)
+	got := CompactHistory(msgs, 1, countMessages)
+	want := 2 + 2*CompactKeepRecent
+	if len(got) != want {
+		t.Fatalf("expected %d messages (pinned + recent), got %d", want, len(got))
+	}
+	if got[0].Role != "system" {
+		t.Fatal("system message dropped")
+	}
+
+	// A system message in the middle of history is never dropped either.
+	mid := append(syntheticHistory(3), Message{Role: "system", Content: "late system"})
+	mid = append(mid, syntheticHistory(6)[2:]...)
+	got = CompactHistory(mid, 1, countMessages)
+	found := false
+	for _, m := range got {
+		if m.Content == "late system" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("mid-history system message dropped")
+	}
+}
+
+func TestCompactHistoryNoop(t *testing.T) {
+	msgs := syntheticHistory(3)
+	if got := CompactHistory(msgs, 100, countMessages); len(got) != len(msgs) {
+		t.Fatalf("history that fits must be unchanged, got %d", len(got))
+	}
+	if got := CompactHistory(msgs, 0, countMessages); len(got) != len(msgs) {
+		t.Fatalf("maxTokens 0 disables compaction, got %d", len(got))
+	}
+}
+
+func TestCompactHistoryParallelCallsStayTogether(t *testing.T) {
+	msgs := []Message{
+		{Role: "user", Content: "prompt"},
+		{Role: "assistant", Name: "a", ToolID: "1"},
+		{Role: "assistant", Name: "b", ToolID: "2"},
+		{Role: "tool", ToolID: "1"},
+		{Role: "tool", ToolID: "2"},
+	}
+	for i := 0; i < CompactKeepRecent; i++ {
+		msgs = append(msgs, Message{Role: "assistant", Content: fmt.Sprintf("reply %d", i)})
+	}
+	got := CompactHistory(msgs, len(msgs)-1, countMessages)
+	if len(got) != 1+CompactKeepRecent {
+		t.Fatalf("expected the whole parallel exchange dropped, got %+v", got)
+	}
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	msgs := syntheticHistory(8)
+	var prompt string
+	collect := func(_ context.Context, turn *Turn) (*TurnResult, error) {
+		prompt = turn.Messages[0].Content
+		return &TurnResult{FinalText: "ran ls three times"}, nil
+	}
+	got, err := SummarizeHistory(context.Background(), collect, "m", msgs, 13, countMessages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 13 || got[2].Role != "assistant" || !strings.Contains(got[2].Content, "ran ls three times") {
+		t.Fatalf("expected summary after the original prompt, got %+v", got[:3])
+	}
+	if !strings.Contains(prompt, "output 0") || strings.Contains(prompt, "output 7") {
+		t.Fatalf("summary prompt should cover only dropped exchanges: %q", prompt)
+	}
+
+	boom := errors.New("boom")
+	got, err = SummarizeHistory(context.Background(), func(context.Context, *Turn) (*TurnResult, error) {
+		return nil, boom
+	}, "m", msgs, 12, countMessages)
+	if !errors.Is(err, boom) || len(got) != 12 {
+		t.Fatalf("expected drop_oldest fallback with error, got %d, %v", len(got), err)
+	}
+}
+
+func TestValidateCompaction(t *testing.T) {
+	for _, s := range []string{"", CompactDropOldest, CompactSummarize} {
+		if err := ValidateCompaction(s); err != nil {
+			t.Errorf("%q: %v", s, err)
+		}
+	}
+	if err := ValidateCompaction("truncate"); !errors.Is(err, ErrUnknownCompaction) {
+		t.Fatalf("expected ErrUnknownCompaction, got %v", err)
+	}
+}
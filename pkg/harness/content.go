@@ -0,0 +1,86 @@
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ContentBlock is one part of a multi-part message. It is either a
+// TextBlock or an ImageBlock.
+type ContentBlock interface {
+	contentBlock()
+}
+
+// TextBlock is a text part of a message.
+type TextBlock struct {
+	Text string `json:"text"`
+}
+
+// ImageBlock is an image part of a message. Set either Base64 and MediaType
+// for inline data, or URL for an image the provider fetches itself.
+type ImageBlock struct {
+	MediaType string `json:"media_type,omitempty"` // e.g. "image/png"
+	Base64    string `json:"base64,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+func (TextBlock) contentBlock()  {}
+func (ImageBlock) contentBlock() {}
+
+// MarshalJSON adds a "type": "text" discriminator.
+func (b TextBlock) MarshalJSON() ([]byte, error) {
+	type plain TextBlock
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{"text", plain(b)})
+}
+
+// MarshalJSON adds a "type": "image" discriminator.
+func (b ImageBlock) MarshalJSON() ([]byte, error) {
+	type plain ImageBlock
+	return json.Marshal(struct {
+		Type string `json:"type"`
+		plain
+	}{"image", plain(b)})
+}
+
+// UnmarshalJSON decodes ContentBlocks by their "type" field, so messages
+// saved in sessions and logs load back with their images.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type plain Message
+	var raw struct {
+		plain
+		ContentBlocks []json.RawMessage `json:"content_blocks,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = Message(raw.plain)
+	m.ContentBlocks = nil
+	for _, item := range raw.ContentBlocks {
+		var head struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(item, &head); err != nil {
+			return err
+		}
+		switch head.Type {
+		case "text":
+			var b TextBlock
+			if err := json.Unmarshal(item, &b); err != nil {
+				return err
+			}
+			m.ContentBlocks = append(m.ContentBlocks, b)
+		case "image":
+			var b ImageBlock
+			if err := json.Unmarshal(item, &b); err != nil {
+				return err
+			}
+			m.ContentBlocks = append(m.ContentBlocks, b)
+		default:
+			return fmt.Errorf("harness: unknown content block type %q", head.Type)
+		}
+	}
+	return nil
+}
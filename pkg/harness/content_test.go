@@ -0,0 +1,51 @@
+package harness
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMessageContentBlocksRoundTrip(t *testing.T) {
+	msg := Message{
+		Role: "user",
+		ContentBlocks: []ContentBlock{
+			TextBlock{Text: "describe"},
+			ImageBlock{MediaType: "image/png", Base64: "aGk="},
+			ImageBlock{URL: "https://example.com/a.png"},
+		},
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `{"type":"image","media_type":"image/png","base64":"aGk="}`) {
+		t.Errorf("unexpected encoding: %s", data)
+	}
+	var got Message
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, msg) {
+		t.Fatalf("round trip mismatch:\n got %+v\nwant %+v", got, msg)
+	}
+}
+
+func TestMessageUnmarshal_TextOnly(t *testing.T) {
+	var got Message
+	if err := json.Unmarshal([]byte(`{"role":"user","content":"hi"}`), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Content != "hi" || got.ContentBlocks != nil {
+		t.Fatalf("unexpected message %+v", got)
+	}
+}
+
+func TestMessageUnmarshal_UnknownBlockType(t *testing.T) {
+	var got Message
+	err := json.Unmarshal([]byte(`{"role":"user","content_blocks":[{"type":"audio"}]}`), &got)
+	if err == nil {
+		t.Fatal("expected an error for an unknown block type")
+	}
+}
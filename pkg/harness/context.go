@@ -4,7 +4,11 @@ import "context"
 
 type contextKey string
 
-const providerKeyKey contextKey = "provider-key"
+const (
+	providerKeyKey contextKey = "provider-key"
+	requestIDKey   contextKey = "request-id"
+	modelKey       contextKey = "model"
+)
 
 // WithProviderKey returns a context with a provider API key override.
 func WithProviderKey(ctx context.Context, key string) context.Context {
@@ -16,3 +20,26 @@ func ProviderKey(ctx context.Context) (string, bool) {
 	key, ok := ctx.Value(providerKeyKey).(string)
 	return key, ok && key != ""
 }
+
+// WithRequestID returns a context carrying a correlation ID that clients
+// forward upstream as X-Request-ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID extracts the correlation ID from the context, if any.
+func RequestID(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey).(string)
+	return id, ok && id != ""
+}
+
+// WithModel returns a context naming the model a Capabilities call is about.
+func WithModel(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, modelKey, model)
+}
+
+// Model extracts the model set by WithModel, if any.
+func Model(ctx context.Context) (string, bool) {
+	model, ok := ctx.Value(modelKey).(string)
+	return model, ok && model != ""
+}
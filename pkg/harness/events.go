@@ -24,6 +24,15 @@ const (
 	EventError
 	// EventDone indicates the turn is complete.
 	EventDone
+	// EventStopReason indicates generation ended on one of the turn's stop
+	// sequences.
+	EventStopReason
+	// EventPatchApplied summarizes an apply_patch tool call. It follows the
+	// call's EventToolCall.
+	EventPatchApplied
+	// EventLogProbs carries the log probabilities of the tokens in the
+	// preceding EventText, when the turn set LogProbs.
+	EventLogProbs
 )
 
 // String returns the human-readable name of the event kind.
@@ -47,6 +56,12 @@ func (k EventKind) String() string {
 		return "error"
 	case EventDone:
 		return "done"
+	case EventStopReason:
+		return "stop_reason"
+	case EventPatchApplied:
+		return "patch_applied"
+	case EventLogProbs:
+		return "logprobs"
 	default:
 		return "unknown"
 	}
@@ -66,6 +81,10 @@ type Event struct {
 	Preamble   *PreambleEvent   `json:"preamble,omitempty"`
 	Usage      *UsageEvent      `json:"usage,omitempty"`
 	Error      *ErrorEvent      `json:"error,omitempty"`
+	Done       *DoneEvent       `json:"done,omitempty"`
+	StopReason *StopReasonEvent `json:"stop_reason,omitempty"`
+	Patch      *ParsedPatch     `json:"patch,omitempty"`
+	LogProbs   *LogProbsEvent   `json:"logprobs,omitempty"`
 }
 
 // TextEvent carries a model text output delta or complete text.
@@ -113,6 +132,9 @@ type UsageEvent struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
 	TotalTokens  int `json:"total_tokens,omitempty"`
+	// CachedTokens is the part of InputTokens read from the provider's
+	// prompt cache.
+	CachedTokens int `json:"cached_tokens,omitempty"`
 }
 
 // ErrorEvent carries error information from the turn.
@@ -122,6 +144,39 @@ type ErrorEvent struct {
 	Retry   bool   `json:"retry,omitempty"` // Whether the caller should retry
 }
 
+// DoneEvent carries optional metadata reported when a turn completes.
+type DoneEvent struct {
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+	// ResponseID is the provider's ID for a response it stored.
+	ResponseID string `json:"response_id,omitempty"`
+}
+
+// StopReasonEvent reports why generation stopped. Reason is
+// StopReasonStopSequence and Sequence is the stop sequence that matched, or
+// empty if the provider does not say which one.
+type StopReasonEvent struct {
+	Reason   string `json:"reason"`
+	Sequence string `json:"sequence,omitempty"`
+}
+
+// LogProbsEvent carries per-token log probabilities for a text delta.
+type LogProbsEvent struct {
+	Tokens []TokenLogProb `json:"tokens"`
+}
+
+// TokenLogProb is one token's log probability. TopLogProbs lists the most
+// likely alternatives at that position and is empty for the alternatives
+// themselves.
+type TokenLogProb struct {
+	Token       string         `json:"token"`
+	LogProb     float64        `json:"logprob"`
+	TopLogProbs []TokenLogProb `json:"top_logprobs,omitempty"`
+}
+
+// StopReasonStopSequence is the StopReasonEvent.Reason for a stop sequence
+// match.
+const StopReasonStopSequence = "stop_sequence"
+
 // NewTextEvent creates a text event with the given delta.
 func NewTextEvent(delta string) Event {
 	return Event{
@@ -201,3 +256,31 @@ func NewDoneEvent() Event {
 		Timestamp: time.Now(),
 	}
 }
+
+// NewStopReasonEvent creates an event reporting that the given stop sequence
+// ended generation.
+func NewStopReasonEvent(sequence string) Event {
+	return Event{
+		Kind:       EventStopReason,
+		Timestamp:  time.Now(),
+		StopReason: &StopReasonEvent{Reason: StopReasonStopSequence, Sequence: sequence},
+	}
+}
+
+// NewLogProbsEvent creates an event carrying per-token log probabilities.
+func NewLogProbsEvent(tokens []TokenLogProb) Event {
+	return Event{
+		Kind:      EventLogProbs,
+		Timestamp: time.Now(),
+		LogProbs:  &LogProbsEvent{Tokens: tokens},
+	}
+}
+
+// NewPatchAppliedEvent creates an event summarizing an apply_patch call.
+func NewPatchAppliedEvent(patch *ParsedPatch) Event {
+	return Event{
+		Kind:      EventPatchApplied,
+		Timestamp: time.Now(),
+		Patch:     patch,
+	}
+}
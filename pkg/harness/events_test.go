@@ -16,6 +16,7 @@ func TestEventKindString(t *testing.T) {
 		{EventUsage, "usage"},
 		{EventError, "error"},
 		{EventDone, "done"},
+		{EventStopReason, "stop_reason"},
 		{EventKind(99), "unknown"},
 	}
 	for _, tt := range tests {
@@ -70,4 +71,9 @@ func TestEventConstructors(t *testing.T) {
 	if ev.Kind != EventDone {
 		t.Error("NewDoneEvent failed")
 	}
+
+	ev = NewStopReasonEvent("\n\n")
+	if ev.Kind != EventStopReason || ev.StopReason.Reason != StopReasonStopSequence || ev.StopReason.Sequence != "\n\n" {
+		t.Error("NewStopReasonEvent failed")
+	}
 }
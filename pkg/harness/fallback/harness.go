@@ -0,0 +1,183 @@
+// Package fallback provides a harness that tries several backends in order,
+// moving on to the next one when a backend fails.
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"godex/pkg/harness"
+)
+
+// Chain tries each harness in order until one succeeds. Every harness in the
+// chain should be able to serve the models routed to it.
+type Chain struct {
+	Harnesses []harness.Harness
+}
+
+// New creates a chain over the given harnesses, in priority order.
+func New(harnesses ...harness.Harness) *Chain {
+	return &Chain{Harnesses: harnesses}
+}
+
+// Name returns "fallback(h1,h2,…)".
+func (c *Chain) Name() string {
+	names := make([]string, len(c.Harnesses))
+	for i, h := range c.Harnesses {
+		names[i] = h.Name()
+	}
+	return "fallback(" + strings.Join(names, ",") + ")"
+}
+
+// StreamTurn runs the turn on each harness in turn. Events are forwarded
+// as they arrive, so the chain only moves on while an attempt has emitted
+// nothing; once output has reached the caller, a failure is returned as
+// is. Bad requests and context cancellation are returned immediately.
+func (c *Chain) StreamTurn(ctx context.Context, turn *harness.Turn, onEvent func(harness.Event) error) error {
+	if len(c.Harnesses) == 0 {
+		return errors.New("fallback: no harnesses configured")
+	}
+	var errs []error
+	for _, h := range c.Harnesses {
+		forwarded := false
+		var callbackErr error
+		err := h.StreamTurn(ctx, turn, func(ev harness.Event) error {
+			forwarded = true
+			if err := onEvent(ev); err != nil {
+				callbackErr = err
+				return err
+			}
+			return nil
+		})
+		if err == nil {
+			return nil
+		}
+		if forwarded || callbackErr != nil || !shouldFallback(ctx, err) {
+			return err
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", h.Name(), err))
+	}
+	return fmt.Errorf("fallback: all %d backends failed: %w", len(c.Harnesses), errors.Join(errs...))
+}
+
+// StreamAndCollect executes a turn and returns the collected result of the
+// first harness that succeeds.
+func (c *Chain) StreamAndCollect(ctx context.Context, turn *harness.Turn) (*harness.TurnResult, error) {
+	start := time.Now()
+	result := &harness.TurnResult{}
+	err := c.StreamTurn(ctx, turn, func(ev harness.Event) error {
+		result.Events = append(result.Events, ev)
+		switch ev.Kind {
+		case harness.EventText:
+			if ev.Text != nil {
+				result.FinalText += ev.Text.Delta
+				if ev.Text.Complete != "" {
+					result.FinalText = ev.Text.Complete
+				}
+			}
+		case harness.EventUsage:
+			result.Usage = ev.Usage
+		case harness.EventDone:
+			if ev.Done != nil {
+				result.SystemFingerprint = ev.Done.SystemFingerprint
+			}
+		case harness.EventToolCall:
+			if ev.ToolCall != nil {
+				result.ToolCalls = append(result.ToolCalls, *ev.ToolCall)
+			}
+		}
+		return nil
+	})
+	result.Duration = time.Since(start)
+	if err == nil {
+		_ = harness.ParseStructuredOutput(turn, result)
+	}
+	return result, err
+}
+
+// RunToolLoop executes the full agentic loop; each model call falls back
+// independently.
+func (c *Chain) RunToolLoop(ctx context.Context, turn *harness.Turn, handler harness.ToolHandler, opts harness.LoopOptions) (*harness.TurnResult, error) {
+	return harness.RunToolLoop(ctx, c.StreamTurn, turn, handler, opts)
+}
+
+// BatchTurns runs independent turns concurrently.
+func (c *Chain) BatchTurns(ctx context.Context, turns []*harness.Turn, onResult func(index int, result *harness.TurnResult, err error), opts harness.LoopOptions) error {
+	return harness.RunBatch(ctx, c.StreamAndCollect, turns, onResult, opts)
+}
+
+// Capabilities reports the primary harness's capabilities.
+func (c *Chain) Capabilities(ctx context.Context) (harness.CapabilitySet, error) {
+	if len(c.Harnesses) == 0 {
+		return harness.CapabilitySet{}, nil
+	}
+	return c.Harnesses[0].Capabilities(ctx)
+}
+
+// CountTokens asks the primary harness, since that is where the turn goes
+// first.
+func (c *Chain) CountTokens(ctx context.Context, turn *harness.Turn) (int, error) {
+	if len(c.Harnesses) == 0 {
+		return harness.EstimateTurnTokens(turn), nil
+	}
+	return c.Harnesses[0].CountTokens(ctx, turn)
+}
+
+// ListModels returns the union of all harness models, first occurrence of
+// each ID winning. It fails only if every harness fails.
+func (c *Chain) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
+	seen := map[string]bool{}
+	var models []harness.ModelInfo
+	var errs []error
+	for _, h := range c.Harnesses {
+		list, err := h.ListModels(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", h.Name(), err))
+			continue
+		}
+		for _, m := range list {
+			if !seen[m.ID] {
+				seen[m.ID] = true
+				models = append(models, m)
+			}
+		}
+	}
+	if len(errs) > 0 && len(errs) == len(c.Harnesses) {
+		return nil, errors.Join(errs...)
+	}
+	return models, nil
+}
+
+// ExpandAlias returns the first expansion offered by a harness in the chain.
+func (c *Chain) ExpandAlias(alias string) string {
+	for _, h := range c.Harnesses {
+		if expanded := h.ExpandAlias(alias); expanded != alias {
+			return expanded
+		}
+	}
+	return alias
+}
+
+// MatchesModel returns true if any harness in the chain handles model.
+func (c *Chain) MatchesModel(model string) bool {
+	for _, h := range c.Harnesses {
+		if h.MatchesModel(model) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldFallback reports whether another backend might succeed where err
+// failed. A cancelled request or a request the provider rejected as
+// malformed will fail the same way everywhere.
+func shouldFallback(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	return harness.HTTPStatus(err) != http.StatusBadRequest
+}
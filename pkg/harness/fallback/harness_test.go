@@ -0,0 +1,144 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"godex/pkg/harness"
+)
+
+func failing(name string, err error) *harness.Mock {
+	return harness.NewMock(harness.MockConfig{
+		HarnessName: name,
+		Responses:   [][]harness.Event{{harness.NewTextEvent("partial"), harness.NewTextEvent("never")}},
+		FailAfterN:  1,
+		FailErr:     err,
+	})
+}
+
+// rejecting fails every turn before emitting anything, as a backend that
+// refuses the request does.
+type rejecting struct {
+	*harness.Mock
+	err error
+}
+
+func (r rejecting) StreamTurn(context.Context, *harness.Turn, func(harness.Event) error) error {
+	return r.err
+}
+
+func reject(name string, status int, msg string) rejecting {
+	return rejecting{
+		Mock: harness.NewMock(harness.MockConfig{HarnessName: name}),
+		err:  &harness.StatusError{StatusCode: status, Err: errors.New(msg)},
+	}
+}
+
+func succeeding(name, text string) *harness.Mock {
+	return harness.NewMock(harness.MockConfig{
+		HarnessName: name,
+		Responses:   [][]harness.Event{{harness.NewTextEvent(text), harness.NewUsageEvent(3, 2)}},
+		Models:      []harness.ModelInfo{{ID: name + "-model"}, {ID: "shared"}},
+	})
+}
+
+func TestChainFallsBackOnFailure(t *testing.T) {
+	primary := reject("primary", http.StatusServiceUnavailable, "request failed with status 503: overloaded")
+	secondary := succeeding("secondary", "from secondary")
+	chain := New(primary, secondary)
+
+	var texts []string
+	err := chain.StreamTurn(context.Background(), &harness.Turn{}, func(ev harness.Event) error {
+		if ev.Kind == harness.EventText {
+			texts = append(texts, ev.Text.Delta)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamTurn: %v", err)
+	}
+	if len(texts) != 1 || texts[0] != "from secondary" {
+		t.Fatalf("expected only the secondary's events, got %v", texts)
+	}
+
+	result, err := New(reject("p", http.StatusBadGateway, "boom"), succeeding("s", "ok")).StreamAndCollect(context.Background(), &harness.Turn{})
+	if err != nil || result.FinalText != "ok" || result.Usage == nil {
+		t.Fatalf("StreamAndCollect: %+v, %v", result, err)
+	}
+}
+
+func TestChainDoesNotFallBackAfterOutput(t *testing.T) {
+	secondary := succeeding("secondary", "unused")
+	chain := New(failing("primary", errors.New("connection reset")), secondary)
+	var texts []string
+	err := chain.StreamTurn(context.Background(), &harness.Turn{}, func(ev harness.Event) error {
+		if ev.Kind == harness.EventText {
+			texts = append(texts, ev.Text.Delta)
+		}
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("expected the primary's error, got %v", err)
+	}
+	if len(texts) != 1 || texts[0] != "partial" {
+		t.Fatalf("expected the primary's partial output only, got %v", texts)
+	}
+	if _, err := secondary.StreamAndCollect(context.Background(), &harness.Turn{}); err != nil {
+		t.Fatalf("secondary should not have been called: %v", err)
+	}
+}
+
+func TestChainDoesNotFallBackOnBadRequest(t *testing.T) {
+	secondary := succeeding("secondary", "unused")
+	chain := New(reject("primary", http.StatusBadRequest, "request failed with status 400: bad schema"), secondary)
+	err := chain.StreamTurn(context.Background(), &harness.Turn{}, func(harness.Event) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "status 400") {
+		t.Fatalf("expected the 400 to be returned, got %v", err)
+	}
+	if _, err := secondary.StreamAndCollect(context.Background(), &harness.Turn{}); err != nil {
+		t.Fatalf("secondary should not have been called: %v", err)
+	}
+}
+
+func TestChainDoesNotFallBackOnCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	chain := New(succeeding("primary", "x"), succeeding("secondary", "y"))
+	err := chain.StreamTurn(ctx, &harness.Turn{}, func(harness.Event) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestChainAllFail(t *testing.T) {
+	chain := New(reject("a", http.StatusBadGateway, "down"), reject("b", http.StatusServiceUnavailable, "also down"))
+	err := chain.StreamTurn(context.Background(), &harness.Turn{}, func(harness.Event) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "all 2 backends failed") ||
+		!strings.Contains(err.Error(), "a: down") || !strings.Contains(err.Error(), "b: also down") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestChainNameAndModels(t *testing.T) {
+	chain := New(succeeding("codex", ""), succeeding("claude", ""))
+	if got := chain.Name(); got != "fallback(codex,claude)" {
+		t.Fatalf("Name = %q", got)
+	}
+	models, err := chain.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range models {
+		ids = append(ids, m.ID)
+	}
+	if got := strings.Join(ids, ","); got != "codex-model,shared,claude-model" {
+		t.Fatalf("ListModels = %s", got)
+	}
+}
+
+// Chain must satisfy harness.Harness so it can be registered with the router.
+var _ harness.Harness = (*Chain)(nil)
@@ -6,9 +6,36 @@ package harness
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"time"
 )
 
+// ErrPartialResponse is wrapped by StreamTurn errors when the stream broke
+// off before the provider finished the response. The events emitted before
+// the break (text deltas, complete tool calls) are all the caller gets.
+var ErrPartialResponse = errors.New("partial response")
+
+// StatusError is a provider error that came with an HTTP status, so callers
+// can act on the status without parsing the message.
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string { return e.Err.Error() }
+
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// HTTPStatus returns the status of the StatusError in err's chain, or 0.
+func HTTPStatus(err error) int {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode
+	}
+	return 0
+}
+
 // Harness is the core interface that all provider harnesses implement.
 // It handles the full agentic loop: prompt injection, streaming, tool
 // execution, and structured event emission.
@@ -28,6 +55,20 @@ type Harness interface {
 	// follow-up → ... until the model produces a final response or max steps.
 	RunToolLoop(ctx context.Context, turn *Turn, handler ToolHandler, opts LoopOptions) (*TurnResult, error)
 
+	// BatchTurns executes independent turns concurrently, at most
+	// opts.MaxConcurrency at once, and calls onResult once per turn with its
+	// index in turns. Individual failures are passed to onResult; the
+	// returned error is non-nil only if the batch itself was cut short.
+	BatchTurns(ctx context.Context, turns []*Turn, onResult func(index int, result *TurnResult, err error), opts LoopOptions) error
+
+	// Capabilities reports what the model named by WithModel(ctx) supports,
+	// or the best this harness offers across its models if ctx names none.
+	Capabilities(ctx context.Context) (CapabilitySet, error)
+
+	// CountTokens estimates the prompt tokens turn would use, without
+	// running it. How exact the count is depends on the provider.
+	CountTokens(ctx context.Context, turn *Turn) (int, error)
+
 	// ListModels returns available models for this harness.
 	ListModels(ctx context.Context) ([]ModelInfo, error)
 
@@ -45,6 +86,9 @@ type Message struct {
 	Content string `json:"content"` // Text content
 	Name    string `json:"name,omitempty"`
 	ToolID  string `json:"tool_id,omitempty"` // For tool result messages
+	// ContentBlocks, when set, replaces Content with text and image parts.
+	// Harnesses without image support fall back to Content.
+	ContentBlocks []ContentBlock `json:"content_blocks,omitempty"`
 }
 
 // ToolSpec describes a tool available to the model.
@@ -94,7 +138,38 @@ type Turn struct {
 	Permissions  *PermissionsCtx   `json:"permissions,omitempty"`
 	Reasoning    *ReasoningConfig  `json:"reasoning,omitempty"`
 	UserContext  *UserContext       `json:"user_context,omitempty"`
-	Metadata     map[string]any    `json:"metadata,omitempty"`
+	// ResponseSchema asks for JSON output matching this JSON Schema, using
+	// the provider's structured output mode where it has one.
+	ResponseSchema *json.RawMessage `json:"response_schema,omitempty"`
+	// ResponseSchemaName names ResponseSchema in the openai harness's
+	// response_format; empty means "output".
+	ResponseSchemaName string `json:"response_schema_name,omitempty"`
+	// Sampling overrides; nil keeps the provider default. See ValidateSampling.
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"top_p,omitempty"`
+	PresencePenalty *float64 `json:"presence_penalty,omitempty"`
+	// Seed asks for deterministic sampling where the provider supports it.
+	// Only the openai harness forwards it; codex and claude ignore it.
+	Seed *int64 `json:"seed,omitempty"`
+	// StopSequences end generation when the model emits one of them; at
+	// most MaxStopSequences.
+	StopSequences []string `json:"stop_sequences,omitempty"`
+	// MaxOutputTokens caps the tokens of the reply; 0 keeps the provider
+	// default. The codex harness ignores it.
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+	// PromptCacheKey identifies the conversation to the provider's prompt
+	// cache; reuse it across turns of one conversation. Codex sends it as
+	// prompt_cache_key.
+	PromptCacheKey string `json:"prompt_cache_key,omitempty"`
+	// Store asks the provider to keep the response for later retrieval;
+	// TurnResult.ResponseID then names it. Metadata tags the stored
+	// response. Only the openai harness sends them.
+	Store    bool              `json:"store,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// LogProbs asks for each output token's log probability and its
+	// LogProbs most likely alternatives, reported as EventLogProbs; at most
+	// MaxLogProbs. Only the openai harness supports it.
+	LogProbs int `json:"logprobs,omitempty"`
 }
 
 // TurnResult is the collected output of a completed turn.
@@ -109,6 +184,15 @@ type TurnResult struct {
 	Duration time.Duration `json:"duration"`
 	// ToolCalls contains all tool calls made during this turn.
 	ToolCalls []ToolCallEvent `json:"tool_calls,omitempty"`
+	// ParsedOutput is FinalText decoded as JSON when the turn set a
+	// ResponseSchema and the reply satisfied it.
+	ParsedOutput interface{} `json:"parsed_output,omitempty"`
+	// SystemFingerprint identifies the backend configuration that served the
+	// turn, when the provider reports one (OpenAI-compatible backends). A
+	// change means a seeded turn may no longer reproduce.
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+	// ResponseID identifies the response stored by a Turn with Store set.
+	ResponseID string `json:"response_id,omitempty"`
 }
 
 // ToolHandler executes tool calls on behalf of the harness.
@@ -127,6 +211,17 @@ type LoopOptions struct {
 	MaxTokens int `json:"max_tokens,omitempty"`
 	// OnEvent is called for each event during the loop.
 	OnEvent func(Event) error `json:"-"`
+	// ParallelToolCalls runs a turn's tool calls concurrently. Handler
+	// errors then become failed tool results instead of ending the loop.
+	ParallelToolCalls bool `json:"parallel_tool_calls,omitempty"`
+	// MaxParallelToolCalls caps concurrent tool calls (0 = 5).
+	MaxParallelToolCalls int `json:"max_parallel_tool_calls,omitempty"`
+	// MaxConcurrency caps the turns BatchTurns runs at once
+	// (0 = DefaultBatchConcurrency).
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+	// MaxDuration limits the loop's wall-clock time (0 = no limit). When it
+	// is reached the loop stops with ErrLoopTimeout.
+	MaxDuration time.Duration `json:"max_duration,omitempty"`
 }
 
 // ModelInfo describes an available model.
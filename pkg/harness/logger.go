@@ -58,6 +58,14 @@ func (l *loggerHarness) ListModels(ctx context.Context) ([]ModelInfo, error) {
 func (l *loggerHarness) ExpandAlias(alias string) string { return l.inner.ExpandAlias(alias) }
 func (l *loggerHarness) MatchesModel(model string) bool  { return l.inner.MatchesModel(model) }
 
+func (l *loggerHarness) Capabilities(ctx context.Context) (CapabilitySet, error) {
+	return l.inner.Capabilities(ctx)
+}
+
+func (l *loggerHarness) CountTokens(ctx context.Context, turn *Turn) (int, error) {
+	return l.inner.CountTokens(ctx, turn)
+}
+
 func (l *loggerHarness) StreamTurn(ctx context.Context, turn *Turn, onEvent func(Event) error) error {
 	seq := l.turnSeq.Add(1)
 	w, err := l.openLog(seq)
@@ -135,6 +143,11 @@ func (l *loggerHarness) StreamAndCollect(ctx context.Context, turn *Turn) (*Turn
 			}
 		case EventUsage:
 			result.Usage = ev.Usage
+		case EventDone:
+			if ev.Done != nil {
+				result.SystemFingerprint = ev.Done.SystemFingerprint
+				result.ResponseID = ev.Done.ResponseID
+			}
 		case EventToolCall:
 			if ev.ToolCall != nil {
 				result.ToolCalls = append(result.ToolCalls, *ev.ToolCall)
@@ -143,6 +156,9 @@ func (l *loggerHarness) StreamAndCollect(ctx context.Context, turn *Turn) (*Turn
 		return nil
 	})
 	result.Duration = time.Since(start)
+	if err == nil {
+		_ = ParseStructuredOutput(turn, result)
+	}
 	return result, err
 }
 
@@ -150,6 +166,10 @@ func (l *loggerHarness) RunToolLoop(ctx context.Context, turn *Turn, handler Too
 	return l.inner.RunToolLoop(ctx, turn, handler, opts)
 }
 
+func (l *loggerHarness) BatchTurns(ctx context.Context, turns []*Turn, onResult func(index int, result *TurnResult, err error), opts LoopOptions) error {
+	return RunBatch(ctx, l.StreamAndCollect, turns, onResult, opts)
+}
+
 func (l *loggerHarness) openLog(seq int64) (*os.File, error) {
 	if err := os.MkdirAll(l.cfg.Dir, 0o755); err != nil {
 		return nil, err
@@ -31,6 +31,9 @@ type MockConfig struct {
 
 	// Models is the list returned by ListModels.
 	Models []ModelInfo
+
+	// Capabilities is the set returned by Capabilities.
+	Capabilities CapabilitySet
 }
 
 // Mock implements Harness with scripted responses for deterministic testing
@@ -109,6 +112,11 @@ func (m *Mock) StreamAndCollect(ctx context.Context, turn *Turn) (*TurnResult, e
 			}
 		case EventUsage:
 			result.Usage = ev.Usage
+		case EventDone:
+			if ev.Done != nil {
+				result.SystemFingerprint = ev.Done.SystemFingerprint
+				result.ResponseID = ev.Done.ResponseID
+			}
 		case EventToolCall:
 			if ev.ToolCall != nil {
 				result.ToolCalls = append(result.ToolCalls, *ev.ToolCall)
@@ -117,6 +125,10 @@ func (m *Mock) StreamAndCollect(ctx context.Context, turn *Turn) (*TurnResult, e
 		return nil
 	})
 	result.Duration = time.Since(start)
+	if err == nil {
+		// A reply that misses the schema leaves ParsedOutput nil.
+		_ = ParseStructuredOutput(turn, result)
+	}
 	return result, err
 }
 
@@ -129,10 +141,12 @@ func (m *Mock) RunToolLoop(ctx context.Context, turn *Turn, handler ToolHandler,
 	if maxTurns <= 0 {
 		maxTurns = 10 // safety limit
 	}
+	loopCtx, cancel := withLoopDeadline(ctx, opts)
+	defer cancel()
 
 	for i := 0; i < maxTurns; i++ {
 		var pendingCalls []ToolCallEvent
-		err := m.StreamTurn(ctx, turn, func(ev Event) error {
+		err := m.StreamTurn(loopCtx, turn, func(ev Event) error {
 			combined.Events = append(combined.Events, ev)
 			if opts.OnEvent != nil {
 				if err := opts.OnEvent(ev); err != nil {
@@ -149,6 +163,11 @@ func (m *Mock) RunToolLoop(ctx context.Context, turn *Turn, handler ToolHandler,
 				}
 			case EventUsage:
 				combined.Usage = ev.Usage
+			case EventDone:
+				if ev.Done != nil {
+					combined.SystemFingerprint = ev.Done.SystemFingerprint
+					combined.ResponseID = ev.Done.ResponseID
+				}
 			case EventToolCall:
 				if ev.ToolCall != nil {
 					pendingCalls = append(pendingCalls, *ev.ToolCall)
@@ -159,7 +178,7 @@ func (m *Mock) RunToolLoop(ctx context.Context, turn *Turn, handler ToolHandler,
 		})
 		if err != nil {
 			combined.Duration = time.Since(start)
-			return combined, err
+			return combined, loopError(ctx, loopCtx, err, opts)
 		}
 
 		if len(pendingCalls) == 0 {
@@ -168,10 +187,10 @@ func (m *Mock) RunToolLoop(ctx context.Context, turn *Turn, handler ToolHandler,
 
 		// Execute tool calls
 		for _, call := range pendingCalls {
-			result, err := handler.Handle(ctx, call)
+			result, err := handler.Handle(loopCtx, call)
 			if err != nil {
 				combined.Duration = time.Since(start)
-				return combined, err
+				return combined, loopError(ctx, loopCtx, err, opts)
 			}
 			if result != nil {
 				ev := NewToolResultEvent(result.CallID, result.Output, result.IsError)
@@ -181,9 +200,26 @@ func (m *Mock) RunToolLoop(ctx context.Context, turn *Turn, handler ToolHandler,
 	}
 
 	combined.Duration = time.Since(start)
+	_ = ParseStructuredOutput(turn, combined)
 	return combined, nil
 }
 
+// BatchTurns runs turns concurrently; scripted responses are handed out in
+// call order, not turn order.
+func (m *Mock) BatchTurns(ctx context.Context, turns []*Turn, onResult func(index int, result *TurnResult, err error), opts LoopOptions) error {
+	return RunBatch(ctx, m.StreamAndCollect, turns, onResult, opts)
+}
+
+// Capabilities returns the configured capability set.
+func (m *Mock) Capabilities(_ context.Context) (CapabilitySet, error) {
+	return m.cfg.Capabilities, nil
+}
+
+// CountTokens returns EstimateTurnTokens(turn).
+func (m *Mock) CountTokens(_ context.Context, turn *Turn) (int, error) {
+	return EstimateTurnTokens(turn), nil
+}
+
 // ListModels returns the configured mock models.
 func (m *Mock) ListModels(_ context.Context) ([]ModelInfo, error) {
 	return m.cfg.Models, nil
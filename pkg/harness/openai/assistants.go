@@ -0,0 +1,322 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"godex/pkg/harness"
+	"godex/pkg/sse"
+)
+
+// assistantsBeta opts requests into the Assistants v2 API.
+const assistantsBeta = "assistants=v2"
+
+// ---------------------------------------------------------------------------
+// Assistants v2 wire format
+// ---------------------------------------------------------------------------
+
+type threadMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// assistantsContent is one part of a thread message or message delta.
+type assistantsContent struct {
+	Type string `json:"type"`
+	Text *struct {
+		Value string `json:"value"`
+	} `json:"text,omitempty"`
+}
+
+type assistantsRun struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	RequiredAction *struct {
+		SubmitToolOutputs struct {
+			ToolCalls []chatToolCall `json:"tool_calls"`
+		} `json:"submit_tool_outputs"`
+	} `json:"required_action,omitempty"`
+	LastError *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"last_error,omitempty"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage,omitempty"`
+}
+
+// assistantsStreamEvent is the data of one streamed run event. Object tells
+// the kinds apart: "thread.message.delta" carries Delta, "thread.run"
+// carries the run fields, and error events have none.
+type assistantsStreamEvent struct {
+	Object string `json:"object"`
+	Delta  struct {
+		Content []assistantsContent `json:"content"`
+	} `json:"delta"`
+	// Message is set on error events.
+	Message string `json:"message"`
+	assistantsRun
+}
+
+// ---------------------------------------------------------------------------
+// Threads and runs
+// ---------------------------------------------------------------------------
+
+// CreateThread starts an Assistants thread holding messages and returns its
+// ID. Threads take only user and assistant messages; put system prompts in
+// the assistant's instructions.
+func (c *Client) CreateThread(ctx context.Context, messages []harness.Message) (string, error) {
+	body := struct {
+		Messages []threadMessage `json:"messages,omitempty"`
+	}{}
+	for _, msg := range messages {
+		tm, err := toThreadMessage(msg)
+		if err != nil {
+			return "", err
+		}
+		body.Messages = append(body.Messages, tm)
+	}
+	var thread struct {
+		ID string `json:"id"`
+	}
+	if err := c.assistantsCall(ctx, "/threads", body, &thread); err != nil {
+		return "", fmt.Errorf("create thread: %w", err)
+	}
+	return thread.ID, nil
+}
+
+// AddMessage appends msg to a thread.
+func (c *Client) AddMessage(ctx context.Context, threadID string, msg harness.Message) error {
+	tm, err := toThreadMessage(msg)
+	if err != nil {
+		return err
+	}
+	if err := c.assistantsCall(ctx, "/threads/"+url.PathEscape(threadID)+"/messages", tm, nil); err != nil {
+		return fmt.Errorf("add message: %w", err)
+	}
+	return nil
+}
+
+// CreateRun starts assistantID on a thread without streaming and returns
+// the run ID for PollRun.
+func (c *Client) CreateRun(ctx context.Context, threadID, assistantID string) (string, error) {
+	var run assistantsRun
+	body := map[string]any{"assistant_id": assistantID}
+	if err := c.assistantsCall(ctx, "/threads/"+url.PathEscape(threadID)+"/runs", body, &run); err != nil {
+		return "", fmt.Errorf("create run: %w", err)
+	}
+	return run.ID, nil
+}
+
+// RunThread runs assistantID on a thread and streams the reply as harness
+// events: text deltas, then tool calls if the run requires action, usage
+// when it completes, and a final EventDone. The tool calls are left pending
+// on the run.
+func (c *Client) RunThread(ctx context.Context, threadID, assistantID string, onEvent func(harness.Event) error) error {
+	payload, err := json.Marshal(map[string]any{"assistant_id": assistantID, "stream": true})
+	if err != nil {
+		return fmt.Errorf("encode run: %w", err)
+	}
+	resp, err := c.assistantsDo(ctx, "/threads/"+url.PathEscape(threadID)+"/runs", payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+		return fmt.Errorf("run thread failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	err = sse.ParseData(resp.Body, func(raw json.RawMessage) error {
+		var data assistantsStreamEvent
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil
+		}
+		switch data.Object {
+		case "thread.message.delta":
+			for _, part := range data.Delta.Content {
+				if part.Type == "text" && part.Text != nil && part.Text.Value != "" {
+					if err := onEvent(harness.NewTextEvent(part.Text.Value)); err != nil {
+						return err
+					}
+				}
+			}
+		case "thread.run":
+			return emitRunEvents(&data.assistantsRun, onEvent)
+		case "":
+			// An error event's data is the bare error object.
+			if data.Message != "" {
+				return onEvent(harness.NewErrorEvent(data.Message))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return onEvent(harness.NewDoneEvent())
+}
+
+// emitRunEvents reports a run that has stopped: its pending tool calls, its
+// usage or its error. Runs still in progress emit nothing.
+func emitRunEvents(run *assistantsRun, onEvent func(harness.Event) error) error {
+	switch run.Status {
+	case "requires_action":
+		for _, tc := range runToolCalls(run) {
+			if err := onEvent(harness.NewToolCallEvent(tc.CallID, tc.Name, tc.Arguments)); err != nil {
+				return err
+			}
+		}
+	case "completed", "incomplete":
+		if run.Usage != nil {
+			return onEvent(harness.NewUsageEvent(run.Usage.PromptTokens, run.Usage.CompletionTokens))
+		}
+	case "failed", "cancelled", "expired":
+		return onEvent(harness.NewErrorEvent(runError(run).Error()))
+	}
+	return nil
+}
+
+// PollRun waits until a run stops, checking every
+// ClientConfig.RunPollInterval, and returns its result. A run that requires
+// action returns its pending ToolCalls; one that completes returns the text
+// of the messages it added to the thread.
+func (c *Client) PollRun(ctx context.Context, threadID, runID string) (*harness.TurnResult, error) {
+	start := time.Now()
+	runPath := "/threads/" + url.PathEscape(threadID) + "/runs/" + url.PathEscape(runID)
+	for {
+		var run assistantsRun
+		if err := c.assistantsCall(ctx, runPath, nil, &run); err != nil {
+			return nil, fmt.Errorf("poll run: %w", err)
+		}
+		result := &harness.TurnResult{}
+		switch run.Status {
+		case "queued", "in_progress", "cancelling":
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.cfg.RunPollInterval):
+			}
+			continue
+		case "requires_action":
+			result.ToolCalls = runToolCalls(&run)
+		case "completed", "incomplete":
+			text, err := c.runText(ctx, threadID, runID)
+			if err != nil {
+				return nil, err
+			}
+			result.FinalText = text
+		default:
+			return nil, runError(&run)
+		}
+		if run.Usage != nil {
+			result.Usage = harness.NewUsageEvent(run.Usage.PromptTokens, run.Usage.CompletionTokens).Usage
+		}
+		result.Duration = time.Since(start)
+		return result, nil
+	}
+}
+
+// runText joins the text of the assistant messages a run added.
+func (c *Client) runText(ctx context.Context, threadID, runID string) (string, error) {
+	q := url.Values{"run_id": {runID}, "order": {"asc"}}
+	var list struct {
+		Data []struct {
+			Role    string              `json:"role"`
+			Content []assistantsContent `json:"content"`
+		} `json:"data"`
+	}
+	if err := c.assistantsCall(ctx, "/threads/"+url.PathEscape(threadID)+"/messages?"+q.Encode(), nil, &list); err != nil {
+		return "", fmt.Errorf("list run messages: %w", err)
+	}
+	var text strings.Builder
+	for _, msg := range list.Data {
+		if msg.Role != "assistant" {
+			continue
+		}
+		for _, part := range msg.Content {
+			if part.Type == "text" && part.Text != nil {
+				text.WriteString(part.Text.Value)
+			}
+		}
+	}
+	return text.String(), nil
+}
+
+func runToolCalls(run *assistantsRun) []harness.ToolCallEvent {
+	if run.RequiredAction == nil {
+		return nil
+	}
+	var out []harness.ToolCallEvent
+	for _, tc := range run.RequiredAction.SubmitToolOutputs.ToolCalls {
+		out = append(out, harness.ToolCallEvent{CallID: tc.ID, Name: tc.Function.Name, Arguments: tc.Function.Arguments})
+	}
+	return out
+}
+
+func runError(run *assistantsRun) error {
+	if run.LastError != nil && run.LastError.Message != "" {
+		return fmt.Errorf("run %s %s: %s: %s", run.ID, run.Status, run.LastError.Code, run.LastError.Message)
+	}
+	return fmt.Errorf("run %s %s", run.ID, run.Status)
+}
+
+func toThreadMessage(msg harness.Message) (threadMessage, error) {
+	switch msg.Role {
+	case "user", "assistant":
+		return threadMessage{Role: msg.Role, Content: msg.Content}, nil
+	default:
+		return threadMessage{}, fmt.Errorf("assistants: a thread cannot hold a %q message", msg.Role)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// HTTP plumbing
+// ---------------------------------------------------------------------------
+
+// assistantsCall POSTs body to path (or GETs it when body is nil) and decodes
+// the JSON reply into out, if given.
+func (c *Client) assistantsCall(ctx context.Context, path string, body, out any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("encode request: %w", err)
+		}
+	}
+	resp, err := c.assistantsDo(ctx, path, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return &harness.StatusError{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg))),
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) assistantsDo(ctx context.Context, path string, payload []byte) (*http.Response, error) {
+	req, err := c.newRequest(ctx, path, payload)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("OpenAI-Beta", assistantsBeta)
+	return c.httpClient.Do(req)
+}
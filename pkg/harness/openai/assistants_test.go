@@ -0,0 +1,156 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"godex/pkg/config"
+	"godex/pkg/harness"
+)
+
+// assistantsServer mocks the Assistants v2 endpoints for thread "t1".
+func assistantsServer(t *testing.T, runPolls *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("OpenAI-Beta") != "assistants=v2" || r.Header.Get("Authorization") != "Bearer sk-test" {
+			t.Errorf("%s %s: missing beta or auth header", r.Method, r.URL.Path)
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/threads":
+			msgs, _ := body["messages"].([]any)
+			if len(msgs) != 2 {
+				t.Errorf("expected 2 thread messages, got %v", body)
+			}
+			w.Write([]byte(`{"id":"t1","object":"thread"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/threads/t1/messages":
+			if body["role"] != "user" || body["content"] != "and now?" {
+				t.Errorf("unexpected message %v", body)
+			}
+			w.Write([]byte(`{"id":"m3","object":"thread.message"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/threads/t1/runs":
+			if body["assistant_id"] != "asst_1" {
+				t.Errorf("unexpected run %v", body)
+			}
+			if body["stream"] != true {
+				w.Write([]byte(`{"id":"r1","object":"thread.run","status":"queued"}`))
+				return
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, ev := range []string{
+				"event: thread.run.created\ndata: {\"id\":\"r2\",\"object\":\"thread.run\",\"status\":\"queued\"}\n\n",
+				"event: thread.message.delta\ndata: {\"id\":\"m4\",\"object\":\"thread.message.delta\",\"delta\":{\"content\":[{\"index\":0,\"type\":\"text\",\"text\":{\"value\":\"Hel\"}}]}}\n\n",
+				"event: thread.message.delta\ndata: {\"id\":\"m4\",\"object\":\"thread.message.delta\",\"delta\":{\"content\":[{\"index\":0,\"type\":\"text\",\"text\":{\"value\":\"lo\"}}]}}\n\n",
+				"event: thread.run.completed\ndata: {\"id\":\"r2\",\"object\":\"thread.run\",\"status\":\"completed\",\"usage\":{\"prompt_tokens\":9,\"completion_tokens\":2}}\n\n",
+				"event: done\ndata: [DONE]\n\n",
+			} {
+				w.Write([]byte(ev))
+			}
+		case r.Method == http.MethodGet && r.URL.Path == "/threads/t1/runs/r1":
+			*runPolls++
+			if *runPolls == 1 {
+				w.Write([]byte(`{"id":"r1","object":"thread.run","status":"in_progress"}`))
+				return
+			}
+			w.Write([]byte(`{"id":"r1","object":"thread.run","status":"completed","usage":{"prompt_tokens":7,"completion_tokens":3}}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/threads/t1/messages":
+			if r.URL.Query().Get("run_id") != "r1" {
+				t.Errorf("unexpected message query %q", r.URL.RawQuery)
+			}
+			w.Write([]byte(`{"object":"list","data":[{"id":"m5","role":"assistant","content":[{"type":"text","text":{"value":"Forty-two."}}]}]}`))
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestAssistantsThreadAndRuns(t *testing.T) {
+	polls := 0
+	srv := assistantsServer(t, &polls)
+	defer srv.Close()
+	c, _ := NewClient(ClientConfig{
+		BaseURL:         srv.URL,
+		Auth:            config.BackendAuthConfig{Type: "api_key", Key: "sk-test"},
+		RunPollInterval: time.Millisecond,
+	})
+	ctx := context.Background()
+
+	threadID, err := c.CreateThread(ctx, []harness.Message{
+		{Role: "user", Content: "question"},
+		{Role: "assistant", Content: "answer"},
+	})
+	if err != nil || threadID != "t1" {
+		t.Fatalf("CreateThread = %q, %v", threadID, err)
+	}
+	if err := c.AddMessage(ctx, threadID, harness.Message{Role: "user", Content: "and now?"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateThread(ctx, []harness.Message{{Role: "tool", Content: "x"}}); err == nil {
+		t.Error("expected tool messages to be rejected")
+	}
+
+	var text strings.Builder
+	var kinds []harness.EventKind
+	err = c.RunThread(ctx, threadID, "asst_1", func(ev harness.Event) error {
+		kinds = append(kinds, ev.Kind)
+		if ev.Kind == harness.EventText {
+			text.WriteString(ev.Text.Delta)
+		}
+		if ev.Kind == harness.EventUsage && (ev.Usage.InputTokens != 9 || ev.Usage.OutputTokens != 2) {
+			t.Errorf("unexpected usage %+v", ev.Usage)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []harness.EventKind{harness.EventText, harness.EventText, harness.EventUsage, harness.EventDone}
+	if text.String() != "Hello" || len(kinds) != len(want) {
+		t.Fatalf("got text %q, events %v", text.String(), kinds)
+	}
+	for i := range want {
+		if kinds[i] != want[i] {
+			t.Errorf("event %d = %s, want %s", i, kinds[i], want[i])
+		}
+	}
+
+	runID, err := c.CreateRun(ctx, threadID, "asst_1")
+	if err != nil || runID != "r1" {
+		t.Fatalf("CreateRun = %q, %v", runID, err)
+	}
+	result, err := c.PollRun(ctx, threadID, runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if polls != 2 || result.FinalText != "Forty-two." || result.Usage == nil || result.Usage.TotalTokens != 10 {
+		t.Errorf("PollRun after %d polls = %+v", polls, result)
+	}
+}
+
+func TestEmitRunEvents_RequiresActionAndFailure(t *testing.T) {
+	var run assistantsRun
+	_ = json.Unmarshal([]byte(`{"id":"r1","status":"requires_action","required_action":{"type":"submit_tool_outputs",
+		"submit_tool_outputs":{"tool_calls":[{"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":1}"}}]}}}`), &run)
+	var events []harness.Event
+	collect := func(ev harness.Event) error { events = append(events, ev); return nil }
+	if err := emitRunEvents(&run, collect); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ToolCall == nil || events[0].ToolCall.CallID != "call_1" || events[0].ToolCall.Arguments != `{"q":1}` {
+		t.Fatalf("unexpected tool call events %+v", events)
+	}
+
+	events = nil
+	_ = json.Unmarshal([]byte(`{"id":"r1","status":"failed","last_error":{"code":"rate_limit_exceeded","message":"slow down"}}`), &run)
+	_ = emitRunEvents(&run, collect)
+	if len(events) != 1 || events[0].Kind != harness.EventError || !strings.Contains(events[0].Error.Message, "slow down") {
+		t.Fatalf("unexpected failure events %+v", events)
+	}
+}
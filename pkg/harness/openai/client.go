@@ -27,6 +27,9 @@ type ClientConfig struct {
 	Timeout   time.Duration
 	Discovery bool
 	Models    []config.BackendModelDef
+	// RunPollInterval is how often PollRun checks an Assistants run
+	// (default 1s).
+	RunPollInterval time.Duration
 }
 
 // Client implements the OpenAI-compatible API client.
@@ -44,6 +47,9 @@ func NewClient(cfg ClientConfig) (*Client, error) {
 	if cfg.Timeout == 0 {
 		cfg.Timeout = defaultTimeout
 	}
+	if cfg.RunPollInterval == 0 {
+		cfg.RunPollInterval = time.Second
+	}
 	c := &Client{
 		httpClient: &http.Client{Timeout: cfg.Timeout},
 		cfg:        cfg,
@@ -78,10 +84,40 @@ func (c *Client) Name() string { return c.cfg.Name }
 // ---------------------------------------------------------------------------
 
 type chatRequest struct {
-	Model    string        `json:"model"`
-	Messages []chatMessage `json:"messages"`
-	Tools    []chatTool    `json:"tools,omitempty"`
-	Stream   bool          `json:"stream"`
+	Model           string              `json:"model"`
+	Messages        []chatMessage       `json:"messages"`
+	Tools           []chatTool          `json:"tools,omitempty"`
+	ResponseFormat  *chatResponseFormat `json:"response_format,omitempty"`
+	Temperature     *float64            `json:"temperature,omitempty"`
+	TopP            *float64            `json:"top_p,omitempty"`
+	PresencePenalty *float64            `json:"presence_penalty,omitempty"`
+	Seed            *int64              `json:"seed,omitempty"`
+	Stop            []string            `json:"stop,omitempty"`
+	MaxTokens       int                 `json:"max_tokens,omitempty"`
+	ReasoningEffort string              `json:"reasoning_effort,omitempty"`
+	Logprobs        bool                `json:"logprobs,omitempty"`
+	TopLogprobs     int                 `json:"top_logprobs,omitempty"`
+	Stream          bool                `json:"stream"`
+	StreamOptions   *chatStreamOptions  `json:"stream_options,omitempty"`
+	Store           bool                `json:"store,omitempty"`
+	Metadata        map[string]string   `json:"metadata,omitempty"`
+}
+
+// chatStreamOptions asks for a final usage-only chunk (empty choices) after
+// the finishing chunk; streams report no usage without it.
+type chatStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatResponseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema *chatJSONSchema `json:"json_schema,omitempty"`
+}
+
+type chatJSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict,omitempty"`
 }
 
 type chatMessage struct {
@@ -115,15 +151,17 @@ type chatFunctionCall struct {
 }
 
 type chatChunk struct {
-	ID      string `json:"id"`
-	Choices []struct {
+	ID                string `json:"id"`
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+	Choices           []struct {
 		Index int `json:"index"`
 		Delta struct {
 			Role      string         `json:"role,omitempty"`
 			Content   string         `json:"content,omitempty"`
 			ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
 		} `json:"delta"`
-		FinishReason *string `json:"finish_reason,omitempty"`
+		Logprobs     *chatLogprobs `json:"logprobs,omitempty"`
+		FinishReason *string       `json:"finish_reason,omitempty"`
 	} `json:"choices"`
 	Usage *struct {
 		PromptTokens     int `json:"prompt_tokens"`
@@ -132,14 +170,34 @@ type chatChunk struct {
 	} `json:"usage,omitempty"`
 }
 
+type chatLogprobs struct {
+	Content []protocol.LogProb `json:"content"`
+}
+
 // ---------------------------------------------------------------------------
 // Request translation
 // ---------------------------------------------------------------------------
 
 func (c *Client) buildChatRequest(req protocol.ResponsesRequest) chatRequest {
 	cr := chatRequest{
-		Model:  req.Model,
-		Stream: true,
+		Model:           req.Model,
+		Temperature:     req.Temperature,
+		TopP:            req.TopP,
+		PresencePenalty: req.PresencePenalty,
+		Seed:            req.Seed,
+		Stop:            req.StopSequences,
+		MaxTokens:       req.MaxOutputTokens,
+		Stream:          true,
+		StreamOptions:   &chatStreamOptions{IncludeUsage: true},
+		Store:           req.Store,
+		Metadata:        req.Metadata,
+	}
+	if req.Reasoning != nil {
+		cr.ReasoningEffort = req.Reasoning.Effort
+	}
+	if req.TopLogprobs > 0 {
+		cr.Logprobs = true
+		cr.TopLogprobs = req.TopLogprobs
 	}
 
 	if req.Instructions != "" {
@@ -194,6 +252,17 @@ func (c *Client) buildChatRequest(req protocol.ResponsesRequest) chatRequest {
 		}
 	}
 
+	if req.Text != nil && req.Text.Format != nil && req.Text.Format.Type == "json_schema" {
+		cr.ResponseFormat = &chatResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &chatJSONSchema{
+				Name:   req.Text.Format.Name,
+				Schema: req.Text.Format.Schema,
+				Strict: req.Text.Format.Strict,
+			},
+		}
+	}
+
 	return cr
 }
 
@@ -221,7 +290,10 @@ func (c *Client) StreamResponses(ctx context.Context, req protocol.ResponsesRequ
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
-		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		return &harness.StatusError{
+			StatusCode: resp.StatusCode,
+			Err:        fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body))),
+		}
 	}
 
 	type toolState struct {
@@ -231,22 +303,34 @@ func (c *Client) StreamResponses(ctx context.Context, req protocol.ResponsesRequ
 	}
 	calls := map[int]*toolState{}
 	textStarted := false
+	fingerprint := ""
+	// The finishing chunk's response.completed waits for the usage-only
+	// chunk that follows it, so that one event carries both.
+	var pending *protocol.ResponseRef
 
-	return sse.ParseStream(resp.Body, func(ev sse.Event) error {
+	err = sse.ParseStream(resp.Body, func(ev sse.Event) error {
 		var chunk chatChunk
 		if err := json.Unmarshal(ev.Raw, &chunk); err != nil {
 			return nil
 		}
+		if chunk.SystemFingerprint != "" {
+			fingerprint = chunk.SystemFingerprint
+		}
 		if len(chunk.Choices) == 0 {
 			if chunk.Usage != nil {
+				ref := pending
+				pending = nil
+				if ref == nil {
+					ref = &protocol.ResponseRef{ID: chunk.ID}
+				}
+				ref.Usage = &protocol.Usage{
+					InputTokens:  chunk.Usage.PromptTokens,
+					OutputTokens: chunk.Usage.CompletionTokens,
+				}
+				ref.SystemFingerprint = fingerprint
 				return onEvent(codexEvent("response.completed", &protocol.StreamEvent{
-					Type: "response.completed",
-					Response: &protocol.ResponseRef{
-						Usage: &protocol.Usage{
-							InputTokens:  chunk.Usage.PromptTokens,
-							OutputTokens: chunk.Usage.CompletionTokens,
-						},
-					},
+					Type:     "response.completed",
+					Response: ref,
 				}))
 			}
 			return nil
@@ -264,10 +348,14 @@ func (c *Client) StreamResponses(ctx context.Context, req protocol.ResponsesRequ
 					return err
 				}
 			}
-			if err := onEvent(codexEvent("response.output_text.delta", &protocol.StreamEvent{
+			delta := &protocol.StreamEvent{
 				Type:  "response.output_text.delta",
 				Delta: choice.Delta.Content,
-			})); err != nil {
+			}
+			if choice.Logprobs != nil {
+				delta.Logprobs = choice.Logprobs.Content
+			}
+			if err := onEvent(codexEvent("response.output_text.delta", delta)); err != nil {
 				return err
 			}
 		}
@@ -343,16 +431,60 @@ func (c *Client) StreamResponses(ctx context.Context, req protocol.ResponsesRequ
 					OutputTokens: chunk.Usage.CompletionTokens,
 				}
 			}
+			ref := &protocol.ResponseRef{
+				ID:                chunk.ID,
+				Usage:             usage,
+				SystemFingerprint: fingerprint,
+			}
+			if *choice.FinishReason == "stop" && len(chatReq.Stop) > 0 {
+				if seq, ok := matchedStop(ev.Raw); ok {
+					ref.StopReason = "stop_sequence"
+					ref.StopSequence = seq
+				}
+			}
+			if usage == nil {
+				pending = ref
+				return nil
+			}
 			return onEvent(codexEvent("response.completed", &protocol.StreamEvent{
-				Type: "response.completed",
-				Response: &protocol.ResponseRef{
-					Usage: usage,
-				},
+				Type:     "response.completed",
+				Response: ref,
 			}))
 		}
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	// Servers that ignore stream_options never send the usage chunk.
+	if pending != nil {
+		return onEvent(codexEvent("response.completed", &protocol.StreamEvent{
+			Type:     "response.completed",
+			Response: pending,
+		}))
+	}
+	return nil
+}
+
+// matchedStop returns the stop sequence the first choice of a finishing
+// chunk ended on. OpenAI reports only finish_reason "stop", which also covers
+// a natural end, so this relies on servers such as vLLM that add the matched
+// string as choices[].stop_reason (a token id there is not a match).
+func matchedStop(raw json.RawMessage) (string, bool) {
+	var chunk struct {
+		Choices []struct {
+			StopReason json.RawMessage `json:"stop_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(raw, &chunk); err != nil || len(chunk.Choices) == 0 {
+		return "", false
+	}
+	var seq string
+	if err := json.Unmarshal(chunk.Choices[0].StopReason, &seq); err != nil || seq == "" {
+		return "", false
+	}
+	return seq, true
 }
 
 func codexEvent(eventType string, se *protocol.StreamEvent) sse.Event {
@@ -464,6 +596,16 @@ func (c *Client) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
 // ---------------------------------------------------------------------------
 
 func (c *Client) doRequest(ctx context.Context, path string, body []byte) (*http.Response, error) {
+	req, err := c.newRequest(ctx, path, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.httpClient.Do(req)
+}
+
+// newRequest builds an authenticated request to path: a POST of body, or a
+// GET when body is nil.
+func (c *Client) newRequest(ctx context.Context, path string, body []byte) (*http.Request, error) {
 	url := strings.TrimSuffix(c.cfg.BaseURL, "/") + path
 
 	var reqBody io.Reader
@@ -482,9 +624,11 @@ func (c *Client) doRequest(ctx context.Context, path string, body []byte) (*http
 		req.Header.Set("Content-Type", "application/json")
 	}
 	req.Header.Set("Accept", "text/event-stream")
+	if id, ok := harness.RequestID(ctx); ok {
+		req.Header.Set("X-Request-ID", id)
+	}
 	c.applyAuth(ctx, req)
-
-	return c.httpClient.Do(req)
+	return req, nil
 }
 
 func (c *Client) applyAuth(ctx context.Context, req *http.Request) {
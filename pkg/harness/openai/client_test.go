@@ -222,7 +222,8 @@ func TestStreamResponses_TextStream(t *testing.T) {
 				Content   string         `json:"content,omitempty"`
 				ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
 			} `json:"delta"`
-			FinishReason *string `json:"finish_reason,omitempty"`
+			Logprobs     *chatLogprobs `json:"logprobs,omitempty"`
+			FinishReason *string       `json:"finish_reason,omitempty"`
 		}{{Delta: struct {
 			Role      string         `json:"role,omitempty"`
 			Content   string         `json:"content,omitempty"`
@@ -239,7 +240,8 @@ func TestStreamResponses_TextStream(t *testing.T) {
 				Content   string         `json:"content,omitempty"`
 				ToolCalls []chatToolCall `json:"tool_calls,omitempty"`
 			} `json:"delta"`
-			FinishReason *string `json:"finish_reason,omitempty"`
+			Logprobs     *chatLogprobs `json:"logprobs,omitempty"`
+			FinishReason *string       `json:"finish_reason,omitempty"`
 		}{{FinishReason: &stop}}, Usage: &struct {
 			PromptTokens     int `json:"prompt_tokens"`
 			CompletionTokens int `json:"completion_tokens"`
@@ -406,6 +408,55 @@ func TestStreamResponses_UsageOnlyChunk(t *testing.T) {
 	}
 }
 
+func TestStreamResponses_SystemFingerprint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(sseChunk(`{"id":"1","system_fingerprint":"fp_abc","choices":[{"index":0,"delta":{"content":"hi"}}]}`)))
+		w.Write([]byte(sseChunk(`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)))
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(ClientConfig{BaseURL: srv.URL})
+	var fingerprint string
+	err := c.StreamResponses(context.Background(), protocol.ResponsesRequest{Model: "test"}, func(ev sse.Event) error {
+		if ev.Value.Type == "response.completed" {
+			fingerprint = ev.Value.Response.SystemFingerprint
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fingerprint != "fp_abc" {
+		t.Errorf("expected fingerprint fp_abc on completed event, got %q", fingerprint)
+	}
+}
+
+func TestStreamResponses_StopSequence(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(sseChunk(`{"id":"1","choices":[{"index":0,"delta":{"content":"def f():"}}]}`)))
+		w.Write([]byte(sseChunk(`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"stop","stop_reason":"\ndef "}]}`)))
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(ClientConfig{BaseURL: srv.URL})
+	var ref *protocol.ResponseRef
+	req := protocol.ResponsesRequest{Model: "test", StopSequences: []string{"\ndef "}}
+	err := c.StreamResponses(context.Background(), req, func(ev sse.Event) error {
+		if ev.Value.Type == "response.completed" {
+			ref = ev.Value.Response
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref == nil || ref.StopReason != "stop_sequence" || ref.StopSequence != "\ndef " {
+		t.Fatalf("expected stop_sequence match on completed event, got %+v", ref)
+	}
+}
+
 func TestCodexEvent(t *testing.T) {
 	se := &protocol.StreamEvent{Type: "test.event", Delta: "hello"}
 	ev := codexEvent("test.event", se)
@@ -416,3 +467,44 @@ func TestCodexEvent(t *testing.T) {
 		t.Error("expected non-empty raw")
 	}
 }
+
+func TestBuildChatRequest_ResponseFormat(t *testing.T) {
+	c, _ := NewClient(ClientConfig{BaseURL: "http://localhost"})
+	cr := c.buildChatRequest(protocol.ResponsesRequest{
+		Model: "gpt-4o",
+		Text: &protocol.TextControls{Format: &protocol.TextFormat{
+			Type:   "json_schema",
+			Name:   "response",
+			Schema: json.RawMessage(`{"type":"object"}`),
+		}},
+	})
+	raw, _ := json.Marshal(cr)
+	want := `"response_format":{"type":"json_schema","json_schema":{"name":"response","schema":{"type":"object"}}}`
+	if !strings.Contains(string(raw), want) {
+		t.Fatalf("expected %s in %s", want, raw)
+	}
+}
+
+func TestBuildChatRequest_Sampling(t *testing.T) {
+	c, _ := NewClient(ClientConfig{BaseURL: "http://localhost"})
+	temp, topP, presence := 0.7, 0.5, 1.0
+	seed := int64(42)
+	raw, _ := json.Marshal(c.buildChatRequest(protocol.ResponsesRequest{
+		Model:           "gpt-4o",
+		Temperature:     &temp,
+		TopP:            &topP,
+		PresencePenalty: &presence,
+		Seed:            &seed,
+		StopSequences:   []string{"END"},
+		MaxOutputTokens: 64,
+	}))
+	for _, want := range []string{`"temperature":0.7`, `"top_p":0.5`, `"presence_penalty":1`, `"seed":42`, `"stop":["END"]`, `"max_tokens":64`} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("expected %s in %s", want, raw)
+		}
+	}
+	raw, _ = json.Marshal(c.buildChatRequest(protocol.ResponsesRequest{Model: "gpt-4o"}))
+	if strings.Contains(string(raw), "temperature") || strings.Contains(string(raw), "top_p") || strings.Contains(string(raw), "seed") {
+		t.Errorf("expected unset sampling to be omitted, got %s", raw)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"godex/pkg/harness"
 	"godex/pkg/protocol"
+	"godex/pkg/schema"
 	"godex/pkg/sse"
 )
 
@@ -44,6 +45,9 @@ type Harness struct {
 
 var _ harness.Harness = (*Harness)(nil)
 
+// defaultSchemaName names Turn.ResponseSchema when ResponseSchemaName is empty.
+const defaultSchemaName = "output"
+
 // New creates a new OpenAI-compatible harness.
 func New(cfg Config) *Harness {
 	model := cfg.DefaultModel
@@ -78,14 +82,27 @@ func (h *Harness) StreamTurn(ctx context.Context, turn *harness.Turn, onEvent fu
 
 	// The client translates Chat Completions SSE into Codex-format
 	// protocol.StreamEvent. We translate those into harness.Event.
+	var meta harness.DoneEvent
 	err = h.client.StreamResponses(ctx, req, func(ev sse.Event) error {
+		if r := ev.Value.Response; r != nil {
+			if r.SystemFingerprint != "" {
+				meta.SystemFingerprint = r.SystemFingerprint
+			}
+			if turn.Store && r.ID != "" {
+				meta.ResponseID = r.ID
+			}
+		}
 		return h.translateEvent(ev.Value, onEvent)
 	})
 	if err != nil {
 		return err
 	}
 
-	return onEvent(harness.NewDoneEvent())
+	done := harness.NewDoneEvent()
+	if meta != (harness.DoneEvent{}) {
+		done.Done = &meta
+	}
+	return onEvent(done)
 }
 
 // StreamAndCollect executes a turn and returns collected results.
@@ -104,6 +121,11 @@ func (h *Harness) StreamAndCollect(ctx context.Context, turn *harness.Turn) (*ha
 			}
 		case harness.EventUsage:
 			result.Usage = ev.Usage
+		case harness.EventDone:
+			if ev.Done != nil {
+				result.SystemFingerprint = ev.Done.SystemFingerprint
+				result.ResponseID = ev.Done.ResponseID
+			}
 		case harness.EventToolCall:
 			if ev.ToolCall != nil {
 				result.ToolCalls = append(result.ToolCalls, *ev.ToolCall)
@@ -112,6 +134,10 @@ func (h *Harness) StreamAndCollect(ctx context.Context, turn *harness.Turn) (*ha
 		return nil
 	})
 	result.Duration = time.Since(start)
+	if err == nil {
+		// A reply that misses the schema leaves ParsedOutput nil.
+		_ = harness.ParseStructuredOutput(turn, result)
+	}
 	return result, err
 }
 
@@ -120,6 +146,11 @@ func (h *Harness) RunToolLoop(ctx context.Context, turn *harness.Turn, handler h
 	return harness.RunToolLoop(ctx, h.StreamTurn, turn, handler, opts)
 }
 
+// BatchTurns runs independent turns concurrently.
+func (h *Harness) BatchTurns(ctx context.Context, turns []*harness.Turn, onResult func(index int, result *harness.TurnResult, err error), opts harness.LoopOptions) error {
+	return harness.RunBatch(ctx, h.StreamAndCollect, turns, onResult, opts)
+}
+
 // ListModels returns available models.
 func (h *Harness) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
 	return h.listModelsWithDiscovery(ctx)
@@ -132,6 +163,13 @@ func (h *Harness) buildRequest(turn *harness.Turn) (protocol.ResponsesRequest, e
 		model = h.defaultModel
 	}
 
+	if err := turn.ValidateSampling(); err != nil {
+		return protocol.ResponsesRequest{}, err
+	}
+	if err := validateMetadata(turn.Metadata); err != nil {
+		return protocol.ResponsesRequest{}, err
+	}
+
 	instructions, err := BuildSystemPrompt(turn)
 	if err != nil {
 		return protocol.ResponsesRequest{}, err
@@ -181,6 +219,49 @@ func (h *Harness) buildRequest(turn *harness.Turn) (protocol.ResponsesRequest, e
 		toolChoice = "auto"
 	}
 
+	caps, _ := h.Capabilities(harness.WithModel(context.Background(), model))
+
+	// Reasoning effort is only sent to reasoning models; others reject it.
+	var reasoning *protocol.Reasoning
+	if turn.Reasoning != nil {
+		effort := turn.Reasoning.Effort
+		switch effort {
+		case "", "low", "medium", "high":
+		default:
+			return protocol.ResponsesRequest{}, fmt.Errorf("reasoning effort %q is not one of low, medium, high", effort)
+		}
+		if effort == "" && turn.Reasoning.Summaries {
+			effort = "medium"
+		}
+		if effort != "" && caps.SupportsReasoning {
+			reasoning = &protocol.Reasoning{Effort: effort}
+		}
+	}
+
+	// Structured output: strict response_format.json_schema where the model
+	// supports it, otherwise a schema instruction in the system prompt.
+	var text *protocol.TextControls
+	if turn.ResponseSchema != nil {
+		if caps.SupportsStructuredOutput {
+			name := turn.ResponseSchemaName
+			if name == "" {
+				name = defaultSchemaName
+			}
+			strict, err := strictSchema(*turn.ResponseSchema)
+			if err != nil {
+				return protocol.ResponsesRequest{}, fmt.Errorf("response schema: %w", err)
+			}
+			text = &protocol.TextControls{Format: &protocol.TextFormat{
+				Type:   "json_schema",
+				Name:   name,
+				Strict: true,
+				Schema: strict,
+			}}
+		} else {
+			instructions = harness.SchemaInstruction(*turn.ResponseSchema) + "\n\n" + instructions
+		}
+	}
+
 	return protocol.ResponsesRequest{
 		Model:        model,
 		Instructions: instructions,
@@ -188,16 +269,44 @@ func (h *Harness) buildRequest(turn *harness.Turn) (protocol.ResponsesRequest, e
 		Tools:        tools,
 		ToolChoice:   toolChoice,
 		Stream:       true,
+		Reasoning:    reasoning,
+		Text:         text,
+
+		Temperature:     turn.Temperature,
+		TopP:            turn.TopP,
+		PresencePenalty: turn.PresencePenalty,
+		Seed:            turn.Seed,
+		StopSequences:   turn.StopSequences,
+		MaxOutputTokens: turn.MaxOutputTokens,
+		TopLogprobs:     turn.LogProbs,
+		Store:           turn.Store,
+		Metadata:        turn.Metadata,
 	}, nil
 }
 
+// strictSchema closes every object in raw and makes optional properties
+// nullable and required, as strict mode demands. The caller's schema is
+// left untouched.
+func strictSchema(raw json.RawMessage) (json.RawMessage, error) {
+	var node any
+	if err := json.Unmarshal(raw, &node); err != nil {
+		return nil, err
+	}
+	return json.Marshal(schema.NormalizeStrictSchemaNode(node))
+}
+
 // translateEvent converts a Codex-format StreamEvent (produced by the backend
 // openapi client's Chat Completions → Codex SSE translation) into harness events.
 func (h *Harness) translateEvent(ev protocol.StreamEvent, emit func(harness.Event) error) error {
 	switch ev.Type {
 	case "response.output_text.delta":
 		if ev.Delta != "" {
-			return emit(harness.NewTextEvent(ev.Delta))
+			if err := emit(harness.NewTextEvent(ev.Delta)); err != nil {
+				return err
+			}
+		}
+		if len(ev.Logprobs) > 0 {
+			return emit(harness.NewLogProbsEvent(tokenLogProbs(ev.Logprobs)))
 		}
 
 	case "response.output_item.added":
@@ -212,7 +321,15 @@ func (h *Harness) translateEvent(ev protocol.StreamEvent, emit func(harness.Even
 		}
 
 	case "response.completed", "response.done":
-		if ev.Response != nil && ev.Response.Usage != nil {
+		if ev.Response == nil {
+			return nil
+		}
+		if ev.Response.StopReason == harness.StopReasonStopSequence {
+			if err := emit(harness.NewStopReasonEvent(ev.Response.StopSequence)); err != nil {
+				return err
+			}
+		}
+		if ev.Response.Usage != nil {
 			return emit(harness.NewUsageEvent(
 				ev.Response.Usage.InputTokens,
 				ev.Response.Usage.OutputTokens,
@@ -230,4 +347,40 @@ func (h *Harness) translateEvent(ev protocol.StreamEvent, emit func(harness.Even
 	return nil
 }
 
+// OpenAI's limits on response metadata.
+const (
+	maxMetadataKeys     = 16
+	maxMetadataKeyLen   = 64
+	maxMetadataValueLen = 512
+)
+
+// validateMetadata rejects metadata OpenAI would refuse, before any request
+// is sent.
+func validateMetadata(md map[string]string) error {
+	if len(md) > maxMetadataKeys {
+		return fmt.Errorf("metadata has %d keys, at most %d are allowed", len(md), maxMetadataKeys)
+	}
+	for k, v := range md {
+		if len(k) > maxMetadataKeyLen {
+			return fmt.Errorf("metadata key %q is longer than %d characters", k, maxMetadataKeyLen)
+		}
+		if len(v) > maxMetadataValueLen {
+			return fmt.Errorf("metadata value for %q is longer than %d characters", k, maxMetadataValueLen)
+		}
+	}
+	return nil
+}
+
+// tokenLogProbs converts wire log probabilities to harness values.
+func tokenLogProbs(in []protocol.LogProb) []harness.TokenLogProb {
+	out := make([]harness.TokenLogProb, len(in))
+	for i, lp := range in {
+		out[i] = harness.TokenLogProb{Token: lp.Token, LogProb: lp.Logprob}
+		if len(lp.TopLogprobs) > 0 {
+			out[i].TopLogProbs = tokenLogProbs(lp.TopLogprobs)
+		}
+	}
+	return out
+}
+
 // end of file
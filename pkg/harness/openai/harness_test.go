@@ -2,7 +2,12 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"godex/pkg/harness"
@@ -234,6 +239,122 @@ func TestStreamAndCollect(t *testing.T) {
 	}
 }
 
+func TestStreamTurn_LogProbs(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(sseChunk(`{"id":"1","choices":[{"index":0,"delta":{"content":"Yes"},"logprobs":{"content":[` +
+			`{"token":"Yes","logprob":-0.1,"bytes":[89,101,115],"top_logprobs":[{"token":"Yes","logprob":-0.1},{"token":"No","logprob":-2.5}]}]}}]}`)))
+		w.Write([]byte(sseChunk(`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)))
+	}))
+	defer srv.Close()
+	c, _ := NewClient(ClientConfig{BaseURL: srv.URL})
+	h := New(Config{Client: c})
+
+	var events []harness.Event
+	err := h.StreamTurn(context.Background(), &harness.Turn{
+		Model:    "gpt-4o",
+		Messages: []harness.Message{{Role: "user", Content: "ok?"}},
+		LogProbs: 2,
+	}, func(ev harness.Event) error {
+		events = append(events, ev)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, `"logprobs":true,"top_logprobs":2`) {
+		t.Errorf("expected logprobs request fields in %s", body)
+	}
+	if len(events) < 2 || events[0].Kind != harness.EventText || events[1].Kind != harness.EventLogProbs {
+		t.Fatalf("expected text then logprobs, got %+v", events)
+	}
+	want := []harness.TokenLogProb{{Token: "Yes", LogProb: -0.1, TopLogProbs: []harness.TokenLogProb{
+		{Token: "Yes", LogProb: -0.1},
+		{Token: "No", LogProb: -2.5},
+	}}}
+	got, _ := json.Marshal(events[1].LogProbs.Tokens)
+	wantRaw, _ := json.Marshal(want)
+	if string(got) != string(wantRaw) {
+		t.Errorf("logprobs = %s, want %s", got, wantRaw)
+	}
+}
+
+func TestStreamAndCollect_IncludeUsage(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(sseChunk(`{"id":"1","choices":[{"index":0,"delta":{"content":"Hi"}}]}`)))
+		w.Write([]byte(sseChunk(`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)))
+		w.Write([]byte(sseChunk(`{"id":"1","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`)))
+		w.Write([]byte(sseChunk("[DONE]")))
+	}))
+	defer srv.Close()
+	c, _ := NewClient(ClientConfig{BaseURL: srv.URL})
+	h := New(Config{Client: c})
+
+	result, err := h.StreamAndCollect(context.Background(), &harness.Turn{
+		Model:    "gpt-4o",
+		Messages: []harness.Message{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, `"stream_options":{"include_usage":true}`) {
+		t.Errorf("expected stream_options in %s", body)
+	}
+	if result.Usage == nil || result.Usage.InputTokens != 12 || result.Usage.OutputTokens != 3 {
+		t.Fatalf("usage = %+v, want 12 in / 3 out", result.Usage)
+	}
+	usageEvents := 0
+	for _, ev := range result.Events {
+		if ev.Kind == harness.EventUsage {
+			usageEvents++
+		}
+	}
+	if usageEvents != 1 {
+		t.Errorf("expected 1 usage event, got %d", usageEvents)
+	}
+}
+
+func TestStreamAndCollect_SystemFingerprint(t *testing.T) {
+	h := &Harness{
+		client: &mockStreamClient{
+			events: []protocol.StreamEvent{
+				{Type: "response.output_text.delta", Delta: "Hello"},
+				{Type: "response.completed", Response: &protocol.ResponseRef{SystemFingerprint: "fp_abc"}},
+			},
+		},
+		defaultModel: "gpt-4o",
+	}
+
+	result, err := h.StreamAndCollect(context.Background(), &harness.Turn{
+		Messages: []harness.Message{{Role: "user", Content: "hi"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.SystemFingerprint != "fp_abc" {
+		t.Errorf("expected fingerprint fp_abc, got %q", result.SystemFingerprint)
+	}
+}
+
+func TestBuildRequest_Seed(t *testing.T) {
+	h := New(Config{DefaultModel: "gpt-4o"})
+	seed := int64(7)
+	req, err := h.buildRequest(&harness.Turn{Seed: &seed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Seed == nil || *req.Seed != 7 {
+		t.Errorf("expected seed 7, got %v", req.Seed)
+	}
+}
+
 func TestBuildRequest_Basic(t *testing.T) {
 	h := New(Config{DefaultModel: "gpt-4o"})
 	turn := &harness.Turn{
@@ -482,3 +603,170 @@ func (m *multiTurnClient) StreamResponses(ctx context.Context, req protocol.Resp
 func (m *multiTurnClient) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
 	return nil, nil
 }
+
+func TestBuildRequest_ResponseSchema(t *testing.T) {
+	schema := json.RawMessage(`{"type":"object","properties":{"ok":{"type":"boolean"}}}`)
+	h := New(Config{})
+
+	req, err := h.buildRequest(&harness.Turn{Model: "gpt-4o", ResponseSchema: &schema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Text == nil || req.Text.Format == nil || req.Text.Format.Type != "json_schema" {
+		t.Fatalf("expected native json_schema format, got %+v", req.Text)
+	}
+	if strings.Contains(req.Instructions, "<response_schema>") {
+		t.Error("native mode should not add the schema instruction")
+	}
+
+	// The chat request carries a strict, named response_format whose
+	// schema is closed and lists every property as required.
+	c, _ := NewClient(ClientConfig{BaseURL: "http://localhost"})
+	raw, _ := json.Marshal(c.buildChatRequest(req))
+	strict := `{"additionalProperties":false,"properties":{"ok":{"type":["boolean","null"]}},"required":["ok"],"type":"object"}`
+	want := `"response_format":{"type":"json_schema","json_schema":{"name":"output","schema":` + strict + `,"strict":true}}`
+	if !strings.Contains(string(raw), want) {
+		t.Errorf("expected %s in %s", want, raw)
+	}
+	req, _ = h.buildRequest(&harness.Turn{Model: "gpt-4o", ResponseSchema: &schema, ResponseSchemaName: "verdict"})
+	if req.Text.Format.Name != "verdict" {
+		t.Errorf("schema name = %q, want verdict", req.Text.Format.Name)
+	}
+
+	// Models without structured output fall back to a prompt instruction.
+	req, err = h.buildRequest(&harness.Turn{Model: "llama-3.1-8b", ResponseSchema: &schema})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.Text != nil {
+		t.Errorf("expected no native format for fallback, got %+v", req.Text)
+	}
+	if !strings.HasPrefix(req.Instructions, harness.SchemaInstruction(schema)) {
+		t.Errorf("expected schema instruction first, got %q", req.Instructions)
+	}
+}
+
+func TestBuildRequest_ReasoningEffort(t *testing.T) {
+	h := New(Config{})
+	c, _ := NewClient(ClientConfig{BaseURL: "http://localhost"})
+	effort := func(model string, r *harness.ReasoningConfig) string {
+		t.Helper()
+		req, err := h.buildRequest(&harness.Turn{Model: model, Reasoning: r})
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, _ := json.Marshal(c.buildChatRequest(req))
+		var wire struct {
+			ReasoningEffort string `json:"reasoning_effort"`
+		}
+		_ = json.Unmarshal(raw, &wire)
+		return wire.ReasoningEffort
+	}
+	if got := effort("o3-mini", &harness.ReasoningConfig{Effort: "high"}); got != "high" {
+		t.Errorf("o3-mini: reasoning_effort = %q, want high", got)
+	}
+	if got := effort("o1", &harness.ReasoningConfig{Summaries: true}); got != "medium" {
+		t.Errorf("summaries: reasoning_effort = %q, want medium", got)
+	}
+	if got := effort("gpt-4o", &harness.ReasoningConfig{Effort: "high"}); got != "" {
+		t.Errorf("gpt-4o: reasoning_effort = %q, want it omitted", got)
+	}
+	if got := effort("o3", nil); got != "" {
+		t.Errorf("no reasoning: reasoning_effort = %q, want it omitted", got)
+	}
+
+	_, err := h.buildRequest(&harness.Turn{Model: "o3", Reasoning: &harness.ReasoningConfig{Effort: "max"}})
+	if err == nil || !strings.Contains(err.Error(), `"max"`) {
+		t.Fatalf("expected invalid effort error, got %v", err)
+	}
+}
+
+func TestBuildRequest_StoreMetadata(t *testing.T) {
+	h := New(Config{})
+	c, _ := NewClient(ClientConfig{BaseURL: "http://localhost"})
+	req, err := h.buildRequest(&harness.Turn{Model: "gpt-4o", Store: true, Metadata: map[string]string{"run": "nightly"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := json.Marshal(c.buildChatRequest(req))
+	if !strings.Contains(string(raw), `"store":true,"metadata":{"run":"nightly"}`) {
+		t.Errorf("expected store and metadata in %s", raw)
+	}
+
+	tooMany := map[string]string{}
+	for i := 0; i < 17; i++ {
+		tooMany[fmt.Sprintf("k%d", i)] = "v"
+	}
+	if _, err := h.buildRequest(&harness.Turn{Metadata: tooMany}); err == nil || !strings.Contains(err.Error(), "at most 16") {
+		t.Errorf("expected key count error, got %v", err)
+	}
+	long := map[string]string{"note": strings.Repeat("x", 513)}
+	if _, err := h.buildRequest(&harness.Turn{Metadata: long}); err == nil || !strings.Contains(err.Error(), `"note"`) {
+		t.Errorf("expected value length error, got %v", err)
+	}
+}
+
+func TestStreamAndCollect_ResponseID(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(sseChunk(`{"id":"chatcmpl-7","choices":[{"index":0,"delta":{"content":"Hi"}}]}`)))
+		w.Write([]byte(sseChunk(`{"id":"chatcmpl-7","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`)))
+	}))
+	defer srv.Close()
+	c, _ := NewClient(ClientConfig{BaseURL: srv.URL})
+	h := New(Config{Client: c})
+
+	for _, store := range []bool{true, false} {
+		result, err := h.StreamAndCollect(context.Background(), &harness.Turn{
+			Model:    "gpt-4o",
+			Messages: []harness.Message{{Role: "user", Content: "hi"}},
+			Store:    store,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := ""
+		if store {
+			want = "chatcmpl-7"
+		}
+		if result.ResponseID != want {
+			t.Errorf("store=%v: ResponseID = %q, want %q", store, result.ResponseID, want)
+		}
+	}
+}
+
+func TestBuildRequest_SamplingValidation(t *testing.T) {
+	h := New(Config{})
+	topP := 1.2
+	_, err := h.buildRequest(&harness.Turn{Model: "gpt-4o", TopP: &topP})
+	if err == nil || !strings.Contains(err.Error(), "top_p") {
+		t.Fatalf("expected top_p range error, got %v", err)
+	}
+}
+
+func TestCountTokens_Local(t *testing.T) {
+	h := New(Config{}) // no client: counting must not need one
+	count := func(model, content string) int {
+		t.Helper()
+		n, err := h.CountTokens(context.Background(), &harness.Turn{
+			Model:    model,
+			Messages: []harness.Message{{Role: "user", Content: content}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	base := count("gpt-4o", "Hello world")
+	if base <= tokensPerMessage+tokensReplyPrime {
+		t.Fatalf("expected system prompt and message to be counted, got %d", base)
+	}
+	// " Hello world" is two more tokens in o200k_base.
+	if got := count("gpt-4o", "Hello world Hello world") - base; got != 2 {
+		t.Fatalf("expected 2 extra tokens, got %d", got)
+	}
+	// Models tiktoken does not know still get a count.
+	if count("gemini-2.5-pro", "Hello world") == 0 {
+		t.Fatal("expected an estimate for a non-OpenAI model")
+	}
+}
@@ -0,0 +1,89 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultImageModel is used when ImageOptions.Model is empty.
+const DefaultImageModel = "dall-e-3"
+
+// ImageOptions configures GenerateImage. Empty fields keep the provider
+// defaults.
+type ImageOptions struct {
+	Model   string `json:"model,omitempty"`
+	Size    string `json:"size,omitempty"`    // e.g. "1024x1024"
+	Quality string `json:"quality,omitempty"` // e.g. "standard", "hd"
+	N       int    `json:"n,omitempty"`
+	// ResponseFormat is "url" or "b64_json".
+	ResponseFormat string `json:"response_format,omitempty"`
+}
+
+// ImageResult is one generated image: a URL or base64 data, depending on
+// ImageOptions.ResponseFormat.
+type ImageResult struct {
+	URL           string `json:"url,omitempty"`
+	B64JSON       string `json:"b64_json,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// imageGenerator is implemented by clients that can generate images.
+type imageGenerator interface {
+	GenerateImage(ctx context.Context, prompt string, opts ImageOptions) ([]ImageResult, error)
+}
+
+// GenerateImage creates images from prompt via POST /images/generations.
+func (c *Client) GenerateImage(ctx context.Context, prompt string, opts ImageOptions) ([]ImageResult, error) {
+	if strings.TrimSpace(prompt) == "" {
+		return nil, fmt.Errorf("image prompt is required")
+	}
+	switch opts.ResponseFormat {
+	case "", "url", "b64_json":
+	default:
+		return nil, fmt.Errorf("image response format %q is not url or b64_json", opts.ResponseFormat)
+	}
+	if opts.Model == "" {
+		opts.Model = DefaultImageModel
+	}
+	payload, err := json.Marshal(struct {
+		Prompt string `json:"prompt"`
+		ImageOptions
+	}{prompt, opts})
+	if err != nil {
+		return nil, fmt.Errorf("encode image request: %w", err)
+	}
+
+	resp, err := c.doRequest(ctx, "/images/generations", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, fmt.Errorf("image request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var out struct {
+		Data []ImageResult `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode image response: %w", err)
+	}
+	return out.Data, nil
+}
+
+// GenerateImage creates images with the configured client, expanding an
+// alias in opts.Model.
+func (h *Harness) GenerateImage(ctx context.Context, prompt string, opts ImageOptions) ([]ImageResult, error) {
+	gen, ok := h.client.(imageGenerator)
+	if !ok {
+		return nil, fmt.Errorf("openai: no image-capable client configured")
+	}
+	if opts.Model != "" {
+		opts.Model = h.ExpandAlias(opts.Model)
+	}
+	return gen.GenerateImage(ctx, prompt, opts)
+}
@@ -0,0 +1,47 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGenerateImage(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/images/generations" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"created":1,"data":[{"url":"https://img.example/1.png","revised_prompt":"a red fox"}]}`))
+	}))
+	defer srv.Close()
+	c, _ := NewClient(ClientConfig{BaseURL: srv.URL})
+	h := New(Config{Client: c, Aliases: map[string]string{"img": "gpt-image-1"}})
+
+	results, err := h.GenerateImage(context.Background(), "a fox", ImageOptions{Model: "img", Size: "1024x1024", N: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"prompt": "a fox", "model": "gpt-image-1", "size": "1024x1024", "n": float64(1)}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("request %s = %v, want %v", k, got[k], v)
+		}
+	}
+	if _, ok := got["quality"]; ok {
+		t.Error("empty quality should be omitted")
+	}
+	if len(results) != 1 || results[0].URL != "https://img.example/1.png" || results[0].RevisedPrompt != "a red fox" {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	if _, err := c.GenerateImage(context.Background(), "a fox", ImageOptions{ResponseFormat: "png"}); err == nil {
+		t.Error("expected invalid response format error")
+	}
+	if _, err := New(Config{}).GenerateImage(context.Background(), "a fox", ImageOptions{}); err == nil {
+		t.Error("expected error without a client")
+	}
+}
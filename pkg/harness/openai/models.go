@@ -7,6 +7,51 @@ import (
 	"godex/pkg/harness"
 )
 
+var openaiVision = harness.CapabilitySet{
+	SupportsVision:           true,
+	SupportsTools:            true,
+	SupportsStructuredOutput: true,
+	SupportedMediaTypes:      harness.ImageMediaTypes,
+}
+
+// openaiCapabilityRules covers well-known models served by OpenAI-compatible
+// providers, matched by longest prefix. Unknown models are assumed to handle
+// tools and nothing else.
+var openaiCapabilityRules = []harness.CapabilityRule{
+	{Prefix: "gemini-", Caps: withContext(openaiVision, 1048576, false)},
+	{Prefix: "gemini-2.5", Caps: withContext(openaiVision, 1048576, true)},
+	{Prefix: "gemini-3", Caps: withContext(openaiVision, 1048576, true)},
+	{Prefix: "gpt-4o", Caps: withContext(openaiVision, 128000, false)},
+	{Prefix: "gpt-4.1", Caps: withContext(openaiVision, 1047576, false)},
+	{Prefix: "gpt-5", Caps: withContext(openaiVision, 400000, true)},
+	{Prefix: "llama", Caps: harness.CapabilitySet{MaxContextTokens: 128000, SupportsTools: true}},
+	{Prefix: "llama-3.2-11b-vision", Caps: withContext(openaiVision, 128000, false)},
+	{Prefix: "llama-3.2-90b-vision", Caps: withContext(openaiVision, 128000, false)},
+	{Prefix: "o1", Caps: withContext(openaiVision, 200000, true)},
+	{Prefix: "o1-mini", Caps: harness.CapabilitySet{MaxContextTokens: 128000, SupportsReasoning: true}},
+	{Prefix: "o3", Caps: withContext(openaiVision, 200000, true)},
+	{Prefix: "o3-mini", Caps: harness.CapabilitySet{MaxContextTokens: 200000, SupportsTools: true, SupportsStructuredOutput: true, SupportsReasoning: true}},
+	{Prefix: "o4-mini", Caps: withContext(openaiVision, 200000, true)},
+}
+
+var openaiFallbackCapabilities = harness.CapabilitySet{SupportsTools: true}
+
+func withContext(c harness.CapabilitySet, tokens int, reasoning bool) harness.CapabilitySet {
+	c.MaxContextTokens = tokens
+	c.SupportsReasoning = reasoning
+	return c
+}
+
+// Capabilities reports what the model named in ctx supports, from the
+// known-model table.
+func (h *Harness) Capabilities(ctx context.Context) (harness.CapabilitySet, error) {
+	model, _ := harness.Model(ctx)
+	if model != "" {
+		model = h.ExpandAlias(model)
+	}
+	return harness.LookupCapabilities(openaiCapabilityRules, openaiFallbackCapabilities, model), nil
+}
+
 // ExpandAlias expands a model alias to its full name.
 func (h *Harness) ExpandAlias(alias string) string {
 	if h.aliases == nil {
@@ -1,6 +1,11 @@
 package openai
 
-import "testing"
+import (
+	"context"
+	"testing"
+
+	"godex/pkg/harness"
+)
 
 func TestExpandAlias(t *testing.T) {
 	h := New(Config{Aliases: map[string]string{"mini": "gpt-mini"}})
@@ -38,3 +43,15 @@ func TestMatchesModel_NoConfig(t *testing.T) {
 		t.Error("expected no match when no prefixes or aliases configured")
 	}
 }
+
+func TestCapabilities(t *testing.T) {
+	h := New(Config{Aliases: map[string]string{"flash": "gemini-2.5-flash"}})
+	caps, _ := h.Capabilities(harness.WithModel(context.Background(), "flash"))
+	if !caps.SupportsVision || !caps.SupportsReasoning || caps.MaxContextTokens != 1048576 {
+		t.Fatalf("flash: unexpected %+v", caps)
+	}
+	caps, _ = h.Capabilities(harness.WithModel(context.Background(), "mixtral-8x7b"))
+	if caps.SupportsVision || !caps.SupportsTools {
+		t.Fatalf("unknown model: unexpected %+v", caps)
+	}
+}
@@ -0,0 +1,83 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ModerationModel is the model CheckModeration and Moderate ask for.
+const ModerationModel = "omni-moderation-latest"
+
+// ModerationResult is the moderation API's verdict on one input.
+type ModerationResult struct {
+	Flagged bool `json:"flagged"`
+	// Categories marks each category the API flagged.
+	Categories map[string]bool `json:"categories"`
+	// CategoryScores holds each category's score in [0, 1].
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// FlaggedAbove returns the categories scoring above threshold, sorted. A
+// threshold of zero or less uses the API's own flags instead.
+func (m *ModerationResult) FlaggedAbove(threshold float64) []string {
+	var out []string
+	if threshold <= 0 {
+		for name, flagged := range m.Categories {
+			if flagged {
+				out = append(out, name)
+			}
+		}
+	} else {
+		for name, score := range m.CategoryScores {
+			if score > threshold {
+				out = append(out, name)
+			}
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// Moderate classifies text with POST /moderations.
+func (c *Client) Moderate(ctx context.Context, text string) (*ModerationResult, error) {
+	payload, err := json.Marshal(map[string]string{"model": ModerationModel, "input": text})
+	if err != nil {
+		return nil, fmt.Errorf("encode moderation request: %w", err)
+	}
+	resp, err := c.doRequest(ctx, "/moderations", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		return nil, fmt.Errorf("moderation request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var out struct {
+		Results []ModerationResult `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode moderation response: %w", err)
+	}
+	if len(out.Results) == 0 {
+		return nil, fmt.Errorf("moderation response has no results")
+	}
+	return &out.Results[0], nil
+}
+
+// CheckModeration reports whether text passes moderation and, if not, which
+// categories the API flagged.
+func (c *Client) CheckModeration(ctx context.Context, text string) (safe bool, flaggedCategories []string, err error) {
+	res, err := c.Moderate(ctx, text)
+	if err != nil {
+		return false, nil, err
+	}
+	flagged := res.FlaggedAbove(0)
+	return !res.Flagged && len(flagged) == 0, flagged, nil
+}
@@ -0,0 +1,47 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestCheckModeration(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/moderations" {
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"id":"modr-1","results":[{"flagged":true,` +
+			`"categories":{"violence":true,"hate":false,"self-harm":true},` +
+			`"category_scores":{"violence":0.91,"hate":0.4,"self-harm":0.6}}]}`))
+	}))
+	defer srv.Close()
+
+	c, _ := NewClient(ClientConfig{BaseURL: srv.URL})
+	safe, flagged, err := c.CheckModeration(context.Background(), "some text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["model"] != ModerationModel || got["input"] != "some text" {
+		t.Errorf("unexpected request body %v", got)
+	}
+	if safe || !reflect.DeepEqual(flagged, []string{"self-harm", "violence"}) {
+		t.Errorf("got safe=%v flagged=%v", safe, flagged)
+	}
+
+	res, err := c.Moderate(context.Background(), "some text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if above := res.FlaggedAbove(0.3); !reflect.DeepEqual(above, []string{"hate", "self-harm", "violence"}) {
+		t.Errorf("FlaggedAbove(0.3) = %v", above)
+	}
+	if above := res.FlaggedAbove(0.95); len(above) != 0 {
+		t.Errorf("FlaggedAbove(0.95) = %v, want none", above)
+	}
+}
@@ -0,0 +1,76 @@
+package openai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+
+	"godex/pkg/harness"
+)
+
+// Per-message overhead from OpenAI's token counting guide: every message is
+// wrapped in role/separator tokens and the reply is primed with three more.
+const (
+	tokensPerMessage = 3
+	tokensReplyPrime = 3
+)
+
+var useOfflineBPE sync.Once
+
+// CountTokens counts the prompt tokens of the Chat Completions request turn
+// would send, locally with tiktoken. No network call is made: the BPE ranks
+// are embedded in the binary. Providers with their own tokenizer (Gemini,
+// Groq, ...) get the closest OpenAI encoding, so treat their count as an
+// estimate.
+func (h *Harness) CountTokens(ctx context.Context, turn *harness.Turn) (int, error) {
+	req, err := h.buildRequest(turn)
+	if err != nil {
+		return 0, fmt.Errorf("openai: build request: %w", err)
+	}
+	cr := (&Client{}).buildChatRequest(req) // reads no client state
+	enc, err := encodingFor(cr.Model)
+	if err != nil {
+		return 0, fmt.Errorf("openai: load tokenizer: %w", err)
+	}
+	count := func(s string) int {
+		if s == "" {
+			return 0
+		}
+		return len(enc.EncodeOrdinary(s))
+	}
+	n := tokensReplyPrime
+	for _, msg := range cr.Messages {
+		n += tokensPerMessage + count(msg.Role) + count(msg.Content)
+		for _, call := range msg.ToolCalls {
+			n += count(call.Function.Name) + count(call.Function.Arguments)
+		}
+	}
+	for _, tool := range cr.Tools {
+		n += count(tool.Function.Name) + count(tool.Function.Description) + count(string(tool.Function.Parameters))
+	}
+	if cr.ResponseFormat != nil && cr.ResponseFormat.JSONSchema != nil {
+		n += count(string(cr.ResponseFormat.JSONSchema.Schema))
+	}
+	return n, nil
+}
+
+// encodingFor returns the tiktoken encoding for model, falling back to
+// o200k_base for newer OpenAI models tiktoken does not list yet and to
+// cl100k_base for everything else.
+func encodingFor(model string) (*tiktoken.Tiktoken, error) {
+	useOfflineBPE.Do(func() { tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader()) })
+	if enc, err := tiktoken.EncodingForModel(model); err == nil {
+		return enc, nil
+	}
+	lower := strings.ToLower(model)
+	for _, prefix := range []string{"gpt-4o", "gpt-4.1", "gpt-5", "o1", "o3", "o4"} {
+		if strings.HasPrefix(lower, prefix) {
+			return tiktoken.GetEncoding(tiktoken.MODEL_O200K_BASE)
+		}
+	}
+	return tiktoken.GetEncoding(tiktoken.MODEL_CL100K_BASE)
+}
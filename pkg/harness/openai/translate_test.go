@@ -136,6 +136,32 @@ func TestTranslateEvent_ResponseDone(t *testing.T) {
 	}
 }
 
+func TestTranslateEvent_StopSequence(t *testing.T) {
+	h := &Harness{}
+	ev := protocol.StreamEvent{
+		Type: "response.completed",
+		Response: &protocol.ResponseRef{
+			StopReason:   "stop_sequence",
+			StopSequence: "END",
+			Usage:        &protocol.Usage{InputTokens: 5, OutputTokens: 2},
+		},
+	}
+	var events []harness.Event
+	err := h.translateEvent(ev, func(e harness.Event) error {
+		events = append(events, e)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].Kind != harness.EventStopReason || events[1].Kind != harness.EventUsage {
+		t.Fatalf("expected stop_reason then usage, got %v", events)
+	}
+	if events[0].StopReason.Sequence != "END" {
+		t.Errorf("expected sequence END, got %q", events[0].StopReason.Sequence)
+	}
+}
+
 func TestTranslateEvent_ResponseDone_NoUsage(t *testing.T) {
 	h := &Harness{}
 	ev := protocol.StreamEvent{Type: "response.done", Response: &protocol.ResponseRef{}}
@@ -0,0 +1,115 @@
+package harness
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPatch is returned by ParseApplyPatchArgs when the arguments are
+// not an apply_patch patch.
+var ErrInvalidPatch = errors.New("invalid apply_patch patch")
+
+// ParsedPatch summarizes an apply_patch call. For a patch that touches
+// several files, FilePath is the first file and the counts cover the whole
+// patch.
+type ParsedPatch struct {
+	FilePath     string `json:"file_path"`
+	HunkCount    int    `json:"hunk_count"`
+	AddedLines   int    `json:"added_lines"`
+	RemovedLines int    `json:"removed_lines"`
+}
+
+// ApplyPatchInput returns the patch text from apply_patch tool call
+// arguments. The freeform (Lark grammar) tool passes the patch as-is; the
+// JSON function form wraps it as {"input": "..."}.
+func ApplyPatchInput(args string) string {
+	trimmed := strings.TrimSpace(args)
+	if strings.HasPrefix(trimmed, "{") {
+		var wrapped struct {
+			Input string `json:"input"`
+			Patch string `json:"patch"`
+		}
+		if err := json.Unmarshal([]byte(trimmed), &wrapped); err == nil {
+			if wrapped.Input != "" {
+				return wrapped.Input
+			}
+			return wrapped.Patch
+		}
+	}
+	return args
+}
+
+// ParseApplyPatchArgs parses apply_patch tool call arguments in the Codex
+// patch format ("*** Begin Patch" ... "*** End Patch"). Each "@@" section of
+// an updated file is one hunk, as is each added or deleted file.
+func ParseApplyPatchArgs(args string) (*ParsedPatch, error) {
+	text := strings.ReplaceAll(ApplyPatchInput(args), "\r\n", "\n")
+	lines := strings.Split(text, "\n")
+
+	start := -1
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "*** Begin Patch" {
+			start = i + 1
+			break
+		}
+	}
+	if start < 0 {
+		return nil, fmt.Errorf("%w: missing *** Begin Patch", ErrInvalidPatch)
+	}
+
+	p := &ParsedPatch{}
+	files := 0
+	inUpdate, hunkOpen := false, false
+	openFile := func(path string) {
+		if files == 0 {
+			p.FilePath = strings.TrimSpace(path)
+		}
+		files++
+		inUpdate, hunkOpen = false, false
+	}
+	for _, line := range lines[start:] {
+		switch {
+		case strings.TrimSpace(line) == "*** End Patch":
+			if files == 0 {
+				return nil, fmt.Errorf("%w: no file operations", ErrInvalidPatch)
+			}
+			return p, nil
+		case strings.HasPrefix(line, "*** Add File: "):
+			openFile(strings.TrimPrefix(line, "*** Add File: "))
+			p.HunkCount++
+		case strings.HasPrefix(line, "*** Delete File: "):
+			openFile(strings.TrimPrefix(line, "*** Delete File: "))
+			p.HunkCount++
+		case strings.HasPrefix(line, "*** Update File: "):
+			openFile(strings.TrimPrefix(line, "*** Update File: "))
+			inUpdate = true
+		case strings.HasPrefix(line, "*** "):
+			// "*** Move to:" and "*** End of File" carry no line changes.
+		case inUpdate && strings.HasPrefix(line, "@@"):
+			p.HunkCount++
+			hunkOpen = true
+		case strings.HasPrefix(line, "+"), strings.HasPrefix(line, "-"), strings.HasPrefix(line, " "):
+			if files == 0 {
+				continue
+			}
+			if inUpdate && !hunkOpen {
+				p.HunkCount++
+				hunkOpen = true
+			}
+			switch line[0] {
+			case '+':
+				p.AddedLines++
+			case '-':
+				p.RemovedLines++
+			}
+		}
+	}
+	if files == 0 {
+		return nil, fmt.Errorf("%w: no file operations", ErrInvalidPatch)
+	}
+	// Tolerate a missing "*** End Patch", as streamed arguments can be cut
+	// short.
+	return p, nil
+}
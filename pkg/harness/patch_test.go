@@ -0,0 +1,68 @@
+package harness
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseApplyPatchArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want ParsedPatch
+	}{
+		{
+			name: "update with hunks",
+			args: "*** Begin Patch\n*** Update File: pkg/a.go\n@@ func A()\n-\treturn 1\n+\treturn 2\n@@ func B()\n context\n+\tlog()\n*** End Patch\n",
+			want: ParsedPatch{FilePath: "pkg/a.go", HunkCount: 2, AddedLines: 2, RemovedLines: 1},
+		},
+		{
+			name: "update without @@",
+			args: "*** Begin Patch\n*** Update File: a.txt\n-old\n+new\n*** End Patch",
+			want: ParsedPatch{FilePath: "a.txt", HunkCount: 1, AddedLines: 1, RemovedLines: 1},
+		},
+		{
+			name: "add and delete files",
+			args: "*** Begin Patch\n*** Add File: new.txt\n+one\n+two\n*** Delete File: old.txt\n*** End Patch",
+			want: ParsedPatch{FilePath: "new.txt", HunkCount: 2, AddedLines: 2},
+		},
+		{
+			name: "move with end of file",
+			args: "*** Begin Patch\n*** Update File: a.go\n*** Move to: b.go\n@@\n-x\n+y\n*** End of File\n*** End Patch",
+			want: ParsedPatch{FilePath: "a.go", HunkCount: 1, AddedLines: 1, RemovedLines: 1},
+		},
+		{
+			name: "json wrapped",
+			args: `{"input":"*** Begin Patch\n*** Add File: x\n+hi\n*** End Patch"}`,
+			want: ParsedPatch{FilePath: "x", HunkCount: 1, AddedLines: 1},
+		},
+		{
+			name: "crlf and missing end",
+			args: "*** Begin Patch\r\n*** Update File: a\r\n@@\r\n+b\r\n",
+			want: ParsedPatch{FilePath: "a", HunkCount: 1, AddedLines: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseApplyPatchArgs(tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != tt.want {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseApplyPatchArgs_Invalid(t *testing.T) {
+	for _, args := range []string{
+		"",
+		`{"command":["ls"]}`,
+		"*** Begin Patch\n*** End Patch",
+	} {
+		if _, err := ParseApplyPatchArgs(args); !errors.Is(err, ErrInvalidPatch) {
+			t.Errorf("%q: expected ErrInvalidPatch, got %v", args, err)
+		}
+	}
+}
@@ -3,10 +3,15 @@ package harness
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 )
 
+// ErrReplayExhausted is returned by replay harnesses once every recorded
+// turn has been played back.
+var ErrReplayExhausted = errors.New("replay: all recorded turns consumed")
+
 // LogData holds a parsed JSONL log file with the original turn and events.
 type LogData struct {
 	Turn   *Turn
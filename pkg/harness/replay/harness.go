@@ -0,0 +1,234 @@
+// Package replay implements a harness that plays back events recorded by
+// `godex exec --log-responses` (or harness.WithLogger), for testing proxy
+// and client behavior against real traffic without network access.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"godex/pkg/harness"
+)
+
+// Option configures a Harness.
+type Option func(*Harness)
+
+// WithTiming replays events with the gaps between their recorded
+// timestamps. Without it, events are emitted as fast as possible.
+func WithTiming(on bool) Option {
+	return func(h *Harness) { h.timing = on }
+}
+
+// Harness replays recorded turns in order: each StreamTurn call emits the
+// next recorded turn, whatever the request. Once all turns are consumed,
+// StreamTurn returns harness.ErrReplayExhausted.
+type Harness struct {
+	timing bool
+
+	mu    sync.Mutex
+	turns [][]harness.Event
+	next  int
+}
+
+var _ harness.Harness = (*Harness)(nil)
+
+// Open reads a JSONL log file. See New for the accepted formats.
+func Open(path string, opts ...Option) (*Harness, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	defer f.Close()
+	return New(f, opts...)
+}
+
+// New reads JSONL from r. Each line is either a harness.Event, as written by
+// exec --log-responses, or a harness.LogEntry, as written by
+// harness.WithLogger. A done event (or a turn_end entry) ends a turn. Lines
+// of neither shape, such as the Responses-style events exec --json writes,
+// are skipped.
+func New(r io.Reader, opts ...Option) (*Harness, error) {
+	h := &Harness{}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	var current []harness.Event
+	endTurn := func() {
+		if len(current) > 0 {
+			h.turns = append(h.turns, current)
+			current = nil
+		}
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var probe struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &probe); err != nil {
+			continue // skip malformed lines
+		}
+		var ev harness.Event
+		switch probe.Type {
+		case "":
+			if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+				continue
+			}
+		case "event":
+			var entry harness.LogEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Event == nil {
+				continue
+			}
+			ev = *entry.Event
+		case "turn_end":
+			endTurn()
+			continue
+		default:
+			continue
+		}
+		current = append(current, ev)
+		if ev.Kind == harness.EventDone {
+			endTurn()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	endTurn()
+	if len(h.turns) == 0 {
+		return nil, fmt.Errorf("replay: no recorded events")
+	}
+	return h, nil
+}
+
+// Remaining reports how many recorded turns have not been replayed yet.
+func (h *Harness) Remaining() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.turns) - h.next
+}
+
+// Name returns "replay".
+func (h *Harness) Name() string { return "replay" }
+
+// StreamTurn emits the next recorded turn. The turn argument is ignored.
+func (h *Harness) StreamTurn(ctx context.Context, _ *harness.Turn, onEvent func(harness.Event) error) error {
+	h.mu.Lock()
+	if h.next >= len(h.turns) {
+		h.mu.Unlock()
+		return harness.ErrReplayExhausted
+	}
+	events := h.turns[h.next]
+	h.next++
+	h.mu.Unlock()
+
+	for i, ev := range events {
+		if h.timing && i > 0 {
+			if err := sleep(ctx, ev.Timestamp.Sub(events[i-1].Timestamp)); err != nil {
+				return err
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := onEvent(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StreamAndCollect replays the next turn and returns the collected result.
+func (h *Harness) StreamAndCollect(ctx context.Context, turn *harness.Turn) (*harness.TurnResult, error) {
+	start := time.Now()
+	result := &harness.TurnResult{}
+	err := h.StreamTurn(ctx, turn, func(ev harness.Event) error {
+		result.Events = append(result.Events, ev)
+		switch ev.Kind {
+		case harness.EventText:
+			if ev.Text != nil {
+				result.FinalText += ev.Text.Delta
+				if ev.Text.Complete != "" {
+					result.FinalText = ev.Text.Complete
+				}
+			}
+		case harness.EventUsage:
+			result.Usage = ev.Usage
+		case harness.EventDone:
+			if ev.Done != nil {
+				result.SystemFingerprint = ev.Done.SystemFingerprint
+			}
+		case harness.EventToolCall:
+			if ev.ToolCall != nil {
+				result.ToolCalls = append(result.ToolCalls, *ev.ToolCall)
+			}
+		}
+		return nil
+	})
+	result.Duration = time.Since(start)
+	if err == nil {
+		_ = harness.ParseStructuredOutput(turn, result)
+	}
+	return result, err
+}
+
+// RunToolLoop runs the generic tool loop; each model call consumes one
+// recorded turn.
+func (h *Harness) RunToolLoop(ctx context.Context, turn *harness.Turn, handler harness.ToolHandler, opts harness.LoopOptions) (*harness.TurnResult, error) {
+	return harness.RunToolLoop(ctx, h.StreamTurn, turn, handler, opts)
+}
+
+// BatchTurns runs turns concurrently; recorded turns are handed out in call
+// order, not turn order.
+func (h *Harness) BatchTurns(ctx context.Context, turns []*harness.Turn, onResult func(index int, result *harness.TurnResult, err error), opts harness.LoopOptions) error {
+	return harness.RunBatch(ctx, h.StreamAndCollect, turns, onResult, opts)
+}
+
+// Capabilities claims everything so capability-based routing never skips a
+// replay; the recording decides what actually comes back.
+func (h *Harness) Capabilities(context.Context) (harness.CapabilitySet, error) {
+	return harness.CapabilitySet{
+		SupportsVision:           true,
+		SupportsTools:            true,
+		SupportsReasoning:        true,
+		SupportsStructuredOutput: true,
+		SupportedMediaTypes:      harness.ImageMediaTypes,
+	}, nil
+}
+
+// CountTokens returns harness.EstimateTurnTokens(turn).
+func (h *Harness) CountTokens(_ context.Context, turn *harness.Turn) (int, error) {
+	return harness.EstimateTurnTokens(turn), nil
+}
+
+// ListModels returns no models; route to the replay with router patterns.
+func (h *Harness) ListModels(context.Context) ([]harness.ModelInfo, error) {
+	return nil, nil
+}
+
+// ExpandAlias returns the alias unchanged.
+func (h *Harness) ExpandAlias(alias string) string { return alias }
+
+// MatchesModel returns false, like harness.Mock.
+func (h *Harness) MatchesModel(string) bool { return false }
@@ -0,0 +1,135 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"godex/pkg/harness"
+)
+
+// recording builds an exec --log-responses file with two turns whose events
+// are gap apart.
+func recording(t *testing.T, gap time.Duration) string {
+	t.Helper()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []harness.Event{
+		harness.NewToolCallEvent("c1", "shell", `{"cmd":"ls"}`),
+		harness.NewDoneEvent(),
+		harness.NewTextEvent("hello "),
+		harness.NewTextEvent("world"),
+		harness.NewUsageEvent(12, 3),
+		harness.NewDoneEvent(),
+	}
+	var b strings.Builder
+	for i, ev := range events {
+		ev.Timestamp = base.Add(time.Duration(i) * gap)
+		line, _ := json.Marshal(ev)
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	b.WriteString(`{"type":"response.output_text.delta","delta":"ignored"}` + "\n")
+	path := filepath.Join(t.TempDir(), "responses.jsonl")
+	if err := os.WriteFile(path, []byte(b.String()), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReplayTurnsInOrder(t *testing.T) {
+	h, err := Open(recording(t, time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Remaining() != 2 {
+		t.Fatalf("expected 2 recorded turns, got %d", h.Remaining())
+	}
+
+	start := time.Now()
+	first, err := h.StreamAndCollect(context.Background(), &harness.Turn{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.ToolCalls) != 1 || first.ToolCalls[0].Name != "shell" {
+		t.Fatalf("unexpected first turn: %+v", first)
+	}
+	second, err := h.StreamAndCollect(context.Background(), &harness.Turn{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.FinalText != "hello world" || second.Usage == nil || second.Usage.InputTokens != 12 {
+		t.Fatalf("unexpected second turn: %+v", second)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Fatal("replay without WithTiming should not wait between events")
+	}
+
+	err = h.StreamTurn(context.Background(), &harness.Turn{}, func(harness.Event) error { return nil })
+	if !errors.Is(err, harness.ErrReplayExhausted) {
+		t.Fatalf("expected ErrReplayExhausted, got %v", err)
+	}
+}
+
+func TestReplayWithTiming(t *testing.T) {
+	h, err := Open(recording(t, 20*time.Millisecond), WithTiming(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = h.StreamTurn(context.Background(), nil, func(harness.Event) error { return nil })
+
+	start := time.Now()
+	if err := h.StreamTurn(context.Background(), nil, func(harness.Event) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	// Four events in the second turn: three recorded gaps.
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected recorded gaps to be kept, took %s", elapsed)
+	}
+}
+
+func TestReplayTimingHonorsCancel(t *testing.T) {
+	h, err := Open(recording(t, time.Hour), WithTiming(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = h.StreamTurn(ctx, nil, func(harness.Event) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+}
+
+func TestReplayLoggerFormat(t *testing.T) {
+	ev := harness.NewTextEvent("from logger")
+	entries := []harness.LogEntry{
+		{Type: "turn_start", Turn: &harness.Turn{Model: "m"}},
+		{Type: "event", Kind: "text", Event: &ev},
+		{Type: "turn_end"},
+	}
+	var b strings.Builder
+	for _, e := range entries {
+		line, _ := json.Marshal(e)
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	h, err := New(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := h.StreamAndCollect(context.Background(), &harness.Turn{})
+	if err != nil || result.FinalText != "from logger" {
+		t.Fatalf("got %+v, %v", result, err)
+	}
+}
+
+func TestReplayEmptyLog(t *testing.T) {
+	if _, err := New(strings.NewReader("not json\n")); err == nil {
+		t.Fatal("expected an error for a log without events")
+	}
+}
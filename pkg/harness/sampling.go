@@ -0,0 +1,38 @@
+package harness
+
+import "fmt"
+
+// MaxStopSequences is the most stop sequences a Turn may set, the lowest
+// limit among the providers (OpenAI allows 4).
+const MaxStopSequences = 4
+
+// MaxLogProbs is the most alternatives per token a Turn may ask for
+// (OpenAI's top_logprobs limit).
+const MaxLogProbs = 20
+
+// ValidateSampling rejects sampling parameters outside the ranges providers
+// accept, and stop sequences beyond MaxStopSequences, so a bad value fails
+// before any request is sent.
+func (t *Turn) ValidateSampling() error {
+	if t.Temperature != nil && (*t.Temperature < 0 || *t.Temperature > 2) {
+		return fmt.Errorf("temperature %g is out of range [0, 2]", *t.Temperature)
+	}
+	if t.TopP != nil && (*t.TopP < 0 || *t.TopP > 1) {
+		return fmt.Errorf("top_p %g is out of range [0, 1]", *t.TopP)
+	}
+	if t.PresencePenalty != nil && (*t.PresencePenalty < -2 || *t.PresencePenalty > 2) {
+		return fmt.Errorf("presence_penalty %g is out of range [-2, 2]", *t.PresencePenalty)
+	}
+	if len(t.StopSequences) > MaxStopSequences {
+		return fmt.Errorf("at most %d stop sequences are allowed, got %d", MaxStopSequences, len(t.StopSequences))
+	}
+	for _, s := range t.StopSequences {
+		if s == "" {
+			return fmt.Errorf("stop sequences must not be empty")
+		}
+	}
+	if t.LogProbs < 0 || t.LogProbs > MaxLogProbs {
+		return fmt.Errorf("logprobs %d is out of range [0, %d]", t.LogProbs, MaxLogProbs)
+	}
+	return nil
+}
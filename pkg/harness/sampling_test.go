@@ -0,0 +1,40 @@
+package harness
+
+import (
+	"strings"
+	"testing"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestValidateSampling(t *testing.T) {
+	cases := []struct {
+		name string
+		turn Turn
+		want string
+	}{
+		{"defaults", Turn{}, ""},
+		{"in range", Turn{Temperature: float(2), TopP: float(0), PresencePenalty: float(-2)}, ""},
+		{"temperature high", Turn{Temperature: float(2.1)}, "temperature 2.1 is out of range [0, 2]"},
+		{"temperature negative", Turn{Temperature: float(-0.5)}, "temperature -0.5"},
+		{"top_p high", Turn{TopP: float(1.5)}, "top_p 1.5 is out of range [0, 1]"},
+		{"presence penalty", Turn{PresencePenalty: float(3)}, "presence_penalty 3"},
+		{"stop sequences", Turn{StopSequences: []string{"a", "b", "c", "d"}}, ""},
+		{"too many stop sequences", Turn{StopSequences: []string{"a", "b", "c", "d", "e"}}, "at most 4 stop sequences"},
+		{"empty stop sequence", Turn{StopSequences: []string{""}}, "must not be empty"},
+		{"logprobs", Turn{LogProbs: 20}, ""},
+		{"too many logprobs", Turn{LogProbs: 21}, "logprobs 21 is out of range [0, 20]"},
+	}
+	for _, tc := range cases {
+		err := tc.turn.ValidateSampling()
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
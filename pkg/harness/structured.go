@@ -0,0 +1,158 @@
+package harness
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrSchemaViolation is returned by ParseStructuredOutput when the model's
+// reply is not JSON or does not match Turn.ResponseSchema.
+var ErrSchemaViolation = errors.New("response does not match schema")
+
+// SchemaInstruction is the system-prompt fallback used by harnesses whose
+// provider has no native structured output mode.
+func SchemaInstruction(schema json.RawMessage) string {
+	return "Respond with a single JSON value that conforms to the JSON Schema below. " +
+		"Output only the JSON: no Markdown fences, no commentary.\n\n" +
+		"<response_schema>\n" + strings.TrimSpace(string(schema)) + "\n</response_schema>"
+}
+
+// ParseStructuredOutput decodes result.FinalText into result.ParsedOutput
+// when turn requests a ResponseSchema. ParsedOutput is only set when the text
+// is valid JSON that satisfies the schema; otherwise the returned error wraps
+// ErrSchemaViolation and ParsedOutput stays nil. A Markdown code fence around
+// the JSON is tolerated.
+func ParseStructuredOutput(turn *Turn, result *TurnResult) error {
+	if turn == nil || turn.ResponseSchema == nil || result == nil {
+		return nil
+	}
+	text := stripCodeFence(strings.TrimSpace(result.FinalText))
+	var parsed any
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+		return fmt.Errorf("%w: invalid JSON: %v", ErrSchemaViolation, err)
+	}
+	var schema any
+	if err := json.Unmarshal(*turn.ResponseSchema, &schema); err != nil {
+		return fmt.Errorf("invalid response schema: %w", err)
+	}
+	if err := validateSchema(schema, parsed, "$"); err != nil {
+		return fmt.Errorf("%w: %v", ErrSchemaViolation, err)
+	}
+	result.ParsedOutput = parsed
+	return nil
+}
+
+func stripCodeFence(text string) string {
+	if !strings.HasPrefix(text, "```") || !strings.HasSuffix(text, "```") || len(text) < 6 {
+		return text
+	}
+	body := strings.TrimSuffix(text[3:], "```")
+	if nl := strings.IndexByte(body, '\n'); nl >= 0 {
+		body = body[nl+1:] // drop the language tag line
+	}
+	return strings.TrimSpace(body)
+}
+
+// validateSchema checks the JSON Schema keywords providers enforce in strict
+// mode: type, enum, const, properties, required, additionalProperties and
+// items. Other keywords are ignored.
+func validateSchema(schema, v any, path string) error {
+	s, ok := schema.(map[string]any)
+	if !ok {
+		return nil
+	}
+	if t, ok := s["type"]; ok && !matchesType(t, v) {
+		return fmt.Errorf("%s: expected %v, got %s", path, t, jsonType(v))
+	}
+	if enum, ok := s["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value not in enum", path)
+		}
+	}
+	if c, ok := s["const"]; ok && !reflect.DeepEqual(c, v) {
+		return fmt.Errorf("%s: value does not match const", path)
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		props, _ := s["properties"].(map[string]any)
+		if req, ok := s["required"].([]any); ok {
+			for _, r := range req {
+				name, _ := r.(string)
+				if _, ok := val[name]; !ok {
+					return fmt.Errorf("%s: missing required property %q", path, name)
+				}
+			}
+		}
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if sub, ok := props[k]; ok {
+				if err := validateSchema(sub, val[k], path+"."+k); err != nil {
+					return err
+				}
+			} else if s["additionalProperties"] == false {
+				return fmt.Errorf("%s: unexpected property %q", path, k)
+			}
+		}
+	case []any:
+		if items, ok := s["items"]; ok {
+			for i, item := range val {
+				if err := validateSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func matchesType(t, v any) bool {
+	switch tt := t.(type) {
+	case string:
+		return jsonType(v) == tt || (tt == "number" && jsonType(v) == "integer")
+	case []any:
+		for _, one := range tt {
+			if matchesType(one, v) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func jsonType(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if val == math.Trunc(val) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
@@ -0,0 +1,78 @@
+package harness
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const testSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string"},
+		"age": {"type": "integer"},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"kind": {"enum": ["a", "b"]}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false
+}`
+
+func schemaTurn() *Turn {
+	raw := json.RawMessage(testSchema)
+	return &Turn{ResponseSchema: &raw}
+}
+
+func TestParseStructuredOutput(t *testing.T) {
+	cases := []struct {
+		name  string
+		text  string
+		valid bool
+	}{
+		{"valid", `{"name":"Ada","age":36,"tags":["x"],"kind":"a"}`, true},
+		{"fenced", "```json\n{\"name\":\"Ada\",\"age\":36}\n```", true},
+		{"not json", `Ada is 36`, false},
+		{"missing required", `{"name":"Ada"}`, false},
+		{"wrong type", `{"name":"Ada","age":"36"}`, false},
+		{"non-integer", `{"name":"Ada","age":36.5}`, false},
+		{"bad item", `{"name":"Ada","age":36,"tags":[1]}`, false},
+		{"not in enum", `{"name":"Ada","age":36,"kind":"c"}`, false},
+		{"extra property", `{"name":"Ada","age":36,"extra":true}`, false},
+	}
+	for _, tc := range cases {
+		result := &TurnResult{FinalText: tc.text}
+		err := ParseStructuredOutput(schemaTurn(), result)
+		if tc.valid {
+			if err != nil || result.ParsedOutput == nil {
+				t.Errorf("%s: expected parsed output, got %v", tc.name, err)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrSchemaViolation) {
+			t.Errorf("%s: expected ErrSchemaViolation, got %v", tc.name, err)
+		}
+		if result.ParsedOutput != nil {
+			t.Errorf("%s: ParsedOutput should stay nil on violation", tc.name)
+		}
+	}
+}
+
+func TestParseStructuredOutputWithoutSchema(t *testing.T) {
+	result := &TurnResult{FinalText: `{"a":1}`}
+	if err := ParseStructuredOutput(&Turn{}, result); err != nil || result.ParsedOutput != nil {
+		t.Fatalf("expected no parsing without a schema, got %v %v", result.ParsedOutput, err)
+	}
+}
+
+func TestMockStreamAndCollectParsesOutput(t *testing.T) {
+	m := NewMock(MockConfig{Responses: [][]Event{{NewTextEvent(`{"name":"Ada",`), NewTextEvent(`"age":36}`)}}})
+	result, err := m.StreamAndCollect(context.Background(), schemaTurn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, ok := result.ParsedOutput.(map[string]any)
+	if !ok || obj["name"] != "Ada" {
+		t.Fatalf("unexpected parsed output %#v", result.ParsedOutput)
+	}
+}
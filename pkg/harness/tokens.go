@@ -0,0 +1,38 @@
+package harness
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// charsPerToken is the rough ratio used when no tokenizer is available.
+const charsPerToken = 4
+
+// EstimateTokens approximates the token count of text at four characters per
+// token. Harnesses whose provider has no tokenizer or counting API use it for
+// CountTokens.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// EstimateTurnTokens applies EstimateTokens to everything in turn the model
+// reads: instructions, messages, tool definitions and the response schema.
+// Prompt scaffolding a harness adds on top is not included.
+func EstimateTurnTokens(turn *Turn) int {
+	if turn == nil {
+		return 0
+	}
+	n := EstimateTokens(turn.Instructions)
+	for _, msg := range turn.Messages {
+		n += EstimateTokens(msg.Content)
+	}
+	if len(turn.Tools) > 0 {
+		if raw, err := json.Marshal(turn.Tools); err == nil {
+			n += EstimateTokens(string(raw))
+		}
+	}
+	if turn.ResponseSchema != nil {
+		n += EstimateTokens(string(*turn.ResponseSchema))
+	}
+	return n
+}
@@ -0,0 +1,40 @@
+package harness
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	cases := map[string]int{
+		"":          0,
+		"abc":       1,
+		"abcd":      1,
+		"abcde":     2,
+		"héllo wör": 3, // counted in characters, not bytes
+	}
+	for text, want := range cases {
+		if got := EstimateTokens(text); got != want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestEstimateTurnTokens(t *testing.T) {
+	schema := json.RawMessage(`{"type":"object"}`) // 17 chars
+	turn := &Turn{
+		Instructions:   "12345678",                                                     // 2
+		Messages:       []Message{{Role: "user", Content: "1234"}, {Content: "12345"}}, // 1 + 2
+		ResponseSchema: &schema,                                                        // 5
+	}
+	if got := EstimateTurnTokens(turn); got != 10 {
+		t.Fatalf("EstimateTurnTokens = %d, want 10", got)
+	}
+	turn.Tools = []ToolSpec{{Name: "shell"}}
+	if got := EstimateTurnTokens(turn); got <= 10 {
+		t.Fatalf("tools should add to the estimate, got %d", got)
+	}
+	if EstimateTurnTokens(nil) != 0 {
+		t.Fatal("nil turn should be 0")
+	}
+}
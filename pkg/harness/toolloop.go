@@ -2,13 +2,42 @@ package harness
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 )
 
+// defaultMaxParallelToolCalls caps concurrency when LoopOptions leaves it unset.
+const defaultMaxParallelToolCalls = 5
+
+// ErrLoopTimeout is returned by RunToolLoop when LoopOptions.MaxDuration is
+// reached. It does not wrap context.DeadlineExceeded, so callers can tell
+// the loop's own limit apart from a deadline or cancellation on their ctx.
+var ErrLoopTimeout = errors.New("tool loop exceeded max duration")
+
+// withLoopDeadline applies opts.MaxDuration to ctx.
+func withLoopDeadline(ctx context.Context, opts LoopOptions) (context.Context, context.CancelFunc) {
+	if opts.MaxDuration <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, opts.MaxDuration)
+}
+
+// loopError maps an error caused by the MaxDuration deadline on loopCtx to
+// ErrLoopTimeout. Errors from the caller's own ctx pass through unchanged.
+func loopError(ctx, loopCtx context.Context, err error, opts LoopOptions) error {
+	if err != nil && ctx.Err() == nil && errors.Is(loopCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w (%s)", ErrLoopTimeout, opts.MaxDuration)
+	}
+	return err
+}
+
 // RunToolLoop is the generic agentic tool loop shared by all harnesses.
 // It calls StreamTurn, collects tool calls, executes them via handler,
 // builds follow-up messages, and repeats until no tool calls remain or
-// max turns is reached.
+// max turns is reached. If opts.MaxDuration is reached first it returns
+// ErrLoopTimeout together with the partial result collected so far.
 func RunToolLoop(
 	ctx context.Context,
 	streamTurn func(ctx context.Context, turn *Turn, onEvent func(Event) error) error,
@@ -22,11 +51,13 @@ func RunToolLoop(
 	if maxTurns <= 0 {
 		maxTurns = 10
 	}
+	loopCtx, cancel := withLoopDeadline(ctx, opts)
+	defer cancel()
 
 	currentTurn := turn
 	for i := 0; i < maxTurns; i++ {
 		var pendingCalls []ToolCallEvent
-		err := streamTurn(ctx, currentTurn, func(ev Event) error {
+		err := streamTurn(loopCtx, currentTurn, func(ev Event) error {
 			combined.Events = append(combined.Events, ev)
 			if opts.OnEvent != nil {
 				if err := opts.OnEvent(ev); err != nil {
@@ -43,6 +74,11 @@ func RunToolLoop(
 				}
 			case EventUsage:
 				combined.Usage = ev.Usage
+			case EventDone:
+				if ev.Done != nil {
+					combined.SystemFingerprint = ev.Done.SystemFingerprint
+					combined.ResponseID = ev.Done.ResponseID
+				}
 			case EventToolCall:
 				if ev.ToolCall != nil {
 					pendingCalls = append(pendingCalls, *ev.ToolCall)
@@ -53,7 +89,7 @@ func RunToolLoop(
 		})
 		if err != nil {
 			combined.Duration = time.Since(start)
-			return combined, err
+			return combined, loopError(ctx, loopCtx, err, opts)
 		}
 
 		if len(pendingCalls) == 0 {
@@ -61,20 +97,23 @@ func RunToolLoop(
 		}
 
 		// Execute tools and build follow-up messages
+		results := executeToolCalls(loopCtx, handler, pendingCalls, opts)
+		if err := loopCtx.Err(); err != nil {
+			// Handlers failed because the loop ended, not on their own.
+			combined.Duration = time.Since(start)
+			return combined, loopError(ctx, loopCtx, err, opts)
+		}
 		followupMsgs := make([]Message, 0, len(pendingCalls)*2)
-		for _, call := range pendingCalls {
-			result, err := handler.Handle(ctx, call)
-			if err != nil {
-				combined.Duration = time.Since(start)
-				return combined, err
-			}
-			if result != nil {
-				ev := NewToolResultEvent(result.CallID, result.Output, result.IsError)
+		for i, call := range pendingCalls {
+			var output string
+			if result := results[i]; result != nil {
+				output = result.Output
+				ev := NewToolResultEvent(call.CallID, result.Output, result.IsError)
 				combined.Events = append(combined.Events, ev)
 			}
 			followupMsgs = append(followupMsgs,
 				Message{Role: "assistant", Content: call.Arguments, Name: call.Name, ToolID: call.CallID},
-				Message{Role: "tool", Content: result.Output, ToolID: call.CallID},
+				Message{Role: "tool", Content: output, ToolID: call.CallID},
 			)
 		}
 
@@ -84,5 +123,48 @@ func RunToolLoop(
 	}
 
 	combined.Duration = time.Since(start)
+	_ = ParseStructuredOutput(turn, combined)
 	return combined, nil
 }
+
+// executeToolCalls runs calls through handler and returns their results
+// in call order, so each result reaches its own call however the calls
+// were scheduled, even when call_ids repeat. A handler error becomes a
+// failed result for that call, serially and in parallel alike.
+func executeToolCalls(ctx context.Context, handler ToolHandler, calls []ToolCallEvent, opts LoopOptions) []*ToolResultEvent {
+	results := make([]*ToolResultEvent, len(calls))
+	if !opts.ParallelToolCalls || len(calls) < 2 {
+		for i, call := range calls {
+			results[i] = handleToolCall(ctx, handler, call)
+		}
+		return results
+	}
+
+	limit := opts.MaxParallelToolCalls
+	if limit <= 0 {
+		limit = defaultMaxParallelToolCalls
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, call ToolCallEvent) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = handleToolCall(ctx, handler, call)
+		}(i, call)
+	}
+	wg.Wait()
+	return results
+}
+
+// handleToolCall runs one call, turning a handler error into a failed
+// result.
+func handleToolCall(ctx context.Context, handler ToolHandler, call ToolCallEvent) *ToolResultEvent {
+	result, err := handler.Handle(ctx, call)
+	if err != nil {
+		return &ToolResultEvent{CallID: call.CallID, Output: err.Error(), IsError: true}
+	}
+	return result
+}
@@ -3,7 +3,9 @@ package harness
 import (
 	"context"
 	"errors"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestRunToolLoop_NoToolCalls(t *testing.T) {
@@ -79,17 +81,68 @@ func TestRunToolLoop_StreamError(t *testing.T) {
 
 func TestRunToolLoop_ToolHandlerError(t *testing.T) {
 	mock := NewMock(MockConfig{
+		Record: true,
 		Responses: [][]Event{
 			{NewToolCallEvent("c1", "shell", "{}"), NewDoneEvent()},
+			{NewTextEvent("recovered"), NewDoneEvent()},
 		},
 	})
 
-	handlerErr := errors.New("tool failed")
-	handler := &errorHandler{err: handlerErr}
+	handler := &errorHandler{err: errors.New("tool failed")}
 
-	_, err := RunToolLoop(context.Background(), mock.StreamTurn, &Turn{}, handler, LoopOptions{MaxTurns: 5})
-	if !errors.Is(err, handlerErr) {
-		t.Errorf("expected handler error, got %v", err)
+	// A serial handler error is a failed result, as on the parallel path.
+	result, err := RunToolLoop(context.Background(), mock.StreamTurn, &Turn{}, handler, LoopOptions{MaxTurns: 5})
+	if err != nil {
+		t.Fatalf("expected handler error to be reported as a result, got %v", err)
+	}
+	if result.FinalText != "recovered" {
+		t.Errorf("expected 'recovered', got %q", result.FinalText)
+	}
+	turns := mock.Recorded()
+	if len(turns) != 2 {
+		t.Fatalf("expected 2 turns, got %d", len(turns))
+	}
+	last := turns[1].Messages[len(turns[1].Messages)-1]
+	if last.Role != "tool" || last.ToolID != "c1" || last.Content != "tool failed" {
+		t.Errorf("tool message = %+v", last)
+	}
+	var failed bool
+	for _, ev := range result.Events {
+		if ev.Kind == EventToolResult && ev.ToolResult.CallID == "c1" && ev.ToolResult.IsError {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Error("expected failed tool result event for c1")
+	}
+}
+
+func TestRunToolLoop_DuplicateCallIDs(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		mock := NewMock(MockConfig{
+			Record: true,
+			Responses: [][]Event{
+				{
+					NewToolCallEvent("dup", "first", "{}"),
+					NewToolCallEvent("dup", "second", "{}"),
+					NewDoneEvent(),
+				},
+				{NewTextEvent("done"), NewDoneEvent()},
+			},
+		})
+		handler := &echoHandler{}
+		if _, err := RunToolLoop(context.Background(), mock.StreamTurn, &Turn{}, handler, LoopOptions{MaxTurns: 5, ParallelToolCalls: parallel}); err != nil {
+			t.Fatalf("parallel=%v: %v", parallel, err)
+		}
+		var got []string
+		for _, m := range mock.Recorded()[1].Messages {
+			if m.Role == "tool" {
+				got = append(got, m.Content)
+			}
+		}
+		if len(got) != 2 || got[0] != "ran first" || got[1] != "ran second" {
+			t.Errorf("parallel=%v: tool messages = %q, want each call's own result in order", parallel, got)
+		}
 	}
 }
 
@@ -163,3 +216,157 @@ func (h *errorHandler) Handle(_ context.Context, call ToolCallEvent) (*ToolResul
 }
 
 func (h *errorHandler) Available() []ToolSpec { return nil }
+
+// echoHandler answers each call with its tool name.
+type echoHandler struct{}
+
+func (echoHandler) Handle(_ context.Context, call ToolCallEvent) (*ToolResultEvent, error) {
+	return &ToolResultEvent{CallID: call.CallID, Output: "ran " + call.Name}, nil
+}
+
+func (echoHandler) Available() []ToolSpec { return nil }
+
+// slowHandler sleeps per call and tracks peak concurrency. Calls named
+// "fail" return an error.
+type slowHandler struct {
+	delay   time.Duration
+	mu      sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (h *slowHandler) Handle(_ context.Context, call ToolCallEvent) (*ToolResultEvent, error) {
+	h.mu.Lock()
+	h.active++
+	if h.active > h.maxSeen {
+		h.maxSeen = h.active
+	}
+	h.mu.Unlock()
+	time.Sleep(h.delay)
+	h.mu.Lock()
+	h.active--
+	h.mu.Unlock()
+	if call.Name == "fail" {
+		return nil, errors.New("boom")
+	}
+	return &ToolResultEvent{CallID: call.CallID, Output: "out-" + call.CallID}, nil
+}
+
+func (h *slowHandler) Available() []ToolSpec { return nil }
+
+func TestRunToolLoop_ParallelToolCalls(t *testing.T) {
+	mock := NewMock(MockConfig{
+		Record: true,
+		Responses: [][]Event{
+			{
+				NewToolCallEvent("c1", "shell", "{}"),
+				NewToolCallEvent("c2", "fail", "{}"),
+				NewToolCallEvent("c3", "shell", "{}"),
+				NewToolCallEvent("c4", "shell", "{}"),
+				NewDoneEvent(),
+			},
+			{NewTextEvent("done"), NewDoneEvent()},
+		},
+	})
+	handler := &slowHandler{delay: 20 * time.Millisecond}
+
+	result, err := RunToolLoop(context.Background(), mock.StreamTurn, &Turn{}, handler, LoopOptions{
+		MaxTurns:             5,
+		ParallelToolCalls:    true,
+		MaxParallelToolCalls: 2,
+	})
+	if err != nil {
+		t.Fatalf("expected tool error to be reported as a result, got %v", err)
+	}
+	if result.FinalText != "done" {
+		t.Errorf("expected 'done', got %q", result.FinalText)
+	}
+	if handler.maxSeen != 2 {
+		t.Errorf("expected concurrency capped at 2, saw %d", handler.maxSeen)
+	}
+
+	// Follow-up tool messages keep call order, with the failure inline.
+	turns := mock.Recorded()
+	if len(turns) != 2 {
+		t.Fatalf("expected 2 turns, got %d", len(turns))
+	}
+	var toolMsgs []Message
+	for _, m := range turns[1].Messages {
+		if m.Role == "tool" {
+			toolMsgs = append(toolMsgs, m)
+		}
+	}
+	want := []string{"out-c1", "boom", "out-c3", "out-c4"}
+	if len(toolMsgs) != len(want) {
+		t.Fatalf("expected %d tool messages, got %d", len(want), len(toolMsgs))
+	}
+	for i, m := range toolMsgs {
+		if m.Content != want[i] {
+			t.Errorf("tool message %d = %q, want %q", i, m.Content, want[i])
+		}
+	}
+	var failed bool
+	for _, ev := range result.Events {
+		if ev.Kind == EventToolResult && ev.ToolResult.CallID == "c2" && ev.ToolResult.IsError {
+			failed = true
+		}
+	}
+	if !failed {
+		t.Error("expected failed tool result event for c2")
+	}
+}
+
+// blockingHandler waits for ctx to end, like a tool that outlives the loop.
+type blockingHandler struct{}
+
+func (blockingHandler) Handle(ctx context.Context, _ ToolCallEvent) (*ToolResultEvent, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (blockingHandler) Available() []ToolSpec { return nil }
+
+func TestRunToolLoop_MaxDuration(t *testing.T) {
+	streamTurn := func(ctx context.Context, _ *Turn, onEvent func(Event) error) error {
+		for _, ev := range []Event{NewTextEvent("working"), NewToolCallEvent("c1", "shell", `{}`), NewUsageEvent(7, 3)} {
+			if err := onEvent(ev); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	start := time.Now()
+	result, err := RunToolLoop(context.Background(), streamTurn, &Turn{}, blockingHandler{}, LoopOptions{MaxDuration: 20 * time.Millisecond})
+	if !errors.Is(err, ErrLoopTimeout) {
+		t.Fatalf("expected ErrLoopTimeout, got %v", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("ErrLoopTimeout must be distinguishable from context.DeadlineExceeded")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("loop was not stopped at MaxDuration")
+	}
+	if result == nil || result.FinalText != "working" || len(result.ToolCalls) != 1 || result.Usage == nil {
+		t.Fatalf("partial result not returned: %+v", result)
+	}
+
+	// Cancellation of the caller's ctx is reported as such.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err = RunToolLoop(ctx, streamTurn, &Turn{}, blockingHandler{}, LoopOptions{MaxDuration: time.Minute})
+	if !errors.Is(err, context.Canceled) || errors.Is(err, ErrLoopTimeout) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestMockRunToolLoop_MaxDuration(t *testing.T) {
+	mock := NewMock(MockConfig{Responses: [][]Event{{NewToolCallEvent("c1", "shell", `{}`)}}})
+	_, err := mock.RunToolLoop(context.Background(), &Turn{}, blockingHandler{}, LoopOptions{MaxDuration: 10 * time.Millisecond})
+	if !errors.Is(err, ErrLoopTimeout) {
+		t.Fatalf("expected ErrLoopTimeout, got %v", err)
+	}
+}
@@ -15,6 +15,22 @@ type ResponsesRequest struct {
 	Include           []string            `json:"include,omitempty"`
 	PromptCacheKey    string              `json:"prompt_cache_key,omitempty"`
 	Text              *TextControls       `json:"text,omitempty"`
+	Temperature       *float64            `json:"temperature,omitempty"`
+	TopP              *float64            `json:"top_p,omitempty"`
+	// PresencePenalty is only forwarded by Chat Completions backends.
+	PresencePenalty *float64 `json:"presence_penalty,omitempty"`
+	// Seed is only forwarded by Chat Completions backends.
+	Seed *int64 `json:"seed,omitempty"`
+	// StopSequences is sent as stop by Chat Completions backends.
+	StopSequences []string `json:"stop_sequences,omitempty"`
+	// MaxOutputTokens caps the reply; sent as max_tokens by Chat
+	// Completions backends.
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
+	// TopLogprobs turns on per-token log probabilities with this many
+	// alternatives; only forwarded by Chat Completions backends.
+	TopLogprobs int `json:"top_logprobs,omitempty"`
+	// Metadata tags a stored response.
+	Metadata map[string]string `json:"metadata,omitempty"`
 }
 
 type Reasoning struct {
@@ -29,6 +45,7 @@ type TextControls struct {
 
 type TextFormat struct {
 	Type   string          `json:"type"`
+	Name   string          `json:"name,omitempty"`
 	Strict bool            `json:"strict,omitempty"`
 	Schema json.RawMessage `json:"schema,omitempty"`
 }
@@ -78,11 +95,26 @@ type StreamEvent struct {
 	Name     string       `json:"name,omitempty"`
 	Arguments string      `json:"arguments,omitempty"`
 	Message  string       `json:"message,omitempty"`
+	// Logprobs accompanies response.output_text.delta when requested.
+	Logprobs []LogProb `json:"logprobs,omitempty"`
+}
+
+// LogProb is a token's log probability and its most likely alternatives.
+type LogProb struct {
+	Token       string    `json:"token"`
+	Logprob     float64   `json:"logprob"`
+	TopLogprobs []LogProb `json:"top_logprobs,omitempty"`
 }
 
 type ResponseRef struct {
 	ID    string `json:"id,omitempty"`
 	Usage *Usage `json:"usage,omitempty"`
+	// SystemFingerprint is reported by Chat Completions backends.
+	SystemFingerprint string `json:"system_fingerprint,omitempty"`
+	// StopReason is "stop_sequence" when a stop sequence ended the
+	// response; StopSequence is the match, when known.
+	StopReason   string `json:"stop_reason,omitempty"`
+	StopSequence string `json:"stop_sequence,omitempty"`
 }
 
 type Usage struct {
@@ -99,6 +131,8 @@ type OutputItem struct {
 	Arguments string `json:"arguments,omitempty"`
 	Status    string `json:"status,omitempty"`
 	Output    string `json:"output,omitempty"`
+	// Summary holds the summary_text parts of a reasoning item.
+	Summary []ContentPart `json:"summary,omitempty"`
 }
 
 type ContentPart struct {
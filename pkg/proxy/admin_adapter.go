@@ -1,13 +1,79 @@
 package proxy
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"time"
 
 	"godex/pkg/admin"
 )
 
 type adminAdapter struct {
-	keys *KeyStore
+	keys   *KeyStore
+	stats  *liveStats
+	logger *Logger
+	server *Server
+}
+
+// AdminUsage serves GET /admin/usage from the stats file, as GET /v1/usage
+// does.
+func (a adminAdapter) AdminUsage(since time.Duration, keyID string) ([]admin.UsageReport, error) {
+	rows, err := a.server.usageReports(since, keyID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]admin.UsageReport, len(rows))
+	for i, row := range rows {
+		out[i] = admin.UsageReport(row)
+	}
+	return out, nil
+}
+
+// AdminConfig serves GET /admin/config from Config.AdminConfig.
+func (a adminAdapter) AdminConfig() any {
+	if a.server.cfg.AdminConfig == nil {
+		return map[string]any{}
+	}
+	return a.server.cfg.AdminConfig()
+}
+
+// AdminBackends serves GET /admin/backends with the probes of
+// GET /v1/backends, sharing their cache.
+func (a adminAdapter) AdminBackends(ctx context.Context) []admin.BackendStatus {
+	statuses := a.server.backendStatuses(ctx)
+	out := make([]admin.BackendStatus, len(statuses))
+	for i, st := range statuses {
+		out[i] = admin.BackendStatus(st)
+	}
+	return out
+}
+
+// RecentLogs serves GET /admin/logs from the logger's recent lines.
+func (a adminAdapter) RecentLogs(seq uint64) ([]admin.LogEntry, <-chan struct{}) {
+	lines, changed := a.logger.Recent(seq)
+	entries := make([]admin.LogEntry, len(lines))
+	for i, l := range lines {
+		entries[i] = admin.LogEntry(l)
+	}
+	return entries, changed
+}
+
+// AdminStats reports the counters behind GET /v1/stats/stream.
+func (a adminAdapter) AdminStats() admin.StatsTotals {
+	snap := a.stats.snapshot(time.Now())
+	totals := admin.StatsTotals{
+		Active:    snap.ActiveConnections,
+		Requests:  snap.RequestsTotal,
+		Errors:    snap.ErrorsTotal,
+		TokensIn:  snap.TokensInTotal,
+		TokensOut: snap.TokensOutTotal,
+		Backends:  make(map[string]admin.BackendStats, len(snap.Backends)),
+	}
+	for name, b := range snap.Backends {
+		totals.Backends[name] = admin.BackendStats(b)
+	}
+	return totals
 }
 
 func (a adminAdapter) Add(label, rate string, burst int, quota int64, providedKey string, ttl time.Duration) (admin.KeyInfo, string, error) {
@@ -18,6 +84,60 @@ func (a adminAdapter) Add(label, rate string, burst int, quota int64, providedKe
 	return admin.KeyInfo{ID: rec.ID, TokenBalance: rec.TokenBalance, TokenAllowance: rec.TokenAllowance, AllowanceDurationSec: rec.AllowanceDurationSec}, secret, nil
 }
 
+// BulkKeys applies ops in one KeyTx, rolling all of them back if any
+// fails or the save does.
+func (a adminAdapter) BulkKeys(ops []admin.KeyOp) ([]admin.KeyOpResult, error) {
+	results := make([]admin.KeyOpResult, len(ops))
+	for i, op := range ops {
+		results[i] = admin.KeyOpResult{Index: i, Op: op.Op, Status: admin.KeyOpStatusSkipped}
+	}
+	tx := a.keys.BeginTx()
+	var failure error
+	for i, op := range ops {
+		res := &results[i]
+		var err error
+		switch op.Op {
+		case admin.KeyOpAdd:
+			var rec KeyRecord
+			rec, res.APIKey, err = tx.Add(op.Label, op.Rate, op.Burst, op.Quota, "", 0)
+			res.KeyID = rec.ID
+		case admin.KeyOpRevoke:
+			res.KeyID = op.ID
+			if _, ok := tx.Revoke(op.ID); !ok {
+				err = errors.New("key not found")
+			}
+		case admin.KeyOpUpdate:
+			res.KeyID = op.ID
+			_, err = tx.Update(op.ID, op.Label, op.Rate, op.Burst, op.Quota, 0, nil)
+		default:
+			err = fmt.Errorf("unknown op %q", op.Op)
+		}
+		if err != nil {
+			res.Status = admin.KeyOpStatusError
+			res.Error = err.Error()
+			res.APIKey = ""
+			failure = fmt.Errorf("operation %d (%s): %w", i, op.Op, err)
+			break
+		}
+		res.Status = admin.KeyOpStatusOK
+	}
+	if failure == nil {
+		failure = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if failure != nil {
+		for i := range results {
+			if results[i].Status == admin.KeyOpStatusOK {
+				results[i].Status = admin.KeyOpStatusRolledBack
+				results[i].APIKey = ""
+			}
+		}
+		return results, failure
+	}
+	return results, nil
+}
+
 func (a adminAdapter) SetTokenPolicy(id string, balance int64, allowance int64, duration time.Duration) (admin.KeyInfo, error) {
 	rec, err := a.keys.SetTokenPolicy(id, balance, allowance, duration)
 	if err != nil {
@@ -19,12 +19,14 @@ type AuditLogger struct {
 type AuditEntry struct {
 	Timestamp  string          `json:"ts"`
 	RequestID  string          `json:"request_id,omitempty"`
+	JobID      string          `json:"job_id,omitempty"`
 	KeyID      string          `json:"key_id,omitempty"`
 	KeyLabel   string          `json:"key_label,omitempty"`
 	Method     string          `json:"method"`
 	Path       string          `json:"path"`
 	Model      string          `json:"model,omitempty"`
 	Backend    string          `json:"backend,omitempty"`
+	BackendOverride string     `json:"backend_override,omitempty"`
 	Status     int             `json:"status"`
 	ElapsedMs  int64           `json:"elapsed_ms"`
 	InputItems int             `json:"input_items,omitempty"`
@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"godex/pkg/auth"
+	"godex/pkg/circuitbreaker"
+	"godex/pkg/harness"
+)
+
+const (
+	// backendProbeTimeout bounds each backend probe in GET /v1/backends.
+	backendProbeTimeout = 5 * time.Second
+	// backendProbeTTL is how long a probe result is reused, so polling
+	// GET /v1/backends does not send a turn to every backend each time.
+	backendProbeTTL    = 30 * time.Second
+	defaultProbePrompt = "ping"
+)
+
+// probeCache holds the latest probe result of each backend. The zero value
+// is ready to use.
+type probeCache struct {
+	mu      sync.Mutex
+	results map[string]cachedProbe
+}
+
+type cachedProbe struct {
+	status BackendStatus
+	at     time.Time
+}
+
+// get returns name's cached result if it is younger than backendProbeTTL.
+func (c *probeCache) get(name string) (BackendStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.results[name]
+	if !ok || time.Since(p.at) >= backendProbeTTL {
+		return BackendStatus{}, false
+	}
+	return p.status, true
+}
+
+func (c *probeCache) put(name string, st BackendStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results == nil {
+		c.results = make(map[string]cachedProbe)
+	}
+	c.results[name] = cachedProbe{status: st, at: time.Now()}
+}
+
+// BackendStatus describes one registered harness for GET /v1/backends.
+type BackendStatus struct {
+	Name      string                   `json:"name"`
+	Model     string                   `json:"model,omitempty"`
+	Status    string                   `json:"status"`
+	LatencyMs int64                    `json:"latency_ms"`
+	LastError string                   `json:"last_error,omitempty"`
+	Breaker   *circuitbreaker.Snapshot `json:"circuit_breaker,omitempty"`
+}
+
+// handleBackends handles GET /v1/backends. Each backend is probed with a
+// minimal turn so operators can check reachability and credentials; a probe
+// result is reused for backendProbeTTL.
+func (s *Server) handleBackends(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	key, ok := s.requireAuth(w, r, auth.ScopeRead)
+	if !ok {
+		return
+	}
+	if ok, _ := s.allowRequest(w, r, key); !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		s.logRequest(r, http.StatusMethodNotAllowed, start)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   s.backendStatuses(requestContext(r)),
+	})
+	s.logRequest(r, http.StatusOK, start)
+}
+
+// backendStatuses probes every registered backend concurrently.
+func (s *Server) backendStatuses(ctx context.Context) []BackendStatus {
+	if s.harnessRouter == nil {
+		return []BackendStatus{}
+	}
+	names := s.harnessRouter.List()
+	data := make([]BackendStatus, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			data[i] = s.probeBackend(ctx, name, s.harnessRouter.Get(name))
+		}(i, name)
+	}
+	wg.Wait()
+	return data
+}
+
+// probeBackend sends the probe prompt to the backend's first listed model,
+// unless a recent result is cached. The outcome is reported to the
+// backend's circuit breaker; a probe cut short because the client went away
+// is neither reported nor cached.
+func (s *Server) probeBackend(ctx context.Context, name string, h harness.Harness) BackendStatus {
+	br := s.breakers[name]
+	if st, ok := s.probes.get(name); ok {
+		if br != nil {
+			snap := br.Snapshot()
+			st.Breaker = &snap
+		}
+		return st
+	}
+	st := BackendStatus{Name: name, Status: "ok"}
+	ctx, cancel := context.WithTimeout(ctx, backendProbeTimeout)
+	defer cancel()
+	probeStart := time.Now()
+	err := func() error {
+		if h == nil {
+			return errors.New("backend not registered")
+		}
+		models, err := h.ListModels(ctx)
+		if err != nil {
+			return err
+		}
+		if len(models) == 0 {
+			return errors.New("backend lists no models")
+		}
+		st.Model = models[0].ID
+		prompt := strings.TrimSpace(s.cfg.ProbePrompt)
+		if prompt == "" {
+			prompt = defaultProbePrompt
+		}
+		_, err = h.StreamAndCollect(ctx, &harness.Turn{
+			Model:        st.Model,
+			Instructions: "Reply with a single word.",
+			Messages:     []harness.Message{{Role: "user", Content: prompt}},
+		})
+		return err
+	}()
+	st.LatencyMs = time.Since(probeStart).Milliseconds()
+	canceled := errors.Is(err, context.Canceled)
+	if err != nil {
+		st.Status = "error"
+		st.LastError = err.Error()
+	}
+	if br != nil && !canceled {
+		if err != nil {
+			br.Failure(err)
+		} else {
+			br.Success()
+		}
+	}
+	if !canceled {
+		s.probes.put(name, st)
+	}
+	if br != nil {
+		snap := br.Snapshot()
+		st.Breaker = &snap
+	}
+	return st
+}
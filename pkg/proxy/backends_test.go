@@ -0,0 +1,123 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"godex/pkg/harness"
+	"godex/pkg/router"
+)
+
+func TestBackendsProbe(t *testing.T) {
+	healthy := harness.NewMock(harness.MockConfig{
+		HarnessName: "healthy",
+		Record:      true,
+		Models:      []harness.ModelInfo{{ID: "gpt-5"}},
+		Responses:   [][]harness.Event{{harness.NewTextEvent("pong")}},
+	})
+	// broken has no scripted responses, so the probe fails.
+	broken := harness.NewMock(harness.MockConfig{
+		HarnessName: "broken",
+		Models:      []harness.ModelInfo{{ID: "claude-sonnet-4-5"}},
+	})
+	r := router.New(router.Config{})
+	r.Register("healthy", healthy)
+	r.Register("broken", broken)
+
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true, ProbePrompt: "are you there?"},
+		harnessRouter: r,
+		limiters:      NewLimiterStore("60/m", 10),
+		breakers:      newBreakers(CircuitBreakerConfig{FailureThreshold: 1, RecoveryWindow: time.Hour}, r),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/backends", nil)
+	req.Header.Set("Authorization", "Bearer test")
+	rr := httptest.NewRecorder()
+	srv.handleBackends(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var body struct {
+		Data []BackendStatus `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	got := map[string]BackendStatus{}
+	for _, st := range body.Data {
+		got[st.Name] = st
+	}
+	if st := got["healthy"]; st.Status != "ok" || st.Model != "gpt-5" || st.LastError != "" {
+		t.Fatalf("unexpected healthy status: %+v", st)
+	}
+	if st := got["broken"]; st.Status != "error" || st.LastError == "" {
+		t.Fatalf("unexpected broken status: %+v", st)
+	}
+	if st := got["broken"]; st.Breaker == nil || st.Breaker.State != "open" {
+		t.Fatalf("expected failed probe to open breaker: %+v", st.Breaker)
+	}
+
+	turns := healthy.Recorded()
+	if len(turns) != 1 || turns[0].Messages[0].Content != "are you there?" {
+		t.Fatalf("expected probe prompt to be sent, got %+v", turns)
+	}
+
+	// A second request within the TTL reuses the cached results.
+	rr = httptest.NewRecorder()
+	srv.handleBackends(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if turns := healthy.Recorded(); len(turns) != 1 {
+		t.Fatalf("expected the cached probe to be reused, got %d probes", len(turns))
+	}
+}
+
+// waitingHarness blocks each turn until its context ends.
+type waitingHarness struct{ *harness.Mock }
+
+func (w waitingHarness) StreamAndCollect(ctx context.Context, _ *harness.Turn) (*harness.TurnResult, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestBackendsProbeCancelled(t *testing.T) {
+	h := waitingHarness{harness.NewMock(harness.MockConfig{
+		HarnessName: "slow",
+		Models:      []harness.ModelInfo{{ID: "gpt-5"}},
+	})}
+	r := router.New(router.Config{})
+	r.Register("slow", h)
+	srv := &Server{
+		harnessRouter: r,
+		breakers:      newBreakers(CircuitBreakerConfig{FailureThreshold: 1, RecoveryWindow: time.Hour}, r),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	st := srv.probeBackend(ctx, "slow", h)
+	if st.Status != "error" {
+		t.Fatalf("expected the cancelled probe to fail, got %+v", st)
+	}
+	if st.Breaker == nil || st.Breaker.State != "closed" {
+		t.Fatalf("a cancelled probe must not trip the breaker: %+v", st.Breaker)
+	}
+	if _, ok := srv.probes.get("slow"); ok {
+		t.Fatal("a cancelled probe must not be cached")
+	}
+}
+
+func TestBackendsRequiresAuth(t *testing.T) {
+	srv := &Server{limiters: NewLimiterStore("60/m", 10)}
+	rr := httptest.NewRecorder()
+	srv.handleBackends(rr, httptest.NewRequest(http.MethodGet, "/v1/backends", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"godex/pkg/circuitbreaker"
+	"godex/pkg/harness"
+	"godex/pkg/router"
+)
+
+// CircuitBreakerConfig configures per-backend circuit breakers. A zero
+// FailureThreshold disables them.
+type CircuitBreakerConfig struct {
+	FailureThreshold int
+	RecoveryWindow   time.Duration
+}
+
+// newBreakers creates one breaker per harness registered with r. While a
+// breaker is open its backend is marked unhealthy in r, so the router stops
+// offering it; once the recovery window has passed it is offered again for
+// the half-open probe, and a success marks it healthy.
+func newBreakers(cfg CircuitBreakerConfig, r *router.Router) map[string]*circuitbreaker.Breaker {
+	names := r.List()
+	if cfg.FailureThreshold <= 0 || len(names) == 0 {
+		return nil
+	}
+	recovery := cfg.RecoveryWindow
+	if recovery <= 0 {
+		recovery = circuitbreaker.DefaultRecoveryWindow
+	}
+	out := make(map[string]*circuitbreaker.Breaker, len(names))
+	for _, name := range names {
+		out[name] = circuitbreaker.New(circuitbreaker.Config{
+			FailureThreshold: cfg.FailureThreshold,
+			RecoveryWindow:   recovery,
+			OnStateChange: func(_, to circuitbreaker.State) {
+				switch to {
+				case circuitbreaker.Open:
+					r.MarkUnhealthy(name)
+					time.AfterFunc(recovery, func() { r.MarkHealthy(name) })
+				case circuitbreaker.Closed:
+					r.MarkHealthy(name)
+				}
+			},
+		})
+	}
+	return out
+}
+
+// breakerStates returns a snapshot of every breaker keyed by harness name.
+func (s *Server) breakerStates() map[string]circuitbreaker.Snapshot {
+	if len(s.breakers) == 0 {
+		return nil
+	}
+	out := make(map[string]circuitbreaker.Snapshot, len(s.breakers))
+	for name, br := range s.breakers {
+		out[name] = br.Snapshot()
+	}
+	return out
+}
+
+// breakerHarness reports the outcome of each call to its breaker. Only
+// upstream results count: a call ended by the caller's onEvent (e.g. a
+// client disconnect) or by cancelling its context is reported neither as
+// a success nor as a failure, so a client going away cannot close a
+// half-open breaker.
+type breakerHarness struct {
+	harness.Harness
+	breaker *circuitbreaker.Breaker
+}
+
+func (b *breakerHarness) StreamTurn(ctx context.Context, turn *harness.Turn, onEvent func(harness.Event) error) error {
+	var callbackErr error
+	err := b.Harness.StreamTurn(ctx, turn, func(ev harness.Event) error {
+		if err := onEvent(ev); err != nil {
+			callbackErr = err
+			return err
+		}
+		return nil
+	})
+	if callbackErr != nil && errors.Is(err, callbackErr) {
+		return err
+	}
+	b.report(ctx, err)
+	return err
+}
+
+func (b *breakerHarness) StreamAndCollect(ctx context.Context, turn *harness.Turn) (*harness.TurnResult, error) {
+	result, err := b.Harness.StreamAndCollect(ctx, turn)
+	b.report(ctx, err)
+	return result, err
+}
+
+// BatchTurns runs the batch on the wrapped harness, so native batch APIs
+// still apply, and reports each turn's outcome to the breaker.
+func (b *breakerHarness) BatchTurns(ctx context.Context, turns []*harness.Turn, onResult func(index int, result *harness.TurnResult, err error), opts harness.LoopOptions) error {
+	return b.Harness.BatchTurns(ctx, turns, func(index int, result *harness.TurnResult, err error) {
+		b.report(ctx, err)
+		if onResult != nil {
+			onResult(index, result, err)
+		}
+	}, opts)
+}
+
+func (b *breakerHarness) report(ctx context.Context, err error) {
+	switch {
+	case err == nil:
+		b.breaker.Success()
+	case ctx.Err() != nil:
+		// Client went away; says nothing about backend health.
+	default:
+		b.breaker.Failure(err)
+	}
+}
@@ -0,0 +1,212 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"godex/pkg/circuitbreaker"
+	"godex/pkg/harness"
+	"godex/pkg/router"
+)
+
+func TestCircuitBreakerFallsBackToNextBackend(t *testing.T) {
+	// primary has no scripted responses, so every call fails.
+	primary := harness.NewMock(harness.MockConfig{HarnessName: "primary"})
+	secondary := harness.NewMock(harness.MockConfig{
+		HarnessName: "secondary",
+		Responses: [][]harness.Event{
+			{harness.NewTextEvent("from secondary")},
+			{harness.NewTextEvent("from secondary")},
+		},
+	})
+	r := router.New(router.Config{
+		UserPatterns: map[string][]string{
+			"primary":   {"gpt-"},
+			"secondary": {"gpt-"},
+		},
+	})
+	r.Register("primary", primary)
+	r.Register("secondary", secondary)
+
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+		breakers:      newBreakers(CircuitBreakerConfig{FailureThreshold: 1, RecoveryWindow: time.Hour}, r),
+	}
+
+	send := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(OpenAIChatRequest{
+			Model:    "gpt-5",
+			Messages: []OpenAIChatMessage{{Role: "user", Content: "hi"}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test")
+		w := httptest.NewRecorder()
+		srv.handleChatCompletions(w, req)
+		return w
+	}
+
+	if w := send(); w.Code != http.StatusBadGateway {
+		t.Fatalf("expected first request to hit failing primary (502), got %d", w.Code)
+	}
+	if got := srv.breakers["primary"].State().String(); got != "open" {
+		t.Fatalf("expected primary breaker open, got %s", got)
+	}
+	if r.Healthy("primary") {
+		t.Fatal("expected the open breaker to mark primary unhealthy in the router")
+	}
+
+	w := send()
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected fallback to secondary, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp OpenAIChatResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "from secondary" {
+		t.Fatalf("unexpected content %q", resp.Choices[0].Message.Content)
+	}
+	// Each request counts once, for the backend that served it.
+	if stats := r.Stats(); stats["primary"] != 1 || stats["secondary"] != 1 {
+		t.Fatalf("expected one pick each, got %v", stats)
+	}
+
+	rr := httptest.NewRecorder()
+	srv.handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var health struct {
+		CircuitBreakers map[string]struct {
+			State string `json:"state"`
+		} `json:"circuit_breakers"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &health); err != nil {
+		t.Fatalf("decode health: %v", err)
+	}
+	if health.CircuitBreakers["primary"].State != "open" || health.CircuitBreakers["secondary"].State != "closed" {
+		t.Fatalf("unexpected breaker states in /health: %s", rr.Body.String())
+	}
+}
+
+func TestOpenBreakerReturnsBackendForProbe(t *testing.T) {
+	r := router.New(router.Config{})
+	r.Register("primary", harness.NewMock(harness.MockConfig{HarnessName: "primary"}))
+	breakers := newBreakers(CircuitBreakerConfig{FailureThreshold: 1, RecoveryWindow: 20 * time.Millisecond}, r)
+	br := breakers["primary"]
+
+	br.Failure(errors.New("down"))
+	if r.Healthy("primary") {
+		t.Fatal("expected primary unhealthy while its breaker is open")
+	}
+	deadline := time.Now().Add(time.Second)
+	for !r.Healthy("primary") {
+		if time.Now().After(deadline) {
+			t.Fatal("expected primary offered again after the recovery window")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if !br.Allow() {
+		t.Fatal("expected the half-open probe to be admitted")
+	}
+	br.Failure(errors.New("still down"))
+	if r.Healthy("primary") {
+		t.Fatal("expected a failed probe to mark primary unhealthy again")
+	}
+	br.Success()
+	if !r.Healthy("primary") {
+		t.Fatal("expected a success to mark primary healthy")
+	}
+}
+
+func TestBreakerHarnessBatchTurns(t *testing.T) {
+	// One scripted response: the first turn succeeds, the second fails.
+	mock := harness.NewMock(harness.MockConfig{
+		Responses: [][]harness.Event{{harness.NewTextEvent("ok")}},
+	})
+	br := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, RecoveryWindow: time.Hour})
+	bh := &breakerHarness{Harness: mock, breaker: br}
+	var reported int
+	err := bh.BatchTurns(context.Background(), []*harness.Turn{{}, {}}, func(int, *harness.TurnResult, error) {
+		reported++
+	}, harness.LoopOptions{MaxConcurrency: 1})
+	if err != nil || reported != 2 {
+		t.Fatalf("BatchTurns = %v with %d results, want 2", err, reported)
+	}
+	if got := br.State().String(); got != "open" {
+		t.Fatalf("expected the failed turn to open the breaker, got %s", got)
+	}
+}
+
+func TestBreakerHarnessIgnoresClientErrors(t *testing.T) {
+	mock := harness.NewMock(harness.MockConfig{
+		Responses: [][]harness.Event{{harness.NewTextEvent("a")}, {harness.NewTextEvent("b")}},
+	})
+	br := circuitbreaker.New(circuitbreaker.Config{FailureThreshold: 1, RecoveryWindow: time.Millisecond})
+	bh := &breakerHarness{Harness: mock, breaker: br}
+
+	// Trip the breaker and let it go half-open with a probe in flight.
+	br.Failure(errors.New("upstream down"))
+	time.Sleep(2 * time.Millisecond)
+	if !br.Allow() {
+		t.Fatal("expected a half-open probe to be admitted")
+	}
+
+	// The client rejects the first event: neither success nor failure.
+	err := bh.StreamTurn(context.Background(), &harness.Turn{}, func(harness.Event) error {
+		return context.Canceled
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("StreamTurn = %v, want context.Canceled", err)
+	}
+	if got := br.State(); got != circuitbreaker.HalfOpen {
+		t.Fatalf("client callback error moved the breaker to %s", got)
+	}
+
+	// A cancelled request context does not count either.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bh.StreamTurn(ctx, &harness.Turn{}, func(harness.Event) error { return ctx.Err() })
+	if got := br.State(); got != circuitbreaker.HalfOpen {
+		t.Fatalf("cancelled context moved the breaker to %s", got)
+	}
+}
+
+func TestBackendOverrideHonorsOpenBreaker(t *testing.T) {
+	r := router.New(router.Config{UserPatterns: map[string][]string{"primary": {"gpt-"}}})
+	r.Register("primary", harness.NewMock(harness.MockConfig{
+		HarnessName: "primary",
+		Responses:   [][]harness.Event{{harness.NewTextEvent("from primary")}},
+	}))
+	r.Register("debug", harness.NewMock(harness.MockConfig{HarnessName: "debug"}))
+	srv := &Server{
+		cfg:           Config{AllowBackendOverride: true},
+		harnessRouter: r,
+		breakers:      newBreakers(CircuitBreakerConfig{FailureThreshold: 1, RecoveryWindow: time.Hour}, r),
+	}
+
+	h := srv.harnessForModel(context.Background(), "", "gpt-5", "debug", harness.CapabilitySet{})
+	if overrideOf(h) != "debug" {
+		t.Fatalf("expected the debug override, got %q", overrideOf(h))
+	}
+	if err := h.StreamTurn(context.Background(), &harness.Turn{}, func(harness.Event) error { return nil }); err == nil {
+		t.Fatal("expected the unscripted debug backend to fail")
+	}
+	if got := srv.breakers["debug"].State().String(); got != "open" {
+		t.Fatalf("expected the override's failure to open its breaker, got %s", got)
+	}
+
+	h = srv.harnessForModel(context.Background(), "", "gpt-5", "debug", harness.CapabilitySet{})
+	if overrideOf(h) != "" || h.Name() != "primary" {
+		t.Fatalf("expected routing to primary while debug's breaker is open, got %s (override %q)", h.Name(), overrideOf(h))
+	}
+}
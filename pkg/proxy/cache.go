@@ -33,6 +33,17 @@ func NewCache(ttl time.Duration) *Cache {
 	return &Cache{ttl: ttl, entries: map[string]*cacheEntry{}}
 }
 
+// SetTTL changes how long idle entries are kept, defaulting as NewCache
+// does. Entries already cached are judged by the new TTL.
+func (c *Cache) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = 6 * time.Hour
+	}
+	c.mu.Lock()
+	c.ttl = ttl
+	c.mu.Unlock()
+}
+
 func HashInstructions(instructions string) string {
 	h := sha256.Sum256([]byte(instructions))
 	return hex.EncodeToString(h[:])
@@ -17,11 +17,11 @@ type chatCallInfo struct {
 
 func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	requestID := newResponseID("pxreq")
+	requestID, _ := harness.RequestID(r.Context())
 	var req OpenAIChatRequest
-	if err := readJSON(r, &req); err != nil {
+	if err := readJSON(r, &req, s.cfg.MaxRequestBytes); err != nil {
 		s.traceMessage(requestID, "proxy", "in", "/v1/chat/completions", "openclaw_request_decode_error", err.Error())
-		writeError(w, http.StatusBadRequest, err)
+		writeError(w, readJSONStatus(err), err)
 		return
 	}
 	if rawReq, err := json.Marshal(req); err == nil {
@@ -43,6 +43,9 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if !s.allowModel(w, key, req.Model) {
+		return
+	}
 	sessionKey := s.sessionKey(req.User, r)
 	items := make([]OpenAIItem, 0, len(req.Messages)*2) // May expand due to tool_calls
 	for _, msg := range req.Messages {
@@ -75,6 +78,9 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 			items = append(items, OpenAIItem{Type: "message", Role: msg.Role, Content: msg.Content})
 		}
 	}
+	if !s.moderate(w, r, requestID, items) {
+		return
+	}
 	input, system, err := buildSystemAndInput(sessionKey, items, s.cache)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err)
@@ -86,11 +92,12 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 	_, tools = resolveToolChoice(req.ToolChoice, tools)
 
 	// Try harness-based routing first
-	if h := s.harnessForModel(req.Model); h != nil {
+	if h := s.harnessForModel(r.Context(), sessionKey, req.Model, s.backendOverride(r), requiredCapabilities(items)); h != nil {
 		turn := buildTurnFromChat(req.Model, instructions, input, tools)
 		if rawTurn, err := json.Marshal(turn); err == nil {
 			s.tracePayload(requestID, "proxy_harness", "out", "/v1/chat/completions", "harness_turn", json.RawMessage(rawTurn))
 		}
+		s.logTokenEstimate(r.Context(), h, turn, requestID)
 		if !req.Stream {
 			result, err := h.StreamAndCollect(requestContext(r), turn)
 			if err != nil {
@@ -108,7 +115,7 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 				s.tracePayload(requestID, "proxy_openclaw", "out", "/v1/chat/completions", "json.response", json.RawMessage(rawResp))
 			}
 			writeJSON(w, http.StatusOK, resp)
-			s.recordUsage(r, key, http.StatusOK, nil)
+			s.recordUsage(r, key, req.Model, http.StatusOK, harnessUsage(result.Usage))
 			return
 		}
 
@@ -120,6 +127,10 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 			writeError(w, http.StatusInternalServerError, errNoFlusher)
 			return
 		}
+		defer s.trackStream()()
+		var stopHeartbeat func()
+		w, flusher, stopHeartbeat = s.startHeartbeat(w, flusher)
+		defer stopHeartbeat()
 		if err := s.harnessChatStream(requestContext(r), w, flusher, h, turn, req.Model, key, start, sessionKey, requestID); err != nil {
 			s.traceMessage(requestID, "proxy", "out", "/v1/chat/completions", "stream_error", err.Error())
 			_ = writeSSE(w, flusher, map[string]any{
@@ -132,7 +143,7 @@ func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	writeError(w, http.StatusBadRequest, fmt.Errorf("model %q not available", req.Model))
+	writeError(w, http.StatusBadRequest, noHarnessError(req.Model, requiredCapabilities(items)))
 }
 
 // harnessResultToChatResponse converts a harness.TurnResult to OpenAI chat response.
@@ -0,0 +1,332 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"godex/pkg/harness"
+	"godex/pkg/protocol"
+)
+
+// handleCompletions serves the legacy /v1/completions endpoint. The prompt is
+// sent as a single user message through the same routing as chat completions
+// and the reply is returned as text_completion objects. Every request is
+// logged with the status it got, or 502 if its stream failed.
+func (s *Server) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w}
+	w = rec
+	streamFailed := false
+	defer func() {
+		status := rec.status
+		if streamFailed {
+			status = http.StatusBadGateway
+		}
+		s.logRequest(r, status, start)
+	}()
+	requestID, _ := harness.RequestID(r.Context())
+	var req OpenAICompletionRequest
+	if err := readJSON(r, &req, s.cfg.MaxRequestBytes); err != nil {
+		s.traceMessage(requestID, "proxy", "in", "/v1/completions", "openclaw_request_decode_error", err.Error())
+		writeError(w, readJSONStatus(err), err)
+		return
+	}
+	if rawReq, err := json.Marshal(req); err == nil {
+		s.tracePayload(requestID, "proxy", "in", "/v1/completions", "openclaw_request", json.RawMessage(rawReq))
+	}
+	prompt, err := completionPrompt(req.Prompt)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	stops, err := completionStops(req.Stop)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.MaxTokens != nil && *req.MaxTokens < 1 {
+		writeError(w, http.StatusBadRequest, errors.New("max_tokens must be at least 1"))
+		return
+	}
+	modelEntry, ok := s.resolveModel(req.Model)
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("model %q not available", req.Model))
+		return
+	}
+	req.Model = modelEntry.ID
+	key, ok := s.requireAuthOrPayment(w, r, req.Model)
+	if !ok {
+		return
+	}
+	if ok, reason := s.allowRequest(w, r, key); !ok {
+		if reason == "tokens" {
+			_ = s.issuePaymentChallenge(w, r, "topup", key.ID, req.Model)
+		}
+		return
+	}
+	if !s.allowModel(w, key, req.Model) {
+		return
+	}
+	sessionKey := s.sessionKey(req.User, r)
+
+	h := s.harnessForModel(r.Context(), sessionKey, req.Model, "", harness.CapabilitySet{})
+	if h == nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("model %q not available", req.Model))
+		return
+	}
+	turn := &harness.Turn{
+		Model:        req.Model,
+		Instructions: s.resolveInstructions(sessionKey, ""),
+		Messages:     []harness.Message{{Role: "user", Content: prompt}},
+	}
+	if req.MaxTokens != nil {
+		turn.MaxOutputTokens = *req.MaxTokens
+	}
+	if rawTurn, err := json.Marshal(turn); err == nil {
+		s.tracePayload(requestID, "proxy_harness", "out", "/v1/completions", "harness_turn", json.RawMessage(rawTurn))
+	}
+	s.logTokenEstimate(r.Context(), h, turn, requestID)
+
+	if !req.Stream {
+		result, err := h.StreamAndCollect(requestContext(r), turn)
+		if err != nil {
+			s.traceMessage(requestID, "proxy_harness", "in", "/v1/completions", "stream_and_collect_error", err.Error())
+			writeError(w, http.StatusBadGateway, err)
+			return
+		}
+		text, _ := truncateAtStop(result.FinalText, stops)
+		finish := "stop"
+		usage := harnessUsage(result.Usage)
+		resp := OpenAICompletionResponse{
+			ID:      newResponseID("cmpl"),
+			Object:  "text_completion",
+			Created: time.Now().Unix(),
+			Model:   req.Model,
+			Choices: []OpenAICompletionChoice{{Text: text, FinishReason: &finish}},
+		}
+		if usage != nil {
+			resp.Usage = &OpenAIUsage{
+				PromptTokens:     usage.InputTokens,
+				CompletionTokens: usage.OutputTokens,
+				TotalTokens:      usage.InputTokens + usage.OutputTokens,
+			}
+		}
+		if rawResp, err := json.Marshal(resp); err == nil {
+			s.tracePayload(requestID, "proxy_openclaw", "out", "/v1/completions", "json.response", json.RawMessage(rawResp))
+		}
+		writeJSON(w, http.StatusOK, resp)
+		s.recordUsage(r, key, req.Model, http.StatusOK, usage)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errNoFlusher)
+		return
+	}
+	defer s.trackStream()()
+	var stopHeartbeat func()
+	w, flusher, stopHeartbeat = s.startHeartbeat(w, flusher)
+	defer stopHeartbeat()
+	if err := s.harnessCompletionStream(requestContext(r), w, flusher, h, turn, req.Model, key, start, stops, requestID); err != nil {
+		streamFailed = true
+		s.traceMessage(requestID, "proxy", "out", "/v1/completions", "stream_error", err.Error())
+		_ = writeSSE(w, flusher, map[string]any{
+			"type":    "error",
+			"message": err.Error(),
+		})
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+		flusher.Flush()
+	}
+}
+
+// harnessCompletionStream streams a harness turn as text_completion chunks.
+// Text after a stop sequence is dropped, but the turn runs to completion so
+// usage is still recorded.
+func (s *Server) harnessCompletionStream(
+	ctx context.Context,
+	w http.ResponseWriter,
+	flusher http.Flusher,
+	h harness.Harness,
+	turn *harness.Turn,
+	model string,
+	key *KeyRecord,
+	start time.Time,
+	stops []string,
+	requestID string,
+) error {
+	chunkID := newResponseID("cmpl")
+	created := time.Now().Unix()
+	matcher := newStopMatcher(stops)
+	var usage *protocol.Usage
+
+	writeText := func(text string, finish *string) error {
+		if text == "" && finish == nil {
+			return nil
+		}
+		chunk := OpenAICompletionResponse{
+			ID:      chunkID,
+			Object:  "text_completion",
+			Created: created,
+			Model:   model,
+			Choices: []OpenAICompletionChoice{{Text: text, FinishReason: finish}},
+		}
+		s.tracePayload(requestID, "proxy_openclaw", "out", "/v1/completions", "sse.completion.delta", chunk)
+		return writeSSE(w, flusher, chunk)
+	}
+
+	err := h.StreamTurn(ctx, turn, func(ev harness.Event) error {
+		if rawEv, err := json.Marshal(ev); err == nil {
+			s.tracePayload(requestID, "proxy_harness", "in", "/v1/completions", "harness.event", json.RawMessage(rawEv))
+		}
+		switch ev.Kind {
+		case harness.EventText:
+			if ev.Text == nil || ev.Text.Delta == "" {
+				return nil
+			}
+			return writeText(matcher.push(ev.Text.Delta), nil)
+		case harness.EventUsage:
+			if ev.Usage != nil {
+				usage = &protocol.Usage{
+					InputTokens:  ev.Usage.InputTokens,
+					OutputTokens: ev.Usage.OutputTokens,
+					CachedTokens: ev.Usage.CachedTokens,
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	finish := "stop"
+	_ = writeText(matcher.flush(), &finish)
+	_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	flusher.Flush()
+
+	s.recordUsage(nil, key, model, http.StatusOK, usage)
+	s.recordMetric(h.Name(), model, start, "ok", "", usage)
+	return nil
+}
+
+// completionPrompt accepts a string or a single-element string array.
+func completionPrompt(raw any) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case []any:
+		if len(v) != 1 {
+			return "", errors.New("prompt arrays must contain exactly one string")
+		}
+		if s, ok := v[0].(string); ok {
+			return s, nil
+		}
+	case nil:
+		return "", errors.New("prompt is required")
+	}
+	return "", errors.New("prompt must be a string")
+}
+
+// completionStops accepts a string or an array of up to four strings.
+func completionStops(raw any) ([]string, error) {
+	var stops []string
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		stops = []string{v}
+	case []any:
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, errors.New("stop must be a string or an array of strings")
+			}
+			stops = append(stops, s)
+		}
+	default:
+		return nil, errors.New("stop must be a string or an array of strings")
+	}
+	if len(stops) > harness.MaxStopSequences {
+		return nil, fmt.Errorf("stop accepts at most %d sequences", harness.MaxStopSequences)
+	}
+	out := stops[:0]
+	for _, s := range stops {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
+
+// truncateAtStop cuts text at the earliest stop sequence.
+func truncateAtStop(text string, stops []string) (string, bool) {
+	cut := -1
+	for _, stop := range stops {
+		if i := strings.Index(text, stop); i >= 0 && (cut < 0 || i < cut) {
+			cut = i
+		}
+	}
+	if cut < 0 {
+		return text, false
+	}
+	return text[:cut], true
+}
+
+// stopMatcher applies stop sequences to streamed text, holding back just
+// enough of the tail to catch a sequence split across deltas.
+type stopMatcher struct {
+	stops   []string
+	hold    int
+	buf     string
+	stopped bool
+}
+
+func newStopMatcher(stops []string) *stopMatcher {
+	m := &stopMatcher{stops: stops}
+	for _, s := range stops {
+		if len(s)-1 > m.hold {
+			m.hold = len(s) - 1
+		}
+	}
+	return m
+}
+
+// push adds a delta and returns the text that is safe to emit.
+func (m *stopMatcher) push(delta string) string {
+	if m.stopped {
+		return ""
+	}
+	m.buf += delta
+	if text, ok := truncateAtStop(m.buf, m.stops); ok {
+		m.stopped = true
+		m.buf = ""
+		return text
+	}
+	cut := len(m.buf) - m.hold
+	for cut > 0 && cut < len(m.buf) && !utf8.RuneStart(m.buf[cut]) {
+		cut--
+	}
+	if cut <= 0 {
+		return ""
+	}
+	out := m.buf[:cut]
+	m.buf = m.buf[cut:]
+	return out
+}
+
+// flush returns any held-back text once the stream has ended.
+func (m *stopMatcher) flush() string {
+	out := m.buf
+	m.buf = ""
+	return out
+}
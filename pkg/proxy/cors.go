@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	corsAllowMethods  = "GET, POST, OPTIONS"
+	corsExposeHeaders = "X-Request-ID, X-RateLimit-Limit, X-RateLimit-Remaining, X-RateLimit-Reset, Retry-After"
+)
+
+// defaultCORSAllowHeaders are accepted on preflight when the config does not
+// list any.
+var defaultCORSAllowHeaders = []string{"Authorization", "Content-Type", "X-Request-ID"}
+
+// corsMiddleware adds CORS headers for browser clients. With no allowed
+// origins it returns next unchanged.
+func corsMiddleware(origins, headers []string, next http.Handler) http.Handler {
+	origins = corsList(origins)
+	if len(origins) == 0 {
+		return next
+	}
+	allowHeaders := corsList(headers)
+	if len(allowHeaders) == 0 {
+		allowHeaders = defaultCORSAllowHeaders
+	}
+	wildcard := false
+	allowed := map[string]bool{}
+	for _, o := range origins {
+		if o == "*" {
+			wildcard = true
+		}
+		allowed[o] = true
+	}
+	headerList := strings.Join(allowHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		h := w.Header()
+		switch {
+		case wildcard:
+			h.Set("Access-Control-Allow-Origin", "*")
+		case origin != "" && allowed[origin]:
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Add("Vary", "Origin")
+		}
+		if h.Get("Access-Control-Allow-Origin") != "" {
+			h.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			h.Set("Access-Control-Allow-Headers", headerList)
+			h.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		}
+		if r.Method == http.MethodOptions {
+			if origin != "" && h.Get("Access-Control-Allow-Origin") == "" {
+				// A preflight from an origin we do not serve.
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsList trims configured origins or headers, splitting comma-separated
+// entries and dropping blanks.
+func corsList(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
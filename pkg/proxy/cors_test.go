@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func TestCORSDisabledWithoutOrigins(t *testing.T) {
+	h := corsMiddleware(nil, nil, okHandler())
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected no CORS headers, got %q", got)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	h := corsMiddleware([]string{"https://app.example.com"}, nil, okHandler())
+	req := httptest.NewRequest(http.MethodOptions, "/v1/responses", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("unexpected allow-origin %q", got)
+	}
+	methods := rr.Header().Get("Access-Control-Allow-Methods")
+	if !strings.Contains(methods, "GET") || !strings.Contains(methods, "POST") {
+		t.Fatalf("unexpected allow-methods %q", methods)
+	}
+	if !strings.Contains(rr.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+		t.Fatalf("expected Authorization in allow-headers")
+	}
+}
+
+func TestCORSPreflightRejectsUnlistedOrigin(t *testing.T) {
+	h := corsMiddleware([]string{"https://app.example.com"}, nil, okHandler())
+	req := httptest.NewRequest(http.MethodOptions, "/v1/responses", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rr.Code)
+	}
+	for _, name := range []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods", "Access-Control-Max-Age"} {
+		if got := rr.Header().Get(name); got != "" {
+			t.Errorf("expected no %s, got %q", name, got)
+		}
+	}
+}
+
+func TestCORSList(t *testing.T) {
+	got := corsList([]string{" https://a.example.com ", "", "https://b.example.com,https://c.example.com"})
+	want := []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("corsList = %q, want %q", got, want)
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	h := corsMiddleware([]string{"https://app.example.com"}, []string{"Authorization", "X-Custom"}, okHandler())
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected handler to run, got %d", rr.Code)
+	}
+	expose := rr.Header().Get("Access-Control-Expose-Headers")
+	if !strings.Contains(expose, "X-Request-ID") || !strings.Contains(expose, "X-RateLimit-Remaining") {
+		t.Fatalf("unexpected expose-headers %q", expose)
+	}
+	if got := rr.Header().Get("Access-Control-Allow-Headers"); got != "Authorization, X-Custom" {
+		t.Fatalf("unexpected allow-headers %q", got)
+	}
+
+	// Origins outside the list get no CORS headers.
+	req = httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("expected no allow-origin for unlisted origin, got %q", got)
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	h := corsMiddleware([]string{"*"}, nil, okHandler())
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	req.Header.Set("Origin", "https://anything.example.com")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("expected wildcard, got %q", got)
+	}
+}
@@ -5,10 +5,12 @@ package proxy
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -17,6 +19,15 @@ import (
 	"godex/pkg/router"
 )
 
+// interruptedMarker is streamed as text when the upstream stream breaks off
+// (harness.ErrPartialResponse), so clients can tell a cut-off reply from a
+// finished one.
+const interruptedMarker = "\n\n[generation interrupted]"
+
+// backendOverrideHeader names the backend for one request when
+// Config.AllowBackendOverride is set.
+const backendOverrideHeader = "X-Godex-Backend"
+
 // harnessResponsesStream handles a streaming /v1/responses request via harness.
 // It translates harness.Event back to the Codex-format SSE that clients expect.
 func (s *Server) harnessResponsesStream(
@@ -61,7 +72,7 @@ func (s *Server) harnessResponsesStream(
 	// Track whether we've started a text output item
 	textItemStarted := false
 
-	err := h.StreamTurn(ctx, turn, func(ev harness.Event) error {
+	onEvent := func(ev harness.Event) error {
 		if rawEv, err := json.Marshal(ev); err == nil {
 			s.tracePayload(requestID, "proxy_harness", "in", "/v1/responses", "harness.event", json.RawMessage(rawEv))
 		}
@@ -116,7 +127,7 @@ func (s *Server) harnessResponsesStream(
 			tc := ev.ToolCall
 			normalizeExecToolCall(turn, tc)
 			if tc.Name == "exec" {
-				log.Printf("[INFO] emitting exec tool call stream call_id=%s args=%s", tc.CallID, tc.Arguments)
+				log.Printf("[INFO] emitting exec tool call stream request_id=%s call_id=%s args=%s", requestID, tc.CallID, tc.Arguments)
 			}
 			// If we had a text item, close it and advance
 			if textItemStarted {
@@ -188,6 +199,7 @@ func (s *Server) harnessResponsesStream(
 				usage = &protocol.Usage{
 					InputTokens:  ev.Usage.InputTokens,
 					OutputTokens: ev.Usage.OutputTokens,
+					CachedTokens: ev.Usage.CachedTokens,
 				}
 			}
 
@@ -239,8 +251,17 @@ func (s *Server) harnessResponsesStream(
 			// Plan updates are harness-internal and are not emitted over proxy SSE.
 		}
 		return nil
-	})
+	}
 
+	err := h.StreamTurn(ctx, turn, onEvent)
+	if errors.Is(err, harness.ErrPartialResponse) {
+		// Keep what was streamed, mark the cut and complete the response.
+		log.Printf("[WARN] harness stream interrupted request_id=%s: %v", requestID, err)
+		if err := onEvent(harness.NewTextEvent(interruptedMarker)); err != nil {
+			return err
+		}
+		err = onEvent(harness.NewDoneEvent())
+	}
 	if err != nil {
 		return err
 	}
@@ -249,7 +270,7 @@ func (s *Server) harnessResponsesStream(
 	s.cache.SaveToolCalls(sessionKey, toolCalls)
 
 	// Record usage
-	s.recordUsage(nil, key, http.StatusOK, usage)
+	s.recordUsage(nil, key, model, http.StatusOK, usage)
 
 	// Audit log
 	if s.audit != nil {
@@ -258,6 +279,7 @@ func (s *Server) harnessResponsesStream(
 			toolNames = append(toolNames, tc.Name)
 		}
 		entry := AuditEntry{
+			RequestID:     requestID,
 			KeyID:         key.ID,
 			KeyLabel:      key.Label,
 			Method:        "POST",
@@ -269,6 +291,7 @@ func (s *Server) harnessResponsesStream(
 			ToolCallNames: toolNames,
 			OutputText:    outputText,
 		}
+		entry.BackendOverride = overrideOf(h)
 		if usage != nil {
 			entry.TokensIn = usage.InputTokens
 			entry.TokensOut = usage.OutputTokens
@@ -433,12 +456,34 @@ func (s *Server) harnessResponsesNonStream(
 	sessionKey string,
 	requestID string,
 ) {
-	result, err := h.StreamAndCollect(ctx, turn)
+	resp, err := s.collectResponses(ctx, h, turn, model, key, start, auditReq, sessionKey, requestID, "")
 	if err != nil {
-		s.traceMessage(requestID, "proxy_harness", "in", "/v1/responses", "stream_and_collect_error", err.Error())
 		writeError(w, http.StatusBadGateway, err)
 		return
 	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// collectResponses runs a turn to completion and builds the non-streamed
+// /v1/responses body, recording usage and audit along the way. jobID is set
+// for webhook deliveries.
+func (s *Server) collectResponses(
+	ctx context.Context,
+	h harness.Harness,
+	turn *harness.Turn,
+	model string,
+	key *KeyRecord,
+	start time.Time,
+	auditReq json.RawMessage,
+	sessionKey string,
+	requestID string,
+	jobID string,
+) (OpenAIResponsesResponse, error) {
+	result, err := h.StreamAndCollect(ctx, turn)
+	if err != nil {
+		s.traceMessage(requestID, "proxy_harness", "in", "/v1/responses", "stream_and_collect_error", err.Error())
+		return OpenAIResponsesResponse{}, err
+	}
 
 	// Build tool calls cache
 	calls := map[string]ToolCall{}
@@ -447,7 +492,7 @@ func (s *Server) harnessResponsesNonStream(
 		normalizeExecToolCall(turn, &local)
 		tc = local
 		if tc.Name == "exec" {
-			log.Printf("[INFO] emitting exec tool call nonstream call_id=%s args=%s", tc.CallID, tc.Arguments)
+			log.Printf("[INFO] emitting exec tool call nonstream request_id=%s call_id=%s args=%s", requestID, tc.CallID, tc.Arguments)
 		}
 		calls[tc.CallID] = ToolCall{Name: tc.Name, Arguments: tc.Arguments}
 	}
@@ -485,8 +530,7 @@ func (s *Server) harnessResponsesNonStream(
 		s.tracePayload(requestID, "proxy_openclaw", "out", "/v1/responses", "json.response", json.RawMessage(rawResp))
 	}
 
-	writeJSON(w, http.StatusOK, resp)
-	s.recordUsage(nil, key, http.StatusOK, nil)
+	s.recordUsage(nil, key, model, http.StatusOK, harnessUsage(result.Usage))
 
 	// Audit
 	if s.audit != nil {
@@ -495,6 +539,8 @@ func (s *Server) harnessResponsesNonStream(
 			toolNames = append(toolNames, tc.Name)
 		}
 		entry := AuditEntry{
+			RequestID:     requestID,
+			JobID:         jobID,
 			KeyID:         key.ID,
 			KeyLabel:      key.Label,
 			Method:        "POST",
@@ -506,6 +552,7 @@ func (s *Server) harnessResponsesNonStream(
 			ToolCallNames: toolNames,
 			OutputText:    result.FinalText,
 		}
+		entry.BackendOverride = overrideOf(h)
 		if result.Usage != nil {
 			entry.TokensIn = result.Usage.InputTokens
 			entry.TokensOut = result.Usage.OutputTokens
@@ -513,6 +560,7 @@ func (s *Server) harnessResponsesNonStream(
 		entry.Request = auditReq
 		s.audit.Log(entry)
 	}
+	return resp, nil
 }
 
 // harnessChatStream handles a streaming /v1/chat/completions request via harness.
@@ -536,7 +584,7 @@ func (s *Server) harnessChatStream(
 	toolCalls := map[string]ToolCall{}
 	var usage *protocol.Usage
 
-	err := h.StreamTurn(ctx, turn, func(ev harness.Event) error {
+	onEvent := func(ev harness.Event) error {
 		if rawEv, err := json.Marshal(ev); err == nil {
 			s.tracePayload(requestID, "proxy_harness", "in", "/v1/chat/completions", "harness.event", json.RawMessage(rawEv))
 		}
@@ -569,7 +617,7 @@ func (s *Server) harnessChatStream(
 			tc := ev.ToolCall
 			normalizeExecToolCall(turn, tc)
 			if tc.Name == "exec" {
-				log.Printf("[INFO] emitting exec tool call chat-stream call_id=%s args=%s", tc.CallID, tc.Arguments)
+				log.Printf("[INFO] emitting exec tool call chat-stream request_id=%s call_id=%s args=%s", requestID, tc.CallID, tc.Arguments)
 			}
 			sawTool = true
 			info, ok := callInfoMap[tc.CallID]
@@ -631,6 +679,7 @@ func (s *Server) harnessChatStream(
 				usage = &protocol.Usage{
 					InputTokens:  ev.Usage.InputTokens,
 					OutputTokens: ev.Usage.OutputTokens,
+					CachedTokens: ev.Usage.CachedTokens,
 				}
 			}
 
@@ -638,8 +687,13 @@ func (s *Server) harnessChatStream(
 			// Will send final chunk after StreamTurn returns
 		}
 		return nil
-	})
+	}
 
+	err := h.StreamTurn(ctx, turn, onEvent)
+	if errors.Is(err, harness.ErrPartialResponse) {
+		log.Printf("[WARN] harness stream interrupted request_id=%s: %v", requestID, err)
+		err = onEvent(harness.NewTextEvent(interruptedMarker))
+	}
 	if err != nil {
 		return err
 	}
@@ -666,7 +720,7 @@ func (s *Server) harnessChatStream(
 	_, _ = w.Write([]byte("data: [DONE]\n\n"))
 	flusher.Flush()
 
-	s.recordUsage(nil, key, http.StatusOK, usage)
+	s.recordUsage(nil, key, model, http.StatusOK, usage)
 	harnessName := h.Name()
 	s.recordMetric(harnessName, model, start, "ok", "", usage)
 
@@ -733,13 +787,147 @@ func buildTurnFromChat(model, instructions string, input []protocol.ResponseInpu
 }
 
 // harnessForModel returns the harness for a model from the harness router.
-// Returns nil if no harness router is configured or no match found.
-func (s *Server) harnessForModel(model string) harness.Harness {
+// Returns nil if no harness router is configured or no match found. When
+// circuit breakers are configured, backends with an open breaker are skipped
+// in favour of the router's next match. With sticky sessions enabled on the
+// router, sessionKey keeps a conversation on its first backend while that
+// backend stays available. Backends whose capabilities do not cover need
+// (e.g. vision for a request with images) are never chosen. The latency of
+// each successful turn is reported back to the router. A registered
+// override backend, from backendOverride, skips patterns, stickiness and
+// capabilities but not its circuit breaker: while that is open the override
+// is ignored.
+func (s *Server) harnessForModel(ctx context.Context, sessionKey, model, override string, need harness.CapabilitySet) harness.Harness {
+	var h harness.Harness
+	if override != "" && s.harnessRouter != nil && s.harnessRouter.Get(override) != nil {
+		h = s.overrideHarness(model, override)
+	}
+	if h == nil {
+		override = ""
+		h = s.selectHarness(ctx, sessionKey, model, need)
+	}
+	if h == nil {
+		return nil
+	}
+	sh := &statsHarness{Harness: h, counters: s.stats.backend(h.Name()), override: override}
+	base := h
+	if bh, ok := h.(*breakerHarness); ok {
+		base = bh.Harness
+	}
+	if name := s.harnessRouter.NameOf(base); name != "" {
+		sh.latency = func(d time.Duration) { s.harnessRouter.RecordLatency(name, d) }
+	}
+	return sh
+}
+
+// overrideHarness returns the registered backend override, wrapped in its
+// circuit breaker, or nil while that breaker rejects requests.
+func (s *Server) overrideHarness(model, override string) harness.Harness {
+	br := s.breakers[override]
+	if br != nil && !br.Allow() {
+		return nil
+	}
+	h := s.harnessRouter.HarnessForRequest(s.harnessRouter.ExpandAlias(model), override)
+	if br != nil {
+		return &breakerHarness{Harness: h, breaker: br}
+	}
+	return h
+}
+
+// backendOverride returns the backend named by the X-Godex-Backend header,
+// or "" unless Config.AllowBackendOverride is set.
+func (s *Server) backendOverride(r *http.Request) string {
+	if !s.cfg.AllowBackendOverride {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get(backendOverrideHeader))
+}
+
+// overrideOf returns the backend an X-Godex-Backend header forced h onto.
+func overrideOf(h harness.Harness) string {
+	if sh, ok := h.(*statsHarness); ok {
+		return sh.override
+	}
+	return ""
+}
+
+func (s *Server) selectHarness(ctx context.Context, sessionKey, model string, need harness.CapabilitySet) harness.Harness {
 	if s.harnessRouter == nil {
 		return nil
 	}
 	expanded := s.harnessRouter.ExpandAlias(model)
-	return s.harnessRouter.HarnessFor(expanded)
+	if len(s.breakers) == 0 {
+		return s.harnessRouter.HarnessWithCapabilities(ctx, sessionKey, expanded, need)
+	}
+	candidates := s.harnessRouter.CandidatesFor(ctx, expanded, need)
+	first, ok := s.harnessRouter.Pinned(sessionKey)
+	if !ok {
+		if canary, ok := s.harnessRouter.Canary(ctx, sessionKey, expanded, need); ok {
+			first = canary
+			if !slices.Contains(candidates, canary) {
+				candidates = append([]string{canary}, candidates...)
+			}
+		} else {
+			first = s.harnessRouter.Choose(candidates)
+		}
+	}
+	for i, name := range candidates {
+		if name == first {
+			candidates = append([]string{name}, append(candidates[:i:i], candidates[i+1:]...)...)
+			break
+		}
+	}
+	for _, name := range candidates {
+		h := s.harnessRouter.Get(name)
+		br := s.breakers[name]
+		if br == nil {
+			s.harnessRouter.Pin(sessionKey, name)
+			s.harnessRouter.RecordPick(name)
+			return h
+		}
+		if br.Allow() {
+			s.harnessRouter.Pin(sessionKey, name)
+			s.harnessRouter.RecordPick(name)
+			return &breakerHarness{Harness: h, breaker: br}
+		}
+	}
+	return nil
+}
+
+// requiredCapabilities derives what a backend must support to serve items.
+// Only vision is detected: any image content part requires it.
+func requiredCapabilities(items []OpenAIItem) harness.CapabilitySet {
+	for _, item := range items {
+		if hasImagePart(item.Content) {
+			return harness.CapabilitySet{SupportsVision: true}
+		}
+	}
+	return harness.CapabilitySet{}
+}
+
+// noHarnessError explains why no backend was found for model.
+func noHarnessError(model string, need harness.CapabilitySet) error {
+	if need.SupportsVision {
+		return fmt.Errorf("no backend for model %q supports image input", model)
+	}
+	return fmt.Errorf("model %q not available", model)
+}
+
+func hasImagePart(content any) bool {
+	switch v := content.(type) {
+	case []any:
+		for _, part := range v {
+			if hasImagePart(part) {
+				return true
+			}
+		}
+	case map[string]any:
+		switch v["type"] {
+		case "image_url", "input_image", "image":
+			return true
+		}
+	}
+	return false
 }
 
 // harnessModelInfo is analogous to backend.ModelInfo for the harness system.
@@ -754,7 +942,7 @@ func (s *Server) getCachedHarnessModels(ctx context.Context) []harnessModelInfo
 		return nil
 	}
 	// Use the same cache mechanism; harness models integrate with backend models
-	models := s.harnessRouter.AllModels(ctx)
+	models, _ := s.harnessRouter.AllModels(ctx, 0, 0)
 	result := make([]harnessModelInfo, len(models))
 	for i, m := range models {
 		result[i] = harnessModelInfo{ID: m.ID, DisplayName: m.Name}
@@ -1,14 +1,23 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"godex/pkg/harness"
+	"godex/pkg/harness/replay"
+	"godex/pkg/router"
 )
 
 func TestRepairEmptyExecArgs_BacktickCommand(t *testing.T) {
@@ -147,3 +156,250 @@ func TestHarnessResponsesStream_FunctionCallArgsDoneHasArguments(t *testing.T) {
 		t.Fatalf("arguments = %#v, want tool-call args", argsDone["arguments"])
 	}
 }
+
+// partialHarness streams some text and then fails like a dropped upstream
+// connection.
+type partialHarness struct {
+	harness.Harness
+}
+
+func (partialHarness) StreamTurn(ctx context.Context, turn *harness.Turn, onEvent func(harness.Event) error) error {
+	if err := onEvent(harness.NewTextEvent("half an ans")); err != nil {
+		return err
+	}
+	return fmt.Errorf("codex: %w: unexpected EOF", harness.ErrPartialResponse)
+}
+
+func TestHarnessStreams_PartialResponseIsMarked(t *testing.T) {
+	s := &Server{cache: NewCache(time.Hour)}
+	h := partialHarness{harness.NewMock(harness.MockConfig{})}
+	turn := &harness.Turn{Model: "gpt-5.3-codex"}
+
+	rr := httptest.NewRecorder()
+	if err := s.harnessResponsesStream(context.Background(), rr, rr, h, turn, "gpt-5.3-codex", nil, time.Now(), nil, "", "req_test"); err != nil {
+		t.Fatalf("harnessResponsesStream error: %v", err)
+	}
+	body := rr.Body.String()
+	if !strings.Contains(body, "half an ans") || !strings.Contains(body, "[generation interrupted]") {
+		t.Errorf("expected partial text and interruption marker, got:\n%s", body)
+	}
+	if !strings.Contains(body, `"type":"response.completed"`) {
+		t.Errorf("expected the response to be completed, got:\n%s", body)
+	}
+
+	rr = httptest.NewRecorder()
+	if err := s.harnessChatStream(context.Background(), rr, rr, h, turn, "gpt-5.3-codex", nil, time.Now(), "", "req_test"); err != nil {
+		t.Fatalf("harnessChatStream error: %v", err)
+	}
+	body = rr.Body.String()
+	marker := strings.Index(body, "[generation interrupted]")
+	done := strings.Index(body, "data: [DONE]")
+	if marker < 0 || done < 0 || marker > done {
+		t.Errorf("expected interruption marker before [DONE], got:\n%s", body)
+	}
+}
+
+func TestVisionRequestsRouteToCapableBackend(t *testing.T) {
+	textOnly := harness.NewMock(harness.MockConfig{
+		HarnessName:  "text",
+		Responses:    [][]harness.Event{{harness.NewTextEvent("from text")}},
+		Capabilities: harness.CapabilitySet{SupportsTools: true},
+	})
+	vision := harness.NewMock(harness.MockConfig{
+		HarnessName:  "vision",
+		Responses:    [][]harness.Event{{harness.NewTextEvent("from vision")}},
+		Capabilities: harness.CapabilitySet{SupportsVision: true, SupportsTools: true},
+	})
+	r := router.New(router.Config{UserPatterns: map[string][]string{
+		"text":   {"gpt-"},
+		"vision": {"gpt-"},
+	}})
+	r.Register("text", textOnly)
+	r.Register("vision", vision)
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+	}
+
+	send := func(content any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(OpenAIChatRequest{
+			Model:    "gpt-4o",
+			Messages: []OpenAIChatMessage{{Role: "user", Content: content}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test")
+		rr := httptest.NewRecorder()
+		srv.handleChatCompletions(rr, req)
+		return rr
+	}
+
+	rr := send([]any{
+		map[string]any{"type": "text", "text": "what is this?"},
+		map[string]any{"type": "image_url", "image_url": map[string]any{"url": "data:image/png;base64,AAAA"}},
+	})
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "from vision") {
+		t.Fatalf("expected the vision backend, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr = send("plain text")
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "from text") {
+		t.Fatalf("expected the first backend for text, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLogTokenEstimateOnlyAtDebug(t *testing.T) {
+	mock := harness.NewMock(harness.MockConfig{HarnessName: "mock"})
+	turn := &harness.Turn{Model: "m", Messages: []harness.Message{{Role: "user", Content: "12345678"}}}
+
+	var out lockedBuffer
+	s := &Server{logger: NewLogger(LogLevelDebug)}
+	s.logger.logger = log.New(&out, "", 0)
+	s.logTokenEstimate(context.Background(), mock, turn, "req-1")
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(out.String(), "token estimate") && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := out.String(); !strings.Contains(got, "[DEBUG] token estimate") || !strings.Contains(got, "input_tokens=2") {
+		t.Fatalf("unexpected debug output %q", got)
+	}
+
+	var quiet lockedBuffer
+	s = &Server{logger: NewLogger(LogLevelInfo)}
+	s.logger.logger = log.New(&quiet, "", 0)
+	s.logTokenEstimate(context.Background(), mock, turn, "req-2")
+	time.Sleep(20 * time.Millisecond)
+	if quiet.String() != "" {
+		t.Fatalf("expected no output at info level, got %q", quiet.String())
+	}
+}
+
+func TestChatCompletionsFromReplayedRecording(t *testing.T) {
+	var recording strings.Builder
+	for _, ev := range []harness.Event{harness.NewTextEvent("recorded "), harness.NewTextEvent("reply"), harness.NewUsageEvent(9, 2), harness.NewDoneEvent()} {
+		line, _ := json.Marshal(ev)
+		recording.Write(append(line, '\n'))
+	}
+	rep, err := replay.New(strings.NewReader(recording.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := router.New(router.Config{UserPatterns: map[string][]string{"replay": {"gpt-"}}})
+	r.Register("replay", rep)
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+	}
+
+	send := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(OpenAIChatRequest{
+			Model:    "gpt-4o",
+			Messages: []OpenAIChatMessage{{Role: "user", Content: "hi"}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test")
+		rr := httptest.NewRecorder()
+		srv.handleChatCompletions(rr, req)
+		return rr
+	}
+	rr := send()
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "recorded reply") {
+		t.Fatalf("expected the recorded reply, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr = send(); rr.Code != http.StatusBadGateway || !strings.Contains(rr.Body.String(), "consumed") {
+		t.Fatalf("expected the exhausted replay to surface as 502, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestBackendOverrideHeader(t *testing.T) {
+	reply := func(text string) [][]harness.Event {
+		return [][]harness.Event{{harness.NewTextEvent(text)}, {harness.NewTextEvent(text)}, {harness.NewTextEvent(text)}}
+	}
+	r := router.New(router.Config{UserPatterns: map[string][]string{"primary": {"gpt-"}}})
+	r.Register("primary", harness.NewMock(harness.MockConfig{HarnessName: "primary", Responses: reply("from primary")}))
+	r.Register("debug", harness.NewMock(harness.MockConfig{HarnessName: "debug", Responses: reply("from debug")}))
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+		audit:         NewAuditLogger(auditPath, 0, 0),
+	}
+
+	chat := func(backend string) string {
+		body, _ := json.Marshal(OpenAIChatRequest{
+			Model:    "gpt-4o",
+			Messages: []OpenAIChatMessage{{Role: "user", Content: "hi"}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test")
+		req.Header.Set("X-Godex-Backend", backend)
+		rr := httptest.NewRecorder()
+		srv.handleChatCompletions(rr, req)
+		if rr.Code != http.StatusOK {
+			t.Fatalf("chat status %d: %s", rr.Code, rr.Body.String())
+		}
+		return rr.Body.String()
+	}
+
+	if got := chat("debug"); !strings.Contains(got, "from primary") {
+		t.Fatalf("override must be ignored unless allowed, got %s", got)
+	}
+	srv.cfg.AllowBackendOverride = true
+	if got := chat("missing"); !strings.Contains(got, "from primary") {
+		t.Fatalf("unknown override should fall back to routing, got %s", got)
+	}
+	if got := chat("debug"); !strings.Contains(got, "from debug") {
+		t.Fatalf("expected the debug backend, got %s", got)
+	}
+
+	body, _ := json.Marshal(OpenAIResponsesRequest{Model: "gpt-4o", Input: json.RawMessage(`"hi"`)})
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test")
+	req.Header.Set("X-Godex-Backend", "debug")
+	rr := httptest.NewRecorder()
+	srv.handleResponses(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "from debug") {
+		t.Fatalf("expected the debug backend, got %d: %s", rr.Code, rr.Body.String())
+	}
+	raw, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("read audit log: %v", err)
+	}
+	var entry AuditEntry
+	if err := json.Unmarshal(bytes.TrimSpace(raw), &entry); err != nil {
+		t.Fatalf("decode audit entry: %v", err)
+	}
+	if entry.BackendOverride != "debug" {
+		t.Fatalf("expected backend_override=debug in audit, got %+v", entry)
+	}
+}
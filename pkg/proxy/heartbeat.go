@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+var ssePing = []byte(": ping\n\n")
+
+// sseWriter serializes writes and flushes so heartbeat pings never land in
+// the middle of an SSE frame.
+type sseWriter struct {
+	http.ResponseWriter
+	flusher http.Flusher
+	mu      sync.Mutex
+}
+
+func (w *sseWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *sseWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flusher.Flush()
+}
+
+func (w *sseWriter) ping() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, err := w.ResponseWriter.Write(ssePing); err != nil {
+		return err
+	}
+	w.flusher.Flush()
+	return nil
+}
+
+// startHeartbeat sends SSE comment pings every HeartbeatInterval while a
+// stream is active. It returns the writer/flusher the stream must use from
+// now on and a stop func. With a zero interval it returns w and flusher
+// unchanged.
+func (s *Server) startHeartbeat(w http.ResponseWriter, flusher http.Flusher) (http.ResponseWriter, http.Flusher, func()) {
+	interval := s.cfg.HeartbeatInterval
+	if interval <= 0 {
+		return w, flusher, func() {}
+	}
+	sw := &sseWriter{ResponseWriter: w, flusher: flusher}
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := sw.ping(); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	var once sync.Once
+	return sw, sw, func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"godex/pkg/harness"
+	"godex/pkg/router"
+)
+
+func TestChatStreamSendsHeartbeats(t *testing.T) {
+	mock := harness.NewMock(harness.MockConfig{
+		HarnessName: "mock",
+		EventDelay:  40 * time.Millisecond,
+		Responses: [][]harness.Event{{
+			harness.NewTextEvent("slow"),
+			harness.NewTextEvent(" response"),
+		}},
+	})
+	r := router.New(router.Config{UserPatterns: map[string][]string{"mock": {"any-model"}}})
+	r.Register("mock", mock)
+
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true, HeartbeatInterval: 10 * time.Millisecond},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+	}
+
+	body, _ := json.Marshal(OpenAIChatRequest{
+		Model:    "any-model",
+		Stream:   true,
+		Messages: []OpenAIChatMessage{{Role: "user", Content: "Hello"}},
+	})
+	req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	w := httptest.NewRecorder()
+	srv.handleChatCompletions(w, req)
+
+	out := w.Body.String()
+	if !strings.Contains(out, ": ping\n\n") {
+		t.Fatalf("expected heartbeat ping in stream, got:\n%s", out)
+	}
+	// Every non-ping frame must still be a well-formed data line.
+	for _, frame := range strings.Split(strings.TrimSpace(out), "\n\n") {
+		if frame != ": ping" && !strings.HasPrefix(frame, "data: ") {
+			t.Fatalf("malformed SSE frame %q", frame)
+		}
+	}
+}
+
+func TestStartHeartbeatDisabled(t *testing.T) {
+	srv := &Server{}
+	w := httptest.NewRecorder()
+	gotW, gotF, stop := srv.startHeartbeat(w, w)
+	defer stop()
+	if gotW != w || gotF != w {
+		t.Fatal("expected writer to be returned unchanged when heartbeats are disabled")
+	}
+}
@@ -554,3 +554,197 @@ func TestResponsesReplayFixtureContract(t *testing.T) {
 		})
 	}
 }
+
+// TestRequestIDRoundTrip tests that X-Request-ID is echoed or generated.
+func TestRequestIDRoundTrip(t *testing.T) {
+	mock := harness.NewMock(harness.MockConfig{
+		HarnessName: "mock",
+		Responses: [][]harness.Event{
+			{harness.NewTextEvent("one")},
+			{harness.NewTextEvent("two")},
+		},
+	})
+	r := router.New(router.Config{UserPatterns: map[string][]string{"mock": {"any-model"}}})
+	r.Register("mock", mock)
+
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+	}
+
+	send := func(requestID string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(OpenAIChatRequest{
+			Model:    "any-model",
+			Messages: []OpenAIChatMessage{{Role: "user", Content: "Hello"}},
+		})
+		req := httptest.NewRequest("POST", "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		if requestID != "" {
+			req.Header.Set("X-Request-ID", requestID)
+		}
+		w := httptest.NewRecorder()
+		WithRequestID(http.HandlerFunc(srv.handleChatCompletions)).ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("client-req-123")
+	if got := w.Header().Get("X-Request-ID"); got != "client-req-123" {
+		t.Fatalf("expected client X-Request-ID to round-trip, got %q", got)
+	}
+
+	w = send("")
+	got := w.Header().Get("X-Request-ID")
+	if len(got) != 36 || got[14] != '4' {
+		t.Fatalf("expected generated UUIDv4 X-Request-ID, got %q", got)
+	}
+}
+
+func newCompletionsTestServer(events ...harness.Event) *Server {
+	mock := harness.NewMock(harness.MockConfig{
+		HarnessName: "mock",
+		Responses:   [][]harness.Event{events},
+		Record:      true,
+	})
+	r := router.New(router.Config{
+		UserPatterns: map[string][]string{"mock": {"gpt-"}},
+		UserAliases:  map[string]string{"fast": "gpt-4o-mini"},
+	})
+	r.Register("mock", mock)
+	return &Server{
+		cfg:           Config{AllowAnyKey: true},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+	}
+}
+
+// TestCompletionsNonStreaming tests the legacy text_completion response.
+func TestCompletionsNonStreaming(t *testing.T) {
+	srv := newCompletionsTestServer(
+		harness.NewTextEvent("def add(a, b):\n    return a + b\n\n\nprint"),
+		harness.NewUsageEvent(12, 8),
+	)
+	body := `{"model":"fast","prompt":"def add(a, b):","max_tokens":64,"stop":["\n\n\n"]}`
+	req := httptest.NewRequest("POST", "/v1/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	w := httptest.NewRecorder()
+	srv.handleCompletions(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp OpenAICompletionResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Object != "text_completion" || !strings.HasPrefix(resp.ID, "cmpl") {
+		t.Fatalf("unexpected envelope %+v", resp)
+	}
+	if resp.Model != "gpt-4o-mini" {
+		t.Fatalf("expected alias to resolve, got model %q", resp.Model)
+	}
+	if len(resp.Choices) != 1 || resp.Choices[0].Text != "def add(a, b):\n    return a + b" {
+		t.Fatalf("unexpected choices %+v", resp.Choices)
+	}
+	if resp.Usage == nil || resp.Usage.PromptTokens != 12 || resp.Usage.CompletionTokens != 8 || resp.Usage.TotalTokens != 20 {
+		t.Fatalf("unexpected usage %+v", resp.Usage)
+	}
+
+	mock := srv.harnessRouter.Get("mock").(*harness.Mock)
+	turns := mock.Recorded()
+	if len(turns) != 1 || len(turns[0].Messages) != 1 || turns[0].Messages[0].Role != "user" || turns[0].Messages[0].Content != "def add(a, b):" {
+		t.Fatalf("expected prompt as a single user message, got %+v", turns)
+	}
+	if turns[0].MaxOutputTokens != 64 {
+		t.Fatalf("expected max_tokens on the turn, got %d", turns[0].MaxOutputTokens)
+	}
+}
+
+// TestCompletionsStreaming tests text_completion chunks, including a stop
+// sequence split across deltas.
+func TestCompletionsStreaming(t *testing.T) {
+	srv := newCompletionsTestServer(
+		harness.NewTextEvent("Hello"),
+		harness.NewTextEvent(" world EN"),
+		harness.NewTextEvent("D ignored"),
+		harness.NewUsageEvent(4, 3),
+	)
+	body := `{"model":"gpt-4o","prompt":["Say hello"],"stop":"END","stream":true}`
+	req := httptest.NewRequest("POST", "/v1/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	w := httptest.NewRecorder()
+	srv.handleCompletions(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+
+	var text strings.Builder
+	var finish string
+	sawDone := false
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if data == "[DONE]" {
+			sawDone = true
+			continue
+		}
+		var chunk OpenAICompletionResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			t.Fatalf("decode chunk %q: %v", data, err)
+		}
+		if chunk.Object != "text_completion" || len(chunk.Choices) != 1 {
+			t.Fatalf("unexpected chunk %q", data)
+		}
+		text.WriteString(chunk.Choices[0].Text)
+		if chunk.Choices[0].FinishReason != nil {
+			finish = *chunk.Choices[0].FinishReason
+		}
+	}
+	if got := text.String(); got != "Hello world " {
+		t.Fatalf("expected text cut at stop sequence, got %q", got)
+	}
+	if finish != "stop" || !sawDone {
+		t.Fatalf("expected finish_reason stop and [DONE], got %q done=%v", finish, sawDone)
+	}
+}
+
+func TestCompletionsRejectsBadPrompt(t *testing.T) {
+	srv := newCompletionsTestServer(harness.NewTextEvent("unused"))
+	srv.cfg.LogRequests = true
+	for _, body := range []string{
+		`{"model":"gpt-4o","prompt":["a","b"]}`,
+		`{"model":"gpt-4o","prompt":"a","max_tokens":0}`,
+	} {
+		req := httptest.NewRequest("POST", "/v1/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		w := httptest.NewRecorder()
+		srv.handleCompletions(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+	lines, _ := srv.logger.Recent(0)
+	var logged int
+	for _, line := range lines {
+		if line.Message == "request" && line.Fields["path"] == "/v1/completions" && line.Fields["status"] == "400" {
+			logged++
+		}
+	}
+	if logged != 2 {
+		t.Fatalf("expected both rejections logged, got %d in %+v", logged, lines)
+	}
+}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ipFilter restricts callers by source address. Deny rules win over allow
+// rules; an empty allow list admits every address not denied.
+type ipFilter struct {
+	allow        []netip.Prefix
+	deny         []netip.Prefix
+	trustHeaders bool
+	// trusted lists the reverse proxies whose forwarding headers count.
+	// Empty trusts only the immediate peer.
+	trusted []netip.Prefix
+}
+
+// newIPFilter parses CIDR (or bare IP) lists. It returns nil when both lists
+// are empty so callers can skip filtering entirely.
+func newIPFilter(allow, deny []string, trustHeaders bool, trustedProxies []string) (*ipFilter, error) {
+	f := &ipFilter{trustHeaders: trustHeaders}
+	var err error
+	if f.trusted, err = parsePrefixes(trustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted_proxies: %w", err)
+	}
+	if f.allow, err = parsePrefixes(allow); err != nil {
+		return nil, fmt.Errorf("allow_ips: %w", err)
+	}
+	if f.deny, err = parsePrefixes(deny); err != nil {
+		return nil, fmt.Errorf("deny_ips: %w", err)
+	}
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return nil, nil
+	}
+	return f, nil
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	var out []netip.Prefix
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if strings.Contains(v, "/") {
+			p, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return out, nil
+}
+
+// allowed reports whether addr passes the deny and allow lists.
+func (f *ipFilter) allowed(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	for _, p := range f.deny {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, p := range f.allow {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP extracts the caller's address. Forwarding headers are honored
+// only when trustHeaders is set and the peer is a trusted proxy, since
+// clients can forge them. Clients control the leftmost X-Forwarded-For
+// entries and each proxy appends its peer, so the caller is the rightmost
+// entry that is not itself a trusted proxy.
+func (f *ipFilter) clientIP(r *http.Request) (netip.Addr, error) {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		host = h
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, errors.New("unparseable remote address")
+	}
+	if !f.trustHeaders || !f.trustedProxy(peer, true) {
+		return peer, nil
+	}
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				return netip.Addr{}, errors.New("unparseable X-Forwarded-For entry")
+			}
+			if i == 0 || !f.trustedProxy(addr, false) {
+				return addr, nil
+			}
+		}
+	}
+	if real := strings.TrimSpace(r.Header.Get("X-Real-IP")); real != "" {
+		if addr, err := netip.ParseAddr(real); err == nil {
+			return addr, nil
+		}
+	}
+	return peer, nil
+}
+
+// trustedProxy reports whether addr is a trusted reverse proxy. Without a
+// trusted_proxies list only the immediate peer is trusted.
+func (f *ipFilter) trustedProxy(addr netip.Addr, peer bool) bool {
+	if len(f.trusted) == 0 {
+		return peer
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, p := range f.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowIP writes a 403 and returns false when the caller's address is
+// filtered out.
+func (s *Server) allowIP(w http.ResponseWriter, r *http.Request) bool {
+	if s.ipFilter == nil {
+		return true
+	}
+	addr, err := s.ipFilter.clientIP(r)
+	if err != nil || !s.ipFilter.allowed(addr) {
+		writeError(w, http.StatusForbidden, errors.New("source address not allowed"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"godex/pkg/auth"
+)
+
+func TestIPFilterEmptyIsNil(t *testing.T) {
+	f, err := newIPFilter(nil, []string{" "}, false, nil)
+	if err != nil || f != nil {
+		t.Fatalf("expected nil filter, got %v %v", f, err)
+	}
+}
+
+func TestIPFilterRejectsBadCIDR(t *testing.T) {
+	if _, err := newIPFilter([]string{"10.0.0.0/33"}, nil, false, nil); err == nil {
+		t.Fatal("expected error for invalid CIDR")
+	}
+	if _, err := newIPFilter(nil, []string{"not-an-ip"}, false, nil); err == nil {
+		t.Fatal("expected error for invalid IP")
+	}
+}
+
+func TestIPFilterAllowAndDeny(t *testing.T) {
+	f, err := newIPFilter(
+		[]string{"10.0.0.0/8", "2001:db8::/32"},
+		[]string{"10.1.2.3", "2001:db8:dead::/48"},
+		false,
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("newIPFilter: %v", err)
+	}
+	cases := []struct {
+		remote string
+		want   bool
+	}{
+		{"10.0.0.5:1234", true},
+		{"10.1.2.3:1234", false},    // denied host inside allowed range
+		{"192.168.1.1:1234", false}, // outside allow list
+		{"[2001:db8::1]:443", true}, // IPv6 in allowed range
+		{"[2001:db8:dead::1]:443", false},
+		{"[::ffff:10.0.0.7]:80", true}, // IPv4-mapped IPv6
+		{"[fe80::1%eth0]:80", false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.RemoteAddr = tc.remote
+		addr, err := f.clientIP(req)
+		if err != nil {
+			t.Fatalf("%s: clientIP: %v", tc.remote, err)
+		}
+		if got := f.allowed(addr); got != tc.want {
+			t.Errorf("%s: allowed=%v, want %v", tc.remote, got, tc.want)
+		}
+	}
+}
+
+func TestIPFilterDenyOnly(t *testing.T) {
+	f, err := newIPFilter(nil, []string{"192.168.0.0/16"}, false, nil)
+	if err != nil {
+		t.Fatalf("newIPFilter: %v", err)
+	}
+	s := &Server{ipFilter: f}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.RemoteAddr = "192.168.4.4:5000"
+	rr := httptest.NewRecorder()
+	if _, ok := s.requireAuth(rr, req, auth.ScopeRead); ok || rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for denied address, got %d", rr.Code)
+	}
+
+	req.RemoteAddr = "172.16.0.1:5000"
+	if !s.allowIP(httptest.NewRecorder(), req) {
+		t.Fatal("expected address outside deny list to pass")
+	}
+}
+
+func TestIPFilterProxyHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.RemoteAddr = "127.0.0.1:9000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 10.0.0.1")
+
+	untrusted, _ := newIPFilter([]string{"203.0.113.0/24"}, nil, false, nil)
+	addr, _ := untrusted.clientIP(req)
+	if untrusted.allowed(addr) {
+		t.Fatal("expected forwarded header to be ignored when untrusted")
+	}
+
+	// Only the peer is trusted, so the hop it appended is the caller and
+	// the client-supplied 203.0.113.9 is ignored.
+	trusted, _ := newIPFilter([]string{"203.0.113.0/24"}, nil, true, nil)
+	addr, _ = trusted.clientIP(req)
+	if addr != netip.MustParseAddr("10.0.0.1") || trusted.allowed(addr) {
+		t.Fatalf("expected rightmost X-Forwarded-For hop, got %s", addr)
+	}
+
+	// With 10.0.0.0/8 as a second trusted proxy, the caller is one hop further left.
+	chain, _ := newIPFilter([]string{"203.0.113.0/24"}, nil, true, []string{"127.0.0.1", "10.0.0.0/8"})
+	addr, _ = chain.clientIP(req)
+	if !chain.allowed(addr) {
+		t.Fatalf("expected first untrusted hop 203.0.113.9, got %s", addr)
+	}
+
+	// A peer outside trusted_proxies cannot set the headers at all.
+	req.RemoteAddr = "198.51.100.4:9000"
+	addr, _ = chain.clientIP(req)
+	if addr != netip.MustParseAddr("198.51.100.4") {
+		t.Fatalf("expected untrusted peer address, got %s", addr)
+	}
+	req.RemoteAddr = "127.0.0.1:9000"
+
+	req.Header.Del("X-Forwarded-For")
+	req.Header.Set("X-Real-IP", "2001:db8::7")
+	v6, _ := newIPFilter([]string{"2001:db8::/32"}, nil, true, nil)
+	addr, _ = v6.clientIP(req)
+	if !v6.allowed(addr) {
+		t.Fatalf("expected X-Real-IP to be used, got %s", addr)
+	}
+}
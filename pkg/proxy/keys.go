@@ -9,6 +9,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,6 +30,30 @@ type KeyRecord struct {
 	TokenAllowance       int64      `json:"token_allowance,omitempty"`
 	AllowanceDurationSec int64      `json:"allowance_duration_sec,omitempty"`
 	AllowanceWindowStart *time.Time `json:"allowance_window_start,omitempty"`
+	AllowedModels        []string   `json:"allowed_models,omitempty"`
+	Scopes               []string   `json:"scopes,omitempty"`
+	// ModelQuotas overrides the proxy-wide per-model token limits for this key.
+	ModelQuotas map[string]int64 `json:"model_quotas,omitempty"`
+}
+
+// AllowsModel reports whether the key may call model. An empty allowlist
+// permits every model.
+func (r KeyRecord) AllowsModel(model string) bool {
+	if len(r.AllowedModels) == 0 {
+		return true
+	}
+	for _, m := range r.AllowedModels {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsScope reports whether the key may call endpoints of scope (see
+// auth.ScopeRead and auth.ScopeChat). A key without scopes may call all.
+func (r KeyRecord) AllowsScope(scope string) bool {
+	return len(r.Scopes) == 0 || slices.Contains(r.Scopes, scope)
 }
 
 type KeyFile struct {
@@ -88,7 +114,23 @@ func (s *KeyStore) List() []KeyRecord {
 	return out
 }
 
-func (s *KeyStore) Add(label string, rate string, burst int, quota int64, providedKey string, ttl time.Duration) (KeyRecord, string, error) {
+func (s *KeyStore) Add(label string, rate string, burst int, quota int64, providedKey string, ttl time.Duration, allowedModels ...string) (KeyRecord, string, error) {
+	rec, secret, err := newKeyRecord(label, rate, burst, quota, providedKey, ttl, allowedModels)
+	if err != nil {
+		return KeyRecord{}, "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file.Keys = append(s.file.Keys, rec)
+	if err := s.saveLocked(); err != nil {
+		return KeyRecord{}, "", err
+	}
+	return rec, secret, nil
+}
+
+// newKeyRecord builds the record for a new key and returns it with the
+// key's secret.
+func newKeyRecord(label string, rate string, burst int, quota int64, providedKey string, ttl time.Duration, allowedModels []string) (KeyRecord, string, error) {
 	label = strings.TrimSpace(label)
 	if label == "" {
 		return KeyRecord{}, "", errors.New("label is required")
@@ -113,17 +155,11 @@ func (s *KeyStore) Add(label string, rate string, burst int, quota int64, provid
 		Burst:       burst,
 		QuotaTokens: quota,
 	}
+	rec.AllowedModels = normalizeModelList(allowedModels)
 	if ttl > 0 {
 		expires := time.Now().UTC().Add(ttl)
 		rec.ExpiresAt = &expires
 	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.file.Keys = append(s.file.Keys, rec)
-	if err := s.saveLocked(); err != nil {
-		return KeyRecord{}, "", err
-	}
 	return rec, secret, nil
 }
 
@@ -134,25 +170,45 @@ func (s *KeyStore) Revoke(idOrToken string) (KeyRecord, bool) {
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	rec, ok := s.revokeLocked(idOrToken)
+	if ok {
+		_ = s.saveLocked()
+	}
+	return rec, ok
+}
+
+func (s *KeyStore) revokeLocked(idOrToken string) (KeyRecord, bool) {
 	for i, rec := range s.file.Keys {
 		if rec.ID == idOrToken || rec.Hash == hashToken(idOrToken) {
 			now := time.Now().UTC()
 			rec.RevokedAt = &now
 			s.file.Keys[i] = rec
-			_ = s.saveLocked()
 			return rec, true
 		}
 	}
 	return KeyRecord{}, false
 }
 
-func (s *KeyStore) Update(id string, label string, rate string, burst int, quota int64, ttl time.Duration) (KeyRecord, error) {
+// Update changes the given fields of a key. Zero values leave a field
+// unchanged; a nil allowedModels keeps the current allowlist.
+func (s *KeyStore) Update(id string, label string, rate string, burst int, quota int64, ttl time.Duration, allowedModels []string) (KeyRecord, error) {
 	id = strings.TrimSpace(id)
 	if id == "" {
 		return KeyRecord{}, errors.New("id required")
 	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	rec, err := s.updateLocked(id, label, rate, burst, quota, ttl, allowedModels)
+	if err != nil {
+		return KeyRecord{}, err
+	}
+	if err := s.saveLocked(); err != nil {
+		return KeyRecord{}, err
+	}
+	return rec, nil
+}
+
+func (s *KeyStore) updateLocked(id string, label string, rate string, burst int, quota int64, ttl time.Duration, allowedModels []string) (KeyRecord, error) {
 	for i, rec := range s.file.Keys {
 		if rec.ID != id {
 			continue
@@ -173,10 +229,10 @@ func (s *KeyStore) Update(id string, label string, rate string, burst int, quota
 			expires := time.Now().UTC().Add(ttl)
 			rec.ExpiresAt = &expires
 		}
-		s.file.Keys[i] = rec
-		if err := s.saveLocked(); err != nil {
-			return KeyRecord{}, err
+		if allowedModels != nil {
+			rec.AllowedModels = normalizeModelList(allowedModels)
 		}
+		s.file.Keys[i] = rec
 		return rec, nil
 	}
 	return KeyRecord{}, errors.New("key not found")
@@ -187,7 +243,49 @@ func (s *KeyStore) Rotate(idOrToken string) (KeyRecord, string, error) {
 	if !ok {
 		return KeyRecord{}, "", errors.New("key not found")
 	}
-	return s.Add(rec.Label, rec.Rate, rec.Burst, rec.QuotaTokens, "", 0)
+	created, token, err := s.Add(rec.Label, rec.Rate, rec.Burst, rec.QuotaTokens, "", 0, rec.AllowedModels...)
+	if err != nil || len(rec.ModelQuotas) == 0 {
+		return created, token, err
+	}
+	created, err = s.SetModelQuotas(created.ID, rec.ModelQuotas)
+	return created, token, err
+}
+
+// SetModelQuotas merges per-model token limits into a key. A limit of zero
+// removes the override for that model.
+func (s *KeyStore) SetModelQuotas(id string, quotas map[string]int64) (KeyRecord, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return KeyRecord{}, errors.New("id required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, rec := range s.file.Keys {
+		if rec.ID != id {
+			continue
+		}
+		merged := map[string]int64{}
+		for model, limit := range rec.ModelQuotas {
+			merged[model] = limit
+		}
+		for model, limit := range quotas {
+			if limit <= 0 {
+				delete(merged, model)
+				continue
+			}
+			merged[model] = limit
+		}
+		if len(merged) == 0 {
+			merged = nil
+		}
+		rec.ModelQuotas = merged
+		s.file.Keys[i] = rec
+		if err := s.saveLocked(); err != nil {
+			return KeyRecord{}, err
+		}
+		return rec, nil
+	}
+	return KeyRecord{}, errors.New("key not found")
 }
 
 func (s *KeyStore) SetTokenPolicy(id string, balance int64, allowance int64, duration time.Duration) (KeyRecord, error) {
@@ -284,7 +382,12 @@ func (s *KeyStore) UpdateAllowanceWindow(id string, tokenAllowance int64, durati
 	return KeyRecord{}, false, errors.New("key not found")
 }
 
+// Validate looks token up by hash. Signed keys are never accepted here,
+// even if an older store recorded their hash; see ValidateSigned.
 func (s *KeyStore) Validate(token string) (KeyRecord, bool) {
+	if strings.HasPrefix(token, signedKeyPrefix) {
+		return KeyRecord{}, false
+	}
 	hash := hashToken(token)
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -318,6 +421,35 @@ func (s *KeyStore) PruneExpired() {
 	_ = s.saveLocked()
 }
 
+// ParseModelList splits a comma-separated model list, dropping blanks.
+func ParseModelList(raw string) []string {
+	return normalizeModelList(strings.Split(raw, ","))
+}
+
+// ParseModelQuota parses a "model=tokens" pair as given to --model-quota.
+func ParseModelQuota(raw string) (string, int64, error) {
+	model, value, ok := strings.Cut(raw, "=")
+	model = strings.TrimSpace(model)
+	if !ok || model == "" {
+		return "", 0, fmt.Errorf("invalid model quota %q (want model=tokens)", raw)
+	}
+	limit, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || limit < 0 {
+		return "", 0, fmt.Errorf("invalid model quota %q (want model=tokens)", raw)
+	}
+	return model, limit, nil
+}
+
+func normalizeModelList(models []string) []string {
+	var out []string
+	for _, m := range models {
+		if m = strings.TrimSpace(m); m != "" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
 func hashToken(token string) string {
 	if strings.HasPrefix(token, "sha256:") {
 		return token
@@ -5,6 +5,8 @@ import (
 	"path/filepath"
 	"testing"
 	"time"
+
+	"godex/pkg/admin"
 )
 
 func TestLoadKeyStoreEmpty(t *testing.T) {
@@ -328,3 +330,171 @@ func TestKeyStoreList(t *testing.T) {
 func hasPrefix(s, prefix string) bool {
 	return len(s) >= len(prefix) && s[:len(prefix)] == prefix
 }
+
+func TestKeyStoreAllowedModels(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "keys.json")
+
+	store, _ := LoadKeyStore(path)
+	rec, _, err := store.Add("scoped", "60/m", 10, 0, "", 0, "gpt-4o", " claude-sonnet ", "")
+	if err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	if len(rec.AllowedModels) != 2 || rec.AllowedModels[1] != "claude-sonnet" {
+		t.Fatalf("unexpected allowed models: %#v", rec.AllowedModels)
+	}
+	if !rec.AllowsModel("gpt-4o") || rec.AllowsModel("o3") {
+		t.Fatalf("allowlist not enforced: %#v", rec.AllowedModels)
+	}
+
+	// nil keeps the allowlist
+	rec, err = store.Update(rec.ID, "renamed", "", 0, 0, 0, nil)
+	if err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+	if len(rec.AllowedModels) != 2 {
+		t.Fatalf("expected allowlist unchanged, got %#v", rec.AllowedModels)
+	}
+	rec, err = store.Update(rec.ID, "", "", 0, 0, 0, []string{"o3"})
+	if err != nil {
+		t.Fatalf("Update error: %v", err)
+	}
+
+	reloaded, err := LoadKeyStore(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	keys := reloaded.List()
+	if len(keys) != 1 || len(keys[0].AllowedModels) != 1 || keys[0].AllowedModels[0] != "o3" {
+		t.Fatalf("allowlist not persisted: %#v", keys)
+	}
+
+	rotated, _, err := reloaded.Rotate(rec.ID)
+	if err != nil {
+		t.Fatalf("Rotate error: %v", err)
+	}
+	if !rotated.AllowsModel("o3") || rotated.AllowsModel("gpt-4o") {
+		t.Fatalf("rotate dropped allowlist: %#v", rotated.AllowedModels)
+	}
+}
+
+func TestKeyRecordWithoutAllowedModelsAllowsAll(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "keys.json")
+	legacy := `{"version":1,"keys":[{"id":"key_old","label":"old","hash":"sha256:abc","created_at":"2025-01-01T00:00:00Z"}]}`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := LoadKeyStore(path)
+	if err != nil {
+		t.Fatalf("LoadKeyStore: %v", err)
+	}
+	keys := store.List()
+	if len(keys) != 1 || !keys[0].AllowsModel("anything") {
+		t.Fatalf("expected legacy key to allow all models: %#v", keys)
+	}
+}
+
+func TestKeyStoreSetModelQuotas(t *testing.T) {
+	store, err := LoadKeyStore(t.TempDir() + "/keys.json")
+	if err != nil {
+		t.Fatalf("LoadKeyStore: %v", err)
+	}
+	rec, _, err := store.Add("quota", "60/m", 10, 0, "", 0)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	rec, err = store.SetModelQuotas(rec.ID, map[string]int64{"gpt-5": 1000, "claude-opus-4-5": 200})
+	if err != nil {
+		t.Fatalf("SetModelQuotas: %v", err)
+	}
+	if rec.ModelQuotas["gpt-5"] != 1000 || rec.ModelQuotas["claude-opus-4-5"] != 200 {
+		t.Fatalf("unexpected quotas: %v", rec.ModelQuotas)
+	}
+
+	// Zero removes a single override and leaves the rest.
+	rec, err = store.SetModelQuotas(rec.ID, map[string]int64{"claude-opus-4-5": 0})
+	if err != nil {
+		t.Fatalf("SetModelQuotas: %v", err)
+	}
+	if _, ok := rec.ModelQuotas["claude-opus-4-5"]; ok {
+		t.Fatalf("expected override removed: %v", rec.ModelQuotas)
+	}
+	if rec.ModelQuotas["gpt-5"] != 1000 {
+		t.Fatalf("expected gpt-5 override kept: %v", rec.ModelQuotas)
+	}
+
+	rotated, _, err := store.Rotate(rec.ID)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if rotated.ModelQuotas["gpt-5"] != 1000 {
+		t.Fatalf("expected rotate to keep quotas: %v", rotated.ModelQuotas)
+	}
+
+	if _, err := store.SetModelQuotas("missing", map[string]int64{"gpt-5": 1}); err == nil {
+		t.Fatal("expected error for unknown key")
+	}
+}
+
+func TestParseModelQuota(t *testing.T) {
+	model, limit, err := ParseModelQuota("gpt-5=5000")
+	if err != nil || model != "gpt-5" || limit != 5000 {
+		t.Fatalf("got %q %d %v", model, limit, err)
+	}
+	for _, bad := range []string{"gpt-5", "=10", "gpt-5=abc", "gpt-5=-1"} {
+		if _, _, err := ParseModelQuota(bad); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestAdminBulkKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := LoadKeyStore(path)
+	if err != nil {
+		t.Fatalf("LoadKeyStore error: %v", err)
+	}
+	existing, _, err := store.Add("existing", "60/m", 10, 0, "", 0)
+	if err != nil {
+		t.Fatalf("Add error: %v", err)
+	}
+	a := adminAdapter{keys: store}
+
+	results, err := a.BulkKeys([]admin.KeyOp{
+		{Op: admin.KeyOpAdd, Label: "ci-1", Rate: "10/m"},
+		{Op: admin.KeyOpUpdate, ID: existing.ID, Rate: "120/m"},
+		{Op: admin.KeyOpRevoke, ID: "missing"},
+	})
+	if err == nil {
+		t.Fatal("batch with a missing key committed")
+	}
+	if results[0].Status != admin.KeyOpStatusRolledBack || results[0].APIKey != "" || results[2].Status != admin.KeyOpStatusError {
+		t.Errorf("results = %+v", results)
+	}
+	reloaded, _ := LoadKeyStore(path)
+	if keys := reloaded.List(); len(keys) != 1 || keys[0].Rate != "60/m" {
+		t.Fatalf("after rollback, keys file = %+v", keys)
+	}
+	if keys := store.List(); len(keys) != 1 || keys[0].Rate != "60/m" {
+		t.Fatalf("after rollback, store = %+v", keys)
+	}
+
+	results, err = a.BulkKeys([]admin.KeyOp{
+		{Op: admin.KeyOpAdd, Label: "ci-1", Rate: "10/m"},
+		{Op: admin.KeyOpUpdate, ID: existing.ID, Rate: "120/m"},
+		{Op: admin.KeyOpRevoke, ID: existing.ID},
+	})
+	if err != nil {
+		t.Fatalf("BulkKeys error: %v", err)
+	}
+	if rec, ok := store.Validate(results[0].APIKey); !ok || rec.Label != "ci-1" {
+		t.Errorf("added key does not validate: %+v", results[0])
+	}
+	reloaded, _ = LoadKeyStore(path)
+	keys := reloaded.List()
+	if len(keys) != 2 || keys[0].Rate != "120/m" || keys[0].RevokedAt == nil {
+		t.Errorf("after commit, keys file = %+v", keys)
+	}
+}
@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// errTxDone is returned by a KeyTx used after Commit or Rollback.
+var errTxDone = errors.New("key transaction already finished")
+
+// KeyTx is a batch of key changes that are saved together or not at all.
+// It holds the store's lock from BeginTx until Commit or Rollback, so it
+// must be finished promptly and other KeyStore methods must not be called
+// meanwhile.
+type KeyTx struct {
+	s      *KeyStore
+	backup []KeyRecord
+	done   bool
+}
+
+// BeginTx starts a transaction on the store.
+func (s *KeyStore) BeginTx() *KeyTx {
+	s.mu.Lock()
+	return &KeyTx{s: s, backup: slices.Clone(s.file.Keys)}
+}
+
+// Add is KeyStore.Add within the transaction.
+func (tx *KeyTx) Add(label string, rate string, burst int, quota int64, providedKey string, ttl time.Duration, allowedModels ...string) (KeyRecord, string, error) {
+	if tx.done {
+		return KeyRecord{}, "", errTxDone
+	}
+	rec, secret, err := newKeyRecord(label, rate, burst, quota, providedKey, ttl, allowedModels)
+	if err != nil {
+		return KeyRecord{}, "", err
+	}
+	tx.s.file.Keys = append(tx.s.file.Keys, rec)
+	return rec, secret, nil
+}
+
+// Revoke is KeyStore.Revoke within the transaction.
+func (tx *KeyTx) Revoke(idOrToken string) (KeyRecord, bool) {
+	idOrToken = strings.TrimSpace(idOrToken)
+	if tx.done || idOrToken == "" {
+		return KeyRecord{}, false
+	}
+	return tx.s.revokeLocked(idOrToken)
+}
+
+// Update is KeyStore.Update within the transaction.
+func (tx *KeyTx) Update(id string, label string, rate string, burst int, quota int64, ttl time.Duration, allowedModels []string) (KeyRecord, error) {
+	if tx.done {
+		return KeyRecord{}, errTxDone
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return KeyRecord{}, errors.New("id required")
+	}
+	return tx.s.updateLocked(id, label, rate, burst, quota, ttl, allowedModels)
+}
+
+// Commit saves the transaction's changes and releases the store. If the
+// save fails, the changes are rolled back.
+func (tx *KeyTx) Commit() error {
+	if tx.done {
+		return errTxDone
+	}
+	tx.done = true
+	defer tx.s.mu.Unlock()
+	if err := tx.s.saveLocked(); err != nil {
+		tx.s.file.Keys = tx.backup
+		return err
+	}
+	return nil
+}
+
+// Rollback discards the transaction's changes and releases the store. It
+// does nothing after Commit.
+func (tx *KeyTx) Rollback() {
+	if tx.done {
+		return
+	}
+	tx.done = true
+	tx.s.file.Keys = tx.backup
+	tx.s.mu.Unlock()
+}
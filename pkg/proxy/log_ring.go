@@ -0,0 +1,68 @@
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// logRingSize is how many lines the Logger keeps for GET /admin/logs.
+const logRingSize = 10000
+
+// LogLine is one line written by a Logger.
+type LogLine struct {
+	Seq     uint64
+	Time    time.Time
+	Level   string
+	Message string
+	Fields  map[string]string
+}
+
+// logRing keeps the most recent log lines and signals when more arrive.
+type logRing struct {
+	mu      sync.Mutex
+	lines   []LogLine // circular once full
+	next    int
+	seq     uint64
+	changed chan struct{}
+}
+
+func newLogRing(size int) *logRing {
+	return &logRing{lines: make([]LogLine, 0, size), changed: make(chan struct{})}
+}
+
+func (r *logRing) add(level, msg string, keyvals []string) {
+	line := LogLine{Time: time.Now().UTC(), Level: level, Message: msg}
+	if len(keyvals) > 1 {
+		line.Fields = make(map[string]string, len(keyvals)/2)
+		for i := 0; i+1 < len(keyvals); i += 2 {
+			line.Fields[keyvals[i]] = keyvals[i+1]
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	line.Seq = r.seq
+	if len(r.lines) < cap(r.lines) {
+		r.lines = append(r.lines, line)
+	} else {
+		r.lines[r.next] = line
+		r.next = (r.next + 1) % len(r.lines)
+	}
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// since returns the kept lines after seq, oldest first, and a channel
+// that is closed when another line is added.
+func (r *logRing) since(seq uint64) ([]LogLine, <-chan struct{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []LogLine
+	for i := range r.lines {
+		line := r.lines[(r.next+i)%len(r.lines)]
+		if line.Seq > seq {
+			out = append(out, line)
+		}
+	}
+	return out, r.changed
+}
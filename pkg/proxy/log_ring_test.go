@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"io"
+	"testing"
+)
+
+func TestLogRing(t *testing.T) {
+	r := newLogRing(3)
+	lines, changed := r.since(0)
+	if len(lines) != 0 {
+		t.Fatalf("empty ring returned %d lines", len(lines))
+	}
+	r.add("INFO", "one", []string{"key", "value"})
+	select {
+	case <-changed:
+	default:
+		t.Fatal("add did not signal the change")
+	}
+	for _, msg := range []string{"two", "three", "four"} {
+		r.add("WARN", msg, nil)
+	}
+
+	lines, _ = r.since(0)
+	var got []string
+	for _, l := range lines {
+		got = append(got, l.Message)
+	}
+	if len(got) != 3 || got[0] != "two" || got[2] != "four" {
+		t.Fatalf("lines = %q, want the last three", got)
+	}
+	if lines[0].Seq != 2 || lines[2].Seq != 4 {
+		t.Errorf("seqs = %d..%d, want 2..4", lines[0].Seq, lines[2].Seq)
+	}
+	if lines, _ = r.since(3); len(lines) != 1 || lines[0].Message != "four" {
+		t.Errorf("since(3) = %+v, want only four", lines)
+	}
+}
+
+func TestLoggerRecent(t *testing.T) {
+	l := NewLogger(LogLevelWarn)
+	l.logger.SetOutput(io.Discard)
+	l.Info("skipped")
+	l.Warn("kept", "backend", "codex")
+	lines, _ := l.Recent(0)
+	if len(lines) != 1 || lines[0].Level != "WARN" || lines[0].Fields["backend"] != "codex" {
+		t.Fatalf("Recent = %+v, want only the warning", lines)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"sync/atomic"
 )
 
 type LogLevel int
@@ -32,36 +33,76 @@ func ParseLogLevel(level string) LogLevel {
 }
 
 type Logger struct {
-	level  LogLevel
+	level  atomic.Int32
 	logger *log.Logger
+	recent *logRing
 }
 
 func NewLogger(level LogLevel) *Logger {
-	return &Logger{
-		level:  level,
-		logger: log.New(os.Stderr, "", log.LstdFlags),
+	l := &Logger{logger: log.New(os.Stderr, "", log.LstdFlags), recent: newLogRing(logRingSize)}
+	l.SetLevel(level)
+	return l
+}
+
+// SetLevel changes which messages are written. It is safe to call while
+// other goroutines log.
+func (l *Logger) SetLevel(level LogLevel) {
+	l.level.Store(int32(level))
+}
+
+func (l *Logger) enabled(level LogLevel) bool {
+	return l != nil && LogLevel(l.level.Load()) >= level
+}
+
+// DebugEnabled reports whether Debug messages are written. Callers use it to
+// skip work whose only purpose is a debug line.
+func (l *Logger) DebugEnabled() bool {
+	return l.enabled(LogLevelDebug)
+}
+
+func (l *Logger) Debug(msg string, keyvals ...string) {
+	if !l.DebugEnabled() {
+		return
 	}
+	l.write("DEBUG", msg, keyvals)
 }
 
 func (l *Logger) Info(msg string, keyvals ...string) {
-	if l == nil || l.level < LogLevelInfo {
+	if !l.enabled(LogLevelInfo) {
 		return
 	}
-	l.logger.Println(formatLog("INFO", msg, keyvals...))
+	l.write("INFO", msg, keyvals)
 }
 
 func (l *Logger) Warn(msg string, keyvals ...string) {
-	if l == nil || l.level < LogLevelWarn {
+	if !l.enabled(LogLevelWarn) {
 		return
 	}
-	l.logger.Println(formatLog("WARN", msg, keyvals...))
+	l.write("WARN", msg, keyvals)
 }
 
 func (l *Logger) Error(msg string, keyvals ...string) {
-	if l == nil || l.level < LogLevelError {
+	if !l.enabled(LogLevelError) {
 		return
 	}
-	l.logger.Println(formatLog("ERROR", msg, keyvals...))
+	l.write("ERROR", msg, keyvals)
+}
+
+// write prints a line and keeps it for Recent.
+func (l *Logger) write(level, msg string, keyvals []string) {
+	l.logger.Println(formatLog(level, msg, keyvals...))
+	if l.recent != nil {
+		l.recent.add(level, msg, keyvals)
+	}
+}
+
+// Recent returns the last lines written after seq, up to 10,000, and a
+// channel that is closed when another line is written.
+func (l *Logger) Recent(seq uint64) ([]LogLine, <-chan struct{}) {
+	if l == nil || l.recent == nil {
+		return nil, nil
+	}
+	return l.recent.since(seq)
 }
 
 func formatLog(level, msg string, keyvals ...string) string {
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"godex/pkg/harness"
+)
+
+// Middleware wraps an http.Handler. Config.Middleware entries are applied
+// in order, so the first one sees the request first.
+type Middleware = func(http.Handler) http.Handler
+
+// chainMiddleware wraps h so that mws[0] is the outermost handler.
+func chainMiddleware(h http.Handler, mws []Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i] != nil {
+			h = mws[i](h)
+		}
+	}
+	return h
+}
+
+// WithRequestID assigns a correlation ID before the proxy handlers run. A
+// well-formed client X-Request-ID is kept; otherwise a UUID v4 is generated.
+// The ID is set on the response header and the request context, where
+// handlers, log lines and upstream calls pick it up. Run installs it ahead
+// of Config.Middleware, so listing it there again is harmless.
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, ok := harness.RequestID(r.Context())
+		if !ok {
+			id = strings.TrimSpace(r.Header.Get("X-Request-ID"))
+			if !validRequestID(id) {
+				id = newUUID()
+			}
+			r = r.WithContext(harness.WithRequestID(r.Context(), id))
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithRecovery turns handler panics into a 500 instead of dropping the
+// connection. Place it outside WithLogging to log the 500.
+func WithRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			if p := recover(); p != nil {
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				log.Printf("[ERROR] panic serving %s %s: %v\n%s", r.Method, r.URL.Path, p, debug.Stack())
+				if !rec.wroteHeader {
+					writeError(w, http.StatusInternalServerError, fmt.Errorf("internal error"))
+				}
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// WithLogging logs one line per request with status and duration.
+func WithLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		id, _ := harness.RequestID(r.Context())
+		if id == "" {
+			id = w.Header().Get("X-Request-ID")
+		}
+		log.Print(formatLog("INFO", "http", "request_id", id, "method", r.Method, "path", r.URL.Path, "status", fmt.Sprintf("%d", status), "elapsed", time.Since(start).String()))
+	})
+}
+
+// statusRecorder captures the response status while keeping streaming
+// (Flush) available to SSE handlers.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.status = code
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	if !s.wroteHeader {
+		s.status = http.StatusOK
+		s.wroteHeader = true
+	}
+	return s.ResponseWriter.Write(p)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,76 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"godex/pkg/harness"
+)
+
+func TestChainMiddlewareOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	base := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "mux")
+	})
+	h := chainMiddleware(base, []Middleware{mark("first"), nil, mark("second")})
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := strings.Join(order, ","); got != "first,second,mux" {
+		t.Fatalf("unexpected order %q", got)
+	}
+}
+
+func TestWithRequestIDFeedsHandlers(t *testing.T) {
+	var seen string
+	h := WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = harness.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "abc-123")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if seen != "abc-123" || rr.Header().Get("X-Request-ID") != "abc-123" {
+		t.Fatalf("expected client id reused, got ctx=%q header=%q", seen, rr.Header().Get("X-Request-ID"))
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(seen) != 36 || rr.Header().Get("X-Request-ID") != seen {
+		t.Fatalf("expected generated id, got ctx=%q header=%q", seen, rr.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestWithRecovery(t *testing.T) {
+	h := WithRecovery(WithLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rr.Code)
+	}
+}
+
+func TestStatusRecorderKeepsFlusher(t *testing.T) {
+	h := WithLogging(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); !ok {
+			t.Error("expected wrapped writer to implement http.Flusher")
+		}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusTeapot {
+		t.Fatalf("expected status passthrough, got %d", rr.Code)
+	}
+}
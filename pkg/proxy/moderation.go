@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"godex/pkg/harness/openai"
+)
+
+// Moderator classifies text against content-policy categories.
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (*openai.ModerationResult, error)
+}
+
+// ModerationConfig screens the latest user message of chat and responses
+// requests before they are forwarded. A category is flagged when its score
+// exceeds Threshold, or when the moderation API flags it if Threshold is
+// zero. Flagged requests get HTTP 451 when BlockOnFail is set and are only
+// logged otherwise. A moderation call that fails lets the request through.
+type ModerationConfig struct {
+	Enabled     bool
+	Threshold   float64
+	BlockOnFail bool
+	Moderator   Moderator
+}
+
+// moderate checks the last user message in items and reports whether the
+// request may proceed, writing the 451 response when it may not.
+func (s *Server) moderate(w http.ResponseWriter, r *http.Request, requestID string, items []OpenAIItem) bool {
+	cfg := s.cfg.Moderation
+	if !cfg.Enabled || cfg.Moderator == nil {
+		return true
+	}
+	text := lastUserText(items)
+	if strings.TrimSpace(text) == "" {
+		return true
+	}
+	res, err := cfg.Moderator.Moderate(r.Context(), text)
+	if err != nil {
+		log.Printf("[WARN] moderation failed request_id=%s: %v", requestID, err)
+		return true
+	}
+	flagged := res.FlaggedAbove(cfg.Threshold)
+	if len(flagged) == 0 {
+		return true
+	}
+	s.traceMessage(requestID, "proxy", "in", r.URL.Path, "moderation_flagged", strings.Join(flagged, ","))
+	if !cfg.BlockOnFail {
+		log.Printf("[WARN] moderation flagged request_id=%s categories=%s", requestID, strings.Join(flagged, ","))
+		return true
+	}
+	writeJSON(w, http.StatusUnavailableForLegalReasons, map[string]any{
+		"error": map[string]any{
+			"message":    "request blocked by content moderation",
+			"type":       "moderation_error",
+			"categories": flagged,
+		},
+	})
+	return false
+}
+
+// lastUserText returns the text of the last user message in items.
+func lastUserText(items []OpenAIItem) string {
+	for i := len(items) - 1; i >= 0; i-- {
+		if (items[i].Type == "" || items[i].Type == "message") && items[i].Role == "user" {
+			return extractText(items[i].Content)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"godex/pkg/harness"
+	"godex/pkg/harness/openai"
+	"godex/pkg/router"
+)
+
+type fakeModerator struct {
+	scores map[string]float64
+	text   string
+}
+
+func (f *fakeModerator) Moderate(_ context.Context, text string) (*openai.ModerationResult, error) {
+	f.text = text
+	return &openai.ModerationResult{CategoryScores: f.scores}, nil
+}
+
+func TestModerationBlocksFlaggedMessage(t *testing.T) {
+	mock := harness.NewMock(harness.MockConfig{
+		HarnessName: "mock",
+		Responses:   [][]harness.Event{{harness.NewTextEvent("ok")}},
+	})
+	r := router.New(router.Config{UserPatterns: map[string][]string{"mock": {"any-model"}}})
+	r.Register("mock", mock)
+	moderator := &fakeModerator{scores: map[string]float64{"violence": 0.9, "harassment": 0.2}}
+	srv := &Server{
+		cfg: Config{AllowAnyKey: true, Moderation: ModerationConfig{
+			Enabled: true, Threshold: 0.5, BlockOnFail: true, Moderator: moderator,
+		}},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+	}
+	send := func() *httptest.ResponseRecorder {
+		body, _ := json.Marshal(OpenAIChatRequest{
+			Model: "any-model",
+			Messages: []OpenAIChatMessage{
+				{Role: "system", Content: "be nice"},
+				{Role: "user", Content: "bad words"},
+			},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test")
+		w := httptest.NewRecorder()
+		srv.handleChatCompletions(w, req)
+		return w
+	}
+
+	w := send()
+	if w.Code != http.StatusUnavailableForLegalReasons {
+		t.Fatalf("expected 451, got %d: %s", w.Code, w.Body.String())
+	}
+	if moderator.text != "bad words" {
+		t.Errorf("moderated %q, want the user message", moderator.text)
+	}
+	var resp struct {
+		Error struct {
+			Categories []string `json:"categories"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Error.Categories) != 1 || resp.Error.Categories[0] != "violence" {
+		t.Errorf("categories = %v, want [violence]", resp.Error.Categories)
+	}
+
+	// Without BlockOnFail the request is only logged.
+	srv.cfg.Moderation.BlockOnFail = false
+	if w := send(); w.Code != http.StatusOK {
+		t.Fatalf("expected 200 without block_on_fail, got %d", w.Code)
+	}
+}
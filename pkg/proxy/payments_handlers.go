@@ -2,8 +2,11 @@ package proxy
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+
+	"godex/pkg/auth"
 )
 
 func (s *Server) handlePaymentRedeem(w http.ResponseWriter, r *http.Request) bool {
@@ -48,6 +51,9 @@ func (s *Server) issuePaymentChallenge(w http.ResponseWriter, r *http.Request, p
 }
 
 func (s *Server) requireAuthOrPayment(w http.ResponseWriter, r *http.Request, model string) (*KeyRecord, bool) {
+	if !s.allowIP(w, r) {
+		return nil, false
+	}
 	if s.handlePaymentRedeem(w, r) {
 		return nil, false
 	}
@@ -59,9 +65,42 @@ func (s *Server) requireAuthOrPayment(w http.ResponseWriter, r *http.Request, mo
 		writeError(w, http.StatusUnauthorized, errUnauthorized())
 		return nil, false
 	}
-	key, ok := s.requireAuth(w, r)
+	return s.requireModelAuth(w, r, auth.ScopeChat, model)
+}
+
+// requireModelAuth is requireAuth for routes that name a model: the key
+// must also allow model. Every such route authenticates through here, so
+// the allowlist cannot be skipped.
+func (s *Server) requireModelAuth(w http.ResponseWriter, r *http.Request, scope, model string) (*KeyRecord, bool) {
+	key, ok := s.requireAuth(w, r, scope)
 	if !ok {
 		return nil, false
 	}
+	if !s.keyAllowsModel(key, model) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("model %q not allowed for this key", model))
+		return nil, false
+	}
 	return key, true
 }
+
+// keyAllowsModel checks the key's model allowlist. The requested model and
+// the allowlist entries are both expanded through the alias map, so an
+// alias reaches exactly the models its target would.
+func (s *Server) keyAllowsModel(key *KeyRecord, model string) bool {
+	if key == nil || len(key.AllowedModels) == 0 {
+		return true
+	}
+	expand := func(m string) string {
+		if s.harnessRouter == nil {
+			return m
+		}
+		return s.harnessRouter.ExpandAlias(m)
+	}
+	model = expand(model)
+	for _, m := range key.AllowedModels {
+		if expand(m) == model {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,8 @@ package proxy
 
 import (
 	"fmt"
+	"math"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,17 +24,58 @@ func newRateLimiter(ratePerSec float64, capacity float64) *rateLimiter {
 }
 
 func (l *rateLimiter) Allow() bool {
+	ok, _ := l.allow()
+	return ok
+}
+
+// allow consumes one token when available and reports the limiter state
+// observed after the decision.
+func (l *rateLimiter) allow() (bool, RateLimitState) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	now := time.Now()
 	elapsed := now.Sub(l.last).Seconds()
 	l.last = now
 	l.budget = minFloat(l.capacity, l.budget+elapsed*l.ratePerSec)
+	ok := false
 	if l.budget >= 1 {
 		l.budget -= 1
-		return true
+		ok = true
+	}
+	return ok, l.stateLocked()
+}
+
+func (l *rateLimiter) stateLocked() RateLimitState {
+	st := RateLimitState{
+		Limit:     int(l.capacity),
+		Remaining: int(math.Floor(l.budget)),
+	}
+	if l.ratePerSec > 0 {
+		st.Reset = secondsToDuration((l.capacity - l.budget) / l.ratePerSec)
+		if l.budget < 1 {
+			st.RetryAfter = secondsToDuration((1 - l.budget) / l.ratePerSec)
+		}
+	}
+	return st
+}
+
+// RateLimitState describes a key's rate limit budget for response headers.
+type RateLimitState struct {
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed; zero if allowed now
+}
+
+// WriteHeaders sets X-RateLimit-* headers and, when the budget is exhausted,
+// Retry-After. Durations are rounded up to whole seconds.
+func (st RateLimitState) WriteHeaders(h http.Header) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(st.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(st.Remaining))
+	h.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(st.Reset)))
+	if st.RetryAfter > 0 {
+		h.Set("Retry-After", strconv.Itoa(ceilSeconds(st.RetryAfter)))
 	}
-	return false
 }
 
 type LimiterStore struct {
@@ -46,6 +89,20 @@ func NewLimiterStore(defRate string, defBurst int) *LimiterStore {
 	return &LimiterStore{entries: map[string]*rateLimiter{}, defRate: defRate, defBurst: defBurst}
 }
 
+// SetDefaults changes the rate and burst used for keys without their own.
+// When they change, existing limiters are dropped and rebuilt on the next
+// request, so their buckets start full.
+func (s *LimiterStore) SetDefaults(defRate string, defBurst int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if defRate == s.defRate && defBurst == s.defBurst {
+		return
+	}
+	s.defRate = defRate
+	s.defBurst = defBurst
+	s.entries = map[string]*rateLimiter{}
+}
+
 func (s *LimiterStore) Allow(keyID string, rateSpec string, burst int) bool {
 	lim := s.getLimiter(keyID, rateSpec, burst)
 	if lim == nil {
@@ -54,6 +111,18 @@ func (s *LimiterStore) Allow(keyID string, rateSpec string, burst int) bool {
 	return lim.Allow()
 }
 
+// Check consumes one request from the key's budget and returns the resulting
+// state. ok is false when the key has exhausted its budget; a nil state means
+// the key has no usable rate spec and is not limited.
+func (s *LimiterStore) Check(keyID string, rateSpec string, burst int) (bool, *RateLimitState) {
+	lim := s.getLimiter(keyID, rateSpec, burst)
+	if lim == nil {
+		return true, nil
+	}
+	ok, st := lim.allow()
+	return ok, &st
+}
+
 func (s *LimiterStore) getLimiter(keyID string, rateSpec string, burst int) *rateLimiter {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -113,3 +182,17 @@ func minFloat(a, b float64) float64 {
 	}
 	return b
 }
+
+func secondsToDuration(sec float64) time.Duration {
+	if sec <= 0 {
+		return 0
+	}
+	return time.Duration(sec * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int(math.Ceil(d.Seconds()))
+}
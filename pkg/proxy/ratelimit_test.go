@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowRequestSetsRateLimitHeaders(t *testing.T) {
+	s := &Server{limiters: NewLimiterStore("2/m", 2)}
+	key := &KeyRecord{ID: "key_test"}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	if ok, _ := s.allowRequest(rr, req, key); !ok {
+		t.Fatalf("expected first request to be allowed")
+	}
+	if got := rr.Header().Get("X-RateLimit-Limit"); got != "2" {
+		t.Fatalf("X-RateLimit-Limit = %q, want 2", got)
+	}
+	if got := rr.Header().Get("X-RateLimit-Remaining"); got != "1" {
+		t.Fatalf("X-RateLimit-Remaining = %q, want 1", got)
+	}
+	if got := rr.Header().Get("X-RateLimit-Reset"); got == "" || got == "0" {
+		t.Fatalf("expected non-zero X-RateLimit-Reset, got %q", got)
+	}
+	if got := rr.Header().Get("Retry-After"); got != "" {
+		t.Fatalf("unexpected Retry-After on allowed request: %q", got)
+	}
+
+	_, _ = s.allowRequest(httptest.NewRecorder(), req, key)
+
+	rr = httptest.NewRecorder()
+	if ok, reason := s.allowRequest(rr, req, key); ok || reason != "rate" {
+		t.Fatalf("expected rate limit rejection, got ok=%v reason=%q", ok, reason)
+	}
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	if got := rr.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Fatalf("X-RateLimit-Remaining = %q, want 0", got)
+	}
+	// 2/m refills one token every 30s.
+	if got := rr.Header().Get("Retry-After"); got != "30" {
+		t.Fatalf("Retry-After = %q, want 30", got)
+	}
+}
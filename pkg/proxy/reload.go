@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"context"
+	"strings"
+
+	"godex/pkg/admin"
+)
+
+// Reload applies the settings in cfg that can change while the proxy runs:
+// the log level, default rate limit and burst, metering window, cache TTL
+// and response headers. Settings bound at startup, such as the listen
+// address and TLS files, are not applied; a warning asks for a restart
+// when they differ.
+func (s *Server) Reload(cfg Config) {
+	if cfg.Listen == "" {
+		cfg.Listen = "127.0.0.1:39001"
+	}
+	if cfg.Listen != s.cfg.Listen {
+		s.logger.Warn("listen address changed; restart required", "listen", s.cfg.Listen, "new_listen", cfg.Listen)
+	}
+	if cfg.TLSCertFile != s.cfg.TLSCertFile || cfg.TLSKeyFile != s.cfg.TLSKeyFile {
+		s.logger.Warn("tls files changed; restart required")
+	}
+	if strings.TrimSpace(cfg.RateLimit) == "" {
+		cfg.RateLimit = "60/m"
+	}
+	if cfg.Burst == 0 {
+		cfg.Burst = 10
+	}
+
+	s.logger.SetLevel(ParseLogLevel(cfg.LogLevel))
+	s.limiters.SetDefaults(cfg.RateLimit, cfg.Burst)
+	s.usage.SetWindow(cfg.MeterWindow)
+	s.cache.SetTTL(cfg.CacheTTL)
+	s.headers.set(cfg.ResponseHeaders)
+	s.logger.Info("config reloaded", "log_level", cfg.LogLevel, "rate", cfg.RateLimit)
+}
+
+// reloadConfig reloads the config file through cfg.ConfigReload and
+// applies it, for POST /admin/reload.
+func (s *Server) reloadConfig() (admin.ReloadResult, error) {
+	next, result, err := s.cfg.ConfigReload()
+	if err != nil {
+		return admin.ReloadResult{}, err
+	}
+	if len(result.Changes) > 0 {
+		s.Reload(next)
+	}
+	return result, nil
+}
+
+// watchReloads applies each config received on reloads until ctx is done.
+func (s *Server) watchReloads(ctx context.Context, reloads <-chan Config) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case cfg, ok := <-reloads:
+			if !ok {
+				return
+			}
+			s.Reload(cfg)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"godex/pkg/admin"
+)
+
+func TestServerReload(t *testing.T) {
+	srv := &Server{
+		cfg:      Config{Listen: "127.0.0.1:39001"},
+		cache:    NewCache(0),
+		usage:    NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters: NewLimiterStore("60/m", 10),
+		logger:   NewLogger(LogLevelInfo),
+	}
+	srv.headers.set(nil)
+
+	srv.Reload(Config{
+		LogLevel:        "debug",
+		RateLimit:       "1/m",
+		Burst:           1,
+		MeterWindow:     time.Hour,
+		CacheTTL:        time.Minute,
+		ResponseHeaders: map[string]string{"X-Reloaded": "yes"},
+	})
+
+	if !srv.logger.DebugEnabled() {
+		t.Error("log level not reloaded")
+	}
+	if !srv.limiters.Allow("k", "", 0) || srv.limiters.Allow("k", "", 0) {
+		t.Error("default rate limit not reloaded")
+	}
+	if srv.usage.window != time.Hour {
+		t.Errorf("meter window = %v, want 1h", srv.usage.window)
+	}
+	if srv.cache.ttl != time.Minute {
+		t.Errorf("cache ttl = %v, want 1m", srv.cache.ttl)
+	}
+	rr := httptest.NewRecorder()
+	responseHeadersMiddleware(&srv.headers, http.NotFoundHandler()).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rr.Header().Get("X-Reloaded"); got != "yes" {
+		t.Errorf("X-Reloaded = %q, want yes", got)
+	}
+}
+
+func TestServerReloadConfig(t *testing.T) {
+	srv := &Server{logger: NewLogger(LogLevelInfo), cache: NewCache(0), usage: NewUsageStore("", "", 0, 0, 0, "", 0, 0), limiters: NewLimiterStore("60/m", 10)}
+	srv.headers.set(nil)
+	var changes []admin.ConfigChange
+	srv.cfg.ConfigReload = func() (Config, admin.ReloadResult, error) {
+		return Config{LogLevel: "debug"}, admin.ReloadResult{Changes: changes}, nil
+	}
+
+	if _, err := srv.reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if srv.logger.DebugEnabled() {
+		t.Error("config applied without changes")
+	}
+	changes = []admin.ConfigChange{{Field: "proxy.log_level", Old: "info", New: "debug"}}
+	if _, err := srv.reloadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if !srv.logger.DebugEnabled() {
+		t.Error("log level not reloaded")
+	}
+}
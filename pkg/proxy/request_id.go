@@ -0,0 +1,33 @@
+package proxy
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// maxClientRequestIDLen bounds client-supplied X-Request-ID values so they
+// cannot bloat logs.
+const maxClientRequestIDLen = 128
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxClientRequestIDLen {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newUUID returns a random RFC 4122 version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return newResponseID("pxreq")
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
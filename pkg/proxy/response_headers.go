@@ -0,0 +1,66 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// reservedResponseHeaders are owned by the handlers and cannot be
+// overridden through Config.ResponseHeaders.
+var reservedResponseHeaders = map[string]bool{
+	"Content-Type":   true,
+	"Content-Length": true,
+}
+
+// expandResponseHeaders canonicalizes header names, expands ${ENV_VAR}
+// references in values and drops reserved headers.
+func expandResponseHeaders(headers map[string]string) http.Header {
+	out := http.Header{}
+	for name, value := range headers {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if name == "" || reservedResponseHeaders[name] {
+			continue
+		}
+		out.Set(name, os.ExpandEnv(value))
+	}
+	return out
+}
+
+// responseHeaderSet holds the expanded response headers so Reload can swap
+// them while requests are being served.
+type responseHeaderSet struct {
+	extra atomic.Pointer[http.Header]
+}
+
+// set replaces the headers with the expansion of headers.
+func (rs *responseHeaderSet) set(headers map[string]string) {
+	extra := expandResponseHeaders(headers)
+	rs.extra.Store(&extra)
+}
+
+// responseHeadersMiddleware sets the headers in rs before the handler runs
+// so they reach JSON, SSE and error responses alike.
+func responseHeadersMiddleware(rs *responseHeaderSet, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if extra := rs.extra.Load(); extra != nil {
+			h := w.Header()
+			for name, values := range *extra {
+				h[name] = append([]string(nil), values...)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ParseResponseHeader parses a "Key=Value" pair as given to --response-header.
+func ParseResponseHeader(raw string) (string, string, error) {
+	name, value, ok := strings.Cut(raw, "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid response header %q (want Key=Value)", raw)
+	}
+	return name, strings.TrimSpace(value), nil
+}
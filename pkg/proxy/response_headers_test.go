@@ -0,0 +1,89 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"godex/pkg/harness"
+	"godex/pkg/router"
+)
+
+func TestResponseHeadersOnAllResponseTypes(t *testing.T) {
+	t.Setenv("GODEX_TEST_HSTS_AGE", "31536000")
+
+	mock := harness.NewMock(harness.MockConfig{
+		HarnessName: "mock",
+		Responses: [][]harness.Event{
+			{harness.NewTextEvent("json")},
+			{harness.NewTextEvent("sse")},
+		},
+	})
+	r := router.New(router.Config{UserPatterns: map[string][]string{"mock": {"any-model"}}})
+	r.Register("mock", mock)
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+	}
+	srv.headers.set(map[string]string{
+		"x-content-type-options":    "nosniff",
+		"Strict-Transport-Security": "max-age=${GODEX_TEST_HSTS_AGE}",
+		"Content-Type":              "text/plain",
+	})
+	h := responseHeadersMiddleware(&srv.headers, http.HandlerFunc(srv.handleChatCompletions))
+
+	send := func(stream bool, auth bool) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(OpenAIChatRequest{
+			Model:    "any-model",
+			Stream:   stream,
+			Messages: []OpenAIChatMessage{{Role: "user", Content: "hi"}},
+		})
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+		if auth {
+			req.Header.Set("Authorization", "Bearer test")
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	cases := map[string]*httptest.ResponseRecorder{
+		"json":  send(false, true),
+		"sse":   send(true, true),
+		"error": send(false, false),
+	}
+	for name, rr := range cases {
+		if got := rr.Header().Get("X-Content-Type-Options"); got != "nosniff" {
+			t.Errorf("%s: X-Content-Type-Options = %q", name, got)
+		}
+		if got := rr.Header().Get("Strict-Transport-Security"); got != "max-age=31536000" {
+			t.Errorf("%s: Strict-Transport-Security = %q", name, got)
+		}
+		if got := rr.Header().Get("Content-Type"); got == "text/plain" {
+			t.Errorf("%s: reserved Content-Type was overridden", name)
+		}
+	}
+	if cases["error"].Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for unauthenticated request, got %d", cases["error"].Code)
+	}
+	if got := cases["sse"].Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("expected SSE content type, got %q", got)
+	}
+}
+
+func TestParseResponseHeader(t *testing.T) {
+	name, value, err := ParseResponseHeader("X-Frame-Options=DENY")
+	if err != nil || name != "X-Frame-Options" || value != "DENY" {
+		t.Fatalf("got %q %q %v", name, value, err)
+	}
+	if _, _, err := ParseResponseHeader("no-equals"); err == nil {
+		t.Fatal("expected error without '='")
+	}
+}
@@ -9,11 +9,18 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"godex/pkg/admin"
 	"godex/pkg/auth"
+	"godex/pkg/circuitbreaker"
 	"godex/pkg/config"
 	"godex/pkg/harness"
 	"godex/pkg/metrics"
@@ -33,6 +40,8 @@ type ModelEntry struct {
 // Config controls proxy behavior.
 type Config struct {
 	Listen          string
+	TLSCertFile     string
+	TLSKeyFile      string
 	Version         string
 	APIKey          string
 	Model           string
@@ -65,10 +74,81 @@ type Config struct {
 	TraceBackups    int
 	MeterWindow     time.Duration
 	AdminSocket     string
-	Payments        payments.Config
-	Backends        BackendsConfig
-	Metrics         MetricsConfig
-	HarnessRouter   *router.Router
+	// AdminHTTPAddr, when set, also serves the admin endpoints over TCP,
+	// authenticated with AdminAPIKey as a bearer token.
+	AdminHTTPAddr  string
+	AdminAPIKey    string
+	AdminDebug     bool // serve pprof under /admin/debug/pprof/
+	Payments       payments.Config
+	Backends       BackendsConfig
+	Metrics        MetricsConfig
+	CircuitBreaker CircuitBreakerConfig
+	Moderation     ModerationConfig
+	DrainTimeout   time.Duration
+	// HeartbeatInterval controls SSE ": ping" comments on active streams.
+	// Zero disables heartbeats.
+	HeartbeatInterval time.Duration
+	// MaxRequestBytes caps JSON request bodies; larger bodies get 413.
+	// Zero means 20 MB.
+	MaxRequestBytes int64
+	// ModelQuotas sets default per-model token limits within the meter
+	// window. Keys may override individual models.
+	ModelQuotas map[string]int64
+	// CORSAllowOrigins enables CORS for the listed origins ("*" for any).
+	// Empty disables CORS headers entirely.
+	CORSAllowOrigins []string
+	// CORSAllowHeaders lists request headers accepted on preflight.
+	CORSAllowHeaders []string
+	// WebhookMaxRetries bounds redelivery of X-Webhook-URL callbacks.
+	// Zero means 3; negative disables retries.
+	WebhookMaxRetries int
+	// WebhookAllowedHosts lists the hosts X-Webhook-URL may name, exactly
+	// or as "*.example.com". Empty rejects every webhook.
+	WebhookAllowedHosts []string
+	// AllowIPs and DenyIPs filter callers by CIDR (or bare IP); deny wins.
+	// An empty AllowIPs admits any address not denied.
+	AllowIPs []string
+	DenyIPs  []string
+	// TrustProxyHeaders uses X-Forwarded-For / X-Real-IP for the caller
+	// address. Enable only behind a trusted reverse proxy.
+	TrustProxyHeaders bool
+	// TrustedProxies lists the reverse proxies (CIDR or bare IP) allowed to
+	// set those headers. Empty trusts only the immediate peer, so the
+	// rightmost X-Forwarded-For entry is the caller.
+	TrustedProxies []string
+	// ProbePrompt is sent to each backend by GET /v1/backends ("ping" if empty).
+	ProbePrompt string
+	// Middleware wraps the route mux for library callers of Run; the first
+	// entry runs first. See WithRecovery and WithLogging.
+	Middleware []Middleware
+	// ResponseHeaders are added to every response; values may reference
+	// ${ENV_VAR}. Content-Type and Content-Length cannot be overridden.
+	ResponseHeaders map[string]string
+	// StatsStreamMaxAge closes GET /v1/stats/stream connections after this
+	// long. Zero means one hour.
+	StatsStreamMaxAge time.Duration
+	HarnessRouter     *router.Router
+	// AllowBackendOverride lets a request name its backend in the
+	// X-Godex-Backend header, bypassing routing. Unknown names are ignored.
+	AllowBackendOverride bool
+	// Reloads delivers configs to apply with Server.Reload while the proxy
+	// runs. Nil disables reloading.
+	Reloads <-chan Config
+	// ConfigReload, when set, serves POST /admin/reload. It re-reads the
+	// config file and returns the config to apply and what changed.
+	ConfigReload func() (Config, admin.ReloadResult, error)
+	// AdminConfig, when set, returns the running config with its secrets
+	// masked, for GET /admin/config.
+	AdminConfig func() any
+	// JWT, when enabled, accepts bearer tokens signed by an identity
+	// provider alongside keys from KeysPath.
+	JWT auth.JWTConfig
+	// HMACSecret, when set, accepts keys from KeyStore.GenerateSigned
+	// without a key store lookup.
+	HMACSecret string
+	// ServiceAccountSecret, when set, accepts tokens from
+	// auth.IssueServiceAccount alongside keys from KeysPath.
+	ServiceAccountSecret string
 }
 
 // BackendsConfig configures available LLM backends.
@@ -88,9 +168,8 @@ type MetricsConfig struct {
 
 // CodexBackendConfig configures the Codex/ChatGPT backend.
 type CodexBackendConfig struct {
-	Enabled         bool
-	BaseURL         string
-	CredentialsPath string
+	Enabled bool
+	BaseURL string
 }
 
 // AnthropicBackendConfig configures the Anthropic backend.
@@ -102,8 +181,14 @@ type AnthropicBackendConfig struct {
 
 // RoutingConfig configures model-to-backend routing.
 type RoutingConfig struct {
-	Patterns map[string][]string
-	Aliases  map[string]string
+	Patterns            map[string][]string
+	Aliases             map[string]string
+	StickySessionTTL    time.Duration
+	Weights             map[string]int
+	LatencyRouting      bool
+	WarmupRequests      int
+	Canary              router.CanaryConfig
+	HealthCheckInterval time.Duration
 }
 
 type Server struct {
@@ -121,9 +206,25 @@ type Server struct {
 	payments      payments.Gateway
 	models        map[string]ModelEntry
 	harnessRouter *router.Router
+	breakers      map[string]*circuitbreaker.Breaker
+	probes        probeCache
+	ipFilter      *ipFilter
+	stats         liveStats
+	stopping      chan struct{}
+	stopOnce      sync.Once
+	streams       sync.WaitGroup
+	activeStreams atomic.Int64
+	headers       responseHeaderSet
 }
 
 func Run(cfg Config) error {
+	if err := validateTLS(cfg); err != nil {
+		return err
+	}
+	ipf, ipErr := newIPFilter(cfg.AllowIPs, cfg.DenyIPs, cfg.TrustProxyHeaders, cfg.TrustedProxies)
+	if ipErr != nil {
+		return ipErr
+	}
 	if cfg.Listen == "" {
 		cfg.Listen = "127.0.0.1:39001"
 	}
@@ -167,6 +268,15 @@ func Run(cfg Config) error {
 	if cfg.Burst == 0 {
 		cfg.Burst = 10
 	}
+	if cfg.MaxRequestBytes == 0 {
+		cfg.MaxRequestBytes = defaultMaxRequestBytes
+	}
+	if cfg.WebhookMaxRetries == 0 {
+		cfg.WebhookMaxRetries = defaultWebhookMaxRetries
+	}
+	if cfg.DrainTimeout == 0 {
+		cfg.DrainTimeout = 30 * time.Second
+	}
 
 	authPath := strings.TrimSpace(cfg.AuthPath)
 	var err error
@@ -195,6 +305,7 @@ func Run(cfg Config) error {
 
 	usage := NewUsageStore(cfg.StatsPath, cfg.StatsSummary, cfg.StatsMaxBytes, cfg.StatsMaxBackups, cfg.MeterWindow, cfg.EventsPath, cfg.EventsMaxBytes, cfg.EventsBackups)
 	_ = usage.LoadFromFile()
+	usage.SetModelQuotas(cfg.ModelQuotas)
 	limiters := NewLimiterStore(cfg.RateLimit, cfg.Burst)
 	payGateway := payments.NewTokenMeterGateway(cfg.Payments)
 
@@ -237,7 +348,13 @@ func Run(cfg Config) error {
 		models:        models,
 		harnessRouter: cfg.HarnessRouter,
 		metrics:       metricsCollector,
+		ipFilter:      ipf,
+		stopping:      make(chan struct{}),
 	}
+	if cfg.HarnessRouter != nil {
+		s.breakers = newBreakers(cfg.CircuitBreaker, cfg.HarnessRouter)
+	}
+	s.headers.set(cfg.ResponseHeaders)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v1/models/", s.handleModelByID) // must come before /v1/models
@@ -245,30 +362,90 @@ func Run(cfg Config) error {
 	mux.HandleFunc("/v1/pricing", s.handlePricing)
 	mux.HandleFunc("/v1/responses", s.handleResponses)
 	mux.HandleFunc("/v1/chat/completions", s.handleChatCompletions)
+	mux.HandleFunc("/v1/completions", s.handleCompletions)
+	mux.HandleFunc("/v1/backends", s.handleBackends)
+	mux.HandleFunc("/v1/usage", s.handleUsage)
+	mux.HandleFunc("/v1/stats/stream", s.handleStatsStream)
 	mux.HandleFunc("/metrics", s.handleMetrics)
 	mux.HandleFunc("/health", s.handleHealth)
 
 	server := &http.Server{
 		Addr:              cfg.Listen,
-		Handler:           mux,
+		Handler:           corsMiddleware(cfg.CORSAllowOrigins, cfg.CORSAllowHeaders, responseHeadersMiddleware(&s.headers, s.countRequests(WithRequestID(chainMiddleware(mux, cfg.Middleware))))),
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
-	if strings.TrimSpace(cfg.AdminSocket) != "" {
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-		go func() {
-			adminSrv := admin.New(cfg.AdminSocket, adminAdapter{keys: keys})
-			_ = adminSrv.Start(ctx)
-		}()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if cfg.Reloads != nil {
+		go s.watchReloads(ctx, cfg.Reloads)
+	}
+
+	if strings.TrimSpace(cfg.AdminSocket) != "" || strings.TrimSpace(cfg.AdminHTTPAddr) != "" {
+		adapter := adminAdapter{keys: keys, stats: &s.stats, logger: s.logger, server: s}
+		adminSrv := admin.New(cfg.AdminSocket, adapter)
+		adminSrv.EnableStats(adapter)
+		adminSrv.EnableLogs(adapter)
+		adminSrv.EnableStatus(adapter)
+		if cfg.ConfigReload != nil {
+			adminSrv.EnableReload(admin.ReloadFunc(s.reloadConfig))
+		}
+		if cfg.AdminDebug {
+			adminSrv.EnableDebug()
+		}
+		if strings.TrimSpace(cfg.AdminSocket) != "" {
+			go func() {
+				_ = adminSrv.Start(ctx)
+			}()
+		}
+		if strings.TrimSpace(cfg.AdminHTTPAddr) != "" {
+			go func() {
+				if err := adminSrv.StartHTTP(ctx, cfg.AdminHTTPAddr, cfg.AdminAPIKey); err != nil {
+					s.logger.Warn("admin http server stopped", "addr", cfg.AdminHTTPAddr, "error", err.Error())
+				}
+			}()
+		}
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if tlsEnabled(cfg) {
+			serveErr <- server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+			return
+		}
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+		stop()
+		return s.shutdown(server, cfg.DrainTimeout)
+	}
+}
+
+// validateTLS rejects configs where only one half of the cert/key pair is set.
+func validateTLS(cfg Config) error {
+	cert := strings.TrimSpace(cfg.TLSCertFile)
+	key := strings.TrimSpace(cfg.TLSKeyFile)
+	if cert == "" && key != "" {
+		return errors.New("tls: key file set without cert file (use --cert with --key)")
 	}
+	if cert != "" && key == "" {
+		return errors.New("tls: cert file set without key file (use --key with --cert)")
+	}
+	return nil
+}
 
-	return server.ListenAndServe()
+func tlsEnabled(cfg Config) bool {
+	return strings.TrimSpace(cfg.TLSCertFile) != "" && strings.TrimSpace(cfg.TLSKeyFile) != ""
 }
 
 func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	key, ok := s.requireAuth(w, r)
+	key, ok := s.requireAuth(w, r, auth.ScopeRead)
 	if !ok {
 		return
 	}
@@ -276,10 +453,32 @@ func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Pagination: ?limit=N&after=<model id>, as in OpenAI list endpoints.
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("limit must be a positive integer"))
+			s.logRequest(r, http.StatusBadRequest, start)
+			return
+		}
+		limit = n
+	}
+	after := r.URL.Query().Get("after")
+
 	// Try to get models from harness router first, then backend router
 	var data []OpenAIModel
+	resp := OpenAIModelsResponse{Object: "list"}
 	if s.harnessRouter != nil {
-		models := s.harnessRouter.AllModels(r.Context())
+		fetch := 0
+		if limit > 0 {
+			fetch = limit + 1 // one extra to tell whether more follow
+		}
+		models, total := s.harnessRouter.AllModelsAfter(r.Context(), after, fetch)
+		if limit > 0 && len(models) > limit {
+			models = models[:limit]
+			resp.HasMore = true
+		}
 		for _, m := range models {
 			data = append(data, OpenAIModel{
 				ID:      m.ID,
@@ -287,9 +486,16 @@ func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 				OwnedBy: "godex",
 			})
 		}
+		if len(models) > 0 {
+			resp.FirstID = models[0].ID
+			resp.LastID = models[len(models)-1].ID
+		} else if total > 0 {
+			// Past the last page: an empty page, not the fallbacks below.
+			data = []OpenAIModel{}
+		}
 	}
 	// Fall back to configured models
-	if len(data) == 0 {
+	if data == nil {
 		for _, m := range s.models {
 			data = append(data, OpenAIModel{
 				ID:      m.ID,
@@ -300,7 +506,7 @@ func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Final fallback to default model
-	if len(data) == 0 {
+	if data == nil {
 		data = []OpenAIModel{{
 			ID:      s.cfg.Model,
 			Object:  "model",
@@ -308,10 +514,7 @@ func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 		}}
 	}
 
-	resp := OpenAIModelsResponse{
-		Object: "list",
-		Data:   data,
-	}
+	resp.Data = data
 	writeJSON(w, http.StatusOK, resp)
 	s.logRequest(r, http.StatusOK, start)
 }
@@ -319,7 +522,9 @@ func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
 // handleModelByID handles GET /v1/models/{model_id}
 func (s *Server) handleModelByID(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	key, ok := s.requireAuth(w, r)
+	// Extract model ID from path
+	modelID := strings.TrimPrefix(r.URL.Path, "/v1/models/")
+	key, ok := s.requireModelAuth(w, r, auth.ScopeRead, modelID)
 	if !ok {
 		return
 	}
@@ -327,8 +532,6 @@ func (s *Server) handleModelByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract model ID from path
-	modelID := strings.TrimPrefix(r.URL.Path, "/v1/models/")
 	if modelID == "" {
 		writeError(w, http.StatusBadRequest, fmt.Errorf("model ID required"))
 		s.logRequest(r, http.StatusBadRequest, start)
@@ -342,21 +545,25 @@ func (s *Server) handleModelByID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if harness router can handle this model
-	if s.harnessRouter != nil {
-		if h := s.harnessRouter.HarnessFor(expandedID); h != nil {
-			resp := OpenAIModelDetail{
-				ID:      expandedID,
-				Object:  "model",
-				OwnedBy: "godex",
-				Backend: h.Name(),
-			}
-			if modelID != expandedID {
-				resp.Alias = modelID
-			}
-			writeJSON(w, http.StatusOK, resp)
-			s.logRequest(r, http.StatusOK, start)
-			return
+	if s.harnessRouter != nil && s.harnessRouter.Routable(expandedID) {
+		resp := OpenAIModelDetail{
+			ID:      expandedID,
+			Object:  "model",
+			OwnedBy: "godex",
 		}
+		if candidates := s.harnessRouter.Candidates(expandedID); len(candidates) > 0 {
+			resp.Backend = s.harnessRouter.Get(candidates[0]).Name()
+		}
+		if modelID != expandedID {
+			resp.Alias = modelID
+		}
+		if r.URL.Query().Get("explain") == "true" {
+			explanation := s.harnessRouter.ExplainRouting(modelID)
+			resp.RouteExplanation = &explanation
+		}
+		writeJSON(w, http.StatusOK, resp)
+		s.logRequest(r, http.StatusOK, start)
+		return
 	}
 
 	// Model not found
@@ -372,6 +579,8 @@ type OpenAIModelDetail struct {
 	DisplayName string `json:"display_name,omitempty"`
 	Backend     string `json:"backend,omitempty"`
 	Alias       string `json:"alias,omitempty"`
+	// RouteExplanation is added with ?explain=true.
+	*router.RouteExplanation
 }
 
 func (s *Server) resolveModel(model string) (ModelEntry, bool) {
@@ -383,13 +592,13 @@ func (s *Server) resolveModel(model string) (ModelEntry, bool) {
 		model = s.harnessRouter.ExpandAlias(model)
 	}
 	if m, ok := s.models[model]; ok {
-		if s.harnessRouter == nil || s.harnessRouter.HarnessFor(model) == nil {
+		if s.harnessRouter == nil || !s.harnessRouter.Routable(model) {
 			return ModelEntry{}, false
 		}
 		return m, true
 	}
 	// If harness router has a harness for this model, allow it
-	if s.harnessRouter != nil && s.harnessRouter.HarnessFor(model) != nil {
+	if s.harnessRouter != nil && s.harnessRouter.Routable(model) {
 		return ModelEntry{ID: model, BaseURL: ""}, true
 	}
 	return ModelEntry{}, false
@@ -397,12 +606,13 @@ func (s *Server) resolveModel(model string) (ModelEntry, bool) {
 
 func (s *Server) handleResponses(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	requestID := newResponseID("pxreq")
+	requestID, _ := harness.RequestID(r.Context())
 	var req OpenAIResponsesRequest
-	if err := readJSON(r, &req); err != nil {
+	if err := readJSON(r, &req, s.cfg.MaxRequestBytes); err != nil {
 		s.traceMessage(requestID, "proxy", "in", "/v1/responses", "openclaw_request_decode_error", err.Error())
-		writeError(w, http.StatusBadRequest, err)
-		s.logRequest(r, http.StatusBadRequest, start)
+		status := readJSONStatus(err)
+		writeError(w, status, err)
+		s.logRequest(r, status, start)
 		return
 	}
 	if raw, err := json.Marshal(req); err == nil {
@@ -426,6 +636,16 @@ func (s *Server) handleResponses(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if !s.allowModel(w, key, req.Model) {
+		s.logRequest(r, http.StatusTooManyRequests, start)
+		return
+	}
+	target, err := webhookURL(r, s.cfg.WebhookAllowedHosts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		s.logRequest(r, http.StatusBadRequest, start)
+		return
+	}
 
 	sessionKey := s.sessionKey(req.User, r)
 	items, err := parseOpenAIInput(req.Input)
@@ -439,6 +659,10 @@ func (s *Server) handleResponses(w http.ResponseWriter, r *http.Request) {
 	if req.Stream != nil {
 		stream = *req.Stream
 	}
+	if !s.moderate(w, r, requestID, items) {
+		s.logRequest(r, http.StatusUnavailableForLegalReasons, start)
+		return
+	}
 	if badPairs := countInvalidExecPairs(items); badPairs > 0 {
 		s.traceMessage(requestID, "proxy", "in", "/v1/responses", "drop_invalid_exec_pairs", fmt.Sprintf("count=%d", badPairs))
 		items = dropInvalidExecPairs(items)
@@ -456,16 +680,22 @@ func (s *Server) handleResponses(w http.ResponseWriter, r *http.Request) {
 	_, tools = resolveToolChoice(req.ToolChoice, tools)
 
 	// Try harness-based routing first
-	if h := s.harnessForModel(req.Model); h != nil {
+	if h := s.harnessForModel(r.Context(), sessionKey, req.Model, s.backendOverride(r), requiredCapabilities(items)); h != nil {
 		turn := buildTurnFromResponses(req.Model, instructions, input, tools, nil)
 		if rawTurn, err := json.Marshal(turn); err == nil {
 			s.tracePayload(requestID, "proxy_harness", "out", "/v1/responses", "harness_turn", json.RawMessage(rawTurn))
 		}
+		s.logTokenEstimate(r.Context(), h, turn, requestID)
 		var auditReqJSON json.RawMessage
 		if s.audit != nil {
 			auditReqJSON, _ = json.Marshal(req)
 		}
 
+		if target != "" {
+			s.startWebhookJob(requestContext(r), w, target, h, turn, req.Model, key, start, auditReqJSON, sessionKey, requestID)
+			s.logRequest(r, http.StatusAccepted, start)
+			return
+		}
 		if !stream {
 			s.harnessResponsesNonStream(requestContext(r), w, h, turn, req.Model, key, start, auditReqJSON, sessionKey, requestID)
 			s.logRequest(r, http.StatusOK, start)
@@ -481,6 +711,10 @@ func (s *Server) handleResponses(w http.ResponseWriter, r *http.Request) {
 			s.logRequest(r, http.StatusInternalServerError, start)
 			return
 		}
+		defer s.trackStream()()
+		var stopHeartbeat func()
+		w, flusher, stopHeartbeat = s.startHeartbeat(w, flusher)
+		defer stopHeartbeat()
 		if err := s.harnessResponsesStream(requestContext(r), w, flusher, h, turn, req.Model, key, start, auditReqJSON, sessionKey, requestID); err != nil {
 			s.traceMessage(requestID, "proxy", "out", "/v1/responses", "stream_error", err.Error())
 			_ = writeSSE(w, flusher, map[string]any{
@@ -497,11 +731,28 @@ func (s *Server) handleResponses(w http.ResponseWriter, r *http.Request) {
 		s.logRequest(r, http.StatusOK, start)
 		return
 	}
-	writeError(w, http.StatusBadRequest, fmt.Errorf("model %q not available", req.Model))
+	writeError(w, http.StatusBadRequest, noHarnessError(req.Model, requiredCapabilities(items)))
 	s.logRequest(r, http.StatusBadRequest, start)
 }
 
-func (s *Server) requireAuth(w http.ResponseWriter, r *http.Request) (*KeyRecord, bool) {
+// requireAuth authenticates the request and checks that its key grants
+// scope, the auth.ScopeRead or auth.ScopeChat class of the endpoint.
+func (s *Server) requireAuth(w http.ResponseWriter, r *http.Request, scope string) (*KeyRecord, bool) {
+	rec, ok := s.authenticate(w, r)
+	if !ok {
+		return nil, false
+	}
+	if !rec.AllowsScope(scope) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("key lacks the %q scope", scope))
+		return nil, false
+	}
+	return rec, true
+}
+
+func (s *Server) authenticate(w http.ResponseWriter, r *http.Request) (*KeyRecord, bool) {
+	if !s.allowIP(w, r) {
+		return nil, false
+	}
 	authz := r.Header.Get("Authorization")
 	if !strings.HasPrefix(authz, "Bearer ") {
 		if s.cfg.AllowAnyKey {
@@ -514,11 +765,46 @@ func (s *Server) requireAuth(w http.ResponseWriter, r *http.Request) (*KeyRecord
 	if s.cfg.AllowAnyKey {
 		return &KeyRecord{ID: hashToken(token), Label: "anonymous"}, true
 	}
+	if s.cfg.JWT.Enabled() && strings.Count(token, ".") == 2 {
+		claims, err := auth.ValidateJWT(r.Context(), token, s.cfg.JWT)
+		if err == nil {
+			rec, err := jwtKeyRecord(claims)
+			if err != nil {
+				s.logger.Warn("jwt rejected", "error", err.Error())
+				writeError(w, http.StatusUnauthorized, errors.New("invalid bearer token"))
+				return nil, false
+			}
+			return rec, true
+		}
+		if s.keys == nil {
+			s.logger.Warn("jwt rejected", "error", err.Error())
+			writeError(w, http.StatusUnauthorized, errors.New("invalid bearer token"))
+			return nil, false
+		}
+	}
+	if s.cfg.ServiceAccountSecret != "" && auth.IsServiceAccountToken(token) {
+		cred, err := auth.ValidateServiceAccount(token, []byte(s.cfg.ServiceAccountSecret))
+		if err != nil {
+			s.logger.Warn("service account token rejected", "error", err.Error())
+			writeError(w, http.StatusUnauthorized, errors.New("invalid bearer token"))
+			return nil, false
+		}
+		if s.keys != nil && s.keys.revoked(cred.ID) {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid bearer token"))
+			return nil, false
+		}
+		return &KeyRecord{ID: cred.ID, Label: cred.Label, Scopes: cred.Scopes}, true
+	}
 	// static api_key disabled; use key store or --allow-any-key
 	if s.keys == nil {
 		writeError(w, http.StatusUnauthorized, errors.New("invalid bearer token"))
 		return nil, false
 	}
+	if s.cfg.HMACSecret != "" && strings.HasPrefix(token, signedKeyPrefix) {
+		if rec, ok := s.keys.ValidateSigned(token, []byte(s.cfg.HMACSecret)); ok {
+			return rec, true
+		}
+	}
 	rec, ok := s.keys.Validate(token)
 	if !ok {
 		writeError(w, http.StatusUnauthorized, errors.New("invalid bearer token"))
@@ -527,6 +813,31 @@ func (s *Server) requireAuth(w http.ResponseWriter, r *http.Request) (*KeyRecord
 	return &rec, true
 }
 
+// jwtKeyRecord is the key a validated JWT stands for. It has no limits of
+// its own, so the proxy defaults apply. Its ID is "jwt:" and the subject,
+// so a subject cannot collide with a key store ID. The token's read and
+// chat scopes become the key's; a token whose scopes include neither is
+// rejected rather than treated as unscoped.
+func jwtKeyRecord(claims *auth.JWTClaims) (*KeyRecord, error) {
+	rec := &KeyRecord{ID: "jwt:" + claims.Subject, Label: claims.Email}
+	for _, scope := range claims.Scopes {
+		if (scope == auth.ScopeRead || scope == auth.ScopeChat) && !slices.Contains(rec.Scopes, scope) {
+			rec.Scopes = append(rec.Scopes, scope)
+		}
+	}
+	if len(claims.Scopes) > 0 && len(rec.Scopes) == 0 {
+		return nil, fmt.Errorf("jwt scopes %q grant neither %q nor %q", claims.Scopes, auth.ScopeRead, auth.ScopeChat)
+	}
+	if rec.Label == "" {
+		rec.Label = claims.Name
+	}
+	if !claims.ExpiresAt.IsZero() {
+		exp := claims.ExpiresAt
+		rec.ExpiresAt = &exp
+	}
+	return rec, nil
+}
+
 // requestContext returns the request context, enriched with a provider key
 // if the X-Provider-Key header is present.
 func requestContext(r *http.Request) context.Context {
@@ -635,18 +946,43 @@ func (s *Server) resolveInstructions(sessionKey, instructions string) string {
 	return instructions
 }
 
-func readJSON(r *http.Request, out any) error {
+// defaultMaxRequestBytes caps request bodies when Config.MaxRequestBytes is unset.
+const defaultMaxRequestBytes = 20 * 1024 * 1024
+
+var errRequestTooLarge = errors.New("request body too large")
+
+// readJSON decodes at most limit bytes of the request body into out. A body
+// longer than limit yields errRequestTooLarge rather than a truncated decode.
+func readJSON(r *http.Request, out any, limit int64) error {
 	defer r.Body.Close()
-	body, err := io.ReadAll(io.LimitReader(r.Body, 20*1024*1024))
+	if limit <= 0 {
+		limit = defaultMaxRequestBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, limit))
 	if err != nil {
 		return err
 	}
+	if int64(len(body)) == limit {
+		// The limit reader stops silently; probe for trailing data.
+		var probe [1]byte
+		if n, _ := r.Body.Read(probe[:]); n > 0 {
+			return fmt.Errorf("%w (limit %d bytes)", errRequestTooLarge, limit)
+		}
+	}
 	if len(body) == 0 {
 		return errors.New("empty body")
 	}
 	return json.Unmarshal(body, out)
 }
 
+// readJSONStatus maps a readJSON error to an HTTP status.
+func readJSONStatus(err error) int {
+	if errors.Is(err, errRequestTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
 func writeJSON(w http.ResponseWriter, status int, body any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -679,13 +1015,12 @@ func writeSSE(w io.Writer, flusher http.Flusher, payload any) error {
 		}
 		data = buf
 	}
-	if _, err := w.Write([]byte("data: ")); err != nil {
-		return err
-	}
-	if _, err := w.Write(data); err != nil {
-		return err
-	}
-	if _, err := w.Write([]byte("\n\n")); err != nil {
+	// Write the frame in one call so concurrent heartbeat pings cannot split it.
+	frame := make([]byte, 0, len(data)+8)
+	frame = append(frame, "data: "...)
+	frame = append(frame, data...)
+	frame = append(frame, "\n\n"...)
+	if _, err := w.Write(frame); err != nil {
 		return err
 	}
 	flusher.Flush()
@@ -712,11 +1047,32 @@ func defaultInstructions() string {
 
 func (s *Server) ServeWithContext(ctx context.Context) error {
 	server := &http.Server{Addr: s.cfg.Listen}
+	serveErr := make(chan error, 1)
 	go func() {
-		<-ctx.Done()
-		_ = server.Shutdown(context.Background())
+		serveErr <- server.ListenAndServe()
 	}()
-	return server.ListenAndServe()
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+		return s.shutdown(server, s.cfg.DrainTimeout)
+	}
+}
+
+// availableEndpoints is reported by /health for discovery; keep it in sync
+// with the mux in Run.
+var availableEndpoints = []string{
+	"GET /v1/models",
+	"GET /v1/models/{id}",
+	"GET /v1/pricing",
+	"POST /v1/responses",
+	"POST /v1/chat/completions",
+	"POST /v1/completions",
+	"GET /v1/backends",
+	"GET /v1/usage",
+	"GET /v1/stats/stream",
+	"GET /metrics",
+	"GET /health",
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
@@ -725,10 +1081,15 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if strings.TrimSpace(version) == "" {
 		version = "dev"
 	}
-	writeJSON(w, http.StatusOK, map[string]string{
-		"status":  "ok",
-		"version": version,
-	})
+	resp := map[string]any{
+		"status":              "ok",
+		"version":             version,
+		"available_endpoints": availableEndpoints,
+	}
+	if states := s.breakerStates(); len(states) > 0 {
+		resp["circuit_breakers"] = states
+	}
+	writeJSON(w, http.StatusOK, resp)
 	s.logRequest(r, http.StatusOK, start)
 }
 
@@ -756,7 +1117,25 @@ func (s *Server) logRequest(r *http.Request, status int, start time.Time) {
 		return
 	}
 	elapsed := time.Since(start)
-	s.logger.Info("request", "method", r.Method, "path", r.URL.Path, "status", fmt.Sprintf("%d", status), "elapsed", elapsed.String())
+	requestID, _ := harness.RequestID(r.Context())
+	s.logger.Info("request", "request_id", requestID, "method", r.Method, "path", r.URL.Path, "status", fmt.Sprintf("%d", status), "elapsed", elapsed.String())
+}
+
+// logTokenEstimate logs the harness's prompt token count for turn at debug
+// level. It runs in the background because some harnesses count with an API
+// call (claude uses /v1/messages/count_tokens).
+func (s *Server) logTokenEstimate(ctx context.Context, h harness.Harness, turn *harness.Turn, requestID string) {
+	if !s.logger.DebugEnabled() {
+		return
+	}
+	go func() {
+		n, err := h.CountTokens(ctx, turn)
+		if err != nil {
+			s.logger.Debug("token estimate failed", "request_id", requestID, "harness", h.Name(), "error", err.Error())
+			return
+		}
+		s.logger.Debug("token estimate", "request_id", requestID, "harness", h.Name(), "model", turn.Model, "input_tokens", fmt.Sprintf("%d", n))
+	}()
 }
 
 // recordMetric records a request metric for a backend.
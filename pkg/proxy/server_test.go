@@ -1,12 +1,25 @@
 package proxy
 
 import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"godex/pkg/auth"
+	"godex/pkg/harness"
+	"godex/pkg/router"
 )
 
 func TestCountInvalidExecPairs(t *testing.T) {
@@ -55,7 +68,7 @@ func TestRequireAuthAllowAnyKey(t *testing.T) {
 	s := &Server{cfg: Config{AllowAnyKey: true}}
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
-	if _, ok := s.requireAuth(rr, req); !ok {
+	if _, ok := s.requireAuth(rr, req, auth.ScopeRead); !ok {
 		t.Fatalf("expected allow-any-key to pass auth")
 	}
 }
@@ -64,7 +77,7 @@ func TestRequireAuthMissingKey(t *testing.T) {
 	s := &Server{cfg: Config{APIKey: "secret"}}
 	rr := httptest.NewRecorder()
 	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
-	if _, ok := s.requireAuth(rr, req); ok {
+	if _, ok := s.requireAuth(rr, req, auth.ScopeRead); ok {
 		t.Fatalf("expected missing auth to fail")
 	}
 	if rr.Code != http.StatusUnauthorized {
@@ -72,6 +85,146 @@ func TestRequireAuthMissingKey(t *testing.T) {
 	}
 }
 
+func TestRequireAuthJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "RSA", "kid": "k1", "n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes())},
+		}})
+	}))
+	defer jwks.Close()
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	sign := func(scope string) (string, string) {
+		input := b64([]byte(`{"alg":"RS256","kid":"k1"}`)) + "." +
+			b64([]byte(`{"sub":"user-1","email":"a@example.com","aud":"godex","exp":`+strconv.FormatInt(exp.Unix(), 10)+scope+`}`))
+		sum := sha256.Sum256([]byte(input))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, sum[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return input, input + "." + b64(sig)
+	}
+	input, token := sign("")
+
+	keys, err := LoadKeyStore(t.TempDir() + "/keys.json")
+	if err != nil {
+		t.Fatalf("LoadKeyStore: %v", err)
+	}
+	_, secret, err := keys.Add("static", "60/m", 10, 0, "", 0)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	r := router.New(router.Config{UserPatterns: map[string][]string{"mock": {"any-"}}})
+	r.Register("mock", harness.NewMock(harness.MockConfig{HarnessName: "mock"}))
+	s := &Server{cfg: Config{JWT: auth.JWTConfig{JWKSURL: jwks.URL, Audience: "godex"}}, keys: keys, harnessRouter: r, logger: NewLogger(LogLevelError)}
+
+	authorize := func(bearer string) (*KeyRecord, int) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rr := httptest.NewRecorder()
+		rec, _ := s.requireAuth(rr, req, auth.ScopeRead)
+		return rec, rr.Code
+	}
+	rec, _ := authorize(token)
+	if rec == nil || rec.ID != "jwt:user-1" || rec.Label != "a@example.com" || rec.ExpiresAt == nil || !rec.ExpiresAt.Equal(exp) {
+		t.Fatalf("JWT key = %+v", rec)
+	}
+	// Keystore keys still work alongside JWTs.
+	if rec, _ := authorize(secret); rec == nil || rec.Label != "static" {
+		t.Fatalf("keystore key = %+v", rec)
+	}
+	if rec, code := authorize(input + "." + b64([]byte("forged"))); rec != nil || code != http.StatusUnauthorized {
+		t.Fatalf("forged JWT: key = %+v, status %d", rec, code)
+	}
+
+	// Scopes carry over: a read-only token cannot run the model.
+	_, readOnly := sign(`,"scope":"openid read"`)
+	if rec, _ := authorize(readOnly); rec == nil || len(rec.Scopes) != 1 || rec.Scopes[0] != auth.ScopeRead {
+		t.Fatalf("read-only JWT key = %+v", rec)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"any-model","messages":[{"role":"user","content":"hi"}]}`))
+	req.Header.Set("Authorization", "Bearer "+readOnly)
+	rr := httptest.NewRecorder()
+	s.handleChatCompletions(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("read-only JWT on chat completions: status %d: %s", rr.Code, rr.Body.String())
+	}
+	// Scopes that mean nothing to the proxy do not make a token unscoped.
+	_, foreign := sign(`,"scp":["openid","profile"]`)
+	if rec, code := authorize(foreign); rec != nil || code != http.StatusUnauthorized {
+		t.Fatalf("JWT without read or chat scope: key = %+v, status %d", rec, code)
+	}
+}
+
+func TestRequireAuthServiceAccount(t *testing.T) {
+	secret := "service-account-secret"
+	cred, err := auth.IssueServiceAccount("ci-bot", []string{"read"}, []byte(secret))
+	if err != nil {
+		t.Fatalf("IssueServiceAccount: %v", err)
+	}
+	keys, err := LoadKeyStore(t.TempDir() + "/keys.json")
+	if err != nil {
+		t.Fatalf("LoadKeyStore: %v", err)
+	}
+	_, static, err := keys.Add("static", "60/m", 10, 0, "", 0)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	s := &Server{cfg: Config{ServiceAccountSecret: secret}, keys: keys, logger: NewLogger(LogLevelError)}
+
+	authorizeScope := func(bearer, scope string) (*KeyRecord, int) {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rr := httptest.NewRecorder()
+		rec, _ := s.requireAuth(rr, req, scope)
+		return rec, rr.Code
+	}
+	authorize := func(bearer string) (*KeyRecord, int) {
+		return authorizeScope(bearer, auth.ScopeRead)
+	}
+	if rec, _ := authorize(cred.Secret); rec == nil || rec.ID != cred.ID || rec.Label != "ci-bot" {
+		t.Fatalf("service account key = %+v", rec)
+	}
+	if rec, code := authorizeScope(cred.Secret, auth.ScopeChat); rec != nil || code != http.StatusForbidden {
+		t.Fatalf("read-only service account on a chat endpoint: key = %+v, status %d", rec, code)
+	}
+	if rec, _ := authorize(static); rec == nil || rec.Label != "static" {
+		t.Fatalf("keystore key = %+v", rec)
+	}
+	if rec, _ := authorizeScope(static, auth.ScopeChat); rec == nil {
+		t.Fatal("unscoped keystore key rejected on a chat endpoint")
+	}
+	other, _ := auth.IssueServiceAccount("ci-bot", nil, []byte("a-different-secret"))
+	if rec, code := authorize(other.Secret); rec != nil || code != http.StatusUnauthorized {
+		t.Fatalf("foreign service account: key = %+v, status %d", rec, code)
+	}
+
+	if err := keys.AddServiceAccount(cred); err != nil {
+		t.Fatalf("AddServiceAccount: %v", err)
+	}
+	if rec, _ := authorize(cred.Secret); rec == nil {
+		t.Fatal("recorded service account rejected")
+	}
+	if _, ok := keys.Revoke(cred.ID); !ok {
+		t.Fatal("Revoke did not find the service account")
+	}
+	if rec, code := authorize(cred.Secret); rec != nil || code != http.StatusUnauthorized {
+		t.Fatalf("revoked service account: key = %+v, status %d", rec, code)
+	}
+	s.cfg.ServiceAccountSecret = ""
+	fresh, _ := auth.IssueServiceAccount("ci-bot", nil, []byte(secret))
+	if err := keys.AddServiceAccount(fresh); err != nil {
+		t.Fatalf("AddServiceAccount: %v", err)
+	}
+	if rec, code := authorize(fresh.Secret); rec != nil || code != http.StatusUnauthorized {
+		t.Fatalf("service account as a plain key: key = %+v, status %d", rec, code)
+	}
+}
+
 func TestHealthEndpoint(t *testing.T) {
 	s := &Server{cfg: Config{Version: "v1.2.3"}}
 	rr := httptest.NewRecorder()
@@ -80,15 +233,18 @@ func TestHealthEndpoint(t *testing.T) {
 	if rr.Code != http.StatusOK {
 		t.Fatalf("expected status 200, got %d", rr.Code)
 	}
-	var body map[string]string
+	var body struct {
+		Status  string `json:"status"`
+		Version string `json:"version"`
+	}
 	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
 		t.Fatalf("decode response: %v", err)
 	}
-	if body["status"] != "ok" {
-		t.Fatalf("expected status=ok, got %q", body["status"])
+	if body.Status != "ok" {
+		t.Fatalf("expected status=ok, got %q", body.Status)
 	}
-	if body["version"] != "v1.2.3" {
-		t.Fatalf("expected version v1.2.3, got %q", body["version"])
+	if body.Version != "v1.2.3" {
+		t.Fatalf("expected version v1.2.3, got %q", body.Version)
 	}
 }
 
@@ -119,3 +275,273 @@ func TestRunUsesCustomAuthPath(t *testing.T) {
 		// Run reached ListenAndServe without auth load error.
 	}
 }
+
+func TestRunRejectsPartialTLSConfig(t *testing.T) {
+	cases := []Config{
+		{Listen: "127.0.0.1:0", AllowAnyKey: true, TLSCertFile: "/tmp/cert.pem"},
+		{Listen: "127.0.0.1:0", AllowAnyKey: true, TLSKeyFile: "/tmp/key.pem"},
+	}
+	for _, cfg := range cases {
+		err := Run(cfg)
+		if err == nil || !strings.Contains(err.Error(), "tls:") {
+			t.Fatalf("expected tls config error, got %v", err)
+		}
+	}
+}
+
+func TestRequireAuthOrPaymentEnforcesAllowedModels(t *testing.T) {
+	keys, err := LoadKeyStore(t.TempDir() + "/keys.json")
+	if err != nil {
+		t.Fatalf("LoadKeyStore: %v", err)
+	}
+	_, secret, err := keys.Add("scoped", "60/m", 10, 0, "", 0, "gpt-4o")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	s := &Server{keys: keys}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	rr := httptest.NewRecorder()
+	if _, ok := s.requireAuthOrPayment(rr, req, "gpt-4o"); !ok {
+		t.Fatalf("expected allowed model to pass, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	if _, ok := s.requireAuthOrPayment(rr, req, "claude-sonnet-4-5"); ok {
+		t.Fatalf("expected disallowed model to fail")
+	}
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rr.Code)
+	}
+
+	// The requested model is resolved through the alias map: an alias of a
+	// disallowed model is refused, and one of an allowed model passes.
+	s.harnessRouter = router.New(router.Config{UserAliases: map[string]string{"sonnet": "claude-sonnet-4-5", "omni": "gpt-4o"}})
+	rr = httptest.NewRecorder()
+	if _, ok := s.requireAuthOrPayment(rr, req, "sonnet"); ok || rr.Code != http.StatusForbidden {
+		t.Fatalf("alias of a disallowed model: ok=%v status %d", ok, rr.Code)
+	}
+	rr = httptest.NewRecorder()
+	if _, ok := s.requireAuthOrPayment(rr, req, "omni"); !ok {
+		t.Fatalf("alias of an allowed model: status %d", rr.Code)
+	}
+}
+
+func TestModelByIDEnforcesAllowedModels(t *testing.T) {
+	keys, err := LoadKeyStore(t.TempDir() + "/keys.json")
+	if err != nil {
+		t.Fatalf("LoadKeyStore: %v", err)
+	}
+	_, secret, err := keys.Add("scoped", "60/m", 10, 0, "", 0, "gpt-4o")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	r := router.New(router.Config{
+		UserPatterns: map[string][]string{"mock": {"gpt-", "claude-"}},
+		UserAliases:  map[string]string{"sonnet": "claude-sonnet-4-5"},
+	})
+	r.Register("mock", harness.NewMock(harness.MockConfig{HarnessName: "mock"}))
+	s := &Server{
+		keys:          keys,
+		harnessRouter: r,
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelError),
+	}
+
+	for path, want := range map[string]int{
+		"/v1/models/gpt-4o":            http.StatusOK,
+		"/v1/models/claude-sonnet-4-5": http.StatusForbidden,
+		"/v1/models/sonnet":            http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+secret)
+		rr := httptest.NewRecorder()
+		s.handleModelByID(rr, req)
+		if rr.Code != want {
+			t.Errorf("%s: status %d, want %d: %s", path, rr.Code, want, rr.Body.String())
+		}
+	}
+}
+
+func TestReadJSONDetectsTruncation(t *testing.T) {
+	body := `{"model":"gpt-5"}`
+	var out map[string]any
+
+	// Body exactly at the limit decodes normally.
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(body))
+	if err := readJSON(req, &out, int64(len(body))); err != nil {
+		t.Fatalf("expected body at limit to decode, got %v", err)
+	}
+
+	// One byte over the limit is rejected instead of silently truncated.
+	req = httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(body+" "))
+	err := readJSON(req, &out, int64(len(body)))
+	if !errors.Is(err, errRequestTooLarge) {
+		t.Fatalf("expected errRequestTooLarge, got %v", err)
+	}
+}
+
+func TestOversizedRequestReturns413(t *testing.T) {
+	s := &Server{cfg: Config{MaxRequestBytes: 16}}
+	big := `{"model":"gpt-5","input":"` + strings.Repeat("x", 64) + `"}`
+
+	for path, handler := range map[string]http.HandlerFunc{
+		"/v1/responses":        s.handleResponses,
+		"/v1/chat/completions": s.handleChatCompletions,
+	} {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(big)))
+		if rr.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("%s: expected 413, got %d", path, rr.Code)
+		}
+	}
+}
+
+func TestAllowModelRetryAfterWindowReset(t *testing.T) {
+	usage := NewUsageStore("", "", 0, 0, 0, "", 0, 0)
+	usage.window = time.Hour
+	usage.windowStart = time.Now().UTC().Add(-50 * time.Minute)
+	usage.SetModelQuotas(map[string]int64{"gpt-5": 100})
+	usage.Record(UsageEvent{KeyID: "k1", Model: "gpt-5", TotalTokens: 100})
+	s := &Server{usage: usage}
+
+	rr := httptest.NewRecorder()
+	if s.allowModel(rr, &KeyRecord{ID: "k1"}, "gpt-5") {
+		t.Fatal("expected exhausted model to be rejected")
+	}
+	secs, err := strconv.Atoi(rr.Header().Get("Retry-After"))
+	if err != nil || secs < 590 || secs > 600 {
+		t.Errorf("expected Retry-After of about 600s, got %q", rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestAllowModelEnforcesModelQuota(t *testing.T) {
+	usage := NewUsageStore("", "", 0, 0, 0, "", 0, 0)
+	usage.SetModelQuotas(map[string]int64{"gpt-5": 100})
+	s := &Server{usage: usage}
+	key := &KeyRecord{ID: "k1"}
+
+	rr := httptest.NewRecorder()
+	if !s.allowModel(rr, key, "gpt-5") {
+		t.Fatalf("expected fresh key to pass, got %d", rr.Code)
+	}
+
+	usage.Record(UsageEvent{KeyID: "k1", Model: "gpt-5", TotalTokens: 100})
+	usage.Record(UsageEvent{KeyID: "k1", Model: "gpt-4o", TotalTokens: 5000})
+
+	rr = httptest.NewRecorder()
+	if s.allowModel(rr, key, "gpt-5") {
+		t.Fatal("expected exhausted model to be rejected")
+	}
+	if rr.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rr.Code)
+	}
+	var body struct {
+		Error struct {
+			Type  string `json:"type"`
+			Model string `json:"model"`
+			Used  int64  `json:"used"`
+			Limit int64  `json:"limit"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Error.Type != "model_quota_exceeded" || body.Error.Model != "gpt-5" || body.Error.Used != 100 || body.Error.Limit != 100 {
+		t.Fatalf("unexpected body: %+v", body.Error)
+	}
+	// Without a meter window the quota never resets on its own.
+	if got := rr.Header().Get("Retry-After"); got != "" {
+		t.Errorf("expected no Retry-After without a window, got %q", got)
+	}
+
+	// Unbudgeted models are unaffected by the gpt-5 limit.
+	if !s.allowModel(httptest.NewRecorder(), key, "gpt-4o") {
+		t.Fatal("expected unbudgeted model to pass")
+	}
+
+	// A per-key override raises the limit for that key only.
+	key.ModelQuotas = map[string]int64{"gpt-5": 1000}
+	if !s.allowModel(httptest.NewRecorder(), key, "gpt-5") {
+		t.Fatal("expected per-key override to allow more tokens")
+	}
+}
+
+func TestModelByIDExplain(t *testing.T) {
+	r := router.New(router.Config{UserPatterns: map[string][]string{"mock": {"any-"}}})
+	r.Register("mock", harness.NewMock(harness.MockConfig{HarnessName: "mock"}))
+	s := &Server{
+		cfg:           Config{AllowAnyKey: true},
+		harnessRouter: r,
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/models/any-model?explain=true", nil)
+	req.Header.Set("Authorization", "Bearer test")
+	s.handleModelByID(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if body["backend"] != "mock" || body["matched_backend"] != "mock" || body["matched_pattern"] != "any-" {
+		t.Fatalf("unexpected explanation %v", body)
+	}
+	if reason, _ := body["reason"].(string); !strings.Contains(reason, "any-") {
+		t.Fatalf("unexpected reason %q", reason)
+	}
+
+	rr = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/v1/models/any-model", nil)
+	req.Header.Set("Authorization", "Bearer test")
+	s.handleModelByID(rr, req)
+	if strings.Contains(rr.Body.String(), "reason") {
+		t.Fatalf("explanation returned without explain=true: %s", rr.Body.String())
+	}
+}
+
+func TestModelsPagination(t *testing.T) {
+	r := router.New(router.Config{})
+	r.Register("a", harness.NewMock(harness.MockConfig{HarnessName: "a", Models: []harness.ModelInfo{{ID: "m3"}, {ID: "m1"}}}))
+	r.Register("b", harness.NewMock(harness.MockConfig{HarnessName: "b", Models: []harness.ModelInfo{{ID: "m2"}}}))
+	s := &Server{
+		cfg:           Config{AllowAnyKey: true},
+		harnessRouter: r,
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+	}
+	list := func(query string) (int, OpenAIModelsResponse) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/v1/models"+query, nil)
+		req.Header.Set("Authorization", "Bearer test")
+		s.handleModels(rr, req)
+		var resp OpenAIModelsResponse
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		return rr.Code, resp
+	}
+
+	_, page := list("?limit=2")
+	if len(page.Data) != 2 || page.Data[0].ID != "m1" || page.LastID != "m2" || !page.HasMore {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	_, page = list("?limit=2&after=" + page.LastID)
+	if len(page.Data) != 1 || page.Data[0].ID != "m3" || page.FirstID != "m3" || page.HasMore {
+		t.Fatalf("unexpected last page %+v", page)
+	}
+	_, page = list("?limit=2&after=m3")
+	if len(page.Data) != 0 || page.HasMore {
+		t.Fatalf("expected an empty page past the end, got %+v", page)
+	}
+	_, page = list("")
+	if len(page.Data) != 3 || page.HasMore {
+		t.Fatalf("expected every model without a limit, got %+v", page)
+	}
+	if code, _ := list("?limit=0"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for limit=0, got %d", code)
+	}
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// trackStream registers an in-flight streaming response so shutdown can wait
+// for it. The returned func must be called when the stream ends.
+func (s *Server) trackStream() func() {
+	s.streams.Add(1)
+	s.activeStreams.Add(1)
+	return func() {
+		s.activeStreams.Add(-1)
+		s.streams.Done()
+	}
+}
+
+// shutdown stops accepting connections and waits up to timeout for in-flight
+// requests and streams to finish.
+func (s *Server) shutdown(server *http.Server, timeout time.Duration) error {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	s.stopOnce.Do(func() {
+		if s.stopping != nil {
+			close(s.stopping)
+		}
+	})
+	s.logger.Info(fmt.Sprintf("draining %d connections…", s.activeStreams.Load()), "timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	drained := make(chan struct{})
+	go func() {
+		s.streams.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+	if err != nil {
+		s.logger.Warn("drain incomplete", "remaining", fmt.Sprintf("%d", s.activeStreams.Load()), "error", err.Error())
+		_ = server.Close()
+		return err
+	}
+	s.logger.Info("done")
+	return nil
+}
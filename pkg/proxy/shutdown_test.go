@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownWaitsForStreams(t *testing.T) {
+	s := &Server{}
+	done := s.trackStream()
+
+	result := make(chan error, 1)
+	go func() {
+		result <- s.shutdown(&http.Server{}, time.Second)
+	}()
+
+	select {
+	case err := <-result:
+		t.Fatalf("shutdown returned before stream finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expected clean shutdown, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("shutdown did not return after stream finished")
+	}
+}
+
+func TestShutdownDrainTimeout(t *testing.T) {
+	s := &Server{}
+	done := s.trackStream()
+	defer done()
+
+	start := time.Now()
+	if err := s.shutdown(&http.Server{}, 30*time.Millisecond); err == nil {
+		t.Fatal("expected drain timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("shutdown exceeded drain timeout: %v", elapsed)
+	}
+}
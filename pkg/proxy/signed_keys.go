@@ -0,0 +1,164 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"godex/pkg/auth"
+)
+
+// signedKeyPrefix marks HMAC-signed keys, which carry their own claims.
+const signedKeyPrefix = "gxs_"
+
+// minHMACSecret is the shortest secret GenerateSigned accepts.
+const minHMACSecret = 16
+
+// signedClaims is the payload of a signed key. Claims passed to
+// GenerateSigned under these names set the key's limits; other claims are
+// kept in the payload but ignored.
+type signedClaims struct {
+	ID            string   `json:"id"`
+	Label         string   `json:"label"`
+	IssuedAt      int64    `json:"iat"`
+	ExpiresAt     int64    `json:"exp,omitempty"`
+	Rate          string   `json:"rate,omitempty"`
+	Burst         int      `json:"burst,omitempty"`
+	QuotaTokens   int64    `json:"quota_tokens,omitempty"`
+	AllowedModels []string `json:"allowed_models,omitempty"`
+}
+
+// GenerateSigned issues a key whose claims travel in the key itself:
+// "gxs_" then the base64url JSON claims, a ".", and their HMAC-SHA256
+// under secret. The claims "exp" (Unix seconds), "rate", "burst",
+// "quota_tokens" and "allowed_models" set the key's limits. The key is
+// also recorded in the store so it can be listed and revoked by ID, but
+// without a token hash: it is only accepted through ValidateSigned, so
+// rotating the secret retires it.
+func (s *KeyStore) GenerateSigned(label string, claims map[string]any, secret []byte) (string, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return "", errors.New("label is required")
+	}
+	if len(secret) < minHMACSecret {
+		return "", errors.New("hmac secret must be at least 16 bytes")
+	}
+	id, err := newKeyID()
+	if err != nil {
+		return "", err
+	}
+	payload := map[string]any{}
+	for k, v := range claims {
+		payload[k] = v
+	}
+	payload["id"] = id
+	payload["label"] = label
+	payload["iat"] = time.Now().Unix()
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	var sc signedClaims
+	if err := json.Unmarshal(buf, &sc); err != nil {
+		return "", errors.New("invalid claims: " + err.Error())
+	}
+	body := signedKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	token := body + "." + base64.RawURLEncoding.EncodeToString(signKey(body, secret))
+
+	rec := sc.record()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file.Keys = append(s.file.Keys, *rec)
+	if err := s.saveLocked(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ValidateSigned checks a key from GenerateSigned against secret and
+// returns the key its claims describe. Only the store's revocations are
+// consulted, so keys issued by another store with the same secret are
+// accepted too.
+func (s *KeyStore) ValidateSigned(token string, secret []byte) (*KeyRecord, bool) {
+	if len(secret) == 0 || !strings.HasPrefix(token, signedKeyPrefix) {
+		return nil, false
+	}
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, signKey(body, secret)) {
+		return nil, false
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(body, signedKeyPrefix))
+	if err != nil {
+		return nil, false
+	}
+	var sc signedClaims
+	if err := json.Unmarshal(buf, &sc); err != nil || sc.ID == "" {
+		return nil, false
+	}
+	rec := sc.record()
+	if rec.ExpiresAt != nil && rec.ExpiresAt.Before(time.Now()) {
+		return nil, false
+	}
+	if s.revoked(sc.ID) {
+		return nil, false
+	}
+	return rec, true
+}
+
+// revoked reports whether the store holds id as revoked.
+func (s *KeyStore) revoked(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, rec := range s.file.Keys {
+		if rec.ID == id {
+			return rec.RevokedAt != nil
+		}
+	}
+	return false
+}
+
+// AddServiceAccount records an issued service account credential so it can
+// be listed and revoked by ID. No token hash is kept: the credential is
+// only accepted through its signature, never as a plain key.
+func (s *KeyStore) AddServiceAccount(cred auth.ServiceAccountCredential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.file.Keys = append(s.file.Keys, KeyRecord{
+		ID:        cred.ID,
+		Label:     cred.Label,
+		CreatedAt: time.Now().UTC(),
+		Scopes:    cred.Scopes,
+	})
+	return s.saveLocked()
+}
+
+func (c signedClaims) record() *KeyRecord {
+	rec := &KeyRecord{
+		ID:            c.ID,
+		Label:         c.Label,
+		CreatedAt:     time.Unix(c.IssuedAt, 0).UTC(),
+		Rate:          c.Rate,
+		Burst:         c.Burst,
+		QuotaTokens:   c.QuotaTokens,
+		AllowedModels: normalizeModelList(c.AllowedModels),
+	}
+	if c.ExpiresAt > 0 {
+		exp := time.Unix(c.ExpiresAt, 0).UTC()
+		rec.ExpiresAt = &exp
+	}
+	return rec
+}
+
+func signKey(body string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
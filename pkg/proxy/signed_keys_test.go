@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"godex/pkg/auth"
+)
+
+func TestSignedKeys(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	store, err := LoadKeyStore(t.TempDir() + "/keys.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := store.GenerateSigned("ci", map[string]any{"rate": "10/s", "burst": 5, "allowed_models": []string{"gpt-5"}, "team": "infra"}, secret)
+	if err != nil {
+		t.Fatalf("GenerateSigned: %v", err)
+	}
+	if !strings.HasPrefix(token, signedKeyPrefix) {
+		t.Fatalf("token = %q, want %s prefix", token, signedKeyPrefix)
+	}
+
+	rec, ok := store.ValidateSigned(token, secret)
+	if !ok {
+		t.Fatal("ValidateSigned rejected a fresh key")
+	}
+	if rec.Label != "ci" || rec.Rate != "10/s" || rec.Burst != 5 || !rec.AllowsModel("gpt-5") || rec.AllowsModel("gpt-4o") {
+		t.Errorf("record = %+v", rec)
+	}
+	// The key is listed by ID but never validates as a plain stored key.
+	if listed := store.List(); len(listed) != 1 || listed[0].ID != rec.ID || listed[0].Hash != "" {
+		t.Errorf("stored records = %+v", listed)
+	}
+	if _, ok := store.Validate(token); ok {
+		t.Error("Validate accepted a signed key without its signature")
+	}
+
+	body, sig, _ := strings.Cut(token, ".")
+	if _, ok := store.ValidateSigned(body+"x."+sig, secret); ok {
+		t.Error("accepted a tampered payload")
+	}
+	if _, ok := store.ValidateSigned(token, []byte("another-secret-of-32-bytes-long!")); ok {
+		t.Error("accepted a key signed with another secret")
+	}
+
+	expired, err := store.GenerateSigned("old", map[string]any{"exp": time.Now().Add(-time.Minute).Unix()}, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.ValidateSigned(expired, secret); ok {
+		t.Error("accepted an expired key")
+	}
+
+	if _, ok := store.Revoke(rec.ID); !ok {
+		t.Fatal("Revoke failed")
+	}
+	if _, ok := store.ValidateSigned(token, secret); ok {
+		t.Error("accepted a revoked key")
+	}
+
+	if _, err := store.GenerateSigned("weak", nil, []byte("short")); err == nil {
+		t.Error("GenerateSigned accepted a short secret")
+	}
+}
+
+func TestRequireAuthSignedKey(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	issuer, err := LoadKeyStore(t.TempDir() + "/issuer.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := issuer.GenerateSigned("svc", nil, []byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The serving store never saw the key; the signature is enough.
+	keys, err := LoadKeyStore(t.TempDir() + "/keys.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{cfg: Config{HMACSecret: secret}, keys: keys}
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rr := httptest.NewRecorder()
+	if rec, ok := s.requireAuth(rr, req, auth.ScopeRead); !ok || rec.Label != "svc" {
+		t.Fatalf("requireAuth = %+v, %v (status %d)", rec, ok, rr.Code)
+	}
+
+	s.cfg.HMACSecret = ""
+	rr = httptest.NewRecorder()
+	if _, ok := s.requireAuth(rr, req, auth.ScopeRead); ok || rr.Code != http.StatusUnauthorized {
+		t.Fatalf("without hmac_secret: ok=%v status %d", ok, rr.Code)
+	}
+}
+
+func TestRequireAuthSignedKeyAfterRotation(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	keys, err := LoadKeyStore(t.TempDir() + "/keys.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The issuing store serves requests too, so the key is recorded in it.
+	token, err := keys.GenerateSigned("svc", nil, []byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{cfg: Config{HMACSecret: secret}, keys: keys}
+	authorize := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		s.requireAuth(rr, req, auth.ScopeRead)
+		return rr.Code
+	}
+	if code := authorize(); code != http.StatusOK {
+		t.Fatalf("before rotation: status %d", code)
+	}
+	for _, rotated := range []string{"fedcba9876543210fedcba9876543210", ""} {
+		s.cfg.HMACSecret = rotated
+		if code := authorize(); code != http.StatusUnauthorized {
+			t.Fatalf("hmac_secret %q: status %d, want 401", rotated, code)
+		}
+	}
+}
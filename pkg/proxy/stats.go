@@ -0,0 +1,219 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"godex/pkg/auth"
+	"godex/pkg/harness"
+)
+
+const (
+	statsStreamInterval      = time.Second
+	defaultStatsStreamMaxAge = time.Hour
+)
+
+// liveStats holds process-lifetime counters for GET /v1/stats/stream.
+type liveStats struct {
+	requests  atomic.Int64
+	errors    atomic.Int64
+	tokensIn  atomic.Int64
+	tokensOut atomic.Int64
+	active    atomic.Int64
+	backends  sync.Map // backend name -> *backendCounters
+}
+
+type backendCounters struct {
+	requests  atomic.Int64
+	errors    atomic.Int64
+	tokensIn  atomic.Int64
+	tokensOut atomic.Int64
+}
+
+func (l *liveStats) backend(name string) *backendCounters {
+	if c, ok := l.backends.Load(name); ok {
+		return c.(*backendCounters)
+	}
+	c, _ := l.backends.LoadOrStore(name, &backendCounters{})
+	return c.(*backendCounters)
+}
+
+// StatsSnapshot is one event of GET /v1/stats/stream.
+type StatsSnapshot struct {
+	Timestamp         string                  `json:"ts"`
+	RequestsTotal     int64                   `json:"requests_total"`
+	ErrorsTotal       int64                   `json:"errors_total"`
+	TokensInTotal     int64                   `json:"tokens_in_total"`
+	TokensOutTotal    int64                   `json:"tokens_out_total"`
+	ActiveConnections int64                   `json:"active_connections"`
+	Backends          map[string]BackendStats `json:"backends"`
+}
+
+// BackendStats is the per-backend part of a StatsSnapshot.
+type BackendStats struct {
+	Requests  int64 `json:"requests"`
+	Errors    int64 `json:"errors"`
+	TokensIn  int64 `json:"tokens_in"`
+	TokensOut int64 `json:"tokens_out"`
+}
+
+func (l *liveStats) snapshot(now time.Time) StatsSnapshot {
+	snap := StatsSnapshot{
+		Timestamp:         now.UTC().Format(time.RFC3339),
+		RequestsTotal:     l.requests.Load(),
+		ErrorsTotal:       l.errors.Load(),
+		TokensInTotal:     l.tokensIn.Load(),
+		TokensOutTotal:    l.tokensOut.Load(),
+		ActiveConnections: l.active.Load(),
+		Backends:          map[string]BackendStats{},
+	}
+	l.backends.Range(func(k, v any) bool {
+		c := v.(*backendCounters)
+		snap.Backends[k.(string)] = BackendStats{
+			Requests:  c.requests.Load(),
+			Errors:    c.errors.Load(),
+			TokensIn:  c.tokensIn.Load(),
+			TokensOut: c.tokensOut.Load(),
+		}
+		return true
+	})
+	return snap
+}
+
+// countRequests feeds the request, error and active-connection counters.
+func (s *Server) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.stats.requests.Add(1)
+		s.stats.active.Add(1)
+		defer s.stats.active.Add(-1)
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status >= http.StatusBadRequest {
+			s.stats.errors.Add(1)
+		}
+	})
+}
+
+// statsHarness counts turns, failures and tokens per backend, and passes
+// the duration of successful turns to latency when set. override names the
+// backend when an X-Godex-Backend header chose it.
+type statsHarness struct {
+	harness.Harness
+	counters *backendCounters
+	latency  func(time.Duration)
+	override string
+}
+
+func (h *statsHarness) StreamTurn(ctx context.Context, turn *harness.Turn, onEvent func(harness.Event) error) error {
+	h.counters.requests.Add(1)
+	start := time.Now()
+	err := h.Harness.StreamTurn(ctx, turn, func(ev harness.Event) error {
+		if ev.Kind == harness.EventUsage {
+			h.addUsage(ev.Usage)
+		}
+		return onEvent(ev)
+	})
+	if err != nil {
+		h.counters.errors.Add(1)
+	} else {
+		h.recordLatency(start)
+	}
+	return err
+}
+
+func (h *statsHarness) StreamAndCollect(ctx context.Context, turn *harness.Turn) (*harness.TurnResult, error) {
+	h.counters.requests.Add(1)
+	start := time.Now()
+	result, err := h.Harness.StreamAndCollect(ctx, turn)
+	if err != nil {
+		h.counters.errors.Add(1)
+	} else {
+		h.recordLatency(start)
+	}
+	if result != nil {
+		h.addUsage(result.Usage)
+	}
+	return result, err
+}
+
+func (h *statsHarness) BatchTurns(ctx context.Context, turns []*harness.Turn, onResult func(index int, result *harness.TurnResult, err error), opts harness.LoopOptions) error {
+	return harness.RunBatch(ctx, h.StreamAndCollect, turns, onResult, opts)
+}
+
+func (h *statsHarness) recordLatency(start time.Time) {
+	if h.latency != nil {
+		h.latency(time.Since(start))
+	}
+}
+
+func (h *statsHarness) addUsage(u *harness.UsageEvent) {
+	if u == nil {
+		return
+	}
+	h.counters.tokensIn.Add(int64(u.InputTokens))
+	h.counters.tokensOut.Add(int64(u.OutputTokens))
+}
+
+// handleStatsStream handles GET /v1/stats/stream, emitting a StatsSnapshot
+// every second until the client leaves, the proxy shuts down, or
+// StatsStreamMaxAge passes.
+func (s *Server) handleStatsStream(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	key, ok := s.requireAuth(w, r, auth.ScopeRead)
+	if !ok {
+		return
+	}
+	if ok, _ := s.allowRequest(w, r, key); !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		s.logRequest(r, http.StatusMethodNotAllowed, start)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errNoFlusher)
+		s.logRequest(r, http.StatusInternalServerError, start)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	maxAge := s.cfg.StatsStreamMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultStatsStreamMaxAge
+	}
+	deadline := time.NewTimer(maxAge)
+	defer deadline.Stop()
+	ticker := time.NewTicker(statsStreamInterval)
+	defer ticker.Stop()
+
+	if err := writeSSE(w, flusher, s.stats.snapshot(time.Now())); err != nil {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			s.logRequest(r, http.StatusOK, start)
+			return
+		case <-s.stopping:
+			s.logRequest(r, http.StatusOK, start)
+			return
+		case <-deadline.C:
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+			flusher.Flush()
+			s.logRequest(r, http.StatusOK, start)
+			return
+		case now := <-ticker.C:
+			if err := writeSSE(w, flusher, s.stats.snapshot(now)); err != nil {
+				return
+			}
+		}
+	}
+}
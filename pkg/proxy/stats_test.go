@@ -0,0 +1,169 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"godex/pkg/harness"
+	"godex/pkg/router"
+)
+
+func TestStatsStreamReportsCounters(t *testing.T) {
+	mock := harness.NewMock(harness.MockConfig{
+		HarnessName: "mock",
+		Responses: [][]harness.Event{{
+			harness.NewTextEvent("hi"),
+			harness.NewUsageEvent(7, 3),
+		}},
+	})
+	r := router.New(router.Config{UserPatterns: map[string][]string{"mock": {"any-model"}}})
+	r.Register("mock", mock)
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true, StatsStreamMaxAge: 50 * time.Millisecond},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+		stopping:      make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/chat/completions", srv.handleChatCompletions)
+	mux.HandleFunc("/v1/stats/stream", srv.handleStatsStream)
+	ts := httptest.NewServer(srv.countRequests(mux))
+	defer ts.Close()
+
+	body, _ := json.Marshal(OpenAIChatRequest{
+		Model:    "any-model",
+		Messages: []OpenAIChatMessage{{Role: "user", Content: "hi"}},
+	})
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/v1/chat/completions", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("chat status %d", resp.StatusCode)
+	}
+	if _, n := r.AverageLatency("mock"); n != 1 {
+		t.Fatalf("expected the turn's latency reported to the router, got %d samples", n)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/v1/stats/stream", nil)
+	req.Header.Set("Authorization", "Bearer test")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("stats stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+
+	var events []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	if len(events) < 2 || events[len(events)-1] != "[DONE]" {
+		t.Fatalf("expected snapshots followed by [DONE], got %v", events)
+	}
+	var snap StatsSnapshot
+	if err := json.Unmarshal([]byte(events[0]), &snap); err != nil {
+		t.Fatalf("decode snapshot: %v", err)
+	}
+	if snap.RequestsTotal != 2 || snap.ActiveConnections != 1 {
+		t.Fatalf("unexpected totals %+v", snap)
+	}
+	if snap.TokensInTotal != 7 || snap.TokensOutTotal != 3 {
+		t.Fatalf("unexpected token totals %+v", snap)
+	}
+	if b := snap.Backends["mock"]; b.Requests != 1 || b.TokensIn != 7 || b.TokensOut != 3 {
+		t.Fatalf("unexpected backend stats %+v", snap.Backends)
+	}
+}
+
+func TestStatsStreamStopsOnShutdown(t *testing.T) {
+	srv := &Server{
+		cfg:      Config{AllowAnyKey: true},
+		limiters: NewLimiterStore("60/m", 10),
+		stopping: make(chan struct{}),
+	}
+	close(srv.stopping)
+	req := httptest.NewRequest(http.MethodGet, "/v1/stats/stream", nil)
+	req.Header.Set("Authorization", "Bearer test")
+	rr := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		srv.handleStatsStream(rr, req)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stats stream did not stop on shutdown")
+	}
+	if !strings.Contains(rr.Body.String(), `"requests_total"`) {
+		t.Fatalf("expected an initial snapshot, got %q", rr.Body.String())
+	}
+}
+
+func TestLatencyWarmupAlternatesBackends(t *testing.T) {
+	const requests = 20
+	script := make([][]harness.Event, requests)
+	for i := range script {
+		script[i] = []harness.Event{harness.NewTextEvent("hi"), harness.NewUsageEvent(1, 1)}
+	}
+	r := router.New(router.Config{
+		UserPatterns:   map[string][]string{"a": {"any-model"}, "b": {"any-model"}},
+		LatencyRouting: true,
+		WarmupRequests: requests,
+	})
+	r.Register("a", harness.NewMock(harness.MockConfig{HarnessName: "a", Responses: script}))
+	r.Register("b", harness.NewMock(harness.MockConfig{HarnessName: "b", Responses: script}))
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 100),
+		logger:        NewLogger(LogLevelInfo),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(srv.handleChatCompletions))
+	defer ts.Close()
+
+	body, _ := json.Marshal(OpenAIChatRequest{
+		Model:    "any-model",
+		Messages: []OpenAIChatMessage{{Role: "user", Content: "hi"}},
+	})
+	for i := 0; i < requests; i++ {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("chat: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("chat status %d", resp.StatusCode)
+		}
+	}
+	// Checking the model must not take a turn in the warm-up rotation.
+	for _, name := range []string{"a", "b"} {
+		if _, n := r.AverageLatency(name); n != requests/2 {
+			t.Fatalf("expected %s to serve %d warm-up requests, got %d", name, requests/2, n)
+		}
+	}
+}
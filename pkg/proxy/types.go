@@ -88,8 +88,11 @@ type OpenAIChatTool struct {
 }
 
 type OpenAIModelsResponse struct {
-	Object string        `json:"object"`
-	Data   []OpenAIModel `json:"data"`
+	Object  string        `json:"object"`
+	Data    []OpenAIModel `json:"data"`
+	FirstID string        `json:"first_id,omitempty"`
+	LastID  string        `json:"last_id,omitempty"`
+	HasMore bool          `json:"has_more"`
 }
 
 type OpenAIModel struct {
@@ -172,6 +175,35 @@ type OpenAIChatToolFuncDelta struct {
 	Arguments string `json:"arguments,omitempty"`
 }
 
+// OpenAICompletionRequest is the legacy /v1/completions request. Prompt and
+// Stop accept either a string or an array of strings.
+type OpenAICompletionRequest struct {
+	Model     string `json:"model"`
+	Prompt    any    `json:"prompt"`
+	MaxTokens *int   `json:"max_tokens,omitempty"`
+	Stop      any    `json:"stop,omitempty"`
+	Stream    bool   `json:"stream,omitempty"`
+	User      string `json:"user,omitempty"`
+}
+
+// OpenAICompletionResponse is a text_completion object; streamed chunks use
+// the same shape with Usage omitted.
+type OpenAICompletionResponse struct {
+	ID      string                   `json:"id"`
+	Object  string                   `json:"object"`
+	Created int64                    `json:"created"`
+	Model   string                   `json:"model"`
+	Choices []OpenAICompletionChoice `json:"choices"`
+	Usage   *OpenAIUsage             `json:"usage,omitempty"`
+}
+
+type OpenAICompletionChoice struct {
+	Text         string  `json:"text"`
+	Index        int     `json:"index"`
+	Logprobs     any     `json:"logprobs"`
+	FinishReason *string `json:"finish_reason"`
+}
+
 type OpenAIChatToolCall struct {
 	ID       string                 `json:"id"`
 	Type     string                 `json:"type"`
@@ -14,6 +14,7 @@ type UsageEvent struct {
 	Timestamp        time.Time `json:"ts"`
 	KeyID            string    `json:"key_id"`
 	Label            string    `json:"label,omitempty"`
+	Model            string    `json:"model,omitempty"`
 	Path             string    `json:"path"`
 	Status           int       `json:"status"`
 	PromptTokens     int       `json:"prompt_tokens,omitempty"`
@@ -33,7 +34,10 @@ type UsageStore struct {
 	windowStart    time.Time
 	mu             sync.Mutex
 	counts         map[string]int
+	modelCounts    map[string]map[string]int
 	lastSeen       map[string]time.Time
+	modelQuotas    map[string]int64
+	keyModelQuotas map[string]map[string]int64
 }
 
 func NewUsageStore(path string, summaryPath string, maxBytes int64, maxBackups int, window time.Duration, eventsPath string, eventsMaxBytes int64, eventsBackups int) *UsageStore {
@@ -47,7 +51,9 @@ func NewUsageStore(path string, summaryPath string, maxBytes int64, maxBackups i
 		eventsBackups:  eventsBackups,
 		window:         window,
 		counts:         map[string]int{},
+		modelCounts:    map[string]map[string]int{},
 		lastSeen:       map[string]time.Time{},
+		keyModelQuotas: map[string]map[string]int64{},
 	}
 	if window > 0 {
 		store.windowStart = time.Now().UTC().Truncate(window)
@@ -74,6 +80,7 @@ func (u *UsageStore) Record(ev UsageEvent) {
 	}
 	if ev.TotalTokens > 0 {
 		u.counts[ev.KeyID] += ev.TotalTokens
+		u.addModelTokensLocked(ev.KeyID, ev.Model, ev.TotalTokens)
 	}
 	if !ev.Timestamp.IsZero() {
 		u.lastSeen[ev.KeyID] = ev.Timestamp
@@ -102,6 +109,78 @@ func (u *UsageStore) TotalTokens(keyID string) int {
 	return u.counts[keyID]
 }
 
+// SetModelQuotas sets the default per-model token limits applied to every key.
+func (u *UsageStore) SetModelQuotas(quotas map[string]int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.modelQuotas = quotas
+}
+
+// SetKeyModelQuotas sets per-model token limits for one key, overriding the
+// defaults model by model. A nil map clears the overrides.
+func (u *UsageStore) SetKeyModelQuotas(keyID string, quotas map[string]int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.keyModelQuotas == nil {
+		u.keyModelQuotas = map[string]map[string]int64{}
+	}
+	if len(quotas) == 0 {
+		delete(u.keyModelQuotas, keyID)
+		return
+	}
+	u.keyModelQuotas[keyID] = quotas
+}
+
+// ModelTokens returns the tokens a key has used on model in the current window.
+func (u *UsageStore) ModelTokens(keyID, model string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.resetIfWindowElapsed(time.Now().UTC())
+	return u.modelCounts[keyID][model]
+}
+
+// CheckModelQuota reports a key's token usage on model against its limit.
+// A zero limit means the model is not budgeted and is never exceeded.
+func (u *UsageStore) CheckModelQuota(keyID, model string) (used int64, limit int64, exceeded bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.resetIfWindowElapsed(time.Now().UTC())
+	limit = u.modelQuotas[model]
+	if q, ok := u.keyModelQuotas[keyID][model]; ok {
+		limit = q
+	}
+	used = int64(u.modelCounts[keyID][model])
+	return used, limit, limit > 0 && used >= limit
+}
+
+// ResetIn reports how long until the meter window resets the counts. It
+// is false when there is no window, so counts only reset by hand.
+func (u *UsageStore) ResetIn() (time.Duration, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.window <= 0 {
+		return 0, false
+	}
+	now := time.Now().UTC()
+	u.resetIfWindowElapsed(now)
+	return u.windowStart.Add(u.window).Sub(now), true
+}
+
+func (u *UsageStore) addModelTokensLocked(keyID, model string, tokens int) {
+	if model == "" || tokens <= 0 {
+		return
+	}
+	if u.modelCounts == nil {
+		u.modelCounts = map[string]map[string]int{}
+	}
+	m := u.modelCounts[keyID]
+	if m == nil {
+		m = map[string]int{}
+		u.modelCounts[keyID] = m
+	}
+	m[model] += tokens
+}
+
 func (u *UsageStore) ResetKey(keyID string) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
@@ -130,6 +209,7 @@ func (u *UsageStore) LoadFromFile() error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 	u.counts = map[string]int{}
+	u.modelCounts = map[string]map[string]int{}
 	u.lastSeen = map[string]time.Time{}
 	if u.window > 0 {
 		u.windowStart = time.Now().UTC().Truncate(u.window)
@@ -140,6 +220,7 @@ func (u *UsageStore) LoadFromFile() error {
 			continue
 		}
 		u.counts[ev.KeyID] += ev.TotalTokens
+		u.addModelTokensLocked(ev.KeyID, ev.Model, ev.TotalTokens)
 		if ev.Timestamp.After(u.lastSeen[ev.KeyID]) {
 			u.lastSeen[ev.KeyID] = ev.Timestamp
 		}
@@ -147,6 +228,21 @@ func (u *UsageStore) LoadFromFile() error {
 	return u.persistSummaryLocked()
 }
 
+// SetWindow changes the metering window. Counts are kept; when the length
+// changes, a new window starts at the current boundary of the new length.
+func (u *UsageStore) SetWindow(window time.Duration) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if window == u.window {
+		return
+	}
+	u.window = window
+	u.windowStart = time.Time{}
+	if window > 0 {
+		u.windowStart = time.Now().UTC().Truncate(window)
+	}
+}
+
 func (u *UsageStore) resetIfWindowElapsed(now time.Time) {
 	if u.window <= 0 {
 		return
@@ -160,6 +256,7 @@ func (u *UsageStore) resetIfWindowElapsed(now time.Time) {
 			u.resetKeyInternal(key, "window", now)
 		}
 		u.counts = map[string]int{}
+		u.modelCounts = map[string]map[string]int{}
 		u.lastSeen = map[string]time.Time{}
 		u.windowStart = now.Truncate(u.window)
 		u.persistSummaryLocked()
@@ -168,6 +265,7 @@ func (u *UsageStore) resetIfWindowElapsed(now time.Time) {
 
 func (u *UsageStore) resetKeyInternal(keyID string, reason string, now time.Time) {
 	u.counts[keyID] = 0
+	delete(u.modelCounts, keyID)
 	u.lastSeen[keyID] = now
 	u.persistSummaryLocked()
 	u.emitEventLocked("reset", keyID, reason, now)
@@ -214,6 +312,9 @@ func (u *UsageStore) persistSummaryLocked() error {
 	vals := map[string]any{}
 	for key, total := range u.counts {
 		entry := map[string]any{"total_tokens": total}
+		if models := u.modelCounts[key]; len(models) > 0 {
+			entry["models"] = models
+		}
 		if last := u.lastSeen[key]; !last.IsZero() {
 			entry["last_seen"] = last.Format(time.RFC3339)
 		}
@@ -240,8 +341,9 @@ func (u *UsageStore) loadSummary() error {
 	}
 	var payload struct {
 		Totals map[string]struct {
-			TotalTokens int    `json:"total_tokens"`
-			LastSeen    string `json:"last_seen"`
+			TotalTokens int            `json:"total_tokens"`
+			Models      map[string]int `json:"models"`
+			LastSeen    string         `json:"last_seen"`
 		} `json:"totals"`
 	}
 	if err := json.Unmarshal(buf, &payload); err != nil {
@@ -250,9 +352,13 @@ func (u *UsageStore) loadSummary() error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 	u.counts = map[string]int{}
+	u.modelCounts = map[string]map[string]int{}
 	u.lastSeen = map[string]time.Time{}
 	for key, entry := range payload.Totals {
 		u.counts[key] = entry.TotalTokens
+		for model, n := range entry.Models {
+			u.addModelTokensLocked(key, model, n)
+		}
 		if entry.LastSeen != "" {
 			if ts, err := time.Parse(time.RFC3339, entry.LastSeen); err == nil {
 				u.lastSeen[key] = ts
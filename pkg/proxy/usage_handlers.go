@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"godex/pkg/auth"
+)
+
+// UsageReport is one key's row in GET /v1/usage, mirroring
+// `godex proxy usage list`.
+type UsageReport struct {
+	KeyID       string `json:"key_id"`
+	Label       string `json:"label,omitempty"`
+	Requests    int    `json:"requests"`
+	TotalTokens int    `json:"total_tokens"`
+	LastSeen    string `json:"last_seen,omitempty"`
+}
+
+// handleUsage handles GET /v1/usage?since=24h&key=KEY_ID[&format=csv].
+// Managed keys only see their own usage; with --allow-any-key every key is
+// visible.
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	key, ok := s.requireAuth(w, r, auth.ScopeRead)
+	if !ok {
+		return
+	}
+	if ok, _ := s.allowRequest(w, r, key); !ok {
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		s.logRequest(r, http.StatusMethodNotAllowed, start)
+		return
+	}
+	q := r.URL.Query()
+	var since time.Duration
+	if raw := strings.TrimSpace(q.Get("since")); raw != "" {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid since %q", raw))
+			s.logRequest(r, http.StatusBadRequest, start)
+			return
+		}
+		since = d
+	}
+	keyID := strings.TrimSpace(q.Get("key"))
+	if !s.cfg.AllowAnyKey {
+		if keyID != "" && keyID != key.ID {
+			writeError(w, http.StatusForbidden, errors.New("usage for other keys is not visible to this key"))
+			s.logRequest(r, http.StatusForbidden, start)
+			return
+		}
+		keyID = key.ID
+	}
+
+	data, err := s.usageReports(since, keyID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		s.logRequest(r, http.StatusInternalServerError, start)
+		return
+	}
+
+	if strings.EqualFold(q.Get("format"), "csv") {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="godex-usage.csv"`)
+		w.WriteHeader(http.StatusOK)
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"key_id", "label", "requests", "total_tokens", "last_seen"})
+		for _, row := range data {
+			_ = cw.Write([]string{row.KeyID, row.Label, strconv.Itoa(row.Requests), strconv.Itoa(row.TotalTokens), row.LastSeen})
+		}
+		cw.Flush()
+		s.logRequest(r, http.StatusOK, start)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"object": "list",
+		"data":   data,
+	})
+	s.logRequest(r, http.StatusOK, start)
+}
+
+// usageReports summarizes the usage recorded in the stats file over the
+// last since (0 for all of it) per key, for keyID only when it is set.
+func (s *Server) usageReports(since time.Duration, keyID string) ([]UsageReport, error) {
+	events, err := ReadUsage(s.cfg.StatsPath, since, keyID)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	sums := SummarizeUsage(events)
+	sort.Slice(sums, func(i, j int) bool { return sums[i].KeyID < sums[j].KeyID })
+	data := make([]UsageReport, 0, len(sums))
+	for _, sum := range sums {
+		row := UsageReport{
+			KeyID:       sum.KeyID,
+			Label:       sum.Label,
+			Requests:    sum.Requests,
+			TotalTokens: sum.TotalTokens,
+		}
+		if !sum.LastSeen.IsZero() {
+			row.LastSeen = sum.LastSeen.Format(time.RFC3339)
+		}
+		data = append(data, row)
+	}
+	return data, nil
+}
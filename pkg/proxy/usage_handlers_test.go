@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newUsageTestServer(t *testing.T) (*Server, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	statsPath := filepath.Join(dir, "usage.jsonl")
+	keys, err := LoadKeyStore(filepath.Join(dir, "keys.json"))
+	if err != nil {
+		t.Fatalf("LoadKeyStore: %v", err)
+	}
+	rec, secret, err := keys.Add("agent-a", "60/m", 10, 0, "", 0)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	usage := NewUsageStore(statsPath, "", 0, 0, 0, "", 0, 0)
+	now := time.Now().UTC()
+	usage.Record(UsageEvent{Timestamp: now, KeyID: rec.ID, Label: "agent-a", Path: "/v1/responses", TotalTokens: 30})
+	usage.Record(UsageEvent{Timestamp: now, KeyID: rec.ID, Label: "agent-a", Path: "/v1/responses", TotalTokens: 12})
+	usage.Record(UsageEvent{Timestamp: now, KeyID: "key_other", Label: "other", Path: "/v1/responses", TotalTokens: 99})
+
+	srv := &Server{
+		cfg:      Config{StatsPath: statsPath},
+		keys:     keys,
+		usage:    usage,
+		limiters: NewLimiterStore("60/m", 10),
+	}
+	return srv, rec.ID, secret
+}
+
+func TestUsageEndpointJSON(t *testing.T) {
+	srv, keyID, secret := newUsageTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/usage?since=24h", nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	rr := httptest.NewRecorder()
+	srv.handleUsage(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("X-RateLimit-Limit") == "" {
+		t.Fatal("expected rate limit headers")
+	}
+	var body struct {
+		Data []UsageReport `json:"data"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(body.Data) != 1 {
+		t.Fatalf("expected only the caller's usage, got %+v", body.Data)
+	}
+	if got := body.Data[0]; got.KeyID != keyID || got.Requests != 2 || got.TotalTokens != 42 {
+		t.Fatalf("unexpected summary %+v", got)
+	}
+
+	// Asking for another key's usage is refused.
+	req = httptest.NewRequest(http.MethodGet, "/v1/usage?key=key_other", nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	rr = httptest.NewRecorder()
+	srv.handleUsage(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rr.Code)
+	}
+}
+
+func TestUsageEndpointCSV(t *testing.T) {
+	srv, keyID, secret := newUsageTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/usage?format=csv", nil)
+	req.Header.Set("Authorization", "Bearer "+secret)
+	rr := httptest.NewRecorder()
+	srv.handleUsage(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	rows, err := csv.NewReader(rr.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	if len(rows) != 2 || rows[0][0] != "key_id" || rows[1][0] != keyID || rows[1][3] != "42" {
+		t.Fatalf("unexpected csv rows %v", rows)
+	}
+}
+
+func TestUsageEndpointRequiresAuth(t *testing.T) {
+	srv, _, _ := newUsageTestServer(t)
+	rr := httptest.NewRecorder()
+	srv.handleUsage(rr, httptest.NewRequest(http.MethodGet, "/v1/usage", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rr.Code)
+	}
+}
+
+func TestHealthListsEndpoints(t *testing.T) {
+	srv := &Server{}
+	rr := httptest.NewRecorder()
+	srv.handleHealth(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+	var body struct {
+		AvailableEndpoints []string `json:"available_endpoints"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	found := false
+	for _, ep := range body.AvailableEndpoints {
+		if ep == "GET /v1/usage" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected /v1/usage in available_endpoints, got %v", body.AvailableEndpoints)
+	}
+}
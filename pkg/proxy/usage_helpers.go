@@ -1,9 +1,12 @@
 package proxy
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"godex/pkg/harness"
 	"godex/pkg/protocol"
 )
 
@@ -12,14 +15,20 @@ func (s *Server) allowRequest(w http.ResponseWriter, r *http.Request, key *KeyRe
 		writeError(w, http.StatusUnauthorized, errUnauthorized())
 		return false, "unauthorized"
 	}
-	if !s.limiters.Allow(key.ID, key.Rate, key.Burst) {
-		w.Header().Set("Retry-After", "5")
+	ok, state := s.limiters.Check(key.ID, key.Rate, key.Burst)
+	if state != nil {
+		state.WriteHeaders(w.Header())
+	}
+	if !ok {
+		if state == nil || state.RetryAfter <= 0 {
+			w.Header().Set("Retry-After", "5")
+		}
 		writeError(w, http.StatusTooManyRequests, errRateLimited())
 		return false, "rate"
 	}
 	if key.QuotaTokens > 0 && s.usage != nil {
 		if s.usage.TotalTokens(key.ID) >= int(key.QuotaTokens) {
-			w.Header().Set("Retry-After", "3600")
+			s.setQuotaRetryAfter(w)
 			writeError(w, http.StatusTooManyRequests, errQuotaExceeded())
 			return false, "quota"
 		}
@@ -36,7 +45,7 @@ func (s *Server) allowRequest(w http.ResponseWriter, r *http.Request, key *KeyRe
 	return true, ""
 }
 
-func (s *Server) recordUsage(r *http.Request, key *KeyRecord, status int, usage *protocol.Usage) {
+func (s *Server) recordUsage(r *http.Request, key *KeyRecord, model string, status int, usage *protocol.Usage) {
 	if key == nil || s.usage == nil {
 		return
 	}
@@ -47,6 +56,8 @@ func (s *Server) recordUsage(r *http.Request, key *KeyRecord, status int, usage
 		completion = usage.OutputTokens
 	}
 	total := prompt + completion
+	s.stats.tokensIn.Add(int64(prompt))
+	s.stats.tokensOut.Add(int64(completion))
 	if key.QuotaTokens > 0 && total > 0 {
 		// quota enforcement is coarse: reject once exceeded by total usage
 		// callers can use usage logs for stricter enforcement
@@ -58,6 +69,7 @@ func (s *Server) recordUsage(r *http.Request, key *KeyRecord, status int, usage
 		Timestamp:        time.Now().UTC(),
 		KeyID:            key.ID,
 		Label:            key.Label,
+		Model:            model,
 		Path:             reqPath(r),
 		Status:           status,
 		PromptTokens:     prompt,
@@ -66,6 +78,46 @@ func (s *Server) recordUsage(r *http.Request, key *KeyRecord, status int, usage
 	})
 }
 
+// allowModel enforces per-model token quotas. It runs after allowRequest so
+// rate limits and the key-wide quota are reported first.
+func (s *Server) allowModel(w http.ResponseWriter, key *KeyRecord, model string) bool {
+	if key == nil || s.usage == nil || model == "" {
+		return true
+	}
+	s.usage.SetKeyModelQuotas(key.ID, key.ModelQuotas)
+	used, limit, exceeded := s.usage.CheckModelQuota(key.ID, model)
+	if !exceeded {
+		return true
+	}
+	s.setQuotaRetryAfter(w)
+	writeJSON(w, http.StatusTooManyRequests, map[string]any{
+		"error": map[string]any{
+			"message": fmt.Sprintf("token quota exceeded for model %s", model),
+			"type":    "model_quota_exceeded",
+			"model":   model,
+			"used":    used,
+			"limit":   limit,
+		},
+	})
+	return false
+}
+
+// setQuotaRetryAfter sets Retry-After to when the meter window resets a
+// spent quota. Without a window waiting does not help, so it is left out.
+func (s *Server) setQuotaRetryAfter(w http.ResponseWriter) {
+	if wait, ok := s.usage.ResetIn(); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(wait))))
+	}
+}
+
+// harnessUsage converts harness token counts to the protocol shape.
+func harnessUsage(u *harness.UsageEvent) *protocol.Usage {
+	if u == nil {
+		return nil
+	}
+	return &protocol.Usage{InputTokens: u.InputTokens, OutputTokens: u.OutputTokens}
+}
+
 func reqPath(r *http.Request) string {
 	if r == nil || r.URL == nil {
 		return ""
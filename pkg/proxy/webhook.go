@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"godex/pkg/harness"
+)
+
+const (
+	// defaultWebhookMaxRetries is used when Config.WebhookMaxRetries is unset.
+	defaultWebhookMaxRetries = 3
+	// webhookJobTimeout bounds the turn a webhook job runs in the background.
+	webhookJobTimeout = 10 * time.Minute
+)
+
+var (
+	// webhookBaseDelay is the first retry delay; each retry doubles it.
+	webhookBaseDelay = time.Second
+	// webhookAllowPrivate lets tests deliver to loopback servers.
+	webhookAllowPrivate = false
+	webhookClient       = &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			// No Proxy: through HTTP(S)_PROXY the dial check would see the
+			// proxy's address, not the webhook host's. Check the address
+			// actually dialed, so a DNS answer that changed since webhookURL
+			// resolved the host cannot reach a private address.
+			DialContext:         (&net.Dialer{Timeout: 10 * time.Second, Control: webhookDialControl}).DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+		// A redirect could leave the allowed hosts.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	// cgnatRange is shared address space (RFC 6598), not publicly routable.
+	cgnatRange = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+)
+
+// webhookURL returns the validated X-Webhook-URL header, or "" if absent.
+// The host must match allowedHosts and resolve only to public addresses.
+func webhookURL(r *http.Request, allowedHosts []string) (string, error) {
+	raw := strings.TrimSpace(r.Header.Get("X-Webhook-URL"))
+	if raw == "" {
+		return "", nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid X-Webhook-URL %q (want absolute http(s) URL)", raw)
+	}
+	host := u.Hostname()
+	if !webhookHostAllowed(host, allowedHosts) {
+		return "", fmt.Errorf("X-Webhook-URL host %q is not in webhook_allowed_hosts", host)
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(r.Context(), host)
+	if err != nil {
+		return "", fmt.Errorf("resolve X-Webhook-URL host %q: %w", host, err)
+	}
+	for _, ip := range ips {
+		if !webhookIPAllowed(ip.IP) {
+			return "", fmt.Errorf("X-Webhook-URL host %q resolves to non-public address %s", host, ip.IP)
+		}
+	}
+	return u.String(), nil
+}
+
+// webhookHostAllowed reports whether host matches an entry of allowed,
+// either exactly or, for entries like "*.example.com", as a subdomain.
+// An empty list allows no host.
+func webhookHostAllowed(host string, allowed []string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == "" {
+			continue
+		}
+		if suffix, ok := strings.CutPrefix(a, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == a {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookIPAllowed rejects loopback, private, link-local (including cloud
+// metadata), shared, multicast and unspecified addresses.
+func webhookIPAllowed(ip net.IP) bool {
+	if webhookAllowPrivate {
+		return true
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() || cgnatRange.Contains(ip))
+}
+
+// webhookDialControl refuses connections to addresses webhookIPAllowed
+// rejects.
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !webhookIPAllowed(ip) {
+		return fmt.Errorf("webhook address %s is not public", host)
+	}
+	return nil
+}
+
+// startWebhookJob answers 202 with a job id and completes the turn in the
+// background, delivering the non-streamed response body to target.
+func (s *Server) startWebhookJob(
+	ctx context.Context,
+	w http.ResponseWriter,
+	target string,
+	h harness.Harness,
+	turn *harness.Turn,
+	model string,
+	key *KeyRecord,
+	start time.Time,
+	auditReq json.RawMessage,
+	sessionKey string,
+	requestID string,
+) {
+	jobID := newResponseID("job")
+	// The job outlives the request, but keeps its values (request ID,
+	// provider key) for upstream calls.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookJobTimeout)
+	done := s.trackStream()
+	go func() {
+		defer done()
+		defer cancel()
+		var payload any
+		resp, err := s.collectResponses(ctx, h, turn, model, key, start, auditReq, sessionKey, requestID, jobID)
+		if err != nil {
+			payload = map[string]any{
+				"error": map[string]any{
+					"message": err.Error(),
+					"type":    "proxy_error",
+				},
+			}
+		} else {
+			payload = resp
+		}
+		if err := s.deliverWebhook(target, jobID, requestID, payload); err != nil {
+			s.logger.Warn("webhook delivery failed", "request_id", requestID, "job_id", jobID, "error", err.Error())
+		}
+	}()
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"object": "job",
+		"job_id": jobID,
+		"status": "accepted",
+	})
+}
+
+// deliverWebhook POSTs payload to target, retrying non-2xx responses and
+// transport errors with exponential backoff.
+func (s *Server) deliverWebhook(target, jobID, requestID string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	retries := s.cfg.WebhookMaxRetries
+	if retries < 0 {
+		retries = 0
+	}
+	delay := webhookBaseDelay
+	for attempt := 0; ; attempt++ {
+		err = postWebhook(target, jobID, requestID, body)
+		if err == nil {
+			return nil
+		}
+		if attempt >= retries {
+			return fmt.Errorf("after %d attempt(s): %w", attempt+1, err)
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func postWebhook(target, jobID, requestID string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Job-ID", jobID)
+	if requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("webhook returned " + resp.Status)
+	}
+	return nil
+}
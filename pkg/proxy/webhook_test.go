@@ -0,0 +1,187 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"godex/pkg/harness"
+	"godex/pkg/router"
+)
+
+func TestResponsesWebhookDelivery(t *testing.T) {
+	prevDelay, prevPrivate := webhookBaseDelay, webhookAllowPrivate
+	webhookBaseDelay, webhookAllowPrivate = time.Millisecond, true
+	defer func() { webhookBaseDelay, webhookAllowPrivate = prevDelay, prevPrivate }()
+
+	var attempts atomic.Int32
+	delivered := make(chan []byte, 1)
+	var gotJobID string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first delivery to exercise the retry path.
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		gotJobID = r.Header.Get("X-Webhook-Job-ID")
+		body, _ := io.ReadAll(r.Body)
+		delivered <- body
+	}))
+	defer hook.Close()
+
+	mock := harness.NewMock(harness.MockConfig{
+		HarnessName: "mock",
+		Responses:   [][]harness.Event{{harness.NewTextEvent("async hello")}},
+	})
+	r := router.New(router.Config{UserPatterns: map[string][]string{"mock": {"any-model"}}})
+	r.Register("mock", mock)
+
+	srv := &Server{
+		cfg:           Config{AllowAnyKey: true, WebhookMaxRetries: 3, WebhookAllowedHosts: []string{"127.0.0.1"}},
+		cache:         NewCache(0),
+		harnessRouter: r,
+		models:        map[string]ModelEntry{},
+		usage:         NewUsageStore("", "", 0, 0, 0, "", 0, 0),
+		limiters:      NewLimiterStore("60/m", 10),
+		logger:        NewLogger(LogLevelInfo),
+	}
+
+	body, _ := json.Marshal(OpenAIResponsesRequest{Model: "any-model", Input: json.RawMessage(`"hi"`)})
+	req := httptest.NewRequest("POST", "/v1/responses", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set("X-Webhook-URL", hook.URL)
+	w := httptest.NewRecorder()
+	srv.handleResponses(w, req)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	var accepted struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &accepted); err != nil || accepted.JobID == "" {
+		t.Fatalf("expected job_id in body, got %s", w.Body.String())
+	}
+
+	select {
+	case raw := <-delivered:
+		var resp OpenAIResponsesResponse
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			t.Fatalf("decode webhook body: %v", err)
+		}
+		if resp.Object != "response" || len(resp.Output) == 0 || resp.Output[0].Content[0].Text != "async hello" {
+			t.Fatalf("unexpected webhook body: %s", raw)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+	srv.streams.Wait()
+	if gotJobID != accepted.JobID {
+		t.Fatalf("expected job id %q on delivery, got %q", accepted.JobID, gotJobID)
+	}
+	if n := attempts.Load(); n != 2 {
+		t.Fatalf("expected 2 attempts, got %d", n)
+	}
+}
+
+func TestDeliverWebhookGivesUp(t *testing.T) {
+	prevDelay, prevPrivate := webhookBaseDelay, webhookAllowPrivate
+	webhookBaseDelay, webhookAllowPrivate = time.Millisecond, true
+	defer func() { webhookBaseDelay, webhookAllowPrivate = prevDelay, prevPrivate }()
+
+	var attempts atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hook.Close()
+
+	srv := &Server{cfg: Config{WebhookMaxRetries: 3}}
+	if err := srv.deliverWebhook(hook.URL, "job_1", "", map[string]any{"ok": true}); err == nil {
+		t.Fatal("expected delivery error")
+	}
+	if n := attempts.Load(); n != 4 {
+		t.Fatalf("expected 1 attempt + 3 retries, got %d", n)
+	}
+}
+
+func TestWebhookURLValidation(t *testing.T) {
+	allowed := []string{"93.184.216.34", "127.0.0.1", "169.254.169.254", "10.0.0.1"}
+	for target, ok := range map[string]bool{
+		"ftp://93.184.216.34/hook":           false,
+		"https://93.184.216.34/hook":         true,
+		"https://203.0.113.9/hook":           false, // not allowed
+		"http://127.0.0.1:8080/hook":         false,
+		"http://169.254.169.254/latest/meta": false,
+		"http://10.0.0.1/hook":               false,
+	} {
+		req := httptest.NewRequest("POST", "/v1/responses", nil)
+		req.Header.Set("X-Webhook-URL", target)
+		got, err := webhookURL(req, allowed)
+		if ok && (err != nil || got != target) {
+			t.Errorf("%s: got %q, %v", target, got, err)
+		}
+		if !ok && err == nil {
+			t.Errorf("%s: accepted", target)
+		}
+	}
+
+	if !webhookHostAllowed("Hooks.Example.com", []string{"*.example.com"}) || webhookHostAllowed("example.com", []string{"*.example.com"}) {
+		t.Error("wildcard host matching")
+	}
+	if webhookHostAllowed("example.com", nil) {
+		t.Error("empty allowlist allowed a host")
+	}
+	if err := webhookDialControl("tcp", "127.0.0.1:80", nil); err == nil {
+		t.Error("dial to loopback allowed")
+	}
+	if err := webhookDialControl("tcp", "[fd00:ec2::254]:80", nil); err == nil {
+		t.Error("dial to private IPv6 allowed")
+	}
+}
+
+func TestPostWebhookIgnoresProxyEnv(t *testing.T) {
+	var proxied atomic.Int32
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied.Add(1)
+	}))
+	defer proxy.Close()
+	t.Setenv("HTTP_PROXY", proxy.URL)
+	t.Setenv("HTTPS_PROXY", proxy.URL)
+
+	// The dial check must see the link-local target, not the proxy.
+	err := postWebhook("http://169.254.169.254/latest/meta-data", "job_1", "", []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "169.254.169.254 is not public") {
+		t.Fatalf("expected the target address to be refused, got %v", err)
+	}
+	if n := proxied.Load(); n != 0 {
+		t.Fatalf("expected no proxied requests, got %d", n)
+	}
+}
+
+func TestDeliverWebhookNegativeRetriesDisables(t *testing.T) {
+	prevDelay, prevPrivate := webhookBaseDelay, webhookAllowPrivate
+	webhookBaseDelay, webhookAllowPrivate = time.Millisecond, true
+	defer func() { webhookBaseDelay, webhookAllowPrivate = prevDelay, prevPrivate }()
+
+	var attempts atomic.Int32
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hook.Close()
+
+	srv := &Server{cfg: Config{WebhookMaxRetries: -1}}
+	if err := srv.deliverWebhook(hook.URL, "job_1", "", map[string]any{"ok": true}); err == nil {
+		t.Fatal("expected delivery error")
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Fatalf("expected a single attempt, got %d", n)
+	}
+}
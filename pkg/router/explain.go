@@ -0,0 +1,149 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// BackendMatch is one backend that can serve a model, and why.
+type BackendMatch struct {
+	Backend string `json:"backend"`
+	// Pattern is the user pattern that matched, empty when the backend
+	// claimed the model through its own MatchesModel.
+	Pattern string `json:"pattern,omitempty"`
+	// Priority is the backend's registration priority.
+	Priority int `json:"priority,omitempty"`
+}
+
+// RouteExplanation describes how the router resolves a model, for
+// debugging routing decisions.
+type RouteExplanation struct {
+	Model          string         `json:"model"`
+	ExpandedAlias  string         `json:"expanded_alias,omitempty"`
+	MatchedBackend string         `json:"matched_backend,omitempty"`
+	MatchedPattern string         `json:"matched_pattern,omitempty"`
+	AllCandidates  []BackendMatch `json:"candidates,omitempty"`
+	// Unhealthy lists matching backends skipped by health checks.
+	Unhealthy []string `json:"unhealthy,omitempty"`
+	Reason    string   `json:"reason"`
+}
+
+// String formats the explanation as a single log line.
+func (e RouteExplanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "model=%s", e.Model)
+	if e.ExpandedAlias != "" {
+		fmt.Fprintf(&b, " expanded=%s", e.ExpandedAlias)
+	}
+	fmt.Fprintf(&b, " backend=%s", e.MatchedBackend)
+	if e.MatchedPattern != "" {
+		fmt.Fprintf(&b, " pattern=%s", e.MatchedPattern)
+	}
+	fmt.Fprintf(&b, " candidates=%s reason=%q", strings.Join(candidateNames(e.AllCandidates), ","), e.Reason)
+	return b.String()
+}
+
+// ExplainRouting reports how HarnessFor would route model after alias
+// expansion: every backend that can serve it, in priority order, the one
+// chosen first and the reason. Canary and load-balancing picks are noted but
+// not made, so the explanation does not change routing stats.
+func (r *Router) ExplainRouting(model string) RouteExplanation {
+	expanded := r.ExpandAlias(model)
+	e := r.explain(model, expanded)
+	if c := r.config.Canary; e.MatchedBackend != "" && r.canaryServes(expanded) {
+		e.Reason += fmt.Sprintf("; %g%% of requests go to canary %s", c.Percent, c.Backend)
+	}
+	return e
+}
+
+// explain resolves expanded, which model expanded to, without picking among
+// candidates.
+func (r *Router) explain(model, expanded string) RouteExplanation {
+	e := RouteExplanation{Model: model}
+	e.AllCandidates, e.Unhealthy = r.routable(expanded)
+	var via string
+	if expanded != model {
+		e.ExpandedAlias = expanded
+		via = fmt.Sprintf("alias %q expands to %q; ", model, expanded)
+	}
+	if len(e.AllCandidates) == 0 {
+		if len(e.Unhealthy) > 0 {
+			e.Reason = fmt.Sprintf("%severy matching backend is unhealthy (%s); the model is not routed", via, strings.Join(e.Unhealthy, ", "))
+		} else {
+			e.Reason = via + "no backend pattern or harness matches; the model is not routed"
+		}
+		return e
+	}
+	first := e.AllCandidates[0]
+	e.MatchedBackend = first.Backend
+	e.MatchedPattern = first.Pattern
+	if first.Pattern != "" {
+		e.Reason = fmt.Sprintf("%suser pattern %q routes to %s", via, first.Pattern, first.Backend)
+	} else {
+		e.Reason = fmt.Sprintf("%sno user pattern matches; falling back to %s, which claims the model", via, first.Backend)
+	}
+	if len(e.Unhealthy) > 0 {
+		e.Reason += fmt.Sprintf("; skipped unhealthy %s", strings.Join(e.Unhealthy, ", "))
+	}
+	if tier := len(r.topTier(candidateNames(e.AllCandidates))); tier > 1 {
+		switch {
+		case r.config.LatencyRouting:
+			e.Reason += fmt.Sprintf("; the fastest of %d candidates is chosen per request", tier)
+		case len(r.config.Weights) > 0:
+			e.Reason += fmt.Sprintf("; %d candidates are chosen by weight per request", tier)
+		}
+	}
+	return e
+}
+
+// matches lists every harness that can serve model in routing priority
+// order: harnesses with a matching user pattern first, then harnesses whose
+// MatchesModel accepts it, each group sorted by registration priority and
+// then registration order.
+func (r *Router) matches(model string) []BackendMatch {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := map[string]bool{}
+	var byPattern, byHarness []BackendMatch
+	for _, rh := range r.harnesses {
+		if pattern, ok := matchesPatterns(model, r.config.UserPatterns[rh.name]); ok {
+			seen[rh.name] = true
+			byPattern = append(byPattern, BackendMatch{Backend: rh.name, Pattern: pattern, Priority: rh.priority})
+		}
+	}
+	for _, rh := range r.harnesses {
+		if !seen[rh.name] && rh.harness.MatchesModel(model) {
+			seen[rh.name] = true
+			byHarness = append(byHarness, BackendMatch{Backend: rh.name, Priority: rh.priority})
+		}
+	}
+	byPriority := func(ms []BackendMatch) func(i, j int) bool {
+		return func(i, j int) bool { return ms[i].Priority > ms[j].Priority }
+	}
+	sort.SliceStable(byPattern, byPriority(byPattern))
+	sort.SliceStable(byHarness, byPriority(byHarness))
+	return append(byPattern, byHarness...)
+}
+
+// routable splits the backends matching model into healthy ones, in
+// priority order, and the names of unhealthy ones.
+func (r *Router) routable(model string) (healthy []BackendMatch, unhealthy []string) {
+	for _, m := range r.matches(model) {
+		if r.Healthy(m.Backend) {
+			healthy = append(healthy, m)
+		} else {
+			unhealthy = append(unhealthy, m.Backend)
+		}
+	}
+	return healthy, unhealthy
+}
+
+func candidateNames(matches []BackendMatch) []string {
+	var names []string
+	for _, m := range matches {
+		names = append(names, m.Backend)
+	}
+	return names
+}
@@ -0,0 +1,80 @@
+package router
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout bounds each background health check.
+const healthCheckTimeout = 10 * time.Second
+
+// RegisterHealthCheck sets the check run for backend every
+// Config.HealthCheckInterval. A backend whose last check returned false is
+// left out of routing until a later check passes. Backends without a check
+// are healthy unless marked otherwise.
+func (r *Router) RegisterHealthCheck(backend string, check func(ctx context.Context) bool) {
+	r.healthMu.Lock()
+	defer r.healthMu.Unlock()
+	if r.healthChecks == nil {
+		r.healthChecks = map[string]func(context.Context) bool{}
+	}
+	r.healthChecks[backend] = check
+}
+
+// MarkUnhealthy excludes backend from routing until MarkHealthy is called
+// or its next health check passes.
+func (r *Router) MarkUnhealthy(backend string) {
+	r.unhealthy.Store(backend, true)
+}
+
+// MarkHealthy returns backend to routing.
+func (r *Router) MarkHealthy(backend string) {
+	r.unhealthy.Delete(backend)
+}
+
+// Healthy reports whether backend may be routed to.
+func (r *Router) Healthy(backend string) bool {
+	_, down := r.unhealthy.Load(backend)
+	return !down
+}
+
+// CheckHealth runs every registered health check once, concurrently, and
+// caches the results.
+func (r *Router) CheckHealth(ctx context.Context) {
+	r.healthMu.Lock()
+	checks := make(map[string]func(context.Context) bool, len(r.healthChecks))
+	for name, check := range r.healthChecks {
+		checks[name] = check
+	}
+	r.healthMu.Unlock()
+
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			if check(ctx) {
+				r.MarkHealthy(name)
+			} else {
+				r.MarkUnhealthy(name)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (r *Router) runHealthChecks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.CheckHealth(context.Background())
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package router
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// regexpPrefix marks a routing pattern as a Go regular expression, e.g.
+// "~^claude-3\.5-sonnet$". Other patterns are case-insensitive prefixes.
+const regexpPrefix = "~"
+
+// patternRegexps caches compiled regexp patterns: pattern -> *regexp.Regexp,
+// or nil when the pattern does not compile.
+var patternRegexps sync.Map
+
+// matchesPatterns returns the first of patterns that model matches.
+func matchesPatterns(model string, patterns []string) (string, bool) {
+	for _, pattern := range patterns {
+		if matchPattern(model, pattern) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// matchPattern reports whether model matches one routing pattern. Regexp
+// patterns match case-insensitively anywhere in model unless anchored; an
+// invalid one matches nothing.
+func matchPattern(model, pattern string) bool {
+	if expr, ok := strings.CutPrefix(pattern, regexpPrefix); ok {
+		re := compilePattern(expr)
+		return re != nil && re.MatchString(model)
+	}
+	return strings.HasPrefix(strings.ToLower(model), strings.ToLower(pattern))
+}
+
+func compilePattern(expr string) *regexp.Regexp {
+	if v, ok := patternRegexps.Load(expr); ok {
+		return v.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile("(?i)" + expr)
+	if err != nil {
+		re = nil
+	}
+	patternRegexps.Store(expr, re)
+	return re
+}
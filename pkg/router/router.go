@@ -5,8 +5,15 @@ package router
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand/v2"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"godex/pkg/harness"
 )
@@ -16,34 +23,172 @@ type Config struct {
 	// UserAliases are override aliases that take priority over harness defaults.
 	UserAliases map[string]string
 
-	// UserPatterns are override patterns: map[harnessName][]prefix.
+	// UserPatterns are override patterns: map[harnessName][]pattern. A
+	// pattern is a model prefix, or a regexp when it starts with "~".
 	UserPatterns map[string][]string
+
+	// StickySessionTTL keeps a session on the backend first chosen for it
+	// until the session has been idle this long. The TTL slides: every
+	// request routed through the pin renews it, so a busy session never
+	// moves. Zero disables stickiness.
+	StickySessionTTL time.Duration
+
+	// Weights spreads requests across the backends that can serve a model:
+	// each is chosen with probability proportional to its weight. Backends
+	// without a weight count as 1; a weight of 0 drains a backend, which is
+	// then only chosen when every candidate is drained. Empty keeps the
+	// first candidate.
+	Weights map[string]int
+
+	// LatencyRouting sends each request to the candidate with the lowest
+	// rolling average latency, as reported to RecordLatency. It takes
+	// precedence over Weights.
+	LatencyRouting bool
+
+	// WarmupRequests is how many latencies each candidate needs before
+	// LatencyRouting trusts its average; until then candidates take turns.
+	// At least one is always required.
+	WarmupRequests int
+
+	// Canary diverts a share of traffic to a backend being rolled out.
+	Canary CanaryConfig
+
+	// HealthCheckInterval is how often registered health checks run. Zero
+	// disables them; MarkUnhealthy still applies.
+	HealthCheckInterval time.Duration
+
+	// Debug logs every canary decision with its session hash.
+	Debug bool
+}
+
+// CanaryConfig sends Percent (0-100) of the requests for models matching
+// ModelPattern, a routing pattern, to Backend, which must be registered. An
+// empty ModelPattern matches every model. Requests are assigned by hashing the
+// session key with a request counter.
+type CanaryConfig struct {
+	Backend      string
+	ModelPattern string
+	Percent      float64
 }
 
+// latencyWindow is the number of recent latencies averaged per backend.
+const latencyWindow = 100
+
 // Router selects the appropriate harness based on model name.
 type Router struct {
 	harnesses []registeredHarness // ordered
 	config    Config
 	mu        sync.RWMutex
+
+	sticky    sync.Map // session key -> stickyEntry
+	stop      chan struct{}
+	closeOnce sync.Once
+	now       func() time.Time
+
+	caps sync.Map // capsKey -> harness.CapabilitySet
+
+	statsMu sync.Mutex
+	stats   map[string]int64 // backend name -> times selected
+
+	latencyMu sync.Mutex
+	latency   map[string]*latencyRing
+	rr        atomic.Uint64 // round-robin position during warm-up
+
+	canaryCount atomic.Uint64
+
+	healthMu     sync.Mutex
+	healthChecks map[string]func(context.Context) bool
+	unhealthy    sync.Map // backend name -> true
 }
 
-type registeredHarness struct {
+// latencyRing holds a backend's last latencyWindow latencies.
+type latencyRing struct {
+	samples [latencyWindow]time.Duration
+	next    int
+	count   int
+	sum     time.Duration
+}
+
+func (l *latencyRing) add(d time.Duration) {
+	if l.count == latencyWindow {
+		l.sum -= l.samples[l.next]
+	} else {
+		l.count++
+	}
+	l.samples[l.next] = d
+	l.sum += d
+	l.next = (l.next + 1) % latencyWindow
+}
+
+func (l *latencyRing) average() time.Duration {
+	if l.count == 0 {
+		return 0
+	}
+	return l.sum / time.Duration(l.count)
+}
+
+type capsKey struct {
+	name  string
+	model string
+}
+
+type stickyEntry struct {
 	name    string
-	harness harness.Harness
+	expires time.Time
+}
+
+type registeredHarness struct {
+	name     string
+	harness  harness.Harness
+	priority int
+}
+
+// RegisterOption configures a harness registration.
+type RegisterOption func(*registeredHarness)
+
+// WithPriority ranks a backend among others that match the same model:
+// higher is preferred. The default is 0.
+func WithPriority(p int) RegisterOption {
+	return func(rh *registeredHarness) { rh.priority = p }
 }
 
 // New creates a new router with the given configuration.
 func New(cfg Config) *Router {
-	return &Router{
+	r := &Router{
 		config: cfg,
+		now:    time.Now,
+	}
+	if cfg.StickySessionTTL > 0 || cfg.HealthCheckInterval > 0 {
+		r.stop = make(chan struct{})
+	}
+	if cfg.StickySessionTTL > 0 {
+		go r.cleanupSticky(cfg.StickySessionTTL)
 	}
+	if cfg.HealthCheckInterval > 0 {
+		go r.runHealthChecks(cfg.HealthCheckInterval)
+	}
+	return r
+}
+
+// Close stops the sticky-session cleanup and health-check goroutines, if
+// any.
+func (r *Router) Close() {
+	r.closeOnce.Do(func() {
+		if r.stop != nil {
+			close(r.stop)
+		}
+	})
 }
 
 // Register adds a harness to the router under the given name.
-func (r *Router) Register(name string, h harness.Harness) {
+func (r *Router) Register(name string, h harness.Harness, opts ...RegisterOption) {
+	rh := registeredHarness{name: name, harness: h}
+	for _, opt := range opts {
+		opt(&rh)
+	}
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.harnesses = append(r.harnesses, registeredHarness{name: name, harness: h})
+	r.harnesses = append(r.harnesses, rh)
 }
 
 // ExpandAlias expands a model alias to its full name.
@@ -65,38 +210,412 @@ func (r *Router) ExpandAlias(model string) string {
 	return model
 }
 
-// HarnessFor returns the appropriate harness for the given model.
-// Checks user patterns first, then asks each harness MatchesModel().
+// HarnessFor returns the appropriate harness for the given model. When
+// several harnesses match, the first in Candidates order wins, or one is
+// drawn by Config.Weights if set. Config.Canary may divert the request
+// first. Unhealthy backends are never returned.
 func (r *Router) HarnessFor(model string) harness.Harness {
+	return r.harnessFor("", model)
+}
+
+func (r *Router) harnessFor(sessionKey, model string) harness.Harness {
+	name, ok := r.Canary(context.Background(), sessionKey, model, harness.CapabilitySet{})
+	if !ok {
+		name = r.Choose(r.Candidates(model))
+	}
+	if name == "" {
+		return nil
+	}
+	r.RecordPick(name)
+	return r.Get(name)
+}
+
+// Routable reports whether a healthy backend, or the canary, can serve
+// model. Unlike HarnessFor it picks nothing, so it leaves Stats, the
+// latency warm-up rotation and the canary counter alone; use it to check
+// a model before routing a request for it.
+func (r *Router) Routable(model string) bool {
+	if r.canaryServes(model) {
+		return true
+	}
+	healthy, _ := r.routable(model)
+	return len(healthy) > 0
+}
+
+// Choose picks one of candidates: the fastest under Config.LatencyRouting,
+// one drawn by Config.Weights when set, and the first otherwise. Latency and
+// weights only choose among the leading candidates that share the first
+// one's priority, so a lower-priority backend stays a fallback. It returns
+// "" for no candidates. Each call advances the latency warm-up rotation, so
+// call it once per routed request; the pick is not counted in Stats until
+// RecordPick.
+func (r *Router) Choose(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	name := candidates[0]
+	switch {
+	case r.config.LatencyRouting:
+		name = r.fastest(r.topTier(candidates))
+	case len(r.config.Weights) > 0:
+		name = weightedRandom(r.topTier(candidates), r.config.Weights)
+	}
+	return name
+}
+
+// topTier returns the leading candidates registered with the same
+// priority as the first.
+func (r *Router) topTier(candidates []string) []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	priority := r.priorityLocked(candidates[0])
+	for i, name := range candidates[1:] {
+		if r.priorityLocked(name) != priority {
+			return candidates[:i+1]
+		}
+	}
+	return candidates
+}
 
-	lower := strings.ToLower(model)
-
-	// Check user pattern overrides first
-	if r.config.UserPatterns != nil {
-		for harnessName, patterns := range r.config.UserPatterns {
-			for _, pattern := range patterns {
-				pattern = strings.ToLower(pattern)
-				if lower == pattern || strings.HasPrefix(lower, pattern) {
-					for _, rh := range r.harnesses {
-						if rh.name == harnessName {
-							return rh.harness
-						}
-					}
-				}
-			}
+func (r *Router) priorityLocked(name string) int {
+	for _, rh := range r.harnesses {
+		if rh.name == name {
+			return rh.priority
 		}
 	}
+	return 0
+}
+
+// RecordPick counts a request routed to the named backend in Stats.
+// HarnessFor, HarnessForSession, HarnessWithCapabilities and
+// HarnessForRequest record their own
+// picks; callers that route with Choose or Canary record the backend they
+// finally use.
+func (r *Router) RecordPick(name string) {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	if r.stats == nil {
+		r.stats = map[string]int64{}
+	}
+	r.stats[name]++
+}
 
-	// Ask each harness
+// Stats returns how many requests have been routed to each backend.
+func (r *Router) Stats() map[string]int64 {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+	out := make(map[string]int64, len(r.stats))
+	for name, n := range r.stats {
+		out[name] = n
+	}
+	return out
+}
+
+// RecordLatency adds one request's latency to backend's rolling average.
+func (r *Router) RecordLatency(backend string, latency time.Duration) {
+	r.latencyMu.Lock()
+	defer r.latencyMu.Unlock()
+	if r.latency == nil {
+		r.latency = map[string]*latencyRing{}
+	}
+	ring := r.latency[backend]
+	if ring == nil {
+		ring = &latencyRing{}
+		r.latency[backend] = ring
+	}
+	ring.add(latency)
+}
+
+// AverageLatency returns backend's rolling average latency and how many
+// samples it covers.
+func (r *Router) AverageLatency(backend string) (time.Duration, int) {
+	r.latencyMu.Lock()
+	defer r.latencyMu.Unlock()
+	ring := r.latency[backend]
+	if ring == nil {
+		return 0, 0
+	}
+	return ring.average(), ring.count
+}
+
+// fastest returns the candidate with the lowest average latency, or the
+// next one in round-robin order while any candidate is still warming up.
+func (r *Router) fastest(candidates []string) string {
+	warmup := max(r.config.WarmupRequests, 1)
+	best := ""
+	var bestAvg time.Duration
+	for _, name := range candidates {
+		avg, n := r.AverageLatency(name)
+		if n < warmup {
+			return candidates[(r.rr.Add(1)-1)%uint64(len(candidates))]
+		}
+		if best == "" || avg < bestAvg {
+			best, bestAvg = name, avg
+		}
+	}
+	return best
+}
+
+// NameOf returns the name h was registered under, or "" if it is unknown.
+func (r *Router) NameOf(h harness.Harness) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	for _, rh := range r.harnesses {
-		if rh.harness.MatchesModel(model) {
-			return rh.harness
+		if rh.harness == h {
+			return rh.name
 		}
 	}
+	return ""
+}
 
-	return nil
+// Canary reports whether this request for model goes to the canary backend,
+// and names it. The decision hashes sessionKey with a running request
+// counter, so Config.Canary.Percent of requests are diverted. A canary that
+// is unregistered, or whose capabilities for model do not cover need, is
+// never chosen.
+func (r *Router) Canary(ctx context.Context, sessionKey, model string, need harness.CapabilitySet) (string, bool) {
+	c := r.config.Canary
+	if !r.canaryServes(model) {
+		return "", false
+	}
+	if !need.IsZero() {
+		caps, err := r.Capabilities(ctx, c.Backend, model)
+		if err == nil && !caps.Covers(need) {
+			return "", false
+		}
+	}
+	sum := canaryHash(sessionKey, r.canaryCount.Add(1))
+	hit := float64(sum%10000) < c.Percent*100
+	if r.config.Debug {
+		log.Printf("[DEBUG] router: canary model=%s session_hash=%016x canary=%t", model, sum, hit)
+	}
+	if !hit {
+		return "", false
+	}
+	return c.Backend, true
+}
+
+// canaryServes reports whether the configured canary takes traffic for
+// model: it must be registered and healthy.
+func (r *Router) canaryServes(model string) bool {
+	c := r.config.Canary
+	if c.Backend == "" || c.Percent <= 0 {
+		return false
+	}
+	if !matchPattern(model, c.ModelPattern) {
+		return false
+	}
+	return r.Get(c.Backend) != nil && r.Healthy(c.Backend)
+}
+
+func canaryHash(sessionKey string, n uint64) uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s:%d", sessionKey, n)
+	return h.Sum64()
+}
+
+// randIntN is swapped out by tests for a seeded source.
+var randIntN = rand.IntN
+
+// weightedRandom draws one of backends with probability proportional to its
+// weight; missing weights count as 1. If every weight is 0 it returns the
+// first backend.
+func weightedRandom(backends []string, weights map[string]int) string {
+	if len(backends) == 0 {
+		return ""
+	}
+	total := 0
+	for _, name := range backends {
+		total += weightOf(name, weights)
+	}
+	if total == 0 {
+		return backends[0]
+	}
+	n := randIntN(total)
+	for _, name := range backends {
+		n -= weightOf(name, weights)
+		if n < 0 {
+			return name
+		}
+	}
+	return backends[len(backends)-1]
+}
+
+// weightOf returns name's weight: 1 if unset, and never below 0.
+func weightOf(name string, weights map[string]int) int {
+	w, ok := weights[name]
+	if !ok {
+		return 1
+	}
+	return max(w, 0)
+}
+
+// Candidates returns the names of every healthy harness that can serve
+// model, in routing priority order: harnesses with a matching user pattern
+// first, then harnesses whose MatchesModel accepts it, each group sorted by
+// registration priority and then registration order.
+func (r *Router) Candidates(model string) []string {
+	healthy, _ := r.routable(model)
+	return candidateNames(healthy)
+}
+
+// Pinned returns the backend a session is pinned to, if the pin is live.
+func (r *Router) Pinned(sessionKey string) (string, bool) {
+	if r.config.StickySessionTTL <= 0 || sessionKey == "" {
+		return "", false
+	}
+	v, ok := r.sticky.Load(sessionKey)
+	if !ok {
+		return "", false
+	}
+	entry := v.(stickyEntry)
+	if !r.now().Before(entry.expires) {
+		r.sticky.Delete(sessionKey)
+		return "", false
+	}
+	return entry.name, true
+}
+
+// Pin routes sessionKey to the named backend for the next StickySessionTTL,
+// replacing any earlier pin and its expiry. pick re-pins on every request,
+// which is what makes the TTL an idle timeout. It is a no-op when
+// stickiness is disabled or the key is empty.
+func (r *Router) Pin(sessionKey, name string) {
+	if r.config.StickySessionTTL <= 0 || sessionKey == "" || name == "" {
+		return
+	}
+	r.sticky.Store(sessionKey, stickyEntry{name: name, expires: r.now().Add(r.config.StickySessionTTL)})
+}
+
+// HarnessForSession is HarnessFor with sticky sessions: a live pin wins as
+// long as the pinned backend can still serve model; otherwise a candidate is
+// chosen as in HarnessFor and pinned.
+func (r *Router) HarnessForSession(sessionKey, model string) harness.Harness {
+	if r.config.StickySessionTTL <= 0 || sessionKey == "" {
+		return r.harnessFor(sessionKey, model)
+	}
+	return r.pick(context.Background(), sessionKey, model, harness.CapabilitySet{}, r.Candidates(model))
+}
+
+// HarnessWithCapabilities is HarnessForSession limited to backends whose
+// capabilities for model cover need, e.g. SupportsVision for a request that
+// carries images. A zero need behaves exactly like HarnessForSession.
+func (r *Router) HarnessWithCapabilities(ctx context.Context, sessionKey, model string, need harness.CapabilitySet) harness.Harness {
+	if need.IsZero() {
+		return r.HarnessForSession(sessionKey, model)
+	}
+	return r.pick(ctx, sessionKey, model, need, r.CandidatesFor(ctx, model, need))
+}
+
+// HarnessForRequest returns the backend registered as override, skipping
+// pattern matching, health and capability checks. An empty or unknown
+// override is ignored and model is routed as in HarnessFor.
+func (r *Router) HarnessForRequest(model, override string) harness.Harness {
+	if override != "" {
+		if h := r.Get(override); h != nil {
+			r.RecordPick(override)
+			return h
+		}
+	}
+	return r.HarnessFor(model)
+}
+
+// CandidatesFor is Candidates without the backends whose capabilities for
+// model do not cover need. A backend whose Capabilities call fails is kept,
+// so an unknown answer never hides a working backend.
+func (r *Router) CandidatesFor(ctx context.Context, model string, need harness.CapabilitySet) []string {
+	candidates := r.Candidates(model)
+	if need.IsZero() {
+		return candidates
+	}
+	out := candidates[:0]
+	for _, name := range candidates {
+		caps, err := r.Capabilities(ctx, name, model)
+		if err != nil || caps.Covers(need) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// Capabilities returns the named backend's capabilities for model, asking
+// the harness once and caching the answer. Errors are not cached.
+func (r *Router) Capabilities(ctx context.Context, name, model string) (harness.CapabilitySet, error) {
+	key := capsKey{name: name, model: model}
+	if v, ok := r.caps.Load(key); ok {
+		return v.(harness.CapabilitySet), nil
+	}
+	h := r.Get(name)
+	if h == nil {
+		return harness.CapabilitySet{}, fmt.Errorf("router: unknown backend %q", name)
+	}
+	caps, err := h.Capabilities(harness.WithModel(ctx, model))
+	if err != nil {
+		return harness.CapabilitySet{}, err
+	}
+	r.caps.Store(key, caps)
+	return caps, nil
+}
+
+// pick returns the pinned backend if it is among candidates, otherwise the
+// canary or the one Choose picks, pinning and recording whichever is chosen.
+// Re-pinning a live pin renews its expiry on purpose; see StickySessionTTL.
+func (r *Router) pick(ctx context.Context, sessionKey, model string, need harness.CapabilitySet, candidates []string) harness.Harness {
+	name := r.pinnedCandidate(sessionKey, model, candidates)
+	if name == "" {
+		var ok bool
+		if name, ok = r.Canary(ctx, sessionKey, model, need); !ok {
+			name = r.Choose(candidates)
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	r.Pin(sessionKey, name)
+	r.RecordPick(name)
+	return r.Get(name)
+}
+
+// pinnedCandidate returns the backend sessionKey is pinned to if it can
+// still serve model: the canary, or one of candidates. Otherwise it
+// returns "".
+func (r *Router) pinnedCandidate(sessionKey, model string, candidates []string) string {
+	name, ok := r.Pinned(sessionKey)
+	if !ok {
+		return ""
+	}
+	if name == r.config.Canary.Backend && r.canaryServes(model) {
+		return name
+	}
+	for _, c := range candidates {
+		if c == name {
+			return name
+		}
+	}
+	return ""
+}
+
+func (r *Router) cleanupSticky(ttl time.Duration) {
+	ticker := time.NewTicker(ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.pruneSticky()
+		}
+	}
+}
+
+func (r *Router) pruneSticky() {
+	now := r.now()
+	r.sticky.Range(func(k, v any) bool {
+		if !now.Before(v.(stickyEntry).expires) {
+			r.sticky.Delete(k)
+		}
+		return true
+	})
 }
 
 // Get returns a harness by name.
@@ -137,12 +656,55 @@ func (r *Router) ListAllModels(ctx context.Context) map[string][]harness.ModelIn
 	return result
 }
 
-// AllModels returns a flat list of all models from all harnesses.
-func (r *Router) AllModels(ctx context.Context) []harness.ModelInfo {
-	modelMap := r.ListAllModels(ctx)
+// AllModels returns one page of the models of all harnesses, sorted by ID
+// with each ID listed once, and the total number of models. The page starts
+// at offset and holds at most limit models; a limit of zero or less returns
+// the rest.
+func (r *Router) AllModels(ctx context.Context, offset, limit int) ([]harness.ModelInfo, int) {
+	all := r.sortedModels(ctx)
+	return paginate(all, offset, limit), len(all)
+}
+
+// AllModelsAfter is AllModels with a cursor: the page starts at the first
+// model whose ID sorts after the given one, or at the beginning when after
+// is empty.
+func (r *Router) AllModelsAfter(ctx context.Context, after string, limit int) ([]harness.ModelInfo, int) {
+	all := r.sortedModels(ctx)
+	offset := 0
+	if after != "" {
+		offset = sort.Search(len(all), func(i int) bool { return all[i].ID > after })
+	}
+	return paginate(all, offset, limit), len(all)
+}
+
+// sortedModels lists every harness's models by ID. When several harnesses
+// list the same ID, the first registered wins.
+func (r *Router) sortedModels(ctx context.Context) []harness.ModelInfo {
+	byName := r.ListAllModels(ctx)
+	seen := map[string]bool{}
 	var all []harness.ModelInfo
-	for _, models := range modelMap {
-		all = append(all, models...)
+	for _, name := range r.List() {
+		for _, m := range byName[name] {
+			if !seen[m.ID] {
+				seen[m.ID] = true
+				all = append(all, m)
+			}
+		}
 	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
 	return all
 }
+
+func paginate(models []harness.ModelInfo, offset, limit int) []harness.ModelInfo {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(models) {
+		return nil
+	}
+	models = models[offset:]
+	if limit > 0 && limit < len(models) {
+		models = models[:limit]
+	}
+	return models
+}
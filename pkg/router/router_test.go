@@ -2,8 +2,16 @@ package router
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"math/rand/v2"
+	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"godex/pkg/harness"
 )
@@ -12,6 +20,8 @@ import (
 type stubHarness struct {
 	name     string
 	models   []harness.ModelInfo
+	caps     harness.CapabilitySet
+	capsHits int
 	aliases  map[string]string
 	prefixes []string
 }
@@ -26,6 +36,16 @@ func (s *stubHarness) StreamAndCollect(ctx context.Context, turn *harness.Turn)
 func (s *stubHarness) RunToolLoop(ctx context.Context, turn *harness.Turn, handler harness.ToolHandler, opts harness.LoopOptions) (*harness.TurnResult, error) {
 	return &harness.TurnResult{}, nil
 }
+func (s *stubHarness) BatchTurns(ctx context.Context, turns []*harness.Turn, onResult func(int, *harness.TurnResult, error), opts harness.LoopOptions) error {
+	return nil
+}
+func (s *stubHarness) Capabilities(ctx context.Context) (harness.CapabilitySet, error) {
+	s.capsHits++
+	return s.caps, nil
+}
+func (s *stubHarness) CountTokens(ctx context.Context, turn *harness.Turn) (int, error) {
+	return 0, nil
+}
 func (s *stubHarness) ListModels(ctx context.Context) ([]harness.ModelInfo, error) {
 	return s.models, nil
 }
@@ -160,9 +180,55 @@ func TestAllModels(t *testing.T) {
 	r.Register("a", &stubHarness{name: "a", models: []harness.ModelInfo{{ID: "m1"}}})
 	r.Register("b", &stubHarness{name: "b", models: []harness.ModelInfo{{ID: "m2"}, {ID: "m3"}}})
 
-	all := r.AllModels(context.Background())
-	if len(all) != 3 {
-		t.Errorf("AllModels() returned %d models, want 3", len(all))
+	all, total := r.AllModels(context.Background(), 0, 0)
+	if len(all) != 3 || total != 3 {
+		t.Errorf("AllModels() returned %d models of %d, want 3", len(all), total)
+	}
+}
+
+func TestAllModels_Pagination(t *testing.T) {
+	r := New(Config{})
+	r.Register("a", &stubHarness{name: "a", models: []harness.ModelInfo{{ID: "m4"}, {ID: "m1"}, {ID: "m3", Name: "from a"}}})
+	r.Register("b", &stubHarness{name: "b", models: []harness.ModelInfo{{ID: "m2"}, {ID: "m3", Name: "from b"}, {ID: "m5"}}})
+	ctx := context.Background()
+
+	ids := func(models []harness.ModelInfo) string {
+		var out []string
+		for _, m := range models {
+			out = append(out, m.ID)
+		}
+		return strings.Join(out, ",")
+	}
+	tests := []struct {
+		offset, limit int
+		want          string
+	}{
+		{0, 0, "m1,m2,m3,m4,m5"},
+		{0, 2, "m1,m2"},
+		{2, 2, "m3,m4"},
+		{4, 2, "m5"},
+		{5, 2, ""},
+		{-1, 1, "m1"},
+	}
+	for _, tt := range tests {
+		page, total := r.AllModels(ctx, tt.offset, tt.limit)
+		if got := ids(page); got != tt.want || total != 5 {
+			t.Errorf("AllModels(%d, %d) = %q of %d, want %q of 5", tt.offset, tt.limit, got, total, tt.want)
+		}
+	}
+
+	page, _ := r.AllModels(ctx, 2, 1)
+	if page[0].Name != "from a" {
+		t.Errorf("expected the first registered backend's m3, got %+v", page[0])
+	}
+	if page, total := r.AllModelsAfter(ctx, "m2", 2); ids(page) != "m3,m4" || total != 5 {
+		t.Errorf("AllModelsAfter(m2) = %q of %d", ids(page), total)
+	}
+	if page, _ := r.AllModelsAfter(ctx, "m25", 0); ids(page) != "m3,m4,m5" {
+		t.Errorf("AllModelsAfter(m25) = %q, want models after the cursor position", ids(page))
+	}
+	if page, _ := r.AllModelsAfter(ctx, "m5", 2); len(page) != 0 {
+		t.Errorf("expected an empty last page, got %q", ids(page))
 	}
 }
 
@@ -190,3 +256,605 @@ func TestMultiHarness_FirstMatchWins(t *testing.T) {
 		t.Errorf("expected first, got %v", h)
 	}
 }
+
+func TestCandidates_OrderedByPriority(t *testing.T) {
+	r := New(Config{
+		UserPatterns: map[string][]string{
+			"custom": {"gpt-"},
+		},
+	})
+	r.Register("first", &stubHarness{name: "first", prefixes: []string{"gpt-"}})
+	r.Register("second", &stubHarness{name: "second", prefixes: []string{"gpt-"}})
+	r.Register("custom", &stubHarness{name: "custom"})
+	r.Register("other", &stubHarness{name: "other", prefixes: []string{"claude-"}})
+
+	got := r.Candidates("gpt-5")
+	want := []string{"custom", "first", "second"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Candidates(gpt-5) = %v, want %v", got, want)
+	}
+	if got := r.Candidates("unknown"); len(got) != 0 {
+		t.Errorf("Candidates(unknown) = %v, want none", got)
+	}
+}
+
+func TestHarnessFor_UserPatternsFollowRegistrationOrder(t *testing.T) {
+	r := New(Config{UserPatterns: map[string][]string{
+		"a": {"gpt-"}, "b": {"gpt-"}, "c": {"gpt-"}, "d": {"gpt-"},
+	}})
+	for _, name := range []string{"c", "a", "d", "b"} {
+		r.Register(name, &stubHarness{name: name})
+	}
+	// Map iteration order is random; repeat to catch a dependence on it.
+	for i := 0; i < 50; i++ {
+		if got := r.HarnessFor("gpt-5").Name(); got != "c" {
+			t.Fatalf("HarnessFor(gpt-5) = %s, want the first registered (c)", got)
+		}
+	}
+}
+
+func TestHarnessForSession_Sticky(t *testing.T) {
+	r := New(Config{StickySessionTTL: time.Minute})
+	defer r.Close()
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	first := &stubHarness{name: "first", prefixes: []string{"gpt-"}}
+	second := &stubHarness{name: "second", prefixes: []string{"gpt-"}}
+	other := &stubHarness{name: "other", prefixes: []string{"claude-"}}
+	r.Register("first", first)
+	r.Register("second", second)
+	r.Register("other", other)
+
+	// A new session takes the first candidate and is pinned to it.
+	if h := r.HarnessForSession("s1", "gpt-5"); h != first {
+		t.Fatalf("expected first, got %v", h)
+	}
+	if name, ok := r.Pinned("s1"); !ok || name != "first" {
+		t.Fatalf("expected s1 pinned to first, got %q %v", name, ok)
+	}
+
+	// An existing pin wins over the default order.
+	r.Pin("s2", "second")
+	if h := r.HarnessForSession("s2", "gpt-5"); h != second {
+		t.Fatalf("expected pinned second, got %v", h)
+	}
+
+	// A pinned backend that cannot serve the model is ignored and re-pinned.
+	if h := r.HarnessForSession("s2", "claude-sonnet-4-5"); h != other {
+		t.Fatalf("expected other for claude model, got %v", h)
+	}
+	if name, _ := r.Pinned("s2"); name != "other" {
+		t.Fatalf("expected s2 re-pinned to other, got %q", name)
+	}
+
+	// Each routed request renews the pin, so an active session stays put.
+	now = now.Add(50 * time.Second)
+	r.HarnessForSession("s1", "gpt-5")
+	now = now.Add(50 * time.Second)
+	if name, ok := r.Pinned("s1"); !ok || name != "first" {
+		t.Fatalf("expected the s1 pin to be renewed, got %q %v", name, ok)
+	}
+
+	// Pins expire after the TTL of inactivity.
+	now = now.Add(2 * time.Minute)
+	if _, ok := r.Pinned("s1"); ok {
+		t.Fatal("expected s1 pin to expire")
+	}
+	r.Pin("s3", "second")
+	now = now.Add(2 * time.Minute)
+	r.pruneSticky()
+	if _, ok := r.sticky.Load("s3"); ok {
+		t.Fatal("expected cleanup to drop expired pin")
+	}
+}
+
+func TestHarnessForSession_Disabled(t *testing.T) {
+	r := New(Config{})
+	r.Register("first", &stubHarness{name: "first", prefixes: []string{"gpt-"}})
+	r.Pin("s1", "first")
+	if _, ok := r.Pinned("s1"); ok {
+		t.Fatal("expected no pins when stickiness is disabled")
+	}
+	if h := r.HarnessForSession("s1", "gpt-5"); h == nil {
+		t.Fatal("expected normal routing")
+	}
+}
+
+func TestHarnessWithCapabilities_VisionRoutesToCapableBackend(t *testing.T) {
+	r := New(Config{})
+	textOnly := &stubHarness{name: "text", prefixes: []string{"gpt-"}, caps: harness.CapabilitySet{SupportsTools: true}}
+	vision := &stubHarness{name: "vision", prefixes: []string{"gpt-"}, caps: harness.CapabilitySet{SupportsVision: true}}
+	r.Register("text", textOnly)
+	r.Register("vision", vision)
+
+	need := harness.CapabilitySet{SupportsVision: true}
+	if h := r.HarnessWithCapabilities(context.Background(), "", "gpt-4o", need); h != vision {
+		t.Fatalf("expected vision backend, got %v", h)
+	}
+	if h := r.HarnessWithCapabilities(context.Background(), "", "gpt-4o", harness.CapabilitySet{}); h != textOnly {
+		t.Fatalf("expected first backend without requirements, got %v", h)
+	}
+	if got := r.CandidatesFor(context.Background(), "gpt-4o", need); len(got) != 1 || got[0] != "vision" {
+		t.Fatalf("unexpected candidates %v", got)
+	}
+
+	// Capability sets are cached per backend and model.
+	_ = r.HarnessWithCapabilities(context.Background(), "", "gpt-4o", need)
+	if textOnly.capsHits != 1 || vision.capsHits != 1 {
+		t.Fatalf("expected one Capabilities call per backend, got %d and %d", textOnly.capsHits, vision.capsHits)
+	}
+}
+
+func TestHarnessWithCapabilities_NoCapableBackend(t *testing.T) {
+	r := New(Config{})
+	r.Register("text", &stubHarness{name: "text", prefixes: []string{"gpt-"}})
+	if h := r.HarnessWithCapabilities(context.Background(), "", "gpt-4o", harness.CapabilitySet{SupportsVision: true}); h != nil {
+		t.Fatalf("expected no backend, got %v", h)
+	}
+}
+
+func TestHarnessForRequest_Override(t *testing.T) {
+	r := New(Config{})
+	codex := &stubHarness{name: "codex", prefixes: []string{"gpt-"}}
+	claude := &stubHarness{name: "claude", prefixes: []string{"claude-"}}
+	r.Register("codex", codex)
+	r.Register("claude", claude)
+
+	if h := r.HarnessForRequest("gpt-5", "claude"); h != claude {
+		t.Fatalf("expected the override to skip pattern matching, got %v", h)
+	}
+	if h := r.HarnessForRequest("gpt-5", "missing"); h != codex {
+		t.Fatalf("expected an unknown override to route normally, got %v", h)
+	}
+	if h := r.HarnessForRequest("gpt-5", ""); h != codex {
+		t.Fatalf("expected no override to route normally, got %v", h)
+	}
+	if stats := r.Stats(); stats["claude"] != 1 || stats["codex"] != 2 {
+		t.Fatalf("expected overrides counted as picks, got %v", stats)
+	}
+}
+
+func TestHarnessFor_Weighted(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	randIntN = rng.IntN
+	t.Cleanup(func() { randIntN = rand.IntN })
+
+	r := New(Config{Weights: map[string]int{"primary": 2, "secondary": 1}})
+	primary := &stubHarness{name: "primary", prefixes: []string{"gpt-"}}
+	secondary := &stubHarness{name: "secondary", prefixes: []string{"gpt-"}}
+	r.Register("primary", primary)
+	r.Register("secondary", secondary)
+
+	const runs = 1000
+	for i := 0; i < runs; i++ {
+		if h := r.HarnessFor("gpt-4o"); h != primary && h != secondary {
+			t.Fatalf("unexpected harness %v", h)
+		}
+	}
+	stats := r.Stats()
+	if stats["primary"]+stats["secondary"] != runs {
+		t.Fatalf("expected %d selections, got %v", runs, stats)
+	}
+	want := float64(runs) * 2 / 3
+	if got := float64(stats["primary"]); math.Abs(got-want) > want*0.05 {
+		t.Fatalf("primary chosen %v times, want %.0f ±5%%", got, want)
+	}
+}
+
+func TestWeightedRandom_DefaultWeight(t *testing.T) {
+	randIntN = func(n int) int {
+		if n != 4 {
+			t.Fatalf("expected total weight 4, got %d", n)
+		}
+		return 3
+	}
+	t.Cleanup(func() { randIntN = rand.IntN })
+
+	// "b" has no weight and counts as 1, after "a"'s 3.
+	if got := weightedRandom([]string{"a", "b"}, map[string]int{"a": 3}); got != "b" {
+		t.Fatalf("expected b, got %q", got)
+	}
+}
+
+func TestWeightedRandom_ZeroWeightDrains(t *testing.T) {
+	randIntN = func(n int) int {
+		if n != 1 {
+			t.Fatalf("expected total weight 1, got %d", n)
+		}
+		return 0
+	}
+	t.Cleanup(func() { randIntN = rand.IntN })
+
+	// "a" is drained, so only "b" can be drawn.
+	if got := weightedRandom([]string{"a", "b"}, map[string]int{"a": 0}); got != "b" {
+		t.Fatalf("expected b, got %q", got)
+	}
+	// A drained backend still serves when it is the only one left.
+	if got := weightedRandom([]string{"a"}, map[string]int{"a": 0}); got != "a" {
+		t.Fatalf("expected a, got %q", got)
+	}
+}
+
+func TestHarnessFor_UnweightedPicksFirst(t *testing.T) {
+	r := New(Config{})
+	first := &stubHarness{name: "first", prefixes: []string{"gpt-"}}
+	r.Register("first", first)
+	r.Register("second", &stubHarness{name: "second", prefixes: []string{"gpt-"}})
+	for i := 0; i < 10; i++ {
+		if h := r.HarnessFor("gpt-4o"); h != first {
+			t.Fatalf("expected first backend, got %v", h)
+		}
+	}
+	if stats := r.Stats(); stats["first"] != 10 || stats["second"] != 0 {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+}
+
+func TestStats_CountsRoutedRequestsOnly(t *testing.T) {
+	r := New(Config{
+		StickySessionTTL: time.Minute,
+		Canary:           CanaryConfig{Backend: "canary", Percent: 50},
+	})
+	defer r.Close()
+	r.Register("primary", &stubHarness{name: "primary", prefixes: []string{"gpt-"}})
+	r.Register("canary", &stubHarness{name: "canary"})
+
+	// Choose and Canary decide without counting; only the final pick does.
+	for i := 0; i < 10; i++ {
+		r.Choose(r.Candidates("gpt-4o"))
+		r.Canary(context.Background(), "s", "gpt-4o", harness.CapabilitySet{})
+	}
+	if got := r.Stats(); len(got) != 0 {
+		t.Fatalf("expected no picks before routing, got %v", got)
+	}
+
+	// A pinned session counts every request it routes.
+	for i := 0; i < 3; i++ {
+		r.HarnessForSession("sticky", "gpt-4o")
+	}
+	for i := 0; i < 7; i++ {
+		r.HarnessFor("gpt-4o")
+	}
+	stats := r.Stats()
+	if total := stats["primary"] + stats["canary"]; total != 10 {
+		t.Fatalf("expected 10 routed requests, got %v", stats)
+	}
+}
+
+func TestHarnessFor_LatencyRouting(t *testing.T) {
+	r := New(Config{LatencyRouting: true, WarmupRequests: 2})
+	slow := &stubHarness{name: "slow", prefixes: []string{"gpt-"}}
+	fast := &stubHarness{name: "fast", prefixes: []string{"gpt-"}}
+	r.Register("slow", slow)
+	r.Register("fast", fast)
+
+	// Warm-up: candidates take turns until each has two samples.
+	if a, b := r.HarnessFor("gpt-4o"), r.HarnessFor("gpt-4o"); a != slow || b != fast {
+		t.Fatalf("expected round-robin during warm-up, got %v then %v", a, b)
+	}
+	r.RecordLatency("slow", 900*time.Millisecond)
+	r.RecordLatency("slow", 800*time.Millisecond)
+	r.RecordLatency("fast", 100*time.Millisecond)
+	if h := r.HarnessFor("gpt-4o"); h != slow {
+		t.Fatalf("expected round-robin while fast is warming up, got %v", h)
+	}
+	r.RecordLatency("fast", 300*time.Millisecond)
+	for i := 0; i < 5; i++ {
+		if h := r.HarnessFor("gpt-4o"); h != fast {
+			t.Fatalf("expected fastest backend, got %v", h)
+		}
+	}
+
+	// Only the last 100 samples count: fast slows down for good.
+	for i := 0; i < 100; i++ {
+		r.RecordLatency("fast", 2*time.Second)
+	}
+	if avg, n := r.AverageLatency("fast"); avg != 2*time.Second || n != 100 {
+		t.Fatalf("expected a 2s average over 100 samples, got %v over %d", avg, n)
+	}
+	if h := r.HarnessFor("gpt-4o"); h != slow {
+		t.Fatalf("expected slow backend once fast degraded, got %v", h)
+	}
+}
+
+func TestRoutable_PicksNothing(t *testing.T) {
+	r := New(Config{LatencyRouting: true, Canary: CanaryConfig{Backend: "canary", ModelPattern: "claude-", Percent: 100}})
+	a := &stubHarness{name: "a", prefixes: []string{"gpt-"}}
+	b := &stubHarness{name: "b", prefixes: []string{"gpt-"}}
+	r.Register("a", a)
+	r.Register("b", b)
+	r.Register("canary", &stubHarness{name: "canary"})
+
+	for i := 0; i < 3; i++ {
+		if !r.Routable("gpt-4o") || !r.Routable("claude-sonnet-4") {
+			t.Fatal("expected both models to be routable")
+		}
+	}
+	if r.Routable("llama-3") {
+		t.Fatal("expected an unmatched model to be unroutable")
+	}
+	if got := r.Stats(); len(got) != 0 {
+		t.Fatalf("Routable should not count picks, got %v", got)
+	}
+	// The warm-up rotation has not moved: the first real pick is still a.
+	if h := r.HarnessFor("gpt-4o"); h != a {
+		t.Fatalf("expected the rotation to start at a, got %v", h)
+	}
+
+	r.MarkUnhealthy("a")
+	r.MarkUnhealthy("b")
+	if r.Routable("gpt-4o") {
+		t.Fatal("expected no route once every backend is unhealthy")
+	}
+}
+
+func TestNameOf(t *testing.T) {
+	r := New(Config{})
+	h := &stubHarness{name: "claude"}
+	r.Register("anthropic", h)
+	if got := r.NameOf(h); got != "anthropic" {
+		t.Fatalf("expected registered name, got %q", got)
+	}
+	if got := r.NameOf(&stubHarness{name: "other"}); got != "" {
+		t.Fatalf("expected empty name, got %q", got)
+	}
+}
+
+func TestHarnessForSession_Canary(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	r := New(Config{Canary: CanaryConfig{Backend: "canary", ModelPattern: "claude-", Percent: 10}})
+	primary := &stubHarness{name: "primary", prefixes: []string{"claude-"}}
+	canary := &stubHarness{name: "canary"}
+	r.Register("primary", primary)
+	r.Register("canary", canary)
+
+	const runs = 10000
+	rng := rand.New(rand.NewPCG(3, 4))
+	hits := 0
+	for i := 0; i < runs; i++ {
+		switch r.HarnessForSession(fmt.Sprintf("session-%d", rng.Uint64()), "claude-sonnet-4") {
+		case canary:
+			hits++
+		case primary:
+		default:
+			t.Fatal("expected primary or canary")
+		}
+	}
+	if pct := float64(hits) * 100 / runs; math.Abs(pct-10) > 2 {
+		t.Fatalf("canary got %.2f%% of requests, want 10%% ±2%%", pct)
+	}
+	if got := r.Stats()["canary"]; got != int64(hits) {
+		t.Fatalf("expected %d canary picks in stats, got %d", hits, got)
+	}
+
+	// Models outside the pattern never reach the canary.
+	for i := 0; i < 100; i++ {
+		if h := r.HarnessFor("claude"); h == canary {
+			t.Fatal("canary chosen for a model outside its pattern")
+		}
+	}
+}
+
+func TestCanary_Unregistered(t *testing.T) {
+	r := New(Config{Canary: CanaryConfig{Backend: "missing", Percent: 100}})
+	primary := &stubHarness{name: "primary", prefixes: []string{"gpt-"}}
+	r.Register("primary", primary)
+	if h := r.HarnessFor("gpt-4o"); h != primary {
+		t.Fatalf("expected primary, got %v", h)
+	}
+}
+
+func TestHarnessForSession_StickyCanary(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	r := New(Config{StickySessionTTL: time.Minute, Canary: CanaryConfig{Backend: "canary", Percent: 100}})
+	defer r.Close()
+	canary := &stubHarness{name: "canary"}
+	r.Register("primary", &stubHarness{name: "primary", prefixes: []string{"gpt-"}})
+	r.Register("canary", canary)
+	if h := r.HarnessForSession("s1", "gpt-4o"); h != canary {
+		t.Fatalf("expected canary, got %v", h)
+	}
+	r.config.Canary.Percent = 0.01
+	if h := r.HarnessForSession("s1", "gpt-4o"); h != canary {
+		t.Fatalf("expected session to stay on canary, got %v", h)
+	}
+}
+
+func TestExplainRouting(t *testing.T) {
+	r := New(Config{
+		UserAliases:  map[string]string{"fast": "gpt-4o-mini"},
+		UserPatterns: map[string][]string{"openrouter": {"gpt-4o"}},
+	})
+	r.Register("codex", &stubHarness{name: "codex", prefixes: []string{"gpt-"}})
+	r.Register("openrouter", &stubHarness{name: "openrouter"})
+
+	alias := r.ExplainRouting("fast")
+	if alias.ExpandedAlias != "gpt-4o-mini" || alias.MatchedBackend != "openrouter" || alias.MatchedPattern != "gpt-4o" {
+		t.Fatalf("unexpected alias explanation %+v", alias)
+	}
+	if len(alias.AllCandidates) != 2 || alias.AllCandidates[1] != (BackendMatch{Backend: "codex"}) {
+		t.Fatalf("unexpected candidates %+v", alias.AllCandidates)
+	}
+
+	pattern := r.ExplainRouting("gpt-4o")
+	if pattern.ExpandedAlias != "" || pattern.MatchedBackend != "openrouter" || pattern.MatchedPattern != "gpt-4o" {
+		t.Fatalf("unexpected pattern explanation %+v", pattern)
+	}
+
+	fallback := r.ExplainRouting("gpt-5")
+	if fallback.MatchedBackend != "codex" || fallback.MatchedPattern != "" {
+		t.Fatalf("unexpected fallback explanation %+v", fallback)
+	}
+
+	none := r.ExplainRouting("llama-3")
+	if none.MatchedBackend != "" || len(none.AllCandidates) != 0 {
+		t.Fatalf("unexpected default explanation %+v", none)
+	}
+
+	reasons := map[string]bool{}
+	for _, e := range []RouteExplanation{alias, pattern, fallback, none} {
+		if e.Reason == "" || reasons[e.Reason] {
+			t.Fatalf("expected a distinct reason, got %q", e.Reason)
+		}
+		reasons[e.Reason] = true
+	}
+	if got := r.Stats(); len(got) != 0 {
+		t.Fatalf("explaining should not count picks, got %v", got)
+	}
+	if !strings.Contains(fallback.String(), "backend=codex") {
+		t.Fatalf("unexpected log line %q", fallback.String())
+	}
+}
+
+func TestHarnessFor_SkipsUnhealthyBackend(t *testing.T) {
+	r := New(Config{})
+	primary := &stubHarness{name: "primary", prefixes: []string{"gpt-"}}
+	backup := &stubHarness{name: "backup", prefixes: []string{"gpt-"}}
+	r.Register("primary", primary)
+	r.Register("backup", backup)
+
+	var healthy atomic.Bool
+	r.RegisterHealthCheck("primary", func(ctx context.Context) bool { return healthy.Load() })
+	r.CheckHealth(context.Background())
+	if h := r.HarnessFor("gpt-4o"); h != backup {
+		t.Fatalf("expected backup while primary is unhealthy, got %v", h)
+	}
+	if e := r.ExplainRouting("gpt-4o"); len(e.Unhealthy) != 1 || e.Unhealthy[0] != "primary" || !strings.Contains(e.Reason, "unhealthy") {
+		t.Fatalf("unexpected explanation %+v", e)
+	}
+
+	healthy.Store(true)
+	r.CheckHealth(context.Background())
+	if h := r.HarnessFor("gpt-4o"); h != primary {
+		t.Fatalf("expected primary once healthy, got %v", h)
+	}
+
+	r.MarkUnhealthy("backup")
+	r.MarkUnhealthy("primary")
+	if h := r.HarnessFor("gpt-4o"); h != nil {
+		t.Fatalf("expected no backend when all are unhealthy, got %v", h)
+	}
+	r.MarkHealthy("backup")
+	if h := r.HarnessFor("gpt-4o"); h != backup {
+		t.Fatalf("expected backup after MarkHealthy, got %v", h)
+	}
+}
+
+func TestHealthChecksRunInBackground(t *testing.T) {
+	r := New(Config{HealthCheckInterval: 5 * time.Millisecond})
+	defer r.Close()
+	r.Register("primary", &stubHarness{name: "primary", prefixes: []string{"gpt-"}})
+	r.RegisterHealthCheck("primary", func(ctx context.Context) bool { return false })
+
+	deadline := time.Now().Add(time.Second)
+	for r.Healthy("primary") {
+		if time.Now().After(deadline) {
+			t.Fatal("health check never ran")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestHarnessFor_RegexpPatterns(t *testing.T) {
+	r := New(Config{UserPatterns: map[string][]string{
+		"exact":  {`~^claude-3\.5-sonnet$`},
+		"prefix": {"claude-"},
+	}})
+	exact := &stubHarness{name: "exact"}
+	prefix := &stubHarness{name: "prefix"}
+	r.Register("exact", exact)
+	r.Register("prefix", prefix)
+
+	tests := []struct {
+		model string
+		want  harness.Harness
+	}{
+		{"claude-3.5-sonnet", exact},
+		{"Claude-3.5-Sonnet", exact},
+		{"claude-3.5-sonnet-20241022-v2", prefix},
+		{"claude-3x5-sonnet", prefix},
+		{"claude-opus-4", prefix},
+		{"gpt-4o", nil},
+	}
+	for _, tt := range tests {
+		if got := r.HarnessFor(tt.model); got != tt.want {
+			t.Errorf("HarnessFor(%q) = %v, want %v", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPattern_InvalidRegexp(t *testing.T) {
+	if matchPattern("gpt-4", "~gpt-(4") {
+		t.Fatal("invalid regexp should match nothing")
+	}
+	if _, ok := patternRegexps.Load("gpt-(4"); !ok {
+		t.Fatal("expected the failed compile to be cached")
+	}
+}
+
+func TestHarnessFor_Priority(t *testing.T) {
+	r := New(Config{})
+	custom := &stubHarness{name: "custom", prefixes: []string{"gpt-"}}
+	anthropic := &stubHarness{name: "anthropic", prefixes: []string{"gpt-"}}
+	codex := &stubHarness{name: "codex", prefixes: []string{"gpt-"}}
+	r.Register("custom", custom)
+	r.Register("anthropic", anthropic, WithPriority(50))
+	r.Register("codex", codex, WithPriority(100))
+
+	if got := strings.Join(r.Candidates("gpt-4o"), ","); got != "codex,anthropic,custom" {
+		t.Fatalf("expected candidates by priority, got %s", got)
+	}
+	if h := r.HarnessFor("gpt-4o"); h != codex {
+		t.Fatalf("expected the highest-priority backend, got %v", h)
+	}
+	r.MarkUnhealthy("codex")
+	if h := r.HarnessFor("gpt-4o"); h != anthropic {
+		t.Fatalf("expected the next priority when the first is unhealthy, got %v", h)
+	}
+}
+
+func TestHarnessFor_UserPatternBeatsPriority(t *testing.T) {
+	r := New(Config{UserPatterns: map[string][]string{"custom": {"gpt-4o"}}})
+	custom := &stubHarness{name: "custom"}
+	r.Register("codex", &stubHarness{name: "codex", prefixes: []string{"gpt-"}}, WithPriority(100))
+	r.Register("custom", custom)
+	if h := r.HarnessFor("gpt-4o"); h != custom {
+		t.Fatalf("expected the user pattern to win over priority, got %v", h)
+	}
+}
+
+func TestHarnessFor_WeightsWithinPriority(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	randIntN = rng.IntN
+	t.Cleanup(func() { randIntN = rand.IntN })
+
+	// Anthropic's heavy weight does not lift it over Codex's priority.
+	r := New(Config{Weights: map[string]int{"anthropic": 10, "codex": 1, "codex-b": 1}})
+	codex := &stubHarness{name: "codex", prefixes: []string{"gpt-"}}
+	codexB := &stubHarness{name: "codex-b", prefixes: []string{"gpt-"}}
+	anthropic := &stubHarness{name: "anthropic", prefixes: []string{"gpt-"}}
+	r.Register("anthropic", anthropic, WithPriority(50))
+	r.Register("codex", codex, WithPriority(100))
+	r.Register("codex-b", codexB, WithPriority(100))
+
+	for i := 0; i < 200; i++ {
+		if h := r.HarnessFor("gpt-4o"); h != codex && h != codexB {
+			t.Fatalf("expected a priority-100 backend, got %v", h)
+		}
+	}
+	if stats := r.Stats(); stats["codex"] == 0 || stats["codex-b"] == 0 {
+		t.Fatalf("expected weights to spread the top tier, got %v", stats)
+	}
+
+	// With the top tier unhealthy, the next tier takes over.
+	r.MarkUnhealthy("codex")
+	r.MarkUnhealthy("codex-b")
+	if h := r.HarnessFor("gpt-4o"); h != anthropic {
+		t.Fatalf("expected the lower tier once the top is unhealthy, got %v", h)
+	}
+}
@@ -1,9 +1,18 @@
 package schema
 
+import (
+	"maps"
+	"slices"
+)
+
 // NormalizeStrictSchemaNode recursively enforces strict JSON-schema object rules:
 // - Object nodes are closed (`additionalProperties: false`)
 // - Optional object properties are made nullable and added to `required`
 //
+// It recurses into properties, items, prefixItems, additionalProperties,
+// anyOf/oneOf/allOf branches and $defs/definitions. Only generic JSON values
+// (map[string]any, []any) are walked, so decode typed schemas first.
+//
 // This matches strict tool-schema requirements used by provider APIs.
 func NormalizeStrictSchemaNode(node any) any {
 	switch n := node.(type) {
@@ -35,6 +44,13 @@ func NormalizeStrictSchemaNode(node any) any {
 		if raw, ok := n["additionalProperties"]; ok {
 			n["additionalProperties"] = NormalizeStrictSchemaNode(raw)
 		}
+		for _, k := range []string{"$defs", "definitions"} {
+			if raw, ok := n[k].(map[string]any); ok {
+				for name, def := range raw {
+					raw[name] = NormalizeStrictSchemaNode(def)
+				}
+			}
+		}
 		return n
 	case []any:
 		for i := range n {
@@ -89,11 +105,12 @@ func normalizeStrictObjectIfPresent(schema map[string]any) {
 		}
 	}
 
-	for name, prop := range props {
+	// Sorted, so the same schema always encodes the same way.
+	for _, name := range slices.Sorted(maps.Keys(props)) {
 		if requiredSet[name] {
 			continue
 		}
-		props[name] = makeSchemaNullable(prop)
+		props[name] = makeSchemaNullable(props[name])
 		requiredSet[name] = true
 		required = append(required, name)
 	}
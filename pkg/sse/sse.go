@@ -15,6 +15,19 @@ type Event struct {
 }
 
 func ParseStream(r io.Reader, emit func(Event) error) error {
+	return ParseData(r, func(raw json.RawMessage) error {
+		var ev protocol.StreamEvent
+		if err := json.Unmarshal(raw, &ev); err != nil {
+			return nil
+		}
+		return emit(Event{Raw: raw, Value: ev})
+	})
+}
+
+// ParseData calls emit with the data of each event in an SSE stream,
+// skipping empty events and the "[DONE]" sentinel. Unlike ParseStream it
+// does not assume the Responses event shape.
+func ParseData(r io.Reader, emit func(json.RawMessage) error) error {
 	s := bufio.NewScanner(r)
 	buf := make([]byte, 0, 64*1024)
 	s.Buffer(buf, 1024*1024)
@@ -29,12 +42,7 @@ func ParseStream(r io.Reader, emit func(Event) error) error {
 		if strings.TrimSpace(joined) == "" || strings.TrimSpace(joined) == "[DONE]" {
 			return nil
 		}
-		raw := json.RawMessage(joined)
-		var ev protocol.StreamEvent
-		if err := json.Unmarshal(raw, &ev); err != nil {
-			return nil
-		}
-		return emit(Event{Raw: raw, Value: ev})
+		return emit(json.RawMessage(joined))
 	}
 
 	for s.Scan() {
@@ -64,6 +72,7 @@ type Collector struct {
 	callNames    map[string]string
 	itemArgs     map[string]*strings.Builder
 	emittedCalls map[string]bool
+	emittedItems map[string]bool
 	text         strings.Builder
 }
 
@@ -74,6 +83,7 @@ func NewCollector() *Collector {
 		callNames:    map[string]string{},
 		itemArgs:     map[string]*strings.Builder{},
 		emittedCalls: map[string]bool{},
+		emittedItems: map[string]bool{},
 	}
 }
 
@@ -200,6 +210,19 @@ func (c *Collector) MarkToolCallEmitted(callID string) bool {
 	return true
 }
 
+// MarkItemEmitted is MarkToolCallEmitted for output items that are not
+// tool calls, keyed by item ID.
+func (c *Collector) MarkItemEmitted(itemID string) bool {
+	if itemID == "" {
+		return true
+	}
+	if c.emittedItems[itemID] {
+		return false
+	}
+	c.emittedItems[itemID] = true
+	return true
+}
+
 func (c *Collector) ensureCallBuilder(callID string) *strings.Builder {
 	if b := c.callArgs[callID]; b != nil {
 		return b